@@ -88,6 +88,7 @@ func main() {
 		),
 		cmds.NewCompletionCommand(internalCLIAction(version.Program+"-completion", dataDir, os.Args)),
 		cmds.NewSandboxApiKeyCommand(),
+		cmds.NewSandboxCertCommand(),
 		cmds.NewE2BServerCommand(internalCLIAction(version.Program+"-e2b-server", dataDir, os.Args)),
 	}
 
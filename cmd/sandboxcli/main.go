@@ -29,6 +29,7 @@ func main() {
 	commands := []cli.Command{
 		sandboxcli.ConnectCommand(),
 		sandboxcli.LoginCommand(),
+		sandboxcli.CertCommand(),
 		sandboxcli.DoctorCommand(),
 		sandboxcli.RunCommand(),
 		sandboxcli.StatusCommand(),
@@ -40,8 +41,13 @@ func main() {
 		sandboxcli.ReadCommand(),
 		sandboxcli.ListCommand(),
 		sandboxcli.SubagentCommand(),
+		sandboxcli.ForkCommand(),
 		sandboxcli.ConfirmCommand(),
 		sandboxcli.ApproveCommand(),
+		sandboxcli.ApprovalsCommand(),
+		sandboxcli.WatchCommand(),
+		sandboxcli.AuditCommand(),
+		sandboxcli.UsageCommand(),
 		sandboxcli.SnapshotCommand(),
 		sandboxcli.PollCommand(),
 		sandboxcli.LogCommand(),
@@ -56,6 +62,8 @@ func main() {
 	// M9 catalog: emits the full command surface (single source for SDK stubs).
 	commands = append(commands, sandboxcli.CatalogCommand(commands))
 	app.Commands = commands
+	app.Before = sandboxcli.StartTracing
+	app.After = sandboxcli.StopTracing
 
 	if err := app.Run(os.Args); err != nil {
 		var exitErr *sandboxcli.ExitError
@@ -75,6 +75,7 @@ func NewAppCommands(f AppCommandFuncs) []cli.Command {
 		),
 		NewCompletionCommand(f.Completion),
 		NewSandboxApiKeyCommand(),
+		NewSandboxCertCommand(),
 		NewE2BServerCommand(f.E2BServer),
 	}
 }
@@ -85,3 +86,11 @@ func NewSandboxApiKeyCommand() cli.Command {
 	cmd.Name = "sandbox-apikey"
 	return cmd
 }
+
+// NewSandboxCertCommand returns the sandbox client-cert command renamed for the
+// server binary (the plain "certificate" command manages cluster certs).
+func NewSandboxCertCommand() cli.Command {
+	cmd := sandboxcli.CertCommand()
+	cmd.Name = "sandbox-cert"
+	return cmd
+}
@@ -1,6 +1,8 @@
 package cmds
 
 import (
+	"time"
+
 	"github.com/urfave/cli"
 )
 
@@ -15,6 +17,9 @@ type E2BServerConfig struct {
 	DefaultMemoryMB     int
 	DefaultDiskMB       int
 	AllowedRuntimeClasses cli.StringSlice
+	AuditDir            string
+	AuditRetention      time.Duration
+	ExposeDomain        string
 }
 
 var (
@@ -82,6 +87,25 @@ var (
 			Value: &E2BServer.AllowedRuntimeClasses,
 			EnvVar: "K8E_E2B_RUNTIMES",
 		},
+		cli.StringFlag{
+			Name:        "audit-dir",
+			Usage:       "(e2b) Directory for the append-only request audit log (NDJSON, rotated daily or at 64 MiB); empty disables it",
+			Destination: &E2BServer.AuditDir,
+			EnvVar:      "K8E_E2B_AUDIT_DIR",
+		},
+		cli.DurationFlag{
+			Name:        "audit-retention",
+			Usage:       "(e2b) How long rotated audit log files are kept",
+			Value:       90 * 24 * time.Hour,
+			Destination: &E2BServer.AuditRetention,
+			EnvVar:      "K8E_E2B_AUDIT_RETENTION",
+		},
+		cli.StringFlag{
+			Name:        "expose-domain",
+			Usage:       "(e2b) Wildcard DNS domain routed to this server: serve each exposed service on its own origin, <port>-<session>.<domain>; must match the gateway's --sandbox-expose-domain",
+			Destination: &E2BServer.ExposeDomain,
+			EnvVar:      "K8E_SANDBOX_EXPOSE_DOMAIN",
+		},
 	}
 )
 
@@ -117,6 +117,20 @@ type Server struct {
 	SandboxNamespace         string
 	SandboxAdvertiseHostname string
 	SandboxExposeBaseURL     string
+	SandboxExposeDomain      string
+	SandboxLayerStoreS3      bool
+	SandboxLayerStoreBucket  string
+	SandboxLayerStoreFolder  string
+	SandboxApprovalWebhook   string
+	SandboxApprovalSecret    string
+	SandboxAuditRetention    time.Duration
+	SandboxMetricsMaxTenants int
+	SandboxTracingEndpoint   string
+	SandboxTracingInsecure   bool
+	SandboxTracingFile       string
+	SandboxTracingRatio      float64
+	SandboxCheckpointClasses cli.StringSlice
+	SandboxEgressProxyPort   int
 }
 
 var (
@@ -616,6 +630,94 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.SandboxExposeBaseURL,
 		EnvVar:      "K8E_SANDBOX_EXPOSE_BASE_URL",
 	},
+	&cli.StringFlag{
+		Name:        "sandbox-expose-domain",
+		Usage:       "(sandbox) Wildcard DNS domain routed to the e2b server (*.<domain>): each KIP-24 exposed service gets its own origin, <scheme>://<port>-<session>.<domain>[:port]/ (scheme and port from --sandbox-expose-base-url). Browsers keep a token or signed URL working through a cookie only on such an origin; on the shared /k8e/expose/ path every request must carry the credential. K8E_SANDBOX_EXPOSE_DOMAIN",
+		Destination: &ServerConfig.SandboxExposeDomain,
+		EnvVar:      "K8E_SANDBOX_EXPOSE_DOMAIN",
+	},
+	&cli.BoolFlag{
+		Name:        "sandbox-layer-store-s3",
+		Usage:       "(sandbox) Keep the snapshot layer registry in S3 so every server shares it (KIP-16 M2). Uses the --etcd-s3-endpoint, -region, -access-key, -secret-key, -endpoint-ca, -skip-ssl-verify, -insecure, -proxy and -timeout settings; the local layer directory becomes a pull-through cache",
+		Destination: &ServerConfig.SandboxLayerStoreS3,
+		EnvVar:      "K8E_SANDBOX_LAYER_STORE_S3",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-layer-store-s3-bucket",
+		Usage:       "(sandbox) S3 bucket for the snapshot layer registry (default: --etcd-s3-bucket)",
+		Destination: &ServerConfig.SandboxLayerStoreBucket,
+		EnvVar:      "K8E_SANDBOX_LAYER_STORE_S3_BUCKET",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-layer-store-s3-folder",
+		Usage:       "(sandbox) S3 folder for the snapshot layer registry",
+		Value:       "sandbox-layers",
+		Destination: &ServerConfig.SandboxLayerStoreFolder,
+		EnvVar:      "K8E_SANDBOX_LAYER_STORE_S3_FOLDER",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-approval-webhook-url",
+		Usage:       "(sandbox) URL sent the approval.requested webhook event for every new ConfirmAction approval, e.g. a chat-ops bridge. K8E_SANDBOX_APPROVAL_WEBHOOK_URL",
+		Destination: &ServerConfig.SandboxApprovalWebhook,
+		EnvVar:      "K8E_SANDBOX_APPROVAL_WEBHOOK_URL",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-approval-webhook-secret",
+		Usage:       "(sandbox) HMAC-SHA256 key signing approval webhook deliveries (X-K8E-Signature: sha256=<hex> over X-K8E-Timestamp + "." + body). K8E_SANDBOX_APPROVAL_WEBHOOK_SECRET",
+		Destination: &ServerConfig.SandboxApprovalSecret,
+		EnvVar:      "K8E_SANDBOX_APPROVAL_WEBHOOK_SECRET",
+	},
+	&cli.DurationFlag{
+		Name:        "sandbox-audit-retention",
+		Usage:       "(sandbox) How long rotated gateway/E2B audit log files under ${data-dir}/server/sandbox-audit are kept. K8E_SANDBOX_AUDIT_RETENTION",
+		Value:       90 * 24 * time.Hour,
+		Destination: &ServerConfig.SandboxAuditRetention,
+		EnvVar:      "K8E_SANDBOX_AUDIT_RETENTION",
+	},
+	&cli.IntFlag{
+		Name:        "sandbox-metrics-max-tenants",
+		Usage:       "(sandbox) Tenants with a SandboxTenantQuota reported by name in sandbox metric labels (first N by name); all others are reported as \"other\". K8E_SANDBOX_METRICS_MAX_TENANTS",
+		Value:       100,
+		Destination: &ServerConfig.SandboxMetricsMaxTenants,
+		EnvVar:      "K8E_SANDBOX_METRICS_MAX_TENANTS",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-tracing-endpoint",
+		Usage:       "(sandbox) OTLP/gRPC collector (host:port or URL) receiving gateway, E2B and sandboxd-call spans. K8E_SANDBOX_TRACING_ENDPOINT",
+		Destination: &ServerConfig.SandboxTracingEndpoint,
+		EnvVar:      "K8E_SANDBOX_TRACING_ENDPOINT",
+	},
+	&cli.BoolFlag{
+		Name:        "sandbox-tracing-insecure",
+		Usage:       "(sandbox) Connect to a host:port tracing endpoint without TLS. K8E_SANDBOX_TRACING_INSECURE",
+		Destination: &ServerConfig.SandboxTracingInsecure,
+		EnvVar:      "K8E_SANDBOX_TRACING_INSECURE",
+	},
+	&cli.StringFlag{
+		Name:        "sandbox-tracing-file",
+		Usage:       "(sandbox) Append spans as JSON lines to this file (\"-\" for stdout), for clusters without a collector. K8E_SANDBOX_TRACING_FILE",
+		Destination: &ServerConfig.SandboxTracingFile,
+		EnvVar:      "K8E_SANDBOX_TRACING_FILE",
+	},
+	&cli.Float64Flag{
+		Name:        "sandbox-tracing-sample-ratio",
+		Usage:       "(sandbox) Fraction of new traces recorded; calls continuing a sampled trace are always recorded. K8E_SANDBOX_TRACING_SAMPLE_RATIO",
+		Value:       1,
+		Destination: &ServerConfig.SandboxTracingRatio,
+		EnvVar:      "K8E_SANDBOX_TRACING_SAMPLE_RATIO",
+	},
+	&cli.IntFlag{
+		Name:        "sandbox-egress-proxy-port",
+		Usage:       "(sandbox) Run the egress filtering proxy on this port (0 disables) and enforce session allowlists through it with plain NetworkPolicies, for clusters without Cilium (--disable-cilium). K8E_SANDBOX_EGRESS_PROXY_PORT",
+		Destination: &ServerConfig.SandboxEgressProxyPort,
+		EnvVar:      "K8E_SANDBOX_EGRESS_PROXY_PORT",
+	},
+	&cli.StringSliceFlag{
+		Name:   "sandbox-checkpoint-runtime-classes",
+		Usage:  "(sandbox) RuntimeClasses whose CRI runtime can checkpoint and restore containers; enables memory-preserving pause for their sessions (needs the kubelet ContainerCheckpoint feature). K8E_SANDBOX_CHECKPOINT_RUNTIME_CLASSES",
+		Value:  &ServerConfig.SandboxCheckpointClasses,
+		EnvVar: "K8E_SANDBOX_CHECKPOINT_RUNTIME_CLASSES",
+	},
 
 	// Hidden/Deprecated flags below
 
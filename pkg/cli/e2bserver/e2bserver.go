@@ -5,6 +5,7 @@ package e2bserver
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rancher/wrangler/v3/pkg/signals"
@@ -13,6 +14,7 @@ import (
 	"github.com/xiaods/k8e/pkg/cli/cmds"
 	"github.com/xiaods/k8e/pkg/sandbox/client"
 	sandboxe2b "github.com/xiaods/k8e/pkg/sandbox/e2b"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 )
 
 // Run starts the E2B server and blocks until a signal arrives.
@@ -32,6 +34,19 @@ func Run(app *cli.Context) error {
 
 	ctx := signals.SetupSignalContext()
 
+	// Standalone servers take the tracing settings from the environment
+	// (K8E_SANDBOX_TRACING_*); the embedded server shares the k8e server's.
+	shutdownTracing, err := tracing.Setup(ctx, tracing.ConfigFromEnv("k8e-e2b-server"))
+	if err != nil {
+		logrus.Warnf("e2b-server: tracing disabled: %v", err)
+	} else {
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(flushCtx) //nolint:errcheck
+		}()
+	}
+
 	var gw sandboxe2b.Gateway
 	if cfg.Endpoint != "" {
 		// One key serves every role: it is accepted from e2b SDK clients (as
@@ -64,6 +79,9 @@ func Run(app *cli.Context) error {
 		DefaultMemoryMB:     cfg.DefaultMemoryMB,
 		DefaultDiskMB:       cfg.DefaultDiskMB,
 		AllowedRuntimeClasses: cfg.AllowedRuntimeClasses,
+		AuditDir:            cfg.AuditDir,
+		AuditRetention:      cfg.AuditRetention,
+		ExposeDomain:        cfg.ExposeDomain,
 	}, gw)
 
 	if err := srv.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
@@ -176,11 +176,44 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		// Server-side snapshot layer registry lives under the data dir.
 		LayerStoreDir:         filepath.Join(cfg.DataDir, "server", "sandbox-layers"),
 		CiliumDNSProxyEnabled: cfg.CiliumDNSProxyEnabled,
+		EgressProxyPort:       cfg.SandboxEgressProxyPort,
 		DisableE2B:            cfg.DisableE2B,
 		E2BListen:             cfg.E2BListen,
 		E2BAPIKey:             cfg.E2BAPIKey,
 		AdvertiseHostname:     cfg.SandboxAdvertiseHostname,
 		ExposeBaseURL:         cfg.SandboxExposeBaseURL,
+		ExposeDomain:          cfg.SandboxExposeDomain,
+		ApprovalWebhookURL:    cfg.SandboxApprovalWebhook,
+		ApprovalWebhookSecret: cfg.SandboxApprovalSecret,
+		AuditDir:              filepath.Join(cfg.DataDir, "server", "sandbox-audit"),
+		AuditRetention:        cfg.SandboxAuditRetention,
+		UsageDir:              filepath.Join(cfg.DataDir, "server", "sandbox-usage"),
+		MetricsMaxTenants:     cfg.SandboxMetricsMaxTenants,
+		TracingEndpoint:       cfg.SandboxTracingEndpoint,
+		TracingInsecure:       cfg.SandboxTracingInsecure,
+		TracingFile:           cfg.SandboxTracingFile,
+		TracingSampleRatio:    cfg.SandboxTracingRatio,
+		// Empty leaves every pause filesystem-only.
+		CheckpointRuntimeClasses: util.SplitStringSlice(cfg.SandboxCheckpointClasses),
+	}
+	if cfg.SandboxLayerStoreS3 {
+		bucket := cfg.SandboxLayerStoreBucket
+		if bucket == "" {
+			bucket = cfg.EtcdS3BucketName
+		}
+		serverConfig.ControlConfig.SandboxConfig.LayerStoreS3 = &config.EtcdS3{
+			AccessKey:     cfg.EtcdS3AccessKey,
+			Bucket:        bucket,
+			Endpoint:      cfg.EtcdS3Endpoint,
+			EndpointCA:    cfg.EtcdS3EndpointCA,
+			Folder:        cfg.SandboxLayerStoreFolder,
+			Insecure:      cfg.EtcdS3Insecure,
+			Proxy:         cfg.EtcdS3Proxy,
+			Region:        cfg.EtcdS3Region,
+			SecretKey:     cfg.EtcdS3SecretKey,
+			SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
+			Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
+		}
 	}
 	serverConfig.ControlConfig.EtcdExposeMetrics = cfg.EtcdExposeMetrics
 	serverConfig.ControlConfig.EtcdDisableSnapshots = cfg.EtcdDisableSnapshots
@@ -260,6 +293,7 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		return err
 	}
 	serverConfig.ControlConfig.ServerNodeName = nodeName
+	serverConfig.ControlConfig.SandboxConfig.NodeName = nodeName
 	serverConfig.ControlConfig.SANs = append(serverConfig.ControlConfig.SANs, "127.0.0.1", "::1", "localhost", nodeName)
 	for _, ip := range nodeIPs {
 		serverConfig.ControlConfig.SANs = append(serverConfig.ControlConfig.SANs, ip.String())
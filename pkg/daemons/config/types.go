@@ -199,9 +199,21 @@ type SandboxConfig struct {
 	// LayerStoreDir, when set, enables the server-side content-addressed
 	// snapshot layer registry (KIP-16 M2 / issue #511).
 	LayerStoreDir string
+	// LayerStoreS3, when set, keeps the layer registry in an S3-compatible
+	// bucket shared by every server, so snapshots survive losing a node and
+	// restore through any gateway; LayerStoreDir becomes its local
+	// pull-through cache.
+	LayerStoreS3 *EtcdS3
 	// CiliumDNSProxyEnabled opts into Cilium toFQDNs egress enforcement for
 	// sessions with allowedHosts (KIP-16 M10 / issue #510).
 	CiliumDNSProxyEnabled bool
+	// EgressProxyPort, when set, runs the CNI-agnostic egress proxy on every
+	// server: sandbox pods get HTTP(S)_PROXY pointing at it and a per-session
+	// NetworkPolicy replaces the CiliumNetworkPolicy.
+	EgressProxyPort int
+	// NodeName is this server's Node; with EgressProxyPort it is labelled
+	// so proxy-mode sandbox pods schedule only where a proxy runs.
+	NodeName string
 	// LeaderElectionIdentity is the per-node identity used to contend for the
 	// sandboxmatrix-controller Lease in HA clusters. Empty means hostname.
 	// Only the elected leader runs the warm-pool/GC/idle reconcilers; the
@@ -234,6 +246,38 @@ type SandboxConfig struct {
 	// Set it to the reachable gateway entry, e.g. http://gw.example.com or
 	// http://ec2-...:31422 when using NodePort without a LoadBalancer IP.
 	ExposeBaseURL string
+	// ExposeDomain, when set, is a wildcard DNS domain (*.<domain> routed to
+	// the e2b server) giving every exposed service its own origin,
+	// <port>-<session>.<domain>. Only there does the expose proxy trade
+	// credentials for a cookie, so browsing a token or signed URL works.
+	ExposeDomain string
+	// ApprovalWebhookURL, when set, receives the approval.requested webhook
+	// event for every new ConfirmAction approval, so approvers need not poll. When
+	// ApprovalWebhookSecret is set the delivery is signed with HMAC-SHA256
+	// (X-K8E-Signature: sha256=<hex> over X-K8E-Timestamp + "." + body).
+	ApprovalWebhookURL    string
+	ApprovalWebhookSecret string `json:"-"`
+	// AuditDir holds the append-only audit log of every gateway RPC and
+	// embedded E2B request (NDJSON, rotated daily or at 64 MiB); rotated
+	// files older than AuditRetention are deleted.
+	AuditDir       string
+	AuditRetention time.Duration
+	// UsageDir holds the usage ledger: per-session CPU, memory, egress,
+	// exec and snapshot totals recorded as sessions end. Never pruned.
+	UsageDir string
+	// MetricsMaxTenants caps the quota-holding tenants named on the sandbox
+	// metrics; every other tenant is reported as "other".
+	MetricsMaxTenants int
+	// TracingEndpoint (OTLP/gRPC collector) and TracingFile (JSON lines,
+	// "-" for stdout) select where gateway spans go; both empty disables
+	// tracing. TracingSampleRatio applies to new traces (zero → all).
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingFile        string
+	TracingSampleRatio float64
+	// CheckpointRuntimeClasses lists the RuntimeClasses whose runtime can
+	// checkpoint/restore containers, enabling memory-preserving pause.
+	CheckpointRuntimeClasses []string
 }
 
 type Control struct {
@@ -2,14 +2,11 @@ package s3
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/textproto"
-	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -21,11 +18,11 @@ import (
 	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pkg/errors"
 	"github.com/rancher/wrangler/v3/pkg/generated/controllers/core"
 	"github.com/sirupsen/logrus"
 	"github.com/xiaods/k8e/pkg/daemons/config"
+	"github.com/xiaods/k8e/pkg/etcd/s3/s3client"
 	"github.com/xiaods/k8e/pkg/etcd/snapshot"
 	"github.com/xiaods/k8e/pkg/util"
 	"github.com/xiaods/k8e/pkg/version"
@@ -166,57 +163,16 @@ func (c *Controller) GetClient(ctx context.Context, etcdS3 *config.EtcdS3) (*Cli
 	if etcdS3.Bucket == "" {
 		return nil, errors.New("s3 bucket name was not set")
 	}
-	tr := http.DefaultTransport.(*http.Transport).Clone()
-
-	// You can either disable SSL verification or use a custom CA bundle,
-	// it doesn't make sense to do both - if verification is disabled,
-	// the CA is not checked!
-	if etcdS3.SkipSSLVerify {
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	} else if etcdS3.EndpointCA != "" {
-		tlsConfig, err := loadEndpointCAs(etcdS3.EndpointCA)
-		if err != nil {
-			return nil, err
-		}
-		tr.TLSClientConfig = tlsConfig
-	}
-
-	// Set a fixed proxy URL, if requested by the user. This replaces the default,
-	// which calls ProxyFromEnvironment to read proxy settings from the environment.
-	if etcdS3.Proxy != "" {
-		var u *url.URL
-		var err error
-		// proxy address of literal "none" disables all use of a proxy by S3
-		if etcdS3.Proxy != "none" {
-			u, err = url.Parse(etcdS3.Proxy)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to parse etcd-s3-proxy value as URL")
-			}
-			if u.Scheme == "" || u.Host == "" {
-				return nil, fmt.Errorf("proxy URL must include scheme and host")
-			}
-		}
-		tr.Proxy = http.ProxyURL(u)
-	}
-
-	var creds *credentials.Credentials
-	if len(etcdS3.AccessKey) == 0 && len(etcdS3.SecretKey) == 0 {
-		creds = credentials.NewIAM("") // for running on ec2 instance
-		if _, err := creds.Get(); err != nil {
-			return nil, errors.Wrap(err, "failed to get IAM credentials")
-		}
-	} else {
-		creds = credentials.NewStaticV4(etcdS3.AccessKey, etcdS3.SecretKey, "")
-	}
-
-	opt := minio.Options{
-		Creds:        creds,
-		Secure:       !etcdS3.Insecure,
-		Region:       etcdS3.Region,
-		Transport:    tr,
-		BucketLookup: bucketLookupType(etcdS3.Endpoint),
-	}
-	mc, err := minio.New(etcdS3.Endpoint, &opt)
+	mc, err := s3client.New(s3client.Options{
+		Endpoint:      etcdS3.Endpoint,
+		EndpointCA:    etcdS3.EndpointCA,
+		Region:        etcdS3.Region,
+		AccessKey:     etcdS3.AccessKey,
+		SecretKey:     etcdS3.SecretKey,
+		Proxy:         etcdS3.Proxy,
+		Insecure:      etcdS3.Insecure,
+		SkipSSLVerify: etcdS3.SkipSSLVerify,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -544,37 +500,3 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 
 	return snapshots, nil
 }
-
-func loadEndpointCAs(etcdS3EndpointCA string) (*tls.Config, error) {
-	var loaded bool
-	certPool := x509.NewCertPool()
-
-	for _, ca := range strings.Split(etcdS3EndpointCA, " ") {
-		// Try to decode the value as base64-encoded data - yes, a base64 string that itself
-		// contains multiline, ascii-armored, base64-encoded certificate data - as would be produced
-		// by `base64 --wrap=0 /path/to/cert.pem`. If this fails, assume the value is the path to a
-		// file on disk, and try to read that.  This is backwards compatible with RKE1.
-		caData, err := base64.StdEncoding.DecodeString(ca)
-		if err != nil {
-			caData, err = os.ReadFile(ca)
-		}
-		if err != nil {
-			return nil, err
-		}
-		if certPool.AppendCertsFromPEM(caData) {
-			loaded = true
-		}
-	}
-
-	if loaded {
-		return &tls.Config{RootCAs: certPool}, nil
-	}
-	return nil, errors.New("no certificates loaded from etcd-s3-endpoint-ca")
-}
-
-func bucketLookupType(endpoint string) minio.BucketLookupType {
-	if strings.Contains(endpoint, "aliyun") { // backwards compatible with RKE1
-		return minio.BucketLookupDNS
-	}
-	return minio.BucketLookupAuto
-}
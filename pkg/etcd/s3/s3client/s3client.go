@@ -0,0 +1,128 @@
+// Package s3client builds minio clients for S3-compatible endpoints. It holds
+// the endpoint, CA bundle, proxy and credential handling shared by etcd
+// snapshot uploads (pkg/etcd/s3) and the sandbox snapshot layer store, and
+// has no dependencies on either.
+package s3client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+)
+
+// Options describe an S3-compatible endpoint and how to reach it.
+type Options struct {
+	Endpoint string
+	// EndpointCA is a space-separated list of CA bundles, each either
+	// base64-encoded PEM or a path to a PEM file.
+	EndpointCA string
+	Region     string
+	AccessKey  string
+	SecretKey  string
+	// Proxy is a proxy URL; "none" disables proxying, empty uses the
+	// environment.
+	Proxy         string
+	Insecure      bool
+	SkipSSLVerify bool
+}
+
+// New returns a minio client for opts. With neither access nor secret key
+// set, credentials come from the EC2 instance role.
+func New(opts Options) (*minio.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+
+	// You can either disable SSL verification or use a custom CA bundle,
+	// it doesn't make sense to do both - if verification is disabled,
+	// the CA is not checked!
+	if opts.SkipSSLVerify {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	} else if opts.EndpointCA != "" {
+		tlsConfig, err := LoadEndpointCAs(opts.EndpointCA)
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig = tlsConfig
+	}
+
+	// Set a fixed proxy URL, if requested by the user. This replaces the default,
+	// which calls ProxyFromEnvironment to read proxy settings from the environment.
+	if opts.Proxy != "" {
+		var u *url.URL
+		var err error
+		// proxy address of literal "none" disables all use of a proxy by S3
+		if opts.Proxy != "none" {
+			u, err = url.Parse(opts.Proxy)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse etcd-s3-proxy value as URL")
+			}
+			if u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("proxy URL must include scheme and host")
+			}
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+
+	var creds *credentials.Credentials
+	if len(opts.AccessKey) == 0 && len(opts.SecretKey) == 0 {
+		creds = credentials.NewIAM("") // for running on ec2 instance
+		if _, err := creds.Get(); err != nil {
+			return nil, errors.Wrap(err, "failed to get IAM credentials")
+		}
+	} else {
+		creds = credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, "")
+	}
+
+	return minio.New(opts.Endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       !opts.Insecure,
+		Region:       opts.Region,
+		Transport:    tr,
+		BucketLookup: BucketLookupType(opts.Endpoint),
+	})
+}
+
+// LoadEndpointCAs builds a TLS config trusting the CA bundles in
+// endpointCA (space-separated; base64 PEM or file paths).
+func LoadEndpointCAs(endpointCA string) (*tls.Config, error) {
+	var loaded bool
+	certPool := x509.NewCertPool()
+
+	for _, ca := range strings.Split(endpointCA, " ") {
+		// Try to decode the value as base64-encoded data - yes, a base64 string that itself
+		// contains multiline, ascii-armored, base64-encoded certificate data - as would be produced
+		// by `base64 --wrap=0 /path/to/cert.pem`. If this fails, assume the value is the path to a
+		// file on disk, and try to read that.  This is backwards compatible with RKE1.
+		caData, err := base64.StdEncoding.DecodeString(ca)
+		if err != nil {
+			caData, err = os.ReadFile(ca)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if certPool.AppendCertsFromPEM(caData) {
+			loaded = true
+		}
+	}
+
+	if loaded {
+		return &tls.Config{RootCAs: certPool}, nil
+	}
+	return nil, errors.New("no certificates loaded from etcd-s3-endpoint-ca")
+}
+
+// BucketLookupType picks path- or DNS-style bucket addressing for endpoint.
+func BucketLookupType(endpoint string) minio.BucketLookupType {
+	if strings.Contains(endpoint, "aliyun") { // backwards compatible with RKE1
+		return minio.BucketLookupDNS
+	}
+	return minio.BucketLookupAuto
+}
@@ -16,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 	"github.com/xiaods/k8e/pkg/version"
 	"google.golang.org/grpc"
@@ -34,16 +35,31 @@ const maxCallRecvMsgSize = 64 * 1024 * 1024
 const maxCallSendMsgSize = 64 * 1024 * 1024
 
 // dialOpts returns the transport + message-size options shared by every client
-// dial site in this package.
+// dial site in this package. Calls carry the W3C trace context in their
+// metadata; see pkg/sandbox/tracing.
 func dialOpts() []grpc.DialOption {
 	return []grpc.DialOption{
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(maxCallRecvMsgSize),
 			grpc.MaxCallSendMsgSize(maxCallSendMsgSize),
 		),
+		grpc.WithStatsHandler(tracing.ClientHandler()),
+		grpc.WithChainUnaryInterceptor(traceParentUnaryInterceptor),
+		grpc.WithChainStreamInterceptor(traceParentStreamInterceptor),
 	}
 }
 
+// traceParentUnaryInterceptor parents calls made with a span-less context
+// (most CLI and E2B call sites use context.Background) on the process's
+// default trace parent, so one CLI command yields one trace.
+func traceParentUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(tracing.WithDefaultParent(ctx), method, req, reply, cc, opts...)
+}
+
+func traceParentStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(tracing.WithDefaultParent(ctx), desc, cc, method, opts...)
+}
+
 var tlsCandidates = []string{
 	// The sandbox gRPC gateway's server cert is signed by the dedicated sandbox
 	// CA (KIP-14, /var/lib/k8e/server/tls/sandbox-ca.crt), not the apiserver
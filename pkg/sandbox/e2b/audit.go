@@ -0,0 +1,141 @@
+package e2b
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
+)
+
+// auditRequests records one audit.Record per control-plane, envd and
+// signed-file request. Health probes and /k8e/expose/ proxy traffic (end-user
+// HTTP into a sandbox, authorized by the audited ExposeService RPC) are not
+// recorded.
+func (s *Server) auditRequests(next http.Handler) http.Handler {
+	if s.audit == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasSuffix(path, "/health") || strings.HasPrefix(path, "/k8e/expose/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		var rw http.ResponseWriter = sw
+		if _, ok := w.(http.Hijacker); ok {
+			rw = hijackStatusWriter{sw}
+		}
+		next.ServeHTTP(rw, r)
+		rec := audit.Record{
+			Time:      start,
+			Source:    "e2b",
+			Caller:    auditCaller(r),
+			Session:   auditSession(r),
+			Method:    r.Method + " " + auditRoute(path),
+			Outcome:   strconv.Itoa(sw.code()),
+			LatencyMS: time.Since(start).Milliseconds(),
+			// Only the file path is known without reading the body; process
+			// commands are recorded by the gateway's Exec/ExecStream audit.
+			Target: audit.Hash(r.URL.Query().Get("path")),
+		}
+		if err := s.audit.Write(rec); err != nil {
+			logrus.Warnf("e2b: audit %s: %v", rec.Method, err)
+		}
+	})
+}
+
+// auditCaller names the credential a request presented without logging it:
+// control-plane keys become "key:<sha256 prefix>", envd tokens "envd" and
+// signed URLs "signed-url".
+func auditCaller(r *http.Request) string {
+	if key := credentialFromHeaders(r); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:6])
+	}
+	if r.Header.Get("X-Access-Token") != "" {
+		return "envd"
+	}
+	if r.URL.Query().Get("signature") != "" {
+		return "signed-url"
+	}
+	return "anonymous"
+}
+
+// auditSession is the sandbox a request addresses: the envd header, else the
+// /sandboxes/{id} path segment.
+func auditSession(r *http.Request) string {
+	if id := r.Header.Get("E2b-Sandbox-Id"); id != "" {
+		return id
+	}
+	if _, rest, ok := strings.Cut(r.URL.Path, "/sandboxes/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		return id
+	}
+	return ""
+}
+
+// auditRoute replaces the sandbox id in control-plane paths with {id} so
+// records group by route.
+func auditRoute(path string) string {
+	prefix, rest, ok := strings.Cut(path, "/sandboxes/")
+	if !ok {
+		return path
+	}
+	if _, tail, more := strings.Cut(rest, "/"); more {
+		return prefix + "/sandboxes/{id}/" + tail
+	}
+	return prefix + "/sandboxes/{id}"
+}
+
+// statusWriter captures the response status while staying transparent to the
+// streaming (Flusher) handlers behind it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// hijackStatusWriter is a statusWriter over a Hijacker. It is a separate type
+// so handlers that probe for http.Hijacker see exactly what the server offers.
+type hijackStatusWriter struct{ *statusWriter }
+
+// Hijack hands the connection over; the Connect-style streams that hijack
+// write their own "200 OK", which code() reports by default.
+func (w hijackStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,68 @@
+package e2b
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
+)
+
+func TestAuditRequests_RecordsControlCalls(t *testing.T) {
+	dir := t.TempDir()
+	srv := NewServer(Config{APIKey: "test-key", AuditDir: dir}, newFakeGateway())
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+
+	resp := controlReq(t, ts, http.MethodGet, "/sandboxes/sbx-1", nil)
+	resp.Body.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/e2b/api/sandboxes/sbx-1", nil)
+	req.Header.Set("X-API-KEY", "e2b_wrong")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = ts.Client().Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	recs, err := audit.Query(dir, audit.Filter{Source: "e2b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records (health skipped), got %+v", recs)
+	}
+	ok, denied := recs[0], recs[1]
+	if ok.Method != "GET /e2b/api/sandboxes/{id}" || ok.Session != "sbx-1" || !strings.HasPrefix(ok.Caller, "key:") {
+		t.Fatalf("unexpected record %+v", ok)
+	}
+	if strings.Contains(ok.Caller, "test-key") {
+		t.Fatalf("caller leaks the API key: %q", ok.Caller)
+	}
+	if denied.Outcome != "401" || denied.Caller == ok.Caller {
+		t.Fatalf("rejected call should record 401 and a different key: %+v", denied)
+	}
+}
+
+func TestAuditRequests_HashesFilePath(t *testing.T) {
+	dir := t.TempDir()
+	srv := NewServer(Config{AuditDir: dir}, newFakeGateway())
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/files?path=/home/user/.ssh/id_rsa&signature=bogus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	recs, _ := audit.Query(dir, audit.Filter{Target: "/home/user/.ssh/id_rsa"})
+	if len(recs) != 1 || recs[0].Caller != "signed-url" || strings.Contains(recs[0].Method, "id_rsa") {
+		t.Fatalf("unexpected records %+v", recs)
+	}
+}
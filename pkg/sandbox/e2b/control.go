@@ -33,6 +33,19 @@ type connectBody struct {
 	Timeout *int `json:"timeout"`
 }
 
+// pauseBody is the optional /pause body. Memory asks for a memory-preserving
+// pause (Dormice's memory:true); the gateway falls back to a filesystem
+// pause when the sandbox's runtime cannot checkpoint.
+type pauseBody struct {
+	Memory bool `json:"memory"`
+}
+
+// Pause outcome headers on the 204 /pause response.
+const (
+	headerPauseMode     = "X-K8e-Pause-Mode"     // "memory" | "filesystem"
+	headerPauseFallback = "X-K8e-Pause-Fallback" // why memory was not kept
+)
+
 // parseSandboxTimeout validates the E2B timeout knob shared by create and
 // connect. Returns the effective seconds, whether the sandbox should never
 // time out, and a protocol error message on invalid values.
@@ -281,19 +294,37 @@ func validTimeout(seconds int) bool {
 // PauseSession RPC: the pod (CPU/memory) is released, the workspace PVC and
 // session survive. An ephemeral (EmptyDir) sandbox cannot pause without
 // losing its files — the gateway refuses with FailedPrecondition, surfaced
-// here as 409 (CubeSandbox's "sandbox cannot be paused").
+// here as 409 (CubeSandbox's "sandbox cannot be paused"). A {"memory": true}
+// body also checkpoints the processes; the outcome is reported in the
+// X-K8e-Pause-Mode / X-K8e-Pause-Fallback headers.
 func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
+	var body pauseBody
+	if r.ContentLength != 0 {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
 	if _, _, ok := s.findLive(r, id); !ok {
 		s.goneOrNotFound(w, s.lastSessionErr(id), id)
 		return
 	}
-	if _, err := s.gw.PauseSession(r.Context(), &pb.PauseSessionRequest{SessionId: id}); err != nil {
+	req := &pb.PauseSessionRequest{SessionId: id}
+	if body.Memory {
+		req.Mode = pb.PauseMode_PAUSE_MODE_MEMORY
+	}
+	resp, err := s.gw.PauseSession(r.Context(), req)
+	if err != nil {
 		e := gwErrorToE2B(err, "pause failed")
 		s.writeControlError(w, e)
 		return
 	}
 	s.registry.markPaused(id, true)
+	w.Header().Set(headerPauseMode, "filesystem")
+	if resp.Mode == pb.PauseMode_PAUSE_MODE_MEMORY {
+		w.Header().Set(headerPauseMode, "memory")
+	}
+	if resp.Fallback != "" {
+		w.Header().Set(headerPauseFallback, resp.Fallback)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -33,6 +33,7 @@ type sandboxdStub struct {
 	attachOutputs map[int]string
 	// watchers: watcher id -> buffered events (name -> type name).
 	watchers      map[int][]watchEvent
+	watchPaths    map[int]string // watcher id -> watched path
 	nextWatcherID int
 	nextPID       int
 }
@@ -44,6 +45,7 @@ func newSandboxdStub() *sandboxdStub {
 		procTable:     map[int]sandboxProcess{},
 		attachOutputs: map[int]string{},
 		watchers:      map[int][]watchEvent{},
+		watchPaths:    map[int]string{},
 		nextWatcherID: 1,
 		nextPID:       1000,
 	}
@@ -84,7 +86,7 @@ func (st *sandboxdStub) handle(w http.ResponseWriter, r *http.Request) {
 	case "/exec/attach":
 		st.handleAttach(w, r)
 	case "/watch/create":
-		st.handleWatchCreate(w, r)
+		st.handleWatchCreate(w, body)
 	case "/watch/events":
 		st.handleWatchEvents(w, r)
 	case "/watch/remove":
@@ -211,16 +213,13 @@ func (st *sandboxdStub) handleAttach(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("data: {\"done\":true}\n\n"))
 }
 
-func (st *sandboxdStub) handleWatchCreate(w http.ResponseWriter, r *http.Request) {
-	var wb struct {
-		Path string `json:"path"`
-	}
-	_ = json.NewDecoder(r.Body).Decode(&wb)
+func (st *sandboxdStub) handleWatchCreate(w http.ResponseWriter, body sandboxdBody) {
 	st.mu.Lock()
 	defer st.mu.Unlock()
 	id := st.nextWatcherID
 	st.nextWatcherID++
 	st.watchers[id] = nil
+	st.watchPaths[id] = body.Path
 	st.writeOK(w, map[string]any{"watcher_id": id})
 }
 
@@ -233,6 +232,8 @@ func (st *sandboxdStub) handleWatchEvents(w http.ResponseWriter, r *http.Request
 		http.Error(w, `{"error":"watcher not found"}`, http.StatusNotFound)
 		return
 	}
+	// Events since the last call, like sandboxd's per-watcher cursor.
+	st.watchers[id] = nil
 	st.writeOK(w, map[string]any{"events": evs})
 }
 
@@ -244,9 +245,34 @@ func (st *sandboxdStub) handleWatchRemove(w http.ResponseWriter, body sandboxdBo
 		return
 	}
 	delete(st.watchers, body.WatcherID)
+	delete(st.watchPaths, body.WatcherID)
 	st.writeOK(w, map[string]any{"ok": true})
 }
 
+// watcherFor returns the id of the live watcher on path (0 when none).
+func (st *sandboxdStub) watcherFor(path string) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, p := range st.watchPaths {
+		if p == path {
+			return id
+		}
+	}
+	return 0
+}
+
+// seedWatch queues events on the watcher for path.
+func (st *sandboxdStub) seedWatch(t *testing.T, path string, events ...watchEvent) {
+	t.Helper()
+	id := st.watcherFor(path)
+	if id == 0 {
+		t.Fatalf("no watcher on %s", path)
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.watchers[id] = append(st.watchers[id], events...)
+}
+
 func testServer(t *testing.T, gw Gateway) (*Server, *httptest.Server) {
 	t.Helper()
 	return testServerWithSandboxd(t, gw, nil)
@@ -500,6 +526,26 @@ func TestControlPauseResume(t *testing.T) {
 	}
 }
 
+func TestControlPauseMemoryReportsFallback(t *testing.T) {
+	gw := newFakeGateway()
+	_, ts := testServer(t, gw)
+	resp := controlReq(t, ts, "POST", "/sandboxes", map[string]any{})
+	session := map[string]any{}
+	_ = json.Unmarshal([]byte(readBody(t, resp)), &session)
+	id := session["sandboxID"].(string)
+
+	pause := controlReq(t, ts, "POST", "/sandboxes/"+id+"/pause", map[string]any{"memory": true})
+	if pause.StatusCode != 204 {
+		t.Fatalf("pause: %d %s", pause.StatusCode, readBody(t, pause))
+	}
+	if got := pause.Header.Get(headerPauseMode); got != "filesystem" {
+		t.Fatalf("%s=%q, want filesystem", headerPauseMode, got)
+	}
+	if !strings.Contains(pause.Header.Get(headerPauseFallback), "does not support checkpoints") {
+		t.Fatalf("missing fallback reason: %q", pause.Header.Get(headerPauseFallback))
+	}
+}
+
 func TestControlPauseEphemeralRefused(t *testing.T) {
 	gw := newFakeGateway()
 	gw.pauseErr = status.Error(codes.FailedPrecondition, "session is ephemeral (no workspace PVC)")
@@ -533,31 +533,54 @@ func (s *Server) handleProcessSendInput(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// PTY sessions route input to the terminal (KIP-19 M4); everything else
-	// goes through sandboxd's stdin pipe. The terminal must belong to the
-	// authenticated sandbox (Greptile security review).
-	sandboxID := sandboxIDOf(r)
-	if row, isPty := s.ptyForSandbox(pid, sandboxID); isPty {
-		if _, err := s.gw.TerminalWrite(r.Context(), &pb.TerminalWriteRequest{TerminalId: row.terminalID, Data: decoded}); err != nil {
-			s.writeEnvdError(w, connectError("internal", "terminal write failed: "+err.Error()))
-			return
-		}
-		writeJSONOK(w)
-		return
-	}
-
-	guestPID, e2e := s.requireGuestPID(sandboxID, pid)
+	target, e2e := s.resolveInputTarget(sandboxIDOf(r), pid)
 	if e2e != nil {
 		s.writeEnvdError(w, e2e)
 		return
 	}
-	if err := s.sandboxd.sendStdin(r.Context(), sandboxID, guestPID, decoded); err != nil {
-		s.writeEnvdError(w, connectError("internal", "send stdin failed: "+err.Error()))
+	if e2e := s.writeInput(r.Context(), sandboxIDOf(r), target, decoded); e2e != nil {
+		s.writeEnvdError(w, e2e)
 		return
 	}
 	writeJSONOK(w)
 }
 
+// inputTarget is where a process's input goes: a KIP-19 terminal when the
+// pid is a PTY session, otherwise sandboxd's stdin pipe for the guest pid.
+type inputTarget struct {
+	terminalID string
+	guestPID   int
+}
+
+// resolveInputTarget maps an E2B-facing pid to its input target. PTY
+// sessions route input to the terminal (KIP-19 M4); everything else goes
+// through sandboxd's stdin pipe. The terminal must belong to the
+// authenticated sandbox (Greptile security review).
+func (s *Server) resolveInputTarget(sandboxID string, pid int) (inputTarget, *E2bError) {
+	if row, isPty := s.ptyForSandbox(pid, sandboxID); isPty {
+		return inputTarget{terminalID: row.terminalID}, nil
+	}
+	guestPID, e2e := s.requireGuestPID(sandboxID, pid)
+	if e2e != nil {
+		return inputTarget{}, e2e
+	}
+	return inputTarget{guestPID: guestPID}, nil
+}
+
+// writeInput delivers decoded input bytes to t.
+func (s *Server) writeInput(ctx context.Context, sandboxID string, t inputTarget, data []byte) *E2bError {
+	if t.terminalID != "" {
+		if _, err := s.gw.TerminalWrite(ctx, &pb.TerminalWriteRequest{TerminalId: t.terminalID, Data: data}); err != nil {
+			return connectError("internal", "terminal write failed: "+err.Error())
+		}
+		return nil
+	}
+	if err := s.sandboxd.sendStdin(ctx, sandboxID, t.guestPID, data); err != nil {
+		return connectError("internal", "send stdin failed: "+err.Error())
+	}
+	return nil
+}
+
 // handleProcessCloseStdin implements process.Process/CloseStdin: EOF.
 func (s *Server) handleProcessCloseStdin(w http.ResponseWriter, r *http.Request) {
 	var body struct {
@@ -696,8 +719,9 @@ func sigName(sig pb.TerminalSignal) string {
 
 // handleProcessUpdate implements process.Process/Update — the SDK's pty
 // resize path ({process: {selector: {case: "pid", value: N}}, pty: {size:
-// {cols, rows}}}). Only meaningful for PTY sessions (KIP-19 M4); pipe-based
-// processes have no window size.
+// {cols, rows}}}). Also serves UpdatePTY, which carries the same body.
+// Only meaningful for PTY sessions (KIP-19 M4); pipe-based processes have
+// no window size.
 func (s *Server) handleProcessUpdate(w http.ResponseWriter, r *http.Request) {
 	// The SDK's pty resize path: {process: {selector: {case: "pid", value:
 	// N}}, pty: {size: {cols, rows}}}.
@@ -728,23 +752,6 @@ func (s *Server) handleProcessUpdate(w http.ResponseWriter, r *http.Request) {
 	writeJSONOK(w)
 }
 
-// handleUnimplementedProcess answers a Connect RPC with the honest
-// unimplemented code and a hint (streamed stdin remains unsupported: the SDK
-// sends stdin via SendInput; Update/UpdatePTY are PTY-only now implemented).
-func (s *Server) handleUnimplementedProcess(name string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		s.writeEnvdError(w, connectError("unimplemented", name+" is not supported"))
-	}
-}
-
-// handleUnimplementedFS answers a filesystem RPC with the honest
-// unimplemented code.
-func (s *Server) handleUnimplementedFS(name string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		s.writeEnvdError(w, connectError("unimplemented", name+" is not supported: K8E has no watch"))
-	}
-}
-
 // fsReady resolves the sandbox for a filesystem RPC, auto-resuming a paused
 // one. Returns the live sandbox ID or writes the error and returns "".
 func (s *Server) fsReady(w http.ResponseWriter, r *http.Request) string {
@@ -1032,6 +1039,12 @@ func asMap(body []byte) map[string]any {
 // value: N}}} shape the official SDK sends.
 func pidFromBody(body []byte) int {
 	proc, _ := asMap(body)["process"].(map[string]any)
+	return processPID(proc)
+}
+
+// processPID extracts the pid from a ProcessSelector: the flat {pid} shape
+// or the SDK's {selector: {case: "pid", value}} shape.
+func processPID(proc map[string]any) int {
 	if f, ok := proc["pid"].(float64); ok && int(f) != 0 {
 		return int(f)
 	}
@@ -1048,8 +1061,16 @@ func pidFromBody(body []byte) int {
 // flat {input: {stdin}} shape.
 func inputData(body []byte) string {
 	input, _ := asMap(body)["input"].(map[string]any)
-	if s, ok := input["stdin"].(string); ok && s != "" {
-		return s
+	return inputValue(input)
+}
+
+// inputValue extracts the base64 payload from a ProcessInput: the flat
+// {stdin} / {pty} oneof shapes or the SDK's {input: {case, value}} shape.
+func inputValue(input map[string]any) string {
+	for _, k := range []string{"stdin", "pty"} {
+		if s, ok := input[k].(string); ok && s != "" {
+			return s
+		}
 	}
 	if inner, ok := input["input"].(map[string]any); ok {
 		if s, ok := inner["value"].(string); ok {
@@ -1302,18 +1323,77 @@ func splitSSEFrames(body []byte) [][]byte {
 	return frames
 }
 
-// handleStreamInputUnimplemented answers process.Process/StreamInput with a
-// documented 501. Decision (KIP-18 P1 follow-up): StreamInput is the only
-// client_stream RPC in the envd spec, but neither the official Python nor
-// JS SDK ever calls it — send_stdin goes through the unary SendInput RPC
-// (verified against e2b packages/python-sdk + js-sdk sources). Implementing
-// HTTP/2 client_stream in sandboxd (Zig) would cost significant work with
-// zero SDK consumers, so the honest answer is a permanent 501 with this
-// rationale. Custom low-level clients that need streamed stdin are out of
-// scope; they can fall back to SendInput.
-func (s *Server) handleStreamInputUnimplemented(w http.ResponseWriter, r *http.Request) {
-	s.writeEnvdError(w, connectError("unimplemented",
-		"StreamInput is not supported: the official e2b SDKs send stdin via SendInput, not the StreamInput client stream; use SendInput"))
+// handleProcessStreamInput implements process.Process/StreamInput, the
+// envd client stream interactive REPLs use for stdin. The request is a
+// sequence of Connect envelopes read as they arrive:
+//
+//	{start: {process: {pid}}}           selects the target (first frame)
+//	{data: {input: {stdin|pty: <b64>}}} writes input to it
+//	{keepalive: {}}                     ignored
+//
+// Each data frame is forwarded immediately — TerminalWrite for a PTY
+// session (KIP-19), sandboxd stdin otherwise — so keystrokes are not held
+// until the client closes the stream. When it does, the response is the
+// single empty StreamInputResponse message followed by end-of-stream.
+func (s *Server) handleProcessStreamInput(w http.ResponseWriter, r *http.Request) {
+	sandboxID := sandboxIDOf(r)
+	var target *inputTarget
+	for {
+		f, err := readEnvelope(r.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeEnvdStreamError(w, connectError("invalid_argument", "read stream input: "+err.Error()))
+			return
+		}
+		if f.flags&FlagEndStream != 0 {
+			break
+		}
+		if start, ok := f.json["start"].(map[string]any); ok {
+			if target != nil {
+				writeEnvdStreamError(w, connectError("invalid_argument", "duplicate start event"))
+				return
+			}
+			proc, _ := start["process"].(map[string]any)
+			pid := processPID(proc)
+			if pid == 0 {
+				writeEnvdStreamError(w, connectError("invalid_argument", "missing process pid"))
+				return
+			}
+			t, e2e := s.resolveInputTarget(sandboxID, pid)
+			if e2e != nil {
+				writeEnvdStreamError(w, e2e)
+				return
+			}
+			target = &t
+			continue
+		}
+		if data, ok := f.json["data"].(map[string]any); ok {
+			if target == nil {
+				writeEnvdStreamError(w, connectError("invalid_argument", "data event before start event"))
+				return
+			}
+			input, _ := data["input"].(map[string]any)
+			decoded, err := base64.StdEncoding.DecodeString(inputValue(input))
+			if err != nil {
+				writeEnvdStreamError(w, connectError("invalid_argument", "invalid base64 input"))
+				return
+			}
+			if e2e := s.writeInput(r.Context(), sandboxID, *target, decoded); e2e != nil {
+				writeEnvdStreamError(w, e2e)
+				return
+			}
+		}
+		// keepalive (or an unknown event): nothing to do.
+	}
+	if target == nil {
+		writeEnvdStreamError(w, connectError("invalid_argument", "stream ended without a start event"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/connect+json")
+	_, _ = w.Write(envelope(FlagMessage, map[string]any{}))
+	_, _ = w.Write(envelope(FlagEndStream, map[string]any{}))
 }
 
 // keepaliveInterval is how often a process stream sends a keepalive event
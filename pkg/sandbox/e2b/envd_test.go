@@ -1,6 +1,7 @@
 package e2b
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
@@ -361,40 +362,6 @@ func TestEnvdProcessConnectUnknownPid(t *testing.T) {
 	}
 }
 
-func TestUnimplementedSurfaces(t *testing.T) {
-	gw := newFakeGateway()
-	s, ts := testServer(t, gw)
-	id := createSandboxID(t, ts)
-
-	// SendSignal/SendInput/CloseStdin, PTY Update (resize), and the polling
-	// watch trio (CreateWatcher/GetWatcherEvents/RemoveWatcher) are now
-	// implemented; the streaming WatchDir and streamed stdin stay 501.
-	cases := []struct {
-		path string
-		body string
-	}{
-		{"/filesystem.Filesystem/WatchDir", `{}`},
-		{"/process.Process/StreamInput", `{}`},
-	}
-	for _, c := range cases {
-		req, _ := http.NewRequest("POST", ts.URL+"/e2b/envd"+c.path, bytes.NewReader([]byte(c.body)))
-		for k, v := range envdHeaders(t, s, id) {
-			req.Header.Set(k, v)
-		}
-		resp, err := ts.Client().Do(req)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if resp.StatusCode != 501 {
-			t.Fatalf("%s: want 501, got %d", c.path, resp.StatusCode)
-		}
-		body := readBody(t, resp)
-		if !strings.Contains(body, "unimplemented") {
-			t.Fatalf("%s: body %s", c.path, body)
-		}
-	}
-}
-
 func mustB64Decode(t *testing.T, s string) []byte {
 	t.Helper()
 	out, err := base64.StdEncoding.DecodeString(s)
@@ -819,3 +786,152 @@ func TestEnvdWatchDirFamily(t *testing.T) {
 		t.Fatalf("events after remove: want 404, got %d", status)
 	}
 }
+
+// openWatchDir starts a streaming WatchDir and returns the response and a
+// reader positioned after the start frame.
+func openWatchDir(t *testing.T, ts *httptest.Server, s *Server, id string, msg map[string]any) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", ts.URL+"/e2b/envd/filesystem.Filesystem/WatchDir", bytes.NewReader(envelope(FlagMessage, msg)))
+	req.Header.Set("Content-Type", "application/connect+json")
+	for k, v := range envdHeaders(t, s, id) {
+		req.Header.Set(k, v)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(resp.Body)
+	f, err := readOneEnvelope(br)
+	if err != nil {
+		t.Fatalf("read start frame: %v", err)
+	}
+	if _, ok := f.json["start"]; !ok {
+		t.Fatalf("first frame must be start, got %v", f.json)
+	}
+	return resp, br
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEnvdWatchDirStream_Recursive(t *testing.T) {
+	gw := newFakeGateway()
+	gw.listDirs = []string{"/workspace/src", "/workspace/src/internal"}
+	stub := newSandboxdStub()
+	stub.dirs["/workspace"] = true
+	stub.dirs["/workspace/src"] = true
+	stub.dirs["/workspace/src/internal"] = true
+	s, ts := testServerWithSandboxd(t, gw, stub)
+	id := createSandboxID(t, ts)
+
+	resp, br := openWatchDir(t, ts, s, id, map[string]any{"path": "/workspace", "recursive": true})
+	for _, dir := range []string{"/workspace", "/workspace/src", "/workspace/src/internal"} {
+		if stub.watcherFor(dir) == 0 {
+			t.Fatalf("recursive watch must cover %s", dir)
+		}
+	}
+
+	next := func() (string, string) {
+		t.Helper()
+		f, err := readOneEnvelope(br)
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		ev, ok := f.json["filesystem"].(map[string]any)
+		if !ok {
+			t.Fatalf("expected filesystem event, got %v", f.json)
+		}
+		return ev["name"].(string), ev["type"].(string)
+	}
+
+	// Events in a subdirectory are named relative to the watched root.
+	stub.seedWatch(t, "/workspace/src/internal", watchEvent{Name: "/workspace/src/internal/db.go", Type: 2})
+	if name, typ := next(); name != "src/internal/db.go" || typ != "EVENT_TYPE_WRITE" {
+		t.Fatalf("got %s %s", name, typ)
+	}
+
+	// A directory created under the root is watched from then on.
+	stub.mu.Lock()
+	stub.dirs["/workspace/web"] = true
+	stub.mu.Unlock()
+	stub.seedWatch(t, "/workspace", watchEvent{Name: "/workspace/web", Type: 1})
+	if name, typ := next(); name != "web" || typ != "EVENT_TYPE_CREATE" {
+		t.Fatalf("got %s %s", name, typ)
+	}
+	waitFor(t, "watcher on new dir", func() bool { return stub.watcherFor("/workspace/web") != 0 })
+	stub.seedWatch(t, "/workspace/web",
+		watchEvent{Name: "/workspace/web/a.js", Type: 4},
+		watchEvent{Name: "/workspace/web/b.js", Type: 5})
+	if name, typ := next(); name != "web/a.js" || typ != "EVENT_TYPE_RENAME" {
+		t.Fatalf("got %s %s", name, typ)
+	}
+	if name, typ := next(); name != "web/b.js" || typ != "EVENT_TYPE_CHMOD" {
+		t.Fatalf("got %s %s", name, typ)
+	}
+
+	// Removing a directory drops its watchers, subtree included.
+	stub.seedWatch(t, "/workspace", watchEvent{Name: "/workspace/src", Type: 3})
+	if name, typ := next(); name != "src" || typ != "EVENT_TYPE_REMOVE" {
+		t.Fatalf("got %s %s", name, typ)
+	}
+	waitFor(t, "removed subtree unwatched", func() bool {
+		return stub.watcherFor("/workspace/src") == 0 && stub.watcherFor("/workspace/src/internal") == 0
+	})
+
+	// Client disconnect tears every watcher down.
+	resp.Body.Close()
+	waitFor(t, "watchers removed on disconnect", func() bool {
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		return len(stub.watchers) == 0
+	})
+}
+
+func TestEnvdWatchDirStream_NonRecursive(t *testing.T) {
+	gw := newFakeGateway()
+	gw.listDirs = []string{"/workspace/src"}
+	stub := newSandboxdStub()
+	stub.dirs["/workspace"] = true
+	stub.dirs["/workspace/src"] = true
+	s, ts := testServerWithSandboxd(t, gw, stub)
+	id := createSandboxID(t, ts)
+
+	resp, _ := openWatchDir(t, ts, s, id, map[string]any{"path": "/workspace"})
+	defer resp.Body.Close()
+	if stub.watcherFor("/workspace/src") != 0 {
+		t.Fatal("non-recursive watch must not watch subdirectories")
+	}
+}
+
+func TestEnvdWatchDirStream_NotFound(t *testing.T) {
+	gw := newFakeGateway()
+	s, ts := testServer(t, gw)
+	id := createSandboxID(t, ts)
+
+	req, _ := http.NewRequest("POST", ts.URL+"/e2b/envd/filesystem.Filesystem/WatchDir",
+		bytes.NewReader(envelope(FlagMessage, map[string]any{"path": "/workspace/missing"})))
+	for k, v := range envdHeaders(t, s, id) {
+		req.Header.Set(k, v)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, _ := parseEnvelopes([]byte(readBody(t, resp)))
+	if len(frames) != 1 {
+		t.Fatalf("expected a single end-stream frame, got %v", frames)
+	}
+	errObj, _ := frames[0].json["error"].(map[string]any)
+	if errObj["code"] != "not_found" {
+		t.Fatalf("expected not_found, got %v", frames[0].json)
+	}
+}
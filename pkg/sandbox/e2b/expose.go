@@ -3,6 +3,7 @@ package e2b
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -20,9 +21,16 @@ import (
 // so an exposed service is reachable at
 // http(s)://<gateway>/k8e/expose/<session>/<port>/.
 //
-// Authorization: the port must be registered in the gateway's expose registry
-// (ListExposed RPC, populated by `k8e sandbox expose` / the dsh plugin). Only
-// then is the request proxied to http://<podIP>:<port>.
+// Authorization: the port must be in the session's status.exposedPorts
+// (populated by `k8e sandbox expose` / the dsh plugin), read from the
+// ExposeIndex informer when configured and from the gateway (ListExposed)
+// otherwise. The request must then satisfy the port's access mode (public,
+// token or signed URL; see authorizeExpose). Only then is it proxied to
+// http://<podIP>:<port>, without the expose credentials.
+//
+// Every exposure shares this origin, so the proxy hands out no cookies
+// here; with an expose domain configured each exposure gets its own origin
+// (see exposeHostTarget).
 func (s *Server) handleExposeProxy(w http.ResponseWriter, r *http.Request) {
 	rest := strings.TrimPrefix(r.URL.Path, "/k8e/expose/")
 	parts := strings.SplitN(rest, "/", 3)
@@ -36,59 +44,81 @@ func (s *Server) handleExposeProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid port", http.StatusBadRequest)
 		return
 	}
+	suffix := "/"
+	if len(parts) > 2 && parts[2] != "" {
+		suffix = "/" + parts[2]
+	}
+	s.proxyExposed(w, r, sessionID, port, suffix, false)
+}
 
-	// Authorization: the port must be currently exposed for this session.
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
-	listed, err := s.gw.ListExposed(ctx, &pb.ListExposedRequest{SessionId: sessionID})
-	if err != nil {
-		// Surface the real cause: this page is exactly where a stale gateway
-		// (missing KIP-24 RPCs) or an auth failure shows up first.
-		logrus.Warnf("k8e expose proxy %s/%d: ListExposed failed: %v", sessionID, port, err)
-		http.Error(w, fmt.Sprintf("gateway unreachable: %v", err), http.StatusBadGateway)
-		return
+// exposeHosts serves requests addressed to an exposure's own host,
+// <port>-<session>.<expose domain>, passing everything else to next.
+func (s *Server) exposeHosts(next http.Handler) http.Handler {
+	if s.exposeDomain == "" {
+		return next
 	}
-	exposed := false
-	for _, svc := range listed.Services {
-		if int(svc.Port) == port {
-			exposed = true
-			break
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID, port, ok := exposeHostTarget(r.Host, s.exposeDomain)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path := r.URL.Path
+		if path == "" {
+			path = "/"
 		}
+		s.proxyExposed(w, r, sessionID, port, path, true)
+	})
+}
+
+// exposeHostTarget parses host as <port>-<session>.<domain>. Such a host is
+// an origin of its own, so the cookie the proxy sets there reaches no other
+// exposure.
+func exposeHostTarget(host, domain string) (sessionID string, port int, ok bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
-	if !exposed {
-		http.Error(w, fmt.Sprintf("port %d not exposed for session %s", port, sessionID), http.StatusNotFound)
-		return
+	label, found := strings.CutSuffix(strings.ToLower(host), "."+domain)
+	if !found || strings.Contains(label, ".") {
+		return "", 0, false
 	}
+	portStr, sessionID, found := strings.Cut(label, "-")
+	port, err := strconv.Atoi(portStr)
+	if !found || err != nil || port <= 0 || port > 65535 || sessionID == "" {
+		return "", 0, false
+	}
+	return sessionID, port, true
+}
 
-	// Resolve the sandbox pod IP via the gateway.
-	sess, err := s.gw.GetSession(ctx, &pb.GetSessionRequest{SessionId: sessionID})
+// proxyExposed authorizes and proxies one request to sessionID's exposed
+// port, forwarding it as path. dedicated is true when the request reached
+// the exposure's own host.
+func (s *Server) proxyExposed(w http.ResponseWriter, r *http.Request, sessionID string, port int, path string, dedicated bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	grant, code, err := s.exposeTarget(ctx, sessionID, port)
 	if err != nil {
-		logrus.Warnf("k8e expose proxy %s/%d: GetSession failed: %v", sessionID, port, err)
-		http.Error(w, fmt.Sprintf("session unreachable: %v", err), http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), code)
 		return
 	}
-	if sess.PodIp == "" {
-		http.Error(w, "session has no pod IP yet", http.StatusServiceUnavailable)
+	if !s.authorizeExpose(w, r, sessionID, port, grant, dedicated) {
 		return
 	}
 
-	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", sess.PodIp, port)}
-	suffix := "/"
-	if len(parts) > 2 && parts[2] != "" {
-		suffix = "/" + parts[2]
-	}
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", grant.PodIP, port)}
 	proxy := httputil.NewSingleHostReverseProxy(target)
 	originalHost := r.Host
 	proxy.Director = func(req *http.Request) {
 		req.URL.Scheme = target.Scheme
 		req.URL.Host = target.Host
 		// The in-pod service sees only the suffix after /k8e/expose/<sid>/<port>.
-		req.URL.Path = suffix
+		req.URL.Path = path
 		req.URL.RawPath = ""
 		// Preserve the original Host so in-pod services that route on
 		// Host/SNI keep working; the proxy only rewrites the dial address.
 		req.Host = originalHost
 		req.Header.Set("X-K8E-Expose-Session", sessionID)
+		stripExposeCredentials(req)
 	}
 	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, proxyErr error) {
 		logrus.Debugf("k8e expose proxy %s/%d: %v", sessionID, port, proxyErr)
@@ -96,3 +126,65 @@ func (s *Server) handleExposeProxy(w http.ResponseWriter, r *http.Request) {
 	}
 	proxy.ServeHTTP(w, r)
 }
+
+// exposeTarget looks up sessionID's exposed port and returns its grant (pod
+// IP and access mode), or an error with the HTTP status to answer.
+func (s *Server) exposeTarget(ctx context.Context, sessionID string, port int) (ExposeGrant, int, error) {
+	if s.exposeIndex != nil {
+		grant, exposed, synced := s.exposeIndex.Lookup(sessionID, port)
+		if synced {
+			switch {
+			case !exposed:
+				return ExposeGrant{}, http.StatusNotFound, fmt.Errorf("port %d not exposed for session %s", port, sessionID)
+			case grant.PodIP == "":
+				return ExposeGrant{}, http.StatusServiceUnavailable, fmt.Errorf("session has no pod IP yet")
+			}
+			return grant, 0, nil
+		}
+	}
+
+	listed, err := s.gw.ListExposed(ctx, &pb.ListExposedRequest{SessionId: sessionID})
+	if err != nil {
+		// Surface the real cause: this page is exactly where a stale gateway
+		// (missing KIP-24 RPCs) or an auth failure shows up first.
+		logrus.Warnf("k8e expose proxy %s/%d: ListExposed failed: %v", sessionID, port, err)
+		return ExposeGrant{}, http.StatusBadGateway, fmt.Errorf("gateway unreachable: %v", err)
+	}
+	var grant ExposeGrant
+	exposed := false
+	for _, svc := range listed.Services {
+		if int(svc.Port) == port {
+			grant.Access, grant.TokenSHA256, grant.Nonce = listedExposeAccess(svc.Access), svc.TokenSha256, svc.Nonce
+			exposed = true
+			break
+		}
+	}
+	if !exposed {
+		return ExposeGrant{}, http.StatusNotFound, fmt.Errorf("port %d not exposed for session %s", port, sessionID)
+	}
+
+	// Resolve the sandbox pod IP via the gateway.
+	sess, err := s.gw.GetSession(ctx, &pb.GetSessionRequest{SessionId: sessionID})
+	if err != nil {
+		logrus.Warnf("k8e expose proxy %s/%d: GetSession failed: %v", sessionID, port, err)
+		return ExposeGrant{}, http.StatusServiceUnavailable, fmt.Errorf("session unreachable: %v", err)
+	}
+	if sess.PodIp == "" {
+		return ExposeGrant{}, http.StatusServiceUnavailable, fmt.Errorf("session has no pod IP yet")
+	}
+	grant.PodIP = sess.PodIp
+	return grant, 0, nil
+}
+
+// listedExposeAccess maps the access mode ListExposed reports to its status
+// form. Unspecified (a gateway without access control) gets the same
+// fail-closed reading as an empty status access: ExposeAccessMode("").
+func listedExposeAccess(a pb.ExposeAccess) string {
+	switch a {
+	case pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC:
+		return ExposeAccessPublic
+	case pb.ExposeAccess_EXPOSE_ACCESS_SIGNED:
+		return ExposeAccessSigned
+	}
+	return ExposeAccessMode("")
+}
@@ -0,0 +1,247 @@
+package e2b
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Access modes of an exposed port, as persisted in the session's
+// status.exposedPorts[].access. An empty mode (ports exposed before access
+// control existed) is read as token; see ExposeAccessMode.
+const (
+	ExposeAccessPublic = "public"
+	ExposeAccessToken  = "token"
+	ExposeAccessSigned = "signed"
+)
+
+// Credentials the expose proxy accepts. The query parameters are consumed
+// on the first visit and traded for the cookie; none of them reach the pod.
+const (
+	exposeTokenParam     = "k8e_token"
+	exposeTokenHeader    = "X-K8E-Expose-Token"
+	exposeExpiresParam   = "k8e_expires"
+	exposeSignatureParam = "k8e_signature"
+	exposeCookieName     = "k8e_expose"
+	// exposeCookieTTL bounds a token-mode cookie; a signed-mode cookie
+	// lasts as long as the link that minted it.
+	exposeCookieTTL = 12 * time.Hour
+)
+
+// ExposeAccessMode is the access mode a recorded status access enforces.
+// Empty fails closed as token: such a port has no token hash, so nothing is
+// let through until it is unexposed and exposed again. Every reader of
+// status.exposedPorts goes through it, so the informer index and the
+// gateway fallback agree.
+func ExposeAccessMode(access string) string {
+	if access == "" {
+		return ExposeAccessToken
+	}
+	return access
+}
+
+// ExposeGrant is what the expose proxy needs to reach and guard one exposed
+// port.
+type ExposeGrant struct {
+	PodIP string
+	// Access is public, token or signed.
+	Access string
+	// TokenSHA256 is the hex SHA-256 of a token-mode port's token.
+	TokenSHA256 string
+	// Nonce identifies the exposure; links and cookies minted for an
+	// earlier exposure of the same port carry another one.
+	Nonce string
+}
+
+// exposePath is the gateway path prefix of sessionID's exposed port; signed
+// links and cookies are scoped to it.
+func exposePath(sessionID string, port int) string {
+	return fmt.Sprintf("/k8e/expose/%s/%d/", sessionID, port)
+}
+
+// exposeSignatureMaterial is the signed-URL material of an exposed port:
+// the E2B file-URL signature over the port's path, with its own operation
+// so a file URL signature never opens an exposed service or vice versa. The
+// operation carries the exposure's nonce, so a link outlives neither an
+// unexpose nor the exposure it was signed for.
+func exposeSignatureMaterial(sessionID string, port int, nonce string, expires int64) signatureMaterial {
+	return signatureMaterial{path: exposePath(sessionID, port), operation: "expose:" + nonce, expirationUnix: &expires}
+}
+
+// SignExposeQuery returns the query string that opens sessionID's exposed
+// port, in the exposure identified by nonce, until expires. It is signed
+// like an E2B signed file URL: keyed by the sandbox's envd access token,
+// itself derived from signingSecret.
+func SignExposeQuery(signingSecret, sessionID string, port int, nonce string, expires time.Time) string {
+	exp := expires.Unix()
+	sig := fileSignature(mintEnvdToken(signingSecret, sessionID), exposeSignatureMaterial(sessionID, port, nonce, exp))
+	return url.Values{
+		exposeExpiresParam:   {strconv.FormatInt(exp, 10)},
+		exposeSignatureParam: {sig},
+	}.Encode()
+}
+
+// ExposeTokenQuery returns the query string that presents a token-mode
+// port's token.
+func ExposeTokenQuery(token string) string {
+	return url.Values{exposeTokenParam: {token}}.Encode()
+}
+
+// ExposeTokenSHA256 is the form a token-mode port's token is stored in.
+func ExposeTokenSHA256(token string) string {
+	return sha256Hex(token)
+}
+
+// ResolveSigningSecret returns the secret the e2b server signs with when
+// none is configured, so a gateway in the same process signs expose links
+// the embedded server accepts. See resolveSigningSecret.
+func ResolveSigningSecret() string {
+	return resolveSigningSecret()
+}
+
+// processSigningSecret is the random fallback signing secret, drawn once so
+// every caller in the process agrees on it.
+var processSigningSecret = sync.OnceValue(func() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	logrus.Warn("e2b: no signing secret configured (K8E_E2B_SIGNING_SECRET); using a random per-process key — envd tokens will not survive a restart")
+	return hex.EncodeToString(buf)
+})
+
+// authorizeExpose enforces the port's access mode. It returns true when the
+// request may be proxied; otherwise it has already answered, either with an
+// error or with the redirect that completes the cookie exchange.
+//
+// A valid credential — the token (query or header), an unexpired
+// signature, or on a dedicated host the cookie — is enough. On a dedicated
+// host (the exposure's own origin) a credential from the query string is
+// traded for a cookie, and GET/HEAD requests are redirected to the same URL
+// without it, so a browser keeps working after the first visit and the
+// credential leaves the address bar. On the shared /k8e/expose/ path no
+// cookie is set or honoured: script served from one exposed port would
+// otherwise carry the cookie of every other port on the origin.
+func (s *Server) authorizeExpose(w http.ResponseWriter, r *http.Request, sessionID string, port int, grant ExposeGrant, dedicated bool) bool {
+	switch grant.Access {
+	case ExposeAccessPublic:
+		return true
+	case ExposeAccessToken, ExposeAccessSigned:
+	default:
+		http.Error(w, "unknown access mode "+grant.Access, http.StatusForbidden)
+		return false
+	}
+	if c, err := r.Cookie(exposeCookieName); dedicated && err == nil && s.exposeCookieValid(c.Value, sessionID, port, grant) {
+		return true
+	}
+	realm := exposePath(sessionID, port)
+	if dedicated {
+		realm = "/"
+	}
+
+	q := r.URL.Query()
+	var expires int64
+	switch grant.Access {
+	case ExposeAccessToken:
+		presented := q.Get(exposeTokenParam)
+		if presented == "" {
+			presented = r.Header.Get(exposeTokenHeader)
+		}
+		if presented == "" || !hmac.Equal([]byte(sha256Hex(presented)), []byte(grant.TokenSHA256)) {
+			w.Header().Set("WWW-Authenticate", `K8E-Expose realm="`+realm+`"`)
+			http.Error(w, "exposed port requires a token", http.StatusUnauthorized)
+			return false
+		}
+		expires = time.Now().Add(exposeCookieTTL).Unix()
+	case ExposeAccessSigned:
+		exp, err := strconv.ParseInt(q.Get(exposeExpiresParam), 10, 64)
+		material := exposeSignatureMaterial(sessionID, port, grant.Nonce, exp)
+		if err != nil || !signatureMatches(q.Get(exposeSignatureParam), sessionID, s.signingSecret, material) {
+			http.Error(w, "exposed port requires a signed URL", http.StatusUnauthorized)
+			return false
+		}
+		if checkSignatureExpiration(material) != nil {
+			http.Error(w, "signed URL has expired", http.StatusUnauthorized)
+			return false
+		}
+		expires = exp
+	}
+	if !dedicated {
+		return true
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     exposeCookieName,
+		Value:    s.exposeCookie(sessionID, port, grant, expires),
+		Path:     "/",
+		Expires:  time.Unix(expires, 0),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	stripped := stripExposeParams(r.URL.Query())
+	if len(stripped) == len(q) || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return true
+	}
+	loc := *r.URL
+	loc.RawQuery = stripped.Encode()
+	http.Redirect(w, r, loc.RequestURI(), http.StatusFound)
+	return false
+}
+
+// exposeCookie is "<expires>.<mac>", the MAC binding the cookie to the
+// port and to its grant: re-exposing the port, in any mode, invalidates
+// cookies handed out before.
+func (s *Server) exposeCookie(sessionID string, port int, grant ExposeGrant, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.signingSecret))
+	fmt.Fprintf(mac, "expose-cookie:%s:%d:%s:%s:%s:%d", sessionID, port, grant.Access, grant.TokenSHA256, grant.Nonce, expires)
+	return strconv.FormatInt(expires, 10) + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) exposeCookieValid(value, sessionID string, port int, grant ExposeGrant) bool {
+	exp, _, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || expires < unixNow() {
+		return false
+	}
+	return hmac.Equal([]byte(value), []byte(s.exposeCookie(sessionID, port, grant, expires)))
+}
+
+// stripExposeParams removes the expose credentials from a query.
+func stripExposeParams(q url.Values) url.Values {
+	for _, k := range []string{exposeTokenParam, exposeExpiresParam, exposeSignatureParam} {
+		q.Del(k)
+	}
+	return q
+}
+
+// stripExposeCredentials removes the expose credentials from a request
+// before it is forwarded to the pod.
+func stripExposeCredentials(req *http.Request) {
+	q := req.URL.Query()
+	if n := len(q); len(stripExposeParams(q)) != n {
+		req.URL.RawQuery = q.Encode()
+	}
+	req.Header.Del(exposeTokenHeader)
+	if _, err := req.Cookie(exposeCookieName); err != nil {
+		return
+	}
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != exposeCookieName {
+			req.AddCookie(c)
+		}
+	}
+}
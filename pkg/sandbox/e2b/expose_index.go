@@ -0,0 +1,82 @@
+package e2b
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ExposeIndex answers the expose proxy's authorization check without a
+// gateway round trip per request: a port is proxied only while it is in the
+// session's status.exposedPorts, under the access mode recorded there, and
+// the pod IP comes from status.podIP.
+type ExposeIndex interface {
+	// Lookup returns the grant for sessionID's exposed port. exposed is
+	// false when the port is not exposed; synced is false until the index
+	// has loaded, and the proxy then asks the gateway instead.
+	Lookup(sessionID string, port int) (grant ExposeGrant, exposed, synced bool)
+}
+
+// informerExposeIndex is an ExposeIndex over a SandboxSession informer.
+type informerExposeIndex struct {
+	namespace string
+	store     cache.Store
+	hasSynced func() bool
+}
+
+// NewExposeIndex starts a SandboxSession informer in namespace (default
+// sandbox-matrix) and returns an ExposeIndex over its cache. The informer
+// stops with ctx.
+func NewExposeIndex(ctx context.Context, dyn dynamic.Interface, namespace string) ExposeIndex {
+	if namespace == "" {
+		namespace = "sandbox-matrix"
+	}
+	inf := dynamicinformer.NewFilteredDynamicInformer(dyn, sessionGVR, namespace, 0, cache.Indexers{}, nil).Informer()
+	go inf.Run(ctx.Done())
+	return &informerExposeIndex{namespace: namespace, store: inf.GetStore(), hasSynced: inf.HasSynced}
+}
+
+func (x *informerExposeIndex) Lookup(sessionID string, port int) (ExposeGrant, bool, bool) {
+	if !x.hasSynced() {
+		return ExposeGrant{}, false, false
+	}
+	obj, ok, err := x.store.GetByKey(x.namespace + "/" + sessionID)
+	if err != nil || !ok {
+		return ExposeGrant{}, false, true
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return ExposeGrant{}, false, true
+	}
+	ports, _, _ := unstructured.NestedSlice(u.Object, "status", "exposedPorts")
+	for _, p := range ports {
+		m, _ := p.(map[string]any)
+		if exposedPortNumber(m["port"]) == port {
+			podIP, _, _ := unstructured.NestedString(u.Object, "status", "podIP")
+			access, _ := m["access"].(string)
+			tokenSHA256, _ := m["tokenSHA256"].(string)
+			nonce, _ := m["nonce"].(string)
+			return ExposeGrant{PodIP: podIP, Access: ExposeAccessMode(access), TokenSHA256: tokenSHA256, Nonce: nonce}, true, true
+		}
+	}
+	return ExposeGrant{}, false, true
+}
+
+// exposedPortNumber reads a port as decoded from JSON (int64) or set
+// directly on an unstructured object.
+func exposedPortNumber(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -1,22 +1,34 @@
 package e2b
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 )
 
 // serveE2BWithExposed boots the e2b HTTP surface with a fake gateway that has
 // a session whose pod IP points at the fake in-pod service, and the given
-// ports registered as exposed. Returns the server URL.
+// ports registered as exposed with public access. Returns the server URL.
 func serveE2BWithExposed(t *testing.T, podIP string, exposed []int32) string {
+	t.Helper()
+	return serveE2BWithExposedAccess(t, podIP, exposed, pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC)
+}
+
+func serveE2BWithExposedAccess(t *testing.T, podIP string, exposed []int32, access pb.ExposeAccess) string {
 	t.Helper()
 	gw := newFakeGateway()
+	gw.exposedAccess = access
 	sess := &pb.GetSessionResponse{SessionId: "sess-1", Phase: "Active", PodIp: podIP}
 	gw.mu.Lock()
 	gw.sessions["sess-1"] = sess
@@ -82,6 +94,21 @@ func TestExposeProxy_NotExposed404(t *testing.T) {
 	}
 }
 
+// TestExposeProxy_UnreportedAccessFailsClosed verifies a gateway that lists
+// a port without an access mode gets the port guarded as token mode, not
+// proxied as public.
+func TestExposeProxy_UnreportedAccessFailsClosed(t *testing.T) {
+	base := serveE2BWithExposedAccess(t, "127.0.0.1", []int32{8080}, pb.ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED)
+	resp, err := http.Get(base + "/k8e/expose/sess-1/8080/")
+	if err != nil {
+		t.Fatalf("proxy request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a port without an access mode, got %d", resp.StatusCode)
+	}
+}
+
 // TestExposeProxy_BadRequest verifies malformed routes are rejected.
 func TestExposeProxy_BadRequest(t *testing.T) {
 	base := serveE2BWithExposed(t, "10.0.0.9", []int32{8080})
@@ -96,3 +123,301 @@ func TestExposeProxy_BadRequest(t *testing.T) {
 		}
 	}
 }
+
+// TestExposeProxy_AuthorizesFromIndex verifies a synced ExposeIndex decides
+// without the gateway: its ports are proxied to its pod IP even when the
+// gateway lists nothing, and a port missing from it is a 404.
+func TestExposeProxy_AuthorizesFromIndex(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	podPort := ln.Addr().(*net.TCPAddr).Port
+	pod := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "indexed")
+	}))
+	pod.Listener = ln
+	pod.Start()
+	defer pod.Close()
+
+	dyn := newTestCRDStore(t).dyn
+	sess := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "k8e.sh/v1alpha1",
+		"kind":       "SandboxSession",
+		"metadata":   map[string]interface{}{"name": "sess-1", "namespace": "sandbox-matrix"},
+		"status": map[string]interface{}{
+			"podIP":        "127.0.0.1",
+			"exposedPorts": []interface{}{map[string]interface{}{"port": int64(podPort), "access": ExposeAccessPublic}},
+		},
+	}}
+	if _, err := dyn.Resource(sessionGVR).Namespace("sandbox-matrix").Create(context.Background(), sess, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	index := NewExposeIndex(ctx, dyn, "")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, _, synced := index.Lookup("sess-1", podPort); synced {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expose index never synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The gateway knows the session but lists no exposures.
+	gw := newFakeGateway()
+	gw.sessions["sess-1"] = &pb.GetSessionResponse{SessionId: "sess-1", Phase: "Active", PodIp: "10.0.0.9"}
+	srv := NewServer(Config{Listen: "127.0.0.1:0", Endpoint: "127.0.0.1:50051", ExposeIndex: index}, gw)
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + fmt.Sprintf("/k8e/expose/sess-1/%d/", podPort))
+	if err != nil {
+		t.Fatalf("proxy request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "indexed" {
+		t.Fatalf("indexed port: status=%d body=%q", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(ts.URL + "/k8e/expose/sess-1/8080/")
+	if err != nil {
+		t.Fatalf("proxy request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("port missing from the index: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// TestExposeIndex_MissingAccessIsToken verifies an exposedPorts entry with
+// no access field (exposed before access control) is read as token mode by
+// the index, like the gateway fallback, so the proxy refuses it.
+func TestExposeIndex_MissingAccessIsToken(t *testing.T) {
+	dyn := newTestCRDStore(t).dyn
+	sess := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "k8e.sh/v1alpha1",
+		"kind":       "SandboxSession",
+		"metadata":   map[string]interface{}{"name": "sess-1", "namespace": "sandbox-matrix"},
+		"status": map[string]interface{}{
+			"podIP":        "127.0.0.1",
+			"exposedPorts": []interface{}{map[string]interface{}{"port": int64(8080)}},
+		},
+	}}
+	if _, err := dyn.Resource(sessionGVR).Namespace("sandbox-matrix").Create(context.Background(), sess, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	index := NewExposeIndex(ctx, dyn, "")
+	deadline := time.Now().Add(5 * time.Second)
+	var grant ExposeGrant
+	for {
+		var exposed, synced bool
+		if grant, exposed, synced = index.Lookup("sess-1", 8080); synced {
+			if !exposed {
+				t.Fatal("port missing from the index")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expose index never synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if grant.Access != ExposeAccessToken || grant.Access != listedExposeAccess(pb.ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED) {
+		t.Fatalf("missing access read as %q", grant.Access)
+	}
+
+	srv := NewServer(Config{Listen: "127.0.0.1:0", Endpoint: "127.0.0.1:50051", ExposeIndex: index}, newFakeGateway())
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/k8e/expose/sess-1/8080/")
+	if err != nil {
+		t.Fatalf("proxy request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("port without access: expected 401, got %d", resp.StatusCode)
+	}
+}
+
+// stubExposeIndex is a synced ExposeIndex with one grant per port.
+type stubExposeIndex map[int]ExposeGrant
+
+func (x stubExposeIndex) Lookup(_ string, port int) (ExposeGrant, bool, bool) {
+	g, ok := x[port]
+	return g, ok, true
+}
+
+// TestExposeProxy_AccessModes verifies token and signed ports demand a
+// credential and, on the exposure's own host, trade a query credential for
+// a cookie with a redirect to the clean URL, never forwarding the
+// credentials to the pod.
+func TestExposeProxy_AccessModes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	pod := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.URL.RawQuery, r.Header.Get("Cookie"), r.Header.Get(exposeTokenHeader))
+	}))
+	pod.Listener = ln
+	pod.Start()
+	defer pod.Close()
+
+	index := stubExposeIndex{port: {PodIP: "127.0.0.1", Access: ExposeAccessToken, TokenSHA256: ExposeTokenSHA256("tok")}}
+	srv := NewServer(Config{Listen: "127.0.0.1:0", Endpoint: "127.0.0.1:50051", SigningSecret: "secret", ExposeIndex: index, ExposeDomain: "Expose.Test."}, newFakeGateway())
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	page := "/page"
+	get := func(path string, header http.Header) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Host = fmt.Sprintf("%d-sess-1.expose.test:443", port)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if resp, _ := get(page, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("token port without a token: %d", resp.StatusCode)
+	}
+	if resp, _ := get(page+"?k8e_token=wrong", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong token: %d", resp.StatusCode)
+	}
+	resp, _ := get(page+"?x=1&k8e_token=tok", nil)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != page+"?x=1" {
+		t.Fatalf("token exchange: %d location=%q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == exposeCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.Path != "/" {
+		t.Fatalf("cookie: %+v", cookie)
+	}
+	withCookie := http.Header{"Cookie": {"a=b; " + cookie.Name + "=" + cookie.Value}}
+	if resp, body := get(page+"?x=1", withCookie); resp.StatusCode != http.StatusOK || body != "x=1|a=b|" {
+		t.Fatalf("cookie visit: %d %q", resp.StatusCode, body)
+	}
+	if resp, body := get(page, http.Header{exposeTokenHeader: {"tok"}}); resp.StatusCode != http.StatusOK || body != "||" {
+		t.Fatalf("header token: %d %q", resp.StatusCode, body)
+	}
+
+	// Switching the port to signed access voids the token cookie.
+	index[port] = ExposeGrant{PodIP: "127.0.0.1", Access: ExposeAccessSigned, Nonce: "n1"}
+	if resp, _ := get(page, withCookie); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("token cookie on a signed port: %d", resp.StatusCode)
+	}
+	expired := SignExposeQuery("secret", "sess-1", port, "n1", time.Now().Add(-time.Minute))
+	if resp, body := get(page+"?"+expired, nil); resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "expired") {
+		t.Fatalf("expired signature: %d %q", resp.StatusCode, body)
+	}
+	forged := SignExposeQuery("other", "sess-1", port, "n1", time.Now().Add(time.Hour))
+	if resp, _ := get(page+"?"+forged, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("signature under another secret: %d", resp.StatusCode)
+	}
+	link := SignExposeQuery("secret", "sess-1", port, "n1", time.Now().Add(time.Hour))
+	resp, _ = get(page+"?"+link, nil)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != page || len(resp.Cookies()) != 1 {
+		t.Fatalf("signed exchange: %d location=%q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	c := resp.Cookies()[0]
+	signedCookie := http.Header{"Cookie": {c.Name + "=" + c.Value}}
+	if resp, body := get(page, signedCookie); resp.StatusCode != http.StatusOK || body != "||" {
+		t.Fatalf("signed cookie visit: %d %q", resp.StatusCode, body)
+	}
+
+	// Unexpose and re-expose in the same mode: a new exposure, a new nonce,
+	// and neither the old link nor its cookie opens the port any more.
+	index[port] = ExposeGrant{PodIP: "127.0.0.1", Access: ExposeAccessSigned, Nonce: "n2"}
+	if resp, _ := get(page+"?"+link, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("link of a previous exposure: %d", resp.StatusCode)
+	}
+	if resp, _ := get(page, signedCookie); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("cookie of a previous exposure: %d", resp.StatusCode)
+	}
+}
+
+// TestExposeProxy_SharedPathSetsNoCookie verifies the shared /k8e/expose/
+// path, one origin for every exposure, demands the credential on each
+// request: it sets no cookie and ignores one that would be valid on the
+// exposure's own host.
+func TestExposeProxy_SharedPathSetsNoCookie(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	pod := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s", r.URL.Path, r.URL.RawQuery)
+	}))
+	pod.Listener = ln
+	pod.Start()
+	defer pod.Close()
+
+	grant := ExposeGrant{PodIP: "127.0.0.1", Access: ExposeAccessToken, TokenSHA256: ExposeTokenSHA256("tok")}
+	srv := NewServer(Config{Listen: "127.0.0.1:0", Endpoint: "127.0.0.1:50051", SigningSecret: "secret", ExposeIndex: stubExposeIndex{port: grant}}, newFakeGateway())
+	ts := httptest.NewServer(srv.Handle())
+	defer ts.Close()
+	page := fmt.Sprintf("%s/k8e/expose/sess-1/%d/page", ts.URL, port)
+
+	resp, err := http.Get(page + "?x=1&k8e_token=tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "/page|x=1" || len(resp.Cookies()) != 0 {
+		t.Fatalf("token on the shared path: %d %q cookies=%v", resp.StatusCode, body, resp.Cookies())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, page, nil)
+	req.AddCookie(&http.Cookie{Name: exposeCookieName, Value: srv.exposeCookie("sess-1", port, grant, time.Now().Add(time.Hour).Unix())})
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("cookie on the shared path: %d", resp.StatusCode)
+	}
+}
+
+func TestExposeHostTarget(t *testing.T) {
+	for _, tc := range []struct {
+		host    string
+		session string
+		port    int
+	}{
+		{"8080-sess-1.expose.test", "sess-1", 8080},
+		{"8080-Sess-1.Expose.Test:443", "sess-1", 8080},
+		{"expose.test", "", 0},
+		{"8080-sess-1.other.test", "", 0},
+		{"a.8080-sess-1.expose.test", "", 0},
+		{"8080.expose.test", "", 0},
+		{"70000-sess-1.expose.test", "", 0},
+		{"x-sess-1.expose.test", "", 0},
+	} {
+		sid, port, ok := exposeHostTarget(tc.host, "expose.test")
+		if ok != (tc.port != 0) || sid != tc.session || port != tc.port {
+			t.Errorf("%s: got %q %d %v", tc.host, sid, port, ok)
+		}
+	}
+}
@@ -18,6 +18,7 @@ type fakeGateway struct {
 	mu        sync.Mutex
 	sessions  map[string]*pb.GetSessionResponse
 	files     map[string]string
+	listDirs  []string // directories ListFiles reports (type "directory")
 	execOut   map[string]*pb.ExecResponse
 	streams   map[string][]*pb.ExecStreamResponse
 	gated     []gatedEntry
@@ -39,6 +40,9 @@ type fakeGateway struct {
 
 	// exposed records KIP-24 expose registrations per session (ports).
 	exposed map[string][]int32
+	// exposedAccess is the mode ListExposed reports for every port; the
+	// zero value stands for a gateway that predates access control.
+	exposedAccess pb.ExposeAccess
 
 	// term records KIP-19 terminal RPCs for pty.* compat tests.
 	term *terminalRows
@@ -177,6 +181,9 @@ func (f *fakeGateway) PauseSession(ctx context.Context, req *pb.PauseSessionRequ
 	}
 	s.Phase = "Paused"
 	f.paused = append(f.paused, req.SessionId)
+	if req.Mode == pb.PauseMode_PAUSE_MODE_MEMORY {
+		return &pb.PauseSessionResponse{Ok: true, Fallback: "runtime class \"\" does not support checkpoints"}, nil
+	}
 	return &pb.PauseSessionResponse{Ok: true}, nil
 }
 
@@ -199,6 +206,9 @@ func (f *fakeGateway) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (
 	for path := range f.files {
 		out = append(out, &pb.FileEntry{Path: path, Modified: 0})
 	}
+	for _, dir := range f.listDirs {
+		out = append(out, &pb.FileEntry{Path: dir, Type: "directory"})
+	}
 	return &pb.ListFilesResponse{Files: out}, nil
 }
 
@@ -298,7 +308,7 @@ func (f *fakeGateway) ListExposed(ctx context.Context, req *pb.ListExposedReques
 	defer f.mu.Unlock()
 	svcs := make([]*pb.ExposedService, 0, len(f.exposed[req.SessionId]))
 	for _, p := range f.exposed[req.SessionId] {
-		svcs = append(svcs, &pb.ExposedService{Port: p, Url: fmt.Sprintf("http://gw/k8e/expose/%s/%d/", req.SessionId, p)})
+		svcs = append(svcs, &pb.ExposedService{Port: p, Url: fmt.Sprintf("http://gw/k8e/expose/%s/%d/", req.SessionId, p), Access: f.exposedAccess})
 	}
 	return &pb.ListExposedResponse{Services: svcs}, nil
 }
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -146,6 +147,39 @@ func readFirstMessage(payload []byte) (map[string]any, error) {
 	return frames[0].json, nil
 }
 
+// maxEnvelopeBytes bounds one frame read off a client stream, so a bogus
+// length prefix cannot make the server allocate unbounded memory.
+const maxEnvelopeBytes = 4 << 20
+
+// readEnvelope reads the next enveloped frame from a client stream as it
+// arrives (StreamInput cannot buffer the whole body: the stream stays open
+// for the life of the REPL). A clean end of stream returns io.EOF; a frame
+// cut short returns io.ErrUnexpectedEOF.
+func readEnvelope(r io.Reader) (frame, error) {
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return frame{}, err
+	}
+	length := binary.BigEndian.Uint32(head[1:])
+	if length > maxEnvelopeBytes {
+		return frame{}, fmt.Errorf("connect envelope too large: %d bytes", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return frame{}, err
+	}
+	var payload map[string]any
+	if length > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return frame{}, fmt.Errorf("connect envelope is not JSON: %w", err)
+		}
+	}
+	return frame{flags: int(head[0]), json: payload}, nil
+}
+
 // --- envd access tokens ---------------------------------------------------
 
 // mintEnvdToken derives the per-sandbox envd access token:
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 )
@@ -136,6 +137,118 @@ func TestEnvdPtyResize(t *testing.T) {
 	}
 }
 
+func TestEnvdPtyUpdatePTY(t *testing.T) {
+	gw := newFakeGateway()
+	s, ts := testServer(t, gw)
+	pid, sid := ptySetup(t, s, ts)
+
+	code := ptyRequest(t, ts, s, sid, "/process.Process/UpdatePTY", map[string]any{
+		"process": map[string]any{"pid": pid},
+		"pty":     map[string]any{"size": map[string]int{"cols": 100, "rows": 30}},
+	})
+	if code != 200 {
+		t.Fatalf("UpdatePTY: want 200, got %d", code)
+	}
+	if len(gw.term.resizes) != 1 || gw.term.resizes[0].Rows != 30 || gw.term.resizes[0].Cols != 100 {
+		t.Fatalf("expected one 30x100 TerminalResize, got %v", gw.term.resizes)
+	}
+}
+
+// streamInputRequest starts a StreamInput client stream for sid and returns
+// the pipe the test writes envelopes to and a channel with the response.
+func streamInputRequest(t *testing.T, ts *httptest.Server, s *Server, sid string) (*io.PipeWriter, <-chan *http.Response) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", ts.URL+"/e2b/envd/process.Process/StreamInput", pr)
+	req.Header.Set("Content-Type", "application/connect+json")
+	for k, v := range envdHeaders(t, s, sid) {
+		req.Header.Set(k, v)
+	}
+	out := make(chan *http.Response, 1)
+	go func() {
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Error(err)
+			close(out)
+			return
+		}
+		out <- resp
+	}()
+	return pw, out
+}
+
+func TestEnvdPtyStreamInput(t *testing.T) {
+	gw := newFakeGateway()
+	s, ts := testServer(t, gw)
+	pid, sid := ptySetup(t, s, ts)
+
+	pw, respCh := streamInputRequest(t, ts, s, sid)
+	b64 := func(v string) string { return base64.StdEncoding.EncodeToString([]byte(v)) }
+	pw.Write(envelope(FlagMessage, map[string]any{"start": map[string]any{"process": map[string]any{"pid": pid}}}))    //nolint:errcheck
+	pw.Write(envelope(FlagMessage, map[string]any{"data": map[string]any{"input": map[string]any{"pty": b64("py")}}})) //nolint:errcheck
+
+	// Input is forwarded while the stream is still open, not on close.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		gw.mu.Lock()
+		n := len(gw.term.writes)
+		gw.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first data frame was not forwarded before the stream closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pw.Write(envelope(FlagMessage, map[string]any{"keepalive": map[string]any{}}))                                         //nolint:errcheck
+	pw.Write(envelope(FlagMessage, map[string]any{"data": map[string]any{"input": map[string]any{"pty": b64("thon\r")}}})) //nolint:errcheck
+	pw.Close()
+
+	resp := <-respCh
+	if resp == nil {
+		t.Fatal("no response")
+	}
+	frames, err := parseEnvelopes([]byte(readBody(t, resp)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].flags != FlagMessage || frames[1].flags != FlagEndStream || frames[1].json["error"] != nil {
+		t.Fatalf("expected message + clean end-stream, got %v", frames)
+	}
+	if len(gw.term.writes) != 2 || string(gw.term.writes[1].Data) != "thon\r" {
+		t.Fatalf("expected 2 TerminalWrites ending in %q, got %v", "thon\r", gw.term.writes)
+	}
+}
+
+func TestEnvdStreamInputDataBeforeStart(t *testing.T) {
+	gw := newFakeGateway()
+	s, ts := testServer(t, gw)
+	_, sid := ptySetup(t, s, ts)
+
+	pw, respCh := streamInputRequest(t, ts, s, sid)
+	go func() {
+		pw.Write(envelope(FlagMessage, map[string]any{"data": map[string]any{"input": map[string]any{"stdin": "eA=="}}})) //nolint:errcheck
+		pw.Close()
+	}()
+	resp := <-respCh
+	if resp == nil {
+		t.Fatal("no response")
+	}
+	frames, _ := parseEnvelopes([]byte(readBody(t, resp)))
+	if len(frames) == 0 {
+		t.Fatal("expected an end-stream error frame")
+	}
+	errObj, _ := frames[len(frames)-1].json["error"].(map[string]any)
+	if errObj["code"] != "invalid_argument" {
+		t.Fatalf("expected invalid_argument, got %v", frames)
+	}
+	if len(gw.term.writes) != 0 {
+		t.Fatalf("data before start must not be written, got %d writes", len(gw.term.writes))
+	}
+}
+
 func TestEnvdPtyKill(t *testing.T) {
 	gw := newFakeGateway()
 	s, ts := testServer(t, gw)
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 )
 
@@ -20,9 +21,9 @@ import (
 // the rest; these endpoints are what the e2b layer needs on top.
 //
 // The pod IP comes from GetSession — the same source the gateway uses to
-// reach sandboxd. sandboxd has no auth (KIP-16 M10 tracks that gap), but the
-// pod IP is not routable from outside the cluster, and the e2b-server already
-// holds the session credential, so this preserves the existing trust model.
+// reach sandboxd. The embedded server shares the gateway's authenticating
+// transport (Config.SandboxdTransport), so keyed pods accept its calls; a
+// standalone e2b-server has no sandboxd keys and reaches only unkeyed pods.
 type sandboxdClient struct {
 	gw     Gateway
 	client *http.Client
@@ -32,10 +33,15 @@ type sandboxdClient struct {
 	baseURL string
 }
 
-func newSandboxdClient(gw Gateway) *sandboxdClient {
+func newSandboxdClient(gw Gateway, transport http.RoundTripper) *sandboxdClient {
+	if transport == nil {
+		// The gateway's shared transport already traces; a standalone
+		// server traces its own calls.
+		transport = tracing.Transport(http.DefaultTransport, "sandboxd")
+	}
 	return &sandboxdClient{
 		gw:     gw,
-		client: &http.Client{Timeout: 30 * time.Second},
+		client: &http.Client{Timeout: 30 * time.Second, Transport: transport},
 	}
 }
 
@@ -2,8 +2,6 @@ package e2b
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"net/http"
 	"os"
 	"regexp"
@@ -14,6 +12,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"github.com/xiaods/k8e/pkg/sandbox/client"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
 )
 
 // Server is the E2B-compatible HTTP server (KIP-18). It speaks the E2B
@@ -54,6 +54,15 @@ type Server struct {
 	mu      sync.Mutex
 	lastErr map[string]error
 
+	audit *audit.Log
+
+	// exposeIndex authorizes the expose proxy from a session informer
+	// (nil → ListExposed and GetSession on every request).
+	exposeIndex ExposeIndex
+	// exposeDomain is the wildcard domain of per-exposure hosts ("" → the
+	// shared /k8e/expose/ path only).
+	exposeDomain string
+
 	logf func(string, ...any)
 }
 
@@ -85,6 +94,25 @@ type Config struct {
 	// Defaults to an in-memory store; the embedded k8e-server mode injects a
 	// CRD-backed store so multi-node control planes share state.
 	StateStore stateStore
+	// AuditDir, when set, receives an append-only audit record per request
+	// (rotated files prefixed "e2b"). The embedded server shares the
+	// gateway's directory so `k8e-sandbox-cli audit` sees both surfaces.
+	AuditDir string
+	// AuditRetention is how long rotated audit files are kept
+	// (zero → audit.DefaultRetention).
+	AuditRetention time.Duration
+	// SandboxdTransport carries the direct sandboxd calls (nil →
+	// http.DefaultTransport). The embedded server passes the gateway's
+	// authenticating transport so keyed sandbox pods accept them.
+	SandboxdTransport http.RoundTripper
+	// ExposeIndex, when set, answers the /k8e/expose proxy's authorization
+	// check from a SandboxSession informer instead of ListExposed per
+	// request. The embedded server passes NewExposeIndex.
+	ExposeIndex ExposeIndex
+	// ExposeDomain, when set, serves every exposure on its own host,
+	// <port>-<session>.<ExposeDomain> (a wildcard DNS name routed to this
+	// server), where the proxy may trade credentials for a cookie.
+	ExposeDomain string
 }
 
 // NewServer builds an E2B server against the given gateway.
@@ -139,10 +167,20 @@ func NewServer(cfg Config, gw Gateway) *Server {
 		registry:        registry,
 		processes:       NewProcessTable(),
 		ptys:            map[int]*ptyRow{},
-		sandboxd:        newSandboxdClient(gw),
+		sandboxd:        newSandboxdClient(gw, cfg.SandboxdTransport),
 		lastErr:         map[string]error{},
+		exposeIndex:     cfg.ExposeIndex,
+		exposeDomain:    strings.ToLower(strings.Trim(cfg.ExposeDomain, ".")),
 		logf:            func(format string, args ...any) { logrus.Infof("e2b: "+format, args...) },
 	}
+	if cfg.AuditDir != "" {
+		l, err := audit.Open(audit.Options{Dir: cfg.AuditDir, Prefix: "e2b", Retention: cfg.AuditRetention})
+		if err != nil {
+			logrus.Errorf("e2b: audit log disabled: %v", err)
+		} else {
+			s.audit = l
+		}
+	}
 	return s
 }
 
@@ -176,18 +214,17 @@ func (s *Server) Handle() http.Handler {
 	envd.HandleFunc("/process.Process/CloseStdin", s.handleProcessCloseStdin)
 	envd.HandleFunc("/process.Process/SendSignal", s.handleProcessSendSignal)
 	envd.HandleFunc("/process.Process/Update", s.handleProcessUpdate)
-	envd.HandleFunc("/process.Process/Update", s.handleUnimplementedProcess("Update"))
-	envd.HandleFunc("/process.Process/StreamInput", s.handleStreamInputUnimplemented)
-	envd.HandleFunc("/process.Process/UpdatePTY", s.handleUnimplementedProcess("UpdatePTY"))
+	envd.HandleFunc("/process.Process/UpdatePTY", s.handleProcessUpdate)
+	envd.HandleFunc("/process.Process/StreamInput", s.handleProcessStreamInput)
 	envd.HandleFunc("/filesystem.Filesystem/Stat", s.handleFSStat)
 	envd.HandleFunc("/filesystem.Filesystem/ListDir", s.handleFSListDir)
 	envd.HandleFunc("/filesystem.Filesystem/MakeDir", s.handleFSMakeDir)
 	envd.HandleFunc("/filesystem.Filesystem/Move", s.handleFSMove)
 	envd.HandleFunc("/filesystem.Filesystem/Remove", s.handleFSRemove)
-	// The streaming WatchDir stays unimplemented (the SDK uses the polling
-	// trio below — CreateWatcher/GetWatcherEvents/RemoveWatcher — via
-	// WatchHandle; the streaming RPC is a low-level path).
-	envd.HandleFunc("/filesystem.Filesystem/WatchDir", s.handleUnimplementedFS("WatchDir"))
+	// WatchDir streams events (files.watchDir); the polling trio below
+	// (CreateWatcher/GetWatcherEvents/RemoveWatcher) backs WatchHandle.
+	// Both ride the same sandboxd watchers.
+	envd.HandleFunc("/filesystem.Filesystem/WatchDir", s.handleFSWatchDir)
 	envd.HandleFunc("/filesystem.Filesystem/CreateWatcher", s.handleFSCreateWatcher)
 	envd.HandleFunc("/filesystem.Filesystem/GetWatcherEvents", s.handleFSGetWatcherEvents)
 	envd.HandleFunc("/filesystem.Filesystem/RemoveWatcher", s.handleFSRemoveWatcher)
@@ -213,7 +250,7 @@ func (s *Server) Handle() http.Handler {
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	}).Methods(http.MethodGet)
 
-	return s.timeoutLayers(r)
+	return tracing.Handler(s.exposeHosts(s.auditRequests(s.timeoutLayers(r))), "e2b")
 }
 
 // registerControlRoutes wires the control-plane routes onto a router (used
@@ -305,7 +342,11 @@ func (s *Server) Start(ctx context.Context) error {
 	case <-ctx.Done():
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		return srv.Shutdown(shutdownCtx)
+		err := srv.Shutdown(shutdownCtx)
+		if s.audit != nil {
+			s.audit.Close()
+		}
+		return err
 	case err := <-errCh:
 		return err
 	}
@@ -421,10 +462,7 @@ func resolveSigningSecret() string {
 			return string(b)
 		}
 	}
-	buf := make([]byte, 32)
-	_, _ = rand.Read(buf)
-	logrus.Warn("e2b: no signing secret configured (K8E_E2B_SIGNING_SECRET); using a random per-process key — envd tokens will not survive a restart")
-	return hex.EncodeToString(buf)
+	return processSigningSecret()
 }
 
 // helpers ------------------------------------------------------------------
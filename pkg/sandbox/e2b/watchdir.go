@@ -0,0 +1,296 @@
+package e2b
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// Streaming WatchDir (E2B SDK files.watchDir) on top of the sandboxd watch
+// backend the polling trio uses. sandboxd watches a single directory per
+// watcher (inotify is not recursive), so a recursive WatchDir holds one
+// watcher per directory in the tree and adds watchers for directories
+// created or moved in while the stream is open. The stream polls the
+// watchers, relays events as FilesystemEvent frames with names relative to
+// the watched root (envd semantics), and removes every watcher when the
+// client disconnects.
+
+// watchPollInterval is how often a WatchDir stream drains its watchers.
+var watchPollInterval = 250 * time.Millisecond
+
+// sandboxd event types (watch.zig eventTypeOf).
+const (
+	watchEventCreate = 1
+	watchEventRemove = 3
+	watchEventRename = 4
+)
+
+// dirWatch is the set of sandboxd watchers backing one WatchDir stream.
+// Owned by the stream goroutine; not safe for concurrent use.
+type dirWatch struct {
+	s         *Server
+	sandboxID string
+	root      string
+	recursive bool
+	dirs      map[string]int // watched directory → sandboxd watcher id
+}
+
+// startDirWatch validates root and creates its watchers: root alone, or
+// root plus every directory below it when recursive.
+func (s *Server) startDirWatch(ctx context.Context, sandboxID, root string, recursive bool) (*dirWatch, *E2bError) {
+	root = path.Clean(root)
+	st, err := s.sandboxd.stat(ctx, sandboxID, root)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, connectError("not_found", "path not found: "+root)
+		}
+		return nil, connectError("internal", "watch failed: "+err.Error())
+	}
+	if st.Type != "dir" {
+		return nil, connectError("invalid_argument", "not a directory: "+root)
+	}
+	dw := &dirWatch{s: s, sandboxID: sandboxID, root: root, recursive: recursive, dirs: map[string]int{}}
+	if err := dw.addTree(ctx, root); err != nil {
+		dw.close()
+		return nil, connectError("internal", "watch failed: "+err.Error())
+	}
+	return dw, nil
+}
+
+// addTree watches dir and, for a recursive watch, every directory below it.
+func (dw *dirWatch) addTree(ctx context.Context, dir string) error {
+	if err := dw.add(ctx, dir); err != nil {
+		return err
+	}
+	if !dw.recursive {
+		return nil
+	}
+	resp, err := dw.s.gw.ListFiles(ctx, &pb.ListFilesRequest{SessionId: dw.sandboxID})
+	if err != nil {
+		return err
+	}
+	var subdirs []string
+	for _, f := range resp.Files {
+		// The gateway reports sandboxd's "dir" as "directory".
+		if f.Type == "directory" && strings.HasPrefix(f.Path, dir+"/") {
+			subdirs = append(subdirs, path.Clean(f.Path))
+		}
+	}
+	sort.Strings(subdirs)
+	for _, d := range subdirs {
+		if err := dw.add(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dw *dirWatch) add(ctx context.Context, dir string) error {
+	if _, ok := dw.dirs[dir]; ok {
+		return nil
+	}
+	id, err := dw.s.sandboxd.createWatcher(ctx, dw.sandboxID, dir)
+	if err != nil {
+		return err
+	}
+	dw.dirs[dir] = id
+	return nil
+}
+
+// dropTree removes the watchers for dir and everything below it (the
+// directory was deleted or moved away).
+func (dw *dirWatch) dropTree(ctx context.Context, dir string) {
+	for d, id := range dw.dirs {
+		if d == dw.root || (d != dir && !strings.HasPrefix(d, dir+"/")) {
+			continue
+		}
+		_ = dw.s.sandboxd.removeWatcher(ctx, dw.sandboxID, id)
+		delete(dw.dirs, d)
+	}
+}
+
+// poll drains every watcher and returns the new events, names relative to
+// the root. Directory churn in a recursive watch updates the watcher set.
+// An error means the root watcher is gone (sandbox restarted or released).
+func (dw *dirWatch) poll(ctx context.Context) ([]watchEvent, error) {
+	dirs := make([]string, 0, len(dw.dirs))
+	for d := range dw.dirs {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	var out []watchEvent
+	for _, d := range dirs {
+		id, ok := dw.dirs[d]
+		if !ok {
+			continue // dropped by an earlier event in this round
+		}
+		events, err := dw.s.sandboxd.getWatcherEvents(ctx, dw.sandboxID, id)
+		if err != nil {
+			if d == dw.root {
+				return out, err
+			}
+			delete(dw.dirs, d)
+			continue
+		}
+		for _, e := range events {
+			full := path.Clean(e.Name)
+			if dw.recursive {
+				dw.track(ctx, full, e.Type)
+			}
+			out = append(out, watchEvent{Name: dw.relative(full), Type: e.Type})
+		}
+	}
+	return out, nil
+}
+
+// track keeps a recursive watch's watcher set in step with the tree.
+func (dw *dirWatch) track(ctx context.Context, full string, eventType int) {
+	switch eventType {
+	case watchEventRemove:
+		dw.dropTree(ctx, full)
+	case watchEventCreate, watchEventRename:
+		// A rename is reported for both ends; the source no longer stats.
+		st, err := dw.s.sandboxd.stat(ctx, dw.sandboxID, full)
+		if err != nil {
+			dw.dropTree(ctx, full)
+			return
+		}
+		if st.Type == "dir" {
+			if err := dw.addTree(ctx, full); err != nil {
+				dw.s.log("watchdir %s: watch %s: %v", dw.sandboxID, full, err)
+			}
+		}
+	}
+}
+
+func (dw *dirWatch) relative(full string) string {
+	if rel := strings.TrimPrefix(full, dw.root+"/"); rel != full {
+		return rel
+	}
+	return path.Base(full)
+}
+
+// close removes every watcher. Runs on a fresh context: the request
+// context may already be done when the client went away.
+func (dw *dirWatch) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for d, id := range dw.dirs {
+		if err := dw.s.sandboxd.removeWatcher(ctx, dw.sandboxID, id); err != nil {
+			dw.s.log("watchdir %s: remove watcher %d: %v", dw.sandboxID, id, err)
+		}
+		delete(dw.dirs, d)
+	}
+}
+
+// handleFSWatchDir implements filesystem.Filesystem/WatchDir, the
+// server-streaming watch behind the SDK's files.watchDir: {path, recursive}
+// in, then a start frame and one {filesystem: {name, type}} frame per event
+// until the client disconnects. Idle streams carry keepalive frames.
+func (s *Server) handleFSWatchDir(w http.ResponseWriter, r *http.Request) {
+	body, err := readFirstMessage(mustReadBody(r))
+	if err != nil {
+		writeEnvdStreamError(w, err.(*E2bError))
+		return
+	}
+	rawPath, _ := body["path"].(string)
+	recursive, _ := body["recursive"].(bool)
+	root, err := resolveSandboxPath(rawPath)
+	if err != nil {
+		writeEnvdStreamError(w, connectError("invalid_argument", err.Error()))
+		return
+	}
+	sandboxID := sandboxIDOf(r)
+	if _, _, e2e := s.wakeForTraffic(r, sandboxID); e2e != nil {
+		writeEnvdStreamError(w, e2e)
+		return
+	}
+	dw, e2e := s.startDirWatch(r.Context(), sandboxID, root, recursive)
+	if e2e != nil {
+		writeEnvdStreamError(w, e2e)
+		return
+	}
+	defer dw.close()
+
+	hijack, ok := w.(http.Hijacker)
+	if !ok {
+		return
+	}
+	conn, buf, err := hijack.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/connect+json\r\n\r\n")
+	if writeWatchFrame(buf, map[string]any{"start": map[string]any{}}) != nil {
+		return
+	}
+
+	// The request body is fully read, so the only thing left to read off
+	// the connection is its close: that is the client going away.
+	gone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, buf)
+		close(gone)
+	}()
+	// The hijacked request's context is not cancelled on disconnect; derive
+	// one that is, so an in-flight sandboxd poll is abandoned promptly.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-gone
+		cancel()
+	}()
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-ticker.C:
+		}
+		events, err := dw.poll(ctx)
+		for _, e := range events {
+			if writeWatchFrame(buf, map[string]any{"filesystem": map[string]any{
+				"name": e.Name,
+				"type": eventTypeName(e.Type),
+			}}) != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				writeEnvelopeRaw(buf, envelope(FlagEndStream, map[string]any{
+					"error": map[string]string{"code": "unavailable", "message": "watch lost: " + err.Error()},
+				}))
+			}
+			return
+		}
+		if time.Since(lastWrite) >= keepaliveInterval {
+			if writeWatchFrame(buf, map[string]any{"keepalive": map[string]any{}}) != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+	}
+}
+
+// writeWatchFrame writes one WatchDirResponse message and flushes it,
+// reporting write errors so the stream stops on a dead connection.
+func writeWatchFrame(buf *bufio.ReadWriter, payload map[string]any) error {
+	if _, err := buf.Write(envelope(FlagMessage, payload)); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
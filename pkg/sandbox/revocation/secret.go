@@ -0,0 +1,77 @@
+package revocation
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// FromSecret decodes the revocation list carried by secret. A nil secret or
+// a secret without DataKey is an empty list.
+func FromSecret(secret *corev1.Secret) (*File, error) {
+	if secret == nil {
+		return New(), nil
+	}
+	return Parse(secret.Data[DataKey])
+}
+
+// Load reads the revocation Secret; a missing Secret is an empty list.
+func Load(ctx context.Context, k8s kubernetes.Interface) (*File, error) {
+	secret, err := k8s.CoreV1().Secrets(SecretNamespace).Get(ctx, SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return FromSecret(secret)
+}
+
+// Update applies mutate to the stored list and writes it back, creating the
+// Secret on first use. Concurrent writers (CLI, every gateway replica) are
+// serialized by resourceVersion and retried on conflict. mutate returns false
+// to skip the write when nothing changed.
+func Update(ctx context.Context, k8s kubernetes.Interface, mutate func(*File) bool) (*File, error) {
+	var out *File
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := k8s.CoreV1().Secrets(SecretNamespace).Get(ctx, SecretName, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		if create {
+			secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: SecretNamespace}}
+		}
+		f, err := FromSecret(secret)
+		if err != nil {
+			return err
+		}
+		out = f
+		if !mutate(f) {
+			return nil
+		}
+		data, err := Encode(f)
+		if err != nil {
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[DataKey] = data
+		if create {
+			_, err = k8s.CoreV1().Secrets(SecretNamespace).Create(ctx, secret, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Lost the create race: retry as an update.
+				return apierrors.NewConflict(corev1.Resource("secrets"), SecretName, err)
+			}
+			return err
+		}
+		_, err = k8s.CoreV1().Secrets(SecretNamespace).Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	return out, err
+}
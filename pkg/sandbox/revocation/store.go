@@ -0,0 +1,149 @@
+// Package revocation provides the shared Secret JSON codec for sandbox mTLS
+// client-certificate revocations (KIP-14). The list lives in the
+// sandbox-matrix/sandbox-cert-revocations Secret so revocations survive
+// k8e-server restarts and are seen by every gateway replica in an HA cluster.
+package revocation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// SecretName / SecretNamespace locate the revocation Secret; it sits next
+	// to the sandbox-apikeys Secret.
+	SecretName      = "sandbox-cert-revocations"
+	SecretNamespace = "sandbox-matrix"
+	// DataKey is the Secret data key holding the JSON-encoded File.
+	DataKey = "revocations.json"
+
+	// FileVersion is the revocations.json version.
+	FileVersion = 1
+)
+
+// Cert revokes a single client certificate by its SHA-256 fingerprint.
+type Cert struct {
+	// Serial is the certificate serial (decimal) when known; the signed CRL
+	// can only list entries with a serial.
+	Serial    string    `json:"serial,omitempty"`
+	KeyName   string    `json:"key_name,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	// ExpiresAt is the certificate NotAfter; the entry is pruned after it.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// Key revokes every certificate issued under an API key name (the cert CN)
+// up to RevokedAt. Certificates issued later — e.g. after the key name is
+// re-created — are unaffected, so no per-node issuance ledger is needed to
+// lock out all devices of a deleted key.
+type Key struct {
+	RevokedAt time.Time `json:"revoked_at"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// File is the Secret payload.
+type File struct {
+	Version int             `json:"version"`
+	Certs   map[string]Cert `json:"certs"` // fingerprint → entry
+	Keys    map[string]Key  `json:"keys"`  // API key name → cutoff
+}
+
+// New returns an empty revocation file.
+func New() *File {
+	return &File{Version: FileVersion, Certs: map[string]Cert{}, Keys: map[string]Key{}}
+}
+
+// Parse decodes a revocations.json payload. Empty input is an empty list.
+func Parse(data []byte) (*File, error) {
+	f := New()
+	if len(data) == 0 {
+		return f, nil
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parse cert revocations: %w", err)
+	}
+	if f.Certs == nil {
+		f.Certs = map[string]Cert{}
+	}
+	if f.Keys == nil {
+		f.Keys = map[string]Key{}
+	}
+	return f, nil
+}
+
+// Encode serializes the file as JSON.
+func Encode(f *File) ([]byte, error) {
+	if f == nil {
+		f = New()
+	}
+	f.Version = FileVersion
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// NormalizeFingerprint lowercases a hex SHA-256 fingerprint and strips the
+// ':' separators openssl prints, so either form matches.
+func NormalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
+
+// RevokeCert adds (or keeps) a fingerprint revocation. Returns false when the
+// fingerprint was already revoked.
+func (f *File) RevokeCert(fingerprint string, c Cert) bool {
+	fp := NormalizeFingerprint(fingerprint)
+	if _, ok := f.Certs[fp]; ok {
+		return false
+	}
+	if c.RevokedAt.IsZero() {
+		c.RevokedAt = time.Now().UTC()
+	}
+	f.Certs[fp] = c
+	return true
+}
+
+// RevokeKey revokes every certificate issued under keyName up to at. A later
+// cutoff replaces an earlier one.
+func (f *File) RevokeKey(keyName string, at time.Time, reason string) {
+	if prev, ok := f.Keys[keyName]; ok && !at.After(prev.RevokedAt) {
+		return
+	}
+	f.Keys[keyName] = Key{RevokedAt: at.UTC(), Reason: reason}
+}
+
+// IsRevoked reports whether a certificate is revoked, either by fingerprint
+// or because it was issued (notBefore) at or before its key's cutoff.
+func (f *File) IsRevoked(fingerprint, keyName string, notBefore time.Time) bool {
+	if f == nil {
+		return false
+	}
+	if _, ok := f.Certs[NormalizeFingerprint(fingerprint)]; ok {
+		return true
+	}
+	if k, ok := f.Keys[keyName]; ok && keyName != "" && !notBefore.After(k.RevokedAt) {
+		return true
+	}
+	return false
+}
+
+// Prune drops fingerprint entries whose certificate has expired (an expired
+// certificate is rejected by the TLS handshake anyway) and key cutoffs older
+// than maxCertTTL, after which every certificate they cover has expired.
+// Returns true when anything was removed.
+func (f *File) Prune(now time.Time, maxCertTTL time.Duration) bool {
+	changed := false
+	for fp, c := range f.Certs {
+		if c.ExpiresAt != nil && now.After(*c.ExpiresAt) {
+			delete(f.Certs, fp)
+			changed = true
+		}
+	}
+	for name, k := range f.Keys {
+		if maxCertTTL > 0 && now.Sub(k.RevokedAt) > maxCertTTL {
+			delete(f.Keys, name)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,105 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKeyCutoffOnlyCoversEarlierCerts(t *testing.T) {
+	f := New()
+	cutoff := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	f.RevokeKey("agent-a", cutoff, "deleted")
+
+	if !f.IsRevoked("", "agent-a", cutoff.Add(-time.Hour)) {
+		t.Fatal("cert issued before the cutoff must be revoked")
+	}
+	if f.IsRevoked("", "agent-a", cutoff.Add(time.Hour)) {
+		t.Fatal("cert issued after the cutoff (re-created key) must stay valid")
+	}
+	if f.IsRevoked("", "agent-b", cutoff.Add(-time.Hour)) {
+		t.Fatal("other keys must be unaffected")
+	}
+
+	// An earlier cutoff never shrinks an existing one.
+	f.RevokeKey("agent-a", cutoff.Add(-24*time.Hour), "")
+	if !f.Keys["agent-a"].RevokedAt.Equal(cutoff) {
+		t.Fatalf("cutoff moved backwards: %v", f.Keys["agent-a"].RevokedAt)
+	}
+}
+
+func TestFingerprintNormalizationAndRoundTrip(t *testing.T) {
+	f := New()
+	if !f.RevokeCert("AB:CD:EF", Cert{Serial: "42"}) {
+		t.Fatal("first revoke should add")
+	}
+	if f.RevokeCert("abcdef", Cert{}) {
+		t.Fatal("same fingerprint in another form should be a no-op")
+	}
+	data, err := Encode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back.IsRevoked("ab:cd:ef", "", time.Time{}) || back.Certs["abcdef"].Serial != "42" {
+		t.Fatalf("round-trip lost entry: %+v", back.Certs)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	f := New()
+	f.RevokeCert("old", Cert{ExpiresAt: &past})
+	f.RevokeCert("live", Cert{ExpiresAt: &future})
+	f.RevokeKey("stale", now.Add(-100*24*time.Hour), "")
+	f.RevokeKey("fresh", now.Add(-time.Hour), "")
+
+	if !f.Prune(now, 90*24*time.Hour) {
+		t.Fatal("expected prune to remove entries")
+	}
+	if _, ok := f.Certs["old"]; ok {
+		t.Fatal("expired cert entry should be pruned")
+	}
+	if _, ok := f.Keys["stale"]; ok {
+		t.Fatal("key cutoff older than the max cert TTL should be pruned")
+	}
+	if _, ok := f.Certs["live"]; !ok {
+		t.Fatal("live cert entry must survive")
+	}
+	if f.Prune(now, 90*24*time.Hour) {
+		t.Fatal("second prune should be a no-op")
+	}
+}
+
+func TestUpdateCreatesAndAppendsSecret(t *testing.T) {
+	ctx := context.Background()
+	k8s := kubefake.NewSimpleClientset()
+
+	if _, err := Update(ctx, k8s, func(f *File) bool {
+		f.RevokeKey("agent-a", time.Now(), "")
+		return true
+	}); err != nil {
+		t.Fatalf("first update (create): %v", err)
+	}
+	if _, err := Update(ctx, k8s, func(f *File) bool {
+		return f.RevokeCert("abcdef", Cert{})
+	}); err != nil {
+		t.Fatalf("second update: %v", err)
+	}
+	f, err := Load(ctx, k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Keys["agent-a"]; !ok {
+		t.Fatal("key revocation lost")
+	}
+	if _, ok := f.Certs["abcdef"]; !ok {
+		t.Fatal("cert revocation lost")
+	}
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// fileExporter writes finished spans as JSON lines, for clusters that
+// cannot reach a collector. The output can be grepped by trace_id or
+// converted for import later.
+type fileExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+// errNoFile is returned for an empty exporter path.
+var errNoFile = errors.New("trace file path is empty")
+
+func newFileExporter(path string) (*fileExporter, error) {
+	switch path {
+	case "":
+		return nil, errNoFile
+	case "-":
+		return &fileExporter{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{w: f, c: f}, nil
+}
+
+// spanRecord is one exported span.
+type spanRecord struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Service      string         `json:"service,omitempty"`
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	Start        time.Time      `json:"start"`
+	DurationMS   float64        `json:"duration_ms"`
+	Status       string         `json:"status,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+}
+
+func toSpanRecord(s sdktrace.ReadOnlySpan) spanRecord {
+	r := spanRecord{
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		Name:       s.Name(),
+		Kind:       s.SpanKind().String(),
+		Start:      s.StartTime(),
+		DurationMS: float64(s.EndTime().Sub(s.StartTime()).Microseconds()) / 1000,
+	}
+	if p := s.Parent(); p.IsValid() {
+		r.ParentSpanID = p.SpanID().String()
+	}
+	if svc, ok := s.Resource().Set().Value("service.name"); ok {
+		r.Service = svc.AsString()
+	}
+	if st := s.Status(); st.Code != 0 {
+		r.Status = st.Code.String()
+		r.Error = st.Description
+	}
+	if attrs := s.Attributes(); len(attrs) > 0 {
+		r.Attributes = make(map[string]any, len(attrs))
+		for _, kv := range attrs {
+			r.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+		}
+	}
+	return r
+}
+
+// ExportSpans implements sdktrace.SpanExporter.
+func (e *fileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		if err := enc.Encode(toSpanRecord(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.c != nil {
+		return e.c.Close()
+	}
+	return nil
+}
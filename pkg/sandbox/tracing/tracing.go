@@ -0,0 +1,184 @@
+// Package tracing wires OpenTelemetry tracing for the sandbox CLI, the E2B
+// HTTP layer and the gRPC gateway: OTLP/gRPC export or a JSON-lines
+// file/stdout exporter for air-gapped clusters, and W3C trace-context
+// propagation over gRPC metadata and HTTP headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xiaods/k8e/pkg/version"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// instrumentationName names the tracer for spans created by k8e code.
+const instrumentationName = "github.com/xiaods/k8e/pkg/sandbox"
+
+// Environment variables read by ConfigFromEnv. The k8e server flags
+// --sandbox-tracing-* read the same variables.
+const (
+	EnvEndpoint    = "K8E_SANDBOX_TRACING_ENDPOINT"
+	EnvInsecure    = "K8E_SANDBOX_TRACING_INSECURE"
+	EnvFile        = "K8E_SANDBOX_TRACING_FILE"
+	EnvSampleRatio = "K8E_SANDBOX_TRACING_SAMPLE_RATIO"
+)
+
+// Config selects where spans go. With neither Endpoint nor File set, no
+// spans are recorded but incoming trace context is still passed on.
+type Config struct {
+	ServiceName string
+	// Endpoint is the OTLP/gRPC collector: host:port, or a URL whose http
+	// scheme implies a plaintext connection.
+	Endpoint string
+	// Insecure disables TLS to a host:port Endpoint.
+	Insecure bool
+	// File receives one JSON object per span; "-" is stdout.
+	File string
+	// SampleRatio is the fraction of new traces recorded (zero → all).
+	// Calls that arrive with a sampled parent are always recorded.
+	SampleRatio float64
+}
+
+// Enabled reports whether spans are exported anywhere.
+func (c Config) Enabled() bool {
+	return c.Endpoint != "" || c.File != ""
+}
+
+// ConfigFromEnv reads the K8E_SANDBOX_TRACING_* variables.
+func ConfigFromEnv(service string) Config {
+	c := Config{
+		ServiceName: service,
+		Endpoint:    os.Getenv(EnvEndpoint),
+		File:        os.Getenv(EnvFile),
+	}
+	c.Insecure, _ = strconv.ParseBool(os.Getenv(EnvInsecure))
+	c.SampleRatio, _ = strconv.ParseFloat(os.Getenv(EnvSampleRatio), 64)
+	return c
+}
+
+// Setup installs the W3C trace-context propagator and, when cfg is enabled,
+// a global tracer provider exporting to cfg's destinations. The returned
+// function flushes and stops export; call it before the process exits.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", cfg.ServiceName),
+			attribute.String("service.version", version.Version),
+		)),
+	}
+	if cfg.Endpoint != "" {
+		exp, err := otlptracegrpc.New(ctx, otlpOptions(cfg)...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	if cfg.File != "" {
+		exp, err := newFileExporter(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func otlpOptions(cfg Config) []otlptracegrpc.Option {
+	if strings.Contains(cfg.Endpoint, "://") {
+		return []otlptracegrpc.Option{otlptracegrpc.WithEndpointURL(cfg.Endpoint)}
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return opts
+}
+
+// Start starts a span from the global tracer provider.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport traces outgoing HTTP calls to peer (e.g. "sandboxd") and
+// injects the trace context into their headers.
+func Transport(base http.RoundTripper, peer string) http.RoundTripper {
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return peer + " " + r.Method + " " + r.URL.Path
+	}))
+}
+
+// Handler traces incoming HTTP requests, continuing the caller's trace
+// when the request carries a traceparent header. The path (which carries
+// sandbox IDs) is an attribute, not part of the span name.
+func Handler(h http.Handler, name string) http.Handler {
+	return otelhttp.NewHandler(h, name, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return name + " " + r.Method
+	}))
+}
+
+// ServerHandler traces incoming gRPC calls (trace context from metadata).
+func ServerHandler() stats.Handler {
+	return otelgrpc.NewServerHandler()
+}
+
+// ClientHandler traces outgoing gRPC calls and injects the trace context
+// into their metadata.
+func ClientHandler() stats.Handler {
+	return otelgrpc.NewClientHandler()
+}
+
+// defaultParent is the span client calls hang off when their context has
+// none; see SetDefaultParent.
+var defaultParent trace.SpanContext
+
+// SetDefaultParent makes ctx's span the parent of outgoing calls made with
+// a context that carries no span. The CLI sets its per-command root span
+// here so every RPC of one command lands in one trace. Call it before any
+// client is used.
+func SetDefaultParent(ctx context.Context) {
+	defaultParent = trace.SpanContextFromContext(ctx)
+}
+
+// WithDefaultParent returns ctx, parented on the default parent when ctx
+// has no span of its own.
+func WithDefaultParent(ctx context.Context) context.Context {
+	if !defaultParent.IsValid() || trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	return trace.ContextWithSpanContext(ctx, defaultParent)
+}
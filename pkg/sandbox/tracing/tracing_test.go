@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_FileExporterWritesSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "test", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("boom"))
+	End(parent, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans := map[string]spanRecord{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r spanRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		spans[r.Name] = r
+	}
+	p, c := spans["parent"], spans["child"]
+	if p.SpanID == "" || c.ParentSpanID != p.SpanID || c.TraceID != p.TraceID {
+		t.Fatalf("child not linked to parent: %+v %+v", p, c)
+	}
+	if c.Status != "Error" || c.Error != "boom" || p.Service != "test" {
+		t.Fatalf("status/service not recorded: %+v %+v", p, c)
+	}
+}
+
+func TestWithDefaultParent(t *testing.T) {
+	defer func(old trace.SpanContext) { defaultParent = old }(defaultParent)
+	root := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceFlags: trace.FlagsSampled})
+	SetDefaultParent(trace.ContextWithSpanContext(context.Background(), root))
+
+	if got := trace.SpanContextFromContext(WithDefaultParent(context.Background())); !got.Equal(root) {
+		t.Fatalf("span-less context not parented: %v", got)
+	}
+	own := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{2}, SpanID: trace.SpanID{2}})
+	ctx := trace.ContextWithSpanContext(context.Background(), own)
+	if got := trace.SpanContextFromContext(WithDefaultParent(ctx)); !got.Equal(own) {
+		t.Fatalf("existing span replaced: %v", got)
+	}
+}
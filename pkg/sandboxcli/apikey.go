@@ -10,6 +10,7 @@ import (
 
 	"github.com/urfave/cli"
 	"github.com/xiaods/k8e/pkg/sandbox/apikey"
+	"github.com/xiaods/k8e/pkg/sandbox/revocation"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -108,6 +109,7 @@ func ApiKeyCommand() cli.Command {
 			apiKeyCreateCommand(),
 			apiKeyListCommand(),
 			apiKeyDeleteCommand(),
+			apiKeyRevokeCertsCommand(),
 		},
 	}
 }
@@ -217,8 +219,49 @@ func apiKeyDeleteCommand() cli.Command {
 			if err := writeAPIKeys(store); err != nil {
 				return printErrorExit("write api-key: "+err.Error(), 2)
 			}
-			printJSON(map[string]any{"ok": true, "name": name})
+			// Lock out every device certificate issued under the key, on all
+			// gateway replicas, even if none is running to observe the delete.
+			if err := revokeKeyCerts(name, "api key deleted"); err != nil {
+				return printErrorExit("revoke certificates: "+err.Error(), 2)
+			}
+			printJSON(map[string]any{"ok": true, "name": name, "certs_revoked": true})
 			return nil
 		},
 	}
 }
+
+func apiKeyRevokeCertsCommand() cli.Command {
+	return cli.Command{
+		Name:      "revoke-certs",
+		Usage:     "Revoke every client certificate issued so far under an API key (the key itself stays valid for new logins)",
+		ArgsUsage: "<name>",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "reason", Usage: "Free-form reason recorded with the revocation"},
+		},
+		Action: func(ctx *cli.Context) error {
+			name := ctx.Args().First()
+			if name == "" {
+				return printErrorExit("usage: k8e sandbox-apikey revoke-certs <name>", 1)
+			}
+			if err := revokeKeyCerts(name, ctx.String("reason")); err != nil {
+				return printErrorExit("revoke certificates: "+err.Error(), 2)
+			}
+			printJSON(map[string]any{"ok": true, "name": name, "revoked_at": time.Now().UTC().Format(time.RFC3339)})
+			return nil
+		},
+	}
+}
+
+// revokeKeyCerts records a key cutoff in the cluster-wide revocation Secret:
+// every certificate with CN=name issued up to now is rejected by all gateways.
+func revokeKeyCerts(name, reason string) error {
+	k8s, err := newK8sClient()
+	if err != nil {
+		return fmt.Errorf("kubeconfig required for certificate revocation: %w", err)
+	}
+	_, err = revocation.Update(context.Background(), k8s, func(f *revocation.File) bool {
+		f.RevokeKey(name, time.Now(), reason)
+		return true
+	})
+	return err
+}
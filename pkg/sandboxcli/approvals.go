@@ -0,0 +1,107 @@
+package sandboxcli
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/urfave/cli"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// ApprovalsCommand lists and watches the gateway's durable approvals
+// (SandboxApproval CRDs) so approvers see what agents are waiting on.
+func ApprovalsCommand() cli.Command {
+	return cli.Command{
+		Name:  "approvals",
+		Usage: "List and watch human-in-the-loop approvals",
+		Subcommands: []cli.Command{
+			approvalsListCommand(),
+			approvalsWatchCommand(),
+		},
+	}
+}
+
+func approvalsListCommand() cli.Command {
+	return cli.Command{
+		Name:  "list",
+		Usage: "List approvals, newest first",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "session-id", Usage: "Only approvals for this session"},
+			cli.StringFlag{Name: "phase", Usage: "Filter phase: Pending, Approved, Denied or Expired"},
+		},
+		Action: func(ctx *cli.Context) error {
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+			resp, err := client.SandboxServiceClient.ListApprovals(context.Background(), &pb.ListApprovalsRequest{
+				SessionId: ctx.String("session-id"),
+				Phase:     ctx.String("phase"),
+			})
+			if err != nil {
+				return printErrorExit("list approvals: "+err.Error(), 2)
+			}
+			list := make([]any, 0, len(resp.Approvals))
+			for _, a := range resp.Approvals {
+				list = append(list, approvalViewJSON(a))
+			}
+			printJSON(map[string]any{"approvals": list})
+			return nil
+		},
+	}
+}
+
+func approvalsWatchCommand() cli.Command {
+	return cli.Command{
+		Name:  "watch",
+		Usage: "Stream approvals as they are created and decided (one JSON object per line)",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "session-id", Usage: "Only approvals for this session"},
+		},
+		Action: func(ctx *cli.Context) error {
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+
+			wctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			stream, err := client.SandboxServiceClient.WatchApprovals(wctx, &pb.WatchApprovalsRequest{
+				SessionId: ctx.String("session-id"),
+			})
+			if err != nil {
+				return printErrorExit("watch approvals: "+err.Error(), 2)
+			}
+			for {
+				a, err := stream.Recv()
+				if err == io.EOF || wctx.Err() != nil {
+					return nil
+				}
+				if err != nil {
+					return printErrorExit("watch approvals: "+err.Error(), 2)
+				}
+				printJSON(approvalViewJSON(a))
+			}
+		},
+	}
+}
+
+func approvalViewJSON(a *pb.Approval) map[string]any {
+	return map[string]any{
+		"approval_id": a.ApprovalId,
+		"session_id":  a.SessionId,
+		"tenant_id":   a.TenantId,
+		"action":      a.Action,
+		"requester":   a.Requester,
+		"phase":       a.Phase,
+		"decided_by":  a.DecidedBy,
+		"reason":      a.Reason,
+		"created_at":  a.CreatedAt,
+		"decided_at":  a.DecidedAt,
+		"expires_at":  a.ExpiresAt,
+	}
+}
@@ -0,0 +1,106 @@
+package sandboxcli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// AuditCommand queries the gateway's audit log: one record per gateway RPC
+// and E2B request on the node the CLI is connected to.
+func AuditCommand() cli.Command {
+	return cli.Command{
+		Name:  "audit",
+		Usage: "Query the gateway audit log (who called what, when, and the outcome)",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "since", Usage: "Only records after this time: a duration ago (1h, 30m) or RFC3339"},
+			cli.StringFlag{Name: "until", Usage: "Only records before this time: a duration ago or RFC3339"},
+			cli.StringFlag{Name: "source", Usage: "grpc or e2b"},
+			cli.StringFlag{Name: "caller", Usage: "API key name, local, or e2b key fingerprint (key:...)"},
+			cli.StringFlag{Name: "tenant", Usage: "Tenant ID"},
+			cli.StringFlag{Name: "session-id", Usage: "Session ID"},
+			cli.StringFlag{Name: "method", Usage: "Method substring, e.g. Exec or /sandboxes"},
+			cli.StringFlag{Name: "command", Usage: "Exact command to look for (hashed by the gateway; never stored in plaintext)"},
+			cli.StringFlag{Name: "path", Usage: "Exact file path to look for (hashed like --command)"},
+			cli.IntFlag{Name: "limit", Usage: "Newest N records (default 1000)"},
+		},
+		Action: func(ctx *cli.Context) error {
+			now := time.Now()
+			since, err := parseAuditTime(ctx.String("since"), now)
+			if err != nil {
+				return printErrorExit("--since: "+err.Error(), 1)
+			}
+			until, err := parseAuditTime(ctx.String("until"), now)
+			if err != nil {
+				return printErrorExit("--until: "+err.Error(), 1)
+			}
+			if ctx.String("command") != "" && ctx.String("path") != "" {
+				return printErrorExit("--command and --path are mutually exclusive", 1)
+			}
+			target := ctx.String("command")
+			if target == "" {
+				target = ctx.String("path")
+			}
+
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+			resp, err := client.SandboxServiceClient.QueryAudit(context.Background(), &pb.QueryAuditRequest{
+				Since:     since,
+				Until:     until,
+				Source:    ctx.String("source"),
+				Caller:    ctx.String("caller"),
+				TenantId:  ctx.String("tenant"),
+				SessionId: ctx.String("session-id"),
+				Method:    ctx.String("method"),
+				Target:    target,
+				Limit:     int32(ctx.Int("limit")),
+			})
+			if err != nil {
+				return printErrorExit("audit: "+err.Error(), 2)
+			}
+			list := make([]any, 0, len(resp.Records))
+			for _, r := range resp.Records {
+				list = append(list, auditViewJSON(r))
+			}
+			printJSON(map[string]any{"node": resp.Node, "records": list})
+			return nil
+		},
+	}
+}
+
+// parseAuditTime turns "" into 0, a duration into now minus it, and RFC3339
+// into its unix seconds.
+func parseAuditTime(v string, now time.Time) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d).Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("want a duration (1h) or RFC3339 time, got %q", v)
+	}
+	return t.Unix(), nil
+}
+
+func auditViewJSON(r *pb.AuditRecord) map[string]any {
+	return map[string]any{
+		"time":        time.UnixMilli(r.Time).UTC().Format(time.RFC3339Nano),
+		"source":      r.Source,
+		"caller":      r.Caller,
+		"fingerprint": r.Fingerprint,
+		"tenant_id":   r.TenantId,
+		"session_id":  r.SessionId,
+		"method":      r.Method,
+		"outcome":     r.Outcome,
+		"latency_ms":  r.LatencyMs,
+		"target":      r.Target,
+	}
+}
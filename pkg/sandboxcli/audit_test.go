@@ -0,0 +1,24 @@
+package sandboxcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	cases := map[string]int64{
+		"":                     0,
+		"1h":                   now.Add(-time.Hour).Unix(),
+		"2026-10-17T08:00:00Z": time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC).Unix(),
+	}
+	for in, want := range cases {
+		got, err := parseAuditTime(in, now)
+		if err != nil || got != want {
+			t.Errorf("parseAuditTime(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseAuditTime("yesterday", now); err == nil {
+		t.Error("expected an error for an unparseable time")
+	}
+}
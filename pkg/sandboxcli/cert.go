@@ -0,0 +1,146 @@
+package sandboxcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/urfave/cli"
+	"github.com/xiaods/k8e/pkg/sandbox/revocation"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// sha256Hex matches a normalized SHA-256 certificate fingerprint.
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// CertCommand manages mTLS client certificates issued by the gateway Login
+// RPC. Revocations are written to the cluster-wide sandbox-cert-revocations
+// Secret (kubeconfig required) and take effect on every gateway replica.
+func CertCommand() cli.Command {
+	return cli.Command{
+		Name:  "cert",
+		Usage: "Manage sandbox mTLS client certificates (revocation, CRL)",
+		Subcommands: []cli.Command{
+			certRevokeCommand(),
+			certRevocationsCommand(),
+			certCRLCommand(),
+		},
+	}
+}
+
+func certRevokeCommand() cli.Command {
+	return cli.Command{
+		Name:      "revoke",
+		Usage:     "Revoke one client certificate by SHA-256 fingerprint (hex, ':' separators allowed)",
+		ArgsUsage: "<fingerprint>",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "reason", Usage: "Free-form reason recorded with the revocation"},
+			cli.StringFlag{Name: "serial", Usage: "Certificate serial (decimal), listed in the CRL when given"},
+		},
+		Action: func(ctx *cli.Context) error {
+			fp := revocation.NormalizeFingerprint(ctx.Args().First())
+			if !sha256Hex.MatchString(fp) {
+				return printErrorExit("usage: cert revoke <sha256-fingerprint>", 1)
+			}
+			k8s, err := newK8sClient()
+			if err != nil {
+				return printErrorExit("kubeconfig required for certificate revocation: "+err.Error(), 1)
+			}
+			added := false
+			_, err = revocation.Update(context.Background(), k8s, func(f *revocation.File) bool {
+				added = f.RevokeCert(fp, revocation.Cert{Serial: ctx.String("serial"), Reason: ctx.String("reason")})
+				return added
+			})
+			if err != nil {
+				return printErrorExit("revoke certificate: "+err.Error(), 2)
+			}
+			printJSON(map[string]any{"ok": true, "fingerprint": fp, "already_revoked": !added})
+			return nil
+		},
+	}
+}
+
+func certRevocationsCommand() cli.Command {
+	return cli.Command{
+		Name:  "revocations",
+		Usage: "List revoked certificates and API key cutoffs",
+		Action: func(ctx *cli.Context) error {
+			k8s, err := newK8sClient()
+			if err != nil {
+				return printErrorExit("kubeconfig required to read revocations: "+err.Error(), 1)
+			}
+			f, err := revocation.Load(context.Background(), k8s)
+			if err != nil {
+				return printErrorExit("read revocations: "+err.Error(), 2)
+			}
+			certs := make([]map[string]any, 0, len(f.Certs))
+			for fp, c := range f.Certs {
+				item := map[string]any{
+					"fingerprint": fp,
+					"revoked_at":  c.RevokedAt.UTC().Format(time.RFC3339),
+				}
+				if c.KeyName != "" {
+					item["key_name"] = c.KeyName
+				}
+				if c.Serial != "" {
+					item["serial"] = c.Serial
+				}
+				if c.Reason != "" {
+					item["reason"] = c.Reason
+				}
+				certs = append(certs, item)
+			}
+			keys := make([]map[string]any, 0, len(f.Keys))
+			for name, k := range f.Keys {
+				item := map[string]any{
+					"name":       name,
+					"revoked_at": k.RevokedAt.UTC().Format(time.RFC3339),
+				}
+				if k.Reason != "" {
+					item["reason"] = k.Reason
+				}
+				keys = append(keys, item)
+			}
+			printJSON(map[string]any{"certs": certs, "keys": keys})
+			return nil
+		},
+	}
+}
+
+func certCRLCommand() cli.Command {
+	return cli.Command{
+		Name:  "crl",
+		Usage: "Fetch the gateway's signed CRL (PEM) for offline verification",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "out", Usage: "Write the PEM CRL to this file instead of the JSON output"},
+		},
+		Action: func(ctx *cli.Context) error {
+			c, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer c.Close()
+			resp, err := c.SandboxServiceClient.GetCRL(context.Background(), &pb.GetCRLRequest{})
+			if err != nil {
+				return printErrorExit("get crl: "+err.Error(), 2)
+			}
+			out := map[string]any{
+				"this_update":   time.Unix(resp.ThisUpdate, 0).UTC().Format(time.RFC3339),
+				"next_update":   time.Unix(resp.NextUpdate, 0).UTC().Format(time.RFC3339),
+				"revoked_count": resp.RevokedCount,
+			}
+			if path := ctx.String("out"); path != "" {
+				if err := os.WriteFile(path, []byte(resp.Crl), 0644); err != nil {
+					return printErrorExit(fmt.Sprintf("write %s: %v", path, err), 1)
+				}
+				out["path"] = path
+			} else {
+				out["crl"] = resp.Crl
+			}
+			printJSON(out)
+			return nil
+		},
+	}
+}
@@ -77,9 +77,9 @@ func ensureSession(client *client.Client, ctx *cli.Context) (string, bool, error
 		return sid, false, nil
 	}
 
-	var hosts []string
-	if raw := ctx.String("allowed-hosts"); raw != "" {
-		hosts = strings.Split(raw, ",")
+	hosts, rules, err := parseEgressList(ctx.String("allowed-hosts"))
+	if err != nil {
+		return "", false, err
 	}
 	// Bound the create RPC: without a deadline a dead gateway hangs the command
 	// and leaves a "creating" placeholder that wedges later runs (session.go
@@ -87,7 +87,7 @@ func ensureSession(client *client.Client, ctx *cli.Context) (string, bool, error
 	cctx, cancel := context.WithTimeout(context.Background(), sessionCreateTimeout)
 	defer cancel()
 	resp, err := client.SandboxServiceClient.CreateSession(cctx, &pb.CreateSessionRequest{
-		TenantId: ctx.String("tenant"), RuntimeClass: "gvisor", AllowedHosts: hosts,
+		TenantId: ctx.String("tenant"), RuntimeClass: "gvisor", AllowedHosts: hosts, EgressRules: rules,
 	})
 	if err != nil {
 		// Never leave a "creating" placeholder behind on failure: the next run
@@ -172,12 +172,14 @@ func RunCommand() cli.Command {
 			cli.StringFlag{Name: "session-id", EnvVar: "K8E_SANDBOX_SESSION_ID", Usage: "Explicit session ID"},
 			cli.StringFlag{Name: "tenant", EnvVar: "K8E_SANDBOX_TENANT", Usage: "Tenant for cross-process session reuse"},
 			cli.BoolFlag{Name: "background", Usage: "Submit asynchronously, return run_id immediately"},
-			cli.BoolFlag{Name: "raw", Usage: "Stream raw output (no JSON wrapper)"},
+			cli.BoolFlag{Name: "raw", Usage: "Stream raw output (no JSON wrapper); stderr and the exit code are kept"},
+			cli.BoolFlag{Name: "stdin", Usage: "Forward this process's stdin to the command (implies --raw; code must be an argument)"},
+			cli.BoolFlag{Name: "full-output", Usage: "When output is truncated, fetch the full stdout/stderr instead of the 1 MiB excerpt"},
 			cli.StringFlag{Name: "manifest", Usage: "Path to workspace manifest (only when auto-creating session)"},
 			cli.StringFlag{Name: "git-repo", Usage: "Git repo to clone (only when auto-creating session)"},
 			cli.StringFlag{Name: "git-ref", Value: "main", Usage: "Git ref for --git-repo"},
 			cli.StringFlag{Name: "git-path", Value: "repo", Usage: "Destination path for --git-repo"},
-			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated FQDN egress allowlist (only when auto-creating session)"},
+			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated egress allowlist, allow-hosts syntax (only when auto-creating session)"},
 		},
 		Action: runAction,
 	}
@@ -202,6 +204,10 @@ func runBackground(cli *client.Client, ctx *cli.Context, code, lang string) erro
 }
 
 func runExec(cli *client.Client, ctx *cli.Context, req *pb.ExecRequest, sid string, needsFinalize, raw bool) error {
+	var stdin io.Reader
+	if ctx.Bool("stdin") {
+		stdin = os.Stdin
+	}
 	retry := func() (int, error) {
 		clearState(ctx.String("tenant"))
 		s, f, e := ensureSession(cli, ctx)
@@ -210,14 +216,14 @@ func runExec(cli *client.Client, ctx *cli.Context, req *pb.ExecRequest, sid stri
 		}
 		req.SessionId = s
 		if raw {
-			return runStream(cli, req, s, f, ctx.String("tenant"))
+			return runStream(cli, req, s, f, ctx.String("tenant"), stdin)
 		}
-		err := runJSON(cli, req, s, f, ctx.String("tenant"))
+		err := runJSON(cli, req, s, f, ctx.String("tenant"), ctx.Bool("full-output"))
 		return 0, err
 	}
 
 	if raw {
-		exitCode, err := runStream(cli, req, sid, needsFinalize, ctx.String("tenant"))
+		exitCode, err := runStream(cli, req, sid, needsFinalize, ctx.String("tenant"), stdin)
 		if isSessionExpired(err) {
 			exitCode, err = retry()
 		}
@@ -226,7 +232,7 @@ func runExec(cli *client.Client, ctx *cli.Context, req *pb.ExecRequest, sid stri
 		}
 		return &ExitError{ExitCode: exitCode}
 	}
-	if err := runJSON(cli, req, sid, needsFinalize, ctx.String("tenant")); err != nil {
+	if err := runJSON(cli, req, sid, needsFinalize, ctx.String("tenant"), ctx.Bool("full-output")); err != nil {
 		if isSessionExpired(err) {
 			_, retryErr := retry()
 			return retryErr
@@ -237,12 +243,15 @@ func runExec(cli *client.Client, ctx *cli.Context, req *pb.ExecRequest, sid stri
 }
 
 func runAction(ctx *cli.Context) error {
+	if ctx.Bool("stdin") && ctx.Args().First() == "" {
+		return printErrorExit("--stdin needs the code as an argument", 1)
+	}
 	code, err := readCode(ctx)
 	if err != nil {
 		return printErrorExit(err.Error(), 1)
 	}
 	lang := ctx.String("lang")
-	raw := ctx.Bool("raw")
+	raw := ctx.Bool("raw") || ctx.Bool("stdin")
 
 	cli, exitErr := newClientFromCtx(ctx)
 	if exitErr != nil {
@@ -273,9 +282,117 @@ func runAction(ctx *cli.Context) error {
 	return runExec(cli, ctx, req, sid, needsFinalize, raw)
 }
 
-func runStream(client *client.Client, req *pb.ExecRequest, sid string, needsFinalize bool, tenant string) (exitCode int, err error) {
+// runStream streams a command's output live: stdout and stderr go to the
+// matching local streams and the sandbox exit status becomes the CLI exit
+// code (128+signal when killed, 124 on timeout, like timeout(1)). When stdin
+// is set it is forwarded to the command through ExecInteractive. Gateways
+// without ExecStreamV2 fall back to the merged legacy ExecStream.
+func runStream(client *client.Client, req *pb.ExecRequest, sid string, needsFinalize bool, tenant string, stdin io.Reader) (exitCode int, err error) {
 	rctx, cancel := rpcCtx(req.Timeout)
 	defer cancel()
+	if needsFinalize {
+		defer func() { _ = finalizeState(tenant, sid) }()
+	}
+
+	var recv func() (*pb.ExecFrame, error)
+	if stdin != nil {
+		stream, openErr := client.SandboxServiceClient.ExecInteractive(rctx)
+		if openErr == nil {
+			openErr = stream.Send(&pb.ExecInput{Input: &pb.ExecInput_Start{Start: req}})
+		}
+		if openErr != nil {
+			logrus.Errorf("exec stream: %v", openErr)
+			return 1, openErr
+		}
+		go forwardStdin(stream, stdin)
+		recv = stream.Recv
+	} else {
+		stream, openErr := client.SandboxServiceClient.ExecStreamV2(rctx, req)
+		if openErr != nil {
+			logrus.Errorf("exec stream: %v", openErr)
+			return 1, openErr
+		}
+		recv = stream.Recv
+	}
+
+	exitCode, started, err := drainFrames(recv, os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+		return exitCode, nil
+	case started:
+		fmt.Fprintf(os.Stderr, "stream error: %v\n", err)
+		return 1, nil
+	case status.Code(err) == codes.Unimplemented && stdin == nil:
+		return runStreamLegacy(rctx, client, req)
+	case status.Code(err) == codes.Unimplemented:
+		return 1, errors.New("this gateway does not support --stdin")
+	case isSessionExpired(err):
+		return 1, fmt.Errorf("%w: session %s", ErrSessionGone, req.SessionId)
+	}
+	logrus.Errorf("exec stream: %v", err)
+	return 1, err
+}
+
+// drainFrames writes typed frames to stdout/stderr until the exit frame.
+// started reports whether any frame arrived, i.e. whether the command ran.
+func drainFrames(recv func() (*pb.ExecFrame, error), stdout, stderr io.Writer) (exitCode int, started bool, err error) {
+	for {
+		f, err := recv()
+		if err == io.EOF {
+			return 1, started, errors.New("stream ended without an exit status")
+		}
+		if err != nil {
+			return 1, started, err
+		}
+		started = true
+		switch v := f.Frame.(type) {
+		case *pb.ExecFrame_Stdout:
+			stdout.Write(v.Stdout) //nolint:errcheck
+		case *pb.ExecFrame_Stderr:
+			stderr.Write(v.Stderr) //nolint:errcheck
+		case *pb.ExecFrame_Exit:
+			return exitStatus(v.Exit, stderr), true, nil
+		}
+	}
+}
+
+// exitStatus maps a sandbox exit to a shell-style exit code.
+func exitStatus(e *pb.ExecExit, stderr io.Writer) int {
+	switch {
+	case e.TimedOut:
+		fmt.Fprintf(stderr, "sandbox: command timed out after %s\n", time.Duration(e.DurationMs)*time.Millisecond)
+		return 124
+	case e.Code >= 0:
+		return int(e.Code)
+	case e.Signal > 0:
+		return 128 + int(e.Signal)
+	}
+	return 1
+}
+
+// forwardStdin copies r to an ExecInteractive stream and closes the
+// command's stdin at EOF. Send errors surface on the receive side.
+func forwardStdin(stream pb.SandboxService_ExecInteractiveClient, r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if stream.Send(&pb.ExecInput{Input: &pb.ExecInput_Stdin{Stdin: append([]byte(nil), buf[:n]...)}}) != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if stream.Send(&pb.ExecInput{Input: &pb.ExecInput_CloseStdin{CloseStdin: true}}) == nil {
+		_ = stream.CloseSend()
+	}
+}
+
+// runStreamLegacy streams merged output from a gateway that predates
+// ExecStreamV2; the exit status is not available there.
+func runStreamLegacy(rctx context.Context, client *client.Client, req *pb.ExecRequest) (int, error) {
 	stream, err := client.SandboxServiceClient.ExecStream(rctx, req)
 	if err != nil {
 		if isSessionExpired(err) {
@@ -284,29 +401,17 @@ func runStream(client *client.Client, req *pb.ExecRequest, sid string, needsFina
 		logrus.Errorf("exec stream: %v", err)
 		return 1, err
 	}
-
-	var streamErr error
 	for {
 		chunk, recvErr := stream.Recv()
+		if recvErr == io.EOF {
+			return 0, nil
+		}
 		if recvErr != nil {
-			if recvErr.Error() == "EOF" || strings.Contains(recvErr.Error(), "EOF") {
-				break
-			}
-			streamErr = recvErr
-			break
+			fmt.Fprintf(os.Stderr, "stream error: %v\n", recvErr)
+			return 1, nil
 		}
 		os.Stdout.WriteString(chunk.Chunk)
 	}
-
-	if needsFinalize {
-		_ = finalizeState(tenant, sid)
-	}
-
-	if streamErr != nil {
-		fmt.Fprintf(os.Stderr, "stream error: %v\n", streamErr)
-		return 1, nil
-	}
-	return 0, nil
 }
 
 // rpcCtx returns a context with a deadline for one sandbox RPC: the sandbox
@@ -342,7 +447,7 @@ func isSessionExpired(err error) bool {
 	return false
 }
 
-func runJSON(client *client.Client, req *pb.ExecRequest, sid string, needsFinalize bool, tenant string) error {
+func runJSON(client *client.Client, req *pb.ExecRequest, sid string, needsFinalize bool, tenant string, fullOutput bool) error {
 	rctx, cancel := rpcCtx(req.Timeout)
 	defer cancel()
 	resp, err := client.SandboxServiceClient.Exec(rctx, req)
@@ -355,7 +460,7 @@ func runJSON(client *client.Client, req *pb.ExecRequest, sid string, needsFinali
 	if needsFinalize {
 		_ = finalizeState(tenant, sid)
 	}
-	printJSON(map[string]any{
+	out := map[string]any{
 		"stdout":      resp.Stdout,
 		"stderr":      resp.Stderr,
 		"exit_code":   resp.ExitCode,
@@ -364,10 +469,60 @@ func runJSON(client *client.Client, req *pb.ExecRequest, sid string, needsFinali
 		"duration_ms": resp.DurationMs,
 		"truncated":   resp.Truncated,
 		"language":    resp.Language,
-	})
+	}
+	if ref := resp.OutputRef; ref != nil {
+		if fullOutput {
+			stdout, err := readRunOutput(client, sid, ref.RunId, "stdout")
+			if err != nil {
+				return printErrorExit("full output: "+err.Error(), 2)
+			}
+			stderr, err := readRunOutput(client, sid, ref.RunId, "stderr")
+			if err != nil {
+				return printErrorExit("full output: "+err.Error(), 2)
+			}
+			out["stdout"], out["stderr"], out["truncated"] = stdout, stderr, false
+		} else {
+			out["output_ref"] = outputRefJSON(ref)
+		}
+	}
+	printJSON(out)
 	return nil
 }
 
+// readRunOutput pages one stream of a run's kept output from the start.
+// The inline excerpt is not reused so the result is byte-exact.
+func readRunOutput(client *client.Client, sid, runID, stream string) (string, error) {
+	var buf strings.Builder
+	var offset int64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		resp, err := client.SandboxServiceClient.GetRunOutput(ctx, &pb.GetRunOutputRequest{
+			SessionId: sid, RunId: runID, Stream: stream, Offset: offset,
+		})
+		cancel()
+		if err != nil {
+			return "", err
+		}
+		buf.Write(resp.Data)
+		if resp.Eof || resp.NextOffset <= offset {
+			return buf.String(), nil
+		}
+		offset = resp.NextOffset
+	}
+}
+
+// outputRefJSON is the JSON shape of an OutputRef: where to continue with
+// GetRunOutput and how large each stream really is.
+func outputRefJSON(ref *pb.OutputRef) map[string]any {
+	return map[string]any{
+		"run_id":        ref.RunId,
+		"stdout_offset": ref.StdoutOffset,
+		"stderr_offset": ref.StderrOffset,
+		"stdout_bytes":  ref.StdoutBytes,
+		"stderr_bytes":  ref.StderrBytes,
+	}
+}
+
 // ── StatusCommand ───────────────────────────────────────────────────────────
 
 func StatusCommand() cli.Command {
@@ -419,11 +574,12 @@ func CreateCommand() cli.Command {
 		Usage: "Create a new sandbox session",
 		Flags: []cli.Flag{
 			cli.StringFlag{Name: "runtime", Value: "gvisor", Usage: "Runtime class: gvisor, kata, firecracker"},
+			cli.StringFlag{Name: "template", Usage: "SandboxTemplate name (image, runtime, limits, allowed hosts)"},
 			cli.StringFlag{Name: "tenant", EnvVar: "K8E_SANDBOX_TENANT", Usage: "Tenant identifier"},
-			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated FQDN egress allowlist"},
+			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated egress allowlist, allow-hosts syntax"},
 			cli.StringFlag{Name: "session-id", Usage: "Custom session ID"},
 			cli.StringSliceFlag{Name: "env", Usage: "Non-sensitive env KEY=VAL (repeatable); applied at exec time"},
-			cli.StringSliceFlag{Name: "secret", Usage: "Secret ref ENV_VAR=secretName:key (repeatable); resolved at exec time"},
+			cli.StringSliceFlag{Name: "secret", Usage: "Secret ref ENV_VAR=secretName:key[@host,...[#Header]] (repeatable); resolved at exec time, or with @hosts injected by the egress proxy into the Authorization header (and Header) of requests to those hosts only"},
 			cli.StringFlag{Name: "manifest", Usage: "Path to workspace manifest YAML file"},
 			cli.StringFlag{Name: "git-repo", Usage: "Git repository URL to clone (shortcut)"},
 			cli.StringFlag{Name: "git-ref", Value: "main", Usage: "Git ref for --git-repo"},
@@ -436,9 +592,9 @@ func CreateCommand() cli.Command {
 			}
 			defer client.Close()
 
-			var hosts []string
-			if raw := ctx.String("allowed-hosts"); raw != "" {
-				hosts = strings.Split(raw, ",")
+			hosts, rules, err := parseEgressList(ctx.String("allowed-hosts"))
+			if err != nil {
+				return printErrorExit(err.Error(), 1)
 			}
 
 			env, envErr := parseEnvFlags(ctx.StringSlice("env"))
@@ -450,13 +606,22 @@ func CreateCommand() cli.Command {
 				return printErrorExit(secErr.Error(), 1)
 			}
 
+			// With --template the template's runtime class applies unless
+			// --runtime is given explicitly.
+			runtimeClass := ctx.String("runtime")
+			if ctx.String("template") != "" && !ctx.IsSet("runtime") {
+				runtimeClass = ""
+			}
+
 			resp, err := client.SandboxServiceClient.CreateSession(context.Background(), &pb.CreateSessionRequest{
 				SessionId:    ctx.String("session-id"),
 				TenantId:     ctx.String("tenant"),
-				RuntimeClass: ctx.String("runtime"),
+				RuntimeClass: runtimeClass,
 				AllowedHosts: hosts,
 				Env:          env,
 				SecretRefs:   secretRefs,
+				Template:     ctx.String("template"),
+				EgressRules:  rules,
 			})
 			if err != nil {
 				return printErrorExit("create session: "+err.Error(), 2)
@@ -509,7 +674,10 @@ func parseEnvFlags(items []string) (map[string]string, error) {
 	return out, nil
 }
 
-// parseSecretFlags converts --secret ENV_VAR=secretName:key into SecretRef messages.
+// parseSecretFlags converts --secret ENV_VAR=secretName:key[@host,...[#Header]]
+// into SecretRef messages. Hosts bind the secret to the egress proxy: the
+// sandbox only sees a placeholder, swapped in the Authorization header and
+// in Header, if given.
 func parseSecretFlags(items []string) ([]*pb.SecretRef, error) {
 	if len(items) == 0 {
 		return nil, nil
@@ -520,11 +688,24 @@ func parseSecretFlags(items []string) ([]*pb.SecretRef, error) {
 		if !ok || envVar == "" {
 			return nil, fmt.Errorf("invalid --secret %q, expected ENV_VAR=secretName:key", item)
 		}
+		rest, hostList, bound := strings.Cut(rest, "@")
 		secretName, key, ok := strings.Cut(rest, ":")
 		if !ok || secretName == "" || key == "" {
 			return nil, fmt.Errorf("invalid --secret %q, expected ENV_VAR=secretName:key", item)
 		}
-		out = append(out, &pb.SecretRef{EnvVar: envVar, SecretName: secretName, Key: key})
+		ref := &pb.SecretRef{EnvVar: envVar, SecretName: secretName, Key: key}
+		if bound {
+			hostList, ref.Header, _ = strings.Cut(hostList, "#")
+			for _, h := range strings.Split(hostList, ",") {
+				if h = strings.TrimSpace(h); h != "" {
+					ref.Hosts = append(ref.Hosts, h)
+				}
+			}
+			if len(ref.Hosts) == 0 {
+				return nil, fmt.Errorf("invalid --secret %q, expected hosts after @", item)
+			}
+		}
+		out = append(out, ref)
 	}
 	return out, nil
 }
@@ -586,10 +767,11 @@ func SessionsCommand() cli.Command {
 }
 
 func sessionViewJSON(s *pb.GetSessionResponse) map[string]any {
-	return map[string]any{
+	view := map[string]any{
 		"session_id":      s.SessionId,
 		"phase":           s.Phase,
 		"runtime_class":   s.RuntimeClass,
+		"template":        s.Template,
 		"pod_ip":          s.PodIp,
 		"tenant_id":       s.TenantId,
 		"expires_at":      s.ExpiresAt,
@@ -597,6 +779,11 @@ func sessionViewJSON(s *pb.GetSessionResponse) map[string]any {
 		"secret_env_vars": s.SecretEnvVars,
 		"background_runs": s.BackgroundRuns,
 	}
+	if s.ForkedFrom != "" {
+		view["forked_from"] = s.ForkedFrom
+		view["fork_index"] = s.ForkIndex
+	}
+	return view
 }
 
 // resolveManifest builds a Manifest from --manifest, --git-repo flags, or returns nil.
@@ -804,6 +991,48 @@ func SubagentCommand() cli.Command {
 	}
 }
 
+// ── ForkCommand ─────────────────────────────────────────────────────────────
+
+func ForkCommand() cli.Command {
+	return cli.Command{
+		Name:      "fork",
+		Usage:     "Clone a live session into N independent children (own pod and workspace copy)",
+		ArgsUsage: "<session-id>",
+		Flags: []cli.Flag{
+			cli.IntFlag{Name: "count", Value: 1, Usage: "Number of children (max 16)"},
+			cli.IntFlag{Name: "timeout", Usage: "Snapshot/restore timeout in seconds (0 = server default)"},
+		},
+		Action: func(ctx *cli.Context) error {
+			sid := ctx.Args().First()
+			if sid == "" {
+				return printErrorExit("usage: k8e-sandbox-cli fork <session-id> --count N", 1)
+			}
+
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+
+			resp, err := client.SandboxServiceClient.ForkSession(context.Background(), &pb.ForkSessionRequest{
+				SessionId: sid,
+				Count:     int32(ctx.Int("count")),
+				Timeout:   int32(ctx.Int("timeout")),
+			})
+			if err != nil {
+				return printErrorExit("fork: "+err.Error(), 1)
+			}
+			printJSON(map[string]any{
+				"parent_session_id": sid,
+				"session_ids":       resp.SessionIds,
+				"size_bytes":        resp.SizeBytes,
+				"latency_ms":        resp.LatencyMs,
+			})
+			return nil
+		},
+	}
+}
+
 // ── ConfirmCommand ──────────────────────────────────────────────────────────
 
 func ConfirmCommand() cli.Command {
@@ -1187,7 +1416,7 @@ func PollCommand() cli.Command {
 			if err != nil {
 				return printErrorExit("poll: "+err.Error(), 1)
 			}
-			printJSON(map[string]any{
+			out := map[string]any{
 				"run_id":      resp.RunId,
 				"status":      resp.Status,
 				"stdout":      resp.Stdout,
@@ -1195,7 +1424,11 @@ func PollCommand() cli.Command {
 				"exit_code":   resp.ExitCode,
 				"duration_ms": resp.DurationMs,
 				"truncated":   resp.Truncated,
-			})
+			}
+			if resp.OutputRef != nil {
+				out["output_ref"] = outputRefJSON(resp.OutputRef)
+			}
+			printJSON(out)
 			return nil
 		},
 	}
@@ -1376,9 +1609,14 @@ func ExposeCommand() cli.Command {
 	return cli.Command{
 		Name:  "expose",
 		Usage: "Expose a sandbox-internal service through the k8e API Gateway (returns a gateway URL; KIP-24)",
+		Description: "--access token (default) returns a URL carrying a one-time-issued token;\n" +
+			"   signed returns a URL valid for --ttl seconds (re-run expose for a new one);\n" +
+			"   public needs no credential. Browsers trade the credential for a cookie.",
 		Flags: []cli.Flag{
 			cli.IntFlag{Name: "port", Usage: "In-pod service port (or positional arg)"},
 			cli.StringFlag{Name: "host", Value: "127.0.0.1", Usage: "In-pod listen address"},
+			cli.StringFlag{Name: "access", Usage: "Who may reach the URL: token, signed or public (default token; re-expose keeps the mode)"},
+			cli.IntFlag{Name: "ttl", Usage: "Signed URL lifetime in seconds (0 = 3600, max 604800)"},
 			cli.StringFlag{Name: "session-id", Usage: sessionIDFlagUsage},
 		},
 		Action: func(ctx *cli.Context) error {
@@ -1386,6 +1624,10 @@ func ExposeCommand() cli.Command {
 			if exitErr != nil {
 				return exitErr
 			}
+			access, err := parseExposeAccess(ctx.String("access"))
+			if err != nil {
+				return printErrorExit("expose: "+err.Error(), 1)
+			}
 			client, sid, exitErr := dialSession(ctx)
 			if exitErr != nil {
 				return exitErr
@@ -1393,16 +1635,41 @@ func ExposeCommand() cli.Command {
 			defer client.Close()
 			resp, err := client.SandboxServiceClient.ExposeService(context.Background(), &pb.ExposeServiceRequest{
 				SessionId: sid, Port: int32(port), Host: ctx.String("host"),
+				Access: access, TtlSeconds: int64(ctx.Int("ttl")),
 			})
 			if err != nil {
 				return printErrorExit("expose: "+err.Error(), 2)
 			}
-			printJSON(map[string]any{"url": resp.Url, "port": port, "session_id": sid})
+			out := map[string]any{"url": resp.Url, "port": port, "session_id": sid, "access": exposeAccessJSON(resp.Access)}
+			if resp.Token != "" {
+				out["token"] = resp.Token
+			}
+			if resp.ExpiresAt != 0 {
+				out["expires_at"] = resp.ExpiresAt
+			}
+			printJSON(out)
 			return nil
 		},
 	}
 }
 
+// parseExposeAccess maps "token" / "signed" / "public" to ExposeAccess; an
+// empty value leaves the choice to the gateway.
+func parseExposeAccess(name string) (pb.ExposeAccess, error) {
+	if name == "" {
+		return pb.ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED, nil
+	}
+	v, ok := pb.ExposeAccess_value["EXPOSE_ACCESS_"+strings.ToUpper(name)]
+	if !ok || v == 0 {
+		return 0, fmt.Errorf("unknown access mode %q (want token, signed or public)", name)
+	}
+	return pb.ExposeAccess(v), nil
+}
+
+func exposeAccessJSON(a pb.ExposeAccess) string {
+	return strings.ToLower(strings.TrimPrefix(a.String(), "EXPOSE_ACCESS_"))
+}
+
 // ── UnexposeCommand ─────────────────────────────────────────────────────────
 
 func UnexposeCommand() cli.Command {
@@ -1463,6 +1730,7 @@ func ExposedCommand() cli.Command {
 					"url":        s.Url,
 					"host":       s.Host,
 					"started_at": s.StartedAt,
+					"access":     exposeAccessJSON(s.Access),
 				})
 			}
 			printJSON(map[string]any{"session_id": sid, "services": services})
@@ -1474,16 +1742,22 @@ func ExposedCommand() cli.Command {
 // ── AllowHostsCommand ───────────────────────────────────────────────────────
 // KIP-24: freely configure the session's egress allowlist (live CNP re-apply).
 // Full replacement via positional args / --hosts; --add / --remove helpers
-// read the current list from GetSession first.
+// read the current list from GetSession first. Entries use the egress.go
+// syntax: wildcard hosts, CIDRs, ports, protocols and HTTP method/path.
 
 func AllowHostsCommand() cli.Command {
 	return cli.Command{
 		Name:  "allow-hosts",
 		Usage: "Set the session egress allowlist (live). Positional/comma-separated --hosts replaces the list; --add / --remove adjust it",
+		Description: "Entries: api.github.com, *.pypi.org, github.com:22, dns.corp:53/udp,\n" +
+			"   10.0.0.0/8:5432, 192.168.1.10, [fd00::]/8, 'GET mirror.corp:80/simple/*'.\n" +
+			"   A bare host allows TCP 443; ports, protocols, wildcards, CIDRs and HTTP\n" +
+			"   rules are enforced by Cilium. Host rules are refused unless FQDN egress or\n" +
+			"   the egress proxy is enabled; HTTP rules need a plain-HTTP port, not 443.",
 		Flags: []cli.Flag{
 			cli.StringFlag{Name: "hosts", Usage: "Comma-separated full replacement list"},
-			cli.StringFlag{Name: "add", Usage: "Comma-separated hosts to add"},
-			cli.StringFlag{Name: "remove", Usage: "Comma-separated hosts to remove"},
+			cli.StringFlag{Name: "add", Usage: "Comma-separated entries to add"},
+			cli.StringFlag{Name: "remove", Usage: "Comma-separated entries to remove (as printed by allow-hosts)"},
 			cli.StringFlag{Name: "session-id", Usage: sessionIDFlagUsage},
 			cli.BoolFlag{Name: "clear", Usage: "Clear the allowlist (fall back to matrix defaults)"},
 		},
@@ -1493,17 +1767,21 @@ func AllowHostsCommand() cli.Command {
 				return exitErr
 			}
 			defer client.Close()
-			hosts, err := resolveAllowedHosts(ctx, client, sid)
+			entries, err := resolveAllowedHosts(ctx, client, sid)
 			if err != nil {
 				return printErrorExit(err.Error(), 2)
 			}
+			hosts, rules, err := parseEgressEntries(entries)
+			if err != nil {
+				return printErrorExit(err.Error(), 1)
+			}
 			resp, err := client.SandboxServiceClient.UpdateAllowedHosts(context.Background(), &pb.UpdateAllowedHostsRequest{
-				SessionId: sid, Hosts: hosts,
+				SessionId: sid, Hosts: hosts, Rules: rules,
 			})
 			if err != nil {
 				return printErrorExit("allow-hosts: "+err.Error(), 2)
 			}
-			printJSON(map[string]any{"session_id": sid, "allowed_hosts": resp.Hosts})
+			printJSON(map[string]any{"session_id": sid, "allowed_hosts": resp.Hosts, "egress_rules": egressEntries(nil, resp.Rules)})
 			return nil
 		},
 	}
@@ -1541,13 +1819,14 @@ func resolveAllowedHosts(ctx *cli.Context, client *client.Client, sid string) ([
 }
 
 // adjustAllowedHosts reads the current allowlist and applies --remove then
-// --add (full replacement semantics on the live list).
+// --add (full replacement semantics on the live list). Structured rules are
+// compared in their printed entry form.
 func adjustAllowedHosts(ctx *cli.Context, client *client.Client, sid string) ([]string, error) {
 	cur, err := client.SandboxServiceClient.GetSession(context.Background(), &pb.GetSessionRequest{SessionId: sid})
 	if err != nil {
 		return nil, fmt.Errorf("allow-hosts: read current: %w", err)
 	}
-	hosts := append([]string(nil), cur.AllowedHosts...)
+	hosts := egressEntries(cur.AllowedHosts, cur.EgressRules)
 	removed := map[string]bool{}
 	for _, h := range splitHosts(ctx.String("remove")) {
 		removed[h] = true
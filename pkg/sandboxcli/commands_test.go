@@ -1,10 +1,13 @@
 package sandboxcli
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/urfave/cli"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -43,6 +46,38 @@ func TestParseSecretFlags(t *testing.T) {
 	if _, err := parseSecretFlags([]string{"X=noseparator"}); err == nil {
 		t.Fatal("expected error for missing colon")
 	}
+	bound, err := parseSecretFlags([]string{"GITHUB_TOKEN=gh:token@api.github.com, github.com"})
+	if err != nil || len(bound) != 1 || bound[0].Key != "token" || len(bound[0].Hosts) != 2 || bound[0].Hosts[1] != "github.com" {
+		t.Fatalf("host-bound: %+v %v", bound, err)
+	}
+	keyed, err := parseSecretFlags([]string{"API_KEY=llm:key@api.anthropic.com#X-Api-Key"})
+	if err != nil || len(keyed[0].Hosts) != 1 || keyed[0].Header != "X-Api-Key" {
+		t.Fatalf("header-bound: %+v %v", keyed, err)
+	}
+	if _, err := parseSecretFlags([]string{"X=s:k@"}); err == nil {
+		t.Fatal("expected error for empty host list")
+	}
+}
+
+func TestParseExposeAccess(t *testing.T) {
+	for in, want := range map[string]pb.ExposeAccess{
+		"":       pb.ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED,
+		"token":  pb.ExposeAccess_EXPOSE_ACCESS_TOKEN,
+		"Signed": pb.ExposeAccess_EXPOSE_ACCESS_SIGNED,
+		"public": pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC,
+	} {
+		if got, err := parseExposeAccess(in); err != nil || got != want {
+			t.Fatalf("%q: %v %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"unspecified", "private"} {
+		if _, err := parseExposeAccess(bad); err == nil {
+			t.Fatalf("%q: expected error", bad)
+		}
+	}
+	if got := exposeAccessJSON(pb.ExposeAccess_EXPOSE_ACCESS_SIGNED); got != "signed" {
+		t.Fatalf("json name %q", got)
+	}
 }
 
 func TestIsSessionExpired_grpcNotFound(t *testing.T) {
@@ -117,3 +152,44 @@ func TestCatalogCommand_ListsSurface(t *testing.T) {
 	}
 	_ = cmd.Action
 }
+
+func TestDrainFrames_SplitsStreamsAndKeepsExitCode(t *testing.T) {
+	frames := []*pb.ExecFrame{
+		{Frame: &pb.ExecFrame_Stdout{Stdout: []byte("out\n")}},
+		{Frame: &pb.ExecFrame_Stderr{Stderr: []byte("err\n")}},
+		{Frame: &pb.ExecFrame_Exit{Exit: &pb.ExecExit{Code: 3}}},
+	}
+	recv := func() (*pb.ExecFrame, error) {
+		if len(frames) == 0 {
+			return nil, io.EOF
+		}
+		f := frames[0]
+		frames = frames[1:]
+		return f, nil
+	}
+	var stdout, stderr bytes.Buffer
+	code, started, err := drainFrames(recv, &stdout, &stderr)
+	if err != nil || !started || code != 3 {
+		t.Fatalf("drainFrames = %d, %v, %v", code, started, err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Fatalf("stdout %q stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	cases := []struct {
+		exit *pb.ExecExit
+		want int
+	}{
+		{&pb.ExecExit{Code: 0}, 0},
+		{&pb.ExecExit{Code: 2}, 2},
+		{&pb.ExecExit{Code: -1, Signal: 15}, 143},
+		{&pb.ExecExit{Code: -1, Signal: 9, TimedOut: true, DurationMs: 30000}, 124},
+	}
+	for _, c := range cases {
+		if got := exitStatus(c.exit, io.Discard); got != c.want {
+			t.Errorf("exitStatus(%+v) = %d, want %d", c.exit, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package sandboxcli
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// Egress allowlist entries (allow-hosts, --allowed-hosts):
+//
+//	api.github.com                 exact host, TCP 443
+//	*.pypi.org                     wildcard host
+//	github.com:22                  other port
+//	dns.corp:53/udp                protocol (tcp, udp, any)
+//	10.0.0.0/8:5432, 192.168.1.10  CIDR or single IP; [fd00::]/8:443 for IPv6
+//	GET mirror.corp:80/simple/*    HTTP method and/or path (plain-HTTP ports)
+//
+// Plain hosts on 443 stay allowed_hosts; everything else becomes a
+// structured EgressRule.
+
+var egressMethodRe = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// parseEgressList splits a comma-separated allowlist into plain hosts and
+// structured rules. Entries for the same target, port and protocol that
+// differ only in HTTP method/path are merged into one rule.
+func parseEgressList(raw string) ([]string, []*pb.EgressRule, error) {
+	var entries []string
+	for _, e := range strings.Split(raw, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			entries = append(entries, e)
+		}
+	}
+	return parseEgressEntries(entries)
+}
+
+func parseEgressEntries(entries []string) ([]string, []*pb.EgressRule, error) {
+	var hosts []string
+	var rules []*pb.EgressRule
+	merged := map[string]*pb.EgressRule{}
+	for _, e := range entries {
+		r, err := parseEgressEntry(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isPlainHost(r) {
+			hosts = append(hosts, r.Host)
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%v|%s|%t", r.Host, r.Cidr, r.Ports, r.Protocol, len(r.Http) > 0)
+		if prev, ok := merged[key]; ok && len(r.Http) > 0 {
+			prev.Http = append(prev.Http, r.Http...)
+			continue
+		}
+		merged[key] = r
+		rules = append(rules, r)
+	}
+	return hosts, rules, nil
+}
+
+// isPlainHost reports whether r is expressible as an allowed_hosts entry.
+func isPlainHost(r *pb.EgressRule) bool {
+	return r.Host != "" && !strings.Contains(r.Host, "*") && len(r.Http) == 0 &&
+		len(r.Ports) == 1 && r.Ports[0] == 443 && r.Protocol == "TCP"
+}
+
+// parseEgressEntry parses one "[METHOD ]target[:port][/proto][/path]" entry.
+func parseEgressEntry(entry string) (*pb.EgressRule, error) {
+	rest := strings.TrimSpace(entry)
+	method := ""
+	if i := strings.IndexByte(rest, ' '); i > 0 {
+		method, rest = strings.ToUpper(rest[:i]), strings.TrimSpace(rest[i+1:])
+		if !egressMethodRe.MatchString(method) {
+			return nil, fmt.Errorf("egress %q: invalid HTTP method %q", entry, method)
+		}
+	}
+	r := &pb.EgressRule{Protocol: "TCP", Ports: []int32{443}}
+
+	// Target: [ipv6]/n, ip/n, ip or host.
+	var target string
+	if strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("egress %q: unterminated [", entry)
+		}
+		target, rest = rest[1:end], rest[end+1:]
+	} else {
+		end := strings.IndexAny(rest, ":/")
+		if end < 0 {
+			end = len(rest)
+		}
+		target, rest = rest[:end], rest[end:]
+	}
+	if target == "" {
+		return nil, fmt.Errorf("egress %q: missing host or CIDR", entry)
+	}
+	if net.ParseIP(target) != nil {
+		r.Cidr = target
+		if strings.HasPrefix(rest, "/") {
+			end := strings.IndexAny(rest[1:], ":/") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if bits := rest[1:end]; bits != "" && strings.Trim(bits, "0123456789") == "" {
+				r.Cidr, rest = target+"/"+bits, rest[end:]
+			}
+		}
+	} else {
+		r.Host = strings.ToLower(target)
+	}
+
+	if strings.HasPrefix(rest, ":") {
+		end := strings.IndexByte(rest, '/')
+		if end < 0 {
+			end = len(rest)
+		}
+		port, err := strconv.Atoi(rest[1:end])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("egress %q: invalid port %q", entry, rest[1:end])
+		}
+		r.Ports, rest = []int32{int32(port)}, rest[end:]
+	}
+	if strings.HasPrefix(rest, "/") {
+		seg := rest[1:]
+		if i := strings.IndexByte(seg, '/'); i >= 0 {
+			seg = seg[:i]
+		}
+		switch p := strings.ToUpper(seg); p {
+		case "TCP", "UDP", "ANY":
+			r.Protocol, rest = p, rest[1+len(seg):]
+		}
+	}
+	path := ""
+	switch {
+	case strings.HasPrefix(rest, "/"):
+		if r.Cidr != "" {
+			return nil, fmt.Errorf("egress %q: HTTP paths need a host, not a CIDR", entry)
+		}
+		path = rest
+	case rest != "":
+		return nil, fmt.Errorf("egress %q: unexpected %q", entry, rest)
+	}
+	if method != "" || path != "" {
+		if r.Protocol != "TCP" {
+			return nil, fmt.Errorf("egress %q: HTTP rules need TCP", entry)
+		}
+		r.Http = []*pb.EgressHTTPRule{{Method: method, Path: path}}
+	}
+	return r, nil
+}
+
+// formatEgressRule renders r as allowlist entries, one per port and HTTP
+// rule, so parseEgressEntries(formatEgressRule(r)) yields r again.
+func formatEgressRule(r *pb.EgressRule) []string {
+	target := r.Host
+	if r.Cidr != "" {
+		target = r.Cidr
+		if ip, n, err := net.ParseCIDR(r.Cidr); err == nil {
+			ones, bits := n.Mask.Size()
+			switch {
+			case ip.To4() == nil && ones == bits:
+				target = "[" + ip.String() + "]"
+			case ip.To4() == nil:
+				target = fmt.Sprintf("[%s]/%d", n.IP, ones)
+			case ones == bits:
+				target = ip.String()
+			}
+		}
+	}
+	ports := r.Ports
+	if len(ports) == 0 {
+		ports = []int32{443}
+	}
+	proto := ""
+	if p := strings.ToUpper(r.Protocol); p != "" && p != "TCP" {
+		proto = "/" + strings.ToLower(p)
+	}
+	http := r.Http
+	if len(http) == 0 {
+		http = []*pb.EgressHTTPRule{{}}
+	}
+	var out []string
+	for _, port := range ports {
+		base := target
+		if port != 443 {
+			base += ":" + strconv.Itoa(int(port))
+		}
+		base += proto
+		for _, h := range http {
+			e := base + h.Path
+			if h.Method != "" {
+				e = h.Method + " " + e
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// egressEntries lists hosts and rules as allowlist entries.
+func egressEntries(hosts []string, rules []*pb.EgressRule) []string {
+	out := append([]string(nil), hosts...)
+	for _, r := range rules {
+		out = append(out, formatEgressRule(r)...)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package sandboxcli
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func TestParseEgressEntry(t *testing.T) {
+	cases := map[string]*pb.EgressRule{
+		"*.pypi.org":      {Host: "*.pypi.org", Ports: []int32{443}, Protocol: "TCP"},
+		"GitHub.com:22":   {Host: "github.com", Ports: []int32{22}, Protocol: "TCP"},
+		"dns.corp:53/udp": {Host: "dns.corp", Ports: []int32{53}, Protocol: "UDP"},
+		"10.0.0.0/8:5432": {Cidr: "10.0.0.0/8", Ports: []int32{5432}, Protocol: "TCP"},
+		"192.168.1.10":    {Cidr: "192.168.1.10", Ports: []int32{443}, Protocol: "TCP"},
+		"[fd00::]/8:443":  {Cidr: "fd00::/8", Ports: []int32{443}, Protocol: "TCP"},
+		"get api.github.com/repos/*": {
+			Host: "api.github.com", Ports: []int32{443}, Protocol: "TCP",
+			Http: []*pb.EgressHTTPRule{{Method: "GET", Path: "/repos/*"}},
+		},
+	}
+	for in, want := range cases {
+		got, err := parseEgressEntry(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", in, got, want)
+		}
+	}
+	for _, bad := range []string{"a.com:0", "a.com:x", "10.0.0.0/8/api", "a.com:53/udp/path", "G/T a.com"} {
+		if _, err := parseEgressEntry(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseEgressList_SplitsAndMerges(t *testing.T) {
+	hosts, rules, err := parseEgressList("pypi.org, api.github.com:443, GET api.github.com/repos/*, POST api.github.com/graphql, 10.0.0.0/8:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hosts, []string{"pypi.org", "api.github.com"}) {
+		t.Fatalf("plain hosts: %v", hosts)
+	}
+	if len(rules) != 2 || len(rules[0].Http) != 2 || rules[1].Cidr != "10.0.0.0/8" {
+		t.Fatalf("rules: %v", rules)
+	}
+}
+
+func TestFormatEgressRule_RoundTrips(t *testing.T) {
+	rules := []*pb.EgressRule{
+		{Host: "*.pypi.org", Ports: []int32{443}, Protocol: "TCP"},
+		{Cidr: "10.0.0.0/8", Ports: []int32{22, 5432}, Protocol: "TCP"},
+		{Cidr: "192.168.1.10/32", Ports: []int32{53}, Protocol: "UDP"},
+		{Cidr: "fd00::/8", Ports: []int32{443}, Protocol: "ANY"},
+		{Host: "api.github.com", Ports: []int32{443}, Protocol: "TCP", Http: []*pb.EgressHTTPRule{{Method: "GET", Path: "/repos/*"}, {Path: "/meta"}}},
+	}
+	entries := egressEntries([]string{"pypi.org"}, rules)
+	want := []string{
+		"pypi.org", "*.pypi.org", "10.0.0.0/8:22", "10.0.0.0/8:5432", "192.168.1.10:53/udp",
+		"[fd00::]/8/any", "GET api.github.com/repos/*", "api.github.com/meta",
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries:\n got %q\nwant %q", entries, want)
+	}
+	hosts, parsed, err := parseEgressEntries(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || len(parsed) != 6 {
+		t.Fatalf("round trip: hosts %v rules %v", hosts, parsed)
+	}
+}
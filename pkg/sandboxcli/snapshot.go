@@ -1,18 +1,20 @@
 package sandboxcli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/urfave/cli"
 	sandboxclient "github.com/xiaods/k8e/pkg/sandbox/client"
 	"github.com/xiaods/k8e/pkg/sandboxlayer"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const snapshotDirName = "snapshots"
@@ -25,6 +27,31 @@ type SnapshotMeta struct {
 	TenantID  string `json:"tenant_id"`
 	SizeBytes int64  `json:"size_bytes"`
 	FileCount int    `json:"file_count"`
+	// Registry is "server" when the workspace lives in the gateway's layer
+	// registry (SnapshotSession); empty for client-local tar snapshots.
+	Registry string `json:"registry,omitempty"`
+}
+
+// snapshotRegistryServer marks snapshots held by the server-side registry.
+const snapshotRegistryServer = "server"
+
+// useLocalSnapshots reports whether a snapshot RPC failed only because the
+// gateway has no layer registry (or predates the RPCs), in which case the
+// CLI falls back to client-local tar snapshots.
+func useLocalSnapshots(err error) bool {
+	switch status.Code(err) {
+	case codes.FailedPrecondition, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+func writeSnapshotMeta(meta SnapshotMeta) error {
+	if err := os.MkdirAll(snapshotDir(meta.Name), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(meta, "", "  ")
+	return os.WriteFile(snapshotMetaPath(meta.Name), data, 0644)
 }
 
 func snapshotDir(name string) string {
@@ -58,22 +85,37 @@ func storeSnapshotLayer(name string, content []byte) error {
 	if err != nil {
 		return fmt.Errorf("open layer store: %w", err)
 	}
-	// Multi-layer content-addressed manifest (KIP-16 M2): chunk the payload so
-	// shared chunks dedup across snapshots and Delta-based incremental restore
+	// Multi-layer content-addressed manifest (KIP-16 M2): content-defined
+	// chunks dedup across snapshots and Delta-based incremental restore
 	// only transfers missing layers.
-	layers, err := store.PutChunks(content, snapshotChunkSize)
+	params := sandboxlayer.DefaultCDCParams
+	layers, err := store.PutCDC(bytes.NewReader(content), params)
 	if err != nil {
 		return fmt.Errorf("store snapshot layers: %w", err)
 	}
-	if err := store.SaveManifest(name, layers); err != nil {
+	m := sandboxlayer.Manifest{Layers: layers, Chunking: sandboxlayer.ChunkingFastCDC, CDC: &params}
+	if err := store.PublishManifest(name, m); err != nil {
 		return fmt.Errorf("store snapshot manifest: %w", err)
 	}
 	return nil
 }
 
-// snapshotChunkSize is the layer chunk size for multi-layer manifests
-// (4 MiB — mirrors the gateway gRPC 64 MiB limit with headroom).
-const snapshotChunkSize = 4 * 1024 * 1024
+// snapshotStoreStats reports the local layer store's dedup for list/delete
+// output; nil when the store is unavailable.
+func snapshotStoreStats() map[string]any {
+	store, err := snapshotStore()
+	if err != nil {
+		return nil
+	}
+	st, err := store.Stats()
+	if err != nil {
+		return nil
+	}
+	return map[string]any{
+		"stored_bytes": st.Stored, "logical_bytes": st.Logical, "unique_bytes": st.Unique,
+		"dedup_ratio": st.DedupRatio(),
+	}
+}
 
 // readSnapshotPayload loads a snapshot's payload bytes: from the content-
 // addressed layer store when present, otherwise from the legacy tar file.
@@ -110,15 +152,6 @@ func reportIncrementalDelta(target, base string, targetPayload []byte) error {
 	return nil
 }
 
-// splitAllowedHosts splits a comma-separated egress allowlist, returning nil
-// for an empty input.
-func splitAllowedHosts(raw string) []string {
-	if raw == "" {
-		return nil
-	}
-	return strings.Split(raw, ",")
-}
-
 // readSnapshotMeta loads and validates a snapshot's metadata file.
 func readSnapshotMeta(name string) (SnapshotMeta, error) {
 	var meta SnapshotMeta
@@ -183,77 +216,107 @@ func snapshotSaveCommand() cli.Command {
 			}
 			defer client.Close()
 
-			// 1. Create tar.gz inside sandbox
 			fmt.Fprintf(os.Stderr, "[k8e-sandbox] archiving workspace...\n")
-			_, err := client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
-				SessionId: sid,
-				Command:   "tar czf /tmp/_snapshot.tar.gz -C /workspace . 2>/dev/null",
-				Timeout:   300,
+			resp, err := client.SandboxServiceClient.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{
+				SessionId: sid, Name: name,
 			})
 			if err != nil {
-				return printErrorExit("archive workspace: "+err.Error(), 1)
-			}
-
-			// 2. Get file count and size
-			countResp, err := client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
-				SessionId: sid,
-				Command:   "find /workspace -type f | wc -l",
-				Timeout:   30,
-			})
-			fileCount := 0
-			if err == nil {
-				fmt.Sscanf(countResp.Stdout, "%d", &fileCount)
-			}
-
-			// 3. Download tar.gz
-			fmt.Fprintf(os.Stderr, "[k8e-sandbox] downloading snapshot...\n")
-			readResp, err := client.SandboxServiceClient.ReadFile(context.Background(), &pb.ReadFileRequest{
-				SessionId: sid, Path: "/tmp/_snapshot.tar.gz",
-			})
-			if err != nil {
-				return printErrorExit("read snapshot: "+err.Error(), 1)
-			}
-
-			// 4. Save to disk
-			dir := snapshotDir(name)
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return printErrorExit("create snapshot dir: "+err.Error(), 2)
-			}
-			if err := os.WriteFile(snapshotTarPath(name), []byte(readResp.Content), 0644); err != nil {
-				return printErrorExit("write snapshot: "+err.Error(), 2)
-			}
-
-			// 4b. Content-address the payload into the layer store and lease it
-			// via a manifest (KIP-16 M2: dedup + GC lease).
-			if err := storeSnapshotLayer(name, []byte(readResp.Content)); err != nil {
-				return printErrorExit(err.Error(), 2)
+				if useLocalSnapshots(err) {
+					return saveLocalSnapshot(client, sid, name)
+				}
+				return printErrorExit("snapshot workspace: "+err.Error(), 1)
 			}
-
-			// 5. Write metadata
 			meta := SnapshotMeta{
 				Name:      name,
 				CreatedAt: time.Now().UTC().Format(time.RFC3339),
 				SessionID: sid,
-				SizeBytes: int64(len(readResp.Content)),
-				FileCount: fileCount,
+				SizeBytes: resp.SizeBytes,
+				FileCount: int(resp.FileCount),
+				Registry:  snapshotRegistryServer,
+			}
+			if err := writeSnapshotMeta(meta); err != nil {
+				return printErrorExit("write snapshot metadata: "+err.Error(), 2)
 			}
-			metaData, _ := json.MarshalIndent(meta, "", "  ")
-			os.WriteFile(snapshotMetaPath(name), metaData, 0644) //nolint:errcheck
-
-			// 6. Cleanup temp file in sandbox
-			client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
-				SessionId: sid, Command: "rm -f /tmp/_snapshot.tar.gz", Timeout: 10,
-			}) //nolint:errcheck
-
 			printJSON(map[string]any{
-				"ok": true, "name": name,
-				"size_bytes": meta.SizeBytes, "file_count": meta.FileCount,
+				"ok": true, "name": name, "registry": meta.Registry,
+				"size_bytes": meta.SizeBytes, "file_count": meta.FileCount, "layers": len(resp.Layers),
 			})
 			return nil
 		},
 	}
 }
 
+// saveLocalSnapshot is the client-local snapshot path for gateways without
+// a layer registry: tar inside the sandbox, download via ReadFile, and chunk
+// into the local layer store.
+func saveLocalSnapshot(client *sandboxclient.Client, sid, name string) error {
+	// 1. Create tar.gz inside sandbox
+	_, err := client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
+		SessionId: sid,
+		Command:   "tar czf /tmp/_snapshot.tar.gz -C /workspace . 2>/dev/null",
+		Timeout:   300,
+	})
+	if err != nil {
+		return printErrorExit("archive workspace: "+err.Error(), 1)
+	}
+
+	// 2. Get file count and size
+	countResp, err := client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
+		SessionId: sid,
+		Command:   "find /workspace -type f | wc -l",
+		Timeout:   30,
+	})
+	fileCount := 0
+	if err == nil {
+		fmt.Sscanf(countResp.Stdout, "%d", &fileCount)
+	}
+
+	// 3. Download tar.gz
+	fmt.Fprintf(os.Stderr, "[k8e-sandbox] downloading snapshot...\n")
+	readResp, err := client.SandboxServiceClient.ReadFile(context.Background(), &pb.ReadFileRequest{
+		SessionId: sid, Path: "/tmp/_snapshot.tar.gz",
+	})
+	if err != nil {
+		return printErrorExit("read snapshot: "+err.Error(), 1)
+	}
+
+	// 4. Save to disk
+	dir := snapshotDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return printErrorExit("create snapshot dir: "+err.Error(), 2)
+	}
+	if err := os.WriteFile(snapshotTarPath(name), []byte(readResp.Content), 0644); err != nil {
+		return printErrorExit("write snapshot: "+err.Error(), 2)
+	}
+
+	// 4b. Content-address the payload into the layer store and lease it
+	// via a manifest (KIP-16 M2: dedup + GC lease).
+	if err := storeSnapshotLayer(name, []byte(readResp.Content)); err != nil {
+		return printErrorExit(err.Error(), 2)
+	}
+
+	// 5. Write metadata
+	meta := SnapshotMeta{
+		Name:      name,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		SessionID: sid,
+		SizeBytes: int64(len(readResp.Content)),
+		FileCount: fileCount,
+	}
+	writeSnapshotMeta(meta) //nolint:errcheck
+
+	// 6. Cleanup temp file in sandbox
+	client.SandboxServiceClient.Exec(context.Background(), &pb.ExecRequest{
+		SessionId: sid, Command: "rm -f /tmp/_snapshot.tar.gz", Timeout: 10,
+	}) //nolint:errcheck
+
+	printJSON(map[string]any{
+		"ok": true, "name": name,
+		"size_bytes": meta.SizeBytes, "file_count": meta.FileCount,
+	})
+	return nil
+}
+
 func snapshotListCommand() cli.Command {
 	return cli.Command{
 		Name:  "list",
@@ -288,7 +351,7 @@ func snapshotListCommand() cli.Command {
 					"size_bytes": meta.SizeBytes, "file_count": meta.FileCount,
 				})
 			}
-			printJSON(map[string]any{"snapshots": snapshots})
+			printJSON(map[string]any{"snapshots": snapshots, "store": snapshotStoreStats()})
 			return nil
 		},
 	}
@@ -302,8 +365,9 @@ func snapshotRestoreCommand() cli.Command {
 		Flags: []cli.Flag{
 			cli.StringFlag{Name: "runtime", Value: "gvisor", Usage: "Runtime class"},
 			cli.StringFlag{Name: "tenant", EnvVar: "K8E_SANDBOX_TENANT", Usage: "Tenant identifier"},
-			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated FQDN egress allowlist"},
-			cli.StringFlag{Name: "base", Usage: "Incremental restore: only layers missing from this existing snapshot are transferred (KIP-16 M2)"},
+			cli.StringFlag{Name: "allowed-hosts", Usage: "Comma-separated egress allowlist, allow-hosts syntax"},
+			cli.StringFlag{Name: "base", Usage: "Incremental restore: only layers missing from this snapshot, already restored into the sandbox, are transferred (KIP-16 M2)"},
+			cli.StringFlag{Name: "session", Usage: "Restore into this existing session instead of creating one (registry snapshots)"},
 		},
 		Action: func(ctx *cli.Context) error {
 			name := ctx.Args().First()
@@ -317,38 +381,69 @@ func snapshotRestoreCommand() cli.Command {
 				return printErrorExit(err.Error(), 1)
 			}
 
-			// 2. Read snapshot payload. Prefer the content-addressed layer store
-			// (KIP-16 M2); fall back to the legacy tar file for old snapshots.
-			tarData, err := readSnapshotPayload(name)
-			if err != nil {
-				return printErrorExit("snapshot data not found: "+name, 2)
-			}
-			if base := ctx.String("base"); base != "" {
-				if err := reportIncrementalDelta(name, base, tarData); err != nil {
-					return printErrorExit(err.Error(), 1)
+			// 2. Read the local payload; registry snapshots stay on the server.
+			var tarData []byte
+			if meta.Registry != snapshotRegistryServer {
+				// Prefer the content-addressed layer store (KIP-16 M2); fall
+				// back to the legacy tar file for old snapshots.
+				if tarData, err = readSnapshotPayload(name); err != nil {
+					return printErrorExit("snapshot data not found: "+name, 2)
+				}
+				if base := ctx.String("base"); base != "" {
+					if err := reportIncrementalDelta(name, base, tarData); err != nil {
+						return printErrorExit(err.Error(), 1)
+					}
 				}
 			}
-			// 3. Create new session
 			client, exitErr := newClientFromCtx(ctx)
 			if exitErr != nil {
 				return exitErr
 			}
 			defer client.Close()
 
-			resp, err := client.SandboxServiceClient.CreateSession(context.Background(), &pb.CreateSessionRequest{
-				TenantId:     ctx.String("tenant"),
-				RuntimeClass: ctx.String("runtime"),
-				AllowedHosts: splitAllowedHosts(ctx.String("allowed-hosts")),
-			})
-			if err != nil {
-				return printErrorExit("create session: "+err.Error(), 2)
+			// 3. Create new session, unless restoring into an existing one
+			// (where --base was restored before and its layers are cached).
+			sid, podIP := ctx.String("session"), ""
+			if sid != "" && meta.Registry != snapshotRegistryServer {
+				return printErrorExit("--session needs a registry snapshot", 1)
+			}
+			if sid == "" {
+				hosts, rules, err := parseEgressList(ctx.String("allowed-hosts"))
+				if err != nil {
+					return printErrorExit(err.Error(), 1)
+				}
+				resp, err := client.SandboxServiceClient.CreateSession(context.Background(), &pb.CreateSessionRequest{
+					TenantId:     ctx.String("tenant"),
+					RuntimeClass: ctx.String("runtime"),
+					AllowedHosts: hosts,
+					EgressRules:  rules,
+				})
+				if err != nil {
+					return printErrorExit("create session: "+err.Error(), 2)
+				}
+				sid, podIP = resp.SessionId, resp.PodIp
 			}
 
-			sid := resp.SessionId
-
-			// 4-5. Upload + extract snapshot payload.
-			if err := uploadAndExtractSnapshot(client, sid, tarData); err != nil {
-				client.SandboxServiceClient.DestroySession(context.Background(), &pb.DestroySessionRequest{SessionId: sid}) //nolint:errcheck
+			// 4-5. Restore from the registry, or upload + extract the payload.
+			out := map[string]any{}
+			if meta.Registry == snapshotRegistryServer {
+				rr, rerr := client.SandboxServiceClient.RestoreSession(context.Background(), &pb.RestoreSessionRequest{
+					SessionId: sid, Name: name, Base: ctx.String("base"),
+				})
+				if rerr != nil {
+					err = fmt.Errorf("restore snapshot: %w", rerr)
+				} else {
+					out["layers"] = rr.Layers
+					out["transferred_layers"] = rr.TransferredLayers
+					out["transferred_bytes"] = rr.TransferredBytes
+				}
+			} else {
+				err = uploadAndExtractSnapshot(client, sid, tarData)
+			}
+			if err != nil {
+				if ctx.String("session") == "" {
+					client.SandboxServiceClient.DestroySession(context.Background(), &pb.DestroySessionRequest{SessionId: sid}) //nolint:errcheck
+				}
 				return printErrorExit(err.Error(), 2)
 			}
 
@@ -359,9 +454,11 @@ func snapshotRestoreCommand() cli.Command {
 			}
 			_ = finalizeState(tenant, sid)
 
-			printJSON(map[string]any{
-				"session_id": sid, "pod_ip": resp.PodIp, "tenant_id": tenant, "restored_from": name,
-			})
+			out["session_id"] = sid
+			out["pod_ip"] = podIP
+			out["tenant_id"] = tenant
+			out["restored_from"] = name
+			printJSON(out)
 			return nil
 		},
 	}
@@ -385,11 +482,15 @@ func snapshotDeleteCommand() cli.Command {
 
 			// Release the manifest lease so GC can reclaim the layers
 			// (KIP-16 M2 lease-driven GC).
+			out := map[string]any{"ok": true}
 			if store, err := snapshotStore(); err == nil {
 				_ = store.DeleteManifest(name)
-				_, _ = store.GC()
+				if reclaimed, err := store.GC(); err == nil {
+					out["reclaimed_bytes"] = reclaimed
+				}
+				out["store"] = snapshotStoreStats()
 			}
-			printJSON(map[string]any{"ok": true})
+			printJSON(out)
 			return nil
 		},
 	}
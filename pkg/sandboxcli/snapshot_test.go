@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // TestSnapshotStore_ContentAddressedDedup verifies saving the same payload
@@ -163,3 +165,18 @@ func TestSnapshotStore_IncrementalDelta(t *testing.T) {
 		t.Fatal("expected strictly fewer missing than total layers")
 	}
 }
+
+// TestUseLocalSnapshots verifies only a missing server registry (or an older
+// gateway) falls back to client-local snapshots; real failures surface.
+func TestUseLocalSnapshots(t *testing.T) {
+	for code, want := range map[codes.Code]bool{
+		codes.FailedPrecondition: true,
+		codes.Unimplemented:      true,
+		codes.NotFound:           false,
+		codes.Internal:           false,
+	} {
+		if got := useLocalSnapshots(status.Error(code, "x")); got != want {
+			t.Fatalf("%s: got %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package sandboxcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cliTrace is the per-invocation root span; every RPC the command makes is
+// its child.
+var cliTrace struct {
+	span     trace.Span
+	shutdown func(context.Context) error
+}
+
+// StartTracing is the app Before hook. With K8E_SANDBOX_TRACING_ENDPOINT or
+// K8E_SANDBOX_TRACING_FILE set it opens a root span for the command and
+// prints its trace ID to stderr, so the run can be found in the gateway's
+// traces. Otherwise it only installs the propagator.
+func StartTracing(c *cli.Context) error {
+	cfg := tracing.ConfigFromEnv("k8e-sandbox-cli")
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		// Tracing must never block the command itself.
+		fmt.Fprintf(os.Stderr, "tracing disabled: %v\n", err)
+		return nil
+	}
+	cliTrace.shutdown = shutdown
+	if !cfg.Enabled() || c.Args().First() == "" {
+		return nil
+	}
+	ctx, span := tracing.Start(context.Background(), "k8e-sandbox-cli "+c.Args().First())
+	cliTrace.span = span
+	tracing.SetDefaultParent(ctx)
+	if sc := span.SpanContext(); sc.IsSampled() {
+		fmt.Fprintf(os.Stderr, "trace_id: %s\n", sc.TraceID())
+	}
+	return nil
+}
+
+// StopTracing is the app After hook: it ends the root span and flushes.
+func StopTracing(c *cli.Context) error {
+	if cliTrace.span != nil {
+		cliTrace.span.End()
+	}
+	if cliTrace.shutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cliTrace.shutdown(ctx) //nolint:errcheck
+	}
+	return nil
+}
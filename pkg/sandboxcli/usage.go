@@ -0,0 +1,100 @@
+package sandboxcli
+
+import (
+	"context"
+	"time"
+
+	"github.com/urfave/cli"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// UsageCommand queries the gateway's usage ledger: per-session resource use
+// recorded as sessions end, with per-tenant totals for chargeback.
+func UsageCommand() cli.Command {
+	return cli.Command{
+		Name:  "usage",
+		Usage: "Query per-session and per-tenant resource usage (CPU, memory, egress, execs, snapshots)",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "tenant", Usage: "Tenant ID"},
+			cli.StringFlag{Name: "session-id", Usage: "Session ID"},
+			cli.StringFlag{Name: "since", Usage: "Only sessions ended after this time: a duration ago (24h) or RFC3339"},
+			cli.StringFlag{Name: "until", Usage: "Only sessions ended before this time: a duration ago or RFC3339"},
+			cli.IntFlag{Name: "limit", Usage: "Newest N ended sessions listed (default 1000); tenant totals cover all matches"},
+			cli.BoolFlag{Name: "active", Usage: "Also include usage so far of sessions that have not ended"},
+		},
+		Action: func(ctx *cli.Context) error {
+			now := time.Now()
+			since, err := parseAuditTime(ctx.String("since"), now)
+			if err != nil {
+				return printErrorExit("--since: "+err.Error(), 1)
+			}
+			until, err := parseAuditTime(ctx.String("until"), now)
+			if err != nil {
+				return printErrorExit("--until: "+err.Error(), 1)
+			}
+
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+			resp, err := client.SandboxServiceClient.QueryUsage(context.Background(), &pb.QueryUsageRequest{
+				TenantId:      ctx.String("tenant"),
+				SessionId:     ctx.String("session-id"),
+				Since:         since,
+				Until:         until,
+				Limit:         int32(ctx.Int("limit")),
+				IncludeActive: ctx.Bool("active"),
+			})
+			if err != nil {
+				return printErrorExit("usage: "+err.Error(), 2)
+			}
+			sessions := make([]any, 0, len(resp.Sessions))
+			for _, s := range resp.Sessions {
+				sessions = append(sessions, sessionUsageJSON(s))
+			}
+			tenants := make([]any, 0, len(resp.Tenants))
+			for _, t := range resp.Tenants {
+				tenants = append(tenants, map[string]any{
+					"tenant_id":         t.TenantId,
+					"sessions":          t.Sessions,
+					"duration_ms":       t.DurationMs,
+					"cpu_seconds":       t.CpuSeconds,
+					"memory_peak_bytes": t.MemoryPeakBytes,
+					"egress_bytes":      t.EgressBytes,
+					"exec_count":        t.ExecCount,
+					"exec_ms":           t.ExecMs,
+					"snapshot_bytes":    t.SnapshotBytes,
+				})
+			}
+			printJSON(map[string]any{"sessions": sessions, "tenants": tenants})
+			return nil
+		},
+	}
+}
+
+func sessionUsageJSON(s *pb.SessionUsage) map[string]any {
+	out := map[string]any{
+		"session_id":        s.SessionId,
+		"tenant_id":         s.TenantId,
+		"active":            s.Active,
+		"runtime_class":     s.RuntimeClass,
+		"template":          s.Template,
+		"duration_ms":       s.DurationMs,
+		"cpu_seconds":       s.CpuSeconds,
+		"memory_peak_bytes": s.MemoryPeakBytes,
+		"memory_avg_bytes":  s.MemoryAvgBytes,
+		"egress_bytes":      s.EgressBytes,
+		"exec_count":        s.ExecCount,
+		"exec_ms":           s.ExecMs,
+		"snapshot_bytes":    s.SnapshotBytes,
+	}
+	if s.StartedAt > 0 {
+		out["started_at"] = time.Unix(s.StartedAt, 0).UTC().Format(time.RFC3339)
+	}
+	if !s.Active {
+		out["reason"] = s.Reason
+		out["ended_at"] = time.Unix(s.EndedAt, 0).UTC().Format(time.RFC3339)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package sandboxcli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/urfave/cli"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// WatchCommand streams session lifecycle events from WatchSessions so
+// orchestrators stop polling get/poll for phase changes and run results.
+func WatchCommand() cli.Command {
+	return cli.Command{
+		Name:      "watch",
+		Usage:     "Stream session lifecycle events (one JSON object per line)",
+		ArgsUsage: "[session-id]",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "tenant", Usage: "Only sessions of this tenant"},
+			cli.StringSliceFlag{Name: "type", Usage: "Event type to include (repeatable): phase, deleted, expiring, run_completed, exposed, unexposed, allowed_hosts, egress"},
+			cli.BoolFlag{Name: "initial", Usage: "Start with the current phase of every matching session"},
+		},
+		Action: func(ctx *cli.Context) error {
+			types, err := parseSessionEventTypes(ctx.StringSlice("type"))
+			if err != nil {
+				return printErrorExit(err.Error(), 1)
+			}
+
+			client, exitErr := newClientFromCtx(ctx)
+			if exitErr != nil {
+				return exitErr
+			}
+			defer client.Close()
+
+			wctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			stream, err := client.SandboxServiceClient.WatchSessions(wctx, &pb.WatchSessionsRequest{
+				TenantId:    ctx.String("tenant"),
+				SessionId:   ctx.Args().First(),
+				Types:       types,
+				SendInitial: ctx.Bool("initial"),
+			})
+			if err != nil {
+				return printErrorExit("watch: "+err.Error(), 2)
+			}
+			for {
+				ev, err := stream.Recv()
+				if err == io.EOF || wctx.Err() != nil {
+					return nil
+				}
+				if err != nil {
+					return printErrorExit("watch: "+err.Error(), 2)
+				}
+				printJSON(sessionEventJSON(ev))
+			}
+		},
+	}
+}
+
+// parseSessionEventTypes maps "run_completed" style names to SessionEventType.
+func parseSessionEventTypes(names []string) ([]pb.SessionEventType, error) {
+	var types []pb.SessionEventType
+	for _, n := range names {
+		v, ok := pb.SessionEventType_value["SESSION_EVENT_"+strings.ToUpper(strings.ReplaceAll(n, "-", "_"))]
+		if !ok || v == 0 {
+			return nil, fmt.Errorf("unknown event type %q", n)
+		}
+		types = append(types, pb.SessionEventType(v))
+	}
+	return types, nil
+}
+
+func sessionEventJSON(ev *pb.SessionEvent) map[string]any {
+	out := map[string]any{
+		"type":       strings.ToLower(strings.TrimPrefix(ev.Type.String(), "SESSION_EVENT_")),
+		"session_id": ev.SessionId,
+		"tenant_id":  ev.TenantId,
+		"time":       ev.Time,
+	}
+	switch ev.Type {
+	case pb.SessionEventType_SESSION_EVENT_PHASE, pb.SessionEventType_SESSION_EVENT_DELETED:
+		out["phase"] = ev.Phase
+		out["previous_phase"] = ev.PreviousPhase
+	case pb.SessionEventType_SESSION_EVENT_EXPIRING:
+		out["expires_at"] = ev.ExpiresAt
+	case pb.SessionEventType_SESSION_EVENT_RUN_COMPLETED:
+		out["run_id"] = ev.RunId
+		out["run_status"] = ev.RunStatus
+		out["exit_code"] = ev.ExitCode
+	case pb.SessionEventType_SESSION_EVENT_EXPOSED, pb.SessionEventType_SESSION_EVENT_UNEXPOSED:
+		out["port"] = ev.Port
+		out["url"] = ev.Url
+	case pb.SessionEventType_SESSION_EVENT_ALLOWED_HOSTS:
+		out["allowed_hosts"] = ev.AllowedHosts
+		if len(ev.EgressRules) > 0 {
+			out["egress_rules"] = egressEntries(nil, ev.EgressRules)
+		}
+	case pb.SessionEventType_SESSION_EVENT_EGRESS:
+		out["host"] = ev.EgressHost
+		out["port"] = ev.Port
+		out["denied"] = ev.EgressDenied
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package sandboxcli
+
+import (
+	"testing"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func TestParseSessionEventTypes(t *testing.T) {
+	got, err := parseSessionEventTypes([]string{"phase", "run-completed", "ALLOWED_HOSTS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pb.SessionEventType{
+		pb.SessionEventType_SESSION_EVENT_PHASE,
+		pb.SessionEventType_SESSION_EVENT_RUN_COMPLETED,
+		pb.SessionEventType_SESSION_EVENT_ALLOWED_HOSTS,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	for _, bad := range []string{"unspecified", "finished"} {
+		if _, err := parseSessionEventTypes([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package sandboxlayer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Backend is the blob storage behind a Store. Layers are stored exactly as
+// given (the Store compresses them) under their uncompressed sha256; manifests
+// are opaque JSON documents. A backend holds no policy: dedup, caching and
+// lease-driven GC live in the Store, so every backend gets them for free.
+//
+// Reads of a missing layer or manifest return an error wrapping
+// fs.ErrNotExist (errors.Is(err, fs.ErrNotExist)).
+type Backend interface {
+	PutLayer(digest string, blob []byte) error
+	GetLayer(digest string) ([]byte, error)
+	// LayerHeader returns up to the first n bytes of a stored layer, so
+	// Stats can read zstd frame headers without fetching whole layers.
+	LayerHeader(digest string, n int) ([]byte, error)
+	HasLayer(digest string) (bool, error)
+	// TouchLayer resets a stored layer's ModTime to now and reports whether
+	// it exists, so a layer re-used by a new snapshot counts as freshly
+	// written for GCBefore.
+	TouchLayer(digest string) (bool, error)
+	DeleteLayer(digest string) error
+	ListLayers() ([]LayerInfo, error)
+
+	PutManifest(name string, doc []byte) error
+	GetManifest(name string) ([]byte, error)
+	DeleteManifest(name string) error
+	// ListManifests returns manifest names, sorted.
+	ListManifests() ([]string, error)
+}
+
+// LayerInfo describes one stored layer.
+type LayerInfo struct {
+	Digest  string
+	Size    int64 // stored (compressed) bytes
+	ModTime time.Time
+}
+
+// FSBackend stores layers and manifests in a directory. It backs local
+// stores, shared filesystems (NFS/EFS mounted on every gateway node) and the
+// pull-through cache in front of remote backends.
+//
+// Layout:
+//
+//	<dir>/layers/<sha256>          immutable published layers
+//	<dir>/staging/                 temporary files before publish
+//	<dir>/manifests/<name>.json    snapshot manifests referencing layers
+type FSBackend struct {
+	dir string
+}
+
+// NewFSBackend opens (creating if needed) a filesystem backend at dir.
+func NewFSBackend(dir string) (*FSBackend, error) {
+	for _, sub := range []string{"layers", "staging", "manifests"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("layerstore mkdir %s: %w", sub, err)
+		}
+	}
+	return &FSBackend{dir: dir}, nil
+}
+
+// PutLayer stages blob and renames it into place. The file is fsync'd before
+// rename so a crash never leaves a torn layer under the final name.
+func (b *FSBackend) PutLayer(digest string, blob []byte) error {
+	if err := b.publish(b.layerPath(digest), "layer-", blob); err != nil {
+		return fmt.Errorf("layerstore publish %s: %w", digest, err)
+	}
+	return nil
+}
+
+func (b *FSBackend) GetLayer(digest string) ([]byte, error) {
+	return os.ReadFile(b.layerPath(digest))
+}
+
+func (b *FSBackend) LayerHeader(digest string, n int) ([]byte, error) {
+	f, err := os.Open(b.layerPath(digest))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, n)
+	m, err := io.ReadFull(f, head)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return head[:m], err
+}
+
+func (b *FSBackend) HasLayer(digest string) (bool, error) {
+	_, err := os.Stat(b.layerPath(digest))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *FSBackend) TouchLayer(digest string) (bool, error) {
+	now := time.Now()
+	err := os.Chtimes(b.layerPath(digest), now, now)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *FSBackend) DeleteLayer(digest string) error {
+	return os.Remove(b.layerPath(digest))
+}
+
+func (b *FSBackend) ListLayers() ([]LayerInfo, error) {
+	entries, err := os.ReadDir(b.layerDir())
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]LayerInfo, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed concurrently
+		}
+		layers = append(layers, LayerInfo{Digest: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return layers, nil
+}
+
+// PutManifest writes doc atomically (tmp + fsync + rename).
+func (b *FSBackend) PutManifest(name string, doc []byte) error {
+	return b.publish(b.manifestPath(name), "manifest-", doc)
+}
+
+func (b *FSBackend) GetManifest(name string) ([]byte, error) {
+	return os.ReadFile(b.manifestPath(name))
+}
+
+func (b *FSBackend) DeleteManifest(name string) error {
+	return os.Remove(b.manifestPath(name))
+}
+
+func (b *FSBackend) ListManifests() ([]string, error) {
+	entries, err := os.ReadDir(b.manifestDir())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		names = append(names, trimExt(e.Name()))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// publish writes data to a staging file and renames it to path.
+func (b *FSBackend) publish(path, prefix string, data []byte) error {
+	tmp, err := os.CreateTemp(b.stagingDir(), prefix)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op after successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+func (b *FSBackend) layerDir() string   { return filepath.Join(b.dir, "layers") }
+func (b *FSBackend) stagingDir() string { return filepath.Join(b.dir, "staging") }
+func (b *FSBackend) manifestDir() string {
+	return filepath.Join(b.dir, "manifests")
+}
+func (b *FSBackend) layerPath(digest string) string {
+	return filepath.Join(b.layerDir(), digest)
+}
+func (b *FSBackend) manifestPath(name string) string {
+	return filepath.Join(b.manifestDir(), name+".json")
+}
@@ -0,0 +1,282 @@
+package sandboxlayer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBackend is an in-memory remote backend that counts layer fetches.
+type memBackend struct {
+	mu        sync.Mutex
+	layers    map[string][]byte
+	mtimes    map[string]time.Time
+	manifests map[string][]byte
+	gets      int
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{layers: map[string][]byte{}, mtimes: map[string]time.Time{}, manifests: map[string][]byte{}}
+}
+
+func (m *memBackend) PutLayer(d string, b []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.layers[d] = append([]byte(nil), b...)
+	m.mtimes[d] = time.Now()
+	return nil
+}
+
+func (m *memBackend) GetLayer(d string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets++
+	b, ok := m.layers[d]
+	if !ok {
+		return nil, fmt.Errorf("layer %s: %w", d, fs.ErrNotExist)
+	}
+	return b, nil
+}
+
+func (m *memBackend) LayerHeader(d string, n int) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.layers[d]
+	if !ok {
+		return nil, fmt.Errorf("layer %s: %w", d, fs.ErrNotExist)
+	}
+	if len(b) > n {
+		b = b[:n]
+	}
+	return b, nil
+}
+
+func (m *memBackend) HasLayer(d string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.layers[d]
+	return ok, nil
+}
+
+func (m *memBackend) TouchLayer(d string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.layers[d]; !ok {
+		return false, nil
+	}
+	m.mtimes[d] = time.Now()
+	return true, nil
+}
+
+func (m *memBackend) DeleteLayer(d string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.layers, d)
+	return nil
+}
+
+func (m *memBackend) ListLayers() ([]LayerInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []LayerInfo
+	for d, b := range m.layers {
+		out = append(out, LayerInfo{Digest: d, Size: int64(len(b)), ModTime: m.mtimes[d]})
+	}
+	return out, nil
+}
+
+func (m *memBackend) PutManifest(name string, doc []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.manifests[name] = doc
+	return nil
+}
+
+func (m *memBackend) GetManifest(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.manifests[name]
+	if !ok {
+		return nil, fmt.Errorf("manifest %s: %w", name, fs.ErrNotExist)
+	}
+	return b, nil
+}
+
+func (m *memBackend) DeleteManifest(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.manifests, name)
+	return nil
+}
+
+func (m *memBackend) ListManifests() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for n := range m.manifests {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestStore_PullThroughCache(t *testing.T) {
+	remote := newMemBackend()
+	writer, err := NewWithBackend(remote, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := writer.Put([]byte("shared layer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.SaveManifest("snap", []string{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another node sees the manifest and fetches the layer once.
+	reader, err := NewWithBackend(remote, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := reader.LoadManifest("snap")
+	if err != nil {
+		t.Fatalf("manifest not shared: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		got, err := reader.Assemble(m.Layers)
+		if err != nil || string(got) != "shared layer" {
+			t.Fatalf("assemble: %q %v", got, err)
+		}
+	}
+	if remote.gets != 1 {
+		t.Fatalf("expected one remote fetch, got %d", remote.gets)
+	}
+
+	// The writer cached on Put and never fetched.
+	if _, err := writer.Get(d); err != nil {
+		t.Fatal(err)
+	}
+	if remote.gets != 1 {
+		t.Fatalf("writer refetched a layer it stored: %d fetches", remote.gets)
+	}
+}
+
+func TestStore_GCBeforeSparesInFlightLayers(t *testing.T) {
+	remote := newMemBackend()
+	s, err := NewWithBackend(remote, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := s.Put([]byte("referenced"))
+	if err := s.SaveManifest("snap", []string{kept}); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := s.Put([]byte("orphaned long ago"))
+	remote.mtimes[old] = time.Now().Add(-2 * time.Hour)
+	inflight, _ := s.Put([]byte("another node's upload"))
+
+	removed, err := s.GCBefore(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed == 0 || s.Has(old) {
+		t.Fatal("old unreferenced layer should be collected")
+	}
+	if !s.Has(kept) || !s.Has(inflight) {
+		t.Fatal("referenced and in-flight layers must survive")
+	}
+	// The cache drops every unreferenced layer, including in-flight ones.
+	if _, err := s.cache.GetLayer(inflight); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unreferenced layer left in cache: %v", err)
+	}
+	if _, err := s.cache.GetLayer(kept); err != nil {
+		t.Fatalf("referenced layer evicted from cache: %v", err)
+	}
+}
+
+func TestStore_GCBeforeSparesDedupedLayers(t *testing.T) {
+	remote := newMemBackend()
+	s, err := NewWithBackend(remote, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A layer orphaned long ago by a deleted snapshot...
+	d, _ := s.Put([]byte("shared chunk"))
+	remote.mtimes[d] = time.Now().Add(-2 * time.Hour)
+
+	// ...is re-used by a snapshot in flight on another node, and GC runs
+	// before that snapshot publishes its manifest.
+	other, _ := NewWithBackend(remote, "")
+	if got, err := other.Put([]byte("shared chunk")); err != nil || got != d {
+		t.Fatalf("dedup put: %s %v", got, err)
+	}
+	if _, err := s.GCBefore(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Has(d) {
+		t.Fatal("GC reclaimed a layer an in-flight snapshot dedups against")
+	}
+	if err := other.SaveManifest("snap", []string{d}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := other.Assemble([]string{d}); err != nil || string(got) != "shared chunk" {
+		t.Fatalf("assemble: %q %v", got, err)
+	}
+}
+
+func TestFSBackend_TouchLayer(t *testing.T) {
+	b, err := NewFSBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.TouchLayer("missing"); ok || err != nil {
+		t.Fatalf("touch missing layer: %v %v", ok, err)
+	}
+	if err := b.PutLayer("abc", []byte("blob")); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(b.layerPath("abc"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := b.TouchLayer("abc"); !ok || err != nil {
+		t.Fatalf("touch layer: %v %v", ok, err)
+	}
+	layers, _ := b.ListLayers()
+	if len(layers) != 1 || layers[0].ModTime.Before(time.Now().Add(-time.Minute)) {
+		t.Fatalf("ModTime not refreshed: %+v", layers)
+	}
+}
+
+func TestStore_GCKeepsLayersOfUnreadableManifest(t *testing.T) {
+	remote := newMemBackend()
+	s, _ := NewWithBackend(remote, "")
+	d, _ := s.Put([]byte("leased"))
+	remote.manifests["broken"] = []byte("{not json")
+	if _, err := s.GC(); err == nil {
+		t.Fatal("GC must fail rather than guess which layers are free")
+	}
+	if !s.Has(d) {
+		t.Fatal("layer removed despite an unreadable manifest")
+	}
+}
+
+func TestFSBackend_MissingIsNotExist(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadManifest("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing manifest: %v", err)
+	}
+	if _, err := s.Get(strings.Repeat("0", 64)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing layer: %v", err)
+	}
+}
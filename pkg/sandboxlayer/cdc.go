@@ -0,0 +1,141 @@
+package sandboxlayer
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Content-defined chunking (FastCDC, Xia et al., USENIX ATC '16). Chunk
+// boundaries are picked by a rolling gear hash over the content itself, so
+// an insert near the start of a workspace tar only changes the chunks around
+// the edit; every later chunk keeps its digest and dedups against earlier
+// snapshots. Fixed-size chunking shifts every chunk after the edit instead.
+
+// Chunking algorithms recorded in Manifest.Chunking.
+const (
+	// ChunkingFixed is fixed-size chunking (PutChunks/PutReader). Manifests
+	// written before the field existed are fixed-size.
+	ChunkingFixed = "fixed"
+	// ChunkingFastCDC is content-defined chunking (PutCDC).
+	ChunkingFastCDC = "fastcdc"
+)
+
+// CDCParams are the FastCDC chunk size bounds in bytes. Avg is rounded down
+// to a power of two; Min and Max bound every chunk except the last.
+type CDCParams struct {
+	Min int `json:"min"`
+	Avg int `json:"avg"`
+	Max int `json:"max"`
+}
+
+// DefaultCDCParams suit workspace tars: chunks small enough to dedup a
+// repo's unchanged files, large enough to keep manifests short.
+var DefaultCDCParams = CDCParams{Min: 256 * 1024, Avg: 1024 * 1024, Max: 4 * 1024 * 1024}
+
+// Validate reports whether p describes a usable chunker.
+func (p CDCParams) Validate() error {
+	if p.Min <= 0 || p.Avg < 64 || p.Min > p.Avg || p.Avg > p.Max {
+		return fmt.Errorf("layerstore: invalid cdc params min=%d avg=%d max=%d (need 0 < min <= avg <= max, avg >= 64)",
+			p.Min, p.Avg, p.Max)
+	}
+	return nil
+}
+
+// chunker holds the derived FastCDC masks for one parameter set.
+type chunker struct {
+	CDCParams
+	maskS, maskL uint64
+}
+
+func newChunker(p CDCParams) (*chunker, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	b := bits.Len(uint(p.Avg)) - 1 // log2, rounding Avg down
+	// Normalized chunking (NC level 1): a stricter mask below the average
+	// and a looser one above it pull chunk sizes towards Avg. The ones sit
+	// in the high bits, which depend on the last 64 bytes of input.
+	return &chunker{
+		CDCParams: p,
+		maskS:     ^uint64(0) << (64 - (b + 1)),
+		maskL:     ^uint64(0) << (64 - (b - 1)),
+	}, nil
+}
+
+// cut returns the length of the first chunk of data. data holds at least
+// Max bytes unless it is the tail of the stream.
+func (c *chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.Min {
+		return n
+	}
+	if n > c.Max {
+		n = c.Max
+	}
+	normal := c.Avg
+	if normal > n {
+		normal = n
+	}
+	var fp uint64
+	i := c.Min
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// split reads r to EOF, calling fn with each chunk in order. fn must not
+// retain the slice: the buffer is reused.
+func (c *chunker) split(r io.Reader, fn func([]byte) error) error {
+	buf := make([]byte, c.Max)
+	filled := 0
+	eof := false
+	for {
+		if !eof {
+			n, err := io.ReadFull(r, buf[filled:])
+			filled += n
+			switch err {
+			case nil:
+			case io.EOF, io.ErrUnexpectedEOF:
+				eof = true
+			default:
+				return fmt.Errorf("layerstore read: %w", err)
+			}
+		}
+		if filled == 0 {
+			return nil
+		}
+		n := c.cut(buf[:filled])
+		if err := fn(buf[:n]); err != nil {
+			return err
+		}
+		filled = copy(buf, buf[n:filled])
+	}
+}
+
+// gear is the FastCDC gear table. It is derived from a fixed seed and MUST
+// NOT change: chunk boundaries, and so dedup against existing layers,
+// depend on it.
+var gear = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0x6b38652d63646321) // "k8e-cdc!"
+	for i := range t {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
@@ -0,0 +1,122 @@
+package sandboxlayer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+var testCDC = CDCParams{Min: 2 * 1024, Avg: 8 * 1024, Max: 32 * 1024}
+
+func randomPayload(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b) //nolint:gosec
+	return b
+}
+
+func cdcChunks(t *testing.T, data []byte, p CDCParams) [][]byte {
+	t.Helper()
+	c, err := newChunker(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][]byte
+	if err := c.split(bytes.NewReader(data), func(b []byte) error {
+		out = append(out, append([]byte(nil), b...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// TestCDC_ChunkBounds verifies chunks reassemble the input, respect min/max
+// and average out near Avg.
+func TestCDC_ChunkBounds(t *testing.T) {
+	data := randomPayload(4*1024*1024, 1)
+	chunks := cdcChunks(t, data, testCDC)
+	if got := bytes.Join(chunks, nil); !bytes.Equal(got, data) {
+		t.Fatal("chunks do not reassemble the input")
+	}
+	for i, c := range chunks {
+		if len(c) > testCDC.Max || (i < len(chunks)-1 && len(c) < testCDC.Min) {
+			t.Fatalf("chunk %d size %d outside [%d, %d]", i, len(c), testCDC.Min, testCDC.Max)
+		}
+	}
+	avg := len(data) / len(chunks)
+	if avg < testCDC.Avg/2 || avg > testCDC.Avg*2 {
+		t.Fatalf("average chunk %d too far from target %d", avg, testCDC.Avg)
+	}
+}
+
+// TestCDC_InsertKeepsLaterChunks is the reason for CDC: a one-byte insert
+// near the start leaves almost every chunk shared, where fixed-size chunks
+// all shift.
+func TestCDC_InsertKeepsLaterChunks(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := randomPayload(2*1024*1024, 2)
+	next := append(append(append([]byte(nil), base[:100]...), 'X'), base[100:]...)
+
+	baseCDC, err := s.PutCDC(bytes.NewReader(base), testCDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextCDC, err := s.PutCDC(bytes.NewReader(next), testCDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := Delta(&Manifest{Layers: baseCDC}, &Manifest{Layers: nextCDC}); len(missing) > 2 {
+		t.Fatalf("cdc: %d of %d chunks changed after a 1-byte insert", len(missing), len(nextCDC))
+	}
+
+	baseFixed, _ := s.PutChunks(base, testCDC.Avg)
+	nextFixed, _ := s.PutChunks(next, testCDC.Avg)
+	if missing := Delta(&Manifest{Layers: baseFixed}, &Manifest{Layers: nextFixed}); len(missing) != len(nextFixed) {
+		t.Fatalf("fixed: expected every chunk to shift, %d of %d changed", len(missing), len(nextFixed))
+	}
+}
+
+// TestCDC_StreamingIsDeterministic verifies boundaries do not depend on how
+// the reader delivers bytes, and that an empty stream yields one layer.
+func TestCDC_StreamingIsDeterministic(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := randomPayload(200*1024, 3)
+	want, err := s.PutCDC(bytes.NewReader(data), testCDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.PutCDC(iotest.HalfReader(bytes.NewReader(data)), testCDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("chunk count %d vs %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("chunk %d differs between readers", i)
+		}
+	}
+	empty, err := s.PutCDC(bytes.NewReader(nil), testCDC)
+	if err != nil || len(empty) != 1 {
+		t.Fatalf("empty stream: layers=%v err=%v", empty, err)
+	}
+}
+
+func TestCDCParams_Validate(t *testing.T) {
+	if err := DefaultCDCParams.Validate(); err != nil {
+		t.Fatalf("defaults invalid: %v", err)
+	}
+	for _, p := range []CDCParams{{}, {Min: 10, Avg: 8, Max: 16}, {Min: 1, Avg: 64, Max: 32}, {Min: 1, Avg: 16, Max: 32}} {
+		if p.Validate() == nil {
+			t.Fatalf("expected %+v to be rejected", p)
+		}
+	}
+}
@@ -4,40 +4,58 @@
 // It mirrors the mechanism of ephemeral-sandbox's layerstack without copying
 // its delivery form: SHA-256 content addressing, immutable layers, atomic
 // staging+publish, manifest-based references, and lease-driven GC. The store is
-// pure Go and file-backed, so it stays inside the single-binary constraint.
+// pure Go and stays inside the single-binary constraint. Blobs live behind a
+// Backend: a local directory by default, or a remote one (S3-compatible, see
+// package s3backend) shared by every gateway node, fronted by a local
+// pull-through layer cache.
 package sandboxlayer
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sort"
+	"io"
+	"io/fs"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 )
 
-// Store is a content-addressed layer store rooted at dir.
-//
-// Layout:
-//
-//	<dir>/layers/<sha256>          immutable published layers
-//	<dir>/staging/                 temporary files before publish
-//	<dir>/manifests/<name>.json    snapshot manifests referencing layers
+// Store is a content-addressed layer store over a Backend.
 type Store struct {
-	dir string
+	backend Backend
+	// cache holds layers pulled from a remote backend. Layers are immutable
+	// and content-addressed, so a cached copy never goes stale; manifests
+	// are mutable and always read from the backend.
+	cache *FSBackend
 }
 
-// New opens (creating if needed) a layer store at dir.
+// New opens (creating if needed) a layer store in the local directory dir
+// (see FSBackend for the layout).
 func New(dir string) (*Store, error) {
-	for _, sub := range []string{"layers", "staging", "manifests"} {
-		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
-			return nil, fmt.Errorf("layerstore mkdir %s: %w", sub, err)
+	b, err := NewFSBackend(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{backend: b}, nil
+}
+
+// NewWithBackend opens a layer store over b. A non-empty cacheDir keeps a
+// local pull-through copy of every layer read or written, so repeat restores
+// on the same node do not refetch from b.
+func NewWithBackend(b Backend, cacheDir string) (*Store, error) {
+	s := &Store{backend: b}
+	if cacheDir != "" {
+		c, err := NewFSBackend(cacheDir)
+		if err != nil {
+			return nil, err
 		}
+		s.cache = c
 	}
-	return &Store{dir: dir}, nil
+	return s, nil
 }
 
 // Digest returns the hex sha256 of b.
@@ -47,76 +65,106 @@ func Digest(b []byte) string {
 }
 
 // Put stages content and publishes it atomically under its sha256. Idempotent:
-// publishing an existing digest is a no-op. The layer is stored zstd-compressed
+// publishing an existing digest only refreshes its ModTime, so GCBefore sees
+// a re-used layer as part of the snapshot now in flight. The layer is stored zstd-compressed
 // (content-addressed by the UNCOMPRESSED digest, like OCI registries) so
-// dedup works on content and disk usage stays compact. The file is fsync'd
-// before rename so a crash never leaves a torn layer under the final name.
+// dedup works on content and disk usage stays compact. The backend publishes
+// atomically, so a crash never leaves a torn layer under the final name.
 func (s *Store) Put(content []byte) (string, error) {
 	digest := Digest(content)
-	layerPath := s.layerPath(digest)
-	if _, err := os.Stat(layerPath); err == nil {
+	// Ask the backend, not the cache: another node's GC may have removed a
+	// layer this node still caches.
+	if ok, err := s.backend.TouchLayer(digest); err != nil {
+		return "", fmt.Errorf("layerstore stat %s: %w", digest, err)
+	} else if ok {
 		return digest, nil // already present
 	}
 
 	compressed := compress(content)
-	tmp, err := os.CreateTemp(s.stagingDir(), "layer-")
-	if err != nil {
-		return "", fmt.Errorf("layerstore stage: %w", err)
-	}
-	tmpName := tmp.Name()
-	defer os.Remove(tmpName) // no-op after successful rename
-
-	if _, err := tmp.Write(compressed); err != nil {
-		tmp.Close() //nolint:errcheck
-		return "", fmt.Errorf("layerstore write: %w", err)
-	}
-	if err := tmp.Sync(); err != nil {
-		tmp.Close() //nolint:errcheck
-		return "", fmt.Errorf("layerstore fsync: %w", err)
-	}
-	if err := tmp.Close(); err != nil {
-		return "", fmt.Errorf("layerstore close: %w", err)
-	}
-	if err := os.Rename(tmpName, layerPath); err != nil {
-		return "", fmt.Errorf("layerstore publish: %w", err)
+	if err := s.backend.PutLayer(digest, compressed); err != nil {
+		return "", err
 	}
+	s.cacheLayer(digest, compressed)
 	return digest, nil
 }
 
 // Has reports whether a layer with digest exists.
 func (s *Store) Has(digest string) bool {
-	_, err := os.Stat(s.layerPath(digest))
-	return err == nil
+	ok, _ := s.backend.HasLayer(digest)
+	return ok
 }
 
 // PutChunks splits content into fixed-size chunks and stores each as a layer,
 // returning the ordered digest list. Chunks dedup across calls, so snapshots
 // sharing identical chunks reference the same layer (Delta-friendly).
 func (s *Store) PutChunks(content []byte, chunkSize int) ([]string, error) {
+	return s.PutReader(bytes.NewReader(content), chunkSize)
+}
+
+// PutReader is PutChunks over a stream: it holds at most one chunk in
+// memory, so archives larger than RAM (or the gRPC message cap) can be
+// stored as they are produced.
+func (s *Store) PutReader(r io.Reader, chunkSize int) ([]string, error) {
 	if chunkSize <= 0 {
 		chunkSize = 4 * 1024 * 1024
 	}
 	var digests []string
-	for start := 0; start < len(content); start += chunkSize {
-		end := start + chunkSize
-		if end > len(content) {
-			end = len(content)
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			d, perr := s.Put(buf[:n])
+			if perr != nil {
+				return nil, perr
+			}
+			digests = append(digests, d)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
 		}
-		d, err := s.Put(content[start:end])
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("layerstore read: %w", err)
 		}
-		digests = append(digests, d)
 	}
-	if len(content) == 0 {
-		// Empty workspace: store one empty layer so the manifest is non-empty.
-		d, err := s.Put(nil)
+	return s.nonEmpty(digests)
+}
+
+// PutCDC stores r as content-defined chunks (FastCDC with p), returning the
+// ordered digest list. Like PutReader it streams, holding one Max-sized
+// buffer; unlike fixed-size chunks, the chunks after an edit are unchanged
+// and dedup against earlier snapshots. Publish the result with Chunking
+// ChunkingFastCDC.
+func (s *Store) PutCDC(r io.Reader, p CDCParams) ([]string, error) {
+	c, err := newChunker(p)
+	if err != nil {
+		return nil, err
+	}
+	var digests []string
+	err = c.split(r, func(chunk []byte) error {
+		d, err := s.Put(chunk)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		digests = append(digests, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.nonEmpty(digests)
+}
+
+// nonEmpty stores one empty layer for an empty payload (empty workspace) so
+// the manifest is non-empty.
+func (s *Store) nonEmpty(digests []string) ([]string, error) {
+	if len(digests) > 0 {
+		return digests, nil
+	}
+	d, err := s.Put(nil)
+	if err != nil {
+		return nil, err
 	}
-	return digests, nil
+	return []string{d}, nil
 }
 
 // Assemble concatenates layer contents in digest order, reconstructing the
@@ -133,24 +181,58 @@ func (s *Store) Assemble(digests []string) ([]byte, error) {
 	return out, nil
 }
 
-// Get returns the (decompressed) layer content for digest.
+// Get returns the (decompressed) layer content for digest, from the cache
+// when present.
 func (s *Store) Get(digest string) ([]byte, error) {
-	b, err := os.ReadFile(s.layerPath(digest))
+	b, err := s.getLayer(digest)
 	if err != nil {
 		return nil, fmt.Errorf("layerstore get %s: %w", digest, err)
 	}
 	return decompress(b)
 }
 
+// getLayer returns the stored (compressed) layer, filling the cache on a
+// miss.
+func (s *Store) getLayer(digest string) ([]byte, error) {
+	if s.cache != nil {
+		if b, err := s.cache.GetLayer(digest); err == nil {
+			return b, nil
+		}
+	}
+	b, err := s.backend.GetLayer(digest)
+	if err != nil {
+		return nil, err
+	}
+	s.cacheLayer(digest, b)
+	return b, nil
+}
+
+// cacheLayer copies a layer into the pull-through cache. Best-effort: the
+// backend already holds it, so a full cache disk only costs a refetch.
+func (s *Store) cacheLayer(digest string, blob []byte) {
+	if s.cache != nil {
+		_ = s.cache.PutLayer(digest, blob)
+	}
+}
+
 // Manifest is a content-addressed snapshot: an ordered list of layer digests
 // whose concatenation reconstructs the workspace.
 type Manifest struct {
 	SchemaVersion int      `json:"schema_version"`
 	Layers        []string `json:"layers"`
+	// Chunking is how Layers were cut (ChunkingFixed or ChunkingFastCDC,
+	// with CDC set). Assembly is plain concatenation either way; the
+	// algorithm matters when chunking a successor snapshot for dedup.
+	Chunking string     `json:"chunking,omitempty"`
+	CDC      *CDCParams `json:"cdc,omitempty"`
+	// Owner is the tenant the snapshot counts against (quota accounting).
+	// Empty for snapshots taken without a tenant.
+	Owner string `json:"owner,omitempty"`
 }
 
-// ManifestVersion is the current manifest schema.
-const ManifestVersion = 1
+// ManifestVersion is the current manifest schema. Version 2 added Chunking;
+// version 1 manifests load as ChunkingFixed.
+const ManifestVersion = 2
 
 // SquashThreshold is the max layers a manifest may hold before SaveManifest
 // squashes it into a single consolidated layer (mirrors ephemeral-sandbox's
@@ -162,41 +244,41 @@ const SquashThreshold = 32
 // When the layer count exceeds SquashThreshold, the layers are squashed into
 // one consolidated layer first (KIP-16 M2 autosquash).
 func (s *Store) SaveManifest(name string, layers []string) error {
-	if len(layers) > SquashThreshold {
-		consolidated, err := s.squash(layers)
+	return s.SaveManifestAs(name, Manifest{Layers: layers, Chunking: ChunkingFixed})
+}
+
+// SaveManifestAs is SaveManifest for a full manifest (e.g. with Owner set);
+// autosquash applies to m.Layers.
+func (s *Store) SaveManifestAs(name string, m Manifest) error {
+	if len(m.Layers) > SquashThreshold {
+		consolidated, err := s.squash(m.Layers)
 		if err != nil {
 			return err
 		}
-		layers = []string{consolidated}
+		m.Layers = []string{consolidated}
 	}
-	m := Manifest{SchemaVersion: ManifestVersion, Layers: layers}
+	return s.PublishManifest(name, m)
+}
+
+// PublishManifest writes m as-is, without autosquash. Chunked workspace
+// archives use it: squashing their chunks would buffer the whole archive
+// and leave Delta nothing to share between snapshots.
+func (s *Store) PublishManifest(name string, m Manifest) error {
+	m.SchemaVersion = ManifestVersion
 	b, err := json.Marshal(m)
 	if err != nil {
 		return err
 	}
-	tmp, err := os.CreateTemp(s.manifestDir(), "manifest-")
-	if err != nil {
-		return err
-	}
-	tmpName := tmp.Name()
-	defer os.Remove(tmpName)
-	if _, err := tmp.Write(b); err != nil {
-		tmp.Close() //nolint:errcheck
-		return err
-	}
-	if err := tmp.Sync(); err != nil {
-		tmp.Close() //nolint:errcheck
-		return err
-	}
-	if err := tmp.Close(); err != nil {
-		return err
+	if err := s.backend.PutManifest(name, b); err != nil {
+		return fmt.Errorf("layerstore manifest %s: %w", name, err)
 	}
-	return os.Rename(tmpName, filepath.Join(s.manifestDir(), name+".json"))
+	return nil
 }
 
-// LoadManifest reads a manifest by name.
+// LoadManifest reads a manifest by name. A missing manifest returns an error
+// wrapping fs.ErrNotExist.
 func (s *Store) LoadManifest(name string) (*Manifest, error) {
-	b, err := os.ReadFile(filepath.Join(s.manifestDir(), name+".json"))
+	b, err := s.backend.GetManifest(name)
 	if err != nil {
 		return nil, fmt.Errorf("layerstore manifest %s: %w", name, err)
 	}
@@ -204,29 +286,20 @@ func (s *Store) LoadManifest(name string) (*Manifest, error) {
 	if err := json.Unmarshal(b, &m); err != nil {
 		return nil, fmt.Errorf("layerstore manifest %s: %w", name, err)
 	}
+	if m.Chunking == "" {
+		m.Chunking = ChunkingFixed
+	}
 	return &m, nil
 }
 
 // DeleteManifest removes a manifest by name, releasing its layers for GC.
 func (s *Store) DeleteManifest(name string) error {
-	return os.Remove(filepath.Join(s.manifestDir(), name+".json"))
+	return s.backend.DeleteManifest(name)
 }
 
 // ListManifests returns manifest names (sorted).
 func (s *Store) ListManifests() ([]string, error) {
-	entries, err := os.ReadDir(s.manifestDir())
-	if err != nil {
-		return nil, err
-	}
-	var names []string
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		names = append(names, trimExt(e.Name()))
-	}
-	sort.Strings(names)
-	return names, nil
+	return s.backend.ListManifests()
 }
 
 // Delta computes which layers of want are missing from have. Useful for
@@ -248,81 +321,190 @@ func Delta(have, want *Manifest) []string {
 // GC removes layers not referenced by any saved manifest (lease-driven: a
 // snapshot's layers are its lease). Returns the number of bytes removed.
 func (s *Store) GC() (int64, error) {
-	referenced := s.referencedLayers()
+	return s.GCBefore(time.Now())
+}
 
-	entries, err := os.ReadDir(s.layerDir())
+// GCBefore is GC limited to layers last written before cutoff. A snapshot's
+// layers are stored before its manifest is published, so on a backend shared
+// by several nodes an unreferenced layer may belong to a snapshot still in
+// flight elsewhere; passing now minus the longest snapshot duration leaves
+// those alone. Put touches layers it dedups against, so this holds for
+// layers an in-flight snapshot re-uses as well as for those it uploads. Unreferenced layers are dropped from the local cache
+// regardless of age.
+func (s *Store) GCBefore(cutoff time.Time) (int64, error) {
+	referenced, err := s.referencedLayers()
+	if err != nil {
+		return 0, err
+	}
+	layers, err := s.backend.ListLayers()
 	if err != nil {
 		return 0, err
 	}
 	var removed int64
-	for _, e := range entries {
-		if e.IsDir() {
+	for _, l := range layers {
+		if referenced[l.Digest] || l.ModTime.After(cutoff) {
 			continue
 		}
-		name := e.Name()
-		if referenced[name] {
-			continue
+		if err := s.backend.DeleteLayer(l.Digest); err == nil {
+			removed += l.Size
 		}
-		if removedBytes, err := s.removeLayer(e, name); err == nil {
-			removed += removedBytes
+	}
+	if s.cache != nil {
+		if cached, err := s.cache.ListLayers(); err == nil {
+			for _, l := range cached {
+				if !referenced[l.Digest] {
+					_ = s.cache.DeleteLayer(l.Digest)
+				}
+			}
 		}
 	}
 	return removed, nil
 }
 
-// referencedLayers returns the set of layer digests leased by saved manifests.
-func (s *Store) referencedLayers() map[string]bool {
+// referencedLayers returns the set of layer digests leased by saved
+// manifests. A manifest that exists but cannot be read fails the walk: GC
+// must not reclaim layers it could not prove unreferenced.
+func (s *Store) referencedLayers() (map[string]bool, error) {
 	referenced := map[string]bool{}
 	names, err := s.ListManifests()
 	if err != nil {
-		return referenced
+		return nil, err
 	}
 	for _, n := range names {
 		m, err := s.LoadManifest(n)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // deleted since listing
+		}
 		if err != nil {
-			continue
+			return nil, err
 		}
 		for _, d := range m.Layers {
 			referenced[d] = true
 		}
 	}
-	return referenced
+	return referenced, nil
 }
 
-// removeLayer deletes one unreferenced layer entry, returning its size.
-func (s *Store) removeLayer(e os.DirEntry, name string) (int64, error) {
-	info, err := e.Info()
+// SizeBytes returns total bytes of all stored layers (for status surfacing).
+func (s *Store) SizeBytes() (int64, error) {
+	layers, err := s.backend.ListLayers()
 	if err != nil {
 		return 0, err
 	}
-	if err := os.Remove(filepath.Join(s.layerDir(), name)); err != nil {
-		return 0, err
+	var total int64
+	for _, l := range layers {
+		total += l.Size
 	}
-	return info.Size(), nil
+	return total, nil
 }
 
-// SizeBytes returns total bytes of all stored layers (for status surfacing).
-func (s *Store) SizeBytes() (int64, error) {
-	entries, err := os.ReadDir(s.layerDir())
+// Stats summarises the store: how much payload the manifests describe
+// versus what dedup leaves on disk.
+type Stats struct {
+	Manifests int   `json:"manifests"`
+	Layers    int   `json:"layers"`       // layer files on disk
+	Stored    int64 `json:"stored_bytes"` // on disk, compressed
+	// Logical is the summed payload size of every manifest; Unique counts
+	// each distinct referenced layer once (both uncompressed).
+	Logical int64 `json:"logical_bytes"`
+	Unique  int64 `json:"unique_bytes"`
+}
+
+// DedupRatio is Logical/Unique: 1 means no sharing, 10 means the manifests
+// describe ten times the bytes their distinct layers hold.
+func (st Stats) DedupRatio() float64 {
+	if st.Unique == 0 {
+		return 1
+	}
+	return float64(st.Logical) / float64(st.Unique)
+}
+
+// Stats walks manifests and layers. Layer sizes come from the zstd frame
+// header, so no layer is decompressed.
+func (s *Store) Stats() (Stats, error) {
+	var st Stats
+	layers, err := s.backend.ListLayers()
 	if err != nil {
-		return 0, err
+		return st, err
+	}
+	stored := make(map[string]int64, len(layers))
+	for _, l := range layers {
+		st.Layers++
+		st.Stored += l.Size
+		stored[l.Digest] = l.Size
+	}
+	names, err := s.ListManifests()
+	if err != nil {
+		return st, err
 	}
+	sizes := map[string]int64{}
+	for _, n := range names {
+		m, err := s.LoadManifest(n)
+		if err != nil {
+			continue
+		}
+		st.Manifests++
+		for _, d := range m.Layers {
+			size, ok := sizes[d]
+			if !ok {
+				onDisk, present := stored[d]
+				if !present {
+					continue // dangling reference
+				}
+				if size, err = s.layerSize(d, onDisk); err != nil {
+					continue
+				}
+				sizes[d] = size
+				st.Unique += size
+			}
+			st.Logical += size
+		}
+	}
+	return st, nil
+}
+
+// LogicalSize returns the uncompressed payload size of layers (what a
+// manifest over them restores to), reading only zstd frame headers.
+func (s *Store) LogicalSize(layers []string) (int64, error) {
 	var total int64
-	for _, e := range entries {
-		if info, err := e.Info(); err == nil {
-			total += info.Size()
+	for _, d := range layers {
+		size, err := s.layerSize(d, -1)
+		if err != nil {
+			return 0, fmt.Errorf("layerstore size %s: %w", d, err)
 		}
+		total += size
 	}
 	return total, nil
 }
 
-func (s *Store) layerDir() string   { return filepath.Join(s.dir, "layers") }
-func (s *Store) stagingDir() string { return filepath.Join(s.dir, "staging") }
-func (s *Store) manifestDir() string {
-	return filepath.Join(s.dir, "manifests")
-}
-func (s *Store) layerPath(digest string) string {
-	return filepath.Join(s.layerDir(), digest)
+// layerSize returns a layer's uncompressed size: the zstd frame content
+// size, or the stored size for legacy uncompressed layers (measured when
+// stored is negative).
+func (s *Store) layerSize(digest string, stored int64) (int64, error) {
+	var head []byte
+	var err error
+	if s.cache != nil {
+		head, err = s.cache.LayerHeader(digest, zstd.HeaderMaxSize)
+	}
+	if s.cache == nil || err != nil {
+		if head, err = s.backend.LayerHeader(digest, zstd.HeaderMaxSize); err != nil {
+			return 0, err
+		}
+	}
+	var h zstd.Header
+	if h.Decode(head) != nil {
+		if stored < 0 {
+			b, err := s.getLayer(digest)
+			return int64(len(b)), err
+		}
+		return stored, nil // legacy uncompressed layer
+	}
+	if h.HasFCS {
+		return int64(h.FrameContentSize), nil
+	}
+	// A zstd frame without a recorded size: decompress to measure.
+	b, err := s.Get(digest)
+	return int64(len(b)), err
 }
 
 // squash consolidates layers into a single layer containing their
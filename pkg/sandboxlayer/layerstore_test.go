@@ -2,9 +2,11 @@ package sandboxlayer
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestDigest_Deterministic(t *testing.T) {
@@ -200,7 +202,7 @@ func TestStore_SizeBytes(t *testing.T) {
 	}
 	// Layers are zstd-compressed on disk (KIP-16 M2); SizeBytes sums the
 	// on-disk files, so it matches the actual layer directory.
-	entries, err := os.ReadDir(s.layerDir())
+	entries, err := os.ReadDir(s.backend.(*FSBackend).layerDir())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -333,7 +335,7 @@ func TestStore_ZstdCompressedOnDisk(t *testing.T) {
 	if !bytes.Equal(got, content) {
 		t.Fatal("zstd round-trip mismatch")
 	}
-	info, err := os.Stat(s.layerPath(d))
+	info, err := os.Stat(s.backend.(*FSBackend).layerPath(d))
 	if err != nil {
 		t.Fatalf("stat layer: %v", err)
 	}
@@ -351,7 +353,7 @@ func TestStore_GetLegacyUncompressedLayer(t *testing.T) {
 	}
 	legacy := []byte("legacy uncompressed layer content")
 	d := Digest(legacy)
-	if err := os.WriteFile(s.layerPath(d), legacy, 0o600); err != nil {
+	if err := os.WriteFile(s.backend.(*FSBackend).layerPath(d), legacy, 0o600); err != nil {
 		t.Fatalf("write legacy layer: %v", err)
 	}
 	got, err := s.Get(d)
@@ -410,3 +412,134 @@ func TestStore_DeltaSharedChunks(t *testing.T) {
 		t.Fatalf("expected some shared chunks, missing=%d of %d", len(missing), len(nextLayers))
 	}
 }
+
+// TestStore_PutReaderStreams verifies PutReader chunks a stream exactly like
+// PutChunks and that an empty stream still yields one layer.
+func TestStore_PutReaderStreams(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	content := bytes.Repeat([]byte("k8e-layer-"), 1000)
+	want, err := s.PutChunks(content, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// iotest.OneByteReader forces short reads across chunk boundaries.
+	got, err := s.PutReader(iotest.OneByteReader(bytes.NewReader(content)), 4096)
+	if err != nil {
+		t.Fatalf("put reader: %v", err)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("stream chunks %v, want %v", got, want)
+	}
+	empty, err := s.PutReader(bytes.NewReader(nil), 4096)
+	if err != nil || len(empty) != 1 {
+		t.Fatalf("empty stream: layers=%v err=%v", empty, err)
+	}
+	if _, err := s.PutReader(iotest.ErrReader(errors.New("boom")), 4096); err == nil {
+		t.Fatal("expected read error to surface")
+	}
+}
+
+// TestStore_PublishManifestSkipsSquash verifies chunked manifests keep every
+// chunk past SquashThreshold.
+func TestStore_PublishManifestSkipsSquash(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	layers, err := s.PutChunks(bytes.Repeat([]byte("x"), SquashThreshold+8), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PublishManifest("chunked", Manifest{Layers: layers}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	m, err := s.LoadManifest("chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Layers) != SquashThreshold+8 {
+		t.Fatalf("expected %d layers, got %d", SquashThreshold+8, len(m.Layers))
+	}
+}
+
+// TestStore_StatsDedupRatio verifies Stats counts shared layers once and
+// reports logical/unique as the dedup ratio.
+func TestStore_StatsDedupRatio(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	layers, err := s.PutChunks(bytes.Repeat([]byte("abcd"), 256), 512) // 2 × 512 B
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := s.SaveManifest(name, layers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := s.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if st.Manifests != 3 || st.Logical != 3*1024 || st.Unique != 512 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if st.DedupRatio() != 6 {
+		t.Fatalf("dedup ratio %v, want 6", st.DedupRatio())
+	}
+}
+
+// TestStore_LegacyManifestIsFixed verifies manifests written before the
+// Chunking field load as fixed-size and still assemble.
+func TestStore_LegacyManifestIsFixed(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	d, err := s.Put([]byte("legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"schema_version":1,"layers":["` + d + `"]}`
+	if err := os.WriteFile(dir+"/manifests/old.json", []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.LoadManifest("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Chunking != ChunkingFixed || m.CDC != nil {
+		t.Fatalf("legacy manifest chunking %q", m.Chunking)
+	}
+	if got, err := s.Assemble(m.Layers); err != nil || string(got) != "legacy" {
+		t.Fatalf("assemble legacy: %q %v", got, err)
+	}
+}
+
+func TestStore_SaveManifestAsKeepsOwner(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := s.Put([]byte("abc"))
+	b, _ := s.Put([]byte("defgh"))
+	if err := s.SaveManifestAs("owned", Manifest{Layers: []string{a, b}, Owner: "team-a"}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.LoadManifest("owned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Owner != "team-a" {
+		t.Fatalf("owner = %q", m.Owner)
+	}
+	size, err := s.LogicalSize(m.Layers)
+	if err != nil || size != 8 {
+		t.Fatalf("logical size = %d, %v (want 8)", size, err)
+	}
+}
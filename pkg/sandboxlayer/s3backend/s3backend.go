@@ -0,0 +1,257 @@
+// Package s3backend stores sandbox snapshot layers and manifests in an
+// S3-compatible bucket (sandboxlayer.Backend), so a snapshot taken through one
+// gateway node can be restored through any other and outlives the node. The
+// client, endpoint CA and proxy handling are shared with etcd snapshot
+// uploads (pkg/etcd/s3/s3client).
+//
+// Objects:
+//
+//	<folder>/layers/<sha256>          immutable layers (zstd)
+//	<folder>/manifests/<name>.json    snapshot manifests
+//
+// S3 PUTs are atomic, so no staging prefix is needed.
+package s3backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/xiaods/k8e/pkg/etcd/s3/s3client"
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+)
+
+// DefaultTimeout bounds each S3 call when Config.Timeout is zero.
+const DefaultTimeout = 5 * time.Minute
+
+// Config selects the bucket and prefix holding the layer store.
+type Config struct {
+	s3client.Options
+	Bucket string
+	// Folder is an optional key prefix, so one bucket can hold several
+	// stores (or share a bucket with etcd snapshots).
+	Folder  string
+	Timeout time.Duration
+}
+
+// Backend is a sandboxlayer.Backend over an S3 bucket.
+type Backend struct {
+	mc      *minio.Client
+	bucket  string
+	folder  string
+	timeout time.Duration
+}
+
+var _ sandboxlayer.Backend = (*Backend)(nil)
+
+// New connects to the bucket in cfg, failing if it does not exist.
+func New(ctx context.Context, cfg Config) (*Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("layerstore s3: bucket name was not set")
+	}
+	mc, err := s3client.New(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("layerstore s3: %w", err)
+	}
+	b := &Backend{mc: mc, bucket: cfg.Bucket, folder: strings.Trim(cfg.Folder, "/"), timeout: cfg.Timeout}
+	if b.timeout <= 0 {
+		b.timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	exists, err := mc.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("layerstore s3: bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("layerstore s3: bucket %s does not exist", cfg.Bucket)
+	}
+	return b, nil
+}
+
+func (b *Backend) PutLayer(digest string, blob []byte) error {
+	return b.put(b.layerKey(digest), blob)
+}
+
+func (b *Backend) GetLayer(digest string) ([]byte, error) {
+	return b.get(b.layerKey(digest), minio.GetObjectOptions{})
+}
+
+func (b *Backend) LayerHeader(digest string, n int) ([]byte, error) {
+	var opts minio.GetObjectOptions
+	if err := opts.SetRange(0, int64(n)-1); err != nil {
+		return nil, err
+	}
+	head, err := b.get(b.layerKey(digest), opts)
+	if minio.ToErrorResponse(err).Code == "InvalidRange" {
+		return nil, nil // zero-length object
+	}
+	if len(head) > n {
+		head = head[:n] // endpoint ignored the range
+	}
+	return head, err
+}
+
+func (b *Backend) HasLayer(digest string) (bool, error) {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	_, err := b.mc.StatObject(ctx, b.bucket, b.layerKey(digest), minio.StatObjectOptions{})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// TouchLayer copies the layer onto itself: S3 has no utime, and a
+// metadata-replacing self-copy is the one way to reset LastModified.
+func (b *Backend) TouchLayer(digest string) (bool, error) {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	key := b.layerKey(digest)
+	_, err := b.mc.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: b.bucket, Object: key, ReplaceMetadata: true,
+			UserMetadata: map[string]string{"k8e-touched": time.Now().UTC().Format(time.RFC3339)}},
+		minio.CopySrcOptions{Bucket: b.bucket, Object: key})
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("layerstore s3 touch %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (b *Backend) DeleteLayer(digest string) error {
+	return b.remove(b.layerKey(digest))
+}
+
+func (b *Backend) ListLayers() ([]sandboxlayer.LayerInfo, error) {
+	var layers []sandboxlayer.LayerInfo
+	err := b.list(b.key("layers")+"/", func(name string, info minio.ObjectInfo) {
+		layers = append(layers, sandboxlayer.LayerInfo{Digest: name, Size: info.Size, ModTime: info.LastModified})
+	})
+	return layers, err
+}
+
+func (b *Backend) PutManifest(name string, doc []byte) error {
+	return b.put(b.manifestKey(name), doc)
+}
+
+func (b *Backend) GetManifest(name string) ([]byte, error) {
+	return b.get(b.manifestKey(name), minio.GetObjectOptions{})
+}
+
+func (b *Backend) DeleteManifest(name string) error {
+	// S3 deletes are idempotent; report a missing manifest like os.Remove.
+	ok, err := b.exists(b.manifestKey(name))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("layerstore s3 %s: %w", b.manifestKey(name), fs.ErrNotExist)
+	}
+	return b.remove(b.manifestKey(name))
+}
+
+func (b *Backend) ListManifests() ([]string, error) {
+	var names []string
+	err := b.list(b.key("manifests")+"/", func(name string, _ minio.ObjectInfo) {
+		if strings.HasSuffix(name, ".json") {
+			names = append(names, strings.TrimSuffix(name, ".json"))
+		}
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func (b *Backend) put(key string, data []byte) error {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	_, err := b.mc.PutObject(ctx, b.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		return fmt.Errorf("layerstore s3 put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *Backend) get(key string, opts minio.GetObjectOptions) ([]byte, error) {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	obj, err := b.mc.GetObject(ctx, b.bucket, key, opts)
+	if err == nil {
+		defer obj.Close()
+		var data []byte
+		if data, err = io.ReadAll(obj); err == nil {
+			return data, nil
+		}
+	}
+	if isNotFound(err) {
+		return nil, fmt.Errorf("layerstore s3 %s: %w", key, fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("layerstore s3 get %s: %w", key, err)
+}
+
+func (b *Backend) exists(key string) (bool, error) {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	_, err := b.mc.StatObject(ctx, b.bucket, key, minio.StatObjectOptions{})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Backend) remove(key string) error {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	if err := b.mc.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("layerstore s3 delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// list calls fn with the base name of every object directly under prefix.
+func (b *Backend) list(prefix string, fn func(name string, info minio.ObjectInfo)) error {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	for info := range b.mc.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if info.Err != nil {
+			return fmt.Errorf("layerstore s3 list %s: %w", prefix, info.Err)
+		}
+		name := strings.TrimPrefix(info.Key, prefix)
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		fn(name, info)
+	}
+	return nil
+}
+
+func (b *Backend) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.timeout)
+}
+
+func (b *Backend) key(elem ...string) string {
+	return path.Join(append([]string{b.folder}, elem...)...)
+}
+func (b *Backend) layerKey(digest string) string { return b.key("layers", digest) }
+func (b *Backend) manifestKey(name string) string {
+	return b.key("manifests", name+".json")
+}
+
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey"
+}
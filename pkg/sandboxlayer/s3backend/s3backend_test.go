@@ -0,0 +1,319 @@
+package s3backend
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiaods/k8e/pkg/etcd/s3/s3client"
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+)
+
+var gmt = time.FixedZone("GMT", 0)
+
+// fakeS3 is a path-style, single-bucket S3 endpoint: just enough of
+// HeadBucket, ListObjectsV2 and object GET/HEAD/PUT/DELETE for minio-go.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	mtimes  map[string]time.Time
+}
+
+type listResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Name     string
+	Prefix   string
+	KeyCount int
+	Contents []listEntry
+}
+
+type listEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+		case http.MethodGet:
+			prefix := r.URL.Query().Get("prefix")
+			res := listResult{Name: bucket, Prefix: prefix}
+			for k, v := range f.objects {
+				if strings.HasPrefix(k, prefix) {
+					res.Contents = append(res.Contents, listEntry{
+						Key: k, Size: len(v), ETag: `"0000"`,
+						LastModified: f.mtimes[k].UTC().Format(time.RFC3339),
+					})
+				}
+			}
+			sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+			res.KeyCount = len(res.Contents)
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(res)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			f.copyObject(w, src, key)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data = decodeAWSChunked(data)
+		}
+		f.objects[key] = data
+		f.mtimes[key] = time.Now()
+		w.Header().Set("ETag", `"0000"`)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprintf(w, "<Error><Code>NoSuchKey</Code><Key>%s</Key></Error>", key)
+			}
+			return
+		}
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Last-Modified", f.mtimes[key].In(gmt).Format(time.RFC1123))
+		w.Header().Set("ETag", `"0000"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// copyObject serves CopyObject; only the self-copy TouchLayer issues is
+// needed, but any source in the bucket works.
+func (f *fakeS3) copyObject(w http.ResponseWriter, src, key string) {
+	src, _ = url.PathUnescape(src)
+	_, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+	data, ok := f.objects[srcKey]
+	w.Header().Set("Content-Type", "application/xml")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "<Error><Code>NoSuchKey</Code><Key>%s</Key></Error>", srcKey)
+		return
+	}
+	f.objects[key] = data
+	f.mtimes[key] = time.Now()
+	fmt.Fprintf(w, "<CopyObjectResult><LastModified>%s</LastModified><ETag>\"0000\"</ETag></CopyObjectResult>",
+		f.mtimes[key].UTC().Format(time.RFC3339))
+}
+
+// decodeAWSChunked strips aws-chunked framing ("<hex>;chunk-signature=...\r\n
+// <data>\r\n"), which minio-go uses for signed uploads over plain HTTP.
+func decodeAWSChunked(body []byte) []byte {
+	var out []byte
+	for len(body) > 0 {
+		line, rest, ok := strings.Cut(string(body), "\r\n")
+		if !ok {
+			break
+		}
+		size, err := strconv.ParseInt(strings.SplitN(line, ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		out = append(out, rest[:size]...)
+		body = []byte(rest[size+2:])
+	}
+	return out
+}
+
+func newTestBackend(t *testing.T, folder string) (*Backend, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{bucket: "layers", objects: map[string][]byte{}, mtimes: map[string]time.Time{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	b, err := New(context.Background(), Config{
+		Options: s3client.Options{
+			Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+			Region:    "us-east-1",
+			AccessKey: "test",
+			SecretKey: "test",
+			Insecure:  true,
+		},
+		Bucket:  "layers",
+		Folder:  folder,
+		Timeout: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	return b, fake
+}
+
+func TestBackend_StoreRoundTrip(t *testing.T) {
+	b, fake := newTestBackend(t, "sandbox/")
+	s, err := sandboxlayer.NewWithBackend(b, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(strings.Repeat("workspace bytes ", 4096))
+	layers, err := s.PutCDC(strings.NewReader(string(payload)), sandboxlayer.CDCParams{Min: 1024, Avg: 4096, Max: 16384})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PublishManifest("v1", sandboxlayer.Manifest{Layers: layers, Chunking: sandboxlayer.ChunkingFastCDC}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["sandbox/manifests/v1.json"]; !ok {
+		t.Fatalf("manifest not stored under the folder prefix: %v", keys(fake))
+	}
+
+	// A second node with an empty cache restores from the bucket.
+	other, err := sandboxlayer.NewWithBackend(b, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := other.ListManifests()
+	if err != nil || len(names) != 1 || names[0] != "v1" {
+		t.Fatalf("list manifests: %v %v", names, err)
+	}
+	m, err := other.LoadManifest("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := other.Assemble(m.Layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(payload) {
+		t.Fatal("assembled payload mismatch")
+	}
+	st, err := other.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Manifests != 1 || st.Logical != int64(len(payload)) || st.Stored == 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+
+	if err := other.DeleteManifest("v1"); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := other.GC(); err != nil || removed == 0 {
+		t.Fatalf("gc: removed=%d err=%v", removed, err)
+	}
+	if left, _ := b.ListLayers(); len(left) != 0 {
+		t.Fatalf("layers left after gc: %v", left)
+	}
+}
+
+func TestBackend_MissingIsNotExist(t *testing.T) {
+	b, _ := newTestBackend(t, "")
+	if _, err := b.GetManifest("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("get manifest: %v", err)
+	}
+	if _, err := b.GetLayer("0000"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("get layer: %v", err)
+	}
+	if ok, err := b.HasLayer("0000"); ok || err != nil {
+		t.Fatalf("has layer: %v %v", ok, err)
+	}
+	if err := b.DeleteManifest("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("delete manifest: %v", err)
+	}
+}
+
+func TestBackend_LayerHeaderIsRanged(t *testing.T) {
+	b, _ := newTestBackend(t, "")
+	if err := b.PutLayer("abc", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	head, err := b.LayerHeader("abc", 4)
+	if err != nil || string(head) != "0123" {
+		t.Fatalf("header: %q %v", head, err)
+	}
+	head, err = b.LayerHeader("abc", 64)
+	if err != nil || string(head) != "0123456789" {
+		t.Fatalf("short layer header: %q %v", head, err)
+	}
+}
+
+func TestBackend_TouchLayer(t *testing.T) {
+	b, fake := newTestBackend(t, "")
+	if ok, err := b.TouchLayer("missing"); ok || err != nil {
+		t.Fatalf("touch missing layer: %v %v", ok, err)
+	}
+	if err := b.PutLayer("abc", []byte("blob")); err != nil {
+		t.Fatal(err)
+	}
+	fake.mtimes["layers/abc"] = time.Now().Add(-2 * time.Hour)
+	if ok, err := b.TouchLayer("abc"); !ok || err != nil {
+		t.Fatalf("touch layer: %v %v", ok, err)
+	}
+	layers, err := b.ListLayers()
+	if err != nil || len(layers) != 1 || layers[0].ModTime.Before(time.Now().Add(-time.Minute)) {
+		t.Fatalf("LastModified not refreshed: %+v %v", layers, err)
+	}
+	if got, _ := b.GetLayer("abc"); string(got) != "blob" {
+		t.Fatalf("touch changed the layer: %q", got)
+	}
+}
+
+func TestNew_MissingBucket(t *testing.T) {
+	fake := &fakeS3{bucket: "other"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	_, err := New(context.Background(), Config{
+		Options: s3client.Options{Endpoint: strings.TrimPrefix(srv.URL, "http://"), Region: "us-east-1", AccessKey: "a", SecretKey: "b", Insecure: true},
+		Bucket:  "layers",
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing bucket error, got %v", err)
+	}
+}
+
+func keys(f *fakeS3) []string {
+	var ks []string
+	for k := range f.objects {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
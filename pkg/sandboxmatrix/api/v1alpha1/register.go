@@ -25,6 +25,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&SandboxWarmPoolList{},
 		&SandboxTemplate{},
 		&SandboxTemplateList{},
+		&SandboxTenantQuota{},
+		&SandboxTenantQuotaList{},
+		&SandboxApproval{},
+		&SandboxApprovalList{},
+		&SandboxWebhook{},
+		&SandboxWebhookList{},
 	)
 	return nil
 }
@@ -45,6 +45,8 @@ type SandboxMatrixStatus struct {
 	ClaimedFromWarm   int64 `json:"claimedFromWarm,omitempty"`
 	ColdStarts        int64 `json:"coldStarts,omitempty"`
 	AvgClaimLatencyMs int64 `json:"avgClaimLatencyMs,omitempty"`
+	Forks             int64 `json:"forks,omitempty"`
+	AvgForkLatencyMs  int64 `json:"avgForkLatencyMs,omitempty"`
 }
 
 // +genclient
@@ -65,6 +67,37 @@ type SecretRef struct {
 	SecretName string `json:"secretName,omitempty"`
 	Key        string `json:"key,omitempty"`
 	EnvVar     string `json:"envVar,omitempty"`
+	// Hosts, when set, keeps the value out of the sandbox: EnvVar holds a
+	// placeholder that the egress proxy swaps for the value in requests to
+	// these hosts (exact or "*" patterns).
+	Hosts []string `json:"hosts,omitempty"`
+	// Header, with Hosts, is a request header (besides Authorization) the
+	// proxy may inject into, e.g. X-Api-Key.
+	Header string `json:"header,omitempty"`
+}
+
+// EgressRule allows egress to a host pattern or CIDR on the given ports,
+// optionally restricted to HTTP requests matching method and path. It
+// extends AllowedHosts, whose entries are exact hosts on TCP 443.
+type EgressRule struct {
+	// Host is an exact FQDN or a pattern with "*" wildcards (*.pypi.org).
+	// Exactly one of Host and CIDR is set.
+	Host string `json:"host,omitempty"`
+	// CIDR is an IP range (10.0.0.0/8); a bare IP is a /32 or /128.
+	CIDR string `json:"cidr,omitempty"`
+	// Ports defaults to 443.
+	Ports []int32 `json:"ports,omitempty"`
+	// Protocol is TCP (default), UDP or ANY.
+	Protocol string `json:"protocol,omitempty"`
+	// HTTP, when set, only admits matching requests (Cilium L7 rules).
+	HTTP []EgressHTTPRule `json:"http,omitempty"`
+}
+
+// EgressHTTPRule matches an HTTP request. Empty fields match anything; Path
+// may end in or contain "*" wildcards (/repos/*).
+type EgressHTTPRule struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
 }
 
 type SandboxSessionSpec struct {
@@ -78,6 +111,24 @@ type SandboxSessionSpec struct {
 	Env map[string]string `json:"env,omitempty"`
 	// SecretRefs are resolved from K8s Secrets at exec time; values are never stored here.
 	SecretRefs []SecretRef `json:"secretRefs,omitempty"`
+	// Template names the SandboxTemplate the session pod was built from.
+	// Empty means the SandboxConfig defaults (no template).
+	Template string `json:"template,omitempty"`
+	// Lineage is set on sessions created by ForkSession.
+	Lineage *SessionLineage `json:"lineage,omitempty"`
+	// EgressRules are allowed in addition to AllowedHosts.
+	EgressRules []EgressRule `json:"egressRules,omitempty"`
+}
+
+// SessionLineage records which session a forked session was cloned from.
+type SessionLineage struct {
+	// ForkedFrom is the parent session whose workspace was cloned.
+	ForkedFrom string `json:"forkedFrom"`
+	// ForkID groups the siblings created by one ForkSession call.
+	ForkID string `json:"forkID,omitempty"`
+	// ForkIndex numbers the siblings from 0.
+	ForkIndex int          `json:"forkIndex"`
+	ForkedAt  *metav1.Time `json:"forkedAt,omitempty"`
 }
 
 type SandboxSessionStatus struct {
@@ -87,6 +138,72 @@ type SandboxSessionStatus struct {
 	WorkspacePVC string        `json:"workspacePVC,omitempty"`
 	CreatedAt    *metav1.Time  `json:"createdAt,omitempty"`
 	ExpiresAt    *metav1.Time  `json:"expiresAt,omitempty"`
+	// Checkpoint is the process memory image saved by a memory pause; a
+	// resume restores from it, then clears it.
+	Checkpoint *SessionCheckpoint `json:"checkpoint,omitempty"`
+	// Usage is what the session has consumed so far; it is written to the
+	// usage ledger when the session ends.
+	Usage *SessionUsage `json:"usage,omitempty"`
+	// ExposedPorts are the in-pod service ports reachable through the
+	// gateway (KIP-24). The session CNP and the expose proxy are built
+	// from them, so they survive restarts and agree across replicas.
+	ExposedPorts []ExposedPort `json:"exposedPorts,omitempty"`
+}
+
+// ExposedPort is one gateway-proxied in-pod service port.
+type ExposedPort struct {
+	Port int32 `json:"port"`
+	// Host is the in-pod listen address recorded at expose time.
+	Host      string       `json:"host,omitempty"`
+	URL       string       `json:"url,omitempty"`
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// Access is who may reach the URL: public, token or signed. Empty
+	// (ports exposed before access control) is read as token, which no
+	// token matches: such a port must be exposed again to be reachable.
+	Access string `json:"access,omitempty"`
+	// TokenSHA256 is the hex SHA-256 of a token-mode port's token; the
+	// token itself is only returned to the caller that exposed the port.
+	TokenSHA256 string `json:"tokenSHA256,omitempty"`
+	// Nonce is drawn afresh for every exposure and bound into its signed
+	// links and cookies, so re-exposing a port revokes everything handed
+	// out for the previous exposure.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// SessionUsage accrues a session's resource consumption. CPU time and
+// egress come from the pod's cumulative kubelet counters, sampled
+// periodically; exec and snapshot figures are counted by the gateway.
+type SessionUsage struct {
+	CPUNanoSeconds  int64 `json:"cpuNanoSeconds,omitempty"`
+	MemoryPeakBytes int64 `json:"memoryPeakBytes,omitempty"`
+	MemoryAvgBytes  int64 `json:"memoryAvgBytes,omitempty"`
+	MemorySamples   int64 `json:"memorySamples,omitempty"`
+	// EgressBytes is the pod's transmitted network bytes.
+	EgressBytes      int64 `json:"egressBytes,omitempty"`
+	ExecCount        int64 `json:"execCount,omitempty"`
+	ExecMilliSeconds int64 `json:"execMilliSeconds,omitempty"`
+	SnapshotBytes    int64 `json:"snapshotBytes,omitempty"`
+	// LastPod and its counter readings at SampledAt are the base for the
+	// next sample's delta; a different pod (resume) starts a new base.
+	LastPod            string       `json:"lastPod,omitempty"`
+	LastCPUNanoSeconds int64        `json:"lastCPUNanoSeconds,omitempty"`
+	LastTxBytes        int64        `json:"lastTxBytes,omitempty"`
+	SampledAt          *metav1.Time `json:"sampledAt,omitempty"`
+}
+
+// SessionCheckpoint locates a paused session's memory checkpoint.
+type SessionCheckpoint struct {
+	// Manifest names the layer store manifest holding the checkpoint archive.
+	Manifest string `json:"manifest"`
+	// Node is where the checkpoint was taken; restore runs there.
+	Node         string       `json:"node"`
+	RuntimeClass string       `json:"runtimeClass,omitempty"`
+	SizeBytes    int64        `json:"sizeBytes,omitempty"`
+	CreatedAt    *metav1.Time `json:"createdAt,omitempty"`
+	// SandboxdKeyID is the checkpointed pod's sandboxd key id; the
+	// restored pod must carry it, since the restored sandboxd keeps the
+	// key it started with.
+	SandboxdKeyID string `json:"sandboxdKeyID,omitempty"`
 }
 
 type SandboxPhase string
@@ -137,7 +254,10 @@ type SandboxWarmPoolStatus struct {
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
-// SandboxTemplate defines the pod template used for sandbox sessions.
+// SandboxTemplate defines the pod template used for sandbox sessions. Sessions
+// select it by name (CreateSessionRequest.template) and warm pools by
+// templateRef; pods built from it carry the sandbox.k8e.io/template label so a
+// session only claims warm pods booted from the same template.
 type SandboxTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -146,10 +266,192 @@ type SandboxTemplate struct {
 }
 
 type SandboxTemplateSpec struct {
-	RuntimeClass   string              `json:"runtimeClass,omitempty"`
-	AllowedHosts   []string            `json:"allowedHosts,omitempty"`
+	// RuntimeClass overrides the default runtime (gvisor) for template pods.
+	RuntimeClass string `json:"runtimeClass,omitempty"`
+	// AllowedHosts is the egress allowlist for sessions that do not declare
+	// their own; it takes precedence over SandboxMatrix.defaultAllowedHosts.
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	// EgressRules, like AllowedHosts, apply to sessions that declare no
+	// egress of their own.
+	EgressRules []EgressRule `json:"egressRules,omitempty"`
+	// ResourceLimits (cpu/memory) override SandboxMatrix.resourceLimits.
 	ResourceLimits corev1.ResourceList `json:"resourceLimits,omitempty"`
-	Image          string              `json:"image,omitempty"`
+	// Image is the sandbox container image (e.g. a python-data or
+	// rust-toolchain build of k8e-sandbox). Empty means the default image.
+	Image string `json:"image,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxTenantQuota caps what one tenant may hold at once. CreateSession,
+// ExecBackground, ExposeService and the snapshot RPCs reject requests that
+// would exceed it with ResourceExhausted; the controller reports current
+// usage in status. Tenants without a quota are limited only by node capacity.
+type SandboxTenantQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SandboxTenantQuotaSpec   `json:"spec,omitempty"`
+	Status SandboxTenantQuotaStatus `json:"status,omitempty"`
+}
+
+// SandboxTenantQuotaSpec limits one tenant. A zero or unset limit means
+// unlimited.
+type SandboxTenantQuotaSpec struct {
+	// TenantID is the tenant the quota applies to: the API key name of
+	// certificate-authenticated callers, the tenant_id or x-sandbox-tenant
+	// header of loopback callers, "default" for callers with neither.
+	// Defaults to the quota's name.
+	TenantID string `json:"tenantID,omitempty"`
+	// MaxSessions caps concurrently live sessions.
+	MaxSessions int `json:"maxSessions,omitempty"`
+	// MaxBackgroundRuns caps registered background runs across all the
+	// tenant's sessions (the per-session cap still applies).
+	MaxBackgroundRuns int `json:"maxBackgroundRuns,omitempty"`
+	// MaxResources caps the summed cpu/memory limits of the tenant's
+	// session pods.
+	MaxResources corev1.ResourceList `json:"maxResources,omitempty"`
+	// MaxSnapshotBytes caps the uncompressed size of the tenant's snapshots
+	// in the server-side registry.
+	MaxSnapshotBytes int64 `json:"maxSnapshotBytes,omitempty"`
+	// MaxExposedPorts caps exposed service ports across the tenant's sessions.
+	MaxExposedPorts int `json:"maxExposedPorts,omitempty"`
+	// AllowedRuntimeClasses restricts the runtime classes the tenant's
+	// sessions may use. Empty allows any.
+	AllowedRuntimeClasses []string `json:"allowedRuntimeClasses,omitempty"`
+}
+
+// SandboxTenantQuotaStatus is the tenant's usage as last observed.
+type SandboxTenantQuotaStatus struct {
+	Sessions       int                 `json:"sessions,omitempty"`
+	BackgroundRuns int                 `json:"backgroundRuns,omitempty"`
+	Resources      corev1.ResourceList `json:"resources,omitempty"`
+	SnapshotBytes  int64               `json:"snapshotBytes,omitempty"`
+	ExposedPorts   int                 `json:"exposedPorts,omitempty"`
+	UpdatedAt      *metav1.Time        `json:"updatedAt,omitempty"`
+	// Reservations are admissions granted but not yet visible in cluster
+	// state. Gateways add them with resourceVersion-guarded status updates,
+	// which is what serializes admissions across replicas.
+	Reservations []QuotaReservation `json:"reservations,omitempty"`
+}
+
+// QuotaReservation holds quota for one admitted request until the session,
+// run, port or snapshot it admitted exists, or ExpiresAt passes.
+type QuotaReservation struct {
+	ID string `json:"id"`
+	// Kind is session, backgroundRun, exposedPort or snapshot.
+	Kind string `json:"kind"`
+	// CPU and Memory are a session's pod limits.
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	// Bytes is a snapshot's logical size.
+	Bytes     int64       `json:"bytes,omitempty"`
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxApproval is a human-in-the-loop gate for one agent action
+// (ConfirmAction). It outlives gateway restarts and is visible to every
+// gateway replica; decided approvals are kept as an audit record.
+type SandboxApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SandboxApprovalSpec   `json:"spec,omitempty"`
+	Status SandboxApprovalStatus `json:"status,omitempty"`
+}
+
+type SandboxApprovalSpec struct {
+	SessionID string `json:"sessionID"`
+	TenantID  string `json:"tenantID,omitempty"`
+	// Action is the agent's description of what it is about to do.
+	Action string `json:"action"`
+	// Requester is the identity (client certificate CN) that asked.
+	Requester string      `json:"requester,omitempty"`
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+type SandboxApprovalPhase string
+
+const (
+	SandboxApprovalPending  SandboxApprovalPhase = "Pending"
+	SandboxApprovalApproved SandboxApprovalPhase = "Approved"
+	SandboxApprovalDenied   SandboxApprovalPhase = "Denied"
+	SandboxApprovalExpired  SandboxApprovalPhase = "Expired"
+)
+
+type SandboxApprovalStatus struct {
+	Phase SandboxApprovalPhase `json:"phase,omitempty"`
+	// DecidedBy is the identity that approved or denied; empty for Expired.
+	DecidedBy string       `json:"decidedBy,omitempty"`
+	DecidedAt *metav1.Time `json:"decidedAt,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxWebhook subscribes an external endpoint (CI, chat-ops) to sandbox
+// lifecycle events. Every gateway replica POSTs signed JSON for the events
+// it observes; deliveries that exhaust their retries are recorded in status.
+type SandboxWebhook struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SandboxWebhookSpec   `json:"spec,omitempty"`
+	Status SandboxWebhookStatus `json:"status,omitempty"`
+}
+
+type SandboxWebhookSpec struct {
+	URL string `json:"url"`
+	// SecretRef names the Secret key (same namespace) holding the HMAC key.
+	// When set each delivery is signed: X-K8E-Signature: sha256=<hex> over
+	// X-K8E-Timestamp + "." + body.
+	SecretRef *WebhookSecretRef `json:"secretRef,omitempty"`
+	// Events filters by event type (session.created, session.destroyed,
+	// session.expired, run.completed, approval.requested). Empty means all.
+	Events []string `json:"events,omitempty"`
+	// TenantID, when set, limits the webhook to one tenant's sessions.
+	TenantID string              `json:"tenantID,omitempty"`
+	Retry    *WebhookRetryPolicy `json:"retry,omitempty"`
+	// Suspend stops deliveries without deleting the webhook.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+type WebhookSecretRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// WebhookRetryPolicy is an exponential backoff: attempt n waits
+// InitialBackoffSeconds * 2^(n-1), capped at MaxBackoffSeconds.
+type WebhookRetryPolicy struct {
+	// MaxAttempts includes the first try. Defaults to 5.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// InitialBackoffSeconds defaults to 1.
+	InitialBackoffSeconds int `json:"initialBackoffSeconds,omitempty"`
+	// MaxBackoffSeconds defaults to 60.
+	MaxBackoffSeconds int `json:"maxBackoffSeconds,omitempty"`
+}
+
+type SandboxWebhookStatus struct {
+	// FailedDeliveries counts events that were dead-lettered.
+	FailedDeliveries int64 `json:"failedDeliveries,omitempty"`
+	// DeadLetters holds the most recent undeliverable events, oldest first.
+	DeadLetters []WebhookDeadLetter `json:"deadLetters,omitempty"`
+}
+
+// WebhookDeadLetter is one event the gateway gave up delivering.
+type WebhookDeadLetter struct {
+	EventID   string      `json:"eventID"`
+	Type      string      `json:"type"`
+	SessionID string      `json:"sessionID,omitempty"`
+	Attempts  int         `json:"attempts"`
+	Error     string      `json:"error"`
+	FailedAt  metav1.Time `json:"failedAt"`
 }
 
 // Ensure resource package is used
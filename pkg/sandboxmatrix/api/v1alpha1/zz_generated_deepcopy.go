@@ -37,6 +37,30 @@ type SandboxTemplateList struct {
 	Items           []SandboxTemplate `json:"items"`
 }
 
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type SandboxTenantQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []SandboxTenantQuota `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type SandboxApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []SandboxApproval `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type SandboxWebhookList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []SandboxWebhook `json:"items"`
+}
+
 func (in *SandboxMatrix) DeepCopyObject() runtime.Object     { return in.DeepCopy() }
 func (in *SandboxMatrixList) DeepCopyObject() runtime.Object { return in.DeepCopy() }
 func (in *SandboxMatrix) DeepCopy() *SandboxMatrix {
@@ -97,6 +121,9 @@ func (in *SandboxSession) DeepCopyInto(out *SandboxSession) {
 }
 func (in *SecretRef) DeepCopyInto(out *SecretRef) {
 	*out = *in
+	if in.Hosts != nil {
+		out.Hosts = append([]string{}, in.Hosts...)
+	}
 }
 func (in *SecretRef) DeepCopy() *SecretRef {
 	if in == nil {
@@ -107,11 +134,42 @@ func (in *SecretRef) DeepCopy() *SecretRef {
 	return out
 }
 
+func (in *EgressRule) DeepCopyInto(out *EgressRule) {
+	*out = *in
+	if in.Ports != nil {
+		out.Ports = append([]int32{}, in.Ports...)
+	}
+	if in.HTTP != nil {
+		out.HTTP = append([]EgressHTTPRule{}, in.HTTP...)
+	}
+}
+func (in *EgressRule) DeepCopy() *EgressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// deepCopyEgressRules copies rules and their nested slices.
+func deepCopyEgressRules(in []EgressRule) []EgressRule {
+	if in == nil {
+		return nil
+	}
+	out := make([]EgressRule, len(in))
+	for i := range in {
+		in[i].DeepCopyInto(&out[i])
+	}
+	return out
+}
+
 func (in *SandboxSessionSpec) DeepCopyInto(out *SandboxSessionSpec) {
 	*out = *in
 	if in.AllowedHosts != nil {
 		out.AllowedHosts = append([]string{}, in.AllowedHosts...)
 	}
+	out.EgressRules = deepCopyEgressRules(in.EgressRules)
 	if in.Env != nil {
 		out.Env = make(map[string]string, len(in.Env))
 		for k, v := range in.Env {
@@ -120,9 +178,28 @@ func (in *SandboxSessionSpec) DeepCopyInto(out *SandboxSessionSpec) {
 	}
 	if in.SecretRefs != nil {
 		out.SecretRefs = make([]SecretRef, len(in.SecretRefs))
-		copy(out.SecretRefs, in.SecretRefs)
+		for i := range in.SecretRefs {
+			in.SecretRefs[i].DeepCopyInto(&out.SecretRefs[i])
+		}
+	}
+	if in.Lineage != nil {
+		out.Lineage = in.Lineage.DeepCopy()
+	}
+}
+func (in *SessionLineage) DeepCopyInto(out *SessionLineage) {
+	*out = *in
+	if in.ForkedAt != nil {
+		out.ForkedAt = in.ForkedAt.DeepCopy()
 	}
 }
+func (in *SessionLineage) DeepCopy() *SessionLineage {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionLineage)
+	in.DeepCopyInto(out)
+	return out
+}
 func (in *SandboxSessionStatus) DeepCopyInto(out *SandboxSessionStatus) {
 	*out = *in
 	if in.CreatedAt != nil {
@@ -133,6 +210,60 @@ func (in *SandboxSessionStatus) DeepCopyInto(out *SandboxSessionStatus) {
 		t := in.ExpiresAt.DeepCopy()
 		out.ExpiresAt = t
 	}
+	if in.Checkpoint != nil {
+		out.Checkpoint = in.Checkpoint.DeepCopy()
+	}
+	if in.Usage != nil {
+		out.Usage = in.Usage.DeepCopy()
+	}
+	if in.ExposedPorts != nil {
+		out.ExposedPorts = make([]ExposedPort, len(in.ExposedPorts))
+		for i := range in.ExposedPorts {
+			in.ExposedPorts[i].DeepCopyInto(&out.ExposedPorts[i])
+		}
+	}
+}
+func (in *ExposedPort) DeepCopyInto(out *ExposedPort) {
+	*out = *in
+	if in.StartedAt != nil {
+		out.StartedAt = in.StartedAt.DeepCopy()
+	}
+}
+func (in *ExposedPort) DeepCopy() *ExposedPort {
+	if in == nil {
+		return nil
+	}
+	out := new(ExposedPort)
+	in.DeepCopyInto(out)
+	return out
+}
+func (in *SessionUsage) DeepCopyInto(out *SessionUsage) {
+	*out = *in
+	if in.SampledAt != nil {
+		out.SampledAt = in.SampledAt.DeepCopy()
+	}
+}
+func (in *SessionUsage) DeepCopy() *SessionUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+func (in *SessionCheckpoint) DeepCopyInto(out *SessionCheckpoint) {
+	*out = *in
+	if in.CreatedAt != nil {
+		out.CreatedAt = in.CreatedAt.DeepCopy()
+	}
+}
+func (in *SessionCheckpoint) DeepCopy() *SessionCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionCheckpoint)
+	in.DeepCopyInto(out)
+	return out
 }
 func (in *SandboxSessionList) DeepCopy() *SandboxSessionList {
 	if in == nil {
@@ -202,6 +333,7 @@ func (in *SandboxTemplateSpec) DeepCopyInto(out *SandboxTemplateSpec) {
 	if in.AllowedHosts != nil {
 		out.AllowedHosts = append([]string{}, in.AllowedHosts...)
 	}
+	out.EgressRules = deepCopyEgressRules(in.EgressRules)
 	if in.ResourceLimits != nil {
 		in.ResourceLimits.DeepCopyInto(&out.ResourceLimits)
 	}
@@ -220,3 +352,145 @@ func (in *SandboxTemplateList) DeepCopy() *SandboxTemplateList {
 	}
 	return out
 }
+
+func (in *SandboxTenantQuota) DeepCopyObject() runtime.Object     { return in.DeepCopy() }
+func (in *SandboxTenantQuotaList) DeepCopyObject() runtime.Object { return in.DeepCopy() }
+func (in *SandboxTenantQuota) DeepCopy() *SandboxTenantQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxTenantQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+func (in *SandboxTenantQuota) DeepCopyInto(out *SandboxTenantQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+func (in *SandboxTenantQuotaSpec) DeepCopyInto(out *SandboxTenantQuotaSpec) {
+	*out = *in
+	if in.MaxResources != nil {
+		in.MaxResources.DeepCopyInto(&out.MaxResources)
+	}
+	if in.AllowedRuntimeClasses != nil {
+		out.AllowedRuntimeClasses = append([]string{}, in.AllowedRuntimeClasses...)
+	}
+}
+func (in *SandboxTenantQuotaStatus) DeepCopyInto(out *SandboxTenantQuotaStatus) {
+	*out = *in
+	if in.Resources != nil {
+		in.Resources.DeepCopyInto(&out.Resources)
+	}
+	if in.UpdatedAt != nil {
+		out.UpdatedAt = in.UpdatedAt.DeepCopy()
+	}
+	if in.Reservations != nil {
+		out.Reservations = make([]QuotaReservation, len(in.Reservations))
+		for i := range in.Reservations {
+			in.Reservations[i].DeepCopyInto(&out.Reservations[i])
+		}
+	}
+}
+func (in *QuotaReservation) DeepCopyInto(out *QuotaReservation) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+}
+func (in *SandboxTenantQuotaList) DeepCopy() *SandboxTenantQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxTenantQuotaList)
+	*out = *in
+	if in.Items != nil {
+		out.Items = make([]SandboxTenantQuota, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
+
+func (in *SandboxApproval) DeepCopyObject() runtime.Object     { return in.DeepCopy() }
+func (in *SandboxApprovalList) DeepCopyObject() runtime.Object { return in.DeepCopy() }
+func (in *SandboxApproval) DeepCopy() *SandboxApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+func (in *SandboxApproval) DeepCopyInto(out *SandboxApproval) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.ExpiresAt.DeepCopyInto(&out.Spec.ExpiresAt)
+	if in.Status.DecidedAt != nil {
+		out.Status.DecidedAt = in.Status.DecidedAt.DeepCopy()
+	}
+}
+func (in *SandboxApprovalList) DeepCopy() *SandboxApprovalList {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxApprovalList)
+	*out = *in
+	if in.Items != nil {
+		out.Items = make([]SandboxApproval, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
+
+func (in *SandboxWebhook) DeepCopyObject() runtime.Object     { return in.DeepCopy() }
+func (in *SandboxWebhookList) DeepCopyObject() runtime.Object { return in.DeepCopy() }
+func (in *SandboxWebhook) DeepCopy() *SandboxWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+func (in *SandboxWebhook) DeepCopyInto(out *SandboxWebhook) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Spec.SecretRef != nil {
+		ref := *in.Spec.SecretRef
+		out.Spec.SecretRef = &ref
+	}
+	if in.Spec.Events != nil {
+		out.Spec.Events = append([]string(nil), in.Spec.Events...)
+	}
+	if in.Spec.Retry != nil {
+		retry := *in.Spec.Retry
+		out.Spec.Retry = &retry
+	}
+	if in.Status.DeadLetters != nil {
+		out.Status.DeadLetters = make([]WebhookDeadLetter, len(in.Status.DeadLetters))
+		for i := range in.Status.DeadLetters {
+			out.Status.DeadLetters[i] = in.Status.DeadLetters[i]
+			in.Status.DeadLetters[i].FailedAt.DeepCopyInto(&out.Status.DeadLetters[i].FailedAt)
+		}
+	}
+}
+func (in *SandboxWebhookList) DeepCopy() *SandboxWebhookList {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxWebhookList)
+	*out = *in
+	if in.Items != nil {
+		out.Items = make([]SandboxWebhook, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
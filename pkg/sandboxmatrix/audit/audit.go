@@ -0,0 +1,296 @@
+// Package audit writes the sandbox gateway's append-only audit trail: one
+// NDJSON record per gRPC call or E2B HTTP request, with size/age-based
+// rotation and age-based retention.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes rotates the active file once it reaches 64 MiB.
+	DefaultMaxBytes = 64 << 20
+	// DefaultRotateEvery rotates the active file at least daily so retention
+	// can drop whole days.
+	DefaultRotateEvery = 24 * time.Hour
+	// DefaultRetention keeps rotated files for 90 days.
+	DefaultRetention = 90 * 24 * time.Hour
+
+	fileExt = ".ndjson"
+	// rotatedLayout names rotated files; it sorts chronologically.
+	rotatedLayout = "20060102T150405.000000000Z"
+)
+
+// Record is one audited call.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Source is "grpc" for gateway RPCs and "e2b" for the E2B HTTP surface.
+	Source string `json:"source"`
+	// Caller is the API key name (client cert CN), "local" for loopback
+	// callers without a cert, an e2b key fingerprint, or "anonymous".
+	Caller string `json:"caller"`
+	// Fingerprint is the SHA-256 of the client certificate, when presented.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Tenant      string `json:"tenant,omitempty"`
+	Session     string `json:"session,omitempty"`
+	Method      string `json:"method"`
+	// Outcome is the gRPC status code name or the HTTP status code.
+	Outcome   string `json:"outcome"`
+	LatencyMS int64  `json:"latency_ms"`
+	// Target is Hash() of the command or path the call acted on; the
+	// plaintext is never written.
+	Target string `json:"target,omitempty"`
+}
+
+// Hash returns the redacted form of a command or path as stored in
+// Record.Target ("" for an empty value).
+func Hash(v string) string {
+	if v == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(sum[:16])
+}
+
+// Options configures a Log.
+type Options struct {
+	// Dir holds the active and rotated files (created 0700).
+	Dir string
+	// Prefix names the files, e.g. "grpc" → grpc.ndjson and
+	// grpc-<time>.ndjson. Writers sharing a Dir must use distinct prefixes.
+	Prefix string
+	// MaxBytes rotates the active file when a write would exceed it
+	// (zero → DefaultMaxBytes).
+	MaxBytes int64
+	// RotateEvery rotates the active file once it is this old
+	// (zero → DefaultRotateEvery).
+	RotateEvery time.Duration
+	// Retention deletes rotated files older than this
+	// (zero → DefaultRetention; negative keeps everything).
+	Retention time.Duration
+}
+
+// Log is an append-only NDJSON audit writer. It is safe for concurrent use.
+type Log struct {
+	opts Options
+	now  func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// Open opens (or creates) the active audit file under opts.Dir.
+func Open(opts Options) (*Log, error) {
+	if opts.Dir == "" || opts.Prefix == "" {
+		return nil, fmt.Errorf("audit: dir and prefix are required")
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.RotateEvery <= 0 {
+		opts.RotateEvery = DefaultRotateEvery
+	}
+	if opts.Retention == 0 {
+		opts.Retention = DefaultRetention
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	l := &Log{opts: opts, now: time.Now}
+	if err := l.openActive(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) activePath() string {
+	return filepath.Join(l.opts.Dir, l.opts.Prefix+fileExt)
+}
+
+func (l *Log) openActive() error {
+	f, err := os.OpenFile(l.activePath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("audit: %w", err)
+	}
+	l.f, l.size, l.opened = f, st.Size(), st.ModTime()
+	if l.size == 0 {
+		l.opened = l.now()
+	}
+	return nil
+}
+
+// Write appends r as one line, rotating first when the active file is full
+// or too old. A zero r.Time is set to now.
+func (l *Log) Write(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = l.now()
+	}
+	return l.Append(r)
+}
+
+// Append writes v as one JSON line with the same rotation as Write. Other
+// append-only ledgers (sandbox usage) share the writer this way.
+func (l *Log) Append(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	now := l.now()
+	if l.size > 0 && (l.size+int64(len(line)) > l.opts.MaxBytes || now.Sub(l.opened) >= l.opts.RotateEvery) {
+		if err := l.rotate(now); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate renames the active file aside, reopens a fresh one and applies
+// retention. Callers hold l.mu.
+func (l *Log) rotate(now time.Time) error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	rotated := filepath.Join(l.opts.Dir, l.opts.Prefix+"-"+now.UTC().Format(rotatedLayout)+fileExt)
+	if err := os.Rename(l.activePath(), rotated); err != nil {
+		return fmt.Errorf("audit: rotate: %w", err)
+	}
+	if err := l.openActive(); err != nil {
+		return err
+	}
+	l.prune(now)
+	return nil
+}
+
+// prune deletes this writer's rotated files older than the retention.
+func (l *Log) prune(now time.Time) {
+	if l.opts.Retention < 0 {
+		return
+	}
+	rotated, _ := filepath.Glob(filepath.Join(l.opts.Dir, l.opts.Prefix+"-*"+fileExt))
+	for _, p := range rotated {
+		if st, err := os.Stat(p); err == nil && now.Sub(st.ModTime()) > l.opts.Retention {
+			_ = os.Remove(p)
+		}
+	}
+}
+
+// Close closes the active file; later writes return os.ErrClosed.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Filter selects records in Query. Zero fields match everything.
+type Filter struct {
+	Since, Until time.Time
+	Source       string
+	Caller       string
+	Tenant       string
+	Session      string
+	// Method matches as a substring, so "Exec" finds Exec and ExecStream.
+	Method string
+	// Target is a plaintext command or path; it is hashed before matching.
+	Target string
+	// Limit keeps only the newest Limit matches (zero → all).
+	Limit int
+}
+
+func (f Filter) match(r Record) bool {
+	switch {
+	case !f.Since.IsZero() && r.Time.Before(f.Since),
+		!f.Until.IsZero() && r.Time.After(f.Until),
+		f.Source != "" && r.Source != f.Source,
+		f.Caller != "" && r.Caller != f.Caller,
+		f.Tenant != "" && r.Tenant != f.Tenant,
+		f.Session != "" && r.Session != f.Session,
+		f.Method != "" && !strings.Contains(r.Method, f.Method),
+		f.Target != "" && r.Target != Hash(f.Target):
+		return false
+	}
+	return true
+}
+
+// Query reads every audit file in dir (active and rotated, all prefixes)
+// and returns the matching records, oldest first. Malformed lines — e.g. a
+// torn final write — are skipped.
+func Query(dir string, f Filter) ([]Record, error) {
+	var out []Record
+	err := ReadLines(dir, func(line []byte) {
+		var r Record
+		if json.Unmarshal(line, &r) == nil && f.match(r) {
+			out = append(out, r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out, nil
+}
+
+// ReadLines calls fn for every line of every NDJSON file in dir, file by
+// file. fn must not retain the slice.
+func ReadLines(dir string, fn func(line []byte)) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	if err != nil {
+		return err
+	}
+	for _, p := range files {
+		if err := scanFile(p, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, fn func(line []byte)) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) { // rotated away mid-query
+			return nil
+		}
+		return err
+	}
+	defer fh.Close()
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		fn(sc.Bytes())
+	}
+	return sc.Err()
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_RotatesBySizeAndAge(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir, Prefix: "grpc", MaxBytes: 300, RotateEvery: time.Hour, Retention: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	l.opened = now
+
+	for i := 0; i < 3; i++ {
+		if err := l.Write(Record{Source: "grpc", Caller: "agent-a", Method: "/sandbox.v1.SandboxService/Exec", Outcome: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Millisecond)
+	}
+	rotated, _ := filepath.Glob(filepath.Join(dir, "grpc-*.ndjson"))
+	if len(rotated) == 0 {
+		t.Fatal("expected a size rotation")
+	}
+
+	before := len(rotated)
+	now = now.Add(2 * time.Hour)
+	if err := l.Write(Record{Method: "late"}); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ = filepath.Glob(filepath.Join(dir, "grpc-*.ndjson"))
+	if len(rotated) != before+1 {
+		t.Fatalf("expected an age rotation: %d → %d files", before, len(rotated))
+	}
+	recs, err := Query(dir, Filter{})
+	if err != nil || len(recs) != 4 {
+		t.Fatalf("records survive rotation: %d %v", len(recs), err)
+	}
+}
+
+func TestLog_RetentionPrunesOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "grpc-20260101T000000.000000000Z.ndjson")
+	other := filepath.Join(dir, "e2b-20260101T000000.000000000Z.ndjson")
+	for _, p := range []string{old, other} {
+		if err := os.WriteFile(p, []byte("{}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		stale := time.Now().Add(-48 * time.Hour)
+		if err := os.Chtimes(p, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := Open(Options{Dir: dir, Prefix: "grpc", MaxBytes: 1, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_ = l.Write(Record{Method: "a"})
+	_ = l.Write(Record{Method: "b"}) // rotates, then prunes
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expired grpc file kept: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("another writer's file pruned: %v", err)
+	}
+}
+
+func TestQuery_Filters(t *testing.T) {
+	dir := t.TempDir()
+	grpcLog, _ := Open(Options{Dir: dir, Prefix: "grpc"})
+	e2bLog, _ := Open(Options{Dir: dir, Prefix: "e2b"})
+	defer grpcLog.Close()
+	defer e2bLog.Close()
+	base := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	_ = grpcLog.Write(Record{Time: base, Source: "grpc", Caller: "agent-a", Tenant: "team-a", Session: "s1", Method: "/sandbox.v1.SandboxService/Exec", Target: Hash("rm -rf /tmp/x")})
+	_ = grpcLog.Write(Record{Time: base.Add(time.Minute), Source: "grpc", Caller: "agent-b", Session: "s2", Method: "/sandbox.v1.SandboxService/ExecStream"})
+	_ = e2bLog.Write(Record{Time: base.Add(2 * time.Minute), Source: "e2b", Caller: "key:abc", Session: "s1", Method: "POST /sandboxes"})
+
+	cases := []struct {
+		name string
+		f    Filter
+		want int
+	}{
+		{"all", Filter{}, 3},
+		{"session", Filter{Session: "s1"}, 2},
+		{"method substring", Filter{Method: "Exec"}, 2},
+		{"source", Filter{Source: "e2b"}, 1},
+		{"target hashed", Filter{Target: "rm -rf /tmp/x"}, 1},
+		{"since", Filter{Since: base.Add(30 * time.Second)}, 2},
+		{"limit keeps newest", Filter{Limit: 1}, 1},
+	}
+	for _, c := range cases {
+		got, err := Query(dir, c.f)
+		if err != nil || len(got) != c.want {
+			t.Errorf("%s: got %d records (%v), want %d", c.name, len(got), err, c.want)
+		}
+	}
+	got, _ := Query(dir, Filter{Limit: 1})
+	if got[0].Source != "e2b" {
+		t.Fatalf("limit should keep the newest record, got %+v", got[0])
+	}
+}
+
+func TestHash_RedactsPlaintext(t *testing.T) {
+	h := Hash("cat /secrets/token")
+	if !strings.HasPrefix(h, "sha256:") || strings.Contains(h, "secrets") || h != Hash("cat /secrets/token") {
+		t.Fatalf("unexpected hash %q", h)
+	}
+	if Hash("") != "" {
+		t.Fatal("empty value should hash to empty")
+	}
+}
@@ -3,6 +3,7 @@ package sandboxmatrix
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"strconv"
 	"sync"
@@ -20,6 +21,11 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/xiaods/k8e/pkg/daemons/config"
+	"github.com/xiaods/k8e/pkg/etcd/s3/s3client"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	"github.com/xiaods/k8e/pkg/sandboxlayer/s3backend"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
 	sandboxgrpc "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc"
 )
 
@@ -54,6 +60,25 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 	if err != nil {
 		return err
 	}
+	tracingCfg := tracing.Config{
+		ServiceName: "k8e-sandbox-gateway",
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		File:        cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
+	}
+	if err := setupTracing(ctx, tracingCfg); err != nil {
+		logrus.Warnf("sandbox: tracing disabled: %v", err)
+	} else if tracingCfg.Enabled() {
+		// Trace the gateway's Kubernetes API calls as children of the RPC
+		// (or claim/CNP span) that made them.
+		restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return tracing.Transport(rt, "kube-apiserver")
+		})
+		if k8s, err = kubernetes.NewForConfig(restConfig); err != nil {
+			return err
+		}
+	}
 
 	dyn, err := dynamic.NewForConfig(restConfig)
 	if err != nil {
@@ -63,7 +88,10 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 	// refillTrigger wakes the warm pool reconciler immediately after a warm pod
 	// claim, instead of waiting up to 10s for the next poll tick.
 	refillTrigger := make(chan struct{}, 1)
+	go enableSandboxdAuth(ctx, k8s)
+	podStats := sandboxgrpc.KubeletPodStats(k8s)
 	orch := sandboxgrpc.NewOrchestrator(k8s, dyn)
+	orch.SetPodStatsSource(podStats)
 	orch.OnWarmClaim = func() {
 		select {
 		case refillTrigger <- struct{}{}:
@@ -76,6 +104,10 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 	// GC) are NOT safe to run on every HA control-plane node — two servers
 	// reconciling the same pool would double-create/double-GC/double-reap —
 	// so they run only on the leader elected via a coordination Lease.
+	layerBackend, err := layerStoreBackend(ctx, cfg)
+	if err != nil {
+		return err
+	}
 	srv := sandboxgrpc.NewServer(sandboxgrpc.ServerConfig{
 		K8s:               k8s,
 		Dyn:               dyn,
@@ -85,10 +117,28 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 		ServerKeyFile:     tlsDir + "/sandbox-server.key",
 		GRPCPort:          cfg.GRPCPort,
 		LayerStoreDir:     cfg.LayerStoreDir,
+		LayerStoreBackend: layerBackend,
 		FQDNEnabled:       cfg.CiliumDNSProxyEnabled,
+		EgressProxyPort:   cfg.EgressProxyPort,
+		NodeName:          cfg.NodeName,
 		AdvertiseHostname: cfg.AdvertiseHostname,
 		ExposeBaseURL:     cfg.ExposeBaseURL,
+		ExposeDomain:      cfg.ExposeDomain,
+		ApprovalWebhook: sandboxgrpc.ApprovalWebhook{
+			URL:    cfg.ApprovalWebhookURL,
+			Secret: cfg.ApprovalWebhookSecret,
+		},
+		AuditDir:                 cfg.AuditDir,
+		AuditRetention:           cfg.AuditRetention,
+		UsageDir:                 cfg.UsageDir,
+		MetricsMaxTenants:        cfg.MetricsMaxTenants,
+		PodStats:                 podStats,
+		CheckpointRuntimeClasses: cfg.CheckpointRuntimeClasses,
 	})
+	// Sessions expired by the GC loop are recorded in the same ledger.
+	if l := srv.UsageLedger(); l != nil {
+		orch.SetUsageLedger(l)
+	}
 	go func() {
 		if err := srv.Start(ctx); err != nil {
 			logrus.Errorf("sandbox gRPC gateway: %v", err)
@@ -102,6 +152,9 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 		go runResettingDetector(leaderCtx, k8s, cfg.Namespace)
 		go runIdlePodReaper(leaderCtx, k8s, dyn, cfg)
 		go runGCLoop(leaderCtx, orch, cfg.Namespace)
+		go runLayerGCLoop(leaderCtx, srv)
+		go runQuotaStatusLoop(leaderCtx, srv)
+		go runUsageSampleLoop(leaderCtx, srv)
 	})
 
 	if _, err := os.Stat("/dev/kvm"); err == nil {
@@ -115,6 +168,44 @@ func Register(ctx context.Context, k8s kubernetes.Interface, kubeconfig string,
 	return nil
 }
 
+// setupTracing installs the process-wide tracer provider (shared with the
+// embedded E2B server) and flushes it when ctx ends.
+func setupTracing(ctx context.Context, cfg tracing.Config) error {
+	shutdown, err := tracing.Setup(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(flushCtx); err != nil {
+			logrus.Warnf("sandbox: flush traces: %v", err)
+		}
+	}()
+	return nil
+}
+
+// enableSandboxdAuth loads the shared sandboxd master key, retrying until the
+// sandbox-matrix namespace exists. Until then new pods are unkeyed and
+// gateway → sandboxd traffic is unauthenticated, as before.
+func enableSandboxdAuth(ctx context.Context, k8s kubernetes.Interface) {
+	for {
+		keys, err := sandboxgrpc.LoadSandboxdKeyring(ctx, k8s)
+		if err == nil {
+			sandboxgrpc.EnableSandboxdAuth(keys)
+			logrus.Info("sandbox-matrix: sandboxd request authentication enabled")
+			return
+		}
+		logrus.Debugf("sandbox-matrix: sandboxd auth key not loaded yet: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
 func runWarmPoolReconciler(ctx context.Context, k8s kubernetes.Interface, dyn dynamic.Interface, cfg config.SandboxConfig, refill <-chan struct{}, orch *sandboxgrpc.Orchestrator) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -144,12 +235,42 @@ func reconcileWarmPools(ctx context.Context, k8s kubernetes.Interface, dyn dynam
 		boost = demand.observe(time.Now(), coldStarts)
 	}
 	for _, pool := range pools.Items {
-		reconcileSinglePool(ctx, k8s, pool, maxPods, cfg, boost)
+		reconcileSinglePool(ctx, k8s, dyn, pool, maxPods, cfg, boost)
 	}
+	reportWarmPools(ctx, k8s, cfg.Namespace, pools.Items)
 	recycleUnhealthyWarmPods(ctx, k8s, cfg.Namespace)
 	updateSandboxMatrixStatus(ctx, k8s, dyn, cfg, orch)
 }
 
+// reportWarmPools publishes each pool's ready and pending warm pods to the
+// sandbox metrics. A pool's pods are the warm pods built from its template.
+func reportWarmPools(ctx context.Context, k8s kubernetes.Interface, namespace string, pools []unstructured.Unstructured) {
+	pods, err := k8s.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: sandboxgrpc.LabelState + "=" + sandboxgrpc.StateWarm,
+	})
+	if err != nil {
+		return
+	}
+	counts := make(map[string]sandboxgrpc.WarmPoolCount, len(pools))
+	for _, pool := range pools {
+		templateName, _, _ := unstructured.NestedString(pool.Object, "spec", "templateRef", "name")
+		var c sandboxgrpc.WarmPoolCount
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Labels[sandboxgrpc.LabelState] != sandboxgrpc.StateWarm || pod.Labels[sandboxgrpc.LabelTemplate] != templateName {
+				continue
+			}
+			if pod.Status.Phase == corev1.PodRunning && sandboxgrpc.PodReadyCondition(pod) {
+				c.Ready++
+			} else {
+				c.Pending++
+			}
+		}
+		counts[pool.GetName()] = c
+	}
+	sandboxgrpc.ReportWarmPools(counts)
+}
+
 // demandDecayWindow is how long without a cold start before the adaptive pool
 // target decays back toward MinSize.
 const demandDecayWindow = 5 * time.Minute
@@ -224,10 +345,34 @@ func deleteWarmPod(ctx context.Context, k8s kubernetes.Interface, namespace, nam
 }
 
 // reconcileSinglePool ensures one WarmPool CRD's target is met within capacity limits.
-func reconcileSinglePool(ctx context.Context, k8s kubernetes.Interface, pool unstructured.Unstructured, maxPods int64, cfg config.SandboxConfig, boost int64) {
+// A pool with spec.templateRef builds its pods from that SandboxTemplate and
+// counts only warm pods carrying the same template label toward its target.
+func reconcileSinglePool(ctx context.Context, k8s kubernetes.Interface, dyn dynamic.Interface, pool unstructured.Unstructured, maxPods int64, cfg config.SandboxConfig, boost int64) {
 	specMap, _ := pool.Object["spec"].(map[string]interface{})
 	configuredSize, _ := specMap["size"].(int64)
+	templateName, _, _ := unstructured.NestedString(pool.Object, "spec", "templateRef", "name")
+	var tmpl *sandboxv1.SandboxTemplate
+	if templateName != "" {
+		t, err := sandboxgrpc.GetTemplate(ctx, dyn, cfg.Namespace, templateName)
+		if err != nil {
+			// Never fall back to default pods: they would be unclaimable by
+			// the template's sessions and only burn capacity.
+			logrus.Warnf("sandbox-matrix: warm pool %s: template %s: %v", pool.GetName(), templateName, err)
+			return
+		}
+		tmpl = t
+	}
 	runtimeClass, _ := specMap["runtimeClass"].(string)
+	if tmpl != nil && tmpl.Spec.RuntimeClass != "" {
+		// Sessions claim a template's pods by the template's class; pods
+		// built with another class would never be claimed.
+		if runtimeClass != "" && runtimeClass != tmpl.Spec.RuntimeClass {
+			logrus.Warnf("sandbox-matrix: warm pool %s: runtimeClass %q conflicts with template %s runtimeClass %q; not filling it",
+				pool.GetName(), runtimeClass, templateName, tmpl.Spec.RuntimeClass)
+			return
+		}
+		runtimeClass = tmpl.Spec.RuntimeClass
+	}
 	if runtimeClass == "" {
 		runtimeClass = cfg.DefaultRuntime
 	}
@@ -254,12 +399,21 @@ func reconcileSinglePool(ctx context.Context, k8s kubernetes.Interface, pool uns
 		return
 	}
 
-	gap := targetSize - int64(len(warmPods.Items))
+	// Only this template's pods fill this pool (checked explicitly: fake
+	// clients in tests may not filter by label selector).
+	var poolWarm int64
+	for i := range warmPods.Items {
+		if warmPods.Items[i].Labels[sandboxgrpc.LabelTemplate] == templateName {
+			poolWarm++
+		}
+	}
+
+	gap := targetSize - poolWarm
 	for i := int64(0); i < gap; i++ {
 		if maxPods > 0 && int64(len(allPods.Items))+i+1 > maxPods {
 			break
 		}
-		pod := newWarmPod(cfg, runtimeClass, idleTTL)
+		pod := newWarmPod(cfg, runtimeClass, idleTTL, tmpl)
 		if _, err := k8s.CoreV1().Pods(cfg.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
 			logrus.Debugf("sandbox-matrix: create warm pod: %v", err)
 		}
@@ -314,8 +468,10 @@ func poolTargetSize(configured, maxPods int64) int64 {
 const podIdleTTLAnnotation = "sandbox.k8e.io/idle-ttl-seconds"
 
 // newWarmPod creates a warm pod spec with the correct labels and runtime.
-// idleTTLSeconds > 0 stamps the per-pool idle TTL override onto the pod.
-func newWarmPod(cfg config.SandboxConfig, runtimeClass string, idleTTLSeconds int64) *corev1.Pod {
+// idleTTLSeconds > 0 stamps the per-pool idle TTL override onto the pod. A
+// non-nil tmpl overrides the image and limits and labels the pod with the
+// template name.
+func newWarmPod(cfg config.SandboxConfig, runtimeClass string, idleTTLSeconds int64, tmpl *sandboxv1.SandboxTemplate) *corev1.Pod {
 	annotations := sandboxgrpc.GvisorAnnotations(runtimeClass)
 	if idleTTLSeconds > 0 {
 		if annotations == nil {
@@ -323,18 +479,25 @@ func newWarmPod(cfg config.SandboxConfig, runtimeClass string, idleTTLSeconds in
 		}
 		annotations[podIdleTTLAnnotation] = strconv.FormatInt(idleTTLSeconds, 10)
 	}
-	return &corev1.Pod{
+	labels := map[string]string{
+		sandboxgrpc.LabelState:        sandboxgrpc.StateWarm,
+		sandboxgrpc.LabelRuntimeClass: runtimeClass,
+	}
+	if tmpl != nil {
+		labels[sandboxgrpc.LabelTemplate] = tmpl.Name
+		cfg.DefaultImage, cfg.DefaultCPU, cfg.DefaultMemory = sandboxgrpc.ApplyTemplate(tmpl, cfg.DefaultImage, cfg.DefaultCPU, cfg.DefaultMemory)
+	}
+	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: "sandbox-warm-",
 			Namespace:    cfg.Namespace,
-			Labels: map[string]string{
-				sandboxgrpc.LabelState:        sandboxgrpc.StateWarm,
-				sandboxgrpc.LabelRuntimeClass: runtimeClass,
-			},
-			Annotations: annotations,
+			Labels:       labels,
+			Annotations:  annotations,
 		},
 		Spec: warmPodSpec(runtimeClass, cfg),
 	}
+	sandboxgrpc.StampSandboxdAuth(pod)
+	return pod
 }
 
 // computeMaxPods returns the maximum number of sandbox pods the cluster can
@@ -425,8 +588,10 @@ func updateSandboxMatrixStatus(ctx context.Context, k8s kubernetes.Interface, dy
 	totalPods := int64(len(warmPods.Items) + len(activePods.Items))
 
 	claimedWarm, coldStarts, avgLatency := int64(0), int64(0), int64(0)
+	forks, avgForkLatency := int64(0), int64(0)
 	if orch != nil {
 		claimedWarm, coldStarts, avgLatency = orch.Metrics()
+		forks, _, avgForkLatency = orch.ForkMetrics()
 	}
 
 	matrix := matrices.Items[0].DeepCopy()
@@ -441,6 +606,8 @@ func updateSandboxMatrixStatus(ctx context.Context, k8s kubernetes.Interface, dy
 	status["claimedFromWarm"] = claimedWarm
 	status["coldStarts"] = coldStarts
 	status["avgClaimLatencyMs"] = avgLatency
+	status["forks"] = forks
+	status["avgForkLatencyMs"] = avgForkLatency
 	dyn.Resource(localMatrixGVR).Namespace(cfg.Namespace).UpdateStatus(ctx, matrix, metav1.UpdateOptions{}) //nolint:errcheck
 }
 
@@ -462,6 +629,94 @@ func runGCLoop(ctx context.Context, orch *sandboxgrpc.Orchestrator, namespace st
 	}
 }
 
+// layerGCInterval is how often the leader reclaims unreferenced registry
+// layers; layerGCGrace spares layers of snapshots still being written (a
+// snapshot's layers land before its manifest, possibly via another server).
+const (
+	layerGCInterval = time.Hour
+	layerGCGrace    = time.Hour
+)
+
+func runLayerGCLoop(ctx context.Context, srv *sandboxgrpc.Server) {
+	ticker := time.NewTicker(layerGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed, err := srv.GCLayerStore(layerGCGrace); err != nil {
+				logrus.Warnf("sandbox-matrix: layer GC: %v", err)
+			} else if removed > 0 {
+				logrus.Infof("sandbox-matrix: layer GC reclaimed %d bytes", removed)
+			}
+		}
+	}
+}
+
+// quotaStatusInterval is how often the leader publishes tenant usage to
+// SandboxTenantQuota status.
+const quotaStatusInterval = 30 * time.Second
+
+func runQuotaStatusLoop(ctx context.Context, srv *sandboxgrpc.Server) {
+	ticker := time.NewTicker(quotaStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			srv.RefreshQuotaStatus(ctx)
+		}
+	}
+}
+
+// usageSampleInterval is how often the leader samples session pod usage from
+// the kubelets.
+const usageSampleInterval = 30 * time.Second
+
+func runUsageSampleLoop(ctx context.Context, srv *sandboxgrpc.Server) {
+	ticker := time.NewTicker(usageSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			srv.SampleUsage(ctx)
+		}
+	}
+}
+
+// layerStoreBackend connects the shared S3 layer registry when configured.
+// A nil backend keeps the registry in cfg.LayerStoreDir on this node only.
+func layerStoreBackend(ctx context.Context, cfg config.SandboxConfig) (sandboxlayer.Backend, error) {
+	s3cfg := cfg.LayerStoreS3
+	if s3cfg == nil {
+		return nil, nil
+	}
+	b, err := s3backend.New(ctx, s3backend.Config{
+		Options: s3client.Options{
+			Endpoint:      s3cfg.Endpoint,
+			EndpointCA:    s3cfg.EndpointCA,
+			Region:        s3cfg.Region,
+			AccessKey:     s3cfg.AccessKey,
+			SecretKey:     s3cfg.SecretKey,
+			Proxy:         s3cfg.Proxy,
+			Insecure:      s3cfg.Insecure,
+			SkipSSLVerify: s3cfg.SkipSSLVerify,
+		},
+		Bucket:  s3cfg.Bucket,
+		Folder:  s3cfg.Folder,
+		Timeout: s3cfg.Timeout.Duration,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("sandbox-matrix: snapshot layer registry in s3://%s/%s", s3cfg.Bucket, s3cfg.Folder)
+	return b, nil
+}
+
 func gcExpiredSessions(ctx context.Context, orch *sandboxgrpc.Orchestrator, namespace string) {
 	sessions, err := orch.ListActiveSessions(ctx, namespace)
 	if err != nil {
@@ -471,7 +726,7 @@ func gcExpiredSessions(ctx context.Context, orch *sandboxgrpc.Orchestrator, name
 	for _, s := range sessions {
 		if s.Status.ExpiresAt != nil && s.Status.ExpiresAt.Time.Before(now) {
 			logrus.Infof("sandbox-matrix: GC session %s (expired at %s)", s.Name, s.Status.ExpiresAt.Time)
-			if err := orch.DestroySession(ctx, s.Name); err != nil {
+			if err := orch.ExpireSession(ctx, s.Name); err != nil {
 				logrus.Warnf("sandbox-matrix: GC destroy %s: %v", s.Name, err)
 			}
 		}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/xiaods/k8e/pkg/daemons/config"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
 	sandboxgrpc "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -88,23 +89,122 @@ func TestRecycleUnhealthyWarmPods(t *testing.T) {
 }
 
 func TestNewWarmPod_RuntimeClassLabel(t *testing.T) {
-	pod := newWarmPod(defaultCfg(), "kata", 0)
+	pod := newWarmPod(defaultCfg(), "kata", 0, nil)
 	if pod.Labels[sandboxgrpc.LabelRuntimeClass] != "kata" {
 		t.Fatalf("expected runtime-class label kata, got %v", pod.Labels)
 	}
 }
 
 func TestNewWarmPod_IdleTTLAnnotation(t *testing.T) {
-	pod := newWarmPod(defaultCfg(), "gvisor", 300)
+	pod := newWarmPod(defaultCfg(), "gvisor", 300, nil)
 	if pod.Annotations[podIdleTTLAnnotation] != "300" {
 		t.Fatalf("expected idle-ttl annotation 300, got %v", pod.Annotations)
 	}
-	plain := newWarmPod(defaultCfg(), "gvisor", 0)
+	plain := newWarmPod(defaultCfg(), "gvisor", 0, nil)
 	if _, present := plain.Annotations[podIdleTTLAnnotation]; present {
 		t.Fatal("expected no idle-ttl annotation when TTL unset")
 	}
 }
 
+func TestNewWarmPod_Template(t *testing.T) {
+	tmpl := &sandboxv1.SandboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "python-data"},
+		Spec: sandboxv1.SandboxTemplateSpec{
+			Image:          "ghcr.io/example/python-data:1",
+			ResourceLimits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+	}
+	pod := newWarmPod(defaultCfg(), "gvisor", 0, tmpl)
+	if pod.Labels[sandboxgrpc.LabelTemplate] != "python-data" {
+		t.Fatalf("expected template label, got %v", pod.Labels)
+	}
+	c := pod.Spec.Containers[0]
+	if c.Image != "ghcr.io/example/python-data:1" {
+		t.Fatalf("expected template image, got %s", c.Image)
+	}
+	if mem := c.Resources.Limits[corev1.ResourceMemory]; mem.String() != "2Gi" {
+		t.Fatalf("expected template memory 2Gi, got %s", mem.String())
+	}
+	// CPU is not set by the template: the config default applies.
+	if cpu := c.Resources.Limits[corev1.ResourceCPU]; cpu.String() != "500m" {
+		t.Fatalf("expected default cpu 500m, got %s", cpu.String())
+	}
+}
+
+func TestReconcileSinglePool_TemplateRef(t *testing.T) {
+	ctx := context.Background()
+	ns := "sandbox-matrix"
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: sandboxgrpc.SandboxAPIGroup, Version: "v1alpha1", Kind: "SandboxTemplate"}, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: sandboxgrpc.SandboxAPIGroup, Version: "v1alpha1", Kind: "SandboxTemplateList"}, &unstructured.UnstructuredList{})
+	dyn := dynfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		sandboxgrpc.TemplateGVR: "SandboxTemplateList",
+	})
+	tmpl := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": sandboxgrpc.SandboxAPIGroup + "/v1alpha1",
+		"kind":       "SandboxTemplate",
+		"metadata":   map[string]interface{}{"name": "rust-toolchain", "namespace": ns},
+		"spec":       map[string]interface{}{"runtimeClass": "kata", "image": "ghcr.io/example/rust:1"},
+	}}
+	if _, err := dyn.Resource(sandboxgrpc.TemplateGVR).Namespace(ns).Create(ctx, tmpl, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+
+	// An untemplated warm pod must not count toward the template pool's target.
+	k8s := kubefake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "warm-plain", Namespace: ns,
+		Labels: map[string]string{sandboxgrpc.LabelState: sandboxgrpc.StateWarm},
+	}})
+	pool := unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "rust", "namespace": ns},
+		"spec": map[string]interface{}{
+			"size":        int64(1),
+			"templateRef": map[string]interface{}{"name": "rust-toolchain"},
+		},
+	}}
+	reconcileSinglePool(ctx, k8s, dyn, pool, 0, defaultCfg(), 0)
+
+	pods, err := k8s.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list pods: %v", err)
+	}
+	var built *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Labels[sandboxgrpc.LabelTemplate] == "rust-toolchain" {
+			built = &pods.Items[i]
+		}
+	}
+	if built == nil {
+		t.Fatalf("expected a rust-toolchain warm pod, got %d pods", len(pods.Items))
+	}
+	if built.Labels[sandboxgrpc.LabelRuntimeClass] != "kata" {
+		t.Fatalf("expected template runtime kata, got %v", built.Labels)
+	}
+	if built.Spec.Containers[0].Image != "ghcr.io/example/rust:1" {
+		t.Fatalf("expected template image, got %s", built.Spec.Containers[0].Image)
+	}
+
+	// A missing template creates nothing.
+	missing := pool.DeepCopy()
+	unstructured.SetNestedField(missing.Object, "nope", "spec", "templateRef", "name") //nolint:errcheck
+	before := len(pods.Items)
+	reconcileSinglePool(ctx, k8s, dyn, *missing, 0, defaultCfg(), 0)
+	after, _ := k8s.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if len(after.Items) != before {
+		t.Fatalf("expected no pods for a missing template, got %d (was %d)", len(after.Items), before)
+	}
+
+	// A pool class that differs from the template's creates nothing either.
+	conflict := pool.DeepCopy()
+	unstructured.SetNestedField(conflict.Object, "gvisor", "spec", "runtimeClass") //nolint:errcheck
+	unstructured.SetNestedField(conflict.Object, int64(2), "spec", "size")         //nolint:errcheck
+	reconcileSinglePool(ctx, k8s, dyn, *conflict, 0, defaultCfg(), 0)
+	after, _ = k8s.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if len(after.Items) != before {
+		t.Fatalf("expected no pods for a conflicting runtimeClass, got %d (was %d)", len(after.Items), before)
+	}
+}
+
 func TestAdaptiveTarget(t *testing.T) {
 	cases := []struct {
 		name                        string
@@ -296,7 +396,7 @@ func TestUpdateSandboxMatrixStatus_WritesMetrics(t *testing.T) {
 	if !ok {
 		t.Fatal("expected status on matrix CR")
 	}
-	for _, field := range []string{"claimedFromWarm", "coldStarts", "avgClaimLatencyMs", "readyWarmCount", "activeSessions", "maxPods", "totalPods"} {
+	for _, field := range []string{"claimedFromWarm", "coldStarts", "avgClaimLatencyMs", "forks", "avgForkLatencyMs", "readyWarmCount", "activeSessions", "maxPods", "totalPods"} {
 		if _, present := status[field]; !present {
 			t.Errorf("expected status field %s to be written", field)
 		}
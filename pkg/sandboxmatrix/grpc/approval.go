@@ -0,0 +1,381 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// Durable human-in-the-loop approvals. Each ConfirmAction registers a
+// SandboxApproval CRD; waiters poll it and ApproveAction records the
+// decision in its status, so an approval survives a gateway restart and can
+// be decided through any replica. Decided approvals are kept for
+// approvalRetention as an audit trail (who asked, who decided, why, when).
+
+var approvalGVR = schema.GroupVersionResource{Group: sandboxAPIGroup, Version: "v1alpha1", Resource: "sandboxapprovals"}
+
+const (
+	// approvalTTL is how long a pending approval waits before auto-expiry.
+	approvalTTL = 5 * time.Minute
+	// approvalRetention is how long decided approvals are kept for audit.
+	approvalRetention = 7 * 24 * time.Hour
+)
+
+// approvalPollInterval is how often a waiting ConfirmAction re-reads its
+// approval. Overridable in tests.
+var approvalPollInterval = time.Second
+
+func (o *Orchestrator) ConfirmAction(ctx context.Context, req *pb.ConfirmActionRequest) (*pb.ConfirmActionResponse, error) {
+	if req.ApprovalId != "" {
+		return o.waitApproval(ctx, req.ApprovalId)
+	}
+	if req.SessionId == "" || req.Action == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id and action required")
+	}
+	tenant := requestTenant(ctx, "")
+	if sess, err := o.getSession(ctx, req.SessionId); err == nil {
+		tenant = sessionTenant(sess)
+	}
+	now := time.Now()
+	a := &sandboxv1.SandboxApproval{
+		TypeMeta: metav1.TypeMeta{APIVersion: sandboxAPIVersion, Kind: "SandboxApproval"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("approval-%s-%d", req.SessionId, now.UnixNano()),
+			Namespace: sandboxNS,
+			Labels:    map[string]string{labelSessionID: req.SessionId},
+		},
+		Spec: sandboxv1.SandboxApprovalSpec{
+			SessionID: req.SessionId,
+			TenantID:  tenant,
+			Action:    req.Action,
+			Requester: callerIdentity(ctx),
+			ExpiresAt: metav1.NewTime(now.Add(approvalTTL)),
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(a)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "approval: %v", err)
+	}
+	u, err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).Create(ctx, &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "approval: %v", err)
+	}
+	if created, err := unstructuredToApproval(u); err == nil {
+		a = created
+	}
+	o.emitWebhook(WebhookApprovalRequested, req.SessionId, tenant, map[string]any{
+		"approval_id": a.Name,
+		"action":      a.Spec.Action,
+		"requester":   a.Spec.Requester,
+		"expires_at":  a.Spec.ExpiresAt.Unix(),
+	})
+	return &pb.ConfirmActionResponse{ApprovalId: a.Name, Approved: false}, nil
+}
+
+// waitApproval blocks until the approval is decided or expires. A cancelled
+// wait leaves the approval pending, so the caller may wait again.
+func (o *Orchestrator) waitApproval(ctx context.Context, id string) (*pb.ConfirmActionResponse, error) {
+	ticker := time.NewTicker(approvalPollInterval)
+	defer ticker.Stop()
+	for {
+		a, err := o.getApproval(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch approvalPhase(a) {
+		case sandboxv1.SandboxApprovalApproved:
+			return &pb.ConfirmActionResponse{ApprovalId: id, Approved: true}, nil
+		case sandboxv1.SandboxApprovalDenied:
+			return &pb.ConfirmActionResponse{ApprovalId: id, Approved: false}, nil
+		case sandboxv1.SandboxApprovalExpired:
+			return nil, status.Errorf(codes.DeadlineExceeded, "approval timed out after %v", approvalTTL)
+		}
+		if time.Now().After(a.Spec.ExpiresAt.Time) {
+			o.expireApproval(ctx, a)
+			return nil, status.Errorf(codes.DeadlineExceeded, "approval timed out after %v", approvalTTL)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, status.Errorf(codes.Canceled, "cancelled")
+		case <-ticker.C:
+		}
+	}
+}
+
+// Approve records decider's decision on a pending approval. Deciding an
+// approval twice, or after it expired, fails with FailedPrecondition.
+func (o *Orchestrator) Approve(ctx context.Context, approvalID string, approved bool, decider, reason string) error {
+	a, err := o.getApproval(ctx, approvalID)
+	if err != nil {
+		return err
+	}
+	if phase := approvalPhase(a); phase != sandboxv1.SandboxApprovalPending {
+		return status.Errorf(codes.FailedPrecondition, "approval %s already %s", approvalID, phase)
+	}
+	if time.Now().After(a.Spec.ExpiresAt.Time) {
+		o.expireApproval(ctx, a)
+		return status.Errorf(codes.FailedPrecondition, "approval %s expired", approvalID)
+	}
+	a.Status = sandboxv1.SandboxApprovalStatus{
+		Phase:     sandboxv1.SandboxApprovalDenied,
+		DecidedBy: decider,
+		DecidedAt: &metav1.Time{Time: time.Now()},
+		Reason:    reason,
+	}
+	if approved {
+		a.Status.Phase = sandboxv1.SandboxApprovalApproved
+	}
+	if err := o.updateApprovalStatus(ctx, a); err != nil {
+		if apierrors.IsConflict(err) {
+			return status.Errorf(codes.Aborted, "approval %s was decided concurrently", approvalID)
+		}
+		return status.Errorf(codes.Internal, "approval %s: %v", approvalID, err)
+	}
+	return nil
+}
+
+// ApproveAction resolves a pending approval (external approval via gRPC).
+func (o *Orchestrator) ApproveAction(ctx context.Context, req *pb.ApproveActionRequest) (*pb.ApproveActionResponse, error) {
+	if err := o.Approve(ctx, req.ApprovalId, req.Approved, callerIdentity(ctx), req.Reason); err != nil {
+		return nil, err
+	}
+	return &pb.ApproveActionResponse{Ok: true}, nil
+}
+
+// ListApprovals returns approvals, newest first.
+func (o *Orchestrator) ListApprovals(ctx context.Context, req *pb.ListApprovalsRequest) (*pb.ListApprovalsResponse, error) {
+	approvals, err := o.listApprovals(ctx, req.SessionId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list approvals: %v", err)
+	}
+	resp := &pb.ListApprovalsResponse{}
+	for i := len(approvals) - 1; i >= 0; i-- {
+		a := approvals[i]
+		if req.Phase != "" && string(approvalPhase(a)) != req.Phase {
+			continue
+		}
+		resp.Approvals = append(resp.Approvals, approvalToPB(a))
+	}
+	return resp, nil
+}
+
+// WatchApprovals sends the current approvals, then every change until the
+// client goes away.
+func (o *Orchestrator) WatchApprovals(req *pb.WatchApprovalsRequest, stream pb.SandboxService_WatchApprovalsServer) error {
+	ctx := stream.Context()
+	opts := metav1.ListOptions{}
+	if req.SessionId != "" {
+		opts.LabelSelector = labelSessionID + "=" + req.SessionId
+	}
+	list, err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).List(ctx, opts)
+	if err != nil {
+		return status.Errorf(codes.Internal, "watch approvals: %v", err)
+	}
+	for i := range list.Items {
+		if a, err := unstructuredToApproval(&list.Items[i]); err == nil {
+			if err := stream.Send(approvalToPB(a)); err != nil {
+				return err
+			}
+		}
+	}
+	opts.ResourceVersion = list.GetResourceVersion()
+	w, err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).Watch(ctx, opts)
+	if err != nil {
+		return status.Errorf(codes.Internal, "watch approvals: %v", err)
+	}
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return status.Error(codes.Unavailable, "approval watch closed; reconnect")
+			}
+			if ev.Type != watch.Added && ev.Type != watch.Modified {
+				continue
+			}
+			u, ok := ev.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			if a, err := unstructuredToApproval(u); err == nil {
+				if err := stream.Send(approvalToPB(a)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// StartApprovalGC periodically expires overdue approvals (whose requester
+// stopped waiting) and deletes decided ones past approvalRetention.
+func (o *Orchestrator) StartApprovalGC(ctx context.Context) {
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.gcApprovals(ctx, time.Now())
+		}
+	}
+}
+
+func (o *Orchestrator) gcApprovals(ctx context.Context, now time.Time) {
+	approvals, err := o.listApprovals(ctx, "")
+	if err != nil {
+		logrus.Debugf("sandbox approvals: gc list: %v", err)
+		return
+	}
+	for _, a := range approvals {
+		switch {
+		case approvalPhase(a) == sandboxv1.SandboxApprovalPending:
+			if now.After(a.Spec.ExpiresAt.Time) {
+				o.expireApproval(ctx, a)
+			}
+		case approvalDecidedAt(a).Add(approvalRetention).Before(now):
+			if err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).Delete(ctx, a.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				logrus.Debugf("sandbox approvals: delete %s: %v", a.Name, err)
+			}
+		}
+	}
+}
+
+// expireApproval marks a pending approval Expired (best effort: another
+// replica may have decided or expired it first).
+func (o *Orchestrator) expireApproval(ctx context.Context, a *sandboxv1.SandboxApproval) {
+	a.Status = sandboxv1.SandboxApprovalStatus{
+		Phase:     sandboxv1.SandboxApprovalExpired,
+		DecidedAt: &metav1.Time{Time: time.Now()},
+	}
+	if err := o.updateApprovalStatus(ctx, a); err != nil {
+		logrus.Debugf("sandbox approvals: expire %s: %v", a.Name, err)
+	}
+}
+
+func (o *Orchestrator) getApproval(ctx context.Context, id string) (*sandboxv1.SandboxApproval, error) {
+	u, err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).Get(ctx, id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "approval %s not found", id)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "approval %s: %v", id, err)
+	}
+	return unstructuredToApproval(u)
+}
+
+// listApprovals returns approvals (optionally one session's), oldest first.
+func (o *Orchestrator) listApprovals(ctx context.Context, sessionID string) ([]*sandboxv1.SandboxApproval, error) {
+	opts := metav1.ListOptions{}
+	if sessionID != "" {
+		opts.LabelSelector = labelSessionID + "=" + sessionID
+	}
+	list, err := o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	approvals := make([]*sandboxv1.SandboxApproval, 0, len(list.Items))
+	for i := range list.Items {
+		a, err := unstructuredToApproval(&list.Items[i])
+		if err != nil {
+			continue
+		}
+		approvals = append(approvals, a)
+	}
+	sortApprovals(approvals)
+	return approvals, nil
+}
+
+func (o *Orchestrator) updateApprovalStatus(ctx context.Context, a *sandboxv1.SandboxApproval) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(a)
+	if err != nil {
+		return err
+	}
+	_, err = o.dynamic.Resource(approvalGVR).Namespace(sandboxNS).UpdateStatus(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	return err
+}
+
+func unstructuredToApproval(u *unstructured.Unstructured) (*sandboxv1.SandboxApproval, error) {
+	var a sandboxv1.SandboxApproval
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// approvalPhase treats a fresh approval (no status yet) as Pending.
+func approvalPhase(a *sandboxv1.SandboxApproval) sandboxv1.SandboxApprovalPhase {
+	if a.Status.Phase == "" {
+		return sandboxv1.SandboxApprovalPending
+	}
+	return a.Status.Phase
+}
+
+func approvalDecidedAt(a *sandboxv1.SandboxApproval) time.Time {
+	if a.Status.DecidedAt != nil {
+		return a.Status.DecidedAt.Time
+	}
+	return a.Spec.ExpiresAt.Time
+}
+
+func sortApprovals(approvals []*sandboxv1.SandboxApproval) {
+	sort.SliceStable(approvals, func(i, j int) bool {
+		return approvals[i].CreationTimestamp.Before(&approvals[j].CreationTimestamp)
+	})
+}
+
+func approvalToPB(a *sandboxv1.SandboxApproval) *pb.Approval {
+	out := &pb.Approval{
+		ApprovalId: a.Name,
+		SessionId:  a.Spec.SessionID,
+		TenantId:   a.Spec.TenantID,
+		Action:     a.Spec.Action,
+		Requester:  a.Spec.Requester,
+		Phase:      string(approvalPhase(a)),
+		DecidedBy:  a.Status.DecidedBy,
+		Reason:     a.Status.Reason,
+		ExpiresAt:  a.Spec.ExpiresAt.Unix(),
+	}
+	if !a.CreationTimestamp.IsZero() {
+		out.CreatedAt = a.CreationTimestamp.Unix()
+	}
+	if a.Status.DecidedAt != nil {
+		out.DecidedAt = a.Status.DecidedAt.Unix()
+	}
+	return out
+}
+
+// callerIdentity names the authenticated caller for the audit trail: the
+// client certificate CN, "local" for loopback callers without one.
+func callerIdentity(ctx context.Context) string {
+	name, local := peerIdentity(ctx)
+	if name == "" && local {
+		return "local"
+	}
+	return name
+}
+
+// ApprovalWebhook notifies an external endpoint (chat-ops bridge, pager) of
+// every new approval, so approvers need not poll `approvals watch`. It is
+// delivered like a SandboxWebhook subscribed to approval.requested.
+type ApprovalWebhook struct {
+	URL string
+	// Secret, when set, signs each delivery like a SandboxWebhook secret.
+	Secret string
+}
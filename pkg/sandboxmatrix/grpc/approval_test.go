@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func mustConfirm(t *testing.T, o *Orchestrator, sid, action string) string {
+	t.Helper()
+	resp, err := o.ConfirmAction(context.Background(), &pb.ConfirmActionRequest{SessionId: sid, Action: action})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return resp.ApprovalId
+}
+
+// TestApproval_SurvivesGatewayRestart verifies an approval registered on one
+// gateway can be decided and awaited through another sharing the cluster.
+func TestApproval_SurvivesGatewayRestart(t *testing.T) {
+	first := newTestOrchestrator()
+	id := mustConfirm(t, first, "sess-1", "rm -rf /workspace/build")
+
+	second := NewOrchestrator(first.k8s, first.dynamic)
+	if err := second.Approve(context.Background(), id, false, "alice", "not during the release"); err != nil {
+		t.Fatalf("approve on second gateway: %v", err)
+	}
+	resp, err := first.ConfirmAction(context.Background(), &pb.ConfirmActionRequest{ApprovalId: id})
+	if err != nil || resp.Approved {
+		t.Fatalf("expected a denial, got %+v %v", resp, err)
+	}
+
+	a, err := first.getApproval(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Spec.Action != "rm -rf /workspace/build" || a.Status.Phase != sandboxv1.SandboxApprovalDenied ||
+		a.Status.DecidedBy != "alice" || a.Status.Reason != "not during the release" || a.Status.DecidedAt == nil {
+		t.Fatalf("audit record incomplete: %+v", a)
+	}
+}
+
+func TestApproval_DecideOnce(t *testing.T) {
+	o := newTestOrchestrator()
+	id := mustConfirm(t, o, "sess-1", "drop table users")
+	if err := o.Approve(context.Background(), id, true, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	err := o.Approve(context.Background(), id, false, "bob", "")
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("second decision: expected FailedPrecondition, got %v", err)
+	}
+	if err := o.Approve(context.Background(), "approval-missing", true, "alice", ""); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown approval: expected NotFound, got %v", err)
+	}
+}
+
+func TestApproval_RequiresSessionAndAction(t *testing.T) {
+	o := newTestOrchestrator()
+	_, err := o.ConfirmAction(context.Background(), &pb.ConfirmActionRequest{SessionId: "sess-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestApproval_GCExpiresAndPrunes(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	pending := mustConfirm(t, o, "sess-1", "pending action")
+	decided := mustConfirm(t, o, "sess-1", "decided action")
+	if err := o.Approve(ctx, decided, true, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	o.gcApprovals(ctx, time.Now().Add(approvalTTL+time.Minute))
+	a, err := o.getApproval(ctx, pending)
+	if err != nil || a.Status.Phase != sandboxv1.SandboxApprovalExpired {
+		t.Fatalf("overdue approval not expired: %+v %v", a, err)
+	}
+	if _, err := o.getApproval(ctx, decided); err != nil {
+		t.Fatalf("decided approval pruned before retention: %v", err)
+	}
+	if _, err := o.ConfirmAction(ctx, &pb.ConfirmActionRequest{ApprovalId: pending}); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("waiting on an expired approval: %v", err)
+	}
+
+	o.gcApprovals(ctx, time.Now().Add(approvalRetention+time.Hour))
+	if _, err := o.getApproval(ctx, decided); status.Code(err) != codes.NotFound {
+		t.Fatalf("decided approval kept past retention: %v", err)
+	}
+}
+
+func TestListApprovals_Filters(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	a1 := mustConfirm(t, o, "sess-1", "one")
+	mustConfirm(t, o, "sess-1", "two")
+	mustConfirm(t, o, "sess-2", "three")
+	if err := o.Approve(ctx, a1, true, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := o.ListApprovals(ctx, &pb.ListApprovalsRequest{SessionId: "sess-1"})
+	if err != nil || len(resp.Approvals) != 2 {
+		t.Fatalf("session filter: %+v %v", resp, err)
+	}
+	resp, err = o.ListApprovals(ctx, &pb.ListApprovalsRequest{Phase: "Pending"})
+	if err != nil || len(resp.Approvals) != 2 {
+		t.Fatalf("phase filter: %+v %v", resp, err)
+	}
+	for _, a := range resp.Approvals {
+		if a.Phase != "Pending" || a.ApprovalId == a1 {
+			t.Fatalf("unexpected approval in pending list: %+v", a)
+		}
+	}
+}
+
+// approvalStream is a WatchApprovals server stream that hands frames to the test.
+type approvalStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.Approval
+}
+
+func (s *approvalStream) Context() context.Context { return s.ctx }
+func (s *approvalStream) Send(a *pb.Approval) error {
+	s.sent <- a
+	return nil
+}
+
+func TestWatchApprovals_StreamsNewAndDecided(t *testing.T) {
+	o := newTestOrchestrator()
+	existing := mustConfirm(t, o, "sess-1", "existing")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &approvalStream{ctx: ctx, sent: make(chan *pb.Approval, 8)}
+	done := make(chan error, 1)
+	go func() { done <- o.WatchApprovals(&pb.WatchApprovalsRequest{SessionId: "sess-1"}, stream) }()
+
+	next := func() *pb.Approval {
+		t.Helper()
+		select {
+		case a := <-stream.sent:
+			return a
+		case <-time.After(5 * time.Second):
+			t.Fatal("no approval event")
+			return nil
+		}
+	}
+	if a := next(); a.ApprovalId != existing || a.Phase != "Pending" {
+		t.Fatalf("initial snapshot: %+v", a)
+	}
+	// Give the watch time to start before generating events.
+	time.Sleep(100 * time.Millisecond)
+	created := mustConfirm(t, o, "sess-1", "new")
+	if a := next(); a.ApprovalId != created {
+		t.Fatalf("created event: %+v", a)
+	}
+	if err := o.Approve(context.Background(), created, true, "alice", "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if a := next(); a.ApprovalId != created || a.Phase != "Approved" || a.DecidedBy != "alice" {
+		t.Fatalf("decided event: %+v", a)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("watch returned %v", err)
+	}
+}
+
+func TestConfirmAction_NotifiesApprovalWebhookOnce(t *testing.T) {
+	o := newTestOrchestrator()
+	srv, got := webhookReceiver(t, 0)
+	o.approvalWebhook = ApprovalWebhook{URL: srv.URL, Secret: "s3cret"}
+	id := mustConfirm(t, o, "sess-1", "push to main")
+
+	d := nextDelivery(t, got)
+	if d.event != WebhookApprovalRequested || d.body.Data["approval_id"] != id || d.body.Data["action"] != "push to main" {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	if d.signature != signWebhookBody("s3cret", d.timestamp, d.raw) {
+		t.Fatalf("bad signature %q", d.signature)
+	}
+
+	// A SandboxWebhook for the same URL does not double the notification.
+	mustCreateWebhook(t, o, "approvals", sandboxv1.SandboxWebhookSpec{URL: srv.URL, Events: []string{WebhookApprovalRequested}})
+	mustConfirm(t, o, "sess-1", "push again")
+	nextDelivery(t, got)
+	select {
+	case d := <-got:
+		t.Fatalf("approval notified twice: %+v", d)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
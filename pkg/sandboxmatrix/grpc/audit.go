@@ -0,0 +1,154 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// defaultAuditQueryLimit caps QueryAudit when the request sets no limit.
+const defaultAuditQueryLimit = 1000
+
+// auditUnaryInterceptor records every unary RPC — including ones the rate
+// limiter or mTLS check reject, so it runs first in the chain.
+func (s *Server) auditUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.writeAudit(ctx, info.FullMethod, req, err, start)
+	return resp, err
+}
+
+// auditStreamInterceptor records every streaming RPC once it ends; the
+// session and command come from the first message the handler receives.
+func (s *Server) auditStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	as := &auditedStream{ServerStream: ss}
+	err := handler(srv, as)
+	s.writeAudit(ss.Context(), info.FullMethod, as.first, err, start)
+	return err
+}
+
+// auditedStream remembers the first received request message.
+type auditedStream struct {
+	grpc.ServerStream
+	first any
+}
+
+func (a *auditedStream) RecvMsg(m any) error {
+	err := a.ServerStream.RecvMsg(m)
+	if err == nil && a.first == nil {
+		a.first = m
+	}
+	return err
+}
+
+func (s *Server) writeAudit(ctx context.Context, method string, req any, err error, start time.Time) {
+	if s.audit == nil {
+		return
+	}
+	if werr := s.audit.Write(auditRecord(ctx, method, req, err, start)); werr != nil {
+		logrus.Warnf("sandbox gRPC: audit %s: %v", method, werr)
+	}
+}
+
+// auditRecord builds the record for one call. Request fields are read
+// through the generated getters, so any request carrying a session, tenant,
+// command or path is covered without a per-RPC table.
+func auditRecord(ctx context.Context, method string, req any, err error, start time.Time) audit.Record {
+	r := audit.Record{
+		Time:      start,
+		Source:    "grpc",
+		Caller:    callerIdentity(ctx),
+		Method:    method,
+		Outcome:   status.Code(err).String(),
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if r.Caller == "" {
+		r.Caller = "anonymous"
+	}
+	if cert := peerCertFromContext(ctx); cert != nil {
+		r.Fingerprint = fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+	}
+	if v, ok := req.(interface{ GetStart() *pb.ExecRequest }); ok && v.GetStart() != nil {
+		req = v.GetStart() // ExecInteractive: the start frame carries the request
+	}
+	var explicitTenant string
+	if v, ok := req.(interface{ GetTenantId() string }); ok {
+		explicitTenant = v.GetTenantId()
+	}
+	r.Tenant = requestTenant(ctx, explicitTenant)
+	if v, ok := req.(interface{ GetSessionId() string }); ok {
+		r.Session = v.GetSessionId()
+	}
+	if v, ok := req.(interface{ GetCommand() string }); ok && v.GetCommand() != "" {
+		r.Target = audit.Hash(v.GetCommand())
+	} else if v, ok := req.(interface{ GetPath() string }); ok {
+		r.Target = audit.Hash(v.GetPath())
+	}
+	return r
+}
+
+// QueryAudit reads this node's audit directory (gateway and embedded E2B
+// records alike); the response names the node, since other nodes keep
+// their own logs. A caller authenticated by client certificate reads only
+// its own tenant's records; the whole log is for the node's trusted
+// loopback callers (its operators).
+func (s *Server) QueryAudit(ctx context.Context, req *pb.QueryAuditRequest) (*pb.QueryAuditResponse, error) {
+	if s.auditDir == "" {
+		return nil, status.Error(codes.FailedPrecondition, "audit log is not enabled on this gateway")
+	}
+	tenant := req.TenantId
+	if name, _ := peerIdentity(ctx); name != "" {
+		if tenant != "" && tenant != name {
+			return nil, status.Errorf(codes.PermissionDenied, "audit: %s may only read tenant %s's records", name, name)
+		}
+		tenant = name
+	}
+	f := audit.Filter{
+		Source:  req.Source,
+		Caller:  req.Caller,
+		Tenant:  tenant,
+		Session: req.SessionId,
+		Method:  req.Method,
+		Target:  req.Target,
+		Limit:   int(req.Limit),
+	}
+	if req.Since > 0 {
+		f.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		f.Until = time.Unix(req.Until, 0)
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultAuditQueryLimit
+	}
+	records, err := audit.Query(s.auditDir, f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "read audit log: %v", err)
+	}
+	resp := &pb.QueryAuditResponse{Node: s.nodeName, Records: make([]*pb.AuditRecord, 0, len(records))}
+	for _, r := range records {
+		resp.Records = append(resp.Records, &pb.AuditRecord{
+			Time:        r.Time.UnixMilli(),
+			Source:      r.Source,
+			Caller:      r.Caller,
+			Fingerprint: r.Fingerprint,
+			TenantId:    r.Tenant,
+			SessionId:   r.Session,
+			Method:      r.Method,
+			Outcome:     r.Outcome,
+			LatencyMs:   r.LatencyMS,
+			Target:      r.Target,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func auditTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer()
+	dir := t.TempDir()
+	l, err := audit.Open(audit.Options{Dir: dir, Prefix: "grpc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s.audit, s.auditDir = l, dir
+	return s
+}
+
+func loopbackCtx() context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(tenantHeader, "team-a"))
+}
+
+func TestAuditUnary_RecordsCallWithHashedCommand(t *testing.T) {
+	s := auditTestServer(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/sandbox.v1.SandboxService/Exec"}
+	req := &pb.ExecRequest{SessionId: "sess-1", Command: "cat /run/secrets/token"}
+	_, err := s.auditUnaryInterceptor(loopbackCtx(), req, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.NotFound, "session not found")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("interceptor changed the handler error: %v", err)
+	}
+
+	resp, err := s.QueryAudit(context.Background(), &pb.QueryAuditRequest{SessionId: "sess-1"})
+	if err != nil || len(resp.Records) != 1 {
+		t.Fatalf("query: %+v %v", resp, err)
+	}
+	r := resp.Records[0]
+	if r.Caller != "local" || r.TenantId != "team-a" || r.Method != info.FullMethod || r.Outcome != "NotFound" || r.Source != "grpc" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if r.Target != audit.Hash(req.Command) || strings.Contains(r.Target, "secrets") {
+		t.Fatalf("command not redacted: %q", r.Target)
+	}
+	// Operators search by the plaintext; the gateway hashes it.
+	if resp, _ := s.QueryAudit(context.Background(), &pb.QueryAuditRequest{Target: req.Command}); len(resp.Records) != 1 {
+		t.Fatalf("target query: %+v", resp)
+	}
+}
+
+// auditStream is a server stream whose first RecvMsg yields req.
+type auditStream struct {
+	grpc.ServerStream
+	req *pb.ExecRequest
+}
+
+func (a *auditStream) Context() context.Context { return loopbackCtx() }
+func (a *auditStream) RecvMsg(m any) error {
+	r := m.(*pb.ExecRequest)
+	r.SessionId, r.Command = a.req.SessionId, a.req.Command
+	return nil
+}
+
+func TestAuditStream_RecordsFirstMessage(t *testing.T) {
+	s := auditTestServer(t)
+	info := &grpc.StreamServerInfo{FullMethod: "/sandbox.v1.SandboxService/ExecStream", IsServerStream: true}
+	ss := &auditStream{req: &pb.ExecRequest{SessionId: "sess-2", Command: "make test"}}
+	err := s.auditStreamInterceptor(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+		return stream.RecvMsg(new(pb.ExecRequest))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.QueryAudit(context.Background(), &pb.QueryAuditRequest{Method: "ExecStream"})
+	if err != nil || len(resp.Records) != 1 {
+		t.Fatalf("query: %+v %v", resp, err)
+	}
+	if r := resp.Records[0]; r.SessionId != "sess-2" || r.Outcome != "OK" || r.Target != audit.Hash("make test") {
+		t.Fatalf("unexpected record %+v", r)
+	}
+}
+
+func TestQueryAudit_Disabled(t *testing.T) {
+	s := newTestServer()
+	if _, err := s.QueryAudit(context.Background(), &pb.QueryAuditRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestQueryAudit_CertCallerSeesOwnTenantOnly(t *testing.T) {
+	s := auditTestServer(t)
+	s.nodeName = "node-1"
+	for _, tenant := range []string{"key-a", "team-b"} {
+		s.writeAudit(metadata.NewIncomingContext(loopbackCtx(), metadata.Pairs(tenantHeader, tenant)),
+			"/sandbox.v1.SandboxService/GetSession", &pb.GetSessionRequest{SessionId: tenant + "-1"}, nil, time.Now())
+	}
+	cert := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "key-a"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	certCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 40000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+
+	resp, err := s.QueryAudit(certCtx, &pb.QueryAuditRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Records) != 1 || resp.Records[0].TenantId != "key-a" || resp.Node != "node-1" {
+		t.Fatalf("certificate caller read %v from %q", resp.Records, resp.Node)
+	}
+	if _, err := s.QueryAudit(certCtx, &pb.QueryAuditRequest{TenantId: "team-b"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for another tenant, got %v", err)
+	}
+	if resp, err := s.QueryAudit(loopbackCtx(), &pb.QueryAuditRequest{}); err != nil || len(resp.Records) != 2 {
+		t.Fatalf("loopback caller should read every tenant: %v %v", resp, err)
+	}
+}
@@ -15,14 +15,21 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/xiaods/k8e/pkg/daemons/config"
+	"github.com/xiaods/k8e/pkg/sandbox/revocation"
 )
 
 const caOrg = "K8E Sandbox"
@@ -277,17 +284,17 @@ func containsIP(list []net.IP, want net.IP) bool {
 
 // signClientCert signs a client CSR with the sandbox CA.
 // The CSR's Subject and SANs are ignored — the server controls certificate identity.
-func signClientCert(caKey *ecdsa.PrivateKey, caCert *x509.Certificate, csrPEM, commonName string, ttlDays int) (certPEM string, fingerprint string, err error) {
+func signClientCert(caKey *ecdsa.PrivateKey, caCert *x509.Certificate, csrPEM, commonName string, ttlDays int) (certPEM, fingerprint, serial string, err error) {
 	block, _ := pem.Decode([]byte(csrPEM))
 	if block == nil {
-		return "", "", fmt.Errorf("invalid CSR PEM")
+		return "", "", "", fmt.Errorf("invalid CSR PEM")
 	}
 	csr, err := x509.ParseCertificateRequest(block.Bytes)
 	if err != nil {
-		return "", "", fmt.Errorf("parse CSR: %w", err)
+		return "", "", "", fmt.Errorf("parse CSR: %w", err)
 	}
 	if err := csr.CheckSignature(); err != nil {
-		return "", "", fmt.Errorf("CSR signature invalid: %w", err)
+		return "", "", "", fmt.Errorf("CSR signature invalid: %w", err)
 	}
 
 	tmpl := &x509.Certificate{
@@ -304,19 +311,20 @@ func signClientCert(caKey *ecdsa.PrivateKey, caCert *x509.Certificate, csrPEM, c
 
 	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, csr.PublicKey, caKey)
 	if err != nil {
-		return "", "", fmt.Errorf("create client cert: %w", err)
+		return "", "", "", fmt.Errorf("create client cert: %w", err)
 	}
 
 	h := sha256.Sum256(der)
 	fingerprint = fmt.Sprintf("%x", h)
 
-	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), fingerprint, nil
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), fingerprint, tmpl.SerialNumber.String(), nil
 }
 
 // issuedCertRecord tracks a signed client certificate.
 type issuedCertRecord struct {
 	KeyName     string    `json:"key_name"`
 	Fingerprint string    `json:"fingerprint"`
+	Serial      string    `json:"serial,omitempty"` // decimal; needed for CRL entries
 	IssuedAt    time.Time `json:"issued_at"`
 	ExpiresAt   time.Time `json:"expires_at"`
 }
@@ -338,11 +346,11 @@ func newIssuedCertStore(filePath string) *issuedCertStore {
 	return s
 }
 
-func (s *issuedCertStore) Add(keyName, fingerprint string, issuedAt, expiresAt time.Time) {
+func (s *issuedCertStore) Add(keyName, fingerprint, serial string, issuedAt, expiresAt time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.records = append(s.records, issuedCertRecord{
-		KeyName: keyName, Fingerprint: fingerprint,
+		KeyName: keyName, Fingerprint: fingerprint, Serial: serial,
 		IssuedAt: issuedAt, ExpiresAt: expiresAt,
 	})
 	s.save()
@@ -360,6 +368,17 @@ func (s *issuedCertStore) FindByKeyName(keyName string) []issuedCertRecord {
 	return out
 }
 
+func (s *issuedCertStore) FindByFingerprint(fingerprint string) (issuedCertRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.records {
+		if r.Fingerprint == fingerprint {
+			return r, true
+		}
+	}
+	return issuedCertRecord{}, false
+}
+
 func (s *issuedCertStore) PruneExpired() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -392,33 +411,222 @@ func (s *issuedCertStore) save() {
 	_ = os.Rename(tmp, s.filePath)
 }
 
-// RevocationList is an in-memory list of revoked certificate fingerprints.
+// RevocationList caches the cluster-wide revocation list (the
+// sandbox-cert-revocations Secret, see pkg/sandbox/revocation). Revocations are
+// written through to the Secret and every gateway replica watches it, so a
+// revoked certificate stays locked out across restarts and HA nodes. With a nil
+// client the list is in-memory only (tests).
 type RevocationList struct {
-	mu      sync.RWMutex
-	entries map[string]time.Time // fingerprint → revokedAt
+	mu   sync.RWMutex
+	file *revocation.File
+	k8s  kubernetes.Interface
 }
 
-func newRevocationList() *RevocationList {
-	return &RevocationList{entries: make(map[string]time.Time)}
+func newRevocationList(k8s kubernetes.Interface) *RevocationList {
+	return &RevocationList{file: revocation.New(), k8s: k8s}
 }
 
-func (rl *RevocationList) Revoke(fingerprint string) {
+func (rl *RevocationList) set(f *revocation.File) {
 	rl.mu.Lock()
-	rl.entries[fingerprint] = time.Now()
+	rl.file = f
 	rl.mu.Unlock()
 }
 
+// update applies mutate to the persisted list (or the in-memory one when no
+// client is configured) and refreshes the cache from the written result.
+func (rl *RevocationList) update(ctx context.Context, mutate func(*revocation.File) bool) error {
+	if rl.k8s == nil {
+		rl.mu.Lock()
+		mutate(rl.file)
+		rl.mu.Unlock()
+		return nil
+	}
+	f, err := revocation.Update(ctx, rl.k8s, mutate)
+	if err != nil {
+		return err
+	}
+	rl.set(f)
+	return nil
+}
+
+// Revoke revokes a single certificate by fingerprint.
+func (rl *RevocationList) Revoke(ctx context.Context, fingerprint string, entry revocation.Cert) error {
+	return rl.update(ctx, func(f *revocation.File) bool {
+		return f.RevokeCert(fingerprint, entry)
+	})
+}
+
+// RevokeByKeyName revokes every certificate issued under keyName so far, on
+// every node: the key cutoff covers certificates this node never saw, and the
+// locally issued ones are also recorded by fingerprint (with serial) so they
+// appear in the signed CRL.
+func (rl *RevocationList) RevokeByKeyName(ctx context.Context, store *issuedCertStore, keyName, reason string) error {
+	now := time.Now()
+	var issued []issuedCertRecord
+	if store != nil {
+		issued = store.FindByKeyName(keyName)
+	}
+	return rl.update(ctx, func(f *revocation.File) bool {
+		f.RevokeKey(keyName, now, reason)
+		for _, r := range issued {
+			exp := r.ExpiresAt
+			f.RevokeCert(r.Fingerprint, revocation.Cert{
+				Serial: r.Serial, KeyName: keyName, RevokedAt: now.UTC(), ExpiresAt: &exp, Reason: reason,
+			})
+		}
+		return true
+	})
+}
+
+// IsRevoked reports whether fingerprint is revoked.
 func (rl *RevocationList) IsRevoked(fingerprint string) bool {
+	if rl == nil {
+		return false
+	}
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.file.IsRevoked(fingerprint, "", time.Time{})
+}
+
+// IsRevokedCert reports whether cert is revoked by fingerprint or by its API
+// key (CN) cutoff.
+func (rl *RevocationList) IsRevokedCert(cert *x509.Certificate) bool {
+	if rl == nil || cert == nil {
+		return false
+	}
+	h := sha256.Sum256(cert.Raw)
 	rl.mu.RLock()
 	defer rl.mu.RUnlock()
-	_, ok := rl.entries[fingerprint]
-	return ok
+	return rl.file.IsRevoked(fmt.Sprintf("%x", h[:]), cert.Subject.CommonName, cert.NotBefore)
 }
 
-func (rl *RevocationList) RevokeByKeyName(store *issuedCertStore, keyName string) {
-	for _, r := range store.FindByKeyName(keyName) {
-		rl.Revoke(r.Fingerprint)
+// Snapshot returns a copy of the cached list.
+func (rl *RevocationList) Snapshot() *revocation.File {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	out := revocation.New()
+	for fp, c := range rl.file.Certs {
+		out.Certs[fp] = c
+	}
+	for name, k := range rl.file.Keys {
+		out.Keys[name] = k
+	}
+	return out
+}
+
+// Load refreshes the cache from the Secret.
+func (rl *RevocationList) Load(ctx context.Context) error {
+	if rl.k8s == nil {
+		return nil
+	}
+	f, err := revocation.Load(ctx, rl.k8s)
+	if err != nil {
+		return err
+	}
+	rl.set(f)
+	return nil
+}
+
+// Prune drops revocations that only cover expired certificates, writing the
+// Secret only when something was removed.
+func (rl *RevocationList) Prune(ctx context.Context) error {
+	return rl.update(ctx, func(f *revocation.File) bool {
+		return f.Prune(time.Now(), clientCertTTLDays*24*time.Hour)
+	})
+}
+
+// Watch keeps the cache in sync with the Secret until ctx is done. Each
+// (re)connect re-reads the Secret first, so no event is lost across watch
+// restarts.
+func (rl *RevocationList) Watch(ctx context.Context) {
+	if rl.k8s == nil {
+		return
+	}
+	for ctx.Err() == nil {
+		if err := rl.Load(ctx); err != nil {
+			logrus.Debugf("sandbox gRPC: load cert revocations: %v", err)
+		}
+		w, err := rl.k8s.CoreV1().Secrets(revocation.SecretNamespace).Watch(ctx, metav1.ListOptions{
+			FieldSelector: "metadata.name=" + revocation.SecretName,
+		})
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for ev := range w.ResultChan() {
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				secret, ok := ev.Object.(*corev1.Secret)
+				if !ok {
+					continue
+				}
+				f, perr := revocation.FromSecret(secret)
+				if perr != nil {
+					logrus.Warnf("sandbox gRPC: cert revocation secret corrupted: %v", perr)
+					continue
+				}
+				rl.set(f)
+			case watch.Deleted:
+				rl.set(revocation.New())
+			}
+		}
+		w.Stop()
+	}
+}
+
+// crlTTL is the NextUpdate horizon of the signed CRL.
+const crlTTL = time.Hour
+
+// buildCRL signs an X.509 CRL listing every revoked certificate with a known
+// serial: fingerprint entries (serial recorded, or resolved from the local
+// issuance ledger) and locally issued certificates covered by a key cutoff.
+func buildCRL(caKey *ecdsa.PrivateKey, caCert *x509.Certificate, f *revocation.File, store *issuedCertStore, now time.Time) ([]byte, int, error) {
+	serials := map[string]time.Time{} // serial → revokedAt
+	for fp, c := range f.Certs {
+		serial := c.Serial
+		if serial == "" && store != nil {
+			if r, ok := store.FindByFingerprint(fp); ok {
+				serial = r.Serial
+			}
+		}
+		if serial != "" {
+			serials[serial] = c.RevokedAt
+		}
+	}
+	if store != nil {
+		for name, k := range f.Keys {
+			for _, r := range store.FindByKeyName(name) {
+				if r.Serial != "" && !r.IssuedAt.After(k.RevokedAt) {
+					if _, ok := serials[r.Serial]; !ok {
+						serials[r.Serial] = k.RevokedAt
+					}
+				}
+			}
+		}
+	}
+	entries := make([]x509.RevocationListEntry, 0, len(serials))
+	for serial, at := range serials {
+		n, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: n, RevocationTime: at})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SerialNumber.Cmp(entries[j].SerialNumber) < 0 })
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(now.Unix()),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlTTL),
+		RevokedCertificateEntries: entries,
+	}, caCert, caKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create CRL: %w", err)
 	}
+	return der, len(entries), nil
 }
 
 // buildMTLSCreds creates gRPC transport credentials with mTLS (VerifyClientCertIfGiven).
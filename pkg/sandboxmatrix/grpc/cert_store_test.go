@@ -41,8 +41,8 @@ func TestIssuedCertStorePruneAndAtomicSave(t *testing.T) {
 	store := newIssuedCertStore(path)
 
 	now := time.Now()
-	store.Add("a", "fp-old", now.Add(-48*time.Hour), now.Add(-time.Hour))
-	store.Add("a", "fp-live", now, now.Add(24*time.Hour))
+	store.Add("a", "fp-old", "", now.Add(-48*time.Hour), now.Add(-time.Hour))
+	store.Add("a", "fp-live", "", now, now.Add(24*time.Hour))
 	store.PruneExpired()
 
 	// Reload from disk — only the live record should remain.
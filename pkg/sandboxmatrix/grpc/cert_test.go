@@ -1,12 +1,23 @@
 package grpc
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	kubefake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/xiaods/k8e/pkg/daemons/config"
+	"github.com/xiaods/k8e/pkg/sandbox/revocation"
 )
 
 // TestCollectServerSANsAdvertiseHostname verifies the AWS/remote-host case: the
@@ -168,3 +179,96 @@ func TestAtomicWriteFile(t *testing.T) {
 		t.Fatalf("temp file left behind: %v", err)
 	}
 }
+
+// TestRevocationListSharedAcrossReplicas: a revocation written by one gateway
+// is seen by another replica (fresh list, same Secret) after Load — the
+// restart / HA case the in-memory list used to miss.
+func TestRevocationListSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	k8s := kubefake.NewSimpleClientset()
+	caKey, caCert := testCA(t)
+	cert := testClientCert(t, caKey, caCert, "agent-a", time.Now().Add(-time.Minute))
+
+	a := newRevocationList(k8s)
+	if err := a.RevokeByKeyName(ctx, nil, "agent-a", "test"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	b := newRevocationList(k8s)
+	if b.IsRevokedCert(cert) {
+		t.Fatal("unloaded replica should not know the revocation yet")
+	}
+	if err := b.Load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !b.IsRevokedCert(cert) {
+		t.Fatal("replica must reject a cert issued before its key was revoked")
+	}
+	later := testClientCert(t, caKey, caCert, "agent-a", time.Now().Add(time.Minute))
+	if b.IsRevokedCert(later) {
+		t.Fatal("cert issued after the key cutoff must remain valid")
+	}
+}
+
+func TestBuildCRLListsRevokedSerials(t *testing.T) {
+	caKey, caCert := testCA(t)
+	store := newIssuedCertStore(filepath.Join(t.TempDir(), "issued.json"))
+	now := time.Now()
+	store.Add("agent-a", "fp-a", "1001", now.Add(-time.Hour), now.Add(time.Hour))
+	store.Add("agent-b", "fp-b", "2002", now.Add(-time.Hour), now.Add(time.Hour))
+
+	f := revocation.New()
+	f.RevokeKey("agent-a", now, "")
+	f.RevokeCert("fp-b", revocation.Cert{RevokedAt: now}) // serial resolved from the ledger
+	f.RevokeCert("fp-x", revocation.Cert{RevokedAt: now}) // unknown serial: omitted
+
+	der, n, err := buildCRL(caKey, caCert, f, store, now)
+	if err != nil {
+		t.Fatalf("buildCRL: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("parse CRL: %v", err)
+	}
+	if err := crl.CheckSignatureFrom(caCert); err != nil {
+		t.Fatalf("CRL not signed by the sandbox CA: %v", err)
+	}
+	if n != 2 || len(crl.RevokedCertificateEntries) != 2 {
+		t.Fatalf("expected 2 CRL entries, got %d", len(crl.RevokedCertificateEntries))
+	}
+	if crl.RevokedCertificateEntries[0].SerialNumber.String() != "1001" ||
+		crl.RevokedCertificateEntries[1].SerialNumber.String() != "2002" {
+		t.Fatalf("unexpected serials: %v, %v", crl.RevokedCertificateEntries[0].SerialNumber, crl.RevokedCertificateEntries[1].SerialNumber)
+	}
+}
+
+func testCA(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	dir := t.TempDir()
+	key, cert, err := generateCA(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"))
+	if err != nil {
+		t.Fatalf("generate CA: %v", err)
+	}
+	return key, cert
+}
+
+func testClientCert(t *testing.T, caKey *ecdsa.PrivateKey, caCert *x509.Certificate, cn string, notBefore time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(notBefore.UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(24 * time.Hour),
+	}, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,367 @@
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+)
+
+// Memory-preserving pause. A MEMORY pause asks the runtime to checkpoint the
+// sandbox container (kubelet ContainerCheckpoint API → CRI → runsc/CRIU),
+// ships the archive off the node into the layer store, and records it on the
+// session. Resume stages the archive back on the same node and creates the
+// pod with the archive as its container image, which CRI runtimes with
+// checkpoint restore support treat as "restore this container". Anything
+// that cannot take part falls back to the filesystem-only pause.
+
+// Checkpointer saves and restores sandbox process memory.
+type Checkpointer interface {
+	// Supports reports whether pods of runtimeClass can be checkpointed.
+	Supports(runtimeClass string) bool
+	// Checkpoint saves pod's sandbox container to durable storage, charged
+	// to owner.
+	Checkpoint(ctx context.Context, pod *corev1.Pod, owner string) (*sandboxv1.SessionCheckpoint, error)
+	// Restore stages ck on its node and rewrites pod (not yet created) so the
+	// runtime restores the container from it instead of starting it fresh.
+	Restore(ctx context.Context, pod *corev1.Pod, ck *sandboxv1.SessionCheckpoint) error
+	// Discard drops a checkpoint that will not be restored.
+	Discard(ctx context.Context, ck *sandboxv1.SessionCheckpoint)
+}
+
+// CheckpointRestoredAnnotation names the checkpoint a pod was restored from.
+const CheckpointRestoredAnnotation = "sandbox.k8e.io/restored-from"
+
+const (
+	// kubeletCheckpointDir is where the kubelet writes checkpoint archives
+	// (default --root-dir) and where restore stages them.
+	kubeletCheckpointDir = "/var/lib/kubelet/checkpoints"
+	// helperCheckpointDir is where a helper pod sees the one archive it
+	// handles.
+	helperCheckpointDir = "/checkpoints"
+	// checkpointHelperImage is the platform's own sandboxd image: helpers
+	// mount host paths, so they never run a session's (user-chosen) image.
+	checkpointHelperImage = sandboxImage
+	// labelRole marks internal pods that are not sandboxes.
+	labelRole            = "sandbox.k8e.io/role"
+	roleCheckpointHelper = "checkpoint-helper"
+	// defaultHelperTimeout bounds waiting for a helper pod to serve.
+	defaultHelperTimeout = 2 * time.Minute
+	// checkpointStreamTimeout bounds shipping one archive either way.
+	checkpointStreamTimeout int32 = 900
+)
+
+// checkpointFileRe matches the archive names the kubelet produces, so they
+// can be used in helper commands unquoted.
+var checkpointFileRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// nodeCheckpointer checkpoints through the kubelet and moves archives
+// between nodes and the layer store with short-lived helper pods: the
+// platform sandboxd image pinned to the node with just the one archive file
+// mounted.
+type nodeCheckpointer struct {
+	k8s     kubernetes.Interface
+	store   *sandboxlayer.Store
+	classes map[string]bool
+
+	// checkpointContainer asks the node's kubelet to checkpoint a container
+	// and returns the archive path on the node. Overridable in tests.
+	checkpointContainer func(ctx context.Context, node, namespace, pod, container string) (string, error)
+	helperTimeout       time.Duration
+}
+
+// NewNodeCheckpointer checkpoints pods of the listed runtime classes ("" is
+// pods without one) into store.
+func NewNodeCheckpointer(k8s kubernetes.Interface, store *sandboxlayer.Store, runtimeClasses []string) Checkpointer {
+	classes := make(map[string]bool, len(runtimeClasses))
+	for _, rc := range runtimeClasses {
+		classes[rc] = true
+	}
+	return &nodeCheckpointer{
+		k8s:                 k8s,
+		store:               store,
+		classes:             classes,
+		checkpointContainer: kubeletCheckpoint(k8s),
+		helperTimeout:       defaultHelperTimeout,
+	}
+}
+
+func (c *nodeCheckpointer) Supports(runtimeClass string) bool { return c.classes[runtimeClass] }
+
+// kubeletCheckpoint calls the kubelet checkpoint endpoint through the API
+// server's node proxy (ContainerCheckpoint feature gate).
+func kubeletCheckpoint(k8s kubernetes.Interface) func(ctx context.Context, node, namespace, pod, container string) (string, error) {
+	return func(ctx context.Context, node, namespace, pod, container string) (string, error) {
+		raw, err := k8s.CoreV1().RESTClient().Post().
+			AbsPath("/api/v1/nodes", node, "proxy", "checkpoint", namespace, pod, container).
+			Do(ctx).Raw()
+		if err != nil {
+			return "", fmt.Errorf("kubelet checkpoint %s/%s on %s: %w", namespace, pod, node, err)
+		}
+		var res struct {
+			Items []string `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &res); err != nil || len(res.Items) == 0 {
+			return "", fmt.Errorf("kubelet checkpoint %s/%s: unexpected response %q", namespace, pod, raw)
+		}
+		return res.Items[0], nil
+	}
+}
+
+func (c *nodeCheckpointer) Checkpoint(ctx context.Context, pod *corev1.Pod, owner string) (*sandboxv1.SessionCheckpoint, error) {
+	node := pod.Spec.NodeName
+	if node == "" {
+		return nil, fmt.Errorf("pod %s is not scheduled", pod.Name)
+	}
+	archive, err := c.checkpointContainer(ctx, node, pod.Namespace, pod.Name, "sandbox")
+	if err != nil {
+		return nil, err
+	}
+	file := path.Base(archive)
+	if !checkpointFileRe.MatchString(file) {
+		return nil, fmt.Errorf("unexpected checkpoint archive %q", archive)
+	}
+
+	ip, release, err := c.startHelper(ctx, pod, node, archive, corev1.HostPathFile)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Stream the archive out and empty it on the node either way: a memory
+	// image left on disk holds whatever secrets were in memory. A mounted
+	// file cannot be unlinked from inside the helper, so it is truncated.
+	httpCtx, cancel := context.WithTimeout(ctx, time.Duration(checkpointStreamTimeout+5)*time.Second)
+	defer cancel()
+	cmd := fmt.Sprintf("base64 < %[1]s/%[2]s; rc=$?; : > %[1]s/%[2]s; exit $rc", helperCheckpointDir, file)
+	resp, err := sandboxdPost(httpCtx, ip, "/exec/stream", sandboxdExecBody("", cmd, checkpointStreamTimeout, "/workspace", nil))
+	if err != nil {
+		return nil, fmt.Errorf("stream checkpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stream checkpoint: HTTP %d", resp.StatusCode)
+	}
+	out := &sseStdout{r: bufio.NewReaderSize(resp.Body, 16*1024)}
+	body := &countingReader{r: base64.NewDecoder(base64.StdEncoding, out)}
+	// restorePieceSize chunks (a multiple of 3) let Restore append the
+	// layers' base64 pieces into one decodable file.
+	layers, err := c.store.PutReader(body, restorePieceSize)
+	if err != nil {
+		return nil, fmt.Errorf("store checkpoint: %w", err)
+	}
+	if !out.exited || out.exitCode != 0 {
+		return nil, fmt.Errorf("stream checkpoint: archive read exited %d (complete=%v)", out.exitCode, out.exited)
+	}
+
+	name := checkpointManifestName(pod)
+	m := sandboxlayer.Manifest{Layers: layers, Chunking: sandboxlayer.ChunkingFixed, Owner: owner}
+	if err := c.store.PublishManifest(name, m); err != nil {
+		return nil, fmt.Errorf("store checkpoint: %w", err)
+	}
+	now := metav1.Now()
+	rc := ""
+	if pod.Spec.RuntimeClassName != nil {
+		rc = *pod.Spec.RuntimeClassName
+	}
+	return &sandboxv1.SessionCheckpoint{
+		Manifest:      name,
+		Node:          node,
+		RuntimeClass:  rc,
+		SizeBytes:     body.n,
+		CreatedAt:     &now,
+		SandboxdKeyID: pod.Annotations[SandboxdKeyIDAnnotation],
+	}, nil
+}
+
+func (c *nodeCheckpointer) Restore(ctx context.Context, pod *corev1.Pod, ck *sandboxv1.SessionCheckpoint) (err error) {
+	m, err := c.store.LoadManifest(ck.Manifest)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s: %w", ck.Manifest, err)
+	}
+	node, err := c.k8s.CoreV1().Nodes().Get(ctx, ck.Node, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("checkpoint node %s: %w", ck.Node, err)
+	}
+	if !nodeReady(node) {
+		return fmt.Errorf("checkpoint node %s is not ready", ck.Node)
+	}
+
+	file := "restore-" + pod.Name + ".tar"
+	ip, release, err := c.startHelper(ctx, pod, ck.Node, kubeletCheckpointDir+"/"+file, corev1.HostPathFileOrCreate)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The base64 pieces are staged in the helper's own emptyDir; only the
+	// decoded archive is written to the node.
+	staged := "/workspace/" + file + ".b64"
+	defer func() {
+		if err != nil {
+			// Leave no partial memory image on the node.
+			sandboxdExecAt(ctx, ip, "", fmt.Sprintf(": > %s/%s", helperCheckpointDir, file), 30) //nolint:errcheck
+		}
+	}()
+	mode := "w"
+	for _, d := range m.Layers {
+		content, err := c.store.Get(d)
+		if err != nil {
+			return fmt.Errorf("checkpoint layer %s: %w", d, err)
+		}
+		if err := writeBase64Pieces(ctx, ip, staged, content, mode); err != nil {
+			return err
+		}
+		mode = "a"
+	}
+	// Written in place: the restored pod is only created after this succeeds.
+	cmd := fmt.Sprintf("set -e; base64 -d %[1]s > %[2]s/%[3]s; rm -f %[1]s",
+		staged, helperCheckpointDir, file)
+	res, err := sandboxdExecAt(ctx, ip, "", cmd, checkpointStreamTimeout)
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("stage checkpoint: exited %d: %s", res.ExitCode, res.Stderr)
+	}
+
+	pod.Spec.NodeName = ck.Node
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == "sandbox" {
+			pod.Spec.Containers[i].Image = kubeletCheckpointDir + "/" + file
+		}
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[CheckpointRestoredAnnotation] = ck.Manifest
+	restampSandboxdAuth(pod, ck.SandboxdKeyID)
+	return nil
+}
+
+func (c *nodeCheckpointer) Discard(ctx context.Context, ck *sandboxv1.SessionCheckpoint) {
+	// The layers go with the next layer GC.
+	if err := c.store.DeleteManifest(ck.Manifest); err != nil {
+		logrus.Warnf("sandbox checkpoint: discard %s: %v", ck.Manifest, err)
+	}
+}
+
+// checkpointManifestName is the layer store name of a session's checkpoint.
+func checkpointManifestName(pod *corev1.Pod) string {
+	if id := pod.Labels[labelSessionID]; id != "" {
+		return "checkpoint-" + id
+	}
+	return "checkpoint-" + pod.Name
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// checkpointHelperPod runs the platform sandboxd on node with the single
+// host file hostFile mounted under helperCheckpointDir. It tolerates only
+// what target tolerates: nodeName skips scheduling, so only NoExecute taints
+// matter, and target is (or will be) running on that node anyway.
+func checkpointHelperPod(name, node, hostFile string, fileType corev1.HostPathType, target *corev1.Pod) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: sandboxNS,
+			Labels:    map[string]string{labelRole: roleCheckpointHelper},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                     node,
+			AutomountServiceAccountToken: boolPtr(false),
+			Containers: []corev1.Container{{
+				Name:  "sandbox",
+				Image: checkpointHelperImage,
+				Ports: []corev1.ContainerPort{{ContainerPort: sandboxdPort}},
+				SecurityContext: &corev1.SecurityContext{
+					ReadOnlyRootFilesystem:   boolPtr(true),
+					AllowPrivilegeEscalation: boolPtr(false),
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "checkpoint", MountPath: helperCheckpointDir + "/" + path.Base(hostFile)},
+					{Name: "workspace", MountPath: "/workspace"},
+				},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(sandboxdPort)},
+					},
+					PeriodSeconds: 1,
+				},
+			}},
+			Volumes: []corev1.Volume{
+				{Name: "checkpoint", VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: hostFile, Type: &fileType},
+				}},
+				{Name: "workspace", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+			Tolerations:   target.Spec.Tolerations,
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	StampSandboxdAuth(pod)
+	return pod
+}
+
+// startHelper creates a helper pod for target with hostFile mounted and
+// waits until its sandboxd serves, returning its IP and a func that deletes
+// it.
+func (c *nodeCheckpointer) startHelper(ctx context.Context, target *corev1.Pod, node, hostFile string, fileType corev1.HostPathType) (string, func(), error) {
+	pods := c.k8s.CoreV1().Pods(sandboxNS)
+	name := "ckpt-" + target.Name
+	// A helper left behind by a crashed gateway would block the name.
+	if err := pods.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return "", nil, fmt.Errorf("checkpoint helper: %w", err)
+	}
+	if _, err := pods.Create(ctx, checkpointHelperPod(name, node, hostFile, fileType, target), metav1.CreateOptions{}); err != nil {
+		return "", nil, fmt.Errorf("checkpoint helper: %w", err)
+	}
+	release := func() {
+		if err := pods.Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			logrus.Warnf("sandbox checkpoint: delete helper %s: %v", name, err)
+		}
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, c.helperTimeout)
+	defer cancel()
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		pod, err := pods.Get(waitCtx, name, metav1.GetOptions{})
+		if err == nil && pod.Status.PodIP != "" && PodReadyCondition(pod) {
+			return pod.Status.PodIP, release, nil
+		}
+		if err == nil && pod.Status.Phase == corev1.PodFailed {
+			release()
+			return "", nil, fmt.Errorf("checkpoint helper %s failed", name)
+		}
+		select {
+		case <-waitCtx.Done():
+			release()
+			return "", nil, fmt.Errorf("checkpoint helper %s not ready: %w", name, waitCtx.Err())
+		case <-tick.C:
+		}
+	}
+}
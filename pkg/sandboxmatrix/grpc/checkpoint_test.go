@@ -0,0 +1,286 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+type fakeCheckpointer struct {
+	supports      bool
+	checkpointErr error
+	restoreErr    error
+	restored      int
+	discarded     []string
+}
+
+func (f *fakeCheckpointer) Supports(string) bool { return f.supports }
+
+func (f *fakeCheckpointer) Checkpoint(ctx context.Context, pod *corev1.Pod, owner string) (*sandboxv1.SessionCheckpoint, error) {
+	if f.checkpointErr != nil {
+		return nil, f.checkpointErr
+	}
+	return &sandboxv1.SessionCheckpoint{Manifest: checkpointManifestName(pod), Node: "node-a", SizeBytes: 42}, nil
+}
+
+func (f *fakeCheckpointer) Restore(ctx context.Context, pod *corev1.Pod, ck *sandboxv1.SessionCheckpoint) error {
+	if f.restoreErr != nil {
+		return f.restoreErr
+	}
+	f.restored++
+	pod.Spec.NodeName = ck.Node
+	pod.Annotations = map[string]string{CheckpointRestoredAnnotation: ck.Manifest}
+	return nil
+}
+
+func (f *fakeCheckpointer) Discard(ctx context.Context, ck *sandboxv1.SessionCheckpoint) {
+	f.discarded = append(f.discarded, ck.Manifest)
+}
+
+func pausedWithMemory(t *testing.T, o *Orchestrator, id string) PauseResult {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: id, TenantId: "tenant-m"}); err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	res, err := o.PauseSession(ctx, id, pb.PauseMode_PAUSE_MODE_MEMORY)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	return res
+}
+
+func TestPauseSession_MemoryCheckpointRestoredOnResume(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	ckpt := &fakeCheckpointer{supports: true}
+	o.SetCheckpointer(ckpt)
+
+	res := pausedWithMemory(t, o, "sess-mem")
+	if res.Mode != pb.PauseMode_PAUSE_MODE_MEMORY || res.Fallback != "" {
+		t.Fatalf("pause result %+v, want memory", res)
+	}
+	paused, _ := o.getSession(ctx, "sess-mem")
+	if ck := paused.Status.Checkpoint; ck == nil || ck.Manifest != "checkpoint-sess-mem" {
+		t.Fatalf("checkpoint not recorded: %+v", paused.Status.Checkpoint)
+	}
+
+	pod, err := o.ResumeSession(ctx, "sess-mem")
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if ckpt.restored != 1 || pod.Annotations[CheckpointRestoredAnnotation] != "checkpoint-sess-mem" || pod.Spec.NodeName != "node-a" {
+		t.Fatalf("pod not restored from checkpoint: restored=%d annotations=%v node=%q", ckpt.restored, pod.Annotations, pod.Spec.NodeName)
+	}
+	resumed, _ := o.getSession(ctx, "sess-mem")
+	if resumed.Status.Checkpoint != nil {
+		t.Fatal("checkpoint must be cleared after resume")
+	}
+	if len(ckpt.discarded) != 1 {
+		t.Fatalf("restored checkpoint should be discarded, got %v", ckpt.discarded)
+	}
+}
+
+func TestPauseSession_MemoryFallsBackToFilesystem(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		c    Checkpointer
+		want string
+	}{
+		{"disabled", nil, "not enabled"},
+		{"unsupported runtime", &fakeCheckpointer{}, "does not support"},
+		{"checkpoint error", &fakeCheckpointer{supports: true, checkpointErr: errors.New("criu: dump failed")}, "criu: dump failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newTestOrchestrator()
+			if tc.c != nil {
+				o.SetCheckpointer(tc.c)
+			}
+			res := pausedWithMemory(t, o, "sess-fb")
+			if res.Mode != pb.PauseMode_PAUSE_MODE_FILESYSTEM || !strings.Contains(res.Fallback, tc.want) {
+				t.Fatalf("pause result %+v, want filesystem fallback mentioning %q", res, tc.want)
+			}
+			after, _ := o.getSession(context.Background(), "sess-fb")
+			if after.Status.Phase != sandboxv1.SandboxPhasePaused || after.Status.Checkpoint != nil {
+				t.Fatalf("want a plain filesystem pause, got %+v", after.Status)
+			}
+		})
+	}
+}
+
+func TestResumeSession_RestoreFailureColdBoots(t *testing.T) {
+	o := newTestOrchestrator()
+	ckpt := &fakeCheckpointer{supports: true}
+	o.SetCheckpointer(ckpt)
+	pausedWithMemory(t, o, "sess-cold")
+
+	ckpt.restoreErr = errors.New("node gone")
+	pod, err := o.ResumeSession(context.Background(), "sess-cold")
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if pod.Annotations[CheckpointRestoredAnnotation] != "" {
+		t.Fatal("cold-booted pod must not claim a restore")
+	}
+	if len(ckpt.discarded) != 1 {
+		t.Fatalf("unusable checkpoint should be discarded, got %v", ckpt.discarded)
+	}
+}
+
+// readyHelpers makes the fake API server report created checkpoint helper
+// pods as running and ready at 127.0.0.1.
+func readyHelpers(k8s *kubefake.Clientset) {
+	k8s.PrependReactor("create", "pods", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		pod := a.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		if pod.Labels[labelRole] == roleCheckpointHelper {
+			pod.Status.PodIP = "127.0.0.1"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		return false, nil, nil
+	})
+}
+
+func TestNodeCheckpointer_RoundTripsThroughLayerStore(t *testing.T) {
+	ctx := context.Background()
+	k8s := kubefake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}},
+	})
+	readyHelpers(k8s)
+	store, err := sandboxlayer.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewNodeCheckpointer(k8s, store, []string{"gvisor"}).(*nodeCheckpointer)
+	c.helperTimeout = 5 * time.Second
+	c.checkpointContainer = func(ctx context.Context, node, ns, pod, container string) (string, error) {
+		return kubeletCheckpointDir + "/checkpoint-" + pod + "_" + ns + "-" + container + ".tar", nil
+	}
+
+	image := bytes.Repeat([]byte("process memory "), restorePieceSize/8) // spans two layers
+	var mu sync.Mutex
+	var staged bytes.Buffer
+	var commands []string
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Command string `json:"command"`
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/exec/stream":
+			commands = append(commands, body.Command)
+			writeExecSSE(w, image, 0)
+		case "/files/write":
+			raw, _ := base64.StdEncoding.DecodeString(body.Content)
+			staged.Write(raw)
+			w.Write([]byte(`{"ok":true}`)) //nolint:errcheck
+		case "/exec":
+			commands = append(commands, body.Command)
+			w.Write([]byte(`{"exit_code":0}`)) //nolint:errcheck
+		}
+	}))
+
+	keys := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	EnableSandboxdAuth(keys)
+	t.Cleanup(func() { EnableSandboxdAuth(nil) })
+	pod := newSessionPod("sess-rt", "workspace-sess-rt", podProfile{runtimeClass: "gvisor", image: "sandbox:v1"})
+	pod.Spec.NodeName = "node-a"
+	if !c.Supports("gvisor") || c.Supports("kata") {
+		t.Fatal("Supports must follow the configured runtime classes")
+	}
+	ck, err := c.Checkpoint(ctx, pod, "tenant-x")
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	m, err := store.LoadManifest(ck.Manifest)
+	if err != nil || m.Owner != "tenant-x" || len(m.Layers) != 2 || ck.SizeBytes != int64(len(image)) {
+		t.Fatalf("manifest %+v err=%v checkpoint=%+v", m, err, ck)
+	}
+	if !strings.Contains(commands[0], ": > /checkpoints/checkpoint-sandbox-sess-rt_sandbox-matrix-sandbox.tar") {
+		t.Fatalf("archive must be emptied on the node: %q", commands[0])
+	}
+
+	restored := newSessionPod("sess-rt", "workspace-sess-rt", podProfile{runtimeClass: "gvisor", image: "sandbox:v1"})
+	if err := c.Restore(ctx, restored, ck); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !bytes.Equal(staged.Bytes(), image) {
+		t.Fatalf("staged %d bytes, want the %d-byte image", staged.Len(), len(image))
+	}
+	if restored.Spec.NodeName != "node-a" || restored.Spec.Containers[0].Image != kubeletCheckpointDir+"/restore-sandbox-sess-rt.tar" {
+		t.Fatalf("restored pod node=%q image=%q", restored.Spec.NodeName, restored.Spec.Containers[0].Image)
+	}
+	// The restored sandboxd keeps the key it was started with.
+	keyID := pod.Annotations[SandboxdKeyIDAnnotation]
+	if keyID == "" || ck.SandboxdKeyID != keyID || restored.Annotations[SandboxdKeyIDAnnotation] != keyID {
+		t.Fatalf("key id: pod %q checkpoint %q restored %q", keyID, ck.SandboxdKeyID, restored.Annotations[SandboxdKeyIDAnnotation])
+	}
+	var keyEnv []string
+	for _, e := range restored.Spec.Containers[0].Env {
+		if e.Name == sandboxdAuthEnv {
+			keyEnv = append(keyEnv, e.Value)
+		}
+	}
+	if len(keyEnv) != 1 || keyEnv[0] != hex.EncodeToString(keys.keyForPod(pod)) {
+		t.Fatalf("restored %s env: %v", sandboxdAuthEnv, keyEnv)
+	}
+	helpers, _ := k8s.CoreV1().Pods(sandboxNS).List(ctx, metav1.ListOptions{})
+	if len(helpers.Items) != 0 {
+		t.Fatalf("helper pods left behind: %d", len(helpers.Items))
+	}
+}
+
+func TestCheckpointHelperPod_MountsOnlyTheArchive(t *testing.T) {
+	target := newSessionPod("sess-h", "workspace-sess-h", podProfile{runtimeClass: "gvisor", image: "user/untrusted:v1"})
+	target.Spec.Tolerations = []corev1.Toleration{{Key: "sandbox", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoExecute}}
+	archive := kubeletCheckpointDir + "/checkpoint-x.tar"
+	pod := checkpointHelperPod("ckpt-x", "node-a", archive, corev1.HostPathFile, target)
+
+	c := pod.Spec.Containers[0]
+	if c.Image != checkpointHelperImage {
+		t.Fatalf("helper image %q: must not run the session image", c.Image)
+	}
+	var host *corev1.HostPathVolumeSource
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath != nil {
+			if host != nil {
+				t.Fatal("more than one hostPath volume")
+			}
+			host = v.HostPath
+		}
+	}
+	if host == nil || host.Path != archive || *host.Type != corev1.HostPathFile {
+		t.Fatalf("hostPath %+v, want the archive file only", host)
+	}
+	if c.VolumeMounts[0].MountPath != helperCheckpointDir+"/checkpoint-x.tar" {
+		t.Fatalf("archive mounted at %q", c.VolumeMounts[0].MountPath)
+	}
+	if len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0].Key != "sandbox" {
+		t.Fatalf("tolerations %+v, want the target's", pod.Spec.Tolerations)
+	}
+	if sc := c.SecurityContext; sc == nil || *sc.AllowPrivilegeEscalation || len(sc.Capabilities.Drop) != 1 {
+		t.Fatalf("security context %+v", sc)
+	}
+}
@@ -0,0 +1,234 @@
+package grpc
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// maxEgressRules bounds the structured egress rules of one session, like
+// maxSessionSecretRefs bounds its secret refs.
+const maxEgressRules = 64
+
+var (
+	// egressHostRe admits DNS names, optionally with Cilium matchPattern
+	// "*" wildcards (*.pypi.org, api-*.example.com).
+	egressHostRe   = regexp.MustCompile(`^[a-z0-9*]([a-z0-9*-]*[a-z0-9*])?(\.[a-z0-9*]([a-z0-9*-]*[a-z0-9*])?)*\.?$`)
+	egressMethodRe = regexp.MustCompile(`^[A-Z]+$`)
+)
+
+// validateEgressRules checks CreateSession / UpdateAllowedHosts egress
+// rules. Defaults (port 443, TCP) are filled in by egressRulesFromPB.
+func validateEgressRules(rules []*pb.EgressRule) error {
+	if len(rules) > maxEgressRules {
+		return fmt.Errorf("too many egress rules: %d (max %d)", len(rules), maxEgressRules)
+	}
+	for i, r := range rules {
+		if r == nil {
+			return fmt.Errorf("rule %d: nil", i)
+		}
+		if err := validateEgressRule(r); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateEgressRule(r *pb.EgressRule) error {
+	switch {
+	case r.Host == "" && r.Cidr == "":
+		return fmt.Errorf("host or cidr is required")
+	case r.Host != "" && r.Cidr != "":
+		return fmt.Errorf("host and cidr are mutually exclusive")
+	case r.Host != "":
+		if len(r.Host) > 253 || !egressHostRe.MatchString(strings.ToLower(r.Host)) {
+			return fmt.Errorf("invalid host %q", r.Host)
+		}
+	default:
+		if _, err := egressCIDR(r.Cidr); err != nil {
+			return err
+		}
+	}
+	for _, p := range r.Ports {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port %d", p)
+		}
+	}
+	proto := strings.ToUpper(r.Protocol)
+	switch proto {
+	case "", "TCP", "UDP", "ANY":
+	default:
+		return fmt.Errorf("invalid protocol %q (TCP, UDP or ANY)", r.Protocol)
+	}
+	if len(r.Http) > 0 && proto != "" && proto != "TCP" {
+		return fmt.Errorf("http rules need protocol TCP")
+	}
+	if len(r.Http) > 0 && (len(r.Ports) == 0 || containsPort(r.Ports, 443)) {
+		return fmt.Errorf("http rules cannot match HTTPS: port 443 carries TLS, which neither Cilium nor the egress proxy inspects; give plain-HTTP ports")
+	}
+	for _, h := range r.Http {
+		if h == nil {
+			return fmt.Errorf("nil http rule")
+		}
+		if h.Method != "" && !egressMethodRe.MatchString(strings.ToUpper(h.Method)) {
+			return fmt.Errorf("invalid http method %q", h.Method)
+		}
+		if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("http path %q must start with /", h.Path)
+		}
+	}
+	return nil
+}
+
+// checkEgressEnforced rejects rules the gateway's egress mode would not
+// enforce as written: without the Cilium DNS proxy (FQDN egress) or the
+// egress proxy, nothing matches traffic against a host name, so a host rule
+// would only open its ports to the whole internet. Template rules reach
+// sessions without validateEgressRules, so this runs on the CRD form.
+func checkEgressEnforced(rules []sandboxv1.EgressRule, hostsEnforced bool) error {
+	for i, r := range rules {
+		r = normalizeEgressRule(r)
+		switch {
+		case r.Host != "" && !hostsEnforced:
+			return fmt.Errorf("rule %d: host %q cannot be enforced without FQDN egress (--cilium-dns-proxy) or the egress proxy (--sandbox-egress-proxy-port); use a cidr rule", i, r.Host)
+		case len(r.HTTP) > 0 && containsPort(r.Ports, 443):
+			return fmt.Errorf("rule %d: http rules cannot match HTTPS on port 443; give plain-HTTP ports", i)
+		}
+	}
+	return nil
+}
+
+// egressCIDR parses a CIDR or a bare IP (a single-address range).
+func egressCIDR(raw string) (string, error) {
+	if ip := net.ParseIP(raw); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, n, err := net.ParseCIDR(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid cidr %q", raw)
+	}
+	return n.String(), nil
+}
+
+// normalizeEgressRule fills in the defaults and canonical spelling. Rules
+// from SandboxTemplates reach the CNP without gRPC validation, so rendering
+// normalizes too.
+func normalizeEgressRule(r sandboxv1.EgressRule) sandboxv1.EgressRule {
+	r.Host = strings.ToLower(r.Host)
+	if r.CIDR != "" {
+		if c, err := egressCIDR(r.CIDR); err == nil {
+			r.CIDR = c
+		}
+	}
+	if len(r.Ports) == 0 {
+		r.Ports = []int32{443}
+	}
+	r.Protocol = strings.ToUpper(r.Protocol)
+	if r.Protocol == "" {
+		r.Protocol = "TCP"
+	}
+	r.HTTP = append([]sandboxv1.EgressHTTPRule(nil), r.HTTP...)
+	for i := range r.HTTP {
+		r.HTTP[i].Method = strings.ToUpper(r.HTTP[i].Method)
+	}
+	return r
+}
+
+// egressRulesFromPB converts validated request rules to their CRD form.
+func egressRulesFromPB(rules []*pb.EgressRule) []sandboxv1.EgressRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]sandboxv1.EgressRule, 0, len(rules))
+	for _, r := range rules {
+		rule := sandboxv1.EgressRule{
+			Host:     r.Host,
+			CIDR:     r.Cidr,
+			Ports:    append([]int32(nil), r.Ports...),
+			Protocol: r.Protocol,
+		}
+		for _, h := range r.Http {
+			rule.HTTP = append(rule.HTTP, sandboxv1.EgressHTTPRule{Method: h.Method, Path: h.Path})
+		}
+		out = append(out, normalizeEgressRule(rule))
+	}
+	return out
+}
+
+// egressRulesToPB is the inverse of egressRulesFromPB.
+func egressRulesToPB(rules []sandboxv1.EgressRule) []*pb.EgressRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]*pb.EgressRule, 0, len(rules))
+	for _, r := range rules {
+		rule := &pb.EgressRule{
+			Host:     r.Host,
+			Cidr:     r.CIDR,
+			Ports:    append([]int32(nil), r.Ports...),
+			Protocol: r.Protocol,
+		}
+		for _, h := range r.HTTP {
+			rule.Http = append(rule.Http, &pb.EgressHTTPRule{Method: h.Method, Path: h.Path})
+		}
+		out = append(out, rule)
+	}
+	return out
+}
+
+// egressRuleCNP renders one rule as a CiliumNetworkPolicy egress entry:
+// hosts become toFQDNs (matchPattern when wildcarded), CIDRs toCIDRSet, and
+// HTTP restrictions rules.http on the port list. checkEgressEnforced keeps
+// host rules out of sessions without FQDN egress; should one still arrive
+// (the flag was turned off after the session was created) it only opens its
+// ports to world, and its HTTP restrictions are dropped rather than applied
+// to all of world.
+func egressRuleCNP(rule sandboxv1.EgressRule, fqdnEnabled bool) map[string]interface{} {
+	r := normalizeEgressRule(rule)
+	ports := make([]interface{}, 0, len(r.Ports))
+	for _, p := range r.Ports {
+		ports = append(ports, map[string]interface{}{"port": strconv.Itoa(int(p)), "protocol": r.Protocol})
+	}
+	toPort := map[string]interface{}{"ports": ports}
+	entry := map[string]interface{}{"toPorts": []interface{}{toPort}}
+	switch {
+	case r.CIDR != "":
+		entry["toCIDRSet"] = []interface{}{map[string]interface{}{"cidr": r.CIDR}}
+	case !fqdnEnabled:
+		entry["toEntities"] = []interface{}{"world"}
+		return entry
+	case strings.Contains(r.Host, "*"):
+		entry["toFQDNs"] = []interface{}{map[string]interface{}{"matchPattern": r.Host}}
+	default:
+		entry["toFQDNs"] = []interface{}{map[string]interface{}{"matchName": r.Host}}
+	}
+	if len(r.HTTP) > 0 {
+		http := make([]interface{}, 0, len(r.HTTP))
+		for _, h := range r.HTTP {
+			m := map[string]interface{}{}
+			if h.Method != "" {
+				m["method"] = h.Method
+			}
+			if h.Path != "" {
+				m["path"] = egressPathRegex(h.Path)
+			}
+			http = append(http, m)
+		}
+		toPort["rules"] = map[string]interface{}{"http": http}
+	}
+	return entry
+}
+
+// egressPathRegex turns a "*" wildcard path into the regular expression
+// Cilium matches against the whole request path.
+func egressPathRegex(path string) string {
+	return strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+}
@@ -0,0 +1,505 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+)
+
+// Credential injection. A SecretRef with hosts never enters the sandbox:
+// its env var holds secretPlaceholder(ref), and the egress proxy terminates
+// CONNECT tunnels to those hosts with a leaf signed by the egress CA,
+// replaces the placeholder with the Secret value in the Authorization header
+// (including Basic credentials) and in the ref's own header, and
+// re-originates TLS to the real host. The query string, the body and other
+// headers are never rewritten: services store or echo them back. Sandbox pods trust the egress CA through the
+// sandbox-egress-ca ConfigMap mounted at egressCAMountPath.
+//
+// Design: docs/sandbox-egress-credentials.md
+
+const (
+	// egressCAName names both the Secret holding the CA key pair and the
+	// ConfigMap publishing its certificate to sandbox pods.
+	egressCAName      = "sandbox-egress-ca"
+	egressCAMountPath = "/etc/k8e/egress-ca"
+	// egressCACert is the egress CA alone; egressCABundle appends it to the
+	// server's system roots, for clients that take a single bundle file.
+	egressCACert   = "ca.crt"
+	egressCABundle = "ca-bundle.crt"
+
+	secretPlaceholderPrefix = "k8e-secret-"
+	// egressLeafTTL is the lifetime of the per-host certificates the proxy
+	// presents to the sandbox; leaves are reissued an hour before expiry.
+	egressLeafTTL = 24 * time.Hour
+	// egressCARetryInterval paces loading the CA until the API server is up.
+	egressCARetryInterval = 10 * time.Second
+)
+
+// systemCABundles are the usual locations of the host's trusted roots,
+// appended to the egress CA in egressCABundle.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",
+}
+
+// secretPlaceholder is the value a host-bound SecretRef's env var carries
+// in the sandbox. It names the secret key, not the session, so forks and
+// sub-agents inherit working placeholders.
+func secretPlaceholder(ref sandboxv1.SecretRef) string {
+	sum := sha256.Sum256([]byte(ref.SecretName + "/" + ref.Key))
+	return secretPlaceholderPrefix + hex.EncodeToString(sum[:8])
+}
+
+// boundSecretRefs returns the session's SecretRefs bound to host.
+func boundSecretRefs(session *sandboxv1.SandboxSession, host string) []sandboxv1.SecretRef {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	var out []sandboxv1.SecretRef
+	for _, ref := range session.Spec.SecretRefs {
+		for _, h := range ref.Hosts {
+			if egressHostMatch(h, host) {
+				out = append(out, ref)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// withEgressProxy points a sandbox pod at the egress proxy on its node and
+// makes it trust the egress CA. The pod requires a node labelled as running
+// the proxy on port. The CA volume is optional so pods start before the
+// first server publishes it.
+func withEgressProxy(spec *corev1.PodSpec, port int) {
+	spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      labelEgressProxy,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{strconv.Itoa(port)},
+				}},
+			}},
+		},
+	}}
+	optional := true
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: egressCAName,
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: egressCAName},
+			Optional:             &optional,
+		}},
+	})
+	c := &spec.Containers[0]
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: egressCAName, MountPath: egressCAMountPath, ReadOnly: true})
+	c.Env = append(c.Env, egressProxyEnv(port)...)
+	bundle := egressCAMountPath + "/" + egressCABundle
+	for _, name := range []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE", "GIT_SSL_CAINFO"} {
+		c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: bundle})
+	}
+	c.Env = append(c.Env, corev1.EnvVar{Name: "NODE_EXTRA_CA_CERTS", Value: egressCAMountPath + "/" + egressCACert})
+}
+
+// egressCA signs the certificates the proxy presents inside credential
+// tunnels.
+type egressCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+func newEgressCA(cert *x509.Certificate, key *ecdsa.PrivateKey) *egressCA {
+	return &egressCA{cert: cert, key: key, leaves: make(map[string]*tls.Certificate)}
+}
+
+// leaf returns a certificate for host, cached until it nears expiry.
+func (ca *egressCA) leaf(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if c, ok := ca.leaves[host]; ok && time.Until(c.Leaf.NotAfter) > time.Hour {
+		return c, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host, Organization: []string{caOrg}},
+		NotBefore:    time.Now().Add(-5 * time.Minute),
+		NotAfter:     time.Now().Add(egressLeafTTL),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign %s: %w", host, err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: parsed}
+	if len(ca.leaves) >= 1024 {
+		ca.leaves = make(map[string]*tls.Certificate)
+	}
+	ca.leaves[host] = c
+	return c, nil
+}
+
+// loadEgressCA reads the egress CA from its Secret, creating it on first
+// use so every server in an HA control plane signs with the same key, and
+// publishes the certificate to the sandbox-egress-ca ConfigMap.
+func loadEgressCA(ctx context.Context, k8s kubernetes.Interface) (*egressCA, error) {
+	secrets := k8s.CoreV1().Secrets(sandboxNS)
+	secret, err := secrets.Get(ctx, egressCAName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		certPEM, keyPEM, gerr := generateEgressCA()
+		if gerr != nil {
+			return nil, gerr
+		}
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: egressCAName, Namespace: sandboxNS},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// Another server created it first.
+			secret, err = secrets.Get(ctx, egressCAName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("egress CA secret: %w", err)
+	}
+	certPEM := secret.Data[corev1.TLSCertKey]
+	cert, key, err := loadCA(certPEM, secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("egress CA secret %s/%s: %w", sandboxNS, egressCAName, err)
+	}
+	if err := publishEgressCA(ctx, k8s, certPEM); err != nil {
+		return nil, fmt.Errorf("egress CA configmap: %w", err)
+	}
+	return newEgressCA(cert, key), nil
+}
+
+func generateEgressCA() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate egress CA key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "K8E Sandbox Egress CA", Organization: []string{caOrg}},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create egress CA cert: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pemEncodeECPrivateKey(key), nil
+}
+
+// publishEgressCA writes the CA certificate and the CA bundle to the
+// ConfigMap sandbox pods mount. An existing ConfigMap is only rewritten
+// when the CA changed, so servers with different system roots do not
+// fight over the bundle.
+func publishEgressCA(ctx context.Context, k8s kubernetes.Interface, certPEM []byte) error {
+	bundle := append([]byte(nil), certPEM...)
+	for _, path := range systemCABundles {
+		if roots, err := os.ReadFile(path); err == nil {
+			bundle = append(bundle, roots...)
+			break
+		}
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: egressCAName, Namespace: sandboxNS},
+		Data:       map[string]string{egressCACert: string(certPEM), egressCABundle: string(bundle)},
+	}
+	configMaps := k8s.CoreV1().ConfigMaps(sandboxNS)
+	existing, err := configMaps.Get(ctx, egressCAName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Data[egressCACert] == string(certPEM) {
+		return nil
+	}
+	cm.ResourceVersion = existing.ResourceVersion
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// loadCA loads the egress CA into the proxy, retrying until ctx is done.
+// Until then credential tunnels are refused.
+func (p *egressProxy) loadCA(ctx context.Context, k8s kubernetes.Interface) {
+	for {
+		ca, err := loadEgressCA(ctx, k8s)
+		if err == nil {
+			p.ca.Store(ca)
+			return
+		}
+		logrus.Warnf("sandbox egress proxy: %v (retrying)", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(egressCARetryInterval):
+		}
+	}
+}
+
+// egressCredential is a host-bound SecretRef resolved for one tunnel.
+type egressCredential struct {
+	placeholder string
+	value       string
+	// header is the ref's own request header (canonical), if any.
+	header string
+}
+
+// secretValues resolves refs into their credentials.
+func (p *egressProxy) secretValues(ctx context.Context, refs []sandboxv1.SecretRef) ([]egressCredential, error) {
+	out := make([]egressCredential, 0, len(refs))
+	for _, ref := range refs {
+		sec, err := p.orch.k8s.CoreV1().Secrets(sandboxNS).Get(ctx, ref.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("egress proxy: secret for %s: %w", ref.EnvVar, err)
+		}
+		raw, ok := sec.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("egress proxy: key %q not found in secret %q", ref.Key, ref.SecretName)
+		}
+		cred := egressCredential{placeholder: secretPlaceholder(ref), value: string(raw)}
+		if ref.Header != "" {
+			cred.header = http.CanonicalHeaderKey(ref.Header)
+		}
+		out = append(out, cred)
+	}
+	return out, nil
+}
+
+// injectCredentials swaps placeholders for secret values in the
+// Authorization header — the credentials of any scheme, or the user or
+// password of Basic — and in each credential's own header, whose whole
+// value must be the placeholder. Nothing else is rewritten: a placeholder
+// in a field the host stores or echoes (query, body, other headers) would
+// hand the value back to the sandbox.
+func injectCredentials(req *http.Request, creds []egressCredential) {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", injectAuthorization(auth, creds))
+	}
+	for _, c := range creds {
+		if c.header != "" && req.Header.Get(c.header) == c.placeholder {
+			req.Header.Set(c.header, c.value)
+		}
+	}
+}
+
+func injectAuthorization(auth string, creds []egressCredential) string {
+	scheme, cred, ok := strings.Cut(auth, " ")
+	if !ok {
+		return auth
+	}
+	cred = strings.TrimSpace(cred)
+	if !strings.EqualFold(scheme, "Basic") {
+		return scheme + " " + credentialValue(cred, creds)
+	}
+	raw, err := base64.StdEncoding.DecodeString(cred)
+	if err != nil {
+		return auth
+	}
+	user, pass, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return auth
+	}
+	pair := credentialValue(user, creds) + ":" + credentialValue(pass, creds)
+	return scheme + " " + base64.StdEncoding.EncodeToString([]byte(pair))
+}
+
+// credentialValue returns the secret s is the placeholder of, else s.
+func credentialValue(s string, creds []egressCredential) string {
+	for _, c := range creds {
+		if s == c.placeholder {
+			return c.value
+		}
+	}
+	return s
+}
+
+// credentialTunnel serves a CONNECT to a host bound to refs: TLS from the
+// sandbox ends here, each HTTP/1.1 request gets its credentials injected
+// and goes upstream over a fresh TLS connection to the CONNECT target.
+func (p *egressProxy) credentialTunnel(w http.ResponseWriter, r *http.Request, session *sandboxv1.SandboxSession, host string, port int, refs []sandboxv1.SecretRef) {
+	ca := p.ca.Load()
+	if ca == nil {
+		p.orch.recordEgress(session, host, port, true)
+		http.Error(w, "egress proxy: credential CA not loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+	creds, err := p.secretValues(r.Context(), refs)
+	if err != nil {
+		p.orch.recordEgress(session, host, port, true)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "egress proxy: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+	tlsConn := tls.Server(bufferedConn{Conn: conn, r: rw.Reader}, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" && !strings.EqualFold(strings.TrimSuffix(hello.ServerName, "."), host) {
+				p.orch.recordEgress(session, hello.ServerName, port, true)
+				return nil, fmt.Errorf("egress proxy: SNI %q does not match CONNECT host %q", hello.ServerName, host)
+			}
+			return ca.leaf(strings.ToLower(host))
+		},
+	})
+	ctx := context.WithValue(r.Context(), egressSessionKey{}, session)
+	hctx, cancel := context.WithTimeout(ctx, egressProxyDialTimeout)
+	err = tlsConn.HandshakeContext(hctx)
+	cancel()
+	if err != nil {
+		return
+	}
+	p.orch.recordEgress(session, host, port, false)
+
+	target := net.JoinHostPort(host, fmt.Sprint(port))
+	hostHeader := host
+	if port != 443 {
+		hostHeader = target
+	}
+	br := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme, req.URL.Host = "https", target
+		req.RequestURI = ""
+		req.Header.Del("Proxy-Connection")
+		req.Header.Del("Proxy-Authorization")
+		// The request goes to the CONNECT target whatever its Host says:
+		// another Host would front the credential to a different vhost
+		// behind the same address.
+		if !tunnelHostMatches(req.Host, host, port) {
+			p.orch.recordEgress(session, req.Host, port, true)
+			writeTunnelError(tlsConn, http.StatusMisdirectedRequest, fmt.Sprintf("Host %q does not match CONNECT host %q", req.Host, host))
+			return
+		}
+		req.Host = hostHeader
+		injectCredentials(req, creds)
+		resp, err := p.upstream.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			writeTunnelError(tlsConn, http.StatusBadGateway, err.Error())
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		if resp.ContentLength < 0 && len(resp.TransferEncoding) == 0 {
+			resp.TransferEncoding = []string{"chunked"}
+		}
+		werr := resp.Write(tlsConn)
+		resp.Body.Close()
+		if werr != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// tunnelHostMatches reports whether a request's Host names the CONNECT
+// target host:port (an empty Host is filled in from the target).
+func tunnelHostMatches(reqHost, host string, port int) bool {
+	if reqHost == "" {
+		return true
+	}
+	h, p, err := net.SplitHostPort(reqHost)
+	if err != nil {
+		h, p = reqHost, ""
+	}
+	return strings.EqualFold(strings.TrimSuffix(h, "."), host) && (p == "" || p == fmt.Sprint(port))
+}
+
+// writeTunnelError answers a request inside a credential tunnel and closes
+// it.
+func writeTunnelError(w io.Writer, code int, msg string) {
+	body := "egress proxy: " + msg
+	resp := &http.Response{
+		StatusCode:    code,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		Close:         true,
+	}
+	resp.Write(w)
+}
+
+// bufferedConn reads through the bufio.Reader a hijacked connection may
+// have already filled.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
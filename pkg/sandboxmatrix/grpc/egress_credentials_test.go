@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func TestHostBoundSecret_PlaceholderEnv(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	req := &pb.CreateSessionRequest{
+		SessionId: "cred-1",
+		SecretRefs: []*pb.SecretRef{
+			{SecretName: "gh", Key: "token", EnvVar: "GITHUB_TOKEN", Hosts: []string{"API.github.com"}},
+		},
+	}
+	if _, err := s.orch.CreateSession(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("host-bound secret without the egress proxy: %v", err)
+	}
+
+	enableEgressProxy(3129)
+	t.Cleanup(func() { enableEgressProxy(0) })
+	if _, err := s.orch.CreateSession(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	// No Secret exists: a bound ref is never read for the sandbox env.
+	env, err := s.resolveSessionEnv(ctx, "cred-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := sandboxv1.SecretRef{SecretName: "gh", Key: "token"}
+	if env["GITHUB_TOKEN"] != secretPlaceholder(ref) || !strings.HasPrefix(env["GITHUB_TOKEN"], secretPlaceholderPrefix) {
+		t.Fatalf("env: %v", env)
+	}
+
+	pod, err := s.k8s.CoreV1().Pods(sandboxNS).Get(ctx, "sandbox-cred-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	podEnv := map[string]string{}
+	for _, e := range pod.Spec.Containers[0].Env {
+		podEnv[e.Name] = e.Value
+	}
+	if podEnv["SSL_CERT_FILE"] != "/etc/k8e/egress-ca/ca-bundle.crt" || podEnv["NODE_EXTRA_CA_CERTS"] != "/etc/k8e/egress-ca/ca.crt" {
+		t.Fatalf("CA env: %v", podEnv)
+	}
+	var mounted bool
+	for _, v := range pod.Spec.Volumes {
+		mounted = mounted || (v.ConfigMap != nil && v.ConfigMap.Name == egressCAName)
+	}
+	if !mounted {
+		t.Fatal("egress CA configmap not mounted")
+	}
+}
+
+func TestEgressProxy_InjectsCredentials(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization")+"|"+r.URL.Query().Get("key")+"|"+r.Header.Get("X-Api-Key")+"|"+r.Header.Get("X-Echo")) //nolint:errcheck
+	}))
+	defer upstream.Close()
+
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	if _, err := o.k8s.CoreV1().Secrets(sandboxNS).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gh", Namespace: sandboxNS},
+		Data:       map[string][]byte{"token": []byte("ghp_real")},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ref := sandboxv1.SecretRef{SecretName: "gh", Key: "token", EnvVar: "GITHUB_TOKEN", Hosts: []string{"127.0.0.1"}, Header: "x-api-key"}
+	sess := &sandboxv1.SandboxSession{}
+	sess.Name, sess.Namespace = "cred-2", sandboxNS
+	sess.Spec.SecretRefs = []sandboxv1.SecretRef{ref}
+	sess.Spec.EgressRules = []sandboxv1.EgressRule{{CIDR: "127.0.0.1", Ports: []int32{listenerPort(t, upstream)}}}
+	sess.Status.Phase, sess.Status.PodIP = sandboxv1.SandboxPhaseActive, "127.0.0.1"
+	obj, _ := sessionToUnstructured(sess)
+	o.watch.store = newSessionIndexer()
+	o.watch.store.Add(obj) //nolint:errcheck
+
+	p := newEgressProxy(o)
+	upstreamRoots := x509.NewCertPool()
+	upstreamRoots.AddCert(upstream.Certificate())
+	p.upstream.TLSClientConfig = &tls.Config{RootCAs: upstreamRoots}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	sandboxRoots := x509.NewCertPool()
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: sandboxRoots},
+	}}
+	placeholder := secretPlaceholder(ref)
+	do := func(setAuth func(*http.Request)) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/user?key="+placeholder, nil)
+		setAuth(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+placeholder) }
+
+	if code, _ := do(bearer); code != 0 {
+		t.Fatalf("credential tunnel before the CA loads must fail, got %d", code)
+	}
+
+	ca, err := loadEgressCA(ctx, o.k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ca.Store(ca)
+	cm, err := o.k8s.CoreV1().ConfigMaps(sandboxNS).Get(ctx, egressCAName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("CA configmap: %v", err)
+	}
+	if !sandboxRoots.AppendCertsFromPEM([]byte(cm.Data[egressCACert])) {
+		t.Fatal("configmap ca.crt is not a certificate")
+	}
+
+	// Only Authorization and the ref's own header carry the value; a
+	// placeholder anywhere else reaches the host as is.
+	if code, body := do(bearer); code != http.StatusOK || body != "Bearer ghp_real|"+placeholder+"||" {
+		t.Fatalf("bearer: %d %q", code, body)
+	}
+	code, body := do(func(r *http.Request) {
+		r.Header.Set("X-Api-Key", placeholder)
+		r.Header.Set("X-Echo", "token="+placeholder)
+	})
+	if code != http.StatusOK || body != "|"+placeholder+"|ghp_real|token="+placeholder {
+		t.Fatalf("header: %d %q", code, body)
+	}
+	code, body = do(func(r *http.Request) { r.SetBasicAuth("x-access-token", placeholder) })
+	want := "x-access-token:ghp_real"
+	if code != http.StatusOK || !strings.HasPrefix(body, "Basic ") {
+		t.Fatalf("basic: %d %q", code, body)
+	}
+	req := &http.Request{Header: http.Header{"Authorization": {strings.SplitN(body, "|", 2)[0]}}}
+	if u, pw, _ := req.BasicAuth(); u+":"+pw != want {
+		t.Fatalf("basic credentials: %q", u+":"+pw)
+	}
+
+	// Domain fronting: a Host other than the CONNECT target never gets
+	// the credential.
+	if code, body := do(func(r *http.Request) {
+		bearer(r)
+		r.Host = "other.example.com"
+	}); code != http.StatusMisdirectedRequest || strings.Contains(body, "ghp_real") {
+		t.Fatalf("fronted Host: %d %q", code, body)
+	}
+
+	again, err := loadEgressCA(ctx, o.k8s)
+	if err != nil || !again.cert.Equal(ca.cert) {
+		t.Fatalf("second load must reuse the CA secret: %v", err)
+	}
+}
@@ -0,0 +1,625 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// CNI-agnostic egress enforcement (--sandbox-egress-proxy-port). Without
+// Cilium there is no CiliumNetworkPolicy to scope a session's egress, so
+// every k8e server runs a forward proxy instead:
+//
+//   - sandbox pods get HTTP(S)_PROXY pointing at the proxy on their node
+//     (status.hostIP) and are pinned to the nodes of servers running it
+//     (labelEgressProxy), never agents;
+//   - the proxy identifies the session by the pod's source IP and checks
+//     each CONNECT target, TLS SNI and plain-HTTP request against the
+//     session's allowedHosts and egressRules, publishing an EGRESS session
+//     event per connection;
+//   - a per-session Kubernetes NetworkPolicy (in place of the CNP) blocks
+//     all other egress: only DNS, the proxy port on the nodes and the
+//     session's CIDR rules stay open;
+//   - tunnels to hosts bound to a SecretRef carry injected credentials
+//     (egress_credentials.go).
+//
+// Design: docs/sandbox-egress-proxy.md
+
+const (
+	// egressProxyHostEnv carries the pod's node IP into the proxy URLs.
+	egressProxyHostEnv = "K8E_EGRESS_PROXY_HOST"
+	// labelEgressProxy marks the nodes running the egress proxy with its
+	// port; proxy-mode sandbox pods require it.
+	labelEgressProxy = "sandbox.k8e.io/egress-proxy-port"
+	// egressProxyLabelRetry spaces attempts to label a node that has not
+	// registered yet.
+	egressProxyLabelRetry = 10 * time.Second
+	// egressProxyDialTimeout bounds connecting to the upstream host.
+	egressProxyDialTimeout = 10 * time.Second
+	// egressProxyHelloTimeout is how long a tunnel waits for a TLS
+	// ClientHello; protocols where the server speaks first go on unchecked.
+	egressProxyHelloTimeout = 2 * time.Second
+)
+
+// egressProxyPort is the proxy port new sandbox pods are pointed at; zero
+// (default) leaves egress to the session CNP.
+var egressProxyPort atomic.Int32
+
+// enableEgressProxy switches new sandbox pods and session network policies
+// to the egress proxy on port.
+func enableEgressProxy(port int) { egressProxyPort.Store(int32(port)) }
+
+func egressProxyEnabled() bool { return egressProxyPort.Load() > 0 }
+
+// egressProxyEnv returns the sandbox container env routing HTTP(S) through
+// the egress proxy on the pod's node.
+func egressProxyEnv(port int) []corev1.EnvVar {
+	proxyURL := fmt.Sprintf("http://$(%s):%d", egressProxyHostEnv, port)
+	noProxy := "localhost,127.0.0.1,::1"
+	return []corev1.EnvVar{
+		{Name: egressProxyHostEnv, ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"},
+		}},
+		{Name: "HTTP_PROXY", Value: proxyURL},
+		{Name: "HTTPS_PROXY", Value: proxyURL},
+		{Name: "http_proxy", Value: proxyURL},
+		{Name: "https_proxy", Value: proxyURL},
+		{Name: "NO_PROXY", Value: noProxy},
+		{Name: "no_proxy", Value: noProxy},
+	}
+}
+
+// egressAllowed reports whether session may reach host:port. method and
+// path are empty for CONNECT tunnels, whose requests the proxy cannot see:
+// rules with HTTP restrictions never admit a tunnel. A session without
+// allowedHosts or egressRules may reach any host on 443, like the CNP's
+// world-443 default.
+func egressAllowed(session *sandboxv1.SandboxSession, host string, port int, method, path string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if len(session.Spec.AllowedHosts) == 0 && len(session.Spec.EgressRules) == 0 {
+		return port == 443
+	}
+	if port == 443 {
+		for _, h := range session.Spec.AllowedHosts {
+			if strings.EqualFold(strings.TrimSuffix(h, "."), host) {
+				return true
+			}
+		}
+	}
+	ip := net.ParseIP(host)
+	for _, rule := range session.Spec.EgressRules {
+		r := normalizeEgressRule(rule)
+		if r.Protocol == "UDP" || !containsPort(r.Ports, port) {
+			continue
+		}
+		switch {
+		case r.CIDR != "":
+			if !cidrContains(r.CIDR, ip) {
+				continue
+			}
+		case !egressHostMatch(r.Host, host):
+			continue
+		}
+		if len(r.HTTP) == 0 {
+			return true
+		}
+		if method != "" && egressHTTPMatch(r.HTTP, method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// egressIPAllowed reports whether the proxy may dial ip for session. The
+// CNP's world entity excludes the cluster and the node, so only public
+// addresses are reachable unless a CIDR rule opens the range.
+func egressIPAllowed(session *sandboxv1.SandboxSession, ip net.IP, port int) bool {
+	if !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast() {
+		return true
+	}
+	for _, rule := range session.Spec.EgressRules {
+		r := normalizeEgressRule(rule)
+		if r.CIDR != "" && r.Protocol != "UDP" && containsPort(r.Ports, port) && cidrContains(r.CIDR, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPort(ports []int32, port int) bool {
+	for _, p := range ports {
+		if int(p) == port {
+			return true
+		}
+	}
+	return false
+}
+
+func cidrContains(cidr string, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	_, n, err := net.ParseCIDR(cidr)
+	return err == nil && n.Contains(ip)
+}
+
+// egressHostMatch matches host against an exact name or a Cilium
+// matchPattern, where "*" stands for any DNS characters within one label
+// and a lone "*" for every host.
+func egressHostMatch(pattern, host string) bool {
+	pattern = strings.TrimSuffix(pattern, ".")
+	if !strings.Contains(pattern, "*") {
+		return pattern == host
+	}
+	if pattern == "*" {
+		return true
+	}
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, "[-a-z0-9_]*") + "$"
+	ok, _ := regexp.MatchString(re, host)
+	return ok
+}
+
+func egressHTTPMatch(rules []sandboxv1.EgressHTTPRule, method, path string) bool {
+	for _, h := range rules {
+		if h.Method != "" && h.Method != strings.ToUpper(method) {
+			continue
+		}
+		if h.Path != "" {
+			if ok, _ := regexp.MatchString("^"+egressPathRegex(h.Path)+"$", path); !ok {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sessionByPodIP returns the active session whose pod holds ip, from the
+// session informer's pod IP index when it has synced.
+func (o *Orchestrator) sessionByPodIP(ctx context.Context, ip string) *sandboxv1.SandboxSession {
+	var sessions []*sandboxv1.SandboxSession
+	o.watch.mu.Lock()
+	store := o.watch.store
+	o.watch.mu.Unlock()
+	if store != nil {
+		objs, _ := store.ByIndex(sessionPodIPIndex, ip)
+		for _, obj := range objs {
+			if s := objToSession(obj); s != nil {
+				sessions = append(sessions, s)
+			}
+		}
+	} else {
+		sessions, _ = o.listSessions(ctx, sandboxNS, string(sandboxv1.SandboxPhaseActive))
+	}
+	for _, s := range sessions {
+		if s.Status.PodIP == ip && s.Status.Phase == sandboxv1.SandboxPhaseActive {
+			return s
+		}
+	}
+	return nil
+}
+
+// recordEgress publishes one proxied connection as an EGRESS event.
+func (o *Orchestrator) recordEgress(session *sandboxv1.SandboxSession, host string, port int, denied bool) {
+	verdict := "allowed"
+	if denied {
+		verdict = "denied"
+	}
+	sandboxEgressProxyConnections.WithLabelValues(verdict).Inc()
+	logrus.Debugf("sandbox egress proxy: session %s %s %s:%d", session.Name, verdict, host, port)
+	ev := sessionEvent(session, pb.SessionEventType_SESSION_EVENT_EGRESS)
+	ev.EgressHost, ev.Port, ev.EgressDenied = host, int32(port), denied
+	o.watch.publish(ev)
+}
+
+// egressProxy is the forward proxy sandbox pods reach through HTTP(S)_PROXY.
+type egressProxy struct {
+	orch    *Orchestrator
+	forward *httputil.ReverseProxy
+	// upstream carries forwarded plain-HTTP and credential-tunnel requests.
+	upstream *http.Transport
+	// ca signs credential tunnels; nil until loaded.
+	ca atomic.Pointer[egressCA]
+}
+
+type egressSessionKey struct{}
+
+func newEgressProxy(orch *Orchestrator) *egressProxy {
+	p := &egressProxy{orch: orch}
+	p.upstream = &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			session, _ := ctx.Value(egressSessionKey{}).(*sandboxv1.SandboxSession)
+			if session == nil {
+				return nil, errors.New("egress proxy: no session")
+			}
+			host, port := splitEgressTarget(addr, 80)
+			return p.dial(ctx, session, host, port)
+		},
+		// Pass the sandbox's Accept-Encoding and bodies through as sent.
+		DisableCompression:    true,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       30 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Minute,
+	}
+	p.forward = &httputil.ReverseProxy{
+		// Forward-proxy requests already carry the absolute target URL.
+		Director:  func(*http.Request) {},
+		Transport: p.upstream,
+	}
+	return p
+}
+
+// serveEgressProxy runs the egress proxy on port until ctx is done.
+func (s *Server) serveEgressProxy(ctx context.Context, port int) error {
+	proxy := newEgressProxy(s.orch)
+	go proxy.loadCA(ctx, s.k8s)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", port),
+		Handler:           proxy,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	logrus.Infof("sandbox egress proxy listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	session := p.orch.sessionByPodIP(r.Context(), ip)
+	if session == nil {
+		http.Error(w, "egress proxy: "+ip+" is not an active sandbox session", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, session)
+		return
+	}
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "egress proxy: expected an absolute http:// URL or CONNECT", http.StatusBadRequest)
+		return
+	}
+	host, port := splitEgressTarget(r.URL.Host, 80)
+	if !egressAllowed(session, host, port, r.Method, r.URL.Path) {
+		p.orch.recordEgress(session, host, port, true)
+		http.Error(w, fmt.Sprintf("egress proxy: %s %s:%d%s is not allowed for session %s", r.Method, host, port, r.URL.Path, session.Name), http.StatusForbidden)
+		return
+	}
+	p.orch.recordEgress(session, host, port, false)
+	p.forward.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), egressSessionKey{}, session)))
+}
+
+// tunnel serves CONNECT: the target must be allowed, and so must the SNI
+// of a TLS ClientHello sent through it. Tunnels to hosts bound to a
+// SecretRef are terminated here to inject the credential.
+func (p *egressProxy) tunnel(w http.ResponseWriter, r *http.Request, session *sandboxv1.SandboxSession) {
+	host, port := splitEgressTarget(r.Host, 443)
+	if !egressAllowed(session, host, port, "", "") {
+		p.orch.recordEgress(session, host, port, true)
+		http.Error(w, fmt.Sprintf("egress proxy: %s:%d is not allowed for session %s", host, port, session.Name), http.StatusForbidden)
+		return
+	}
+	if refs := boundSecretRefs(session, host); len(refs) > 0 {
+		p.credentialTunnel(w, r, session, host, port, refs)
+		return
+	}
+	upstream, err := p.dial(r.Context(), session, host, port)
+	if err != nil {
+		p.orch.recordEgress(session, host, port, true)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "egress proxy: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	sni, hello := peekClientHello(conn, rw.Reader)
+	if sni != "" && !egressAllowed(session, sni, port, "", "") {
+		p.orch.recordEgress(session, sni, port, true)
+		return
+	}
+	p.orch.recordEgress(session, host, port, false)
+	if _, err := upstream.Write(hello); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, rw.Reader)
+		if c, ok := upstream.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+	<-done
+}
+
+// dial connects to host:port on one of its addresses that session may
+// reach.
+func (p *egressProxy) dial(ctx context.Context, session *sandboxv1.SandboxSession, host string, port int) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, egressProxyDialTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("egress proxy: resolve %s: %w", host, err)
+	}
+	var d net.Dialer
+	err = fmt.Errorf("egress proxy: %s resolves to no address session %s may reach", host, session.Name)
+	for _, a := range addrs {
+		if !egressIPAllowed(session, a.IP, port) {
+			continue
+		}
+		conn, derr := d.DialContext(ctx, "tcp", net.JoinHostPort(a.IP.String(), strconv.Itoa(port)))
+		if derr == nil {
+			return conn, nil
+		}
+		err = fmt.Errorf("egress proxy: %w", derr)
+	}
+	return nil, err
+}
+
+// splitEgressTarget splits "host[:port]" (IPv6 in brackets), defaulting
+// the port.
+func splitEgressTarget(hostport string, defaultPort int) (string, int) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), defaultPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, defaultPort
+	}
+	return host, port
+}
+
+// errHelloRead stops the TLS handshake once the ClientHello is parsed.
+var errHelloRead = errors.New("client hello read")
+
+// peekClientHello reads a TLS ClientHello from the start of a tunnel and
+// returns its SNI plus every byte consumed, to be replayed upstream. A
+// tunnel that does not start with a TLS handshake record within
+// egressProxyHelloTimeout yields no SNI and only the bytes already sent.
+func peekClientHello(conn net.Conn, r *bufio.Reader) (string, []byte) {
+	conn.SetReadDeadline(time.Now().Add(egressProxyHelloTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	first, err := r.Peek(1)
+	if err != nil || first[0] != 0x16 { // TLS handshake record
+		b, _ := r.Peek(r.Buffered())
+		hello := append([]byte(nil), b...)
+		r.Discard(len(hello))
+		return "", hello
+	}
+	var consumed bytes.Buffer
+	var sni string
+	tls.Server(helloConn{r: io.TeeReader(r, &consumed)}, &tls.Config{
+		GetConfigForClient: func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni = h.ServerName
+			return nil, errHelloRead
+		},
+	}).Handshake()
+	return sni, consumed.Bytes()
+}
+
+// helloConn feeds a tls.Server handshake from a reader; everything it
+// would send is dropped.
+type helloConn struct{ r io.Reader }
+
+func (c helloConn) Read(b []byte) (int, error)     { return c.r.Read(b) }
+func (helloConn) Write(b []byte) (int, error)      { return len(b), nil }
+func (helloConn) Close() error                     { return nil }
+func (helloConn) SetDeadline(time.Time) error      { return nil }
+func (helloConn) SetReadDeadline(time.Time) error  { return nil }
+func (helloConn) SetWriteDeadline(time.Time) error { return nil }
+func (helloConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (helloConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+
+// sessionNetworkPolicyName matches the CNP name it replaces.
+func sessionNetworkPolicyName(session *sandboxv1.SandboxSession) string {
+	return fmt.Sprintf("sandbox-session-%s", session.Name)
+}
+
+// buildSessionNetworkPolicy returns the egress-only NetworkPolicy pairing
+// the proxy: DNS anywhere, the proxy port on every node, and the session's
+// CIDR rules. Ingress is left unrestricted, as without Cilium before.
+func buildSessionNetworkPolicy(session *sandboxv1.SandboxSession, proxyPort int, nodeIPs []string) *networkingv1.NetworkPolicy {
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	npPort := func(proto corev1.Protocol, port int32) networkingv1.NetworkPolicyPort {
+		p := intstr.FromInt32(port)
+		return networkingv1.NetworkPolicyPort{Protocol: &proto, Port: &p}
+	}
+	// DNS only to the cluster resolver: port 53 anywhere else would be a
+	// tunnel around the proxy.
+	egress := []networkingv1.NetworkPolicyEgressRule{{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "kube-system"}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{npPort(udp, 53), npPort(tcp, 53)},
+	}}
+	if len(nodeIPs) > 0 {
+		proxy := networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{npPort(tcp, int32(proxyPort))}}
+		for _, ip := range nodeIPs {
+			cidr, err := egressCIDR(ip)
+			if err != nil {
+				continue
+			}
+			proxy.To = append(proxy.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		egress = append(egress, proxy)
+	}
+	for _, rule := range session.Spec.EgressRules {
+		r := normalizeEgressRule(rule)
+		if r.CIDR == "" {
+			continue
+		}
+		e := networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: r.CIDR}}},
+		}
+		for _, port := range r.Ports {
+			if r.Protocol != "UDP" {
+				e.Ports = append(e.Ports, npPort(tcp, port))
+			}
+			if r.Protocol != "TCP" {
+				e.Ports = append(e.Ports, npPort(udp, port))
+			}
+		}
+		egress = append(egress, e)
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sessionNetworkPolicyName(session),
+			Namespace: session.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{labelSessionID: session.Name}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+}
+
+// nodeIPs lists the internal IPs of every node: sandbox pods reach the
+// proxy on their own node.
+func (o *Orchestrator) nodeIPs(ctx context.Context) ([]string, error) {
+	nodes, err := o.k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, n := range nodes.Items {
+		for _, a := range n.Status.Addresses {
+			if a.Type == corev1.NodeInternalIP {
+				ips = append(ips, a.Address)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// checkEgressProxyNodes rejects a server whose egress proxy setting differs
+// from the servers already running: node labels record each proxy's port.
+// A stale label on this server's own node is dropped when the proxy is off.
+func (o *Orchestrator) checkEgressProxyNodes(ctx context.Context, nodeName string, port int) error {
+	if port > 0 && nodeName == "" {
+		return errors.New("egress proxy: server node name unknown")
+	}
+	nodes, err := o.k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: labelEgressProxy})
+	if err != nil {
+		return fmt.Errorf("egress proxy: list nodes: %w", err)
+	}
+	want := ""
+	if port > 0 {
+		want = strconv.Itoa(port)
+	}
+	for _, n := range nodes.Items {
+		got := n.Labels[labelEgressProxy]
+		if n.Name == nodeName {
+			if want == "" {
+				o.setEgressProxyLabel(ctx, nodeName, nil)
+			}
+			continue
+		}
+		if got != want {
+			if want == "" {
+				return fmt.Errorf("egress proxy: node %s runs it on port %s; start this server with --sandbox-egress-proxy-port %s or remove the %s label", n.Name, got, got, labelEgressProxy)
+			}
+			return fmt.Errorf("egress proxy: node %s runs it on port %s, not %s; every server needs the same --sandbox-egress-proxy-port", n.Name, got, want)
+		}
+	}
+	return nil
+}
+
+// labelEgressProxyNode marks nodeName as running the egress proxy on port,
+// retrying until the server's node has registered or ctx is done.
+func (o *Orchestrator) labelEgressProxyNode(ctx context.Context, nodeName string, port int) {
+	value := strconv.Itoa(port)
+	for {
+		err := o.setEgressProxyLabel(ctx, nodeName, &value)
+		if err == nil {
+			return
+		}
+		logrus.Debugf("sandbox egress proxy: label node %s: %v", nodeName, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(egressProxyLabelRetry):
+		}
+	}
+}
+
+// setEgressProxyLabel sets (value non-nil) or removes the egress proxy
+// label on a node.
+func (o *Orchestrator) setEgressProxyLabel(ctx context.Context, nodeName string, value *string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": map[string]*string{labelEgressProxy: value}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = o.k8s.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// applySessionNetworkPolicy creates or replaces the session NetworkPolicy
+// (egress proxy mode's counterpart of the CNP).
+func (o *Orchestrator) applySessionNetworkPolicy(ctx context.Context, session *sandboxv1.SandboxSession) error {
+	ips, err := o.nodeIPs(ctx)
+	if err != nil {
+		return fmt.Errorf("list node IPs: %w", err)
+	}
+	np := buildSessionNetworkPolicy(session, int(egressProxyPort.Load()), ips)
+	policies := o.k8s.NetworkingV1().NetworkPolicies(session.Namespace)
+	existing, err := policies.Get(ctx, np.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = policies.Create(ctx, np, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	np.ResourceVersion = existing.ResourceVersion
+	_, err = policies.Update(ctx, np, metav1.UpdateOptions{})
+	return err
+}
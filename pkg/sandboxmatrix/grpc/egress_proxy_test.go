@@ -0,0 +1,271 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func TestEgressAllowed(t *testing.T) {
+	open := &sandboxv1.SandboxSession{}
+	if !egressAllowed(open, "example.com", 443, "", "") || egressAllowed(open, "example.com", 80, "GET", "/") {
+		t.Fatal("unscoped session: any host on 443 only")
+	}
+
+	s := &sandboxv1.SandboxSession{}
+	s.Spec.AllowedHosts = []string{"pypi.org"}
+	s.Spec.EgressRules = []sandboxv1.EgressRule{
+		{Host: "*.pythonhosted.org"},
+		{Host: "github.com", Ports: []int32{22}},
+		{CIDR: "10.0.0.0/8", Ports: []int32{5432}},
+		{Host: "api.github.com", Ports: []int32{80}, HTTP: []sandboxv1.EgressHTTPRule{{Method: "GET", Path: "/repos/*"}}},
+		{Host: "dns.corp", Ports: []int32{53}, Protocol: "UDP"},
+	}
+	cases := []struct {
+		host         string
+		port         int
+		method, path string
+		want         bool
+	}{
+		{"PyPI.org.", 443, "", "", true},
+		{"pypi.org", 80, "GET", "/", false},
+		{"files.pythonhosted.org", 443, "", "", true},
+		{"a.b.pythonhosted.org", 443, "", "", false},
+		{"github.com", 22, "", "", true},
+		{"github.com", 443, "", "", false},
+		{"10.1.2.3", 5432, "", "", true},
+		{"10.1.2.3", 22, "", "", false},
+		{"api.github.com", 80, "GET", "/repos/k8e", true},
+		{"api.github.com", 80, "POST", "/repos/k8e", false},
+		{"api.github.com", 80, "", "", false}, // tunnels cannot be checked against HTTP rules
+		{"dns.corp", 53, "", "", false},
+		{"example.com", 443, "", "", false},
+	}
+	for _, c := range cases {
+		if got := egressAllowed(s, c.host, c.port, c.method, c.path); got != c.want {
+			t.Errorf("%s %s:%d%s: got %v, want %v", c.method, c.host, c.port, c.path, got, c.want)
+		}
+	}
+
+	if egressIPAllowed(s, net.ParseIP("169.254.169.254"), 443) || egressIPAllowed(s, net.ParseIP("127.0.0.1"), 443) {
+		t.Fatal("link-local and loopback must stay closed")
+	}
+	if !egressIPAllowed(s, net.ParseIP("10.9.9.9"), 5432) || egressIPAllowed(s, net.ParseIP("10.9.9.9"), 443) {
+		t.Fatal("private addresses open only through CIDR rules")
+	}
+	if !egressIPAllowed(s, net.ParseIP("140.82.112.3"), 443) {
+		t.Fatal("public addresses are reachable")
+	}
+}
+
+// proxyTestSession puts an Active session on 127.0.0.1 into the watch
+// cache, where the proxy looks sessions up.
+func proxyTestSession(o *Orchestrator, id string, rules []sandboxv1.EgressRule) {
+	s := &sandboxv1.SandboxSession{}
+	s.Name = id
+	s.Namespace = sandboxNS
+	s.Spec.EgressRules = rules
+	s.Status.Phase = sandboxv1.SandboxPhaseActive
+	s.Status.PodIP = "127.0.0.1"
+	obj, _ := sessionToUnstructured(s)
+	o.watch.store = newSessionIndexer()
+	o.watch.store.Add(obj) //nolint:errcheck
+}
+
+func listenerPort(t *testing.T, srv *httptest.Server) int32 {
+	t.Helper()
+	_, p, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int32(port)
+}
+
+func TestEgressProxy_FiltersAndPublishes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain "+r.URL.Path) //nolint:errcheck
+	}))
+	defer upstream.Close()
+	upstreamTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tls "+r.URL.Path) //nolint:errcheck
+	}))
+	defer upstreamTLS.Close()
+
+	o := newTestOrchestrator()
+	proxyTestSession(o, "egress-1", []sandboxv1.EgressRule{
+		{CIDR: "127.0.0.1", Ports: []int32{listenerPort(t, upstream)}, HTTP: []sandboxv1.EgressHTTPRule{{Method: "GET", Path: "/ok*"}}},
+		{CIDR: "127.0.0.1", Ports: []int32{listenerPort(t, upstreamTLS)}},
+	})
+	w, _, err := o.watch.subscribe(&pb.WatchSessionsRequest{SessionId: "egress-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := httptest.NewServer(newEgressProxy(o))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+	}}
+	get := func(method, target string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(context.Background(), method, target, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get(http.MethodGet, upstream.URL+"/ok/1"); code != http.StatusOK || body != "plain /ok/1" {
+		t.Fatalf("allowed GET: %d %q", code, body)
+	}
+	if code, _ := get(http.MethodPost, upstream.URL+"/ok/1"); code != http.StatusForbidden {
+		t.Fatalf("POST outside the HTTP rule: %d", code)
+	}
+	if code, body := get(http.MethodGet, upstreamTLS.URL+"/x"); code != http.StatusOK || body != "tls /x" {
+		t.Fatalf("CONNECT tunnel: %d %q", code, body)
+	}
+	if code, _ := get(http.MethodGet, "https://example.com/"); code != 0 {
+		t.Fatalf("CONNECT to an unlisted host must fail, got %d", code)
+	}
+
+	want := []struct {
+		port   int32
+		denied bool
+	}{
+		{listenerPort(t, upstream), false},
+		{listenerPort(t, upstream), true},
+		{listenerPort(t, upstreamTLS), false},
+		{443, true},
+	}
+	if len(w.ch) != len(want) {
+		t.Fatalf("want %d egress events, got %d", len(want), len(w.ch))
+	}
+	for i, wv := range want {
+		ev := <-w.ch
+		if ev.Type != pb.SessionEventType_SESSION_EVENT_EGRESS || ev.Port != wv.port || ev.EgressDenied != wv.denied {
+			t.Fatalf("event %d: %+v", i, ev)
+		}
+	}
+}
+
+func TestEgressProxyMode_NetworkPolicyAndPodEnv(t *testing.T) {
+	enableEgressProxy(3129)
+	t.Cleanup(func() { enableEgressProxy(0) })
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}}
+	node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.5"}}
+	if _, err := o.k8s.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	mustCreateSession(t, o, "np-1")
+	pod, err := o.k8s.CoreV1().Pods(sandboxNS).Get(ctx, "sandbox-np-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	for _, e := range pod.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["HTTPS_PROXY"] != "http://$(K8E_EGRESS_PROXY_HOST):3129" || env["no_proxy"] == "" {
+		t.Fatalf("proxy env: %v", env)
+	}
+	if a := pod.Spec.Affinity; a == nil || a.NodeAffinity == nil ||
+		a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Key != labelEgressProxy ||
+		a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Values[0] != "3129" {
+		t.Fatalf("pod not pinned to egress proxy nodes: %+v", pod.Spec.Affinity)
+	}
+
+	if _, _, err := o.UpdateAllowedHosts(ctx, "np-1", nil, []sandboxv1.EgressRule{{CIDR: "192.168.0.0/16", Ports: []int32{5432}, Protocol: "ANY"}}); err != nil {
+		t.Fatal(err)
+	}
+	np, err := o.k8s.NetworkingV1().NetworkPolicies(sandboxNS).Get(ctx, "sandbox-session-np-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("network policy: %v", err)
+	}
+	egress := np.Spec.Egress
+	if len(egress) != 3 {
+		t.Fatalf("want dns, proxy and one CIDR rule, got %+v", egress)
+	}
+	if to := egress[0].To; len(to) != 1 || to[0].IPBlock != nil ||
+		to[0].NamespaceSelector.MatchLabels[corev1.LabelMetadataName] != "kube-system" || to[0].PodSelector.MatchLabels["k8s-app"] != "kube-dns" {
+		t.Fatalf("dns rule must be limited to kube-dns: %+v", egress[0])
+	}
+	if to := egress[1].To; len(to) != 1 || to[0].IPBlock.CIDR != "10.0.0.5/32" || egress[1].Ports[0].Port.IntValue() != 3129 {
+		t.Fatalf("proxy rule: %+v", egress[1])
+	}
+	if egress[2].To[0].IPBlock.CIDR != "192.168.0.0/16" || len(egress[2].Ports) != 2 {
+		t.Fatalf("CIDR rule (ANY → TCP and UDP): %+v", egress[2])
+	}
+	if _, err := o.dynamic.Resource(cnpGVR).Namespace(sandboxNS).Get(ctx, "sandbox-session-np-1", metav1.GetOptions{}); err == nil {
+		t.Fatal("egress proxy mode must not create a CNP")
+	}
+
+	if err := o.DestroySession(ctx, "np-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.k8s.NetworkingV1().NetworkPolicies(sandboxNS).Get(ctx, "sandbox-session-np-1", metav1.GetOptions{}); err == nil {
+		t.Fatal("network policy not deleted with the session")
+	}
+}
+
+func TestCheckEgressProxyNodes(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	for _, name := range []string{"server-a", "server-b", "agent-c"} {
+		if _, err := o.k8s.CoreV1().Nodes().Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.checkEgressProxyNodes(ctx, "server-a", 3129); err != nil {
+		t.Fatalf("first proxy server: %v", err)
+	}
+	port := "3129"
+	if err := o.setEgressProxyLabel(ctx, "server-a", &port); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.checkEgressProxyNodes(ctx, "server-b", 3129); err != nil {
+		t.Fatalf("same port: %v", err)
+	}
+	if err := o.checkEgressProxyNodes(ctx, "server-b", 3130); err == nil {
+		t.Fatal("a different proxy port must be rejected")
+	}
+	if err := o.checkEgressProxyNodes(ctx, "server-b", 0); err == nil {
+		t.Fatal("a server without the proxy must be rejected while others run it")
+	}
+	if err := o.checkEgressProxyNodes(ctx, "", 3129); err == nil {
+		t.Fatal("proxy mode needs the node name")
+	}
+
+	// A server restarted without the proxy drops its own stale label.
+	if err := o.checkEgressProxyNodes(ctx, "server-a", 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := o.k8s.CoreV1().Nodes().Get(ctx, "server-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.Labels[labelEgressProxy]; ok {
+		t.Fatalf("stale label kept: %v", n.Labels)
+	}
+}
@@ -0,0 +1,187 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func cnpEgress(t *testing.T, sess *sandboxv1.SandboxSession, fqdnEnabled bool) []map[string]interface{} {
+	t.Helper()
+	obj := buildSessionCNP(sess, fqdnEnabled)
+	var out []map[string]interface{}
+	for _, e := range obj.Object["spec"].(map[string]interface{})["egress"].([]interface{}) {
+		out = append(out, e.(map[string]interface{}))
+	}
+	return out
+}
+
+func firstToPort(rule map[string]interface{}) map[string]interface{} {
+	return rule["toPorts"].([]interface{})[0].(map[string]interface{})
+}
+
+func TestBuildSessionCNP_EgressRulesFQDN(t *testing.T) {
+	sess := &sandboxv1.SandboxSession{}
+	sess.Name = "cnp-rules"
+	sess.Namespace = sandboxNS
+	sess.Spec.EgressRules = []sandboxv1.EgressRule{
+		{Host: "*.pypi.org"},
+		{CIDR: "10.0.0.0/8", Ports: []int32{5432}},
+		{Host: "github.com", Ports: []int32{22}},
+		{Host: "api.github.com", HTTP: []sandboxv1.EgressHTTPRule{{Method: "get", Path: "/repos/*"}}},
+	}
+
+	egress := cnpEgress(t, sess, true)
+	// DNS + one entry per rule; no blanket world 443.
+	if len(egress) != 5 {
+		t.Fatalf("expected 5 egress entries, got %d: %v", len(egress), egress)
+	}
+	for _, e := range egress[1:] {
+		if _, world := e["toEntities"]; world {
+			t.Fatalf("scoped egress must not open world: %v", e)
+		}
+	}
+	if got := egress[1]["toFQDNs"].([]interface{})[0].(map[string]interface{})["matchPattern"]; got != "*.pypi.org" {
+		t.Fatalf("wildcard host not rendered as matchPattern: %v", egress[1])
+	}
+	if got := egress[2]["toCIDRSet"].([]interface{})[0].(map[string]interface{})["cidr"]; got != "10.0.0.0/8" {
+		t.Fatalf("cidr not rendered: %v", egress[2])
+	}
+	port := firstToPort(egress[3])["ports"].([]interface{})[0].(map[string]interface{})
+	if port["port"] != "22" || port["protocol"] != "TCP" {
+		t.Fatalf("port 22 not rendered: %v", port)
+	}
+	http := firstToPort(egress[4])["rules"].(map[string]interface{})["http"].([]interface{})[0].(map[string]interface{})
+	if http["method"] != "GET" || http["path"] != "/repos/.*" {
+		t.Fatalf("http rule not rendered: %v", http)
+	}
+}
+
+func TestBuildSessionCNP_EgressRulesWithoutFQDN(t *testing.T) {
+	sess := &sandboxv1.SandboxSession{}
+	sess.Name = "cnp-rules-world"
+	sess.Namespace = sandboxNS
+	sess.Spec.EgressRules = []sandboxv1.EgressRule{
+		{Host: "github.com", Ports: []int32{22}, HTTP: []sandboxv1.EgressHTTPRule{{Method: "GET"}}},
+		{CIDR: "192.168.1.10"},
+	}
+
+	egress := cnpEgress(t, sess, false)
+	if len(egress) != 4 {
+		t.Fatalf("expected dns + world 443 + 2 rules, got %d: %v", len(egress), egress)
+	}
+	if _, world := egress[1]["toEntities"]; !world {
+		t.Fatalf("default mode keeps world 443: %v", egress[1])
+	}
+	if _, world := egress[2]["toEntities"]; !world {
+		t.Fatalf("host rule without FQDN egress opens its ports to world: %v", egress[2])
+	}
+	if _, l7 := firstToPort(egress[2])["rules"]; l7 {
+		t.Fatalf("http rules must not apply to all of world: %v", egress[2])
+	}
+	if got := egress[3]["toCIDRSet"].([]interface{})[0].(map[string]interface{})["cidr"]; got != "192.168.1.10/32" {
+		t.Fatalf("bare IP not rendered as /32: %v", egress[3])
+	}
+}
+
+func TestValidateEgressRules(t *testing.T) {
+	ok := []*pb.EgressRule{
+		{Host: "*.pypi.org"},
+		{Cidr: "10.0.0.0/8", Ports: []int32{22, 5432}},
+		{Cidr: "fd00::1", Protocol: "udp", Ports: []int32{53}},
+		{Host: "mirror.corp", Ports: []int32{80}, Http: []*pb.EgressHTTPRule{{Method: "GET", Path: "/simple/*"}}},
+	}
+	if err := validateEgressRules(ok); err != nil {
+		t.Fatalf("valid rules rejected: %v", err)
+	}
+	bad := map[string]*pb.EgressRule{
+		"empty":         {},
+		"both":          {Host: "a.com", Cidr: "10.0.0.0/8"},
+		"host":          {Host: "a b.com"},
+		"cidr":          {Cidr: "10.0.0.0/33"},
+		"port":          {Host: "a.com", Ports: []int32{70000}},
+		"protocol":      {Host: "a.com", Protocol: "icmp"},
+		"http over udp": {Host: "a.com", Protocol: "UDP", Http: []*pb.EgressHTTPRule{{Method: "GET"}}},
+		"method":        {Host: "a.com", Http: []*pb.EgressHTTPRule{{Method: "G/T"}}},
+		"path":          {Host: "a.com", Ports: []int32{80}, Http: []*pb.EgressHTTPRule{{Path: "repos"}}},
+		"http on 443":   {Host: "a.com", Ports: []int32{80, 443}, Http: []*pb.EgressHTTPRule{{Method: "GET"}}},
+		"http default":  {Host: "a.com", Http: []*pb.EgressHTTPRule{{Method: "GET"}}},
+	}
+	for name, r := range bad {
+		if err := validateEgressRules([]*pb.EgressRule{r}); err == nil {
+			t.Errorf("%s: expected error for %+v", name, r)
+		}
+	}
+}
+
+func TestUpdateAllowedHosts_EgressRules(t *testing.T) {
+	s := newTestServer()
+	s.orch.SetFQDNEGressEnabled(true)
+	mustCreateSession(t, s.orch, "rules-1")
+	ctx := context.Background()
+
+	resp, err := s.UpdateAllowedHosts(ctx, &pb.UpdateAllowedHostsRequest{
+		SessionId: "rules-1",
+		Hosts:     []string{"pypi.org"},
+		Rules:     []*pb.EgressRule{{Host: "GitHub.com", Ports: []int32{22}}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateAllowedHosts: %v", err)
+	}
+	if len(resp.Rules) != 1 || resp.Rules[0].Host != "github.com" || resp.Rules[0].Protocol != "TCP" {
+		t.Fatalf("rules not normalized: %v", resp.Rules)
+	}
+	got, err := s.GetSession(ctx, &pb.GetSessionRequest{SessionId: "rules-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.EgressRules) != 1 || got.EgressRules[0].Ports[0] != 22 {
+		t.Fatalf("rules not persisted: %v", got.EgressRules)
+	}
+
+	_, err = s.UpdateAllowedHosts(ctx, &pb.UpdateAllowedHostsRequest{
+		SessionId: "rules-1", Rules: []*pb.EgressRule{{Cidr: "nope"}},
+	})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "cidr") {
+		t.Fatalf("invalid rule: %v", err)
+	}
+}
+
+func TestEgressRules_UnenforceableRejected(t *testing.T) {
+	s := newTestServer()
+	mustCreateSession(t, s.orch, "rules-2")
+	ctx := context.Background()
+
+	// Without FQDN egress or the egress proxy a host rule would open world.
+	_, err := s.UpdateAllowedHosts(ctx, &pb.UpdateAllowedHostsRequest{
+		SessionId: "rules-2", Rules: []*pb.EgressRule{{Host: "github.com", Ports: []int32{22}}},
+	})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "cidr") {
+		t.Fatalf("host rule without FQDN egress: %v", err)
+	}
+	_, err = s.orch.CreateSession(ctx, &pb.CreateSessionRequest{
+		SessionId: "rules-3", EgressRules: []*pb.EgressRule{{Host: "*.pypi.org"}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("CreateSession host rule without FQDN egress: %v", err)
+	}
+	// CIDR rules are enforced in every mode.
+	if _, err := s.UpdateAllowedHosts(ctx, &pb.UpdateAllowedHostsRequest{
+		SessionId: "rules-2", Rules: []*pb.EgressRule{{Cidr: "10.0.0.0/8", Ports: []int32{5432}}},
+	}); err != nil {
+		t.Fatalf("cidr rule: %v", err)
+	}
+
+	// Template rules skip request validation but not the mode check.
+	if err := checkEgressEnforced([]sandboxv1.EgressRule{
+		{CIDR: "10.0.0.1", HTTP: []sandboxv1.EgressHTTPRule{{Method: "GET"}}},
+	}, true); err == nil || !strings.Contains(err.Error(), "443") {
+		t.Fatalf("http rule on default port 443: %v", err)
+	}
+}
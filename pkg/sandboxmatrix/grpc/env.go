@@ -2,6 +2,8 @@ package grpc
 
 import (
 	"fmt"
+	"net/http"
+	"regexp"
 	"strings"
 	"unicode/utf8"
 
@@ -17,10 +19,21 @@ const (
 	maxSessionEnvValueBytes = 4 * 1024  // 4 KiB per value
 	maxSessionEnvTotalBytes = 32 * 1024 // 32 KiB keys+values combined
 	maxSessionSecretRefs    = 32
+	maxSecretRefHosts       = 16
 	// maxExecOutputBytes is the per-stream capture cap for agent-facing truncation (~1 MiB).
 	maxExecOutputBytes = 1 * 1024 * 1024
 )
 
+// secretHeaderRe is a SecretRef header name. reservedSecretHeaders are
+// headers the proxy never injects into: they route, frame or are echoed.
+var (
+	secretHeaderRe        = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,63}$`)
+	reservedSecretHeaders = map[string]bool{
+		"Host": true, "Cookie": true, "Connection": true, "Content-Length": true,
+		"Content-Type": true, "Transfer-Encoding": true, "Referer": true, "User-Agent": true,
+	}
+)
+
 // validateSessionEnv checks a CreateSession env map against size and key rules.
 // nil/empty maps are valid (no env configured).
 func validateSessionEnv(env map[string]string) error {
@@ -114,6 +127,22 @@ func validateSecretRefs(refs []*pb.SecretRef) error {
 		if _, ok := seen[r.EnvVar]; ok {
 			return fmt.Errorf("duplicate env_var %q", r.EnvVar)
 		}
+		if len(r.Hosts) > maxSecretRefHosts {
+			return fmt.Errorf("env_var %q: too many hosts: %d (max %d)", r.EnvVar, len(r.Hosts), maxSecretRefHosts)
+		}
+		for _, h := range r.Hosts {
+			if len(h) > 253 || !egressHostRe.MatchString(strings.ToLower(h)) {
+				return fmt.Errorf("env_var %q: invalid host %q", r.EnvVar, h)
+			}
+		}
+		if r.Header != "" {
+			if len(r.Hosts) == 0 {
+				return fmt.Errorf("env_var %q: header needs hosts", r.EnvVar)
+			}
+			if !secretHeaderRe.MatchString(r.Header) || reservedSecretHeaders[http.CanonicalHeaderKey(r.Header)] {
+				return fmt.Errorf("env_var %q: invalid header %q", r.EnvVar, r.Header)
+			}
+		}
 		seen[r.EnvVar] = struct{}{}
 	}
 	return nil
@@ -128,11 +157,39 @@ func pbSecretRefsToAPI(refs []*pb.SecretRef) []sandboxv1.SecretRef {
 		if r == nil {
 			continue
 		}
-		out = append(out, sandboxv1.SecretRef{
+		ref := sandboxv1.SecretRef{
 			SecretName: r.SecretName,
 			Key:        r.Key,
 			EnvVar:     r.EnvVar,
-		})
+			Header:     r.Header,
+		}
+		for _, h := range r.Hosts {
+			ref.Hosts = append(ref.Hosts, strings.ToLower(h))
+		}
+		out = append(out, ref)
+	}
+	return out
+}
+
+// hasHostBoundSecrets reports whether any ref is bound to hosts, which
+// takes the egress proxy to inject.
+func hasHostBoundSecrets(refs []*pb.SecretRef) bool {
+	for _, r := range refs {
+		if r != nil && len(r.Hosts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// apiSecretRefsToPB is the inverse of pbSecretRefsToAPI.
+func apiSecretRefsToPB(refs []sandboxv1.SecretRef) []*pb.SecretRef {
+	if len(refs) == 0 {
+		return nil
+	}
+	out := make([]*pb.SecretRef, 0, len(refs))
+	for _, r := range refs {
+		out = append(out, &pb.SecretRef{SecretName: r.SecretName, Key: r.Key, EnvVar: r.EnvVar, Hosts: append([]string(nil), r.Hosts...), Header: r.Header})
 	}
 	return out
 }
@@ -171,10 +228,16 @@ func sessionToProtoView(s *sandboxv1.SandboxSession, bgRuns int32) *pb.GetSessio
 		TenantId:       s.Spec.TenantID,
 		BackgroundRuns: bgRuns,
 		AllowedHosts:   s.Spec.AllowedHosts,
+		Template:       s.Spec.Template,
+		EgressRules:    egressRulesToPB(s.Spec.EgressRules),
 	}
 	if s.Status.ExpiresAt != nil {
 		view.ExpiresAt = s.Status.ExpiresAt.Unix()
 	}
+	if l := s.Spec.Lineage; l != nil {
+		view.ForkedFrom = l.ForkedFrom
+		view.ForkIndex = int32(l.ForkIndex)
+	}
 	if len(s.Spec.Env) > 0 {
 		keys := make([]string, 0, len(s.Spec.Env))
 		for k := range s.Spec.Env {
@@ -199,6 +199,18 @@ func TestValidateSecretRefs(t *testing.T) {
 	}); err == nil {
 		t.Fatal("expected duplicate env_var")
 	}
+	if err := validateSecretRefs([]*pb.SecretRef{{SecretName: "s", Key: "k", EnvVar: "A", Hosts: []string{"api.example.com"}, Header: "X-Api-Key"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []*pb.SecretRef{
+		{SecretName: "s", Key: "k", EnvVar: "A", Header: "X-Api-Key"},
+		{SecretName: "s", Key: "k", EnvVar: "A", Hosts: []string{"api.example.com"}, Header: "user-agent"},
+		{SecretName: "s", Key: "k", EnvVar: "A", Hosts: []string{"api.example.com"}, Header: "X Api"},
+	} {
+		if err := validateSecretRefs([]*pb.SecretRef{ref}); err == nil {
+			t.Fatalf("header %q (hosts %v) must be rejected", ref.Header, ref.Hosts)
+		}
+	}
 }
 
 func TestGetSession_NotFound(t *testing.T) {
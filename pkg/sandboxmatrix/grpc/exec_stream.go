@@ -0,0 +1,246 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// maxExecFrameBytes bounds one sandboxd SSE event; sandboxd reads 4 KiB at a
+// time, so a base64 frame is well under it.
+const maxExecFrameBytes = 64 * 1024
+
+// ExecStreamV2 runs a command and streams typed stdout/stderr frames and a
+// final exit frame.
+func (s *Server) ExecStreamV2(req *pb.ExecRequest, stream pb.SandboxService_ExecStreamV2Server) error {
+	return s.execFramed(stream.Context(), req, stream.Send, nil)
+}
+
+// ExecInteractive is ExecStreamV2 with stdin. Stdin frames are forwarded to
+// sandboxd's /exec/stdin as they arrive (queued until sandboxd reports the
+// pid); close_stdin sends EOF. The call ends when the command exits.
+func (s *Server) ExecInteractive(stream pb.SandboxService_ExecInteractiveServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.GetStart()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "the first ExecInput must be start")
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	pids := make(chan int, 1)
+	inputErr := make(chan error, 1)
+	go func() { inputErr <- s.pumpStdin(ctx, stream, req.SessionId, pids) }()
+
+	err = s.execFramed(ctx, req, stream.Send, func(pid int) { pids <- pid })
+	select {
+	case ierr := <-inputErr:
+		if err == nil && ierr != nil {
+			err = ierr
+		}
+	default:
+	}
+	return err
+}
+
+// pumpStdin forwards the client's stdin frames once the pid is known. It
+// returns nil when the client half-closes or ctx ends.
+func (s *Server) pumpStdin(ctx context.Context, stream pb.SandboxService_ExecInteractiveServer, sessionID string, pids <-chan int) error {
+	var pid int
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if pid == 0 {
+			select {
+			case pid = <-pids:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		switch v := in.Input.(type) {
+		case *pb.ExecInput_Stdin:
+			err = s.sandboxdStdin(ctx, sessionID, "/exec/stdin", map[string]any{
+				"pid": pid, "data": base64.StdEncoding.EncodeToString(v.Stdin),
+			})
+		case *pb.ExecInput_CloseStdin:
+			if v.CloseStdin {
+				err = s.sandboxdStdin(ctx, sessionID, "/exec/stdin/close", map[string]any{"pid": pid})
+			}
+		default:
+			err = status.Error(codes.InvalidArgument, "start may only be sent once")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) sandboxdStdin(ctx context.Context, sessionID, path string, body map[string]any) error {
+	podIP, err := s.getPodIP(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	resp, err := sandboxdPost(ctx, podIP, path, body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return status.Errorf(codes.Unavailable, "sandboxd %s: %v", path, err)
+	}
+	resp.Body.Close()
+	// 404: the process already exited; its exit frame is on the way.
+	return nil
+}
+
+// execFramed runs req through sandboxd /exec/stream in framed mode and sends
+// every frame. onPID, when set, receives the in-guest pid before any output.
+func (s *Server) execFramed(ctx context.Context, req *pb.ExecRequest, send func(*pb.ExecFrame) error, onPID func(int)) error {
+	podIP, err := s.getPodIP(ctx, req.SessionId)
+	if err != nil {
+		return err
+	}
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = 30
+	}
+	workdir := req.Workdir
+	if workdir == "" {
+		workdir = "/workspace"
+	}
+	env, err := s.resolveSessionEnv(ctx, req.SessionId)
+	if err != nil {
+		return err
+	}
+	body := sandboxdExecBody(req.SessionId, req.Command, timeout, workdir, env)
+	body["framed"] = true
+	start := time.Now()
+	httpCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout+5)*time.Second)
+	defer cancel()
+
+	resp, err := sandboxdPost(httpCtx, podIP, "/exec/stream", body)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "sandboxd stream: %v", err)
+	}
+	defer resp.Body.Close()
+	defer func() { s.observeExec(req, time.Since(start)) }()
+	return relayExecFrames(resp.Body, send, onPID)
+}
+
+// execEvent is one sandboxd framed /exec/stream event.
+type execEvent struct {
+	PID        *int    `json:"pid"`
+	Stdout     *string `json:"stdout"`
+	Stderr     *string `json:"stderr"`
+	Exit       *int32  `json:"exit"`
+	Signal     int32   `json:"signal"`
+	TimedOut   bool    `json:"timed_out"`
+	DurationMS int64   `json:"duration_ms"`
+	Error      string  `json:"error"`
+}
+
+// relayExecFrames turns sandboxd SSE events into ExecFrames. A sandboxd that
+// predates framed mode sends raw output frames; those are relayed as stdout
+// so the stream still works, just without the stderr split.
+func relayExecFrames(r io.Reader, send func(*pb.ExecFrame) error, onPID func(int)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 8*1024), maxExecFrameBytes)
+	sc.Split(splitSSE)
+	for sc.Scan() {
+		payload := bytes.TrimPrefix(bytes.TrimPrefix(sc.Bytes(), []byte("data:")), []byte(" "))
+		var ev execEvent
+		if json.Unmarshal(payload, &ev) != nil || ev.empty() {
+			if err := send(&pb.ExecFrame{Frame: &pb.ExecFrame_Stdout{Stdout: append([]byte(nil), payload...)}}); err != nil {
+				return err
+			}
+			continue
+		}
+		frame, err := ev.frame()
+		if err != nil {
+			return err
+		}
+		switch {
+		case ev.PID != nil:
+			if onPID != nil {
+				onPID(*ev.PID)
+			}
+		case frame != nil:
+			if ev.Exit != nil {
+				if err := finishSandboxdStream(r); err != nil {
+					return status.Errorf(codes.Unavailable, "sandboxd stream: %v", err)
+				}
+			}
+			if err := send(frame); err != nil {
+				return err
+			}
+			if ev.Exit != nil {
+				return nil
+			}
+		}
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return status.Errorf(codes.Unavailable, "sandboxd stream: %v", err)
+	}
+	return status.Error(codes.Unavailable, "sandboxd stream ended without an exit status")
+}
+
+func (ev execEvent) empty() bool {
+	return ev.PID == nil && ev.Stdout == nil && ev.Stderr == nil && ev.Exit == nil && ev.Error == ""
+}
+
+func (ev execEvent) frame() (*pb.ExecFrame, error) {
+	decode := func(v string) ([]byte, error) {
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "sandboxd frame: %v", err)
+		}
+		return b, nil
+	}
+	switch {
+	case ev.Error != "":
+		return nil, status.Errorf(codes.Internal, "sandboxd: %s", ev.Error)
+	case ev.Stdout != nil:
+		b, err := decode(*ev.Stdout)
+		return &pb.ExecFrame{Frame: &pb.ExecFrame_Stdout{Stdout: b}}, err
+	case ev.Stderr != nil:
+		b, err := decode(*ev.Stderr)
+		return &pb.ExecFrame{Frame: &pb.ExecFrame_Stderr{Stderr: b}}, err
+	case ev.Exit != nil:
+		return &pb.ExecFrame{Frame: &pb.ExecFrame_Exit{Exit: &pb.ExecExit{
+			Code:       *ev.Exit,
+			Signal:     ev.Signal,
+			TimedOut:   ev.TimedOut,
+			DurationMs: ev.DurationMS,
+		}}}, nil
+	}
+	return nil, nil
+}
+
+// splitSSE is a bufio.SplitFunc yielding one SSE event (without the blank
+// line that ends it).
+func splitSSE(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		return i + 2, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
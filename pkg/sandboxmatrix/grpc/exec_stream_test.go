@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func execStreamTestServer(t *testing.T, sessionID string) *Server {
+	t.Helper()
+	s := newTestServer()
+	mustCreateSession(t, s.orch, sessionID)
+	stubSessionPodIP(context.Background(), t, s.orch, sessionID, "127.0.0.1")
+	return s
+}
+
+// frameStream collects the frames an ExecStreamV2 handler sends.
+type frameStream struct {
+	grpc.ServerStream
+	frames []*pb.ExecFrame
+}
+
+func (f *frameStream) Context() context.Context { return context.Background() }
+func (f *frameStream) Send(fr *pb.ExecFrame) error {
+	f.frames = append(f.frames, fr)
+	return nil
+}
+
+func b64(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+func TestExecStreamV2_TypedFrames(t *testing.T) {
+	s := execStreamTestServer(t, "framed")
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		if body["framed"] != true {
+			t.Errorf("framed mode not requested: %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"pid\":42}\n\n")
+		fmt.Fprintf(w, "data: {\"stdout\":%q}\n\n", b64("hello\n"))
+		fmt.Fprintf(w, "data: {\"stderr\":%q}\n\n", b64("warning: {\"exit\":1}\n"))
+		fmt.Fprint(w, "data: {\"exit\":-1,\"signal\":9,\"timed_out\":true,\"duration_ms\":30004}\n\n")
+	}))
+
+	stream := &frameStream{}
+	if err := s.ExecStreamV2(&pb.ExecRequest{SessionId: "framed", Command: "make"}, stream); err != nil {
+		t.Fatalf("ExecStreamV2: %v", err)
+	}
+	if len(stream.frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d: %v", len(stream.frames), stream.frames)
+	}
+	if got := string(stream.frames[0].GetStdout()); got != "hello\n" {
+		t.Fatalf("stdout frame: %q", got)
+	}
+	// Output that looks like an exit frame stays output.
+	if got := string(stream.frames[1].GetStderr()); got != "warning: {\"exit\":1}\n" {
+		t.Fatalf("stderr frame: %q", got)
+	}
+	exit := stream.frames[2].GetExit()
+	if exit == nil || exit.Code != -1 || exit.Signal != 9 || !exit.TimedOut || exit.DurationMs != 30004 {
+		t.Fatalf("exit frame: %+v", exit)
+	}
+}
+
+func TestExecStreamV2_LegacySandboxdAndMissingExit(t *testing.T) {
+	s := execStreamTestServer(t, "legacy")
+	exit := true
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"pid\":7}\n\n")
+		fmt.Fprint(w, "data: raw output\n\n")
+		if exit {
+			fmt.Fprint(w, "data: {\"exit\":3}\n\n")
+		}
+	}))
+
+	stream := &frameStream{}
+	if err := s.ExecStreamV2(&pb.ExecRequest{SessionId: "legacy", Command: "ls"}, stream); err != nil {
+		t.Fatalf("ExecStreamV2: %v", err)
+	}
+	if len(stream.frames) != 2 || string(stream.frames[0].GetStdout()) != "raw output" || stream.frames[1].GetExit().GetCode() != 3 {
+		t.Fatalf("legacy frames: %v", stream.frames)
+	}
+
+	exit = false
+	err := s.ExecStreamV2(&pb.ExecRequest{SessionId: "legacy", Command: "ls"}, &frameStream{})
+	if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "without an exit status") {
+		t.Fatalf("expected Unavailable for a truncated stream, got %v", err)
+	}
+}
+
+// interactiveStream feeds inputs to ExecInteractive and collects its frames.
+type interactiveStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	inputs chan *pb.ExecInput
+	frames chan *pb.ExecFrame
+}
+
+func (i *interactiveStream) Context() context.Context { return i.ctx }
+func (i *interactiveStream) Send(f *pb.ExecFrame) error {
+	i.frames <- f
+	return nil
+}
+func (i *interactiveStream) Recv() (*pb.ExecInput, error) {
+	select {
+	case in, ok := <-i.inputs:
+		if !ok {
+			return nil, io.EOF
+		}
+		return in, nil
+	case <-i.ctx.Done():
+		return nil, i.ctx.Err()
+	}
+}
+
+func TestExecInteractive_ForwardsStdin(t *testing.T) {
+	s := execStreamTestServer(t, "interactive")
+	stdin := make(chan string, 4)
+	closed := make(chan struct{})
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		switch r.URL.Path {
+		case "/exec/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"pid\":99}\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-closed:
+			case <-time.After(5 * time.Second):
+				t.Error("stdin was never closed")
+			}
+			fmt.Fprintf(w, "data: {\"stdout\":%q}\n\n", b64("2\n"))
+			fmt.Fprint(w, "data: {\"exit\":0,\"signal\":0,\"timed_out\":false,\"duration_ms\":12}\n\n")
+		case "/exec/stdin":
+			if body["pid"] != float64(99) {
+				t.Errorf("stdin for pid %v", body["pid"])
+			}
+			data, _ := base64.StdEncoding.DecodeString(body["data"].(string))
+			stdin <- string(data)
+		case "/exec/stdin/close":
+			close(closed)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &interactiveStream{ctx: ctx, inputs: make(chan *pb.ExecInput, 4), frames: make(chan *pb.ExecFrame, 4)}
+	stream.inputs <- &pb.ExecInput{Input: &pb.ExecInput_Start{Start: &pb.ExecRequest{SessionId: "interactive", Command: "wc -l"}}}
+	stream.inputs <- &pb.ExecInput{Input: &pb.ExecInput_Stdin{Stdin: []byte("a\nb\n")}}
+	stream.inputs <- &pb.ExecInput{Input: &pb.ExecInput_CloseStdin{CloseStdin: true}}
+
+	if err := s.ExecInteractive(stream); err != nil {
+		t.Fatalf("ExecInteractive: %v", err)
+	}
+	if got := <-stdin; got != "a\nb\n" {
+		t.Fatalf("stdin forwarded as %q", got)
+	}
+	if f := <-stream.frames; string(f.GetStdout()) != "2\n" {
+		t.Fatalf("stdout frame: %v", f)
+	}
+	if f := <-stream.frames; f.GetExit() == nil || f.GetExit().Code != 0 {
+		t.Fatalf("exit frame: %v", f)
+	}
+}
+
+func TestExecInteractive_RequiresStart(t *testing.T) {
+	s := newTestServer()
+	stream := &interactiveStream{ctx: context.Background(), inputs: make(chan *pb.ExecInput, 1)}
+	stream.inputs <- &pb.ExecInput{Input: &pb.ExecInput_Stdin{Stdin: []byte("x")}}
+	if err := s.ExecInteractive(stream); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
@@ -2,14 +2,23 @@ package grpc
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
 
+	"github.com/xiaods/k8e/pkg/sandbox/e2b"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
 	"github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -29,12 +38,40 @@ type ExposedEntry struct {
 	Host      string // in-pod listen address recorded at expose time (informational)
 	URL       string // public gateway URL
 	StartedAt time.Time
+	Access    string // public, token or signed
+	Nonce     string // per-exposure nonce (see sandboxv1.ExposedPort)
 }
 
 // exposeURLPath is the e2b HTTP reverse-proxy route prefix (Gateway-API
 // fronted). Full URL: <gateway-base>/k8e/expose/<session>/<port>/
 const exposeURLPath = "/k8e/expose/%s/%d/"
 
+// exposeURL is the URL of sessionID's exposed port. With an expose domain
+// it is the exposure's own host, <scheme>://<port>-<session>.<domain>[:port]/,
+// taking scheme and port from baseURL; the e2b proxy only hands out cookies
+// there. Otherwise, or when the session ID cannot be a DNS label, it is the
+// shared path under baseURL.
+func (o *Orchestrator) exposeURL(baseURL, sessionID string, port int32) string {
+	label := fmt.Sprintf("%d-%s", port, sessionID)
+	if o.exposeDomain == "" || len(validation.IsDNS1123Label(label)) > 0 {
+		return baseURL + fmt.Sprintf(exposeURLPath, sessionID, port)
+	}
+	scheme, host := "http", o.exposeDomain
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		scheme = u.Scheme
+		if p := u.Port(); p != "" {
+			host = net.JoinHostPort(host, p)
+		}
+	}
+	return scheme + "://" + label + "." + host + "/"
+}
+
+// The exposure registry lives in SandboxSession status.exposedPorts, so it
+// survives gateway restarts and every replica sees the same ports.
+// o.exposed is this replica's index of it: rebuilt at startup
+// (RebuildExposedRegistry), kept current by the session informer, and
+// updated directly after each local write.
+
 // ExposeService registers an in-pod service port for gateway proxying and
 // re-applies the session CNP so the gateway/e2b-server may reach the port.
 // Idempotent: exposing the same port twice returns the existing URL.
@@ -42,7 +79,12 @@ const exposeURLPath = "/k8e/expose/%s/%d/"
 // baseURL is the public gateway base (e.g. http://gw.example.com) the caller
 // will use to reach the exposed service; the server supplies it from its
 // advertised hostname.
-func (o *Orchestrator) ExposeService(ctx context.Context, sessionID string, port int32, host, baseURL string) (*pb.ExposeServiceResponse, error) {
+//
+// access is enforced by the expose proxy (unspecified means token). A
+// token-mode port gets a random token, returned (and appended to the URL)
+// only by the call that created it; status keeps its SHA-256. Signed URLs
+// are minted per call by Server.ExposeService over the exposure's nonce.
+func (o *Orchestrator) ExposeService(ctx context.Context, sessionID string, port int32, host, baseURL string, access pb.ExposeAccess) (*pb.ExposeServiceResponse, error) {
 	if port <= 0 || port > 65535 {
 		return nil, status.Errorf(codes.InvalidArgument, "port must be in [1, 65535]")
 	}
@@ -52,35 +94,229 @@ func (o *Orchestrator) ExposeService(ctx context.Context, sessionID string, port
 
 	// Idempotent fast path: the port is already exposed for this session.
 	o.exposeMu.Lock()
-	for _, e := range o.exposed[sessionID] {
-		if e.Port == int(port) {
-			resp := &pb.ExposeServiceResponse{Url: e.URL}
-			o.exposeMu.Unlock()
-			return resp, nil
-		}
-	}
+	e, found := o.findExposedLocked(sessionID, int(port))
 	o.exposeMu.Unlock()
+	if found {
+		return reexposeResponse(e.URL, e.Access, access)
+	}
 
 	// The session must exist (and its pod must be reachable for the proxy).
 	session, err := o.getSession(ctx, sessionID)
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "session %s not found", sessionID)
 	}
+	// Another replica may have exposed it before our informer caught up.
+	if p, ok := findExposedPort(session.Status.ExposedPorts, port); ok {
+		o.indexExposed(session)
+		return reexposeResponse(p.URL, p.Access, access)
+	}
+	release, err := o.admitExpose(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	url := fmt.Sprintf("%s%s", baseURL, fmt.Sprintf(exposeURLPath, sessionID, port))
-	entry := &ExposedEntry{Port: int(port), Host: host, URL: url, StartedAt: time.Now()}
-	o.exposeMu.Lock()
-	o.exposed[sessionID] = append(o.exposed[sessionID], entry)
-	o.exposeMu.Unlock()
+	url := o.exposeURL(baseURL, sessionID, port)
+	mode := requestedExposeAccess(access)
+	var token, tokenSHA256 string
+	if mode == e2b.ExposeAccessToken {
+		if token, err = newExposeToken(); err != nil {
+			return nil, status.Errorf(codes.Internal, "expose: token: %v", err)
+		}
+		tokenSHA256 = e2b.ExposeTokenSHA256(token)
+	}
+	nonce, err := newExposeNonce()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "expose: nonce: %v", err)
+	}
+	now := metav1.Now()
+	var existing *sandboxv1.ExposedPort
+	session, err = o.mutateExposed(ctx, sessionID, func(ports []sandboxv1.ExposedPort) []sandboxv1.ExposedPort {
+		existing = nil
+		if p, ok := findExposedPort(ports, port); ok {
+			existing = &p
+			return ports
+		}
+		return append(ports, sandboxv1.ExposedPort{Port: port, Host: host, URL: url, StartedAt: &now, Access: mode, TokenSHA256: tokenSHA256, Nonce: nonce})
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "expose: persist: %v", err)
+	}
+	o.indexExposed(session)
+	if existing != nil {
+		return reexposeResponse(existing.URL, existing.Access, access)
+	}
 
 	// Allow gateway/e2b-server ingress to the exposed port on the CNP.
 	if err := o.applySessionCNP(ctx, session); err != nil {
 		// Roll the registry entry back so a failed CNP apply never leaves a
 		// half-exposed port that the proxy route will 404 on.
-		o.removeExposed(sessionID, int(port))
+		if rolled, rerr := o.mutateExposed(ctx, sessionID, func(ports []sandboxv1.ExposedPort) []sandboxv1.ExposedPort {
+			return withoutExposedPort(ports, port)
+		}); rerr == nil {
+			o.indexExposed(rolled)
+		} else {
+			o.removeExposed(sessionID, int(port))
+		}
 		return nil, status.Errorf(codes.Internal, "expose: apply CNP: %v", err)
 	}
-	return &pb.ExposeServiceResponse{Url: url}, nil
+	ev := sessionEvent(session, pb.SessionEventType_SESSION_EVENT_EXPOSED)
+	ev.Port, ev.Url = port, url
+	o.watch.publish(ev)
+	resp := &pb.ExposeServiceResponse{Url: url, Access: exposeAccessPB(mode), Token: token}
+	if token != "" {
+		resp.Url = url + "?" + e2b.ExposeTokenQuery(token)
+	}
+	return resp, nil
+}
+
+// reexposeResponse answers an expose of an already-exposed port. The mode
+// cannot change in place (unspecified keeps whatever the port has), and a
+// token is never handed out again.
+func reexposeResponse(url, existing string, requested pb.ExposeAccess) (*pb.ExposeServiceResponse, error) {
+	have := exposeAccessPB(existing)
+	if requested != pb.ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED && requested != have {
+		return nil, status.Errorf(codes.FailedPrecondition, "port is already exposed with %s access; unexpose it first to change the mode", e2b.ExposeAccessMode(existing))
+	}
+	return &pb.ExposeServiceResponse{Url: url, Access: have}, nil
+}
+
+// requestedExposeAccess maps the access mode asked of ExposeService to its
+// status form; unspecified is token.
+func requestedExposeAccess(a pb.ExposeAccess) string {
+	switch a {
+	case pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC:
+		return e2b.ExposeAccessPublic
+	case pb.ExposeAccess_EXPOSE_ACCESS_SIGNED:
+		return e2b.ExposeAccessSigned
+	}
+	return e2b.ExposeAccessToken
+}
+
+// exposeAccessPB maps a status access mode to the RPC form. Ports exposed
+// before access control have none and read as token (e2b.ExposeAccessMode).
+func exposeAccessPB(access string) pb.ExposeAccess {
+	switch e2b.ExposeAccessMode(access) {
+	case e2b.ExposeAccessPublic:
+		return pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC
+	case e2b.ExposeAccessSigned:
+		return pb.ExposeAccess_EXPOSE_ACCESS_SIGNED
+	}
+	return pb.ExposeAccess_EXPOSE_ACCESS_TOKEN
+}
+
+// newExposeToken returns a random token-mode access token.
+func newExposeToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newExposeNonce returns a random per-exposure nonce.
+func newExposeNonce() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// exposedNonce returns the nonce of sessionID's exposed port from the
+// index, which every successful ExposeService leaves current.
+func (o *Orchestrator) exposedNonce(sessionID string, port int) string {
+	o.exposeMu.Lock()
+	defer o.exposeMu.Unlock()
+	if e, ok := o.findExposedLocked(sessionID, port); ok {
+		return e.Nonce
+	}
+	return ""
+}
+
+// mutateExposed applies fn to the session's status.exposedPorts, re-reading
+// on conflicts so concurrent exposes on different replicas never lose each
+// other's ports.
+func (o *Orchestrator) mutateExposed(ctx context.Context, sessionID string, fn func([]sandboxv1.ExposedPort) []sandboxv1.ExposedPort) (*sandboxv1.SandboxSession, error) {
+	return o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.ExposedPorts = fn(st.ExposedPorts)
+	})
+}
+
+func findExposedPort(ports []sandboxv1.ExposedPort, port int32) (sandboxv1.ExposedPort, bool) {
+	for _, p := range ports {
+		if p.Port == port {
+			return p, true
+		}
+	}
+	return sandboxv1.ExposedPort{}, false
+}
+
+func withoutExposedPort(ports []sandboxv1.ExposedPort, port int32) []sandboxv1.ExposedPort {
+	var rest []sandboxv1.ExposedPort
+	for _, p := range ports {
+		if p.Port != port {
+			rest = append(rest, p)
+		}
+	}
+	return rest
+}
+
+// indexExposed replaces the session's entries in o.exposed with its
+// status.exposedPorts.
+func (o *Orchestrator) indexExposed(session *sandboxv1.SandboxSession) {
+	o.exposeMu.Lock()
+	defer o.exposeMu.Unlock()
+	o.indexExposedLocked(session)
+}
+
+func (o *Orchestrator) indexExposedLocked(session *sandboxv1.SandboxSession) {
+	if len(session.Status.ExposedPorts) == 0 {
+		delete(o.exposed, session.Name)
+		return
+	}
+	entries := make([]*ExposedEntry, 0, len(session.Status.ExposedPorts))
+	for _, p := range session.Status.ExposedPorts {
+		e := &ExposedEntry{Port: int(p.Port), Host: p.Host, URL: p.URL, Access: p.Access, Nonce: p.Nonce}
+		if p.StartedAt != nil {
+			e.StartedAt = p.StartedAt.Time
+		}
+		entries = append(entries, e)
+	}
+	o.exposed[session.Name] = entries
+}
+
+// RebuildExposedRegistry scans Session CRDs and rebuilds the expose index
+// on startup from their status.exposedPorts.
+func (o *Orchestrator) RebuildExposedRegistry(ctx context.Context, namespace string) {
+	sessions, err := o.listSessions(ctx, namespace, "all")
+	if err != nil {
+		return
+	}
+	o.exposeMu.Lock()
+	defer o.exposeMu.Unlock()
+	for _, s := range sessions {
+		o.indexExposedLocked(s)
+	}
+}
+
+// onSessionExposed and onSessionExposedDelete feed session informer
+// events into the expose index.
+func (o *Orchestrator) onSessionExposed(obj any) {
+	if s := objToSession(obj); s != nil {
+		o.indexExposed(s)
+	}
+}
+
+func (o *Orchestrator) onSessionExposedDelete(obj any) {
+	if tomb, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tomb.Obj
+	}
+	if s := objToSession(obj); s != nil {
+		o.exposeMu.Lock()
+		delete(o.exposed, s.Name)
+		o.exposeMu.Unlock()
+	}
 }
 
 // removeExposed deletes one port from a session's registry (no-op when absent).
@@ -108,16 +344,36 @@ func (o *Orchestrator) UnexposeService(ctx context.Context, sessionID string, po
 	o.exposeMu.Lock()
 	_, found := o.findExposedLocked(sessionID, int(port))
 	o.exposeMu.Unlock()
-	if !found {
-		return &pb.UnexposeServiceResponse{Ok: false}, nil
-	}
 	o.removeExposed(sessionID, int(port))
 
-	if session, err := o.getSession(ctx, sessionID); err == nil {
-		if err := o.applySessionCNP(ctx, session); err != nil {
-			return nil, status.Errorf(codes.Internal, "unexpose: apply CNP: %v", err)
+	session, err := o.getSession(ctx, sessionID)
+	if err != nil {
+		if !found {
+			return &pb.UnexposeServiceResponse{Ok: false}, nil
+		}
+		ev := &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_UNEXPOSED, SessionId: sessionID, Port: port}
+		o.watch.publish(ev)
+		return &pb.UnexposeServiceResponse{Ok: true}, nil
+	}
+	if _, ok := findExposedPort(session.Status.ExposedPorts, port); ok {
+		found = true
+		session, err = o.mutateExposed(ctx, sessionID, func(ports []sandboxv1.ExposedPort) []sandboxv1.ExposedPort {
+			return withoutExposedPort(ports, port)
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unexpose: persist: %v", err)
 		}
+		o.indexExposed(session)
+	}
+	if !found {
+		return &pb.UnexposeServiceResponse{Ok: false}, nil
+	}
+	if err := o.applySessionCNP(ctx, session); err != nil {
+		return nil, status.Errorf(codes.Internal, "unexpose: apply CNP: %v", err)
 	}
+	ev := sessionEvent(session, pb.SessionEventType_SESSION_EVENT_UNEXPOSED)
+	ev.Port = port
+	o.watch.publish(ev)
 	return &pb.UnexposeServiceResponse{Ok: true}, nil
 }
 
@@ -130,29 +386,25 @@ func (o *Orchestrator) findExposedLocked(sessionID string, port int) (*ExposedEn
 	return nil, false
 }
 
-// ListExposed returns the current exposures for a session. Entries whose
-// session is gone are pruned.
+// ListExposed returns the current exposures for a session from its status.
+// Index entries whose session is gone are pruned.
 func (o *Orchestrator) ListExposed(ctx context.Context, sessionID string) (*pb.ListExposedResponse, error) {
-	o.exposeMu.Lock()
-	entries := append([]*ExposedEntry(nil), o.exposed[sessionID]...)
-	o.exposeMu.Unlock()
-
-	// Prune entries for sessions that no longer exist.
-	if _, err := o.getSession(ctx, sessionID); err != nil {
+	session, err := o.getSession(ctx, sessionID)
+	if err != nil {
 		o.exposeMu.Lock()
 		delete(o.exposed, sessionID)
 		o.exposeMu.Unlock()
-		entries = nil
+		return &pb.ListExposedResponse{Services: []*pb.ExposedService{}}, nil
 	}
+	o.indexExposed(session)
 
-	services := make([]*pb.ExposedService, 0, len(entries))
-	for _, e := range entries {
-		services = append(services, &pb.ExposedService{
-			Port:      int32(e.Port),
-			Url:       e.URL,
-			Host:      e.Host,
-			StartedAt: e.StartedAt.Unix(),
-		})
+	services := make([]*pb.ExposedService, 0, len(session.Status.ExposedPorts))
+	for _, p := range session.Status.ExposedPorts {
+		svc := &pb.ExposedService{Port: p.Port, Url: p.URL, Host: p.Host, Access: exposeAccessPB(p.Access), TokenSha256: p.TokenSHA256, Nonce: p.Nonce}
+		if p.StartedAt != nil {
+			svc.StartedAt = p.StartedAt.Unix()
+		}
+		services = append(services, svc)
 	}
 	return &pb.ListExposedResponse{Services: services}, nil
 }
@@ -160,31 +412,39 @@ func (o *Orchestrator) ListExposed(ctx context.Context, sessionID string) (*pb.L
 // UpdateAllowedHosts replaces the session's egress allowlist and re-applies
 // the per-session CNP so the change is live (FQDN mode) / declared (default
 // world-443 mode). Empty list clears the override (falls back to matrix
-// defaults on the next create). Existing exposed ports are preserved.
-func (o *Orchestrator) UpdateAllowedHosts(ctx context.Context, sessionID string, hosts []string) ([]string, error) {
+// defaults on the next create). rules replace the structured egress rules
+// the same way. Existing exposed ports are preserved.
+func (o *Orchestrator) UpdateAllowedHosts(ctx context.Context, sessionID string, hosts []string, rules []sandboxv1.EgressRule) ([]string, []sandboxv1.EgressRule, error) {
+	if err := checkEgressEnforced(rules, o.egressHostsEnforced()); err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "rules: %v", err)
+	}
 	session, err := o.getSession(ctx, sessionID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	session.Spec.AllowedHosts = hosts
+	session.Spec.EgressRules = rules
 	o.updateSession(ctx, session)
 	if err := o.applySessionCNP(ctx, session); err != nil {
-		return nil, status.Errorf(codes.Internal, "update allowed hosts: apply CNP: %v", err)
+		return nil, nil, status.Errorf(codes.Internal, "update allowed hosts: apply CNP: %v", err)
 	}
-	return hosts, nil
+	return hosts, rules, nil
 }
 
 // applySessionCNP rebuilds and applies the session CNP including any exposed
 // service ports (gateway/e2b-server ingress) plus the session's allowedHosts
 // egress. Central chokepoint so expose/unexpose/allow-hosts never clobber
-// each other.
-func (o *Orchestrator) applySessionCNP(ctx context.Context, session *sandboxv1.SandboxSession) error {
-	o.exposeMu.Lock()
+// each other. In egress proxy mode a NetworkPolicy takes the CNP's place.
+func (o *Orchestrator) applySessionCNP(ctx context.Context, session *sandboxv1.SandboxSession) (err error) {
+	ctx, span := tracing.Start(ctx, "sandbox.applySessionCNP", attribute.String("sandbox.session_id", session.Name))
+	defer func() { tracing.End(span, err) }()
+	if egressProxyEnabled() {
+		return o.applySessionNetworkPolicy(ctx, session)
+	}
 	var ports []int32
-	for _, e := range o.exposed[session.Name] {
-		ports = append(ports, int32(e.Port))
+	for _, p := range session.Status.ExposedPorts {
+		ports = append(ports, p.Port)
 	}
-	o.exposeMu.Unlock()
 
 	obj := buildSessionCNPExposed(session, o.fqdnEnabled(), ports)
 	name := fmt.Sprintf("sandbox-session-%s", session.Name)
@@ -2,12 +2,18 @@ package grpc
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/xiaods/k8e/pkg/sandbox/e2b"
 	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 )
 
 // seedSession creates a SandboxSession CRD in the fake dynamic client.
@@ -32,7 +38,7 @@ func TestExposeService_RegistersAndReturnsGatewayURL(t *testing.T) {
 	o := newTestOrchestrator()
 	seedSession(t, o, "sess-1")
 
-	resp, err := o.ExposeService(context.Background(), "sess-1", 8080, "127.0.0.1", "http://gw.example.com")
+	resp, err := o.ExposeService(context.Background(), "sess-1", 8080, "127.0.0.1", "http://gw.example.com", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC)
 	if err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
@@ -68,11 +74,11 @@ func TestExposeService_Idempotent(t *testing.T) {
 	o := newTestOrchestrator()
 	o.exposeMu.Lock()
 	o.exposed["sess-1"] = []*ExposedEntry{
-		{Port: 8080, Host: "127.0.0.1", URL: "http://gw/k8e/expose/sess-1/8080/", StartedAt: time.Now()},
+		{Port: 8080, Host: "127.0.0.1", URL: "http://gw/k8e/expose/sess-1/8080/", Access: e2b.ExposeAccessPublic, StartedAt: time.Now()},
 	}
 	o.exposeMu.Unlock()
 
-	resp, err := o.ExposeService(context.Background(), "sess-1", 8080, "", "http://other")
+	resp, err := o.ExposeService(context.Background(), "sess-1", 8080, "", "http://other", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC)
 	if err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
@@ -85,10 +91,10 @@ func TestExposeService_Idempotent(t *testing.T) {
 // before any registry/CNP mutation.
 func TestExposeService_InvalidPort(t *testing.T) {
 	o := newTestOrchestrator()
-	if _, err := o.ExposeService(context.Background(), "sess-1", 0, "", "http://gw"); err == nil {
+	if _, err := o.ExposeService(context.Background(), "sess-1", 0, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err == nil {
 		t.Fatal("expected error for port 0")
 	}
-	if _, err := o.ExposeService(context.Background(), "sess-1", 70000, "", "http://gw"); err == nil {
+	if _, err := o.ExposeService(context.Background(), "sess-1", 70000, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err == nil {
 		t.Fatal("expected error for port 70000")
 	}
 }
@@ -96,7 +102,7 @@ func TestExposeService_InvalidPort(t *testing.T) {
 // TestExposeService_UnknownSession verifies a missing session errors.
 func TestExposeService_UnknownSession(t *testing.T) {
 	o := newTestOrchestrator()
-	if _, err := o.ExposeService(context.Background(), "nope", 8080, "", "http://gw"); err == nil {
+	if _, err := o.ExposeService(context.Background(), "nope", 8080, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err == nil {
 		t.Fatal("expected error for unknown session")
 	}
 }
@@ -160,13 +166,69 @@ func TestListExposed_PrunesGoneSession(t *testing.T) {
 	}
 }
 
+// TestExposeService_PersistsAcrossRestartAndReplicas verifies exposures
+// live in the session status: a restarted gateway rebuilds its index from
+// it, another replica lists and unexposes the port, and CNP rebuilds keep
+// the port without any in-memory entry.
+func TestExposeService_PersistsAcrossRestartAndReplicas(t *testing.T) {
+	o := newTestOrchestrator()
+	seedSession(t, o, "sess-1")
+	ctx := context.Background()
+	if _, err := o.ExposeService(ctx, "sess-1", 8080, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err != nil {
+		t.Fatalf(msgUnexpected, err)
+	}
+	got, err := o.getSession(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if len(got.Status.ExposedPorts) != 1 || got.Status.ExposedPorts[0].Port != 8080 || got.Status.ExposedPorts[0].URL != "http://gw/k8e/expose/sess-1/8080/" {
+		t.Fatalf("status.exposedPorts not persisted: %+v", got.Status.ExposedPorts)
+	}
+
+	// Restart: the in-memory index is gone until rebuilt.
+	o.exposeMu.Lock()
+	o.exposed = map[string][]*ExposedEntry{}
+	o.exposeMu.Unlock()
+	if _, _, err := o.UpdateAllowedHosts(ctx, "sess-1", []string{"a.com"}, nil); err != nil {
+		t.Fatalf(msgUnexpected, err)
+	}
+	cnp, err := o.dynamic.Resource(cnpGVR).Namespace(sandboxNS).Get(ctx, "sandbox-session-sess-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get CNP: %v", err)
+	}
+	if !strings.Contains(fmt.Sprint(cnp.Object["spec"]), "port:8080") {
+		t.Fatal("CNP rebuilt without the exposed port")
+	}
+	o.RebuildExposedRegistry(ctx, sandboxNS)
+	o.exposeMu.Lock()
+	rebuilt := len(o.exposed["sess-1"])
+	o.exposeMu.Unlock()
+	if rebuilt != 1 {
+		t.Fatalf("rebuild restored %d entries, want 1", rebuilt)
+	}
+
+	// A second replica over the same cluster sees and removes it.
+	replica := newTestOrchestrator()
+	replica.dynamic = o.dynamic
+	listed, err := replica.ListExposed(ctx, "sess-1")
+	if err != nil || len(listed.Services) != 1 || listed.Services[0].Port != 8080 {
+		t.Fatalf("replica list: %v %v", listed, err)
+	}
+	if resp, err := replica.UnexposeService(ctx, "sess-1", 8080); err != nil || !resp.Ok {
+		t.Fatalf("replica unexpose: %v %v", resp, err)
+	}
+	if listed, _ = o.ListExposed(ctx, "sess-1"); len(listed.Services) != 0 {
+		t.Fatalf("port still listed after unexpose on another replica: %v", listed.Services)
+	}
+}
+
 // TestUpdateAllowedHosts_AppliesAndPersists verifies the allowlist update
 // persists to the session spec and re-applies the CNP (live semantics).
 func TestUpdateAllowedHosts_AppliesAndPersists(t *testing.T) {
 	o := newTestOrchestrator()
 	seedSession(t, o, "sess-1")
 
-	hosts, err := o.UpdateAllowedHosts(context.Background(), "sess-1", []string{"a.com", "b.com"})
+	hosts, _, err := o.UpdateAllowedHosts(context.Background(), "sess-1", []string{"a.com", "b.com"}, nil)
 	if err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
@@ -192,7 +254,7 @@ func TestUpdateAllowedHosts_AppliesAndPersists(t *testing.T) {
 	}
 
 	// Clearing the list is allowed and persists.
-	if _, err := o.UpdateAllowedHosts(context.Background(), "sess-1", nil); err != nil {
+	if _, _, err := o.UpdateAllowedHosts(context.Background(), "sess-1", nil, nil); err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
 	got, err = o.getSession(context.Background(), "sess-1")
@@ -208,7 +270,7 @@ func TestUpdateAllowedHosts_AppliesAndPersists(t *testing.T) {
 // (NotFound) instead of silently succeeding.
 func TestUpdateAllowedHosts_UnknownSession(t *testing.T) {
 	o := newTestOrchestrator()
-	if _, err := o.UpdateAllowedHosts(context.Background(), "nope", []string{"a.com"}); err == nil {
+	if _, _, err := o.UpdateAllowedHosts(context.Background(), "nope", []string{"a.com"}, nil); err == nil {
 		t.Fatal("expected error for unknown session")
 	}
 }
@@ -255,7 +317,7 @@ func TestExposeService_UpdatesExistingCNP(t *testing.T) {
 	seedSession(t, o, "sess-1")
 
 	// First expose creates the CNP.
-	if _, err := o.ExposeService(context.Background(), "sess-1", 8080, "", "http://gw"); err != nil {
+	if _, err := o.ExposeService(context.Background(), "sess-1", 8080, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
 	first, err := o.dynamic.Resource(cnpGVR).Namespace(sandboxNS).Get(context.Background(), "sandbox-session-sess-1", metav1.GetOptions{})
@@ -271,7 +333,7 @@ func TestExposeService_UpdatesExistingCNP(t *testing.T) {
 
 	// Second expose (another port) must UPDATE without clobbering RV,
 	// and both exposed ports must appear in the ingress rules.
-	if _, err := o.ExposeService(context.Background(), "sess-1", 9090, "", "http://gw"); err != nil {
+	if _, err := o.ExposeService(context.Background(), "sess-1", 9090, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err != nil {
 		t.Fatalf(msgUnexpected, err)
 	}
 	second, err := o.dynamic.Resource(cnpGVR).Namespace(sandboxNS).Get(context.Background(), "sandbox-session-sess-1", metav1.GetOptions{})
@@ -295,3 +357,103 @@ func TestExposeService_UpdatesExistingCNP(t *testing.T) {
 		t.Fatalf("expected both ports present after update, got %v", portCount)
 	}
 }
+
+// TestExposeService_AccessModes verifies the default token mode hands out
+// its token once and persists only the hash, that a re-expose cannot switch
+// modes, and that signed mode mints a fresh signed URL per call.
+func TestExposeService_AccessModes(t *testing.T) {
+	s := newTestServer()
+	s.exposeBaseURLOverride, s.exposeSigningSecret = "http://gw", "secret"
+	ctx := context.Background()
+	seedSession(t, s.orch, "sess-1")
+
+	resp, err := s.ExposeService(ctx, &pb.ExposeServiceRequest{SessionId: "sess-1", Port: 8080})
+	if err != nil {
+		t.Fatalf(msgUnexpected, err)
+	}
+	if resp.Access != pb.ExposeAccess_EXPOSE_ACCESS_TOKEN || resp.Token == "" {
+		t.Fatalf("default access: %v", resp)
+	}
+	if want := "http://gw/k8e/expose/sess-1/8080/?" + e2b.ExposeTokenQuery(resp.Token); resp.Url != want {
+		t.Fatalf("token URL = %q, want %q", resp.Url, want)
+	}
+	sess, err := s.orch.getSession(ctx, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := sess.Status.ExposedPorts[0]
+	if p.Access != e2b.ExposeAccessToken || p.TokenSHA256 != e2b.ExposeTokenSHA256(resp.Token) || strings.Contains(p.URL, resp.Token) {
+		t.Fatalf("persisted port: %+v", p)
+	}
+	listed, _ := s.orch.ListExposed(ctx, "sess-1")
+	if svc := listed.Services[0]; svc.Access != pb.ExposeAccess_EXPOSE_ACCESS_TOKEN || svc.TokenSha256 != p.TokenSHA256 {
+		t.Fatalf("listed: %v", svc)
+	}
+
+	again, err := s.ExposeService(ctx, &pb.ExposeServiceRequest{SessionId: "sess-1", Port: 8080})
+	if err != nil || again.Token != "" || again.Url != "http://gw/k8e/expose/sess-1/8080/" {
+		t.Fatalf("re-expose must not return the token again: %v %v", again, err)
+	}
+	_, err = s.ExposeService(ctx, &pb.ExposeServiceRequest{SessionId: "sess-1", Port: 8080, Access: pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("mode change on re-expose: %v", err)
+	}
+
+	signed := &pb.ExposeServiceRequest{SessionId: "sess-1", Port: 9090, Access: pb.ExposeAccess_EXPOSE_ACCESS_SIGNED, TtlSeconds: 60}
+	resp, err = s.ExposeService(ctx, signed)
+	if err != nil {
+		t.Fatalf(msgUnexpected, err)
+	}
+	expires := time.Unix(resp.ExpiresAt, 0)
+	if d := time.Until(expires); d <= 0 || d > time.Minute {
+		t.Fatalf("expires_at %v", expires)
+	}
+	sess, _ = s.orch.getSession(ctx, "sess-1")
+	p, _ = findExposedPort(sess.Status.ExposedPorts, 9090)
+	if p.Nonce == "" {
+		t.Fatalf("exposure without a nonce: %+v", p)
+	}
+	if want := "http://gw/k8e/expose/sess-1/9090/?" + e2b.SignExposeQuery("secret", "sess-1", 9090, p.Nonce, expires); resp.Url != want || resp.Token != "" {
+		t.Fatalf("signed URL = %q, want %q", resp.Url, want)
+	}
+	signed.TtlSeconds = 120
+	again, err = s.ExposeService(ctx, signed)
+	if err != nil || again.ExpiresAt <= resp.ExpiresAt || again.Url == resp.Url {
+		t.Fatalf("re-expose must mint a new signed URL: %v %v", again, err)
+	}
+	signed.TtlSeconds = maxExposeTTLSeconds + 1
+	if _, err := s.ExposeService(ctx, signed); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ttl over the cap: %v", err)
+	}
+
+	// Re-exposing after an unexpose starts a new exposure with a new nonce.
+	signed.TtlSeconds = 60
+	if _, err := s.orch.UnexposeService(ctx, "sess-1", 9090); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ExposeService(ctx, signed); err != nil {
+		t.Fatalf(msgUnexpected, err)
+	}
+	sess, _ = s.orch.getSession(ctx, "sess-1")
+	if q, _ := findExposedPort(sess.Status.ExposedPorts, 9090); q.Nonce == "" || q.Nonce == p.Nonce {
+		t.Fatalf("re-exposure nonce %q, previous %q", q.Nonce, p.Nonce)
+	}
+}
+
+func TestExposeURL_PerExposureHost(t *testing.T) {
+	o := &Orchestrator{}
+	if got := o.exposeURL("http://gw", "sess-1", 8080); got != "http://gw/k8e/expose/sess-1/8080/" {
+		t.Fatalf("shared path URL = %q", got)
+	}
+	o.exposeDomain = "expose.example.com"
+	for _, tc := range []struct{ base, sid, want string }{
+		{"https://gw.example.com", "sess-1", "https://8080-sess-1.expose.example.com/"},
+		{"http://ec2-1-2-3-4.compute.amazonaws.com:31422", "sess-1", "http://8080-sess-1.expose.example.com:31422/"},
+		// Not a DNS label: falls back to the shared path.
+		{"http://gw", "Sess_1", "http://gw/k8e/expose/Sess_1/8080/"},
+	} {
+		if got := o.exposeURL(tc.base, tc.sid, 8080); got != tc.want {
+			t.Errorf("exposeURL(%q, %q) = %q, want %q", tc.base, tc.sid, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Session forking for tree-search agents. A RunSubAgent child shares its
+// parent's pod and workspace, so siblings cannot diverge. A fork instead
+// snapshots the parent's /workspace into the layer store and restores it
+// into count ordinary sessions: warm-claimed when the parent is ephemeral,
+// cold-started on their own PVC when it has a tenant.
+
+// maxForkCount bounds the children of one ForkSession call.
+const maxForkCount = 16
+
+// ForkSession clones req.SessionId into req.Count independent sessions.
+func (s *Server) ForkSession(ctx context.Context, req *pb.ForkSessionRequest) (*pb.ForkSessionResponse, error) {
+	store, err := s.requireLayerStore()
+	if err != nil {
+		return nil, err
+	}
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id required")
+	}
+	count := int(req.Count)
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count > maxForkCount {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxForkCount)
+	}
+	parent, err := s.orch.getSession(ctx, req.SessionId)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "session %s not found", req.SessionId)
+	}
+	if parent.Status.Phase != sandboxv1.SandboxPhaseActive {
+		return nil, status.Errorf(codes.FailedPrecondition, "session %s is %s; only active sessions can be forked",
+			req.SessionId, parent.Status.Phase)
+	}
+	if err := s.orch.CheckCapacity(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	forkID, err := newForkID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "fork id: %v", err)
+	}
+	snapName := "fork-" + forkID + "-" + parent.Name
+	snap, err := s.SnapshotSession(ctx, &pb.SnapshotSessionRequest{SessionId: parent.Name, Name: snapName, Timeout: req.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	// The manifest only carries the fork; its layers go with the next layer
+	// GC unless a saved snapshot shares them.
+	defer func() {
+		if err := store.DeleteManifest(snapName); err != nil {
+			logrus.Warnf("sandbox fork: delete %s: %v", snapName, err)
+		}
+	}()
+
+	ids := make([]string, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := range ids {
+		ids[i] = forkChildID(parent.Name, forkID, i)
+		lineage := &sandboxv1.SessionLineage{
+			ForkedFrom: parent.Name,
+			ForkID:     forkID,
+			ForkIndex:  i,
+			ForkedAt:   &metav1.Time{Time: start},
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.hydrateFork(ctx, parent, ids[i], lineage, snapName, req.Timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	// All or nothing: a partial fork would leave the caller with siblings it
+	// did not ask for.
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		for _, id := range ids {
+			if derr := s.orch.DestroySession(context.WithoutCancel(ctx), id); derr != nil && status.Code(derr) != codes.NotFound {
+				logrus.Warnf("sandbox fork: destroy %s: %v", id, derr)
+			}
+		}
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "fork child %d: %v", i, err)
+	}
+	s.orch.recordFork(start, count)
+	return &pb.ForkSessionResponse{
+		SessionIds: ids,
+		SizeBytes:  snap.SizeBytes,
+		LatencyMs:  time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// hydrateFork creates fork child id and restores the parent's snapshot into
+// its workspace.
+func (s *Server) hydrateFork(ctx context.Context, parent *sandboxv1.SandboxSession, id string, lineage *sandboxv1.SessionLineage, snapName string, timeout int32) error {
+	if _, err := s.orch.createForkChild(ctx, parent, id, lineage); err != nil {
+		return err
+	}
+	_, err := s.RestoreSession(ctx, &pb.RestoreSessionRequest{SessionId: id, Name: snapName, Timeout: timeout})
+	return err
+}
+
+// createForkChild creates session id with the parent's tenant, runtime,
+// template, env, secret refs and allowed hosts.
+func (o *Orchestrator) createForkChild(ctx context.Context, parent *sandboxv1.SandboxSession, id string, lineage *sandboxv1.SessionLineage) (*sandboxv1.SandboxSession, error) {
+	_, ttl, cpu, memory := o.getMatrixConfig(ctx)
+	req := &pb.CreateSessionRequest{
+		SessionId:    id,
+		TenantId:     parent.Spec.TenantID,
+		RuntimeClass: parent.Spec.RuntimeClass,
+		AllowedHosts: parent.Spec.AllowedHosts,
+		Env:          parent.Spec.Env,
+		SecretRefs:   apiSecretRefsToPB(parent.Spec.SecretRefs),
+		Template:     parent.Spec.Template,
+		EgressRules:  egressRulesToPB(parent.Spec.EgressRules),
+	}
+	return o.createSessionWithTTL(ctx, req, ttl, nil, cpu, memory, lineage)
+}
+
+// newForkID returns a short random id shared by the children of one fork.
+func newForkID() (string, error) {
+	raw := make([]byte, 3)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// forkChildID names child index of a fork, shortening the parent id so the
+// result stays a valid label value (63 characters).
+func forkChildID(parent, forkID string, index int) string {
+	suffix := fmt.Sprintf("-fork-%s-%d", forkID, index)
+	if max := 63 - len(suffix); len(parent) > max {
+		parent = strings.TrimRight(parent[:max], "-.")
+	}
+	return parent + suffix
+}
@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// forkTestServer returns a server with a layer store, node capacity, a
+// parent session "fork-parent" at 127.0.0.1 and cold-started session pods
+// that come up at 127.0.0.1 too.
+func forkTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer()
+	ls, err := sandboxlayer.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("layerstore: %v", err)
+	}
+	s.layerStore = ls
+	ctx := context.Background()
+	k8s := s.k8s.(*kubefake.Clientset)
+	k8s.CoreV1().Nodes().Create(ctx, &corev1.Node{ //nolint:errcheck
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status:     corev1.NodeStatus{Allocatable: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Gi")}},
+	}, metav1.CreateOptions{})
+	k8s.PrependReactor("create", "pods", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		a.(k8stesting.CreateAction).GetObject().(*corev1.Pod).Status.PodIP = "127.0.0.1"
+		return false, nil, nil
+	})
+	if _, err := s.orch.CreateSession(ctx, &pb.CreateSessionRequest{
+		SessionId:    "fork-parent",
+		AllowedHosts: []string{"pypi.org"},
+		Env:          map[string]string{"MODE": "search"},
+		SecretRefs:   []*pb.SecretRef{{SecretName: "llm", Key: "token", EnvVar: "API_TOKEN"}},
+	}); err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	return s
+}
+
+// forkSandboxd serves the parent's workspace archive and records restores;
+// extracts fail when failRestore is set.
+func forkSandboxd(t *testing.T, failRestore bool) *restoreSandboxd {
+	t.Helper()
+	archive := testWorkspaceTar(t)
+	f := &restoreSandboxd{files: map[string]string{}}
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/exec/stream":
+			writeExecSSE(w, archive, 0)
+		case r.URL.Path == "/exec" && failRestore:
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+			if cmd, _ := body["command"].(string); !strings.HasPrefix(cmd, "ls ") {
+				json.NewEncoder(w).Encode(map[string]any{"exit_code": 2, "stderr": "tar: disk full"}) //nolint:errcheck
+				return
+			}
+			w.Write([]byte(`{"exit_code":0}`)) //nolint:errcheck
+		default:
+			f.ServeHTTP(w, r)
+		}
+	}))
+	return f
+}
+
+func TestForkSession_ClonesParentIntoChildren(t *testing.T) {
+	s := forkTestServer(t)
+	ctx := context.Background()
+	sandboxd := forkSandboxd(t, false)
+
+	resp, err := s.ForkSession(ctx, &pb.ForkSessionRequest{SessionId: "fork-parent", Count: 3})
+	if err != nil {
+		t.Fatalf("ForkSession: %v", err)
+	}
+	if len(resp.SessionIds) != 3 || resp.SizeBytes == 0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	for i, id := range resp.SessionIds {
+		child, err := s.orch.getSession(ctx, id)
+		if err != nil {
+			t.Fatalf("child %s: %v", id, err)
+		}
+		l := child.Spec.Lineage
+		if l == nil || l.ForkedFrom != "fork-parent" || l.ForkIndex != i || l.ForkID == "" {
+			t.Fatalf("child %s lineage %+v", id, l)
+		}
+		if child.Spec.Env["MODE"] != "search" || len(child.Spec.AllowedHosts) != 1 ||
+			len(child.Spec.SecretRefs) != 1 || child.Spec.SecretRefs[0].EnvVar != "API_TOKEN" {
+			t.Fatalf("child %s did not inherit the parent's spec: %+v", id, child.Spec)
+		}
+		if child.Status.Phase != sandboxv1.SandboxPhaseActive || child.Status.PodName == "" {
+			t.Fatalf("child %s has no pod of its own: %+v", id, child.Status)
+		}
+	}
+	if len(sandboxd.scripts) != 3 {
+		t.Fatalf("want one extract per child, got %d", len(sandboxd.scripts))
+	}
+	if names, _ := s.layerStore.ListManifests(); len(names) != 0 {
+		t.Fatalf("fork snapshot left in the registry: %v", names)
+	}
+	if forks, children, _ := s.orch.ForkMetrics(); forks != 1 || children != 3 {
+		t.Fatalf("fork metrics forks=%d children=%d", forks, children)
+	}
+}
+
+func TestForkSession_FailureDestroysChildren(t *testing.T) {
+	s := forkTestServer(t)
+	ctx := context.Background()
+	forkSandboxd(t, true)
+
+	if _, err := s.ForkSession(ctx, &pb.ForkSessionRequest{SessionId: "fork-parent", Count: 2}); status.Code(err) != codes.Internal {
+		t.Fatalf("expected the failed extract to fail the fork, got %v", err)
+	}
+	sessions, err := s.orch.listSessions(ctx, sandboxNS, "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sess := range sessions {
+		if sess.Spec.Lineage != nil {
+			t.Fatalf("child %s survived a failed fork", sess.Name)
+		}
+	}
+	if forks, _, _ := s.orch.ForkMetrics(); forks != 0 {
+		t.Fatal("a failed fork must not be counted")
+	}
+}
+
+func TestForkSession_Validation(t *testing.T) {
+	if _, err := newTestServer().ForkSession(context.Background(), &pb.ForkSessionRequest{SessionId: "x"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without a layer store, got %v", err)
+	}
+	s := forkTestServer(t)
+	ctx := context.Background()
+	for _, count := range []int32{-1, maxForkCount + 1} {
+		if _, err := s.ForkSession(ctx, &pb.ForkSessionRequest{SessionId: "fork-parent", Count: count}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("count %d: expected InvalidArgument, got %v", count, err)
+		}
+	}
+	if _, err := s.orch.updateSessionStatus(ctx, "fork-parent", func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhasePaused
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ForkSession(ctx, &pb.ForkSessionRequest{SessionId: "fork-parent"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for a paused parent, got %v", err)
+	}
+}
+
+func TestForkChildID_StaysALabelValue(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	id := forkChildID(long, "abc123", 15)
+	if len(id) > 63 || !strings.HasSuffix(id, "-fork-abc123-15") {
+		t.Fatalf("child id %q (%d chars)", id, len(id))
+	}
+	if got := forkChildID("sess-1", "abc123", 0); got != "sess-1-fork-abc123-0" {
+		t.Fatalf("short parent id changed: %q", got)
+	}
+}
@@ -1,8 +1,20 @@
 package grpc
 
 import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"github.com/xiaods/k8e/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 )
 
 // Sandbox metrics (KIP-16 M5 / issue #513). These are registered against the
@@ -31,13 +43,225 @@ var (
 		"Average pod acquisition latency in milliseconds (warm + cold).",
 		nil, nil,
 	)
+	sandboxForksDesc = prometheus.NewDesc(
+		"k8e_sandbox_forks_total",
+		"Total completed ForkSession calls.",
+		nil, nil,
+	)
+	sandboxForkChildrenDesc = prometheus.NewDesc(
+		"k8e_sandbox_fork_children_total",
+		"Total sessions created by ForkSession.",
+		nil, nil,
+	)
+	sandboxAvgForkLatencyMsDesc = prometheus.NewDesc(
+		"k8e_sandbox_fork_latency_ms_average",
+		"Average fork latency in milliseconds (parent snapshot to last child hydrated).",
+		nil, nil,
+	)
+	sandboxWebhookDeliveriesDesc = prometheus.NewDesc(
+		"k8e_sandbox_webhook_deliveries_total",
+		"Total SandboxWebhook events delivered (2xx answer).",
+		nil, nil,
+	)
+	sandboxWebhookRetriesDesc = prometheus.NewDesc(
+		"k8e_sandbox_webhook_retries_total",
+		"Total SandboxWebhook delivery attempts retried after a failure.",
+		nil, nil,
+	)
+	sandboxWebhookDeadLettersDesc = prometheus.NewDesc(
+		"k8e_sandbox_webhook_dead_letters_total",
+		"Total SandboxWebhook events given up on and recorded in status.deadLetters.",
+		nil, nil,
+	)
 	sandboxBackgroundRunsDesc = prometheus.NewDesc(
 		"k8e_sandbox_background_runs",
 		"Currently registered background runs.",
 		nil, nil,
 	)
+	sandboxSessionsDesc = prometheus.NewDesc(
+		"k8e_sandbox_sessions",
+		"Sessions by tenant and phase (tenant label capped, see --sandbox-metrics-max-tenants).",
+		[]string{"tenant", "phase"}, nil,
+	)
 )
 
+// Distributions and per-label series are observed where they happen rather
+// than derived at scrape time: averages hide the p99 cold starts SLOs are
+// written against.
+var (
+	sandboxClaimDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8e_sandbox_claim_duration_seconds",
+		Help:    "Pod acquisition latency of new sessions, by source (warm claim or cold start).",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"source"})
+	sandboxExecDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8e_sandbox_exec_duration_seconds",
+		Help:    "Foreground exec wall time, by the request's language hint.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900},
+	}, []string{"language"})
+	sandboxRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8e_sandbox_rpc_duration_seconds",
+		Help:    "Gateway RPC latency by method and gRPC status code (streams: until the stream ends).",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"method", "code"})
+	sandboxSnapshotBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "k8e_sandbox_snapshot_bytes",
+		Help: "Snapshot transfer sizes: put is the archive stored by SnapshotSession, get the layer bytes shipped by RestoreSession.",
+		// 64 KiB … 16 GiB
+		Buckets: prometheus.ExponentialBuckets(64<<10, 4, 10),
+	}, []string{"op"})
+	sandboxWarmPoolPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8e_sandbox_warm_pool_pods",
+		Help: "Warm pods per SandboxWarmPool by state (ready or pending); reported by the controller leader.",
+	}, []string{"pool", "state"})
+	sandboxRateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8e_sandbox_rate_limit_rejections_total",
+		Help: "RPCs rejected by the per-tenant rate limiter (tenant label capped).",
+	}, []string{"tenant"})
+	sandboxEgressProxyConnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8e_sandbox_egress_proxy_connections_total",
+		Help: "Connections and plain-HTTP requests through the egress proxy, by verdict (allowed or denied).",
+	}, []string{"verdict"})
+)
+
+// defaultMetricsMaxTenants caps distinct tenant label values when
+// --sandbox-metrics-max-tenants is unset.
+const defaultMetricsMaxTenants = 100
+
+// tenantOverflowLabel stands in for tenants beyond the cap.
+const tenantOverflowLabel = "other"
+
+// metricsTenants bounds the tenant label: only tenants with a
+// SandboxTenantQuota keep their name, at most max of them in name order;
+// everyone else is reported as "other", so a burst of ad-hoc tenants cannot
+// blow up the series count or claim the named slots for themselves.
+var metricsTenants = &tenantLabeler{max: defaultMetricsMaxTenants, named: map[string]struct{}{}}
+
+type tenantLabeler struct {
+	mu    sync.Mutex
+	max   int
+	named map[string]struct{}
+}
+
+func (l *tenantLabeler) label(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.named[tenant]; ok {
+		return tenant
+	}
+	return tenantOverflowLabel
+}
+
+// setTenants replaces the tenants reported by name.
+func (l *tenantLabeler) setTenants(tenants []string) {
+	sorted := append([]string(nil), tenants...)
+	sort.Strings(sorted)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	named := make(map[string]struct{}, len(sorted))
+	for _, t := range sorted {
+		if len(named) >= l.max {
+			break
+		}
+		if t != "" {
+			named[t] = struct{}{}
+		}
+	}
+	l.named = named
+}
+
+// refreshMetricsTenants names the tenants that have a quota. A failed list
+// keeps the previous set.
+func (o *Orchestrator) refreshMetricsTenants(ctx context.Context) {
+	quotas, err := o.listQuotas(ctx)
+	if err != nil {
+		logrus.Debugf("sandbox metrics: list quotas: %v", err)
+		return
+	}
+	tenants := make([]string, 0, len(quotas))
+	for _, q := range quotas {
+		tenants = append(tenants, quotaTenant(q))
+	}
+	metricsTenants.setTenants(tenants)
+}
+
+// SetMetricsMaxTenants sets the tenant label cap (n <= 0 keeps the default).
+func SetMetricsMaxTenants(n int) {
+	if n <= 0 {
+		return
+	}
+	metricsTenants.mu.Lock()
+	metricsTenants.max = n
+	metricsTenants.mu.Unlock()
+}
+
+// execLanguages are the language hints reported by name; any other hint
+// is "other" so clients cannot mint label values.
+var execLanguages = map[string]bool{"python": true, "bash": true, "sh": true, "node": true, "ts": true}
+
+func execLanguageLabel(lang string) string {
+	lang = strings.ToLower(lang)
+	switch {
+	case lang == "":
+		return "none"
+	case execLanguages[lang]:
+		return lang
+	default:
+		return "other"
+	}
+}
+
+// observeExec meters a finished foreground exec: the session's usage and
+// the latency histogram.
+func (s *Server) observeExec(req *pb.ExecRequest, d time.Duration) {
+	s.orch.recordExec(req.SessionId, 1, d)
+	sandboxExecDuration.WithLabelValues(execLanguageLabel(req.Language)).Observe(d.Seconds())
+}
+
+// WarmPoolCount is one SandboxWarmPool's warm pods.
+type WarmPoolCount struct {
+	Ready, Pending int
+}
+
+// ReportWarmPools replaces the warm pool gauges; pools missing from counts
+// (deleted) drop out. The warm pool reconciler calls it every pass.
+func ReportWarmPools(counts map[string]WarmPoolCount) {
+	sandboxWarmPoolPods.Reset()
+	for pool, c := range counts {
+		sandboxWarmPoolPods.WithLabelValues(pool, "ready").Set(float64(c.Ready))
+		sandboxWarmPoolPods.WithLabelValues(pool, "pending").Set(float64(c.Pending))
+	}
+}
+
+// RateLimitRejected counts a rate-limiter rejection; it is the limiter's
+// OnReject hook.
+func RateLimitRejected(tenant string) {
+	sandboxRateLimitRejections.WithLabelValues(metricsTenants.label(tenant)).Inc()
+}
+
+// rpcMetricsUnaryInterceptor observes unary RPC latency.
+func rpcMetricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeRPC(info.FullMethod, err, start)
+	return resp, err
+}
+
+// rpcMetricsStreamInterceptor observes streaming RPC duration.
+func rpcMetricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeRPC(info.FullMethod, err, start)
+	return err
+}
+
+func observeRPC(fullMethod string, err error, start time.Time) {
+	sandboxRPCDuration.WithLabelValues(path.Base(fullMethod), status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
+
 // NewSandboxMetricsCollector returns a Prometheus Collector bound to the
 // orchestrator's live counters. Register with a registry to expose metrics.
 func NewSandboxMetricsCollector(orch *Orchestrator) prometheus.Collector {
@@ -49,7 +273,14 @@ func (c *sandboxMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- sandboxWarmClaimsDesc
 	ch <- sandboxColdStartsDesc
 	ch <- sandboxAvgClaimLatencyMsDesc
+	ch <- sandboxForksDesc
+	ch <- sandboxForkChildrenDesc
+	ch <- sandboxAvgForkLatencyMsDesc
+	ch <- sandboxWebhookDeliveriesDesc
+	ch <- sandboxWebhookRetriesDesc
+	ch <- sandboxWebhookDeadLettersDesc
 	ch <- sandboxBackgroundRunsDesc
+	ch <- sandboxSessionsDesc
 }
 
 // Collect implements prometheus.Collector — reads atomics at scrape time.
@@ -58,15 +289,64 @@ func (c *sandboxMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(sandboxWarmClaimsDesc, prometheus.CounterValue, float64(warm))
 	ch <- prometheus.MustNewConstMetric(sandboxColdStartsDesc, prometheus.CounterValue, float64(cold))
 	ch <- prometheus.MustNewConstMetric(sandboxAvgClaimLatencyMsDesc, prometheus.GaugeValue, float64(avgMs))
+	forks, children, avgForkMs := c.orch.ForkMetrics()
+	ch <- prometheus.MustNewConstMetric(sandboxForksDesc, prometheus.CounterValue, float64(forks))
+	ch <- prometheus.MustNewConstMetric(sandboxForkChildrenDesc, prometheus.CounterValue, float64(children))
+	ch <- prometheus.MustNewConstMetric(sandboxAvgForkLatencyMsDesc, prometheus.GaugeValue, float64(avgForkMs))
+	delivered, retries, deadLetters := c.orch.WebhookMetrics()
+	ch <- prometheus.MustNewConstMetric(sandboxWebhookDeliveriesDesc, prometheus.CounterValue, float64(delivered))
+	ch <- prometheus.MustNewConstMetric(sandboxWebhookRetriesDesc, prometheus.CounterValue, float64(retries))
+	ch <- prometheus.MustNewConstMetric(sandboxWebhookDeadLettersDesc, prometheus.CounterValue, float64(deadLetters))
 	ch <- prometheus.MustNewConstMetric(sandboxBackgroundRunsDesc, prometheus.GaugeValue, float64(c.orch.countAllBackgroundRuns()))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	c.orch.refreshMetricsTenants(ctx)
+	cancel()
+	for k, n := range c.orch.watch.sessionCounts() {
+		ch <- prometheus.MustNewConstMetric(sandboxSessionsDesc, prometheus.GaugeValue, float64(n), k.tenant, k.phase)
+	}
+}
+
+type tenantPhase struct {
+	tenant, phase string
+}
+
+// sessionCounts counts the cached sessions by (capped) tenant and phase;
+// empty until the session informer has synced.
+func (h *sessionWatchHub) sessionCounts() map[tenantPhase]int {
+	h.mu.Lock()
+	store := h.store
+	h.mu.Unlock()
+	counts := map[tenantPhase]int{}
+	if store == nil {
+		return counts
+	}
+	for _, obj := range store.List() {
+		if s := objToSession(obj); s != nil {
+			phase := string(s.Status.Phase)
+			if phase == "" {
+				phase = "Unknown" // still being created
+			}
+			counts[tenantPhase{metricsTenants.label(sessionTenant(s)), phase}]++
+		}
+	}
+	return counts
 }
 
 // RegisterSandboxMetrics adds the sandbox collectors to the shared k8e metrics
 // registry (idempotent-safe: a second registration is a no-op on error).
 func RegisterSandboxMetrics(orch *Orchestrator) {
-	if err := metrics.DefaultRegisterer.Register(NewSandboxMetricsCollector(orch)); err != nil {
+	for _, c := range []prometheus.Collector{
+		NewSandboxMetricsCollector(orch),
+		sandboxClaimDuration,
+		sandboxExecDuration,
+		sandboxRPCDuration,
+		sandboxSnapshotBytes,
+		sandboxWarmPoolPods,
+		sandboxRateLimitRejections,
+		sandboxEgressProxyConnections,
+	} {
 		// Already-registered collectors are expected on re-initialization;
 		// other errors (name collision) are surfaced through the registry.
-		return
+		_ = metrics.DefaultRegisterer.Register(c)
 	}
 }
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+)
+
+func TestTenantLabeler_NamesQuotaTenantsOnly(t *testing.T) {
+	l := &tenantLabeler{max: 2, named: map[string]struct{}{}}
+	l.setTenants([]string{"c", "a", "b"})
+	for tenant, want := range map[string]string{"a": "a", "b": "b", "c": tenantOverflowLabel, "adhoc": tenantOverflowLabel, "": ""} {
+		if got := l.label(tenant); got != want {
+			t.Fatalf("label(%q) = %q, want %q", tenant, got, want)
+		}
+	}
+	l.setTenants([]string{"c"})
+	if l.label("a") != tenantOverflowLabel || l.label("c") != "c" {
+		t.Fatal("a refresh replaces the named set")
+	}
+}
+
+func TestRefreshMetricsTenants_FromQuotas(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "q-team-a", sandboxv1.SandboxTenantQuotaSpec{TenantID: "team-a"})
+	seedQuota(t, o, "team-b", sandboxv1.SandboxTenantQuotaSpec{})
+	defer metricsTenants.setTenants(nil)
+	o.refreshMetricsTenants(context.Background())
+	if metricsTenants.label("team-a") != "team-a" || metricsTenants.label("team-b") != "team-b" || metricsTenants.label("team-c") != tenantOverflowLabel {
+		t.Fatal("quota tenants are named, others are not")
+	}
+}
+
+func TestExecLanguageLabel(t *testing.T) {
+	for in, want := range map[string]string{"": "none", "Python": "python", "ts": "ts", "cobol'; drop": "other"} {
+		if got := execLanguageLabel(in); got != want {
+			t.Errorf("execLanguageLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSessionCounts_ByTenantAndPhase(t *testing.T) {
+	h := newSessionWatchHub()
+	if len(h.sessionCounts()) != 0 {
+		t.Fatal("counts before the informer synced")
+	}
+	store := newSessionIndexer()
+	for i, s := range []struct {
+		tenant string
+		phase  sandboxv1.SandboxPhase
+	}{{"team-a", sandboxv1.SandboxPhaseActive}, {"team-a", sandboxv1.SandboxPhaseActive}, {"team-a", sandboxv1.SandboxPhasePaused}, {"", ""}} {
+		sess := &sandboxv1.SandboxSession{}
+		sess.Name, sess.Namespace = string(rune('a'+i)), sandboxNS
+		sess.Spec.TenantID = s.tenant
+		sess.Status.Phase = s.phase
+		u, err := sessionToUnstructured(sess)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Add(u) //nolint:errcheck
+	}
+	h.store = store
+	metricsTenants.setTenants([]string{"team-a"})
+	defer metricsTenants.setTenants(nil)
+	counts := h.sessionCounts()
+	if counts[tenantPhase{"team-a", "Active"}] != 2 || counts[tenantPhase{"team-a", "Paused"}] != 1 || counts[tenantPhase{"", "Unknown"}] != 1 {
+		t.Fatalf("counts %v", counts)
+	}
+}
+
+func TestReportWarmPools_ReplacesSeries(t *testing.T) {
+	ReportWarmPools(map[string]WarmPoolCount{"default": {Ready: 3, Pending: 1}, "gpu": {Ready: 1}})
+	if got := testutil.ToFloat64(sandboxWarmPoolPods.WithLabelValues("default", "ready")); got != 3 {
+		t.Fatalf("ready = %v", got)
+	}
+	ReportWarmPools(map[string]WarmPoolCount{"default": {Ready: 2}})
+	if n := testutil.CollectAndCount(sandboxWarmPoolPods); n != 2 {
+		t.Fatalf("deleted pool still reported: %d series", n)
+	}
+}
+
+func TestRPCMetricsInterceptor_ObservesMethodAndCode(t *testing.T) {
+	before := testutil.CollectAndCount(sandboxRPCDuration)
+	info := &grpc.UnaryServerInfo{FullMethod: "/sandbox.v1.SandboxService/MetricsProbe"}
+	rpcMetricsUnaryInterceptor(context.Background(), nil, info, func(context.Context, any) (any, error) { //nolint:errcheck
+		return nil, status.Error(codes.NotFound, "gone")
+	})
+	if n := testutil.CollectAndCount(sandboxRPCDuration); n != before+1 {
+		t.Fatalf("series %d → %d", before, n)
+	}
+	var m dto.Metric
+	if err := sandboxRPCDuration.WithLabelValues("MetricsProbe", "NotFound").(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetHistogram().GetSampleCount() != 1 {
+		t.Fatalf("MetricsProbe/NotFound samples = %d", m.GetHistogram().GetSampleCount())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
@@ -13,6 +14,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
@@ -25,9 +28,12 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/usage"
 )
 
 const (
@@ -65,26 +71,23 @@ var (
 	matrixGVR  = schema.GroupVersionResource{Group: sandboxAPIGroup, Version: "v1alpha1", Resource: "sandboxmatrices"}
 )
 
-type pendingApproval struct {
-	action    string
-	approved  chan bool
-	createdAt time.Time
-}
-
-// approvalTTL is how long a pending approval waits before auto-expiry.
-const approvalTTL = 5 * time.Minute
-
 // Orchestrator handles session lifecycle, sub-agent creation, and confirm_action gating.
 type Orchestrator struct {
 	k8s         kubernetes.Interface
 	dynamic     dynamic.Interface
 	mu          sync.Mutex
-	approvals   map[string]*pendingApproval
 	runRegistry map[string]string // run_id → session_id
+	// runsExited holds registered runs PollRun has seen finish; they stay
+	// pollable but no longer count against run caps. Quotas count from the
+	// session annotations instead (see liveRuns).
+	runsExited map[string]bool
 
 	// KIP-24 service exposure registry: session_id → live tunnel entries.
 	exposeMu sync.Mutex
 	exposed  map[string][]*ExposedEntry
+	// exposeDomain, when set, gives every exposure its own host under this
+	// wildcard domain (see exposeURL).
+	exposeDomain string
 
 	// warmPodHealthCheck decides whether a warm pod's sandboxd is actually ready to
 	// serve on :2024 before the pod is claimed for a session. Overridable in tests.
@@ -95,12 +98,20 @@ type Orchestrator struct {
 	// instead of waiting for the next reconcile tick.
 	OnWarmClaim func()
 
+	// approvalWebhook, when its URL is set, also receives approval.requested.
+	approvalWebhook ApprovalWebhook
+
 	// claim accounting for the SandboxMatrix status surface.
 	claimedFromWarm     atomic.Int64
 	coldStarts          atomic.Int64
 	claimLatencyTotalMs atomic.Int64
 	claimCount          atomic.Int64
 
+	// fork accounting, reported next to the claim metrics.
+	forkCount          atomic.Int64
+	forkChildren       atomic.Int64
+	forkLatencyTotalMs atomic.Int64
+
 	// maxBackgroundRuns caps concurrently-registered background runs per
 	// session. Matches the KIP-16 M12 recommendation (capped, reaped
 	// background execution) and KIP-15 G9 Perplexity parity.
@@ -109,17 +120,42 @@ type Orchestrator struct {
 	// fqdnEgressEnabled enables Cilium toFQDNs egress rules for sessions with
 	// allowedHosts (requires the Cilium DNS proxy; see KIP-16 M10 / #510).
 	fqdnEgressEnabled bool
+
+	// checkpointer, when set, backs memory-preserving pause/resume.
+	checkpointer Checkpointer
+
+	// watch fans session lifecycle events out to WatchSessions streams.
+	watch *sessionWatchHub
+
+	// SandboxWebhook delivery accounting; webhookRuns holds the (newest)
+	// run ids already reported as run.completed.
+	webhookDelivered   atomic.Int64
+	webhookRetries     atomic.Int64
+	webhookDeadLetters atomic.Int64
+	webhookRuns        reportedRuns
+
+	// Usage metering: podStats feeds CPU/memory/egress samples, pendingUsage
+	// holds this replica's exec and snapshot counts not yet written to
+	// status.usage, and usageRecords (cluster-wide) and usageLedger (this
+	// server's archive) record sessions as they end.
+	podStats     PodStatsSource
+	usageRecords *usage.ClusterLedger
+	usageLedger  *usage.Ledger
+	usageMu      sync.Mutex
+	pendingUsage map[string]*sandboxv1.SessionUsage
 }
 
 func NewOrchestrator(k8s kubernetes.Interface, dyn dynamic.Interface) *Orchestrator {
 	return &Orchestrator{
 		k8s:                k8s,
 		dynamic:            dyn,
-		approvals:          make(map[string]*pendingApproval),
 		runRegistry:        make(map[string]string),
+		runsExited:         make(map[string]bool),
 		exposed:            make(map[string][]*ExposedEntry),
 		warmPodHealthCheck: defaultWarmPodHealthCheck,
 		maxBackgroundRuns:  defaultMaxBackgroundRuns,
+		watch:              newSessionWatchHub(),
+		pendingUsage:       make(map[string]*sandboxv1.SessionUsage),
 	}
 }
 
@@ -130,7 +166,8 @@ func NewOrchestrator(k8s kubernetes.Interface, dyn dynamic.Interface) *Orchestra
 // not a reliable readiness signal (the socket can be open before sandboxd
 // finished initialization), so we call sandboxd's /ready endpoint and require
 // status=="ready". The TCP dial is kept only as a fallback for sandboxd images
-// that predate the /ready endpoint.
+// that predate the /ready endpoint. A keyed pod must also prove it holds its
+// sandboxd key, so a pod squatting on a recycled IP is never claimed.
 func defaultWarmPodHealthCheck(ctx context.Context, pod *corev1.Pod) bool {
 	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
 		return false
@@ -138,10 +175,18 @@ func defaultWarmPodHealthCheck(ctx context.Context, pod *corev1.Pod) bool {
 	if !PodReadyCondition(pod) {
 		return false
 	}
+	var key []byte
+	if k := sandboxdKeys.Load(); k != nil {
+		key = k.keyForPod(pod)
+	}
 	hostPort := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(sandboxdPort))
-	if readyHandshake(ctx, hostPort) {
+	if readyHandshake(ctx, hostPort, key) {
 		return true
 	}
+	if key != nil {
+		// Keyed pods run a sandboxd with /ready; no unauthenticated fallback.
+		return false
+	}
 	// Fallback: older sandboxd images without /ready. Keep a short timeout.
 	dialer := &net.Dialer{Timeout: 1500 * time.Millisecond}
 	conn, err := dialer.DialContext(ctx, "tcp", hostPort)
@@ -155,8 +200,9 @@ func defaultWarmPodHealthCheck(ctx context.Context, pod *corev1.Pod) bool {
 // readyHandshake performs the application-layer readiness check against
 // sandboxd's /ready endpoint. It returns true only when sandboxd answers
 // 200 with status=="ready" (venv may still be initializing; a bare TCP dial
-// cannot tell).
-func readyHandshake(ctx context.Context, hostPort string) bool {
+// cannot tell). With a key the request is signed and the response must carry
+// sandboxd's proof of the same key.
+func readyHandshake(ctx context.Context, hostPort string, key []byte) bool {
 	reqCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
 	defer cancel()
 	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost,
@@ -164,6 +210,12 @@ func readyHandshake(ctx context.Context, hostPort string) bool {
 	if err != nil {
 		return false
 	}
+	var mac string
+	if key != nil {
+		if req, mac, err = signSandboxdRequest(req, key, time.Now()); err != nil {
+			return false
+		}
+	}
 	client := &http.Client{Timeout: 1500 * time.Millisecond}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -173,6 +225,9 @@ func readyHandshake(ctx context.Context, hostPort string) bool {
 	if resp.StatusCode != http.StatusOK {
 		return false
 	}
+	if key != nil && authenticateSandboxdResponse(key, mac, resp) != nil {
+		return false
+	}
 	var body struct {
 		Status string `json:"status"`
 	}
@@ -202,12 +257,32 @@ func (o *Orchestrator) SetFQDNEGressEnabled(enabled bool) {
 	o.fqdnEgressEnabled = enabled
 }
 
+// SetCheckpointer enables PAUSE_MODE_MEMORY for the runtime classes c
+// supports; without one every pause is filesystem-only.
+func (o *Orchestrator) SetCheckpointer(c Checkpointer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.checkpointer = c
+}
+
+func (o *Orchestrator) getCheckpointer() Checkpointer {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.checkpointer
+}
+
 func (o *Orchestrator) fqdnEnabled() bool {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	return o.fqdnEgressEnabled
 }
 
+// egressHostsEnforced reports whether host egress rules are matched against
+// traffic: by the Cilium DNS proxy, or by the egress proxy.
+func (o *Orchestrator) egressHostsEnforced() bool {
+	return o.fqdnEnabled() || egressProxyEnabled()
+}
+
 // defaultTTL is used when the session has no explicit TTL (0 = no expiry).
 const defaultTTL = 0
 
@@ -225,20 +300,43 @@ func (o *Orchestrator) Metrics() (claimedFromWarm, coldStarts, avgClaimLatencyMs
 	return
 }
 
+// ForkMetrics returns the number of completed forks, the children they
+// created, and the average fork latency (snapshot to last child hydrated)
+// in milliseconds.
+func (o *Orchestrator) ForkMetrics() (forks, children, avgForkLatencyMs int64) {
+	forks = o.forkCount.Load()
+	children = o.forkChildren.Load()
+	if forks > 0 {
+		avgForkLatencyMs = o.forkLatencyTotalMs.Load() / forks
+	}
+	return
+}
+
+// recordFork updates fork metrics after a successful fork.
+func (o *Orchestrator) recordFork(start time.Time, children int) {
+	o.forkLatencyTotalMs.Add(time.Since(start).Milliseconds())
+	o.forkChildren.Add(int64(children))
+	o.forkCount.Add(1)
+}
+
 // recordClaim updates claim metrics after a successful pod acquisition.
 func (o *Orchestrator) recordClaim(start time.Time, warm bool) {
-	o.claimLatencyTotalMs.Add(time.Since(start).Milliseconds())
+	elapsed := time.Since(start)
+	o.claimLatencyTotalMs.Add(elapsed.Milliseconds())
 	o.claimCount.Add(1)
+	source := "cold"
 	if warm {
 		o.claimedFromWarm.Add(1)
+		source = "warm"
 	} else {
 		o.coldStarts.Add(1)
 	}
+	sandboxClaimDuration.WithLabelValues(source).Observe(elapsed.Seconds())
 }
 
 func (o *Orchestrator) CreateSession(ctx context.Context, req *pb.CreateSessionRequest) (*sandboxv1.SandboxSession, error) {
 	matrixHosts, ttl, cpu, memory := o.getMatrixConfig(ctx)
-	return o.createSessionWithTTL(ctx, req, ttl, matrixHosts, cpu, memory)
+	return o.createSessionWithTTL(ctx, req, ttl, matrixHosts, cpu, memory, nil)
 }
 
 // CheckCapacity returns nil if there is sufficient node memory for a new sandbox pod.
@@ -315,7 +413,7 @@ func podMemoryLimit(pods *corev1.PodList) int64 {
 }
 
 func (o *Orchestrator) CreateSessionWithTTL(ctx context.Context, req *pb.CreateSessionRequest, ttl int) (*sandboxv1.SandboxSession, error) {
-	return o.createSessionWithTTL(ctx, req, ttl, nil, "", "")
+	return o.createSessionWithTTL(ctx, req, ttl, nil, "", "", nil)
 }
 
 // getMatrixConfig reads defaultAllowedHosts, sessionTTL, and resourceLimits from the first SandboxMatrix CRD.
@@ -333,7 +431,7 @@ func (o *Orchestrator) getMatrixConfig(ctx context.Context) (allowedHosts []stri
 	return raw, ttl, cpu, memory
 }
 
-func (o *Orchestrator) createSessionWithTTL(ctx context.Context, req *pb.CreateSessionRequest, ttl int, matrixDefaultHosts []string, matrixCPU, matrixMemory string) (*sandboxv1.SandboxSession, error) {
+func (o *Orchestrator) createSessionWithTTL(ctx context.Context, req *pb.CreateSessionRequest, ttl int, matrixDefaultHosts []string, matrixCPU, matrixMemory string, lineage *sandboxv1.SessionLineage) (*sandboxv1.SandboxSession, error) {
 	if err := validateSessionEnv(req.Env); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "env: %v", err)
 	}
@@ -341,33 +439,58 @@ func (o *Orchestrator) createSessionWithTTL(ctx context.Context, req *pb.CreateS
 	if sessionID == "" {
 		sessionID = fmt.Sprintf("sess-%d", time.Now().UnixNano())
 	}
-	runtimeClass := req.RuntimeClass
-	if runtimeClass == "" {
-		runtimeClass = "gvisor"
+	tmpl, err := o.sessionTemplate(ctx, req.Template)
+	if err != nil {
+		return nil, err
 	}
+	profile := newPodProfile(tmpl, req.RuntimeClass, matrixCPU, matrixMemory)
 
 	now := time.Now()
-	// Use request allowed_hosts; fall back to SandboxMatrix.spec.defaultAllowedHosts.
-	allowedHosts := req.AllowedHosts
-	if len(allowedHosts) == 0 && len(matrixDefaultHosts) > 0 {
+	if err := validateEgressRules(req.EgressRules); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "egress_rules: %v", err)
+	}
+	// Use request allowed_hosts; fall back to the template's, then to
+	// SandboxMatrix.spec.defaultAllowedHosts. Egress rules travel with the
+	// hosts: a request that declares either replaces the template's egress.
+	allowedHosts, egressRules := req.AllowedHosts, egressRulesFromPB(req.EgressRules)
+	if len(allowedHosts) == 0 && len(egressRules) == 0 && tmpl != nil {
+		allowedHosts, egressRules = tmpl.Spec.AllowedHosts, tmpl.Spec.EgressRules
+	}
+	if len(allowedHosts) == 0 && len(egressRules) == 0 && len(matrixDefaultHosts) > 0 {
 		allowedHosts = matrixDefaultHosts
 	}
+	if err := checkEgressEnforced(egressRules, o.egressHostsEnforced()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "egress_rules: %v", err)
+	}
 	if err := validateSecretRefs(req.SecretRefs); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "secret_refs: %v", err)
 	}
+	if hasHostBoundSecrets(req.SecretRefs) && !egressProxyEnabled() {
+		return nil, status.Errorf(codes.FailedPrecondition, "secret_refs: hosts need the egress proxy (--sandbox-egress-proxy-port)")
+	}
+	tenant := requestTenant(ctx, req.TenantId)
+	release, err := o.admitSession(ctx, tenant, profile)
+	if err != nil {
+		return nil, err
+	}
 	session := &sandboxv1.SandboxSession{
 		TypeMeta:   metav1.TypeMeta{APIVersion: sandboxAPIVersion, Kind: "SandboxSession"},
-		ObjectMeta: metav1.ObjectMeta{Name: sessionID, Namespace: sandboxNS},
+		ObjectMeta: metav1.ObjectMeta{Name: sessionID, Namespace: sandboxNS, Annotations: quotaAnnotations(tenant, profile)},
 		Spec: sandboxv1.SandboxSessionSpec{
 			TenantID:     req.TenantId,
 			AllowedHosts: allowedHosts,
-			RuntimeClass: runtimeClass,
+			RuntimeClass: profile.runtimeClass,
 			Depth:        0,
 			Env:          req.Env,
 			SecretRefs:   pbSecretRefsToAPI(req.SecretRefs),
+			Template:     profile.template,
+			Lineage:      lineage,
+			EgressRules:  egressRules,
 		},
 	}
-	if err := o.createSession(ctx, session); err != nil {
+	err = o.createSession(ctx, session)
+	release()
+	if err != nil {
 		return nil, err
 	}
 
@@ -383,45 +506,89 @@ func (o *Orchestrator) createSessionWithTTL(ctx context.Context, req *pb.CreateS
 		pvcName = p
 	}
 
-	pod, err := o.claimOrCreatePod(ctx, sessionID, runtimeClass, pvcName, matrixCPU, matrixMemory)
+	pod, err := o.claimOrCreatePod(ctx, sessionID, pvcName, profile)
 	if err != nil {
 		return nil, err
 	}
 
-	session.Status.Phase = sandboxv1.SandboxPhaseActive
-	session.Status.PodName = pod.Name
-	session.Status.PodIP = pod.Status.PodIP
-	session.Status.WorkspacePVC = pvcName
-	session.Status.CreatedAt = &metav1.Time{Time: now}
-	if ttl > 0 {
-		t := metav1.NewTime(now.Add(time.Duration(ttl) * time.Second))
-		session.Status.ExpiresAt = &t
+	session, err = o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhaseActive
+		st.PodName = pod.Name
+		st.PodIP = pod.Status.PodIP
+		st.WorkspacePVC = pvcName
+		st.CreatedAt = &metav1.Time{Time: now}
+		if ttl > 0 {
+			t := metav1.NewTime(now.Add(time.Duration(ttl) * time.Second))
+			st.ExpiresAt = &t
+		}
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "record session %s active: %v", sessionID, err)
 	}
-	o.updateSessionStatus(ctx, session)
 
-	return session, o.applySessionCNP(ctx, session)
+	if err := o.applySessionCNP(ctx, session); err != nil {
+		return session, err
+	}
+	if o.podStats != nil {
+		// Baseline the claimed pod's counters so the session is charged
+		// only for its own use.
+		go o.sampleSession(context.Background(), sessionID)
+	}
+	o.emitWebhook(WebhookSessionCreated, sessionID, tenant, sessionWebhookData(session))
+	return session, nil
 }
 
 func (o *Orchestrator) DestroySession(ctx context.Context, sessionID string) error {
+	return o.destroySession(ctx, sessionID, WebhookSessionDestroyed)
+}
+
+// ExpireSession destroys a session whose TTL has passed; webhooks see
+// session.expired instead of session.destroyed.
+func (o *Orchestrator) ExpireSession(ctx context.Context, sessionID string) error {
+	return o.destroySession(ctx, sessionID, WebhookSessionExpired)
+}
+
+func (o *Orchestrator) destroySession(ctx context.Context, sessionID, event string) error {
 	session, err := o.getSession(ctx, sessionID)
 	if err != nil {
 		return err
 	}
 	// mark Terminating before cleanup so observers can detect in-progress deletion
-	session.Status.Phase = sandboxv1.SandboxPhaseTerminating
-	o.updateSessionStatus(ctx, session)
+	session, err = o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhaseTerminating
+	})
+	if err != nil {
+		return err
+	}
 
 	// M11: destroy-step ledger (ephemeral-sandbox TeardownTransaction analog).
 	// Completed steps are recorded on the session so a crash-resumed destroy
 	// skips them; the ledger is observable on the CRD.
 	done := destroyStepsDone(session)
 
+	// Meter the session before its pod is reset and returned to the pool.
+	if !done["usage"] {
+		reason := "destroyed"
+		if event == WebhookSessionExpired {
+			reason = "expired"
+		}
+		o.finishUsage(ctx, sessionID, reason)
+		o.markDestroyStep(ctx, sessionID, "usage")
+	}
+
 	// 1. Delete CNP
 	if !done["cnp"] {
 		o.deleteCNP(ctx, session)
 		o.markDestroyStep(ctx, sessionID, "cnp")
 	}
 
+	// A paused session's memory checkpoint will never be restored.
+	if ck := session.Status.Checkpoint; ck != nil {
+		if c := o.getCheckpointer(); c != nil {
+			c.Discard(ctx, ck)
+		}
+	}
+
 	// 2. Find the pod by session-id label and reset its workspace
 	podIP, podName := o.findPodBySession(ctx, sessionID)
 	if podIP == "" && session.Status.PodName != "" {
@@ -443,7 +610,12 @@ func (o *Orchestrator) DestroySession(ctx context.Context, sessionID string) err
 	}
 
 	// 4. Delete Session CRD (pod and PVC survive, return to pool)
-	return o.dynamic.Resource(sessionGVR).Namespace(sandboxNS).Delete(ctx, sessionID, metav1.DeleteOptions{})
+	if err := o.dynamic.Resource(sessionGVR).Namespace(sandboxNS).Delete(ctx, sessionID, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+	o.forgetSessionRuns(sessionID)
+	o.emitWebhook(event, sessionID, sessionTenant(session), sessionWebhookData(session))
+	return nil
 }
 
 // destroyStepAnnotation records completed destroy steps (comma-separated) on
@@ -512,7 +684,7 @@ func (o *Orchestrator) resetWorkspace(ctx context.Context, podIP string) {
 	defer cancel()
 	req = req.WithContext(httpCtx)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: sandboxdTransport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return
@@ -567,25 +739,58 @@ func (o *Orchestrator) listSessions(ctx context.Context, namespace, phase string
 // background runs per session.
 const defaultMaxBackgroundRuns = 5
 
-// countBackgroundRuns returns how many run_registry entries point at sessionID.
+// countBackgroundRuns returns how many in-progress run_registry entries
+// point at sessionID.
 func (o *Orchestrator) countBackgroundRuns(sessionID string) int32 {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	var n int32
-	for _, sid := range o.runRegistry {
-		if sid == sessionID {
+	for runID, sid := range o.runRegistry {
+		if sid == sessionID && !o.runsExited[runID] {
 			n++
 		}
 	}
 	return n
 }
 
-// countAllBackgroundRuns returns the total number of registered background runs
+// countAllBackgroundRuns returns the number of in-progress background runs
 // across all sessions (used by the Prometheus gauge).
 func (o *Orchestrator) countAllBackgroundRuns() int32 {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	return int32(len(o.runRegistry))
+	return int32(len(o.runRegistry) - len(o.runsExited))
+}
+
+// markRunExited stops counting runID once PollRun has seen it finish, and
+// records the exit on the session so every replica's quota stops counting it.
+func (o *Orchestrator) markRunExited(ctx context.Context, runID string) {
+	o.mu.Lock()
+	sessionID, ok := o.runRegistry[runID]
+	first := ok && !o.runsExited[runID]
+	if ok {
+		o.runsExited[runID] = true
+	}
+	o.mu.Unlock()
+	if !first {
+		return
+	}
+	if err := o.annotateSession(ctx, sessionID, func(a map[string]string) {
+		a[backgroundRunsExitedAnnotation] = appendRunID(a[backgroundRunsExitedAnnotation], runID)
+	}); err != nil {
+		logrus.Debugf("sandbox run %s: record exit: %v", runID, err)
+	}
+}
+
+// forgetSessionRuns drops a destroyed session's runs from the registry.
+func (o *Orchestrator) forgetSessionRuns(sessionID string) {
+	o.mu.Lock()
+	for runID, sid := range o.runRegistry {
+		if sid == sessionID {
+			delete(o.runRegistry, runID)
+			delete(o.runsExited, runID)
+		}
+	}
+	o.mu.Unlock()
 }
 
 func (o *Orchestrator) RunSubAgent(ctx context.Context, req *pb.RunSubAgentRequest) (*pb.RunSubAgentResponse, error) {
@@ -619,6 +824,8 @@ func (o *Orchestrator) RunSubAgent(ctx context.Context, req *pb.RunSubAgentReque
 			Depth:           parent.Spec.Depth + 1,
 			Env:             parent.Spec.Env,
 			SecretRefs:      append([]sandboxv1.SecretRef(nil), parent.Spec.SecretRefs...),
+			Template:        parent.Spec.Template,
+			EgressRules:     parent.Spec.EgressRules,
 		},
 	}
 	if err := o.createSession(ctx, child); err != nil {
@@ -630,88 +837,18 @@ func (o *Orchestrator) RunSubAgent(ctx context.Context, req *pb.RunSubAgentReque
 	// inherits the parent's PodIP for exec routing but deliberately has NO own
 	// PodName and NO own CNP — so DestroySession/GC only delete the child CRD
 	// and never reset the shared pod's workspace or release it back to the pool.
-	child.Status.Phase = sandboxv1.SandboxPhaseActive
-	child.Status.PodIP = parent.Status.PodIP
-	child.Status.WorkspacePVC = parentPVC
-	child.Status.CreatedAt = &metav1.Time{Time: time.Now()}
-	o.updateSessionStatus(ctx, child)
-
-	return &pb.RunSubAgentResponse{SessionId: childID}, nil
-}
-
-func (o *Orchestrator) ConfirmAction(ctx context.Context, req *pb.ConfirmActionRequest) (*pb.ConfirmActionResponse, error) {
-	if req.ApprovalId != "" {
-		o.mu.Lock()
-		pa, ok := o.approvals[req.ApprovalId]
-		o.mu.Unlock()
-		if !ok {
-			return nil, status.Errorf(codes.NotFound, "approval %s not found", req.ApprovalId)
-		}
-		select {
-		case approved := <-pa.approved:
-			o.mu.Lock()
-			delete(o.approvals, req.ApprovalId)
-			o.mu.Unlock()
-			return &pb.ConfirmActionResponse{ApprovalId: req.ApprovalId, Approved: approved}, nil
-		case <-ctx.Done():
-			o.mu.Lock()
-			delete(o.approvals, req.ApprovalId)
-			o.mu.Unlock()
-			return nil, status.Errorf(codes.Canceled, "cancelled")
-		case <-time.After(approvalTTL):
-			o.mu.Lock()
-			delete(o.approvals, req.ApprovalId)
-			o.mu.Unlock()
-			return nil, status.Errorf(codes.DeadlineExceeded, "approval timed out after %v", approvalTTL)
-		}
-	}
-
-	approvalID := fmt.Sprintf("approval-%s-%d", req.SessionId, time.Now().UnixNano())
-	o.mu.Lock()
-	o.approvals[approvalID] = &pendingApproval{action: req.Action, approved: make(chan bool, 1), createdAt: time.Now()}
-	o.mu.Unlock()
-	return &pb.ConfirmActionResponse{ApprovalId: approvalID, Approved: false}, nil
-}
-
-func (o *Orchestrator) Approve(approvalID string, approved bool) error {
-	o.mu.Lock()
-	pa, ok := o.approvals[approvalID]
-	o.mu.Unlock()
-	if !ok {
-		return fmt.Errorf("approval %s not found", approvalID)
+	child, err = o.updateSessionStatus(ctx, childID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhaseActive
+		st.PodIP = parent.Status.PodIP
+		st.WorkspacePVC = parentPVC
+		st.CreatedAt = &metav1.Time{Time: time.Now()}
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "record sub-agent %s active: %v", childID, err)
 	}
-	pa.approved <- approved
-	return nil
-}
+	o.emitWebhook(WebhookSessionCreated, childID, sessionTenant(child), sessionWebhookData(child))
 
-// ApproveAction resolves a pending approval (external approval via gRPC).
-func (o *Orchestrator) ApproveAction(ctx context.Context, req *pb.ApproveActionRequest) (*pb.ApproveActionResponse, error) {
-	if err := o.Approve(req.ApprovalId, req.Approved); err != nil {
-		return nil, status.Errorf(codes.NotFound, "approval %s: %v", req.ApprovalId, err)
-	}
-	return &pb.ApproveActionResponse{Ok: true}, nil
-}
-
-// StartApprovalGC periodically cleans expired pending approvals from disconnected clients.
-func (o *Orchestrator) StartApprovalGC(ctx context.Context) {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			o.mu.Lock()
-			cutoff := time.Now().Add(-approvalTTL)
-			for id, pa := range o.approvals {
-				if pa.createdAt.Before(cutoff) {
-					close(pa.approved)
-					delete(o.approvals, id)
-				}
-			}
-			o.mu.Unlock()
-		}
-	}
+	return &pb.RunSubAgentResponse{SessionId: childID}, nil
 }
 
 // ExecBackground submits a background command to the sandboxd and registers the run_id.
@@ -722,7 +859,16 @@ func (o *Orchestrator) ExecBackground(ctx context.Context, sessionID, command st
 			"background run limit reached (%d active runs); poll or wait for completion before submitting more",
 			o.maxBackgroundRuns)
 	}
-	podIP, err := o.getPodIPBySession(ctx, sessionID)
+	session, err := o.getSession(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	release, err := o.admitBackgroundRun(ctx, session)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	podIP, err := o.sessionPodIP(ctx, session)
 	if err != nil {
 		return "", err
 	}
@@ -747,10 +893,15 @@ func (o *Orchestrator) ExecBackground(ctx context.Context, sessionID, command st
 	o.mu.Lock()
 	o.runRegistry[runID] = sessionID
 	o.mu.Unlock()
+	o.watch.trackRun(runID)
 
 	// M6: persist the run_id on the session CRD so a gateway restart can
 	// rebuild the registry (ephemeral-sandbox recover_sandboxes analog).
-	o.recordRunOnSession(ctx, sessionID, runID)
+	if err := o.recordRunOnSession(ctx, sessionID, runID); err != nil {
+		logrus.Warnf("sandbox run %s: record on session: %v", runID, err)
+	}
+	// Wall time is metered when the run is seen finished (notifyRunCompleted).
+	o.recordExec(sessionID, 1, 0)
 
 	return runID, nil
 }
@@ -777,8 +928,20 @@ func (o *Orchestrator) PollRun(ctx context.Context, runID string) (*pb.PollRunRe
 	}
 	defer resp.Body.Close()
 
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("sandboxd poll: %w", err)
+	}
 	var result pb.PollRunResponse
-	json.NewDecoder(resp.Body).Decode(&result)
+	json.Unmarshal(data, &result)
+	// Output past sandboxd's poll read cap stays in the run directory.
+	var sizes runOutputSizes
+	json.Unmarshal(data, &sizes)
+	result.OutputRef = sizes.outputRef(runID)
+	if result.Status != "" && result.Status != execStatusStarted && result.Status != execStatusRunning {
+		o.markRunExited(ctx, runID)
+	}
+	o.notifyRunCompleted(sessionID, runID, &result)
 	return &result, nil
 }
 
@@ -787,22 +950,73 @@ func (o *Orchestrator) PollRun(ctx context.Context, runID string) (*pb.PollRunRe
 // restart (KIP-16 M6 / ephemeral-sandbox recover_sandboxes analog).
 const backgroundRunAnnotation = "sandbox.k8e.io/background-runs"
 
+// backgroundRunsExitedAnnotation lists the run_ids of backgroundRunAnnotation
+// some replica has seen finish. Tenant quotas count the difference, so a run
+// is counted the same whichever replica submitted or polled it.
+const backgroundRunsExitedAnnotation = "sandbox.k8e.io/background-runs-exited"
+
 // recordRunOnSession appends a run_id to the session CRD annotation so the
-// registry survives gateway restarts.
-func (o *Orchestrator) recordRunOnSession(ctx context.Context, sessionID, runID string) {
-	session, err := o.getSession(ctx, sessionID)
-	if err != nil {
-		return
+// registry survives gateway restarts and quotas see the run.
+func (o *Orchestrator) recordRunOnSession(ctx context.Context, sessionID, runID string) error {
+	return o.annotateSession(ctx, sessionID, func(a map[string]string) {
+		a[backgroundRunAnnotation] = appendRunID(a[backgroundRunAnnotation], runID)
+	})
+}
+
+// annotateSession applies fn to the session's annotations and writes the
+// session back, re-reading on conflicts.
+func (o *Orchestrator) annotateSession(ctx context.Context, sessionID string, fn func(map[string]string)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := o.getSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if s.Annotations == nil {
+			s.Annotations = map[string]string{}
+		}
+		fn(s.Annotations)
+		u, err := sessionToUnstructured(s)
+		if err != nil {
+			return err
+		}
+		_, err = o.dynamic.Resource(sessionGVR).Namespace(s.Namespace).Update(ctx, u, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// runIDs splits a run_id list annotation.
+func runIDs(list string) []string {
+	var ids []string
+	for _, id := range strings.Split(list, ",") {
+		if id != "" {
+			ids = append(ids, id)
+		}
 	}
-	if session.Annotations == nil {
-		session.Annotations = map[string]string{}
+	return ids
+}
+
+// appendRunID adds runID to a run_id list annotation unless already there.
+func appendRunID(list, runID string) string {
+	if containsString(runIDs(list), runID) {
+		return list
 	}
-	cur := session.Annotations[backgroundRunAnnotation]
-	if cur != "" {
-		cur += ","
+	if list != "" {
+		list += ","
 	}
-	session.Annotations[backgroundRunAnnotation] = cur + runID
-	o.updateSession(ctx, session)
+	return list + runID
+}
+
+// liveRuns is the number of the session's registered runs no replica has
+// seen finish.
+func liveRuns(s *sandboxv1.SandboxSession) int {
+	exited := runIDs(s.Annotations[backgroundRunsExitedAnnotation])
+	n := 0
+	for _, id := range runIDs(s.Annotations[backgroundRunAnnotation]) {
+		if !containsString(exited, id) {
+			n++
+		}
+	}
+	return n
 }
 
 // RebuildRunRegistry scans Session CRDs and rebuilds the run_registry on startup
@@ -817,14 +1031,16 @@ func (o *Orchestrator) RebuildRunRegistry(ctx context.Context, namespace string)
 	for _, s := range sessions.Items {
 		sessionID := s.GetName()
 		annos := s.GetAnnotations()
-		for _, runID := range strings.Split(annos[backgroundRunAnnotation], ",") {
-			if runID == "" {
-				continue
-			}
+		for _, runID := range runIDs(annos[backgroundRunAnnotation]) {
 			if _, exists := o.runRegistry[runID]; !exists {
 				o.runRegistry[runID] = sessionID
 			}
 		}
+		for _, runID := range runIDs(annos[backgroundRunsExitedAnnotation]) {
+			if _, exists := o.runRegistry[runID]; exists {
+				o.runsExited[runID] = true
+			}
+		}
 	}
 }
 
@@ -834,6 +1050,12 @@ func (o *Orchestrator) getPodIPBySession(ctx context.Context, sessionID string)
 	if err != nil {
 		return "", err
 	}
+	return o.sessionPodIP(ctx, session)
+}
+
+// sessionPodIP is getPodIPBySession for a session the caller already holds.
+func (o *Orchestrator) sessionPodIP(ctx context.Context, session *sandboxv1.SandboxSession) (string, error) {
+	sessionID := session.Name
 	if session.Status.PodIP != "" {
 		return session.Status.PodIP, nil
 	}
@@ -847,7 +1069,7 @@ func (o *Orchestrator) getPodIPBySession(ctx context.Context, sessionID string)
 	return pods.Items[0].Status.PodIP, nil
 }
 
-var bgSandboxdClient = &http.Client{Timeout: 10 * time.Second}
+var bgSandboxdClient = &http.Client{Timeout: 10 * time.Second, Transport: sandboxdTransport}
 
 // --- internal helpers ---
 
@@ -868,16 +1090,35 @@ func (o *Orchestrator) createSession(ctx context.Context, session *sandboxv1.San
 	return err
 }
 
-func (o *Orchestrator) updateSessionStatus(ctx context.Context, session *sandboxv1.SandboxSession) {
-	u, err := sessionToUnstructured(session)
-	if err != nil {
-		return
-	}
-	o.dynamic.Resource(sessionGVR).Namespace(sandboxNS).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+// updateSessionStatus applies fn to the session's status and writes it,
+// re-reading on conflicts: usage sampling and exposes update the status
+// concurrently, so a write from a stale copy would be rejected.
+func (o *Orchestrator) updateSessionStatus(ctx context.Context, sessionID string, fn func(*sandboxv1.SandboxSessionStatus)) (*sandboxv1.SandboxSession, error) {
+	var session *sandboxv1.SandboxSession
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := o.getSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		fn(&s.Status)
+		u, err := sessionToUnstructured(s)
+		if err != nil {
+			return err
+		}
+		_, err = o.dynamic.Resource(sessionGVR).Namespace(sandboxNS).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+		session = s
+		return err
+	})
+	return session, err
 }
 
-func (o *Orchestrator) claimOrCreatePod(ctx context.Context, sessionID, runtimeClass, pvcName, cpu, memory string) (*corev1.Pod, error) {
+func (o *Orchestrator) claimOrCreatePod(ctx context.Context, sessionID, pvcName string, profile podProfile) (*corev1.Pod, error) {
+	runtimeClass := profile.runtimeClass
 	start := time.Now()
+	ctx, span := tracing.Start(ctx, "sandbox.claimOrCreatePod",
+		attribute.String("sandbox.session_id", sessionID),
+		attribute.String("sandbox.runtime_class", runtimeClass),
+		attribute.String("sandbox.template", profile.template))
 	// Only ephemeral sessions (no PVC) may adopt a warm pod: warm pods boot with an
 	// EmptyDir volume, and a running pod's volumes cannot be changed to mount a
 	// session PVC. Persistent sessions therefore cold-start with the PVC attached.
@@ -901,12 +1142,20 @@ func (o *Orchestrator) claimOrCreatePod(ctx context.Context, sessionID, runtimeC
 				if rc := pod.Labels[labelRuntimeClass]; rc != "" && rc != runtimeClass {
 					continue
 				}
+				// A warm pod must come from a pool with the same template (its
+				// image and limits are fixed at boot). Untemplated sessions only
+				// adopt untemplated pods.
+				if pod.Labels[LabelTemplate] != profile.template {
+					continue
+				}
 				// atomic claim: use resourceVersion for optimistic locking
 				pod.Labels[labelState] = stateActive
 				pod.Labels[labelSessionID] = sessionID
 				updated, uerr := o.k8s.CoreV1().Pods(sandboxNS).Update(ctx, pod, metav1.UpdateOptions{})
 				if uerr == nil {
 					o.recordClaim(start, true)
+					span.SetAttributes(attribute.String("sandbox.claim_source", "warm"))
+					tracing.End(span, nil)
 					// Pool just shrank by one: ask the controller to refill now.
 					if o.OnWarmClaim != nil {
 						o.OnWarmClaim()
@@ -917,6 +1166,18 @@ func (o *Orchestrator) claimOrCreatePod(ctx context.Context, sessionID, runtimeC
 			}
 		}
 	}
+	created, cerr := o.k8s.CoreV1().Pods(sandboxNS).Create(ctx, newSessionPod(sessionID, pvcName, profile), metav1.CreateOptions{})
+	if cerr == nil {
+		o.recordClaim(start, false)
+	}
+	span.SetAttributes(attribute.String("sandbox.claim_source", "cold"))
+	tracing.End(span, cerr)
+	return created, cerr
+}
+
+// newSessionPod builds the cold-start pod for a session.
+func newSessionPod(sessionID, pvcName string, profile podProfile) *corev1.Pod {
+	runtimeClass := profile.runtimeClass
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("sandbox-%s", sessionID),
@@ -928,28 +1189,36 @@ func (o *Orchestrator) claimOrCreatePod(ctx context.Context, sessionID, runtimeC
 			},
 			Annotations: GvisorAnnotations(runtimeClass),
 		},
-		Spec: sandboxPodSpec(runtimeClass, pvcName, cpu, memory),
+		Spec: SandboxPodSpec(runtimeClass, pvcName, profile.cpu, profile.memory, profile.image),
 	}
-	created, cerr := o.k8s.CoreV1().Pods(sandboxNS).Create(ctx, pod, metav1.CreateOptions{})
-	if cerr == nil {
-		o.recordClaim(start, false)
+	if profile.template != "" {
+		pod.Labels[LabelTemplate] = profile.template
 	}
-	return created, cerr
+	StampSandboxdAuth(pod)
+	return pod
 }
 
-func sandboxPodSpec(runtimeClass, pvcName, cpu, memory string) corev1.PodSpec {
-	return SandboxPodSpec(runtimeClass, pvcName, cpu, memory, sandboxImage)
-}
+// Pod limits when neither the template nor the SandboxMatrix sets them.
+const (
+	defaultPodCPU    = "500m"
+	defaultPodMemory = "512Mi"
+)
 
-// SandboxPodSpec builds a PodSpec for a sandbox session. Exported for use by the controller.
-// Set pvcName to empty string to use an EmptyDir volume instead of a PVC.
-func SandboxPodSpec(runtimeClass, pvcName, cpu, memory, image string) corev1.PodSpec {
+// podLimits applies the default pod limits to unset values.
+func podLimits(cpu, memory string) (string, string) {
 	if cpu == "" {
-		cpu = "500m"
+		cpu = defaultPodCPU
 	}
 	if memory == "" {
-		memory = "512Mi"
+		memory = defaultPodMemory
 	}
+	return cpu, memory
+}
+
+// SandboxPodSpec builds a PodSpec for a sandbox session. Exported for use by the controller.
+// Set pvcName to empty string to use an EmptyDir volume instead of a PVC.
+func SandboxPodSpec(runtimeClass, pvcName, cpu, memory, image string) corev1.PodSpec {
+	cpu, memory = podLimits(cpu, memory)
 	vol := corev1.Volume{Name: "workspace"}
 	if pvcName != "" {
 		vol.VolumeSource = corev1.VolumeSource{
@@ -996,6 +1265,9 @@ func SandboxPodSpec(runtimeClass, pvcName, cpu, memory, image string) corev1.Pod
 		DNSPolicy:     corev1.DNSDefault,
 		RestartPolicy: corev1.RestartPolicyNever,
 	}
+	if port := egressProxyPort.Load(); port > 0 {
+		withEgressProxy(&spec, int(port))
+	}
 	if runtimeClass != "" {
 		spec.RuntimeClassName = &runtimeClass
 	}
@@ -1015,6 +1287,14 @@ func GvisorAnnotations(runtimeClass string) map[string]string {
 	return nil
 }
 
+// PauseResult reports what a pause preserved.
+type PauseResult struct {
+	Mode pb.PauseMode
+	// Fallback says why a PAUSE_MODE_MEMORY request paused the filesystem
+	// only.
+	Fallback string
+}
+
 // PauseSession releases a sandbox's pod (CPU/memory) while keeping the
 // workspace PVC and the Session CRD, so a later ResumeSession comes back
 // with the filesystem intact — E2B pause semantics (KIP-18). Only
@@ -1022,18 +1302,21 @@ func GvisorAnnotations(runtimeClass string) map[string]string {
 // session's EmptyDir volume dies with the pod, so pausing it would silently
 // lose the workspace — the honest refusal matches CubeSandbox's "delete a
 // paused sandbox does not wake it" and Dormice's pause memory:false
-// (filesystem only) semantics.
-func (o *Orchestrator) PauseSession(ctx context.Context, sessionID string) error {
+// (filesystem only) semantics. PAUSE_MODE_MEMORY additionally checkpoints
+// the processes (Dormice's memory:true) when a Checkpointer supports the
+// pod's runtime class, and falls back to the filesystem pause otherwise.
+func (o *Orchestrator) PauseSession(ctx context.Context, sessionID string, mode pb.PauseMode) (PauseResult, error) {
+	result := PauseResult{Mode: pb.PauseMode_PAUSE_MODE_FILESYSTEM}
 	session, err := o.getSession(ctx, sessionID)
 	if err != nil {
-		return err
+		return result, err
 	}
 	if session.Status.Phase != sandboxv1.SandboxPhaseActive {
-		return status.Errorf(codes.FailedPrecondition,
+		return result, status.Errorf(codes.FailedPrecondition,
 			"session %s is not active (phase=%s)", sessionID, session.Status.Phase)
 	}
 	if session.Spec.TenantID == "" && session.Status.WorkspacePVC == "" {
-		return status.Errorf(codes.FailedPrecondition,
+		return result, status.Errorf(codes.FailedPrecondition,
 			"session %s is ephemeral (no workspace PVC); pause requires a persistent session", sessionID)
 	}
 	// Ensure a PVC exists so resume can attach the same workspace.
@@ -1041,7 +1324,7 @@ func (o *Orchestrator) PauseSession(ctx context.Context, sessionID string) error
 	if pvcName == "" {
 		pvcName, err = o.ensureWorkspacePVC(ctx, sessionID)
 		if err != nil {
-			return status.Errorf(codes.Internal, "pause: ensure workspace PVC: %v", err)
+			return result, status.Errorf(codes.Internal, "pause: ensure workspace PVC: %v", err)
 		}
 	}
 
@@ -1054,27 +1337,76 @@ func (o *Orchestrator) PauseSession(ctx context.Context, sessionID string) error
 			podName = pod.Name
 		}
 	}
+	var ck *sandboxv1.SessionCheckpoint
+	if mode == pb.PauseMode_PAUSE_MODE_MEMORY {
+		ck, result.Fallback = o.checkpointSession(ctx, session, podName)
+		if ck != nil {
+			result.Mode = pb.PauseMode_PAUSE_MODE_MEMORY
+		}
+	}
 	if podName != "" {
 		// Best-effort workspace flush would belong here (none exists yet);
 		// the PVC persists regardless.
 		if derr := o.k8s.CoreV1().Pods(sandboxNS).Delete(ctx, podName, metav1.DeleteOptions{}); derr != nil && !errors.IsNotFound(derr) {
-			return status.Errorf(codes.Internal, "pause: delete pod %s: %v", podName, derr)
+			if ck != nil {
+				o.getCheckpointer().Discard(ctx, ck)
+			}
+			return result, status.Errorf(codes.Internal, "pause: delete pod %s: %v", podName, derr)
 		}
 	}
 
-	session.Status.Phase = sandboxv1.SandboxPhasePaused
-	session.Status.PodName = ""
-	session.Status.PodIP = ""
-	session.Status.WorkspacePVC = pvcName
-	o.updateSessionStatus(ctx, session)
+	session, err = o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhasePaused
+		st.PodName = ""
+		st.PodIP = ""
+		st.WorkspacePVC = pvcName
+		st.Checkpoint = ck
+	})
+	if err != nil {
+		// Unrecorded, the checkpoint could never be restored or discarded.
+		if ck != nil {
+			o.getCheckpointer().Discard(ctx, ck)
+		}
+		return result, status.Errorf(codes.Internal, "pause: record paused state: %v", err)
+	}
 	// Delete the session CNP so the paused sandbox exposes no ports.
 	o.deleteCNP(ctx, session)
-	return nil
+	return result, nil
+}
+
+// checkpointSession saves the session pod's memory, or says why it cannot.
+func (o *Orchestrator) checkpointSession(ctx context.Context, session *sandboxv1.SandboxSession, podName string) (*sandboxv1.SessionCheckpoint, string) {
+	c := o.getCheckpointer()
+	if c == nil {
+		return nil, "memory checkpoints are not enabled on this server"
+	}
+	if podName == "" {
+		return nil, "session has no running pod"
+	}
+	pod, err := o.k8s.CoreV1().Pods(sandboxNS).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Sprintf("get pod %s: %v", podName, err)
+	}
+	rc := ""
+	if pod.Spec.RuntimeClassName != nil {
+		rc = *pod.Spec.RuntimeClassName
+	}
+	if !c.Supports(rc) {
+		return nil, fmt.Sprintf("runtime class %q does not support checkpoints", rc)
+	}
+	ck, err := c.Checkpoint(ctx, pod, sessionTenant(session))
+	if err != nil {
+		return nil, fmt.Sprintf("checkpoint failed: %v", err)
+	}
+	return ck, ""
 }
 
 // ResumeSession re-creates a paused sandbox's pod with its workspace PVC
-// attached (cold boot on resume — Dormice's pause memory:false semantics:
-// filesystem only). Files survive; in-memory state does not.
+// attached. After a filesystem pause it cold-boots (Dormice's pause
+// memory:false semantics): files survive, in-memory state does not. After a
+// memory pause the pod is restored from the checkpoint and carries
+// CheckpointRestoredAnnotation; when restoring cannot start it cold-boots
+// instead. The checkpoint is discarded either way.
 func (o *Orchestrator) ResumeSession(ctx context.Context, sessionID string) (*corev1.Pod, error) {
 	session, err := o.getSession(ctx, sessionID)
 	if err != nil {
@@ -1091,23 +1423,62 @@ func (o *Orchestrator) ResumeSession(ctx context.Context, sessionID string) (*co
 
 	// Re-create the pod with the same PVC. A persistent session always
 	// cold-starts (warm pods boot with EmptyDir and cannot swap volumes).
+	// The pod is rebuilt from the session's template so the resumed sandbox
+	// runs the same image and limits it was created with.
+	tmpl, err := o.sessionTemplate(ctx, session.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
 	matrixCPU, matrixMemory := o.matrixResourceDefaults(ctx)
-	pod, perr := o.claimOrCreatePod(ctx, sessionID, session.Spec.RuntimeClass,
-		session.Status.WorkspacePVC, matrixCPU, matrixMemory)
+	profile := newPodProfile(tmpl, session.Spec.RuntimeClass, matrixCPU, matrixMemory)
+	var pod *corev1.Pod
+	var perr error
+	if ck := session.Status.Checkpoint; ck != nil {
+		pod = o.restoreCheckpoint(ctx, sessionID, session.Status.WorkspacePVC, profile, ck)
+	}
+	if pod == nil {
+		pod, perr = o.claimOrCreatePod(ctx, sessionID, session.Status.WorkspacePVC, profile)
+	}
 	if perr != nil {
 		return nil, status.Errorf(codes.Internal, "resume: create pod: %v", perr)
 	}
 
-	session.Status.Phase = sandboxv1.SandboxPhaseActive
-	session.Status.PodName = pod.Name
-	session.Status.PodIP = pod.Status.PodIP
-	o.updateSessionStatus(ctx, session)
+	session, err = o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhaseActive
+		st.PodName = pod.Name
+		st.PodIP = pod.Status.PodIP
+		st.Checkpoint = nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "resume: record active state: %v", err)
+	}
 	if err := o.applySessionCNP(ctx, session); err != nil {
 		return nil, status.Errorf(codes.Internal, "resume: apply network policy: %v", err)
 	}
 	return pod, nil
 }
 
+// restoreCheckpoint creates the session pod restored from ck and discards
+// ck. It returns nil when the pod should cold-boot instead.
+func (o *Orchestrator) restoreCheckpoint(ctx context.Context, sessionID, pvcName string, profile podProfile, ck *sandboxv1.SessionCheckpoint) *corev1.Pod {
+	c := o.getCheckpointer()
+	if c == nil {
+		return nil
+	}
+	defer c.Discard(ctx, ck)
+	start := time.Now()
+	pod := newSessionPod(sessionID, pvcName, profile)
+	if err := c.Restore(ctx, pod, ck); err != nil {
+		return nil
+	}
+	created, err := o.k8s.CoreV1().Pods(sandboxNS).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil
+	}
+	o.recordClaim(start, false)
+	return created
+}
+
 // matrixResourceDefaults reads CPU/memory defaults from the SandboxMatrix CRD.
 func (o *Orchestrator) matrixResourceDefaults(ctx context.Context) (cpu, memory string) {
 	list, err := o.dynamic.Resource(matrixGVR).Namespace(sandboxNS).List(ctx, metav1.ListOptions{})
@@ -1166,7 +1537,7 @@ func buildSessionCNP(session *sandboxv1.SandboxSession, fqdnEnabled bool) *unstr
 func buildSessionCNPExposed(session *sandboxv1.SandboxSession, fqdnEnabled bool, exposedPorts []int32) *unstructured.Unstructured {
 	// Egress: DNS (53) always to world. HTTPS (443) is either blanket world
 	// (default) or scoped toFQDNs when FQDN egress is enabled AND the session
-	// declares allowedHosts (KIP-16 M10 / issue #510).
+	// declares allowedHosts or egressRules (KIP-16 M10 / issue #510).
 	egress := []interface{}{
 		map[string]interface{}{
 			"toEntities": []interface{}{"world"},
@@ -1177,7 +1548,8 @@ func buildSessionCNPExposed(session *sandboxv1.SandboxSession, fqdnEnabled bool,
 			},
 		},
 	}
-	if fqdnEnabled && len(session.Spec.AllowedHosts) > 0 {
+	scoped := fqdnEnabled && (len(session.Spec.AllowedHosts) > 0 || len(session.Spec.EgressRules) > 0)
+	if scoped && len(session.Spec.AllowedHosts) > 0 {
 		// Scoped egress: only the declared hosts, via Cilium FQDN rules.
 		fqdns := make([]interface{}, 0, len(session.Spec.AllowedHosts))
 		for _, h := range session.Spec.AllowedHosts {
@@ -1191,7 +1563,7 @@ func buildSessionCNPExposed(session *sandboxv1.SandboxSession, fqdnEnabled bool,
 				},
 			},
 		})
-	} else {
+	} else if !scoped {
 		egress = append(egress, map[string]interface{}{
 			"toEntities": []interface{}{"world"},
 			"toPorts": []interface{}{
@@ -1201,6 +1573,10 @@ func buildSessionCNPExposed(session *sandboxv1.SandboxSession, fqdnEnabled bool,
 			},
 		})
 	}
+	// Structured rules: wildcard hosts, CIDRs, other ports and L7 HTTP.
+	for _, r := range session.Spec.EgressRules {
+		egress = append(egress, egressRuleCNP(r, fqdnEnabled))
+	}
 	ingress := []interface{}{
 		map[string]interface{}{
 			"fromEntities": []interface{}{"host"},
@@ -1291,6 +1667,10 @@ func buildSessionCNPExposed(session *sandboxv1.SandboxSession, fqdnEnabled bool,
 
 func (o *Orchestrator) deleteCNP(ctx context.Context, session *sandboxv1.SandboxSession) {
 	name := fmt.Sprintf("sandbox-session-%s", session.Name)
+	if egressProxyEnabled() {
+		o.k8s.NetworkingV1().NetworkPolicies(session.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		return
+	}
 	o.dynamic.Resource(cnpGVR).Namespace(session.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
 }
 
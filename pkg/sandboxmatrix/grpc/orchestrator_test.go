@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -12,12 +13,14 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	dynfake "k8s.io/client-go/dynamic/fake"
 	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
@@ -36,6 +39,10 @@ func newTestOrchestrator() *Orchestrator {
 	for _, gvk := range []schema.GroupVersionKind{
 		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxSession"},
 		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxMatrix"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxTemplate"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxTenantQuota"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxApproval"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxWebhook"},
 		{Group: testGroupCilium, Version: "v2", Kind: "CiliumNetworkPolicy"},
 	} {
 		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
@@ -43,15 +50,23 @@ func newTestOrchestrator() *Orchestrator {
 	for _, gvk := range []schema.GroupVersionKind{
 		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxSessionList"},
 		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxMatrixList"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxTemplateList"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxTenantQuotaList"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxApprovalList"},
+		{Group: testGroupK8e, Version: "v1alpha1", Kind: "SandboxWebhookList"},
 		{Group: testGroupCilium, Version: "v2", Kind: "CiliumNetworkPolicyList"},
 	} {
 		scheme.AddKnownTypeWithName(gvk, &unstructured.UnstructuredList{})
 	}
 	// explicit resource→listKind mapping to avoid fake client pluralisation bugs
 	listKinds := map[schema.GroupVersionResource]string{
-		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxsessions"}:    "SandboxSessionList",
-		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxmatrices"}:    "SandboxMatrixList",
-		{Group: testGroupCilium, Version: "v2", Resource: "ciliumnetworkpolicies"}: "CiliumNetworkPolicyList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxsessions"}:     "SandboxSessionList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxmatrices"}:     "SandboxMatrixList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxtemplates"}:    "SandboxTemplateList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxtenantquotas"}: "SandboxTenantQuotaList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxapprovals"}:    "SandboxApprovalList",
+		{Group: testGroupK8e, Version: "v1alpha1", Resource: "sandboxwebhooks"}:     "SandboxWebhookList",
+		{Group: testGroupCilium, Version: "v2", Resource: "ciliumnetworkpolicies"}:  "CiliumNetworkPolicyList",
 	}
 	dyn := dynfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds)
 	k8s := kubefake.NewSimpleClientset()
@@ -639,7 +654,7 @@ func TestConfirmAction_RegisterAndApprove(t *testing.T) {
 	}
 
 	// approve externally
-	go o.Approve(resp.ApprovalId, true) //nolint:errcheck
+	go o.Approve(ctx, resp.ApprovalId, true, "tester", "") //nolint:errcheck
 
 	// poll
 	poll, err := o.ConfirmAction(ctx, &pb.ConfirmActionRequest{
@@ -705,7 +720,7 @@ func TestReadyHandshake_StatusReady(t *testing.T) {
 	}))
 	defer srv.Close()
 	hostPort := strings.TrimPrefix(srv.URL, "http://")
-	if !readyHandshake(context.Background(), hostPort) {
+	if !readyHandshake(context.Background(), hostPort, nil) {
 		t.Fatal("expected ready handshake to pass for status=ready")
 	}
 }
@@ -718,7 +733,7 @@ func TestReadyHandshake_NotReady(t *testing.T) {
 	}))
 	defer srv.Close()
 	hostPort := strings.TrimPrefix(srv.URL, "http://")
-	if readyHandshake(context.Background(), hostPort) {
+	if readyHandshake(context.Background(), hostPort, nil) {
 		t.Fatal("expected handshake to fail for status=initializing")
 	}
 }
@@ -730,7 +745,7 @@ func TestReadyHandshake_ErrorStatus(t *testing.T) {
 	}))
 	defer srv.Close()
 	hostPort := strings.TrimPrefix(srv.URL, "http://")
-	if readyHandshake(context.Background(), hostPort) {
+	if readyHandshake(context.Background(), hostPort, nil) {
 		t.Fatal("expected handshake to fail on non-200")
 	}
 }
@@ -738,7 +753,7 @@ func TestReadyHandshake_ErrorStatus(t *testing.T) {
 // TestReadyHandshake_Unreachable verifies a dead endpoint fails fast and that
 // the TCP fallback still accepts a reachable-but-stale port.
 func TestReadyHandshake_Unreachable(t *testing.T) {
-	if readyHandshake(context.Background(), "127.0.0.1:1") {
+	if readyHandshake(context.Background(), "127.0.0.1:1", nil) {
 		t.Fatal("expected handshake to fail for unreachable endpoint")
 	}
 }
@@ -770,7 +785,7 @@ func TestDefaultWarmPodHealthCheck_ReadyHandshakePreferred(t *testing.T) {
 	// The port is open (bare TCP dial would succeed), but /ready never answers
 	// with status ready, so the pod must not be considered healthy. We exercise
 	// readyHandshake directly against the listener: it will time out / fail.
-	if readyHandshake(context.Background(), hostPort) {
+	if readyHandshake(context.Background(), hostPort, nil) {
 		t.Fatal("expected handshake to fail for TCP-only listener")
 	}
 	_ = pod
@@ -1124,7 +1139,7 @@ func TestPauseSessionReleasesPodKeepsPVC(t *testing.T) {
 		t.Fatal("session should have a pod")
 	}
 
-	if err := o.PauseSession(ctx, sess.Name); err != nil {
+	if _, err := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM); err != nil {
 		t.Fatalf("pause: %v", err)
 	}
 
@@ -1147,6 +1162,56 @@ func TestPauseSessionReleasesPodKeepsPVC(t *testing.T) {
 	}
 }
 
+func TestPauseSession_StatusWriteSurvivesConcurrentUpdate(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	sess, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "sess-pause-race", TenantId: "tenant-a"})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	dyn := o.dynamic.(*dynfake.FakeDynamicClient)
+	raced := false
+	dyn.PrependReactor("update", "sandboxsessions", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		if a.GetSubresource() != "status" || raced {
+			return false, nil, nil
+		}
+		raced = true
+		// A usage sample lands between the pause's read and its write.
+		obj, err := dyn.Tracker().Get(sessionGVR, sandboxNS, sess.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		u := obj.(*unstructured.Unstructured)
+		unstructured.SetNestedField(u.Object, int64(42), "status", "usage", "cpuNanoSeconds") //nolint:errcheck
+		if err := dyn.Tracker().Update(sessionGVR, u, sandboxNS); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(sessionGVR.GroupResource(), sess.Name, errors.New("object has been modified"))
+	})
+
+	if _, err := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	after, err := o.getSession(ctx, sess.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Status.Phase != sandboxv1.SandboxPhasePaused || after.Status.PodName != "" {
+		t.Fatalf("pause lost to the concurrent write: %+v", after.Status)
+	}
+	if after.Status.Usage == nil || after.Status.Usage.CPUNanoSeconds != 42 {
+		t.Fatalf("pause overwrote the concurrent usage write: %+v", after.Status.Usage)
+	}
+
+	// A status write that never lands fails the resume instead of being dropped.
+	dyn.PrependReactor("update", "sandboxsessions", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		return a.GetSubresource() == "status", nil, apierrors.NewConflict(sessionGVR.GroupResource(), sess.Name, errors.New("object has been modified"))
+	})
+	if _, err := o.ResumeSession(ctx, sess.Name); status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal when the status write fails, got %v", err)
+	}
+}
+
 func TestPauseSessionEphemeralRefused(t *testing.T) {
 	o := newTestOrchestrator()
 	ctx := context.Background()
@@ -1155,7 +1220,7 @@ func TestPauseSessionEphemeralRefused(t *testing.T) {
 	if err != nil {
 		t.Fatalf(msgCreate, err)
 	}
-	pauseErr := o.PauseSession(ctx, sess.Name)
+	_, pauseErr := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM)
 	if pauseErr == nil {
 		t.Fatal("ephemeral session must refuse pause (EmptyDir would lose files)")
 	}
@@ -1174,7 +1239,7 @@ func TestResumeSessionRestoresPodWithPVC(t *testing.T) {
 	if err != nil {
 		t.Fatalf(msgCreate, err)
 	}
-	if err := o.PauseSession(ctx, sess.Name); err != nil {
+	if _, err := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM); err != nil {
 		t.Fatalf("pause: %v", err)
 	}
 
@@ -1214,11 +1279,11 @@ func TestPauseSessionNonActiveRefused(t *testing.T) {
 	if err != nil {
 		t.Fatalf(msgCreate, err)
 	}
-	if err := o.PauseSession(ctx, sess.Name); err != nil {
+	if _, err := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM); err != nil {
 		t.Fatalf("first pause: %v", err)
 	}
 	// Pausing an already-paused session is refused.
-	if err := o.PauseSession(ctx, sess.Name); err == nil {
+	if _, err := o.PauseSession(ctx, sess.Name, pb.PauseMode_PAUSE_MODE_FILESYSTEM); err == nil {
 		t.Fatal("second pause must be refused (not active)")
 	}
 	// Resuming the paused session works (it IS paused) — and the second
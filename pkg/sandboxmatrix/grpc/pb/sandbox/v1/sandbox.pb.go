@@ -21,6 +21,121 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// SessionEventType classifies a WatchSessions event.
+type SessionEventType int32
+
+const (
+	SessionEventType_SESSION_EVENT_UNSPECIFIED   SessionEventType = 0
+	SessionEventType_SESSION_EVENT_PHASE         SessionEventType = 1 // phase changed (previous_phase empty for a new session)
+	SessionEventType_SESSION_EVENT_DELETED       SessionEventType = 2 // session CRD removed
+	SessionEventType_SESSION_EVENT_EXPIRING      SessionEventType = 3 // TTL runs out at expires_at
+	SessionEventType_SESSION_EVENT_RUN_COMPLETED SessionEventType = 4 // background run finished
+	SessionEventType_SESSION_EVENT_EXPOSED       SessionEventType = 5 // port exposed through the gateway
+	SessionEventType_SESSION_EVENT_UNEXPOSED     SessionEventType = 6 // exposed port removed
+	SessionEventType_SESSION_EVENT_ALLOWED_HOSTS SessionEventType = 7 // egress allowlist changed
+	SessionEventType_SESSION_EVENT_EGRESS        SessionEventType = 8 // connection through the egress proxy
+)
+
+// Enum value maps for SessionEventType.
+var (
+	SessionEventType_name = map[int32]string{
+		0: "SESSION_EVENT_UNSPECIFIED",
+		1: "SESSION_EVENT_PHASE",
+		2: "SESSION_EVENT_DELETED",
+		3: "SESSION_EVENT_EXPIRING",
+		4: "SESSION_EVENT_RUN_COMPLETED",
+		5: "SESSION_EVENT_EXPOSED",
+		6: "SESSION_EVENT_UNEXPOSED",
+		7: "SESSION_EVENT_ALLOWED_HOSTS",
+		8: "SESSION_EVENT_EGRESS",
+	}
+	SessionEventType_value = map[string]int32{
+		"SESSION_EVENT_UNSPECIFIED":   0,
+		"SESSION_EVENT_PHASE":         1,
+		"SESSION_EVENT_DELETED":       2,
+		"SESSION_EVENT_EXPIRING":      3,
+		"SESSION_EVENT_RUN_COMPLETED": 4,
+		"SESSION_EVENT_EXPOSED":       5,
+		"SESSION_EVENT_UNEXPOSED":     6,
+		"SESSION_EVENT_ALLOWED_HOSTS": 7,
+		"SESSION_EVENT_EGRESS":        8,
+	}
+)
+
+func (x SessionEventType) Enum() *SessionEventType {
+	p := new(SessionEventType)
+	*p = x
+	return p
+}
+
+func (x SessionEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SessionEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_sandbox_v1_sandbox_proto_enumTypes[0].Descriptor()
+}
+
+func (SessionEventType) Type() protoreflect.EnumType {
+	return &file_sandbox_v1_sandbox_proto_enumTypes[0]
+}
+
+func (x SessionEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SessionEventType.Descriptor instead.
+func (SessionEventType) EnumDescriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{0}
+}
+
+// PauseMode selects what PauseSession preserves.
+type PauseMode int32
+
+const (
+	PauseMode_PAUSE_MODE_FILESYSTEM PauseMode = 0 // workspace PVC only; resume cold-boots the processes
+	PauseMode_PAUSE_MODE_MEMORY     PauseMode = 1 // also checkpoint process memory into the layer store
+)
+
+// Enum value maps for PauseMode.
+var (
+	PauseMode_name = map[int32]string{
+		0: "PAUSE_MODE_FILESYSTEM",
+		1: "PAUSE_MODE_MEMORY",
+	}
+	PauseMode_value = map[string]int32{
+		"PAUSE_MODE_FILESYSTEM": 0,
+		"PAUSE_MODE_MEMORY":     1,
+	}
+)
+
+func (x PauseMode) Enum() *PauseMode {
+	p := new(PauseMode)
+	*p = x
+	return p
+}
+
+func (x PauseMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PauseMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_sandbox_v1_sandbox_proto_enumTypes[1].Descriptor()
+}
+
+func (PauseMode) Type() protoreflect.EnumType {
+	return &file_sandbox_v1_sandbox_proto_enumTypes[1]
+}
+
+func (x PauseMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PauseMode.Descriptor instead.
+func (PauseMode) EnumDescriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{1}
+}
+
 type TerminalSignal int32
 
 const (
@@ -63,11 +178,11 @@ func (x TerminalSignal) String() string {
 }
 
 func (TerminalSignal) Descriptor() protoreflect.EnumDescriptor {
-	return file_sandbox_v1_sandbox_proto_enumTypes[0].Descriptor()
+	return file_sandbox_v1_sandbox_proto_enumTypes[2].Descriptor()
 }
 
 func (TerminalSignal) Type() protoreflect.EnumType {
-	return &file_sandbox_v1_sandbox_proto_enumTypes[0]
+	return &file_sandbox_v1_sandbox_proto_enumTypes[2]
 }
 
 func (x TerminalSignal) Number() protoreflect.EnumNumber {
@@ -76,16 +191,76 @@ func (x TerminalSignal) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use TerminalSignal.Descriptor instead.
 func (TerminalSignal) EnumDescriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{0}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{2}
+}
+
+// ExposeAccess is who may reach an exposed URL.
+type ExposeAccess int32
+
+const (
+	ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED ExposeAccess = 0 // token for a new exposure; re-expose keeps the existing mode
+	ExposeAccess_EXPOSE_ACCESS_PUBLIC      ExposeAccess = 1 // anyone with the URL
+	ExposeAccess_EXPOSE_ACCESS_TOKEN       ExposeAccess = 2 // a per-URL token, exchanged for a cookie on first visit
+	ExposeAccess_EXPOSE_ACCESS_SIGNED      ExposeAccess = 3 // a signed URL valid until expires_at
+)
+
+// Enum value maps for ExposeAccess.
+var (
+	ExposeAccess_name = map[int32]string{
+		0: "EXPOSE_ACCESS_UNSPECIFIED",
+		1: "EXPOSE_ACCESS_PUBLIC",
+		2: "EXPOSE_ACCESS_TOKEN",
+		3: "EXPOSE_ACCESS_SIGNED",
+	}
+	ExposeAccess_value = map[string]int32{
+		"EXPOSE_ACCESS_UNSPECIFIED": 0,
+		"EXPOSE_ACCESS_PUBLIC":      1,
+		"EXPOSE_ACCESS_TOKEN":       2,
+		"EXPOSE_ACCESS_SIGNED":      3,
+	}
+)
+
+func (x ExposeAccess) Enum() *ExposeAccess {
+	p := new(ExposeAccess)
+	*p = x
+	return p
+}
+
+func (x ExposeAccess) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExposeAccess) Descriptor() protoreflect.EnumDescriptor {
+	return file_sandbox_v1_sandbox_proto_enumTypes[3].Descriptor()
+}
+
+func (ExposeAccess) Type() protoreflect.EnumType {
+	return &file_sandbox_v1_sandbox_proto_enumTypes[3]
+}
+
+func (x ExposeAccess) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExposeAccess.Descriptor instead.
+func (ExposeAccess) EnumDescriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{3}
 }
 
 // SecretRef references a key in a same-namespace K8s Secret. Values are resolved
 // at exec time only and never stored on the SandboxSession CRD (#505 / KIP-12 B).
 type SecretRef struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SecretName    string                 `protobuf:"bytes,1,opt,name=secret_name,json=secretName,proto3" json:"secret_name,omitempty"` // K8s Secret name
-	Key           string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`                                 // key within Secret.data
-	EnvVar        string                 `protobuf:"bytes,3,opt,name=env_var,json=envVar,proto3" json:"env_var,omitempty"`             // environment variable name injected into the process
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	SecretName string                 `protobuf:"bytes,1,opt,name=secret_name,json=secretName,proto3" json:"secret_name,omitempty"` // K8s Secret name
+	Key        string                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`                                 // key within Secret.data
+	EnvVar     string                 `protobuf:"bytes,3,opt,name=env_var,json=envVar,proto3" json:"env_var,omitempty"`             // environment variable name injected into the process
+	// hosts, when set, keeps the value out of the sandbox: env_var holds a
+	// placeholder that the egress proxy replaces with the value in requests
+	// to these hosts (exact or "*" patterns; needs the egress proxy).
+	Hosts []string `protobuf:"bytes,4,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	// header, with hosts, is a request header the proxy also injects into
+	// (e.g. X-Api-Key); Authorization always is.
+	Header        string `protobuf:"bytes,5,opt,name=header,proto3" json:"header,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -141,6 +316,151 @@ func (x *SecretRef) GetEnvVar() string {
 	return ""
 }
 
+func (x *SecretRef) GetHosts() []string {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *SecretRef) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+// EgressRule allows egress beyond allowed_hosts (exact hosts on TCP 443):
+// a wildcard host or a CIDR, on chosen ports and protocol, optionally
+// limited to HTTP requests by method and path.
+type EgressRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`           // FQDN or pattern (*.pypi.org); exclusive with cidr
+	Cidr          string                 `protobuf:"bytes,2,opt,name=cidr,proto3" json:"cidr,omitempty"`           // 10.0.0.0/8; a bare IP is a single address
+	Ports         []int32                `protobuf:"varint,3,rep,packed,name=ports,proto3" json:"ports,omitempty"` // default 443
+	Protocol      string                 `protobuf:"bytes,4,opt,name=protocol,proto3" json:"protocol,omitempty"`   // TCP (default) | UDP | ANY
+	Http          []*EgressHTTPRule      `protobuf:"bytes,5,rep,name=http,proto3" json:"http,omitempty"`           // L7: only matching requests pass
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EgressRule) Reset() {
+	*x = EgressRule{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EgressRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EgressRule) ProtoMessage() {}
+
+func (x *EgressRule) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EgressRule.ProtoReflect.Descriptor instead.
+func (*EgressRule) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EgressRule) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *EgressRule) GetCidr() string {
+	if x != nil {
+		return x.Cidr
+	}
+	return ""
+}
+
+func (x *EgressRule) GetPorts() []int32 {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+func (x *EgressRule) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *EgressRule) GetHttp() []*EgressHTTPRule {
+	if x != nil {
+		return x.Http
+	}
+	return nil
+}
+
+type EgressHTTPRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Method        string                 `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"` // GET, POST, ...; empty = any
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`     // /repos/* ("*" wildcards); empty = any
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EgressHTTPRule) Reset() {
+	*x = EgressHTTPRule{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EgressHTTPRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EgressHTTPRule) ProtoMessage() {}
+
+func (x *EgressHTTPRule) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EgressHTTPRule.ProtoReflect.Descriptor instead.
+func (*EgressHTTPRule) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EgressHTTPRule) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *EgressHTTPRule) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
 type CreateSessionRequest struct {
 	state        protoimpl.MessageState `protogen:"open.v1"`
 	SessionId    string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -149,15 +469,21 @@ type CreateSessionRequest struct {
 	RuntimeClass string                 `protobuf:"bytes,4,opt,name=runtime_class,json=runtimeClass,proto3" json:"runtime_class,omitempty"`
 	// Non-sensitive environment variables persisted on the SandboxSession and
 	// applied at exec time so warm-pool pods stay reusable (KIP-12 Part B / #483).
-	Env           map[string]string `protobuf:"bytes,5,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	SecretRefs    []*SecretRef      `protobuf:"bytes,6,rep,name=secret_refs,json=secretRefs,proto3" json:"secret_refs,omitempty"`
+	Env        map[string]string `protobuf:"bytes,5,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SecretRefs []*SecretRef      `protobuf:"bytes,6,rep,name=secret_refs,json=secretRefs,proto3" json:"secret_refs,omitempty"`
+	// SandboxTemplate name: the pod is built from the template's image,
+	// runtime class, resource limits and allowed hosts, and only warm pods
+	// from a pool with the same templateRef are claimed. Explicit
+	// runtime_class / allowed_hosts on this request still win.
+	Template      string        `protobuf:"bytes,7,opt,name=template,proto3" json:"template,omitempty"`
+	EgressRules   []*EgressRule `protobuf:"bytes,8,rep,name=egress_rules,json=egressRules,proto3" json:"egress_rules,omitempty"` // in addition to allowed_hosts
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CreateSessionRequest) Reset() {
 	*x = CreateSessionRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[1]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -169,7 +495,7 @@ func (x *CreateSessionRequest) String() string {
 func (*CreateSessionRequest) ProtoMessage() {}
 
 func (x *CreateSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[1]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -182,7 +508,7 @@ func (x *CreateSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateSessionRequest.ProtoReflect.Descriptor instead.
 func (*CreateSessionRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{1}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *CreateSessionRequest) GetSessionId() string {
@@ -227,6 +553,20 @@ func (x *CreateSessionRequest) GetSecretRefs() []*SecretRef {
 	return nil
 }
 
+func (x *CreateSessionRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *CreateSessionRequest) GetEgressRules() []*EgressRule {
+	if x != nil {
+		return x.EgressRules
+	}
+	return nil
+}
+
 type CreateSessionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -237,7 +577,7 @@ type CreateSessionResponse struct {
 
 func (x *CreateSessionResponse) Reset() {
 	*x = CreateSessionResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[2]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -249,7 +589,7 @@ func (x *CreateSessionResponse) String() string {
 func (*CreateSessionResponse) ProtoMessage() {}
 
 func (x *CreateSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[2]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -262,7 +602,7 @@ func (x *CreateSessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateSessionResponse.ProtoReflect.Descriptor instead.
 func (*CreateSessionResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{2}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *CreateSessionResponse) GetSessionId() string {
@@ -288,7 +628,7 @@ type GetSessionRequest struct {
 
 func (x *GetSessionRequest) Reset() {
 	*x = GetSessionRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[3]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -300,7 +640,7 @@ func (x *GetSessionRequest) String() string {
 func (*GetSessionRequest) ProtoMessage() {}
 
 func (x *GetSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[3]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -313,7 +653,7 @@ func (x *GetSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetSessionRequest.ProtoReflect.Descriptor instead.
 func (*GetSessionRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{3}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *GetSessionRequest) GetSessionId() string {
@@ -335,13 +675,17 @@ type GetSessionResponse struct {
 	SecretEnvVars  []string               `protobuf:"bytes,8,rep,name=secret_env_vars,json=secretEnvVars,proto3" json:"secret_env_vars,omitempty"`   // env_var names from secret_refs only
 	BackgroundRuns int32                  `protobuf:"varint,9,opt,name=background_runs,json=backgroundRuns,proto3" json:"background_runs,omitempty"` // active entries known to gateway registry
 	AllowedHosts   []string               `protobuf:"bytes,10,rep,name=allowed_hosts,json=allowedHosts,proto3" json:"allowed_hosts,omitempty"`       // current egress allowlist (KIP-24)
+	Template       string                 `protobuf:"bytes,11,opt,name=template,proto3" json:"template,omitempty"`                                   // SandboxTemplate the pod was built from; empty = defaults
+	ForkedFrom     string                 `protobuf:"bytes,12,opt,name=forked_from,json=forkedFrom,proto3" json:"forked_from,omitempty"`             // parent session when created by ForkSession
+	ForkIndex      int32                  `protobuf:"varint,13,opt,name=fork_index,json=forkIndex,proto3" json:"fork_index,omitempty"`               // position among the fork's siblings
+	EgressRules    []*EgressRule          `protobuf:"bytes,14,rep,name=egress_rules,json=egressRules,proto3" json:"egress_rules,omitempty"`          // structured egress beyond allowed_hosts
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
 
 func (x *GetSessionResponse) Reset() {
 	*x = GetSessionResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[4]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -353,7 +697,7 @@ func (x *GetSessionResponse) String() string {
 func (*GetSessionResponse) ProtoMessage() {}
 
 func (x *GetSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[4]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -366,7 +710,7 @@ func (x *GetSessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetSessionResponse.ProtoReflect.Descriptor instead.
 func (*GetSessionResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{4}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *GetSessionResponse) GetSessionId() string {
@@ -439,6 +783,34 @@ func (x *GetSessionResponse) GetAllowedHosts() []string {
 	return nil
 }
 
+func (x *GetSessionResponse) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *GetSessionResponse) GetForkedFrom() string {
+	if x != nil {
+		return x.ForkedFrom
+	}
+	return ""
+}
+
+func (x *GetSessionResponse) GetForkIndex() int32 {
+	if x != nil {
+		return x.ForkIndex
+	}
+	return 0
+}
+
+func (x *GetSessionResponse) GetEgressRules() []*EgressRule {
+	if x != nil {
+		return x.EgressRules
+	}
+	return nil
+}
+
 type ListSessionsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Phase         string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"` // empty = Active only; "all" = every phase
@@ -448,7 +820,7 @@ type ListSessionsRequest struct {
 
 func (x *ListSessionsRequest) Reset() {
 	*x = ListSessionsRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[5]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -460,7 +832,7 @@ func (x *ListSessionsRequest) String() string {
 func (*ListSessionsRequest) ProtoMessage() {}
 
 func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[5]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -473,7 +845,7 @@ func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListSessionsRequest.ProtoReflect.Descriptor instead.
 func (*ListSessionsRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{5}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ListSessionsRequest) GetPhase() string {
@@ -492,7 +864,7 @@ type ListSessionsResponse struct {
 
 func (x *ListSessionsResponse) Reset() {
 	*x = ListSessionsResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[6]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -504,7 +876,7 @@ func (x *ListSessionsResponse) String() string {
 func (*ListSessionsResponse) ProtoMessage() {}
 
 func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[6]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -517,7 +889,7 @@ func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
 func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{6}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ListSessionsResponse) GetSessions() []*GetSessionResponse {
@@ -527,16 +899,251 @@ func (x *ListSessionsResponse) GetSessions() []*GetSessionResponse {
 	return nil
 }
 
-type DestroySessionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+// WatchSessionsRequest filters the event stream; empty fields match all.
+type WatchSessionsRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	TenantId  string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	SessionId string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Types     []SessionEventType     `protobuf:"varint,3,rep,packed,name=types,proto3,enum=sandbox.v1.SessionEventType" json:"types,omitempty"`
+	// send_initial first sends a PHASE event (previous_phase empty) for
+	// every matching session.
+	SendInitial   bool `protobuf:"varint,4,opt,name=send_initial,json=sendInitial,proto3" json:"send_initial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchSessionsRequest) Reset() {
+	*x = WatchSessionsRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchSessionsRequest) ProtoMessage() {}
+
+func (x *WatchSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchSessionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchSessionsRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *WatchSessionsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *WatchSessionsRequest) GetTypes() []SessionEventType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *WatchSessionsRequest) GetSendInitial() bool {
+	if x != nil {
+		return x.SendInitial
+	}
+	return false
+}
+
+type SessionEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          SessionEventType       `protobuf:"varint,1,opt,name=type,proto3,enum=sandbox.v1.SessionEventType" json:"type,omitempty"`
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Time          int64                  `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`                                       // unix milliseconds
+	Phase         string                 `protobuf:"bytes,5,opt,name=phase,proto3" json:"phase,omitempty"`                                      // current phase
+	PreviousPhase string                 `protobuf:"bytes,6,opt,name=previous_phase,json=previousPhase,proto3" json:"previous_phase,omitempty"` // PHASE only
+	ExpiresAt     int64                  `protobuf:"varint,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`            // EXPIRING: unix seconds
+	RunId         string                 `protobuf:"bytes,8,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`                         // RUN_COMPLETED
+	RunStatus     string                 `protobuf:"bytes,9,opt,name=run_status,json=runStatus,proto3" json:"run_status,omitempty"`             // RUN_COMPLETED: completed|failed|timed_out
+	ExitCode      int32                  `protobuf:"varint,10,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`              // RUN_COMPLETED
+	Port          int32                  `protobuf:"varint,11,opt,name=port,proto3" json:"port,omitempty"`                                      // EXPOSED / UNEXPOSED / EGRESS
+	Url           string                 `protobuf:"bytes,12,opt,name=url,proto3" json:"url,omitempty"`                                         // EXPOSED
+	AllowedHosts  []string               `protobuf:"bytes,13,rep,name=allowed_hosts,json=allowedHosts,proto3" json:"allowed_hosts,omitempty"`   // ALLOWED_HOSTS: the new list
+	EgressRules   []*EgressRule          `protobuf:"bytes,14,rep,name=egress_rules,json=egressRules,proto3" json:"egress_rules,omitempty"`      // ALLOWED_HOSTS: the new rules
+	EgressHost    string                 `protobuf:"bytes,15,opt,name=egress_host,json=egressHost,proto3" json:"egress_host,omitempty"`         // EGRESS: requested host (or IP)
+	EgressDenied  bool                   `protobuf:"varint,16,opt,name=egress_denied,json=egressDenied,proto3" json:"egress_denied,omitempty"`  // EGRESS: refused by the session's allowlist
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionEvent) Reset() {
+	*x = SessionEvent{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionEvent) ProtoMessage() {}
+
+func (x *SessionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionEvent.ProtoReflect.Descriptor instead.
+func (*SessionEvent) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SessionEvent) GetType() SessionEventType {
+	if x != nil {
+		return x.Type
+	}
+	return SessionEventType_SESSION_EVENT_UNSPECIFIED
+}
+
+func (x *SessionEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *SessionEvent) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetPreviousPhase() string {
+	if x != nil {
+		return x.PreviousPhase
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *SessionEvent) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetRunStatus() string {
+	if x != nil {
+		return x.RunStatus
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *SessionEvent) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *SessionEvent) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetAllowedHosts() []string {
+	if x != nil {
+		return x.AllowedHosts
+	}
+	return nil
+}
+
+func (x *SessionEvent) GetEgressRules() []*EgressRule {
+	if x != nil {
+		return x.EgressRules
+	}
+	return nil
+}
+
+func (x *SessionEvent) GetEgressHost() string {
+	if x != nil {
+		return x.EgressHost
+	}
+	return ""
+}
+
+func (x *SessionEvent) GetEgressDenied() bool {
+	if x != nil {
+		return x.EgressDenied
+	}
+	return false
+}
+
+type DestroySessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DestroySessionRequest) Reset() {
 	*x = DestroySessionRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[7]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -548,7 +1155,7 @@ func (x *DestroySessionRequest) String() string {
 func (*DestroySessionRequest) ProtoMessage() {}
 
 func (x *DestroySessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[7]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -561,7 +1168,7 @@ func (x *DestroySessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DestroySessionRequest.ProtoReflect.Descriptor instead.
 func (*DestroySessionRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{7}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *DestroySessionRequest) GetSessionId() string {
@@ -580,7 +1187,7 @@ type DestroySessionResponse struct {
 
 func (x *DestroySessionResponse) Reset() {
 	*x = DestroySessionResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[8]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -592,7 +1199,7 @@ func (x *DestroySessionResponse) String() string {
 func (*DestroySessionResponse) ProtoMessage() {}
 
 func (x *DestroySessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[8]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -605,7 +1212,7 @@ func (x *DestroySessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DestroySessionResponse.ProtoReflect.Descriptor instead.
 func (*DestroySessionResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{8}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *DestroySessionResponse) GetOk() bool {
@@ -618,13 +1225,14 @@ func (x *DestroySessionResponse) GetOk() bool {
 type PauseSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Mode          PauseMode              `protobuf:"varint,2,opt,name=mode,proto3,enum=sandbox.v1.PauseMode" json:"mode,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *PauseSessionRequest) Reset() {
 	*x = PauseSessionRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[9]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -636,7 +1244,7 @@ func (x *PauseSessionRequest) String() string {
 func (*PauseSessionRequest) ProtoMessage() {}
 
 func (x *PauseSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[9]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -649,7 +1257,7 @@ func (x *PauseSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PauseSessionRequest.ProtoReflect.Descriptor instead.
 func (*PauseSessionRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{9}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *PauseSessionRequest) GetSessionId() string {
@@ -659,16 +1267,25 @@ func (x *PauseSessionRequest) GetSessionId() string {
 	return ""
 }
 
+func (x *PauseSessionRequest) GetMode() PauseMode {
+	if x != nil {
+		return x.Mode
+	}
+	return PauseMode_PAUSE_MODE_FILESYSTEM
+}
+
 type PauseSessionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Mode          PauseMode              `protobuf:"varint,2,opt,name=mode,proto3,enum=sandbox.v1.PauseMode" json:"mode,omitempty"` // what was actually preserved
+	Fallback      string                 `protobuf:"bytes,3,opt,name=fallback,proto3" json:"fallback,omitempty"`                    // why a MEMORY pause fell back to FILESYSTEM
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *PauseSessionResponse) Reset() {
 	*x = PauseSessionResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[10]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -680,7 +1297,7 @@ func (x *PauseSessionResponse) String() string {
 func (*PauseSessionResponse) ProtoMessage() {}
 
 func (x *PauseSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[10]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -693,7 +1310,7 @@ func (x *PauseSessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PauseSessionResponse.ProtoReflect.Descriptor instead.
 func (*PauseSessionResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{10}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *PauseSessionResponse) GetOk() bool {
@@ -703,6 +1320,20 @@ func (x *PauseSessionResponse) GetOk() bool {
 	return false
 }
 
+func (x *PauseSessionResponse) GetMode() PauseMode {
+	if x != nil {
+		return x.Mode
+	}
+	return PauseMode_PAUSE_MODE_FILESYSTEM
+}
+
+func (x *PauseSessionResponse) GetFallback() string {
+	if x != nil {
+		return x.Fallback
+	}
+	return ""
+}
+
 type ResumeSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -712,7 +1343,7 @@ type ResumeSessionRequest struct {
 
 func (x *ResumeSessionRequest) Reset() {
 	*x = ResumeSessionRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[11]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -724,7 +1355,7 @@ func (x *ResumeSessionRequest) String() string {
 func (*ResumeSessionRequest) ProtoMessage() {}
 
 func (x *ResumeSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[11]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -737,7 +1368,7 @@ func (x *ResumeSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResumeSessionRequest.ProtoReflect.Descriptor instead.
 func (*ResumeSessionRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{11}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *ResumeSessionRequest) GetSessionId() string {
@@ -748,15 +1379,16 @@ func (x *ResumeSessionRequest) GetSessionId() string {
 }
 
 type ResumeSessionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Ok             bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	MemoryRestored bool                   `protobuf:"varint,2,opt,name=memory_restored,json=memoryRestored,proto3" json:"memory_restored,omitempty"` // processes resumed from a memory checkpoint
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *ResumeSessionResponse) Reset() {
 	*x = ResumeSessionResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[12]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -768,7 +1400,7 @@ func (x *ResumeSessionResponse) String() string {
 func (*ResumeSessionResponse) ProtoMessage() {}
 
 func (x *ResumeSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[12]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -781,7 +1413,7 @@ func (x *ResumeSessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResumeSessionResponse.ProtoReflect.Descriptor instead.
 func (*ResumeSessionResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{12}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *ResumeSessionResponse) GetOk() bool {
@@ -791,6 +1423,13 @@ func (x *ResumeSessionResponse) GetOk() bool {
 	return false
 }
 
+func (x *ResumeSessionResponse) GetMemoryRestored() bool {
+	if x != nil {
+		return x.MemoryRestored
+	}
+	return false
+}
+
 type ExecRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -805,7 +1444,7 @@ type ExecRequest struct {
 
 func (x *ExecRequest) Reset() {
 	*x = ExecRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[13]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -817,7 +1456,7 @@ func (x *ExecRequest) String() string {
 func (*ExecRequest) ProtoMessage() {}
 
 func (x *ExecRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[13]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -830,7 +1469,7 @@ func (x *ExecRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExecRequest.ProtoReflect.Descriptor instead.
 func (*ExecRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{13}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *ExecRequest) GetSessionId() string {
@@ -883,17 +1522,19 @@ type ExecResponse struct {
 	SessionId string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	RunId     string                 `protobuf:"bytes,5,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"` // set when background=true
 	// started|running|completed|timed_out|failed
-	Status        string `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
-	DurationMs    int64  `protobuf:"varint,7,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // wall-clock ms for this execution (0 if unknown/started)
-	Truncated     bool   `protobuf:"varint,8,opt,name=truncated,proto3" json:"truncated,omitempty"`                     // stdout and/or stderr hit size cap
-	Language      string `protobuf:"bytes,9,opt,name=language,proto3" json:"language,omitempty"`                        // echoed from request when set
+	Status     string `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	DurationMs int64  `protobuf:"varint,7,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // wall-clock ms for this execution (0 if unknown/started)
+	Truncated  bool   `protobuf:"varint,8,opt,name=truncated,proto3" json:"truncated,omitempty"`                     // stdout and/or stderr hit size cap
+	Language   string `protobuf:"bytes,9,opt,name=language,proto3" json:"language,omitempty"`                        // echoed from request when set
+	// Set when truncated and the full output was kept: page it with GetRunOutput.
+	OutputRef     *OutputRef `protobuf:"bytes,10,opt,name=output_ref,json=outputRef,proto3" json:"output_ref,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ExecResponse) Reset() {
 	*x = ExecResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[14]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -905,7 +1546,7 @@ func (x *ExecResponse) String() string {
 func (*ExecResponse) ProtoMessage() {}
 
 func (x *ExecResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[14]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -918,7 +1559,7 @@ func (x *ExecResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExecResponse.ProtoReflect.Descriptor instead.
 func (*ExecResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{14}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ExecResponse) GetStdout() string {
@@ -984,28 +1625,42 @@ func (x *ExecResponse) GetLanguage() string {
 	return ""
 }
 
-type ExecStreamResponse struct {
+func (x *ExecResponse) GetOutputRef() *OutputRef {
+	if x != nil {
+		return x.OutputRef
+	}
+	return nil
+}
+
+// OutputRef locates a truncated run's full output. The *_offset fields are
+// where the inline stdout/stderr stop (continue GetRunOutput from there);
+// *_bytes are the full stream sizes.
+type OutputRef struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Chunk         string                 `protobuf:"bytes,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	StdoutOffset  int64                  `protobuf:"varint,2,opt,name=stdout_offset,json=stdoutOffset,proto3" json:"stdout_offset,omitempty"`
+	StderrOffset  int64                  `protobuf:"varint,3,opt,name=stderr_offset,json=stderrOffset,proto3" json:"stderr_offset,omitempty"`
+	StdoutBytes   int64                  `protobuf:"varint,4,opt,name=stdout_bytes,json=stdoutBytes,proto3" json:"stdout_bytes,omitempty"`
+	StderrBytes   int64                  `protobuf:"varint,5,opt,name=stderr_bytes,json=stderrBytes,proto3" json:"stderr_bytes,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecStreamResponse) Reset() {
-	*x = ExecStreamResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[15]
+func (x *OutputRef) Reset() {
+	*x = OutputRef{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecStreamResponse) String() string {
+func (x *OutputRef) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecStreamResponse) ProtoMessage() {}
+func (*OutputRef) ProtoMessage() {}
 
-func (x *ExecStreamResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[15]
+func (x *OutputRef) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1016,108 +1671,68 @@ func (x *ExecStreamResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecStreamResponse.ProtoReflect.Descriptor instead.
-func (*ExecStreamResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{15}
+// Deprecated: Use OutputRef.ProtoReflect.Descriptor instead.
+func (*OutputRef) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *ExecStreamResponse) GetChunk() string {
+func (x *OutputRef) GetRunId() string {
 	if x != nil {
-		return x.Chunk
+		return x.RunId
 	}
 	return ""
 }
 
-type WriteFileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
-	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
-	Mode          string                 `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *WriteFileRequest) Reset() {
-	*x = WriteFileRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[16]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *WriteFileRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*WriteFileRequest) ProtoMessage() {}
-
-func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[16]
-	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use WriteFileRequest.ProtoReflect.Descriptor instead.
-func (*WriteFileRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{16}
-}
-
-func (x *WriteFileRequest) GetSessionId() string {
+func (x *OutputRef) GetStdoutOffset() int64 {
 	if x != nil {
-		return x.SessionId
+		return x.StdoutOffset
 	}
-	return ""
+	return 0
 }
 
-func (x *WriteFileRequest) GetPath() string {
+func (x *OutputRef) GetStderrOffset() int64 {
 	if x != nil {
-		return x.Path
+		return x.StderrOffset
 	}
-	return ""
+	return 0
 }
 
-func (x *WriteFileRequest) GetContent() string {
+func (x *OutputRef) GetStdoutBytes() int64 {
 	if x != nil {
-		return x.Content
+		return x.StdoutBytes
 	}
-	return ""
+	return 0
 }
 
-func (x *WriteFileRequest) GetMode() string {
+func (x *OutputRef) GetStderrBytes() int64 {
 	if x != nil {
-		return x.Mode
+		return x.StderrBytes
 	}
-	return ""
+	return 0
 }
 
-type WriteFileResponse struct {
+type ExecStreamResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Chunk         string                 `protobuf:"bytes,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *WriteFileResponse) Reset() {
-	*x = WriteFileResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[17]
+func (x *ExecStreamResponse) Reset() {
+	*x = ExecStreamResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *WriteFileResponse) String() string {
+func (x *ExecStreamResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*WriteFileResponse) ProtoMessage() {}
+func (*ExecStreamResponse) ProtoMessage() {}
 
-func (x *WriteFileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[17]
+func (x *ExecStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1128,41 +1743,47 @@ func (x *WriteFileResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use WriteFileResponse.ProtoReflect.Descriptor instead.
-func (*WriteFileResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{17}
+// Deprecated: Use ExecStreamResponse.ProtoReflect.Descriptor instead.
+func (*ExecStreamResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *WriteFileResponse) GetOk() bool {
+func (x *ExecStreamResponse) GetChunk() string {
 	if x != nil {
-		return x.Ok
+		return x.Chunk
 	}
-	return false
+	return ""
 }
 
-type ReadFileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+// ExecFrame is one ExecStreamV2 / ExecInteractive frame. exit is always the
+// last frame of a stream that ran to completion.
+type ExecFrame struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Frame:
+	//
+	//	*ExecFrame_Stdout
+	//	*ExecFrame_Stderr
+	//	*ExecFrame_Exit
+	Frame         isExecFrame_Frame `protobuf_oneof:"frame"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ReadFileRequest) Reset() {
-	*x = ReadFileRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[18]
+func (x *ExecFrame) Reset() {
+	*x = ExecFrame{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ReadFileRequest) String() string {
+func (x *ExecFrame) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReadFileRequest) ProtoMessage() {}
+func (*ExecFrame) ProtoMessage() {}
 
-func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[18]
+func (x *ExecFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1173,93 +1794,92 @@ func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReadFileRequest.ProtoReflect.Descriptor instead.
-func (*ReadFileRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{18}
+// Deprecated: Use ExecFrame.ProtoReflect.Descriptor instead.
+func (*ExecFrame) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *ReadFileRequest) GetSessionId() string {
+func (x *ExecFrame) GetFrame() isExecFrame_Frame {
 	if x != nil {
-		return x.SessionId
+		return x.Frame
 	}
-	return ""
+	return nil
 }
 
-func (x *ReadFileRequest) GetPath() string {
+func (x *ExecFrame) GetStdout() []byte {
 	if x != nil {
-		return x.Path
+		if x, ok := x.Frame.(*ExecFrame_Stdout); ok {
+			return x.Stdout
+		}
 	}
-	return ""
+	return nil
 }
 
-type ReadFileResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ExecFrame) GetStderr() []byte {
+	if x != nil {
+		if x, ok := x.Frame.(*ExecFrame_Stderr); ok {
+			return x.Stderr
+		}
+	}
+	return nil
 }
 
-func (x *ReadFileResponse) Reset() {
-	*x = ReadFileResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[19]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ExecFrame) GetExit() *ExecExit {
+	if x != nil {
+		if x, ok := x.Frame.(*ExecFrame_Exit); ok {
+			return x.Exit
+		}
+	}
+	return nil
 }
 
-func (x *ReadFileResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+type isExecFrame_Frame interface {
+	isExecFrame_Frame()
 }
 
-func (*ReadFileResponse) ProtoMessage() {}
-
-func (x *ReadFileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[19]
-	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
+type ExecFrame_Stdout struct {
+	Stdout []byte `protobuf:"bytes,1,opt,name=stdout,proto3,oneof"`
 }
 
-// Deprecated: Use ReadFileResponse.ProtoReflect.Descriptor instead.
-func (*ReadFileResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{19}
+type ExecFrame_Stderr struct {
+	Stderr []byte `protobuf:"bytes,2,opt,name=stderr,proto3,oneof"`
 }
 
-func (x *ReadFileResponse) GetContent() string {
-	if x != nil {
-		return x.Content
-	}
-	return ""
+type ExecFrame_Exit struct {
+	Exit *ExecExit `protobuf:"bytes,3,opt,name=exit,proto3,oneof"`
 }
 
-type ListFilesRequest struct {
-	state     protoimpl.MessageState `protogen:"open.v1"`
-	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	// Return only files modified after this unix timestamp (0 = all).
-	Since         int64 `protobuf:"varint,2,opt,name=since,proto3" json:"since,omitempty"`
+func (*ExecFrame_Stdout) isExecFrame_Frame() {}
+
+func (*ExecFrame_Stderr) isExecFrame_Frame() {}
+
+func (*ExecFrame_Exit) isExecFrame_Frame() {}
+
+type ExecExit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`                         // -1 when killed by a signal
+	Signal        int32                  `protobuf:"varint,2,opt,name=signal,proto3" json:"signal,omitempty"`                     // terminating signal number, 0 if none
+	TimedOut      bool                   `protobuf:"varint,3,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"` // killed for exceeding ExecRequest.timeout
+	DurationMs    int64                  `protobuf:"varint,4,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListFilesRequest) Reset() {
-	*x = ListFilesRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[20]
+func (x *ExecExit) Reset() {
+	*x = ExecExit{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListFilesRequest) String() string {
+func (x *ExecExit) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListFilesRequest) ProtoMessage() {}
+func (*ExecExit) ProtoMessage() {}
 
-func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[20]
+func (x *ExecExit) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1270,47 +1890,66 @@ func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
-func (*ListFilesRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{20}
+// Deprecated: Use ExecExit.ProtoReflect.Descriptor instead.
+func (*ExecExit) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *ListFilesRequest) GetSessionId() string {
+func (x *ExecExit) GetCode() int32 {
 	if x != nil {
-		return x.SessionId
+		return x.Code
 	}
-	return ""
+	return 0
 }
 
-func (x *ListFilesRequest) GetSince() int64 {
+func (x *ExecExit) GetSignal() int32 {
 	if x != nil {
-		return x.Since
+		return x.Signal
 	}
 	return 0
 }
 
-type ListFilesResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Files         []*FileEntry           `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+func (x *ExecExit) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *ExecExit) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+type ExecInput struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Input:
+	//
+	//	*ExecInput_Start
+	//	*ExecInput_Stdin
+	//	*ExecInput_CloseStdin
+	Input         isExecInput_Input `protobuf_oneof:"input"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListFilesResponse) Reset() {
-	*x = ListFilesResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[21]
+func (x *ExecInput) Reset() {
+	*x = ExecInput{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListFilesResponse) String() string {
+func (x *ExecInput) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListFilesResponse) ProtoMessage() {}
+func (*ExecInput) ProtoMessage() {}
 
-func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[21]
+func (x *ExecInput) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1321,112 +1960,92 @@ func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListFilesResponse.ProtoReflect.Descriptor instead.
-func (*ListFilesResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{21}
+// Deprecated: Use ExecInput.ProtoReflect.Descriptor instead.
+func (*ExecInput) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *ListFilesResponse) GetFiles() []*FileEntry {
+func (x *ExecInput) GetInput() isExecInput_Input {
 	if x != nil {
-		return x.Files
+		return x.Input
 	}
 	return nil
 }
 
-type FileEntry struct {
-	state    protoimpl.MessageState `protogen:"open.v1"`
-	Path     string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
-	Modified int64                  `protobuf:"varint,2,opt,name=modified,proto3" json:"modified,omitempty"`
-	// Entry type (file/dir/symlink/other) and size carried by the single
-	// ListFiles RPC so clients can build a directory listing without a
-	// per-entry stat round trip (KIP-20 perf).
-	Type          string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
-	Size          int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ExecInput) GetStart() *ExecRequest {
+	if x != nil {
+		if x, ok := x.Input.(*ExecInput_Start); ok {
+			return x.Start
+		}
+	}
+	return nil
 }
 
-func (x *FileEntry) Reset() {
-	*x = FileEntry{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[22]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ExecInput) GetStdin() []byte {
+	if x != nil {
+		if x, ok := x.Input.(*ExecInput_Stdin); ok {
+			return x.Stdin
+		}
+	}
+	return nil
 }
 
-func (x *FileEntry) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*FileEntry) ProtoMessage() {}
-
-func (x *FileEntry) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[22]
+func (x *ExecInput) GetCloseStdin() bool {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
+		if x, ok := x.Input.(*ExecInput_CloseStdin); ok {
+			return x.CloseStdin
 		}
-		return ms
 	}
-	return mi.MessageOf(x)
+	return false
 }
 
-// Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
-func (*FileEntry) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{22}
+type isExecInput_Input interface {
+	isExecInput_Input()
 }
 
-func (x *FileEntry) GetPath() string {
-	if x != nil {
-		return x.Path
-	}
-	return ""
+type ExecInput_Start struct {
+	Start *ExecRequest `protobuf:"bytes,1,opt,name=start,proto3,oneof"` // first message only
 }
 
-func (x *FileEntry) GetModified() int64 {
-	if x != nil {
-		return x.Modified
-	}
-	return 0
+type ExecInput_Stdin struct {
+	Stdin []byte `protobuf:"bytes,2,opt,name=stdin,proto3,oneof"`
 }
 
-func (x *FileEntry) GetType() string {
-	if x != nil {
-		return x.Type
-	}
-	return ""
+type ExecInput_CloseStdin struct {
+	CloseStdin bool `protobuf:"varint,3,opt,name=close_stdin,json=closeStdin,proto3,oneof"`
 }
 
-func (x *FileEntry) GetSize() int64 {
-	if x != nil {
-		return x.Size
-	}
-	return 0
-}
+func (*ExecInput_Start) isExecInput_Input() {}
 
-type PipInstallRequest struct {
+func (*ExecInput_Stdin) isExecInput_Input() {}
+
+func (*ExecInput_CloseStdin) isExecInput_Input() {}
+
+type WriteFileRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Packages      []string               `protobuf:"bytes,2,rep,name=packages,proto3" json:"packages,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Mode          string                 `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PipInstallRequest) Reset() {
-	*x = PipInstallRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[23]
+func (x *WriteFileRequest) Reset() {
+	*x = WriteFileRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PipInstallRequest) String() string {
+func (x *WriteFileRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PipInstallRequest) ProtoMessage() {}
+func (*WriteFileRequest) ProtoMessage() {}
 
-func (x *PipInstallRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[23]
+func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1437,100 +2056,60 @@ func (x *PipInstallRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PipInstallRequest.ProtoReflect.Descriptor instead.
-func (*PipInstallRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{23}
+// Deprecated: Use WriteFileRequest.ProtoReflect.Descriptor instead.
+func (*WriteFileRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *PipInstallRequest) GetSessionId() string {
+func (x *WriteFileRequest) GetSessionId() string {
 	if x != nil {
 		return x.SessionId
 	}
 	return ""
 }
 
-func (x *PipInstallRequest) GetPackages() []string {
-	if x != nil {
-		return x.Packages
-	}
-	return nil
-}
-
-type PipInstallResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Output        string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
-	ExitCode      int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *PipInstallResponse) Reset() {
-	*x = PipInstallResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[24]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *PipInstallResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*PipInstallResponse) ProtoMessage() {}
-
-func (x *PipInstallResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[24]
+func (x *WriteFileRequest) GetPath() string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Path
 	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use PipInstallResponse.ProtoReflect.Descriptor instead.
-func (*PipInstallResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{24}
+	return ""
 }
 
-func (x *PipInstallResponse) GetOutput() string {
+func (x *WriteFileRequest) GetContent() string {
 	if x != nil {
-		return x.Output
+		return x.Content
 	}
 	return ""
 }
 
-func (x *PipInstallResponse) GetExitCode() int32 {
+func (x *WriteFileRequest) GetMode() string {
 	if x != nil {
-		return x.ExitCode
+		return x.Mode
 	}
-	return 0
+	return ""
 }
 
-type RunSubAgentRequest struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	ParentSessionId string                 `protobuf:"bytes,1,opt,name=parent_session_id,json=parentSessionId,proto3" json:"parent_session_id,omitempty"`
-	AgentType       string                 `protobuf:"bytes,2,opt,name=agent_type,json=agentType,proto3" json:"agent_type,omitempty"`
-	WorkspacePath   string                 `protobuf:"bytes,3,opt,name=workspace_path,json=workspacePath,proto3" json:"workspace_path,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+type WriteFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RunSubAgentRequest) Reset() {
-	*x = RunSubAgentRequest{}
+func (x *WriteFileResponse) Reset() {
+	*x = WriteFileResponse{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RunSubAgentRequest) String() string {
+func (x *WriteFileResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RunSubAgentRequest) ProtoMessage() {}
+func (*WriteFileResponse) ProtoMessage() {}
 
-func (x *RunSubAgentRequest) ProtoReflect() protoreflect.Message {
+func (x *WriteFileResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1542,53 +2121,40 @@ func (x *RunSubAgentRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RunSubAgentRequest.ProtoReflect.Descriptor instead.
-func (*RunSubAgentRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use WriteFileResponse.ProtoReflect.Descriptor instead.
+func (*WriteFileResponse) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *RunSubAgentRequest) GetParentSessionId() string {
-	if x != nil {
-		return x.ParentSessionId
-	}
-	return ""
-}
-
-func (x *RunSubAgentRequest) GetAgentType() string {
-	if x != nil {
-		return x.AgentType
-	}
-	return ""
-}
-
-func (x *RunSubAgentRequest) GetWorkspacePath() string {
+func (x *WriteFileResponse) GetOk() bool {
 	if x != nil {
-		return x.WorkspacePath
+		return x.Ok
 	}
-	return ""
+	return false
 }
 
-type RunSubAgentResponse struct {
+type ReadFileRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RunSubAgentResponse) Reset() {
-	*x = RunSubAgentResponse{}
+func (x *ReadFileRequest) Reset() {
+	*x = ReadFileRequest{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RunSubAgentResponse) String() string {
+func (x *ReadFileRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RunSubAgentResponse) ProtoMessage() {}
+func (*ReadFileRequest) ProtoMessage() {}
 
-func (x *RunSubAgentResponse) ProtoReflect() protoreflect.Message {
+func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1600,41 +2166,46 @@ func (x *RunSubAgentResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RunSubAgentResponse.ProtoReflect.Descriptor instead.
-func (*RunSubAgentResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use ReadFileRequest.ProtoReflect.Descriptor instead.
+func (*ReadFileRequest) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *RunSubAgentResponse) GetSessionId() string {
+func (x *ReadFileRequest) GetSessionId() string {
 	if x != nil {
 		return x.SessionId
 	}
 	return ""
 }
 
-type ConfirmActionRequest struct {
+func (x *ReadFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type ReadFileResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Action        string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
-	ApprovalId    string                 `protobuf:"bytes,3,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
+	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConfirmActionRequest) Reset() {
-	*x = ConfirmActionRequest{}
+func (x *ReadFileResponse) Reset() {
+	*x = ReadFileResponse{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ConfirmActionRequest) String() string {
+func (x *ReadFileResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ConfirmActionRequest) ProtoMessage() {}
+func (*ReadFileResponse) ProtoMessage() {}
 
-func (x *ConfirmActionRequest) ProtoReflect() protoreflect.Message {
+func (x *ReadFileResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1646,54 +2217,41 @@ func (x *ConfirmActionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ConfirmActionRequest.ProtoReflect.Descriptor instead.
-func (*ConfirmActionRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ReadFileResponse.ProtoReflect.Descriptor instead.
+func (*ReadFileResponse) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *ConfirmActionRequest) GetSessionId() string {
-	if x != nil {
-		return x.SessionId
-	}
-	return ""
-}
-
-func (x *ConfirmActionRequest) GetAction() string {
-	if x != nil {
-		return x.Action
-	}
-	return ""
-}
-
-func (x *ConfirmActionRequest) GetApprovalId() string {
+func (x *ReadFileResponse) GetContent() string {
 	if x != nil {
-		return x.ApprovalId
+		return x.Content
 	}
 	return ""
 }
 
-type ConfirmActionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ApprovalId    string                 `protobuf:"bytes,1,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
-	Approved      bool                   `protobuf:"varint,2,opt,name=approved,proto3" json:"approved,omitempty"`
+type ListFilesRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Return only files modified after this unix timestamp (0 = all).
+	Since         int64 `protobuf:"varint,2,opt,name=since,proto3" json:"since,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConfirmActionResponse) Reset() {
-	*x = ConfirmActionResponse{}
+func (x *ListFilesRequest) Reset() {
+	*x = ListFilesRequest{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ConfirmActionResponse) String() string {
+func (x *ListFilesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ConfirmActionResponse) ProtoMessage() {}
+func (*ListFilesRequest) ProtoMessage() {}
 
-func (x *ConfirmActionResponse) ProtoReflect() protoreflect.Message {
+func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1705,48 +2263,46 @@ func (x *ConfirmActionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ConfirmActionResponse.ProtoReflect.Descriptor instead.
-func (*ConfirmActionResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListFilesRequest) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *ConfirmActionResponse) GetApprovalId() string {
+func (x *ListFilesRequest) GetSessionId() string {
 	if x != nil {
-		return x.ApprovalId
+		return x.SessionId
 	}
 	return ""
 }
 
-func (x *ConfirmActionResponse) GetApproved() bool {
+func (x *ListFilesRequest) GetSince() int64 {
 	if x != nil {
-		return x.Approved
+		return x.Since
 	}
-	return false
+	return 0
 }
 
-type ApproveActionRequest struct {
+type ListFilesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ApprovalId    string                 `protobuf:"bytes,1,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
-	Approved      bool                   `protobuf:"varint,2,opt,name=approved,proto3" json:"approved,omitempty"`
-	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Files         []*FileEntry           `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ApproveActionRequest) Reset() {
-	*x = ApproveActionRequest{}
+func (x *ListFilesResponse) Reset() {
+	*x = ListFilesResponse{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ApproveActionRequest) String() string {
+func (x *ListFilesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ApproveActionRequest) ProtoMessage() {}
+func (*ListFilesResponse) ProtoMessage() {}
 
-func (x *ApproveActionRequest) ProtoReflect() protoreflect.Message {
+func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1758,53 +2314,45 @@ func (x *ApproveActionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ApproveActionRequest.ProtoReflect.Descriptor instead.
-func (*ApproveActionRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ListFilesResponse.ProtoReflect.Descriptor instead.
+func (*ListFilesResponse) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{29}
 }
 
-func (x *ApproveActionRequest) GetApprovalId() string {
-	if x != nil {
-		return x.ApprovalId
-	}
-	return ""
-}
-
-func (x *ApproveActionRequest) GetApproved() bool {
-	if x != nil {
-		return x.Approved
-	}
-	return false
-}
-
-func (x *ApproveActionRequest) GetReason() string {
+func (x *ListFilesResponse) GetFiles() []*FileEntry {
 	if x != nil {
-		return x.Reason
+		return x.Files
 	}
-	return ""
+	return nil
 }
 
-type ApproveActionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+type FileEntry struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Path     string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Modified int64                  `protobuf:"varint,2,opt,name=modified,proto3" json:"modified,omitempty"`
+	// Entry type (file/dir/symlink/other) and size carried by the single
+	// ListFiles RPC so clients can build a directory listing without a
+	// per-entry stat round trip (KIP-20 perf).
+	Type          string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Size          int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ApproveActionResponse) Reset() {
-	*x = ApproveActionResponse{}
+func (x *FileEntry) Reset() {
+	*x = FileEntry{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ApproveActionResponse) String() string {
+func (x *FileEntry) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ApproveActionResponse) ProtoMessage() {}
+func (*FileEntry) ProtoMessage() {}
 
-func (x *ApproveActionResponse) ProtoReflect() protoreflect.Message {
+func (x *FileEntry) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1816,41 +2364,61 @@ func (x *ApproveActionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ApproveActionResponse.ProtoReflect.Descriptor instead.
-func (*ApproveActionResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
+func (*FileEntry) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *ApproveActionResponse) GetOk() bool {
+func (x *FileEntry) GetPath() string {
 	if x != nil {
-		return x.Ok
+		return x.Path
 	}
-	return false
+	return ""
 }
 
-type LoginRequest struct {
+func (x *FileEntry) GetModified() int64 {
+	if x != nil {
+		return x.Modified
+	}
+	return 0
+}
+
+func (x *FileEntry) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *FileEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type PipInstallRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Csr           string                 `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`                                          // PEM-encoded PKCS#10 certificate signing request
-	DeviceName    string                 `protobuf:"bytes,2,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`          // e.g. hostname, for audit logging
-	ClientVersion string                 `protobuf:"bytes,3,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"` // k8e version, for audit logging
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Packages      []string               `protobuf:"bytes,2,rep,name=packages,proto3" json:"packages,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LoginRequest) Reset() {
-	*x = LoginRequest{}
+func (x *PipInstallRequest) Reset() {
+	*x = PipInstallRequest{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LoginRequest) String() string {
+func (x *PipInstallRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LoginRequest) ProtoMessage() {}
+func (*PipInstallRequest) ProtoMessage() {}
 
-func (x *LoginRequest) ProtoReflect() protoreflect.Message {
+func (x *PipInstallRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1862,55 +2430,47 @@ func (x *LoginRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
-func (*LoginRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use PipInstallRequest.ProtoReflect.Descriptor instead.
+func (*PipInstallRequest) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *LoginRequest) GetCsr() string {
-	if x != nil {
-		return x.Csr
-	}
-	return ""
-}
-
-func (x *LoginRequest) GetDeviceName() string {
+func (x *PipInstallRequest) GetSessionId() string {
 	if x != nil {
-		return x.DeviceName
+		return x.SessionId
 	}
 	return ""
 }
 
-func (x *LoginRequest) GetClientVersion() string {
+func (x *PipInstallRequest) GetPackages() []string {
 	if x != nil {
-		return x.ClientVersion
+		return x.Packages
 	}
-	return ""
+	return nil
 }
 
-type LoginResponse struct {
+type PipInstallResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Cert          string                 `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`                             // PEM-encoded signed client certificate
-	CaCert        string                 `protobuf:"bytes,2,opt,name=ca_cert,json=caCert,proto3" json:"ca_cert,omitempty"`           // PEM-encoded sandbox CA certificate (trust anchor)
-	ValidDays     int64                  `protobuf:"varint,3,opt,name=valid_days,json=validDays,proto3" json:"valid_days,omitempty"` // days the certificate is valid
+	Output        string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	ExitCode      int32                  `protobuf:"varint,2,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LoginResponse) Reset() {
-	*x = LoginResponse{}
+func (x *PipInstallResponse) Reset() {
+	*x = PipInstallResponse{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LoginResponse) String() string {
+func (x *PipInstallResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LoginResponse) ProtoMessage() {}
+func (*PipInstallResponse) ProtoMessage() {}
 
-func (x *LoginResponse) ProtoReflect() protoreflect.Message {
+func (x *PipInstallResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1922,53 +2482,48 @@ func (x *LoginResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
-func (*LoginResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use PipInstallResponse.ProtoReflect.Descriptor instead.
+func (*PipInstallResponse) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *LoginResponse) GetCert() string {
-	if x != nil {
-		return x.Cert
-	}
-	return ""
-}
-
-func (x *LoginResponse) GetCaCert() string {
+func (x *PipInstallResponse) GetOutput() string {
 	if x != nil {
-		return x.CaCert
+		return x.Output
 	}
 	return ""
 }
 
-func (x *LoginResponse) GetValidDays() int64 {
+func (x *PipInstallResponse) GetExitCode() int32 {
 	if x != nil {
-		return x.ValidDays
+		return x.ExitCode
 	}
 	return 0
 }
 
-type PollRunRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type RunSubAgentRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParentSessionId string                 `protobuf:"bytes,1,opt,name=parent_session_id,json=parentSessionId,proto3" json:"parent_session_id,omitempty"`
+	AgentType       string                 `protobuf:"bytes,2,opt,name=agent_type,json=agentType,proto3" json:"agent_type,omitempty"`
+	WorkspacePath   string                 `protobuf:"bytes,3,opt,name=workspace_path,json=workspacePath,proto3" json:"workspace_path,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
-func (x *PollRunRequest) Reset() {
-	*x = PollRunRequest{}
+func (x *RunSubAgentRequest) Reset() {
+	*x = RunSubAgentRequest{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PollRunRequest) String() string {
+func (x *RunSubAgentRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PollRunRequest) ProtoMessage() {}
+func (*RunSubAgentRequest) ProtoMessage() {}
 
-func (x *PollRunRequest) ProtoReflect() protoreflect.Message {
+func (x *RunSubAgentRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1980,46 +2535,53 @@ func (x *PollRunRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PollRunRequest.ProtoReflect.Descriptor instead.
-func (*PollRunRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use RunSubAgentRequest.ProtoReflect.Descriptor instead.
+func (*RunSubAgentRequest) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *PollRunRequest) GetRunId() string {
+func (x *RunSubAgentRequest) GetParentSessionId() string {
 	if x != nil {
-		return x.RunId
+		return x.ParentSessionId
 	}
 	return ""
 }
 
-type PollRunResponse struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	RunId string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
-	// started|running|completed|failed|timed_out|not_found
-	Status        string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
-	Stdout        string `protobuf:"bytes,3,opt,name=stdout,proto3" json:"stdout,omitempty"`
-	Stderr        string `protobuf:"bytes,4,opt,name=stderr,proto3" json:"stderr,omitempty"`
-	ExitCode      int32  `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	DurationMs    int64  `protobuf:"varint,6,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
-	Truncated     bool   `protobuf:"varint,7,opt,name=truncated,proto3" json:"truncated,omitempty"`
+func (x *RunSubAgentRequest) GetAgentType() string {
+	if x != nil {
+		return x.AgentType
+	}
+	return ""
+}
+
+func (x *RunSubAgentRequest) GetWorkspacePath() string {
+	if x != nil {
+		return x.WorkspacePath
+	}
+	return ""
+}
+
+type RunSubAgentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PollRunResponse) Reset() {
-	*x = PollRunResponse{}
+func (x *RunSubAgentResponse) Reset() {
+	*x = RunSubAgentResponse{}
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PollRunResponse) String() string {
+func (x *RunSubAgentResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PollRunResponse) ProtoMessage() {}
+func (*RunSubAgentResponse) ProtoMessage() {}
 
-func (x *PollRunResponse) ProtoReflect() protoreflect.Message {
+func (x *RunSubAgentResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_sandbox_v1_sandbox_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -2031,87 +2593,1909 @@ func (x *PollRunResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PollRunResponse.ProtoReflect.Descriptor instead.
-func (*PollRunResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use RunSubAgentResponse.ProtoReflect.Descriptor instead.
+func (*RunSubAgentResponse) Descriptor() ([]byte, []int) {
 	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *PollRunResponse) GetRunId() string {
+func (x *RunSubAgentResponse) GetSessionId() string {
 	if x != nil {
-		return x.RunId
+		return x.SessionId
 	}
 	return ""
 }
 
-func (x *PollRunResponse) GetStatus() string {
-	if x != nil {
-		return x.Status
-	}
-	return ""
+type ConfirmActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Action        string                 `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	ApprovalId    string                 `protobuf:"bytes,3,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PollRunResponse) GetStdout() string {
+func (x *ConfirmActionRequest) Reset() {
+	*x = ConfirmActionRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmActionRequest) ProtoMessage() {}
+
+func (x *ConfirmActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmActionRequest.ProtoReflect.Descriptor instead.
+func (*ConfirmActionRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ConfirmActionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ConfirmActionRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ConfirmActionRequest) GetApprovalId() string {
+	if x != nil {
+		return x.ApprovalId
+	}
+	return ""
+}
+
+type ConfirmActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApprovalId    string                 `protobuf:"bytes,1,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
+	Approved      bool                   `protobuf:"varint,2,opt,name=approved,proto3" json:"approved,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmActionResponse) Reset() {
+	*x = ConfirmActionResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmActionResponse) ProtoMessage() {}
+
+func (x *ConfirmActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmActionResponse.ProtoReflect.Descriptor instead.
+func (*ConfirmActionResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ConfirmActionResponse) GetApprovalId() string {
+	if x != nil {
+		return x.ApprovalId
+	}
+	return ""
+}
+
+func (x *ConfirmActionResponse) GetApproved() bool {
+	if x != nil {
+		return x.Approved
+	}
+	return false
+}
+
+type ApproveActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApprovalId    string                 `protobuf:"bytes,1,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
+	Approved      bool                   `protobuf:"varint,2,opt,name=approved,proto3" json:"approved,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApproveActionRequest) Reset() {
+	*x = ApproveActionRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApproveActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApproveActionRequest) ProtoMessage() {}
+
+func (x *ApproveActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApproveActionRequest.ProtoReflect.Descriptor instead.
+func (*ApproveActionRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ApproveActionRequest) GetApprovalId() string {
+	if x != nil {
+		return x.ApprovalId
+	}
+	return ""
+}
+
+func (x *ApproveActionRequest) GetApproved() bool {
+	if x != nil {
+		return x.Approved
+	}
+	return false
+}
+
+func (x *ApproveActionRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ApproveActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApproveActionResponse) Reset() {
+	*x = ApproveActionResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApproveActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApproveActionResponse) ProtoMessage() {}
+
+func (x *ApproveActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApproveActionResponse.ProtoReflect.Descriptor instead.
+func (*ApproveActionResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ApproveActionResponse) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+// Approval is one human-in-the-loop decision (SandboxApproval CRD).
+type Approval struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApprovalId    string                 `protobuf:"bytes,1,opt,name=approval_id,json=approvalId,proto3" json:"approval_id,omitempty"`
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Action        string                 `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	Requester     string                 `protobuf:"bytes,5,opt,name=requester,proto3" json:"requester,omitempty"`
+	Phase         string                 `protobuf:"bytes,6,opt,name=phase,proto3" json:"phase,omitempty"` // Pending | Approved | Denied | Expired
+	DecidedBy     string                 `protobuf:"bytes,7,opt,name=decided_by,json=decidedBy,proto3" json:"decided_by,omitempty"`
+	Reason        string                 `protobuf:"bytes,8,opt,name=reason,proto3" json:"reason,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`  // unix seconds
+	DecidedAt     int64                  `protobuf:"varint,10,opt,name=decided_at,json=decidedAt,proto3" json:"decided_at,omitempty"` // unix seconds; 0 while pending
+	ExpiresAt     int64                  `protobuf:"varint,11,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // unix seconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Approval) Reset() {
+	*x = Approval{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Approval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Approval) ProtoMessage() {}
+
+func (x *Approval) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Approval.ProtoReflect.Descriptor instead.
+func (*Approval) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *Approval) GetApprovalId() string {
+	if x != nil {
+		return x.ApprovalId
+	}
+	return ""
+}
+
+func (x *Approval) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Approval) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *Approval) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *Approval) GetRequester() string {
+	if x != nil {
+		return x.Requester
+	}
+	return ""
+}
+
+func (x *Approval) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *Approval) GetDecidedBy() string {
+	if x != nil {
+		return x.DecidedBy
+	}
+	return ""
+}
+
+func (x *Approval) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Approval) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Approval) GetDecidedAt() int64 {
+	if x != nil {
+		return x.DecidedAt
+	}
+	return 0
+}
+
+func (x *Approval) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type ListApprovalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // optional filter
+	Phase         string                 `protobuf:"bytes,2,opt,name=phase,proto3" json:"phase,omitempty"`                          // optional filter, e.g. "Pending"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListApprovalsRequest) Reset() {
+	*x = ListApprovalsRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListApprovalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListApprovalsRequest) ProtoMessage() {}
+
+func (x *ListApprovalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListApprovalsRequest.ProtoReflect.Descriptor instead.
+func (*ListApprovalsRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *ListApprovalsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ListApprovalsRequest) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+type ListApprovalsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Approvals     []*Approval            `protobuf:"bytes,1,rep,name=approvals,proto3" json:"approvals,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListApprovalsResponse) Reset() {
+	*x = ListApprovalsResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListApprovalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListApprovalsResponse) ProtoMessage() {}
+
+func (x *ListApprovalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListApprovalsResponse.ProtoReflect.Descriptor instead.
+func (*ListApprovalsResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ListApprovalsResponse) GetApprovals() []*Approval {
+	if x != nil {
+		return x.Approvals
+	}
+	return nil
+}
+
+type WatchApprovalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // optional filter
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchApprovalsRequest) Reset() {
+	*x = WatchApprovalsRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchApprovalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchApprovalsRequest) ProtoMessage() {}
+
+func (x *WatchApprovalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchApprovalsRequest.ProtoReflect.Descriptor instead.
+func (*WatchApprovalsRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *WatchApprovalsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+// AuditRecord is one audited call. Commands and paths are never stored in
+// plaintext: target is "sha256:<hex>" of the value.
+type AuditRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          int64                  `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`              // unix milliseconds
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`           // "grpc" or "e2b"
+	Caller        string                 `protobuf:"bytes,3,opt,name=caller,proto3" json:"caller,omitempty"`           // API key name (cert CN), "local", or e2b key fingerprint
+	Fingerprint   string                 `protobuf:"bytes,4,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"` // client certificate SHA-256, when presented
+	TenantId      string                 `protobuf:"bytes,5,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	SessionId     string                 `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Method        string                 `protobuf:"bytes,7,opt,name=method,proto3" json:"method,omitempty"`
+	Outcome       string                 `protobuf:"bytes,8,opt,name=outcome,proto3" json:"outcome,omitempty"` // gRPC code name or HTTP status
+	LatencyMs     int64                  `protobuf:"varint,9,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	Target        string                 `protobuf:"bytes,10,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditRecord) Reset() {
+	*x = AuditRecord{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditRecord) ProtoMessage() {}
+
+func (x *AuditRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditRecord.ProtoReflect.Descriptor instead.
+func (*AuditRecord) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *AuditRecord) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *AuditRecord) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *AuditRecord) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type QueryAuditRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Since         int64                  `protobuf:"varint,1,opt,name=since,proto3" json:"since,omitempty"` // unix seconds; 0 = no lower bound
+	Until         int64                  `protobuf:"varint,2,opt,name=until,proto3" json:"until,omitempty"` // unix seconds; 0 = no upper bound
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Caller        string                 `protobuf:"bytes,4,opt,name=caller,proto3" json:"caller,omitempty"`
+	TenantId      string                 `protobuf:"bytes,5,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	SessionId     string                 `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Method        string                 `protobuf:"bytes,7,opt,name=method,proto3" json:"method,omitempty"` // substring match
+	Target        string                 `protobuf:"bytes,8,opt,name=target,proto3" json:"target,omitempty"` // plaintext command or path, hashed before matching
+	Limit         int32                  `protobuf:"varint,9,opt,name=limit,proto3" json:"limit,omitempty"`  // newest N matches; 0 = server default (1000)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryAuditRequest) Reset() {
+	*x = QueryAuditRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryAuditRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryAuditRequest) ProtoMessage() {}
+
+func (x *QueryAuditRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryAuditRequest.ProtoReflect.Descriptor instead.
+func (*QueryAuditRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *QueryAuditRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *QueryAuditRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *QueryAuditRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type QueryAuditResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*AuditRecord         `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Node          string                 `protobuf:"bytes,2,opt,name=node,proto3" json:"node,omitempty"` // the node whose audit log answered
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryAuditResponse) Reset() {
+	*x = QueryAuditResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryAuditResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryAuditResponse) ProtoMessage() {}
+
+func (x *QueryAuditResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryAuditResponse.ProtoReflect.Descriptor instead.
+func (*QueryAuditResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *QueryAuditResponse) GetRecords() []*AuditRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *QueryAuditResponse) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+// SessionUsage is one session's consumption: a ledger record for a session
+// that ended, or the live figures of an active one.
+type SessionUsage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SessionId       string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	TenantId        string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Active          bool                   `protobuf:"varint,3,opt,name=active,proto3" json:"active,omitempty"` // still running; ended_at is 0
+	Reason          string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`  // "destroyed" or "expired"
+	RuntimeClass    string                 `protobuf:"bytes,5,opt,name=runtime_class,json=runtimeClass,proto3" json:"runtime_class,omitempty"`
+	Template        string                 `protobuf:"bytes,6,opt,name=template,proto3" json:"template,omitempty"`
+	StartedAt       int64                  `protobuf:"varint,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`    // unix seconds
+	EndedAt         int64                  `protobuf:"varint,8,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`          // unix seconds
+	DurationMs      int64                  `protobuf:"varint,9,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // wall-clock lifetime
+	CpuSeconds      float64                `protobuf:"fixed64,10,opt,name=cpu_seconds,json=cpuSeconds,proto3" json:"cpu_seconds,omitempty"`
+	MemoryPeakBytes int64                  `protobuf:"varint,11,opt,name=memory_peak_bytes,json=memoryPeakBytes,proto3" json:"memory_peak_bytes,omitempty"`
+	MemoryAvgBytes  int64                  `protobuf:"varint,12,opt,name=memory_avg_bytes,json=memoryAvgBytes,proto3" json:"memory_avg_bytes,omitempty"`
+	EgressBytes     int64                  `protobuf:"varint,13,opt,name=egress_bytes,json=egressBytes,proto3" json:"egress_bytes,omitempty"`
+	ExecCount       int64                  `protobuf:"varint,14,opt,name=exec_count,json=execCount,proto3" json:"exec_count,omitempty"`
+	ExecMs          int64                  `protobuf:"varint,15,opt,name=exec_ms,json=execMs,proto3" json:"exec_ms,omitempty"`
+	SnapshotBytes   int64                  `protobuf:"varint,16,opt,name=snapshot_bytes,json=snapshotBytes,proto3" json:"snapshot_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SessionUsage) Reset() {
+	*x = SessionUsage{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionUsage) ProtoMessage() {}
+
+func (x *SessionUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionUsage.ProtoReflect.Descriptor instead.
+func (*SessionUsage) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *SessionUsage) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SessionUsage) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *SessionUsage) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *SessionUsage) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SessionUsage) GetRuntimeClass() string {
+	if x != nil {
+		return x.RuntimeClass
+	}
+	return ""
+}
+
+func (x *SessionUsage) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *SessionUsage) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetEndedAt() int64 {
+	if x != nil {
+		return x.EndedAt
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetCpuSeconds() float64 {
+	if x != nil {
+		return x.CpuSeconds
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetMemoryPeakBytes() int64 {
+	if x != nil {
+		return x.MemoryPeakBytes
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetMemoryAvgBytes() int64 {
+	if x != nil {
+		return x.MemoryAvgBytes
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetEgressBytes() int64 {
+	if x != nil {
+		return x.EgressBytes
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetExecCount() int64 {
+	if x != nil {
+		return x.ExecCount
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetExecMs() int64 {
+	if x != nil {
+		return x.ExecMs
+	}
+	return 0
+}
+
+func (x *SessionUsage) GetSnapshotBytes() int64 {
+	if x != nil {
+		return x.SnapshotBytes
+	}
+	return 0
+}
+
+// TenantUsage sums the returned sessions of one tenant.
+type TenantUsage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	TenantId        string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Sessions        int32                  `protobuf:"varint,2,opt,name=sessions,proto3" json:"sessions,omitempty"`
+	DurationMs      int64                  `protobuf:"varint,3,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	CpuSeconds      float64                `protobuf:"fixed64,4,opt,name=cpu_seconds,json=cpuSeconds,proto3" json:"cpu_seconds,omitempty"`
+	MemoryPeakBytes int64                  `protobuf:"varint,5,opt,name=memory_peak_bytes,json=memoryPeakBytes,proto3" json:"memory_peak_bytes,omitempty"` // largest single-session peak
+	EgressBytes     int64                  `protobuf:"varint,6,opt,name=egress_bytes,json=egressBytes,proto3" json:"egress_bytes,omitempty"`
+	ExecCount       int64                  `protobuf:"varint,7,opt,name=exec_count,json=execCount,proto3" json:"exec_count,omitempty"`
+	ExecMs          int64                  `protobuf:"varint,8,opt,name=exec_ms,json=execMs,proto3" json:"exec_ms,omitempty"`
+	SnapshotBytes   int64                  `protobuf:"varint,9,opt,name=snapshot_bytes,json=snapshotBytes,proto3" json:"snapshot_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *TenantUsage) Reset() {
+	*x = TenantUsage{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TenantUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TenantUsage) ProtoMessage() {}
+
+func (x *TenantUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TenantUsage.ProtoReflect.Descriptor instead.
+func (*TenantUsage) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *TenantUsage) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *TenantUsage) GetSessions() int32 {
+	if x != nil {
+		return x.Sessions
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetCpuSeconds() float64 {
+	if x != nil {
+		return x.CpuSeconds
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetMemoryPeakBytes() int64 {
+	if x != nil {
+		return x.MemoryPeakBytes
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetEgressBytes() int64 {
+	if x != nil {
+		return x.EgressBytes
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetExecCount() int64 {
+	if x != nil {
+		return x.ExecCount
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetExecMs() int64 {
+	if x != nil {
+		return x.ExecMs
+	}
+	return 0
+}
+
+func (x *TenantUsage) GetSnapshotBytes() int64 {
+	if x != nil {
+		return x.SnapshotBytes
+	}
+	return 0
+}
+
+type QueryUsageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TenantId      string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Since         int64                  `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`                                      // unix seconds, on the end time; 0 = no lower bound
+	Until         int64                  `protobuf:"varint,4,opt,name=until,proto3" json:"until,omitempty"`                                      // unix seconds; 0 = no upper bound
+	Limit         int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`                                      // newest N ledger records listed; 0 = server default (1000). Totals cover all matches.
+	IncludeActive bool                   `protobuf:"varint,6,opt,name=include_active,json=includeActive,proto3" json:"include_active,omitempty"` // also report sessions that have not ended
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryUsageRequest) Reset() {
+	*x = QueryUsageRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryUsageRequest) ProtoMessage() {}
+
+func (x *QueryUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryUsageRequest.ProtoReflect.Descriptor instead.
+func (*QueryUsageRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *QueryUsageRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *QueryUsageRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *QueryUsageRequest) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *QueryUsageRequest) GetUntil() int64 {
+	if x != nil {
+		return x.Until
+	}
+	return 0
+}
+
+func (x *QueryUsageRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *QueryUsageRequest) GetIncludeActive() bool {
+	if x != nil {
+		return x.IncludeActive
+	}
+	return false
+}
+
+type QueryUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sessions      []*SessionUsage        `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	Tenants       []*TenantUsage         `protobuf:"bytes,2,rep,name=tenants,proto3" json:"tenants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryUsageResponse) Reset() {
+	*x = QueryUsageResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryUsageResponse) ProtoMessage() {}
+
+func (x *QueryUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryUsageResponse.ProtoReflect.Descriptor instead.
+func (*QueryUsageResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *QueryUsageResponse) GetSessions() []*SessionUsage {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+func (x *QueryUsageResponse) GetTenants() []*TenantUsage {
+	if x != nil {
+		return x.Tenants
+	}
+	return nil
+}
+
+type LoginRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Csr           string                 `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`                                          // PEM-encoded PKCS#10 certificate signing request
+	DeviceName    string                 `protobuf:"bytes,2,opt,name=device_name,json=deviceName,proto3" json:"device_name,omitempty"`          // e.g. hostname, for audit logging
+	ClientVersion string                 `protobuf:"bytes,3,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"` // k8e version, for audit logging
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginRequest) Reset() {
+	*x = LoginRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginRequest) ProtoMessage() {}
+
+func (x *LoginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *LoginRequest) GetCsr() string {
+	if x != nil {
+		return x.Csr
+	}
+	return ""
+}
+
+func (x *LoginRequest) GetDeviceName() string {
+	if x != nil {
+		return x.DeviceName
+	}
+	return ""
+}
+
+func (x *LoginRequest) GetClientVersion() string {
+	if x != nil {
+		return x.ClientVersion
+	}
+	return ""
+}
+
+type LoginResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cert          string                 `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`                             // PEM-encoded signed client certificate
+	CaCert        string                 `protobuf:"bytes,2,opt,name=ca_cert,json=caCert,proto3" json:"ca_cert,omitempty"`           // PEM-encoded sandbox CA certificate (trust anchor)
+	ValidDays     int64                  `protobuf:"varint,3,opt,name=valid_days,json=validDays,proto3" json:"valid_days,omitempty"` // days the certificate is valid
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginResponse) Reset() {
+	*x = LoginResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginResponse) ProtoMessage() {}
+
+func (x *LoginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
+func (*LoginResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *LoginResponse) GetCert() string {
+	if x != nil {
+		return x.Cert
+	}
+	return ""
+}
+
+func (x *LoginResponse) GetCaCert() string {
+	if x != nil {
+		return x.CaCert
+	}
+	return ""
+}
+
+func (x *LoginResponse) GetValidDays() int64 {
+	if x != nil {
+		return x.ValidDays
+	}
+	return 0
+}
+
+type GetCRLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCRLRequest) Reset() {
+	*x = GetCRLRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCRLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCRLRequest) ProtoMessage() {}
+
+func (x *GetCRLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCRLRequest.ProtoReflect.Descriptor instead.
+func (*GetCRLRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{52}
+}
+
+type GetCRLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Crl           string                 `protobuf:"bytes,1,opt,name=crl,proto3" json:"crl,omitempty"`                                  // PEM-encoded X.509 CRL signed by the sandbox CA
+	ThisUpdate    int64                  `protobuf:"varint,2,opt,name=this_update,json=thisUpdate,proto3" json:"this_update,omitempty"` // unix seconds
+	NextUpdate    int64                  `protobuf:"varint,3,opt,name=next_update,json=nextUpdate,proto3" json:"next_update,omitempty"` // unix seconds; refetch after this
+	RevokedCount  int32                  `protobuf:"varint,4,opt,name=revoked_count,json=revokedCount,proto3" json:"revoked_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCRLResponse) Reset() {
+	*x = GetCRLResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCRLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCRLResponse) ProtoMessage() {}
+
+func (x *GetCRLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCRLResponse.ProtoReflect.Descriptor instead.
+func (*GetCRLResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *GetCRLResponse) GetCrl() string {
+	if x != nil {
+		return x.Crl
+	}
+	return ""
+}
+
+func (x *GetCRLResponse) GetThisUpdate() int64 {
+	if x != nil {
+		return x.ThisUpdate
+	}
+	return 0
+}
+
+func (x *GetCRLResponse) GetNextUpdate() int64 {
+	if x != nil {
+		return x.NextUpdate
+	}
+	return 0
+}
+
+func (x *GetCRLResponse) GetRevokedCount() int32 {
+	if x != nil {
+		return x.RevokedCount
+	}
+	return 0
+}
+
+type PollRunRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PollRunRequest) Reset() {
+	*x = PollRunRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PollRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PollRunRequest) ProtoMessage() {}
+
+func (x *PollRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PollRunRequest.ProtoReflect.Descriptor instead.
+func (*PollRunRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *PollRunRequest) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+type PollRunResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	RunId string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	// started|running|completed|failed|timed_out|not_found
+	Status        string     `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Stdout        string     `protobuf:"bytes,3,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr        string     `protobuf:"bytes,4,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ExitCode      int32      `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	DurationMs    int64      `protobuf:"varint,6,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	Truncated     bool       `protobuf:"varint,7,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	OutputRef     *OutputRef `protobuf:"bytes,8,opt,name=output_ref,json=outputRef,proto3" json:"output_ref,omitempty"` // set when truncated
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PollRunResponse) Reset() {
+	*x = PollRunResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PollRunResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PollRunResponse) ProtoMessage() {}
+
+func (x *PollRunResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PollRunResponse.ProtoReflect.Descriptor instead.
+func (*PollRunResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *PollRunResponse) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *PollRunResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PollRunResponse) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *PollRunResponse) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+func (x *PollRunResponse) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *PollRunResponse) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *PollRunResponse) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *PollRunResponse) GetOutputRef() *OutputRef {
+	if x != nil {
+		return x.OutputRef
+	}
+	return nil
+}
+
+// GetTranscriptRequest reads a file-backed exec transcript window.
+type GetTranscriptRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Byte offset to start reading from. 0 = start of transcript.
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Max bytes to return in this window. 0 = server default (64 KiB).
+	Limit         int64 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTranscriptRequest) Reset() {
+	*x = GetTranscriptRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptRequest) ProtoMessage() {}
+
+func (x *GetTranscriptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptRequest.ProtoReflect.Descriptor instead.
+func (*GetTranscriptRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *GetTranscriptRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *GetTranscriptRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetTranscriptRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetTranscriptResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SessionId       string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Output          string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`                                           // window content, line-aligned
+	Offset          int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`                                          // absolute offset this window starts at
+	NextOffset      int64                  `protobuf:"varint,4,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`                // offset for the next window; == file size at EOF
+	TruncatedBefore bool                   `protobuf:"varint,5,opt,name=truncated_before,json=truncatedBefore,proto3" json:"truncated_before,omitempty"` // true when the window starts mid-file (lines were skipped)
+	Eof             bool                   `protobuf:"varint,6,opt,name=eof,proto3" json:"eof,omitempty"`                                                // true when next_offset reached the end of the transcript
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GetTranscriptResponse) Reset() {
+	*x = GetTranscriptResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptResponse) ProtoMessage() {}
+
+func (x *GetTranscriptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptResponse.ProtoReflect.Descriptor instead.
+func (*GetTranscriptResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *GetTranscriptResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *GetTranscriptResponse) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *GetTranscriptResponse) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetTranscriptResponse) GetNextOffset() int64 {
+	if x != nil {
+		return x.NextOffset
+	}
+	return 0
+}
+
+func (x *GetTranscriptResponse) GetTruncatedBefore() bool {
+	if x != nil {
+		return x.TruncatedBefore
+	}
+	return false
+}
+
+func (x *GetTranscriptResponse) GetEof() bool {
+	if x != nil {
+		return x.Eof
+	}
+	return false
+}
+
+// GetRunOutputRequest reads one window of a run's full stdout or stderr.
+type GetRunOutputRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional for background runs the gateway still tracks; required for
+	// sync exec run ids.
+	SessionId     string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	RunId         string `protobuf:"bytes,2,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Stream        string `protobuf:"bytes,3,opt,name=stream,proto3" json:"stream,omitempty"`  // stdout (default) | stderr
+	Offset        int64  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"` // absolute byte offset
+	Limit         int64  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`   // max bytes; 0 = server default (256 KiB), capped at 1 MiB
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRunOutputRequest) Reset() {
+	*x = GetRunOutputRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRunOutputRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRunOutputRequest) ProtoMessage() {}
+
+func (x *GetRunOutputRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRunOutputRequest.ProtoReflect.Descriptor instead.
+func (*GetRunOutputRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *GetRunOutputRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *GetRunOutputRequest) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *GetRunOutputRequest) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *GetRunOutputRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetRunOutputRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetRunOutputResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RunId         string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Stream        string                 `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                                // byte-exact window
+	Offset        int64                  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`                           // absolute offset data starts at
+	NextOffset    int64                  `protobuf:"varint,5,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"` // offset for the next window
+	Size          int64                  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`                               // current stream size
+	Eof           bool                   `protobuf:"varint,7,opt,name=eof,proto3" json:"eof,omitempty"`                                 // next_offset reached size
+	Running       bool                   `protobuf:"varint,8,opt,name=running,proto3" json:"running,omitempty"`                         // background run still running; size may grow
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRunOutputResponse) Reset() {
+	*x = GetRunOutputResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRunOutputResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRunOutputResponse) ProtoMessage() {}
+
+func (x *GetRunOutputResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRunOutputResponse.ProtoReflect.Descriptor instead.
+func (*GetRunOutputResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *GetRunOutputResponse) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *GetRunOutputResponse) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *GetRunOutputResponse) GetData() []byte {
 	if x != nil {
-		return x.Stdout
+		return x.Data
 	}
-	return ""
+	return nil
 }
 
-func (x *PollRunResponse) GetStderr() string {
+func (x *GetRunOutputResponse) GetOffset() int64 {
 	if x != nil {
-		return x.Stderr
+		return x.Offset
 	}
-	return ""
+	return 0
 }
 
-func (x *PollRunResponse) GetExitCode() int32 {
+func (x *GetRunOutputResponse) GetNextOffset() int64 {
 	if x != nil {
-		return x.ExitCode
+		return x.NextOffset
 	}
 	return 0
 }
 
-func (x *PollRunResponse) GetDurationMs() int64 {
+func (x *GetRunOutputResponse) GetSize() int64 {
 	if x != nil {
-		return x.DurationMs
+		return x.Size
 	}
 	return 0
 }
 
-func (x *PollRunResponse) GetTruncated() bool {
+func (x *GetRunOutputResponse) GetEof() bool {
 	if x != nil {
-		return x.Truncated
+		return x.Eof
 	}
 	return false
 }
 
-// GetTranscriptRequest reads a file-backed exec transcript window.
-type GetTranscriptRequest struct {
-	state     protoimpl.MessageState `protogen:"open.v1"`
-	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	// Byte offset to start reading from. 0 = start of transcript.
-	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
-	// Max bytes to return in this window. 0 = server default (64 KiB).
-	Limit         int64 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+func (x *GetRunOutputResponse) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+// GetEventsRequest reads the daemon NDJSON event stream (KIP-16 M5).
+type GetEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty = all events (daemon-wide). When set, only events for this session.
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Max events to return in this call (0 = server default 500).
+	Limit         int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetTranscriptRequest) Reset() {
-	*x = GetTranscriptRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[35]
+func (x *GetEventsRequest) Reset() {
+	*x = GetEventsRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetTranscriptRequest) String() string {
+func (x *GetEventsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetTranscriptRequest) ProtoMessage() {}
+func (*GetEventsRequest) ProtoMessage() {}
 
-func (x *GetTranscriptRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[35]
+func (x *GetEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2122,59 +4506,213 @@ func (x *GetTranscriptRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetTranscriptRequest.ProtoReflect.Descriptor instead.
-func (*GetTranscriptRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{35}
+// Deprecated: Use GetEventsRequest.ProtoReflect.Descriptor instead.
+func (*GetEventsRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{60}
 }
 
-func (x *GetTranscriptRequest) GetSessionId() string {
+func (x *GetEventsRequest) GetSessionId() string {
 	if x != nil {
 		return x.SessionId
 	}
 	return ""
 }
 
-func (x *GetTranscriptRequest) GetOffset() int64 {
+func (x *GetEventsRequest) GetLimit() int64 {
 	if x != nil {
-		return x.Offset
+		return x.Limit
 	}
 	return 0
 }
 
-func (x *GetTranscriptRequest) GetLimit() int64 {
+type GetEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []string               `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`        // raw NDJSON lines
+	Truncated     bool                   `protobuf:"varint,2,opt,name=truncated,proto3" json:"truncated,omitempty"` // more events exist beyond the returned window
+	Returned      int64                  `protobuf:"varint,3,opt,name=returned,proto3" json:"returned,omitempty"`   // number of lines returned
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEventsResponse) Reset() {
+	*x = GetEventsResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEventsResponse) ProtoMessage() {}
+
+func (x *GetEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[61]
 	if x != nil {
-		return x.Limit
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEventsResponse.ProtoReflect.Descriptor instead.
+func (*GetEventsResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *GetEventsResponse) GetEvents() []string {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *GetEventsResponse) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *GetEventsResponse) GetReturned() int64 {
+	if x != nil {
+		return x.Returned
 	}
 	return 0
 }
 
-type GetTranscriptResponse struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	SessionId       string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	Output          string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`                                           // window content, line-aligned
-	Offset          int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`                                          // absolute offset this window starts at
-	NextOffset      int64                  `protobuf:"varint,4,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`                // offset for the next window; == file size at EOF
-	TruncatedBefore bool                   `protobuf:"varint,5,opt,name=truncated_before,json=truncatedBefore,proto3" json:"truncated_before,omitempty"` // true when the window starts mid-file (lines were skipped)
-	Eof             bool                   `protobuf:"varint,6,opt,name=eof,proto3" json:"eof,omitempty"`                                                // true when next_offset reached the end of the transcript
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+// SnapshotPutRequest stores one content-addressed layer (or a whole chunked
+// manifest) in the server-side registry.
+type SnapshotPutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`     // snapshot name (manifest id)
+	Layers        []string               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"` // layer digests to publish as this snapshot's manifest
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetTranscriptResponse) Reset() {
-	*x = GetTranscriptResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[36]
+func (x *SnapshotPutRequest) Reset() {
+	*x = SnapshotPutRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetTranscriptResponse) String() string {
+func (x *SnapshotPutRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetTranscriptResponse) ProtoMessage() {}
+func (*SnapshotPutRequest) ProtoMessage() {}
 
-func (x *GetTranscriptResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[36]
+func (x *SnapshotPutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[62]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotPutRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotPutRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{62}
+}
+
+func (x *SnapshotPutRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SnapshotPutRequest) GetLayers() []string {
+	if x != nil {
+		return x.Layers
+	}
+	return nil
+}
+
+type SnapshotPutResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Layers        int64                  `protobuf:"varint,2,opt,name=layers,proto3" json:"layers,omitempty"` // number of layers published
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotPutResponse) Reset() {
+	*x = SnapshotPutResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[63]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotPutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotPutResponse) ProtoMessage() {}
+
+func (x *SnapshotPutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[63]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotPutResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotPutResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{63}
+}
+
+func (x *SnapshotPutResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SnapshotPutResponse) GetLayers() int64 {
+	if x != nil {
+		return x.Layers
+	}
+	return 0
+}
+
+type SnapshotGetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // manifest name
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotGetRequest) Reset() {
+	*x = SnapshotGetRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotGetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotGetRequest) ProtoMessage() {}
+
+func (x *SnapshotGetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[64]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2185,79 +4723,91 @@ func (x *GetTranscriptResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetTranscriptResponse.ProtoReflect.Descriptor instead.
-func (*GetTranscriptResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{36}
+// Deprecated: Use SnapshotGetRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotGetRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{64}
 }
 
-func (x *GetTranscriptResponse) GetSessionId() string {
+func (x *SnapshotGetRequest) GetName() string {
 	if x != nil {
-		return x.SessionId
+		return x.Name
 	}
 	return ""
 }
 
-func (x *GetTranscriptResponse) GetOutput() string {
-	if x != nil {
-		return x.Output
-	}
-	return ""
+type SnapshotGetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Layers        []string               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"` // ordered layer digests of the manifest
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetTranscriptResponse) GetOffset() int64 {
-	if x != nil {
-		return x.Offset
-	}
-	return 0
+func (x *SnapshotGetResponse) Reset() {
+	*x = SnapshotGetResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[65]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetTranscriptResponse) GetNextOffset() int64 {
+func (x *SnapshotGetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotGetResponse) ProtoMessage() {}
+
+func (x *SnapshotGetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[65]
 	if x != nil {
-		return x.NextOffset
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-func (x *GetTranscriptResponse) GetTruncatedBefore() bool {
+// Deprecated: Use SnapshotGetResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotGetResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{65}
+}
+
+func (x *SnapshotGetResponse) GetName() string {
 	if x != nil {
-		return x.TruncatedBefore
+		return x.Name
 	}
-	return false
+	return ""
 }
 
-func (x *GetTranscriptResponse) GetEof() bool {
+func (x *SnapshotGetResponse) GetLayers() []string {
 	if x != nil {
-		return x.Eof
+		return x.Layers
 	}
-	return false
+	return nil
 }
 
-// GetEventsRequest reads the daemon NDJSON event stream (KIP-16 M5).
-type GetEventsRequest struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Empty = all events (daemon-wide). When set, only events for this session.
-	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	// Max events to return in this call (0 = server default 500).
-	Limit         int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+type SnapshotListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetEventsRequest) Reset() {
-	*x = GetEventsRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[37]
+func (x *SnapshotListRequest) Reset() {
+	*x = SnapshotListRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[66]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetEventsRequest) String() string {
+func (x *SnapshotListRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetEventsRequest) ProtoMessage() {}
+func (*SnapshotListRequest) ProtoMessage() {}
 
-func (x *GetEventsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[37]
+func (x *SnapshotListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[66]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2268,49 +4818,40 @@ func (x *GetEventsRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetEventsRequest.ProtoReflect.Descriptor instead.
-func (*GetEventsRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{37}
-}
-
-func (x *GetEventsRequest) GetSessionId() string {
-	if x != nil {
-		return x.SessionId
-	}
-	return ""
-}
-
-func (x *GetEventsRequest) GetLimit() int64 {
-	if x != nil {
-		return x.Limit
-	}
-	return 0
+// Deprecated: Use SnapshotListRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotListRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{66}
 }
 
-type GetEventsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Events        []string               `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`        // raw NDJSON lines
-	Truncated     bool                   `protobuf:"varint,2,opt,name=truncated,proto3" json:"truncated,omitempty"` // more events exist beyond the returned window
-	Returned      int64                  `protobuf:"varint,3,opt,name=returned,proto3" json:"returned,omitempty"`   // number of lines returned
+type SnapshotListResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Names []string               `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"` // manifest names
+	// Registry dedup: logical_bytes is every manifest's payload summed,
+	// unique_bytes each distinct layer once (both uncompressed);
+	// dedup_ratio = logical_bytes / unique_bytes. stored_bytes is on disk.
+	StoredBytes   int64   `protobuf:"varint,2,opt,name=stored_bytes,json=storedBytes,proto3" json:"stored_bytes,omitempty"`
+	LogicalBytes  int64   `protobuf:"varint,3,opt,name=logical_bytes,json=logicalBytes,proto3" json:"logical_bytes,omitempty"`
+	UniqueBytes   int64   `protobuf:"varint,4,opt,name=unique_bytes,json=uniqueBytes,proto3" json:"unique_bytes,omitempty"`
+	DedupRatio    float64 `protobuf:"fixed64,5,opt,name=dedup_ratio,json=dedupRatio,proto3" json:"dedup_ratio,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetEventsResponse) Reset() {
-	*x = GetEventsResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[38]
+func (x *SnapshotListResponse) Reset() {
+	*x = SnapshotListResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[67]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetEventsResponse) String() string {
+func (x *SnapshotListResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetEventsResponse) ProtoMessage() {}
+func (*SnapshotListResponse) ProtoMessage() {}
 
-func (x *GetEventsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[38]
+func (x *SnapshotListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[67]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2321,57 +4862,72 @@ func (x *GetEventsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetEventsResponse.ProtoReflect.Descriptor instead.
-func (*GetEventsResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{38}
+// Deprecated: Use SnapshotListResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotListResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{67}
 }
 
-func (x *GetEventsResponse) GetEvents() []string {
+func (x *SnapshotListResponse) GetNames() []string {
 	if x != nil {
-		return x.Events
+		return x.Names
 	}
 	return nil
 }
 
-func (x *GetEventsResponse) GetTruncated() bool {
+func (x *SnapshotListResponse) GetStoredBytes() int64 {
 	if x != nil {
-		return x.Truncated
+		return x.StoredBytes
 	}
-	return false
+	return 0
 }
 
-func (x *GetEventsResponse) GetReturned() int64 {
+func (x *SnapshotListResponse) GetLogicalBytes() int64 {
 	if x != nil {
-		return x.Returned
+		return x.LogicalBytes
 	}
 	return 0
 }
 
-// SnapshotPutRequest stores one content-addressed layer (or a whole chunked
-// manifest) in the server-side registry.
-type SnapshotPutRequest struct {
+func (x *SnapshotListResponse) GetUniqueBytes() int64 {
+	if x != nil {
+		return x.UniqueBytes
+	}
+	return 0
+}
+
+func (x *SnapshotListResponse) GetDedupRatio() float64 {
+	if x != nil {
+		return x.DedupRatio
+	}
+	return 0
+}
+
+// SnapshotSessionRequest archives the session's /workspace into the
+// server-side registry under name.
+type SnapshotSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`     // snapshot name (manifest id)
-	Layers        []string               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"` // layer digests to publish as this snapshot's manifest
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`        // manifest name ([A-Za-z0-9._-])
+	Timeout       int32                  `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"` // archive timeout in seconds (0 = 600)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SnapshotPutRequest) Reset() {
-	*x = SnapshotPutRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[39]
+func (x *SnapshotSessionRequest) Reset() {
+	*x = SnapshotSessionRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[68]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotPutRequest) String() string {
+func (x *SnapshotSessionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotPutRequest) ProtoMessage() {}
+func (*SnapshotSessionRequest) ProtoMessage() {}
 
-func (x *SnapshotPutRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[39]
+func (x *SnapshotSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[68]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2382,48 +4938,57 @@ func (x *SnapshotPutRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotPutRequest.ProtoReflect.Descriptor instead.
-func (*SnapshotPutRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{39}
+// Deprecated: Use SnapshotSessionRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotSessionRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{68}
 }
 
-func (x *SnapshotPutRequest) GetName() string {
+func (x *SnapshotSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SnapshotSessionRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *SnapshotPutRequest) GetLayers() []string {
+func (x *SnapshotSessionRequest) GetTimeout() int32 {
 	if x != nil {
-		return x.Layers
+		return x.Timeout
 	}
-	return nil
+	return 0
 }
 
-type SnapshotPutResponse struct {
+type SnapshotSessionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Layers        int64                  `protobuf:"varint,2,opt,name=layers,proto3" json:"layers,omitempty"` // number of layers published
+	Layers        []string               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"`                         // ordered layer digests of the new manifest
+	SizeBytes     int64                  `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"` // uncompressed archive size
+	FileCount     int64                  `protobuf:"varint,4,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"` // tar entries archived
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SnapshotPutResponse) Reset() {
-	*x = SnapshotPutResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[40]
+func (x *SnapshotSessionResponse) Reset() {
+	*x = SnapshotSessionResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[69]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotPutResponse) String() string {
+func (x *SnapshotSessionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotPutResponse) ProtoMessage() {}
+func (*SnapshotSessionResponse) ProtoMessage() {}
 
-func (x *SnapshotPutResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[40]
+func (x *SnapshotSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[69]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2434,47 +4999,67 @@ func (x *SnapshotPutResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotPutResponse.ProtoReflect.Descriptor instead.
-func (*SnapshotPutResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{40}
+// Deprecated: Use SnapshotSessionResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotSessionResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{69}
 }
 
-func (x *SnapshotPutResponse) GetName() string {
+func (x *SnapshotSessionResponse) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *SnapshotPutResponse) GetLayers() int64 {
+func (x *SnapshotSessionResponse) GetLayers() []string {
 	if x != nil {
 		return x.Layers
 	}
+	return nil
+}
+
+func (x *SnapshotSessionResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
 	return 0
 }
 
-type SnapshotGetRequest struct {
+func (x *SnapshotSessionResponse) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+// RestoreSessionRequest unpacks snapshot name into the session's
+// /workspace. With base set, layers shared with base that are already in
+// the sandbox's layer cache are not transferred again.
+type RestoreSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // manifest name
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Base          string                 `protobuf:"bytes,3,opt,name=base,proto3" json:"base,omitempty"`        // optional base snapshot previously restored here
+	Timeout       int32                  `protobuf:"varint,4,opt,name=timeout,proto3" json:"timeout,omitempty"` // extract timeout in seconds (0 = 600)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SnapshotGetRequest) Reset() {
-	*x = SnapshotGetRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[41]
+func (x *RestoreSessionRequest) Reset() {
+	*x = RestoreSessionRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[70]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotGetRequest) String() string {
+func (x *RestoreSessionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotGetRequest) ProtoMessage() {}
+func (*RestoreSessionRequest) ProtoMessage() {}
 
-func (x *SnapshotGetRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[41]
+func (x *RestoreSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[70]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2485,41 +5070,64 @@ func (x *SnapshotGetRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotGetRequest.ProtoReflect.Descriptor instead.
-func (*SnapshotGetRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{41}
+// Deprecated: Use RestoreSessionRequest.ProtoReflect.Descriptor instead.
+func (*RestoreSessionRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{70}
 }
 
-func (x *SnapshotGetRequest) GetName() string {
+func (x *RestoreSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *RestoreSessionRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-type SnapshotGetResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Layers        []string               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"` // ordered layer digests of the manifest
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *RestoreSessionRequest) GetBase() string {
+	if x != nil {
+		return x.Base
+	}
+	return ""
 }
 
-func (x *SnapshotGetResponse) Reset() {
-	*x = SnapshotGetResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[42]
+func (x *RestoreSessionRequest) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+type RestoreSessionResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Layers            int64                  `protobuf:"varint,2,opt,name=layers,proto3" json:"layers,omitempty"`                                                // layers in the restored manifest
+	TransferredLayers int64                  `protobuf:"varint,3,opt,name=transferred_layers,json=transferredLayers,proto3" json:"transferred_layers,omitempty"` // layers shipped to the sandbox
+	TransferredBytes  int64                  `protobuf:"varint,4,opt,name=transferred_bytes,json=transferredBytes,proto3" json:"transferred_bytes,omitempty"`    // uncompressed bytes shipped
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RestoreSessionResponse) Reset() {
+	*x = RestoreSessionResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[71]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotGetResponse) String() string {
+func (x *RestoreSessionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotGetResponse) ProtoMessage() {}
+func (*RestoreSessionResponse) ProtoMessage() {}
 
-func (x *SnapshotGetResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[42]
+func (x *RestoreSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[71]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2530,46 +5138,66 @@ func (x *SnapshotGetResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotGetResponse.ProtoReflect.Descriptor instead.
-func (*SnapshotGetResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{42}
+// Deprecated: Use RestoreSessionResponse.ProtoReflect.Descriptor instead.
+func (*RestoreSessionResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{71}
 }
 
-func (x *SnapshotGetResponse) GetName() string {
+func (x *RestoreSessionResponse) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *SnapshotGetResponse) GetLayers() []string {
+func (x *RestoreSessionResponse) GetLayers() int64 {
 	if x != nil {
 		return x.Layers
 	}
-	return nil
+	return 0
 }
 
-type SnapshotListRequest struct {
+func (x *RestoreSessionResponse) GetTransferredLayers() int64 {
+	if x != nil {
+		return x.TransferredLayers
+	}
+	return 0
+}
+
+func (x *RestoreSessionResponse) GetTransferredBytes() int64 {
+	if x != nil {
+		return x.TransferredBytes
+	}
+	return 0
+}
+
+// ForkSessionRequest clones session_id into count children. The fork is
+// all-or-nothing: if any child fails, the children already created are
+// destroyed.
+type ForkSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`     // children to create (0 = 1, max 16)
+	Timeout       int32                  `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"` // snapshot/restore timeout in seconds (0 = 300)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SnapshotListRequest) Reset() {
-	*x = SnapshotListRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[43]
+func (x *ForkSessionRequest) Reset() {
+	*x = ForkSessionRequest{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[72]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotListRequest) String() string {
+func (x *ForkSessionRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotListRequest) ProtoMessage() {}
+func (*ForkSessionRequest) ProtoMessage() {}
 
-func (x *SnapshotListRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[43]
+func (x *ForkSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[72]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2580,33 +5208,56 @@ func (x *SnapshotListRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotListRequest.ProtoReflect.Descriptor instead.
-func (*SnapshotListRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{43}
+// Deprecated: Use ForkSessionRequest.ProtoReflect.Descriptor instead.
+func (*ForkSessionRequest) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{72}
 }
 
-type SnapshotListResponse struct {
+func (x *ForkSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ForkSessionRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ForkSessionRequest) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+type ForkSessionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Names         []string               `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"` // manifest names
+	SessionIds    []string               `protobuf:"bytes,1,rep,name=session_ids,json=sessionIds,proto3" json:"session_ids,omitempty"` // children, in fork_index order
+	SizeBytes     int64                  `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`   // parent workspace archive size
+	LatencyMs     int64                  `protobuf:"varint,3,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`   // snapshot to last child hydrated
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SnapshotListResponse) Reset() {
-	*x = SnapshotListResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[44]
+func (x *ForkSessionResponse) Reset() {
+	*x = ForkSessionResponse{}
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[73]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SnapshotListResponse) String() string {
+func (x *ForkSessionResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SnapshotListResponse) ProtoMessage() {}
+func (*ForkSessionResponse) ProtoMessage() {}
 
-func (x *SnapshotListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[44]
+func (x *ForkSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[73]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2617,18 +5268,32 @@ func (x *SnapshotListResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SnapshotListResponse.ProtoReflect.Descriptor instead.
-func (*SnapshotListResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{44}
+// Deprecated: Use ForkSessionResponse.ProtoReflect.Descriptor instead.
+func (*ForkSessionResponse) Descriptor() ([]byte, []int) {
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{73}
 }
 
-func (x *SnapshotListResponse) GetNames() []string {
+func (x *ForkSessionResponse) GetSessionIds() []string {
 	if x != nil {
-		return x.Names
+		return x.SessionIds
 	}
 	return nil
 }
 
+func (x *ForkSessionResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *ForkSessionResponse) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
 // GetProcessesRequest lists processes visible in the sandbox pod's pid
 // namespace (KIP-16 M5 follow-up: namespace-identity process topology).
 type GetProcessesRequest struct {
@@ -2640,7 +5305,7 @@ type GetProcessesRequest struct {
 
 func (x *GetProcessesRequest) Reset() {
 	*x = GetProcessesRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[45]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[74]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2652,7 +5317,7 @@ func (x *GetProcessesRequest) String() string {
 func (*GetProcessesRequest) ProtoMessage() {}
 
 func (x *GetProcessesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[45]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[74]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2665,7 +5330,7 @@ func (x *GetProcessesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetProcessesRequest.ProtoReflect.Descriptor instead.
 func (*GetProcessesRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{45}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{74}
 }
 
 func (x *GetProcessesRequest) GetSessionId() string {
@@ -2686,7 +5351,7 @@ type ProcessInfo struct {
 
 func (x *ProcessInfo) Reset() {
 	*x = ProcessInfo{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[46]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[75]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2698,7 +5363,7 @@ func (x *ProcessInfo) String() string {
 func (*ProcessInfo) ProtoMessage() {}
 
 func (x *ProcessInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[46]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[75]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2711,7 +5376,7 @@ func (x *ProcessInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProcessInfo.ProtoReflect.Descriptor instead.
 func (*ProcessInfo) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{46}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{75}
 }
 
 func (x *ProcessInfo) GetPid() int32 {
@@ -2744,7 +5409,7 @@ type GetProcessesResponse struct {
 
 func (x *GetProcessesResponse) Reset() {
 	*x = GetProcessesResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[47]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[76]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2756,7 +5421,7 @@ func (x *GetProcessesResponse) String() string {
 func (*GetProcessesResponse) ProtoMessage() {}
 
 func (x *GetProcessesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[47]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[76]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2769,7 +5434,7 @@ func (x *GetProcessesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetProcessesResponse.ProtoReflect.Descriptor instead.
 func (*GetProcessesResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{47}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{76}
 }
 
 func (x *GetProcessesResponse) GetProcesses() []*ProcessInfo {
@@ -2793,7 +5458,7 @@ type CreateTerminalRequest struct {
 
 func (x *CreateTerminalRequest) Reset() {
 	*x = CreateTerminalRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[48]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[77]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2805,7 +5470,7 @@ func (x *CreateTerminalRequest) String() string {
 func (*CreateTerminalRequest) ProtoMessage() {}
 
 func (x *CreateTerminalRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[48]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[77]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2818,7 +5483,7 @@ func (x *CreateTerminalRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateTerminalRequest.ProtoReflect.Descriptor instead.
 func (*CreateTerminalRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{48}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{77}
 }
 
 func (x *CreateTerminalRequest) GetSessionId() string {
@@ -2873,7 +5538,7 @@ type CreateTerminalResponse struct {
 
 func (x *CreateTerminalResponse) Reset() {
 	*x = CreateTerminalResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[49]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[78]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2885,7 +5550,7 @@ func (x *CreateTerminalResponse) String() string {
 func (*CreateTerminalResponse) ProtoMessage() {}
 
 func (x *CreateTerminalResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[49]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[78]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2898,7 +5563,7 @@ func (x *CreateTerminalResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateTerminalResponse.ProtoReflect.Descriptor instead.
 func (*CreateTerminalResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{49}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{78}
 }
 
 func (x *CreateTerminalResponse) GetTerminalId() string {
@@ -2924,7 +5589,7 @@ type TerminalStreamRequest struct {
 
 func (x *TerminalStreamRequest) Reset() {
 	*x = TerminalStreamRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[50]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[79]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2936,7 +5601,7 @@ func (x *TerminalStreamRequest) String() string {
 func (*TerminalStreamRequest) ProtoMessage() {}
 
 func (x *TerminalStreamRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[50]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[79]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2949,7 +5614,7 @@ func (x *TerminalStreamRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalStreamRequest.ProtoReflect.Descriptor instead.
 func (*TerminalStreamRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{50}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{79}
 }
 
 func (x *TerminalStreamRequest) GetTerminalId() string {
@@ -2972,7 +5637,7 @@ type TerminalStreamResponse struct {
 
 func (x *TerminalStreamResponse) Reset() {
 	*x = TerminalStreamResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[51]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[80]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2984,7 +5649,7 @@ func (x *TerminalStreamResponse) String() string {
 func (*TerminalStreamResponse) ProtoMessage() {}
 
 func (x *TerminalStreamResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[51]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[80]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2997,7 +5662,7 @@ func (x *TerminalStreamResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalStreamResponse.ProtoReflect.Descriptor instead.
 func (*TerminalStreamResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{51}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{80}
 }
 
 func (x *TerminalStreamResponse) GetFrame() isTerminalStreamResponse_Frame {
@@ -3051,7 +5716,7 @@ type TerminalExit struct {
 
 func (x *TerminalExit) Reset() {
 	*x = TerminalExit{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[52]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[81]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3063,7 +5728,7 @@ func (x *TerminalExit) String() string {
 func (*TerminalExit) ProtoMessage() {}
 
 func (x *TerminalExit) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[52]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[81]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3076,7 +5741,7 @@ func (x *TerminalExit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalExit.ProtoReflect.Descriptor instead.
 func (*TerminalExit) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{52}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{81}
 }
 
 func (x *TerminalExit) GetExitCode() int32 {
@@ -3103,7 +5768,7 @@ type TerminalWriteRequest struct {
 
 func (x *TerminalWriteRequest) Reset() {
 	*x = TerminalWriteRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[53]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[82]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3115,7 +5780,7 @@ func (x *TerminalWriteRequest) String() string {
 func (*TerminalWriteRequest) ProtoMessage() {}
 
 func (x *TerminalWriteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[53]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[82]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3128,7 +5793,7 @@ func (x *TerminalWriteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalWriteRequest.ProtoReflect.Descriptor instead.
 func (*TerminalWriteRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{53}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{82}
 }
 
 func (x *TerminalWriteRequest) GetTerminalId() string {
@@ -3154,7 +5819,7 @@ type TerminalWriteResponse struct {
 
 func (x *TerminalWriteResponse) Reset() {
 	*x = TerminalWriteResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[54]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[83]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3166,7 +5831,7 @@ func (x *TerminalWriteResponse) String() string {
 func (*TerminalWriteResponse) ProtoMessage() {}
 
 func (x *TerminalWriteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[54]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[83]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3179,7 +5844,7 @@ func (x *TerminalWriteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalWriteResponse.ProtoReflect.Descriptor instead.
 func (*TerminalWriteResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{54}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{83}
 }
 
 func (x *TerminalWriteResponse) GetOk() bool {
@@ -3200,7 +5865,7 @@ type TerminalResizeRequest struct {
 
 func (x *TerminalResizeRequest) Reset() {
 	*x = TerminalResizeRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[55]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[84]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3212,7 +5877,7 @@ func (x *TerminalResizeRequest) String() string {
 func (*TerminalResizeRequest) ProtoMessage() {}
 
 func (x *TerminalResizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[55]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[84]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3225,7 +5890,7 @@ func (x *TerminalResizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalResizeRequest.ProtoReflect.Descriptor instead.
 func (*TerminalResizeRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{55}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{84}
 }
 
 func (x *TerminalResizeRequest) GetTerminalId() string {
@@ -3258,7 +5923,7 @@ type TerminalResizeResponse struct {
 
 func (x *TerminalResizeResponse) Reset() {
 	*x = TerminalResizeResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[56]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[85]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3270,7 +5935,7 @@ func (x *TerminalResizeResponse) String() string {
 func (*TerminalResizeResponse) ProtoMessage() {}
 
 func (x *TerminalResizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[56]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[85]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3283,7 +5948,7 @@ func (x *TerminalResizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalResizeResponse.ProtoReflect.Descriptor instead.
 func (*TerminalResizeResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{56}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{85}
 }
 
 func (x *TerminalResizeResponse) GetOk() bool {
@@ -3302,7 +5967,7 @@ type TerminalForegroundRequest struct {
 
 func (x *TerminalForegroundRequest) Reset() {
 	*x = TerminalForegroundRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[57]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[86]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3314,7 +5979,7 @@ func (x *TerminalForegroundRequest) String() string {
 func (*TerminalForegroundRequest) ProtoMessage() {}
 
 func (x *TerminalForegroundRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[57]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[86]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3327,7 +5992,7 @@ func (x *TerminalForegroundRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalForegroundRequest.ProtoReflect.Descriptor instead.
 func (*TerminalForegroundRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{57}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{86}
 }
 
 func (x *TerminalForegroundRequest) GetTerminalId() string {
@@ -3347,7 +6012,7 @@ type TerminalForegroundResponse struct {
 
 func (x *TerminalForegroundResponse) Reset() {
 	*x = TerminalForegroundResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[58]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[87]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3359,7 +6024,7 @@ func (x *TerminalForegroundResponse) String() string {
 func (*TerminalForegroundResponse) ProtoMessage() {}
 
 func (x *TerminalForegroundResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[58]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[87]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3372,7 +6037,7 @@ func (x *TerminalForegroundResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalForegroundResponse.ProtoReflect.Descriptor instead.
 func (*TerminalForegroundResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{58}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{87}
 }
 
 func (x *TerminalForegroundResponse) GetProcessGroupId() int32 {
@@ -3399,7 +6064,7 @@ type TerminalSignalRequest struct {
 
 func (x *TerminalSignalRequest) Reset() {
 	*x = TerminalSignalRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[59]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[88]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3411,7 +6076,7 @@ func (x *TerminalSignalRequest) String() string {
 func (*TerminalSignalRequest) ProtoMessage() {}
 
 func (x *TerminalSignalRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[59]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[88]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3424,7 +6089,7 @@ func (x *TerminalSignalRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalSignalRequest.ProtoReflect.Descriptor instead.
 func (*TerminalSignalRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{59}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{88}
 }
 
 func (x *TerminalSignalRequest) GetTerminalId() string {
@@ -3450,7 +6115,7 @@ type TerminalSignalResponse struct {
 
 func (x *TerminalSignalResponse) Reset() {
 	*x = TerminalSignalResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[60]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[89]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3462,7 +6127,7 @@ func (x *TerminalSignalResponse) String() string {
 func (*TerminalSignalResponse) ProtoMessage() {}
 
 func (x *TerminalSignalResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[60]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[89]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3475,7 +6140,7 @@ func (x *TerminalSignalResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalSignalResponse.ProtoReflect.Descriptor instead.
 func (*TerminalSignalResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{60}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{89}
 }
 
 func (x *TerminalSignalResponse) GetProcessGroupId() int32 {
@@ -3495,7 +6160,7 @@ type TerminalDestroyRequest struct {
 
 func (x *TerminalDestroyRequest) Reset() {
 	*x = TerminalDestroyRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[61]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[90]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3507,7 +6172,7 @@ func (x *TerminalDestroyRequest) String() string {
 func (*TerminalDestroyRequest) ProtoMessage() {}
 
 func (x *TerminalDestroyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[61]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[90]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3520,7 +6185,7 @@ func (x *TerminalDestroyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalDestroyRequest.ProtoReflect.Descriptor instead.
 func (*TerminalDestroyRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{61}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{90}
 }
 
 func (x *TerminalDestroyRequest) GetTerminalId() string {
@@ -3546,7 +6211,7 @@ type TerminalDestroyResponse struct {
 
 func (x *TerminalDestroyResponse) Reset() {
 	*x = TerminalDestroyResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[62]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[91]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3558,7 +6223,7 @@ func (x *TerminalDestroyResponse) String() string {
 func (*TerminalDestroyResponse) ProtoMessage() {}
 
 func (x *TerminalDestroyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[62]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[91]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3571,7 +6236,7 @@ func (x *TerminalDestroyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalDestroyResponse.ProtoReflect.Descriptor instead.
 func (*TerminalDestroyResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{62}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{91}
 }
 
 func (x *TerminalDestroyResponse) GetOk() bool {
@@ -3586,13 +6251,15 @@ type ExposeServiceRequest struct {
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	Port          int32                  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"` // in-pod service port (required)
 	Host          string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`  // in-pod listen address; default 127.0.0.1
+	Access        ExposeAccess           `protobuf:"varint,4,opt,name=access,proto3,enum=sandbox.v1.ExposeAccess" json:"access,omitempty"`
+	TtlSeconds    int64                  `protobuf:"varint,5,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"` // signed URL lifetime; default 3600, max 604800
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ExposeServiceRequest) Reset() {
 	*x = ExposeServiceRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[63]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[92]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3604,7 +6271,7 @@ func (x *ExposeServiceRequest) String() string {
 func (*ExposeServiceRequest) ProtoMessage() {}
 
 func (x *ExposeServiceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[63]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[92]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3617,7 +6284,7 @@ func (x *ExposeServiceRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExposeServiceRequest.ProtoReflect.Descriptor instead.
 func (*ExposeServiceRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{63}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{92}
 }
 
 func (x *ExposeServiceRequest) GetSessionId() string {
@@ -3641,16 +6308,35 @@ func (x *ExposeServiceRequest) GetHost() string {
 	return ""
 }
 
+func (x *ExposeServiceRequest) GetAccess() ExposeAccess {
+	if x != nil {
+		return x.Access
+	}
+	return ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED
+}
+
+func (x *ExposeServiceRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
 type ExposeServiceResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"` // http(s)://<gateway>/k8e/expose/<session>/<port>/
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// http(s)://<gateway>/k8e/expose/<session>/<port>/, carrying the token
+	// (when just created) or the signature as query parameters.
+	Url           string       `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Access        ExposeAccess `protobuf:"varint,2,opt,name=access,proto3,enum=sandbox.v1.ExposeAccess" json:"access,omitempty"`
+	Token         string       `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`                           // token access: returned only by the call that created it
+	ExpiresAt     int64        `protobuf:"varint,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // signed access: unix seconds the URL stops working
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ExposeServiceResponse) Reset() {
 	*x = ExposeServiceResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[64]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[93]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3662,7 +6348,7 @@ func (x *ExposeServiceResponse) String() string {
 func (*ExposeServiceResponse) ProtoMessage() {}
 
 func (x *ExposeServiceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[64]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[93]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3675,7 +6361,7 @@ func (x *ExposeServiceResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExposeServiceResponse.ProtoReflect.Descriptor instead.
 func (*ExposeServiceResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{64}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{93}
 }
 
 func (x *ExposeServiceResponse) GetUrl() string {
@@ -3685,6 +6371,27 @@ func (x *ExposeServiceResponse) GetUrl() string {
 	return ""
 }
 
+func (x *ExposeServiceResponse) GetAccess() ExposeAccess {
+	if x != nil {
+		return x.Access
+	}
+	return ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED
+}
+
+func (x *ExposeServiceResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ExposeServiceResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
 type UnexposeServiceRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -3695,7 +6402,7 @@ type UnexposeServiceRequest struct {
 
 func (x *UnexposeServiceRequest) Reset() {
 	*x = UnexposeServiceRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[65]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[94]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3707,7 +6414,7 @@ func (x *UnexposeServiceRequest) String() string {
 func (*UnexposeServiceRequest) ProtoMessage() {}
 
 func (x *UnexposeServiceRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[65]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[94]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3720,7 +6427,7 @@ func (x *UnexposeServiceRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnexposeServiceRequest.ProtoReflect.Descriptor instead.
 func (*UnexposeServiceRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{65}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{94}
 }
 
 func (x *UnexposeServiceRequest) GetSessionId() string {
@@ -3746,7 +6453,7 @@ type UnexposeServiceResponse struct {
 
 func (x *UnexposeServiceResponse) Reset() {
 	*x = UnexposeServiceResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[66]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[95]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3758,7 +6465,7 @@ func (x *UnexposeServiceResponse) String() string {
 func (*UnexposeServiceResponse) ProtoMessage() {}
 
 func (x *UnexposeServiceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[66]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[95]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3771,7 +6478,7 @@ func (x *UnexposeServiceResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnexposeServiceResponse.ProtoReflect.Descriptor instead.
 func (*UnexposeServiceResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{66}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{95}
 }
 
 func (x *UnexposeServiceResponse) GetOk() bool {
@@ -3787,13 +6494,16 @@ type ExposedService struct {
 	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
 	Host          string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
 	StartedAt     int64                  `protobuf:"varint,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"` // unix seconds
+	Access        ExposeAccess           `protobuf:"varint,5,opt,name=access,proto3,enum=sandbox.v1.ExposeAccess" json:"access,omitempty"`
+	TokenSha256   string                 `protobuf:"bytes,6,opt,name=token_sha256,json=tokenSha256,proto3" json:"token_sha256,omitempty"` // token access: hex SHA-256 of the token, for the expose proxy
+	Nonce         string                 `protobuf:"bytes,7,opt,name=nonce,proto3" json:"nonce,omitempty"`                                // per-exposure nonce bound into signed URLs and proxy cookies
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ExposedService) Reset() {
 	*x = ExposedService{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[67]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[96]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3805,7 +6515,7 @@ func (x *ExposedService) String() string {
 func (*ExposedService) ProtoMessage() {}
 
 func (x *ExposedService) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[67]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[96]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3818,7 +6528,7 @@ func (x *ExposedService) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ExposedService.ProtoReflect.Descriptor instead.
 func (*ExposedService) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{67}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{96}
 }
 
 func (x *ExposedService) GetPort() int32 {
@@ -3849,6 +6559,27 @@ func (x *ExposedService) GetStartedAt() int64 {
 	return 0
 }
 
+func (x *ExposedService) GetAccess() ExposeAccess {
+	if x != nil {
+		return x.Access
+	}
+	return ExposeAccess_EXPOSE_ACCESS_UNSPECIFIED
+}
+
+func (x *ExposedService) GetTokenSha256() string {
+	if x != nil {
+		return x.TokenSha256
+	}
+	return ""
+}
+
+func (x *ExposedService) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
 type ListExposedRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -3858,7 +6589,7 @@ type ListExposedRequest struct {
 
 func (x *ListExposedRequest) Reset() {
 	*x = ListExposedRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[68]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[97]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3870,7 +6601,7 @@ func (x *ListExposedRequest) String() string {
 func (*ListExposedRequest) ProtoMessage() {}
 
 func (x *ListExposedRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[68]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[97]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3883,7 +6614,7 @@ func (x *ListExposedRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListExposedRequest.ProtoReflect.Descriptor instead.
 func (*ListExposedRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{68}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{97}
 }
 
 func (x *ListExposedRequest) GetSessionId() string {
@@ -3902,7 +6633,7 @@ type ListExposedResponse struct {
 
 func (x *ListExposedResponse) Reset() {
 	*x = ListExposedResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[69]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[98]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3914,7 +6645,7 @@ func (x *ListExposedResponse) String() string {
 func (*ListExposedResponse) ProtoMessage() {}
 
 func (x *ListExposedResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[69]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[98]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3927,7 +6658,7 @@ func (x *ListExposedResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListExposedResponse.ProtoReflect.Descriptor instead.
 func (*ListExposedResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{69}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{98}
 }
 
 func (x *ListExposedResponse) GetServices() []*ExposedService {
@@ -3941,13 +6672,14 @@ type UpdateAllowedHostsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	Hosts         []string               `protobuf:"bytes,2,rep,name=hosts,proto3" json:"hosts,omitempty"` // full replacement list; empty clears (falls back to matrix defaults)
+	Rules         []*EgressRule          `protobuf:"bytes,3,rep,name=rules,proto3" json:"rules,omitempty"` // full replacement of the structured rules
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateAllowedHostsRequest) Reset() {
 	*x = UpdateAllowedHostsRequest{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[70]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[99]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3959,7 +6691,7 @@ func (x *UpdateAllowedHostsRequest) String() string {
 func (*UpdateAllowedHostsRequest) ProtoMessage() {}
 
 func (x *UpdateAllowedHostsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[70]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[99]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3972,7 +6704,7 @@ func (x *UpdateAllowedHostsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateAllowedHostsRequest.ProtoReflect.Descriptor instead.
 func (*UpdateAllowedHostsRequest) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{70}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{99}
 }
 
 func (x *UpdateAllowedHostsRequest) GetSessionId() string {
@@ -3989,16 +6721,24 @@ func (x *UpdateAllowedHostsRequest) GetHosts() []string {
 	return nil
 }
 
+func (x *UpdateAllowedHostsRequest) GetRules() []*EgressRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
 type UpdateAllowedHostsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Hosts         []string               `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	Rules         []*EgressRule          `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateAllowedHostsResponse) Reset() {
 	*x = UpdateAllowedHostsResponse{}
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[71]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[100]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -4010,7 +6750,7 @@ func (x *UpdateAllowedHostsResponse) String() string {
 func (*UpdateAllowedHostsResponse) ProtoMessage() {}
 
 func (x *UpdateAllowedHostsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sandbox_v1_sandbox_proto_msgTypes[71]
+	mi := &file_sandbox_v1_sandbox_proto_msgTypes[100]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -4023,7 +6763,7 @@ func (x *UpdateAllowedHostsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateAllowedHostsResponse.ProtoReflect.Descriptor instead.
 func (*UpdateAllowedHostsResponse) Descriptor() ([]byte, []int) {
-	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{71}
+	return file_sandbox_v1_sandbox_proto_rawDescGZIP(), []int{100}
 }
 
 func (x *UpdateAllowedHostsResponse) GetHosts() []string {
@@ -4033,17 +6773,36 @@ func (x *UpdateAllowedHostsResponse) GetHosts() []string {
 	return nil
 }
 
+func (x *UpdateAllowedHostsResponse) GetRules() []*EgressRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
 var File_sandbox_v1_sandbox_proto protoreflect.FileDescriptor
 
 const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\n" +
 	"\x18sandbox/v1/sandbox.proto\x12\n" +
-	"sandbox.v1\"W\n" +
+	"sandbox.v1\"\x85\x01\n" +
 	"\tSecretRef\x12\x1f\n" +
 	"\vsecret_name\x18\x01 \x01(\tR\n" +
 	"secretName\x12\x10\n" +
 	"\x03key\x18\x02 \x01(\tR\x03key\x12\x17\n" +
-	"\aenv_var\x18\x03 \x01(\tR\x06envVar\"\xc9\x02\n" +
+	"\aenv_var\x18\x03 \x01(\tR\x06envVar\x12\x14\n" +
+	"\x05hosts\x18\x04 \x03(\tR\x05hosts\x12\x16\n" +
+	"\x06header\x18\x05 \x01(\tR\x06header\"\x96\x01\n" +
+	"\n" +
+	"EgressRule\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x12\n" +
+	"\x04cidr\x18\x02 \x01(\tR\x04cidr\x12\x14\n" +
+	"\x05ports\x18\x03 \x03(\x05R\x05ports\x12\x1a\n" +
+	"\bprotocol\x18\x04 \x01(\tR\bprotocol\x12.\n" +
+	"\x04http\x18\x05 \x03(\v2\x1a.sandbox.v1.EgressHTTPRuleR\x04http\"<\n" +
+	"\x0eEgressHTTPRule\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\xa0\x03\n" +
 	"\x14CreateSessionRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1b\n" +
@@ -4052,7 +6811,9 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\rruntime_class\x18\x04 \x01(\tR\fruntimeClass\x12;\n" +
 	"\x03env\x18\x05 \x03(\v2).sandbox.v1.CreateSessionRequest.EnvEntryR\x03env\x126\n" +
 	"\vsecret_refs\x18\x06 \x03(\v2\x15.sandbox.v1.SecretRefR\n" +
-	"secretRefs\x1a6\n" +
+	"secretRefs\x12\x1a\n" +
+	"\btemplate\x18\a \x01(\tR\btemplate\x129\n" +
+	"\fegress_rules\x18\b \x03(\v2\x16.sandbox.v1.EgressRuleR\vegressRules\x1a6\n" +
 	"\bEnvEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"M\n" +
@@ -4062,7 +6823,7 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\x06pod_ip\x18\x02 \x01(\tR\x05podIp\"2\n" +
 	"\x11GetSessionRequest\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x01 \x01(\tR\tsessionId\"\xd2\x02\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xe9\x03\n" +
 	"\x12GetSessionResponse\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
@@ -4076,26 +6837,64 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\x0fsecret_env_vars\x18\b \x03(\tR\rsecretEnvVars\x12'\n" +
 	"\x0fbackground_runs\x18\t \x01(\x05R\x0ebackgroundRuns\x12#\n" +
 	"\rallowed_hosts\x18\n" +
-	" \x03(\tR\fallowedHosts\"+\n" +
+	" \x03(\tR\fallowedHosts\x12\x1a\n" +
+	"\btemplate\x18\v \x01(\tR\btemplate\x12\x1f\n" +
+	"\vforked_from\x18\f \x01(\tR\n" +
+	"forkedFrom\x12\x1d\n" +
+	"\n" +
+	"fork_index\x18\r \x01(\x05R\tforkIndex\x129\n" +
+	"\fegress_rules\x18\x0e \x03(\v2\x16.sandbox.v1.EgressRuleR\vegressRules\"+\n" +
 	"\x13ListSessionsRequest\x12\x14\n" +
 	"\x05phase\x18\x01 \x01(\tR\x05phase\"R\n" +
 	"\x14ListSessionsResponse\x12:\n" +
-	"\bsessions\x18\x01 \x03(\v2\x1e.sandbox.v1.GetSessionResponseR\bsessions\"6\n" +
+	"\bsessions\x18\x01 \x03(\v2\x1e.sandbox.v1.GetSessionResponseR\bsessions\"\xa9\x01\n" +
+	"\x14WatchSessionsRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x122\n" +
+	"\x05types\x18\x03 \x03(\x0e2\x1c.sandbox.v1.SessionEventTypeR\x05types\x12!\n" +
+	"\fsend_initial\x18\x04 \x01(\bR\vsendInitial\"\x8b\x04\n" +
+	"\fSessionEvent\x120\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1c.sandbox.v1.SessionEventTypeR\x04type\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\x03R\x04time\x12\x14\n" +
+	"\x05phase\x18\x05 \x01(\tR\x05phase\x12%\n" +
+	"\x0eprevious_phase\x18\x06 \x01(\tR\rpreviousPhase\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\x03R\texpiresAt\x12\x15\n" +
+	"\x06run_id\x18\b \x01(\tR\x05runId\x12\x1d\n" +
+	"\n" +
+	"run_status\x18\t \x01(\tR\trunStatus\x12\x1b\n" +
+	"\texit_code\x18\n" +
+	" \x01(\x05R\bexitCode\x12\x12\n" +
+	"\x04port\x18\v \x01(\x05R\x04port\x12\x10\n" +
+	"\x03url\x18\f \x01(\tR\x03url\x12#\n" +
+	"\rallowed_hosts\x18\r \x03(\tR\fallowedHosts\x129\n" +
+	"\fegress_rules\x18\x0e \x03(\v2\x16.sandbox.v1.EgressRuleR\vegressRules\x12\x1f\n" +
+	"\vegress_host\x18\x0f \x01(\tR\n" +
+	"egressHost\x12#\n" +
+	"\regress_denied\x18\x10 \x01(\bR\fegressDenied\"6\n" +
 	"\x15DestroySessionRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\"(\n" +
 	"\x16DestroySessionResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"4\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\"_\n" +
 	"\x13PauseSessionRequest\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x01 \x01(\tR\tsessionId\"&\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12)\n" +
+	"\x04mode\x18\x02 \x01(\x0e2\x15.sandbox.v1.PauseModeR\x04mode\"m\n" +
 	"\x14PauseSessionResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"5\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12)\n" +
+	"\x04mode\x18\x02 \x01(\x0e2\x15.sandbox.v1.PauseModeR\x04mode\x12\x1a\n" +
+	"\bfallback\x18\x03 \x01(\tR\bfallback\"5\n" +
 	"\x14ResumeSessionRequest\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x01 \x01(\tR\tsessionId\"'\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"P\n" +
 	"\x15ResumeSessionResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"\xb6\x01\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12'\n" +
+	"\x0fmemory_restored\x18\x02 \x01(\bR\x0ememoryRestored\"\xb6\x01\n" +
 	"\vExecRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x18\n" +
@@ -4105,7 +6904,7 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\n" +
 	"background\x18\x05 \x01(\bR\n" +
 	"background\x12\x1a\n" +
-	"\blanguage\x18\x06 \x01(\tR\blanguage\"\x84\x02\n" +
+	"\blanguage\x18\x06 \x01(\tR\blanguage\"\xba\x02\n" +
 	"\fExecResponse\x12\x16\n" +
 	"\x06stdout\x18\x01 \x01(\tR\x06stdout\x12\x16\n" +
 	"\x06stderr\x18\x02 \x01(\tR\x06stderr\x12\x1b\n" +
@@ -4117,9 +6916,35 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\vduration_ms\x18\a \x01(\x03R\n" +
 	"durationMs\x12\x1c\n" +
 	"\ttruncated\x18\b \x01(\bR\ttruncated\x12\x1a\n" +
-	"\blanguage\x18\t \x01(\tR\blanguage\"*\n" +
+	"\blanguage\x18\t \x01(\tR\blanguage\x124\n" +
+	"\n" +
+	"output_ref\x18\n" +
+	" \x01(\v2\x15.sandbox.v1.OutputRefR\toutputRef\"\xb2\x01\n" +
+	"\tOutputRef\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12#\n" +
+	"\rstdout_offset\x18\x02 \x01(\x03R\fstdoutOffset\x12#\n" +
+	"\rstderr_offset\x18\x03 \x01(\x03R\fstderrOffset\x12!\n" +
+	"\fstdout_bytes\x18\x04 \x01(\x03R\vstdoutBytes\x12!\n" +
+	"\fstderr_bytes\x18\x05 \x01(\x03R\vstderrBytes\"*\n" +
 	"\x12ExecStreamResponse\x12\x14\n" +
-	"\x05chunk\x18\x01 \x01(\tR\x05chunk\"s\n" +
+	"\x05chunk\x18\x01 \x01(\tR\x05chunk\"t\n" +
+	"\tExecFrame\x12\x18\n" +
+	"\x06stdout\x18\x01 \x01(\fH\x00R\x06stdout\x12\x18\n" +
+	"\x06stderr\x18\x02 \x01(\fH\x00R\x06stderr\x12*\n" +
+	"\x04exit\x18\x03 \x01(\v2\x14.sandbox.v1.ExecExitH\x00R\x04exitB\a\n" +
+	"\x05frame\"t\n" +
+	"\bExecExit\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x16\n" +
+	"\x06signal\x18\x02 \x01(\x05R\x06signal\x12\x1b\n" +
+	"\ttimed_out\x18\x03 \x01(\bR\btimedOut\x12\x1f\n" +
+	"\vduration_ms\x18\x04 \x01(\x03R\n" +
+	"durationMs\"\x80\x01\n" +
+	"\tExecInput\x12/\n" +
+	"\x05start\x18\x01 \x01(\v2\x17.sandbox.v1.ExecRequestH\x00R\x05start\x12\x16\n" +
+	"\x05stdin\x18\x02 \x01(\fH\x00R\x05stdin\x12!\n" +
+	"\vclose_stdin\x18\x03 \x01(\bH\x00R\n" +
+	"closeStdinB\a\n" +
+	"\x05input\"s\n" +
 	"\x10WriteFileRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x12\n" +
@@ -4176,7 +7001,110 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\bapproved\x18\x02 \x01(\bR\bapproved\x12\x16\n" +
 	"\x06reason\x18\x03 \x01(\tR\x06reason\"'\n" +
 	"\x15ApproveActionResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"h\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\"\xc7\x02\n" +
+	"\bApproval\x12\x1f\n" +
+	"\vapproval_id\x18\x01 \x01(\tR\n" +
+	"approvalId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12\x16\n" +
+	"\x06action\x18\x04 \x01(\tR\x06action\x12\x1c\n" +
+	"\trequester\x18\x05 \x01(\tR\trequester\x12\x14\n" +
+	"\x05phase\x18\x06 \x01(\tR\x05phase\x12\x1d\n" +
+	"\n" +
+	"decided_by\x18\a \x01(\tR\tdecidedBy\x12\x16\n" +
+	"\x06reason\x18\b \x01(\tR\x06reason\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\t \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"decided_at\x18\n" +
+	" \x01(\x03R\tdecidedAt\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\v \x01(\x03R\texpiresAt\"K\n" +
+	"\x14ListApprovalsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05phase\x18\x02 \x01(\tR\x05phase\"K\n" +
+	"\x15ListApprovalsResponse\x122\n" +
+	"\tapprovals\x18\x01 \x03(\v2\x14.sandbox.v1.ApprovalR\tapprovals\"6\n" +
+	"\x15WatchApprovalsRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x98\x02\n" +
+	"\vAuditRecord\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\x03R\x04time\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x16\n" +
+	"\x06caller\x18\x03 \x01(\tR\x06caller\x12 \n" +
+	"\vfingerprint\x18\x04 \x01(\tR\vfingerprint\x12\x1b\n" +
+	"\ttenant_id\x18\x05 \x01(\tR\btenantId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\x12\x16\n" +
+	"\x06method\x18\a \x01(\tR\x06method\x12\x18\n" +
+	"\aoutcome\x18\b \x01(\tR\aoutcome\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\t \x01(\x03R\tlatencyMs\x12\x16\n" +
+	"\x06target\x18\n" +
+	" \x01(\tR\x06target\"\xf1\x01\n" +
+	"\x11QueryAuditRequest\x12\x14\n" +
+	"\x05since\x18\x01 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x02 \x01(\x03R\x05until\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x16\n" +
+	"\x06caller\x18\x04 \x01(\tR\x06caller\x12\x1b\n" +
+	"\ttenant_id\x18\x05 \x01(\tR\btenantId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\x12\x16\n" +
+	"\x06method\x18\a \x01(\tR\x06method\x12\x16\n" +
+	"\x06target\x18\b \x01(\tR\x06target\x12\x14\n" +
+	"\x05limit\x18\t \x01(\x05R\x05limit\"[\n" +
+	"\x12QueryAuditResponse\x121\n" +
+	"\arecords\x18\x01 \x03(\v2\x17.sandbox.v1.AuditRecordR\arecords\x12\x12\n" +
+	"\x04node\x18\x02 \x01(\tR\x04node\"\x8f\x04\n" +
+	"\fSessionUsage\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x16\n" +
+	"\x06active\x18\x03 \x01(\bR\x06active\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12#\n" +
+	"\rruntime_class\x18\x05 \x01(\tR\fruntimeClass\x12\x1a\n" +
+	"\btemplate\x18\x06 \x01(\tR\btemplate\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\a \x01(\x03R\tstartedAt\x12\x19\n" +
+	"\bended_at\x18\b \x01(\x03R\aendedAt\x12\x1f\n" +
+	"\vduration_ms\x18\t \x01(\x03R\n" +
+	"durationMs\x12\x1f\n" +
+	"\vcpu_seconds\x18\n" +
+	" \x01(\x01R\n" +
+	"cpuSeconds\x12*\n" +
+	"\x11memory_peak_bytes\x18\v \x01(\x03R\x0fmemoryPeakBytes\x12(\n" +
+	"\x10memory_avg_bytes\x18\f \x01(\x03R\x0ememoryAvgBytes\x12!\n" +
+	"\fegress_bytes\x18\r \x01(\x03R\vegressBytes\x12\x1d\n" +
+	"\n" +
+	"exec_count\x18\x0e \x01(\x03R\texecCount\x12\x17\n" +
+	"\aexec_ms\x18\x0f \x01(\x03R\x06execMs\x12%\n" +
+	"\x0esnapshot_bytes\x18\x10 \x01(\x03R\rsnapshotBytes\"\xb6\x02\n" +
+	"\vTenantUsage\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1a\n" +
+	"\bsessions\x18\x02 \x01(\x05R\bsessions\x12\x1f\n" +
+	"\vduration_ms\x18\x03 \x01(\x03R\n" +
+	"durationMs\x12\x1f\n" +
+	"\vcpu_seconds\x18\x04 \x01(\x01R\n" +
+	"cpuSeconds\x12*\n" +
+	"\x11memory_peak_bytes\x18\x05 \x01(\x03R\x0fmemoryPeakBytes\x12!\n" +
+	"\fegress_bytes\x18\x06 \x01(\x03R\vegressBytes\x12\x1d\n" +
+	"\n" +
+	"exec_count\x18\a \x01(\x03R\texecCount\x12\x17\n" +
+	"\aexec_ms\x18\b \x01(\x03R\x06execMs\x12%\n" +
+	"\x0esnapshot_bytes\x18\t \x01(\x03R\rsnapshotBytes\"\xb8\x01\n" +
+	"\x11QueryUsageRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x03R\x05since\x12\x14\n" +
+	"\x05until\x18\x04 \x01(\x03R\x05until\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\x12%\n" +
+	"\x0einclude_active\x18\x06 \x01(\bR\rincludeActive\"}\n" +
+	"\x12QueryUsageResponse\x124\n" +
+	"\bsessions\x18\x01 \x03(\v2\x18.sandbox.v1.SessionUsageR\bsessions\x121\n" +
+	"\atenants\x18\x02 \x03(\v2\x17.sandbox.v1.TenantUsageR\atenants\"h\n" +
 	"\fLoginRequest\x12\x10\n" +
 	"\x03csr\x18\x01 \x01(\tR\x03csr\x12\x1f\n" +
 	"\vdevice_name\x18\x02 \x01(\tR\n" +
@@ -4186,9 +7114,17 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\x04cert\x18\x01 \x01(\tR\x04cert\x12\x17\n" +
 	"\aca_cert\x18\x02 \x01(\tR\x06caCert\x12\x1d\n" +
 	"\n" +
-	"valid_days\x18\x03 \x01(\x03R\tvalidDays\"'\n" +
+	"valid_days\x18\x03 \x01(\x03R\tvalidDays\"\x0f\n" +
+	"\rGetCRLRequest\"\x89\x01\n" +
+	"\x0eGetCRLResponse\x12\x10\n" +
+	"\x03crl\x18\x01 \x01(\tR\x03crl\x12\x1f\n" +
+	"\vthis_update\x18\x02 \x01(\x03R\n" +
+	"thisUpdate\x12\x1f\n" +
+	"\vnext_update\x18\x03 \x01(\x03R\n" +
+	"nextUpdate\x12#\n" +
+	"\rrevoked_count\x18\x04 \x01(\x05R\frevokedCount\"'\n" +
 	"\x0ePollRunRequest\x12\x15\n" +
-	"\x06run_id\x18\x01 \x01(\tR\x05runId\"\xcc\x01\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\"\x82\x02\n" +
 	"\x0fPollRunResponse\x12\x15\n" +
 	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
@@ -4197,7 +7133,9 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\texit_code\x18\x05 \x01(\x05R\bexitCode\x12\x1f\n" +
 	"\vduration_ms\x18\x06 \x01(\x03R\n" +
 	"durationMs\x12\x1c\n" +
-	"\ttruncated\x18\a \x01(\bR\ttruncated\"c\n" +
+	"\ttruncated\x18\a \x01(\bR\ttruncated\x124\n" +
+	"\n" +
+	"output_ref\x18\b \x01(\v2\x15.sandbox.v1.OutputRefR\toutputRef\"c\n" +
 	"\x14GetTranscriptRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x16\n" +
@@ -4211,7 +7149,24 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\vnext_offset\x18\x04 \x01(\x03R\n" +
 	"nextOffset\x12)\n" +
 	"\x10truncated_before\x18\x05 \x01(\bR\x0ftruncatedBefore\x12\x10\n" +
-	"\x03eof\x18\x06 \x01(\bR\x03eof\"G\n" +
+	"\x03eof\x18\x06 \x01(\bR\x03eof\"\x91\x01\n" +
+	"\x13GetRunOutputRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x15\n" +
+	"\x06run_id\x18\x02 \x01(\tR\x05runId\x12\x16\n" +
+	"\x06stream\x18\x03 \x01(\tR\x06stream\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x03R\x05limit\"\xd2\x01\n" +
+	"\x14GetRunOutputResponse\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x16\n" +
+	"\x06stream\x18\x02 \x01(\tR\x06stream\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\x12\x1f\n" +
+	"\vnext_offset\x18\x05 \x01(\x03R\n" +
+	"nextOffset\x12\x12\n" +
+	"\x04size\x18\x06 \x01(\x03R\x04size\x12\x10\n" +
+	"\x03eof\x18\a \x01(\bR\x03eof\x12\x18\n" +
+	"\arunning\x18\b \x01(\bR\arunning\"G\n" +
 	"\x10GetEventsRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
@@ -4231,9 +7186,49 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\x13SnapshotGetResponse\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
 	"\x06layers\x18\x02 \x03(\tR\x06layers\"\x15\n" +
-	"\x13SnapshotListRequest\",\n" +
+	"\x13SnapshotListRequest\"\xb8\x01\n" +
 	"\x14SnapshotListResponse\x12\x14\n" +
-	"\x05names\x18\x01 \x03(\tR\x05names\"4\n" +
+	"\x05names\x18\x01 \x03(\tR\x05names\x12!\n" +
+	"\fstored_bytes\x18\x02 \x01(\x03R\vstoredBytes\x12#\n" +
+	"\rlogical_bytes\x18\x03 \x01(\x03R\flogicalBytes\x12!\n" +
+	"\funique_bytes\x18\x04 \x01(\x03R\vuniqueBytes\x12\x1f\n" +
+	"\vdedup_ratio\x18\x05 \x01(\x01R\n" +
+	"dedupRatio\"e\n" +
+	"\x16SnapshotSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\atimeout\x18\x03 \x01(\x05R\atimeout\"\x83\x01\n" +
+	"\x17SnapshotSessionResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06layers\x18\x02 \x03(\tR\x06layers\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x03 \x01(\x03R\tsizeBytes\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x04 \x01(\x03R\tfileCount\"x\n" +
+	"\x15RestoreSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04base\x18\x03 \x01(\tR\x04base\x12\x18\n" +
+	"\atimeout\x18\x04 \x01(\x05R\atimeout\"\xa0\x01\n" +
+	"\x16RestoreSessionResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06layers\x18\x02 \x01(\x03R\x06layers\x12-\n" +
+	"\x12transferred_layers\x18\x03 \x01(\x03R\x11transferredLayers\x12+\n" +
+	"\x11transferred_bytes\x18\x04 \x01(\x03R\x10transferredBytes\"c\n" +
+	"\x12ForkSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x18\n" +
+	"\atimeout\x18\x03 \x01(\x05R\atimeout\"t\n" +
+	"\x13ForkSessionResponse\x12\x1f\n" +
+	"\vsession_ids\x18\x01 \x03(\tR\n" +
+	"sessionIds\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x02 \x01(\x03R\tsizeBytes\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x03 \x01(\x03R\tlatencyMs\"4\n" +
 	"\x13GetProcessesRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\"I\n" +
@@ -4298,44 +7293,74 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"terminalId\x12\x19\n" +
 	"\bgrace_ms\x18\x02 \x01(\x05R\agraceMs\")\n" +
 	"\x17TerminalDestroyResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"]\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\"\xb0\x01\n" +
 	"\x14ExposeServiceRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x12\n" +
 	"\x04port\x18\x02 \x01(\x05R\x04port\x12\x12\n" +
-	"\x04host\x18\x03 \x01(\tR\x04host\")\n" +
+	"\x04host\x18\x03 \x01(\tR\x04host\x120\n" +
+	"\x06access\x18\x04 \x01(\x0e2\x18.sandbox.v1.ExposeAccessR\x06access\x12\x1f\n" +
+	"\vttl_seconds\x18\x05 \x01(\x03R\n" +
+	"ttlSeconds\"\x90\x01\n" +
 	"\x15ExposeServiceResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"K\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x120\n" +
+	"\x06access\x18\x02 \x01(\x0e2\x18.sandbox.v1.ExposeAccessR\x06access\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\x03R\texpiresAt\"K\n" +
 	"\x16UnexposeServiceRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x12\n" +
 	"\x04port\x18\x02 \x01(\x05R\x04port\")\n" +
 	"\x17UnexposeServiceResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok\"i\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\"\xd4\x01\n" +
 	"\x0eExposedService\x12\x12\n" +
 	"\x04port\x18\x01 \x01(\x05R\x04port\x12\x10\n" +
 	"\x03url\x18\x02 \x01(\tR\x03url\x12\x12\n" +
 	"\x04host\x18\x03 \x01(\tR\x04host\x12\x1d\n" +
 	"\n" +
-	"started_at\x18\x04 \x01(\x03R\tstartedAt\"3\n" +
+	"started_at\x18\x04 \x01(\x03R\tstartedAt\x120\n" +
+	"\x06access\x18\x05 \x01(\x0e2\x18.sandbox.v1.ExposeAccessR\x06access\x12!\n" +
+	"\ftoken_sha256\x18\x06 \x01(\tR\vtokenSha256\x12\x14\n" +
+	"\x05nonce\x18\a \x01(\tR\x05nonce\"3\n" +
 	"\x12ListExposedRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\"M\n" +
 	"\x13ListExposedResponse\x126\n" +
-	"\bservices\x18\x01 \x03(\v2\x1a.sandbox.v1.ExposedServiceR\bservices\"P\n" +
+	"\bservices\x18\x01 \x03(\v2\x1a.sandbox.v1.ExposedServiceR\bservices\"~\n" +
 	"\x19UpdateAllowedHostsRequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
-	"\x05hosts\x18\x02 \x03(\tR\x05hosts\"2\n" +
+	"\x05hosts\x18\x02 \x03(\tR\x05hosts\x12,\n" +
+	"\x05rules\x18\x03 \x03(\v2\x16.sandbox.v1.EgressRuleR\x05rules\"`\n" +
 	"\x1aUpdateAllowedHostsResponse\x12\x14\n" +
-	"\x05hosts\x18\x01 \x03(\tR\x05hosts*\xb1\x01\n" +
+	"\x05hosts\x18\x01 \x03(\tR\x05hosts\x12,\n" +
+	"\x05rules\x18\x02 \x03(\v2\x16.sandbox.v1.EgressRuleR\x05rules*\x95\x02\n" +
+	"\x10SessionEventType\x12\x1d\n" +
+	"\x19SESSION_EVENT_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13SESSION_EVENT_PHASE\x10\x01\x12\x19\n" +
+	"\x15SESSION_EVENT_DELETED\x10\x02\x12\x1a\n" +
+	"\x16SESSION_EVENT_EXPIRING\x10\x03\x12\x1f\n" +
+	"\x1bSESSION_EVENT_RUN_COMPLETED\x10\x04\x12\x19\n" +
+	"\x15SESSION_EVENT_EXPOSED\x10\x05\x12\x1b\n" +
+	"\x17SESSION_EVENT_UNEXPOSED\x10\x06\x12\x1f\n" +
+	"\x1bSESSION_EVENT_ALLOWED_HOSTS\x10\a\x12\x18\n" +
+	"\x14SESSION_EVENT_EGRESS\x10\b*=\n" +
+	"\tPauseMode\x12\x19\n" +
+	"\x15PAUSE_MODE_FILESYSTEM\x10\x00\x12\x15\n" +
+	"\x11PAUSE_MODE_MEMORY\x10\x01*\xb1\x01\n" +
 	"\x0eTerminalSignal\x12\x1f\n" +
 	"\x1bTERMINAL_SIGNAL_UNSPECIFIED\x10\x00\x12\x17\n" +
 	"\x13TERMINAL_SIGNAL_INT\x10\x01\x12\x18\n" +
 	"\x14TERMINAL_SIGNAL_TERM\x10\x02\x12\x18\n" +
 	"\x14TERMINAL_SIGNAL_KILL\x10\x03\x12\x18\n" +
 	"\x14TERMINAL_SIGNAL_TSTP\x10\x04\x12\x17\n" +
-	"\x13TERMINAL_SIGNAL_HUP\x10\x052\xfc\x15\n" +
+	"\x13TERMINAL_SIGNAL_HUP\x10\x05*z\n" +
+	"\fExposeAccess\x12\x1d\n" +
+	"\x19EXPOSE_ACCESS_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14EXPOSE_ACCESS_PUBLIC\x10\x01\x12\x17\n" +
+	"\x13EXPOSE_ACCESS_TOKEN\x10\x02\x12\x18\n" +
+	"\x14EXPOSE_ACCESS_SIGNED\x10\x032\xa8\x1d\n" +
 	"\x0eSandboxService\x12T\n" +
 	"\rCreateSession\x12 .sandbox.v1.CreateSessionRequest\x1a!.sandbox.v1.CreateSessionResponse\x12K\n" +
 	"\n" +
@@ -4343,10 +7368,13 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"\fListSessions\x12\x1f.sandbox.v1.ListSessionsRequest\x1a .sandbox.v1.ListSessionsResponse\x12W\n" +
 	"\x0eDestroySession\x12!.sandbox.v1.DestroySessionRequest\x1a\".sandbox.v1.DestroySessionResponse\x12Q\n" +
 	"\fPauseSession\x12\x1f.sandbox.v1.PauseSessionRequest\x1a .sandbox.v1.PauseSessionResponse\x12T\n" +
-	"\rResumeSession\x12 .sandbox.v1.ResumeSessionRequest\x1a!.sandbox.v1.ResumeSessionResponse\x129\n" +
+	"\rResumeSession\x12 .sandbox.v1.ResumeSessionRequest\x1a!.sandbox.v1.ResumeSessionResponse\x12M\n" +
+	"\rWatchSessions\x12 .sandbox.v1.WatchSessionsRequest\x1a\x18.sandbox.v1.SessionEvent0\x01\x129\n" +
 	"\x04Exec\x12\x17.sandbox.v1.ExecRequest\x1a\x18.sandbox.v1.ExecResponse\x12G\n" +
 	"\n" +
-	"ExecStream\x12\x17.sandbox.v1.ExecRequest\x1a\x1e.sandbox.v1.ExecStreamResponse0\x01\x12H\n" +
+	"ExecStream\x12\x17.sandbox.v1.ExecRequest\x1a\x1e.sandbox.v1.ExecStreamResponse0\x01\x12@\n" +
+	"\fExecStreamV2\x12\x17.sandbox.v1.ExecRequest\x1a\x15.sandbox.v1.ExecFrame0\x01\x12C\n" +
+	"\x0fExecInteractive\x12\x15.sandbox.v1.ExecInput\x1a\x15.sandbox.v1.ExecFrame(\x010\x01\x12H\n" +
 	"\tWriteFile\x12\x1c.sandbox.v1.WriteFileRequest\x1a\x1d.sandbox.v1.WriteFileResponse\x12E\n" +
 	"\bReadFile\x12\x1b.sandbox.v1.ReadFileRequest\x1a\x1c.sandbox.v1.ReadFileResponse\x12H\n" +
 	"\tListFiles\x12\x1c.sandbox.v1.ListFilesRequest\x1a\x1d.sandbox.v1.ListFilesResponse\x12K\n" +
@@ -4354,14 +7382,25 @@ const file_sandbox_v1_sandbox_proto_rawDesc = "" +
 	"PipInstall\x12\x1d.sandbox.v1.PipInstallRequest\x1a\x1e.sandbox.v1.PipInstallResponse\x12N\n" +
 	"\vRunSubAgent\x12\x1e.sandbox.v1.RunSubAgentRequest\x1a\x1f.sandbox.v1.RunSubAgentResponse\x12T\n" +
 	"\rConfirmAction\x12 .sandbox.v1.ConfirmActionRequest\x1a!.sandbox.v1.ConfirmActionResponse\x12T\n" +
-	"\rApproveAction\x12 .sandbox.v1.ApproveActionRequest\x1a!.sandbox.v1.ApproveActionResponse\x12<\n" +
-	"\x05Login\x12\x18.sandbox.v1.LoginRequest\x1a\x19.sandbox.v1.LoginResponse\x12B\n" +
+	"\rApproveAction\x12 .sandbox.v1.ApproveActionRequest\x1a!.sandbox.v1.ApproveActionResponse\x12T\n" +
+	"\rListApprovals\x12 .sandbox.v1.ListApprovalsRequest\x1a!.sandbox.v1.ListApprovalsResponse\x12K\n" +
+	"\x0eWatchApprovals\x12!.sandbox.v1.WatchApprovalsRequest\x1a\x14.sandbox.v1.Approval0\x01\x12K\n" +
+	"\n" +
+	"QueryAudit\x12\x1d.sandbox.v1.QueryAuditRequest\x1a\x1e.sandbox.v1.QueryAuditResponse\x12K\n" +
+	"\n" +
+	"QueryUsage\x12\x1d.sandbox.v1.QueryUsageRequest\x1a\x1e.sandbox.v1.QueryUsageResponse\x12<\n" +
+	"\x05Login\x12\x18.sandbox.v1.LoginRequest\x1a\x19.sandbox.v1.LoginResponse\x12?\n" +
+	"\x06GetCRL\x12\x19.sandbox.v1.GetCRLRequest\x1a\x1a.sandbox.v1.GetCRLResponse\x12B\n" +
 	"\aPollRun\x12\x1a.sandbox.v1.PollRunRequest\x1a\x1b.sandbox.v1.PollRunResponse\x12T\n" +
-	"\rGetTranscript\x12 .sandbox.v1.GetTranscriptRequest\x1a!.sandbox.v1.GetTranscriptResponse\x12H\n" +
+	"\rGetTranscript\x12 .sandbox.v1.GetTranscriptRequest\x1a!.sandbox.v1.GetTranscriptResponse\x12Q\n" +
+	"\fGetRunOutput\x12\x1f.sandbox.v1.GetRunOutputRequest\x1a .sandbox.v1.GetRunOutputResponse\x12H\n" +
 	"\tGetEvents\x12\x1c.sandbox.v1.GetEventsRequest\x1a\x1d.sandbox.v1.GetEventsResponse\x12N\n" +
 	"\vSnapshotPut\x12\x1e.sandbox.v1.SnapshotPutRequest\x1a\x1f.sandbox.v1.SnapshotPutResponse\x12N\n" +
 	"\vSnapshotGet\x12\x1e.sandbox.v1.SnapshotGetRequest\x1a\x1f.sandbox.v1.SnapshotGetResponse\x12Q\n" +
-	"\fSnapshotList\x12\x1f.sandbox.v1.SnapshotListRequest\x1a .sandbox.v1.SnapshotListResponse\x12Q\n" +
+	"\fSnapshotList\x12\x1f.sandbox.v1.SnapshotListRequest\x1a .sandbox.v1.SnapshotListResponse\x12Z\n" +
+	"\x0fSnapshotSession\x12\".sandbox.v1.SnapshotSessionRequest\x1a#.sandbox.v1.SnapshotSessionResponse\x12W\n" +
+	"\x0eRestoreSession\x12!.sandbox.v1.RestoreSessionRequest\x1a\".sandbox.v1.RestoreSessionResponse\x12N\n" +
+	"\vForkSession\x12\x1e.sandbox.v1.ForkSessionRequest\x1a\x1f.sandbox.v1.ForkSessionResponse\x12Q\n" +
 	"\fGetProcesses\x12\x1f.sandbox.v1.GetProcessesRequest\x1a .sandbox.v1.GetProcessesResponse\x12W\n" +
 	"\x0eCreateTerminal\x12!.sandbox.v1.CreateTerminalRequest\x1a\".sandbox.v1.CreateTerminalResponse\x12Y\n" +
 	"\x0eTerminalStream\x12!.sandbox.v1.TerminalStreamRequest\x1a\".sandbox.v1.TerminalStreamResponse0\x01\x12T\n" +
@@ -4387,168 +7426,245 @@ func file_sandbox_v1_sandbox_proto_rawDescGZIP() []byte {
 	return file_sandbox_v1_sandbox_proto_rawDescData
 }
 
-var file_sandbox_v1_sandbox_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_sandbox_v1_sandbox_proto_msgTypes = make([]protoimpl.MessageInfo, 74)
+var file_sandbox_v1_sandbox_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_sandbox_v1_sandbox_proto_msgTypes = make([]protoimpl.MessageInfo, 103)
 var file_sandbox_v1_sandbox_proto_goTypes = []any{
-	(TerminalSignal)(0),                // 0: sandbox.v1.TerminalSignal
-	(*SecretRef)(nil),                  // 1: sandbox.v1.SecretRef
-	(*CreateSessionRequest)(nil),       // 2: sandbox.v1.CreateSessionRequest
-	(*CreateSessionResponse)(nil),      // 3: sandbox.v1.CreateSessionResponse
-	(*GetSessionRequest)(nil),          // 4: sandbox.v1.GetSessionRequest
-	(*GetSessionResponse)(nil),         // 5: sandbox.v1.GetSessionResponse
-	(*ListSessionsRequest)(nil),        // 6: sandbox.v1.ListSessionsRequest
-	(*ListSessionsResponse)(nil),       // 7: sandbox.v1.ListSessionsResponse
-	(*DestroySessionRequest)(nil),      // 8: sandbox.v1.DestroySessionRequest
-	(*DestroySessionResponse)(nil),     // 9: sandbox.v1.DestroySessionResponse
-	(*PauseSessionRequest)(nil),        // 10: sandbox.v1.PauseSessionRequest
-	(*PauseSessionResponse)(nil),       // 11: sandbox.v1.PauseSessionResponse
-	(*ResumeSessionRequest)(nil),       // 12: sandbox.v1.ResumeSessionRequest
-	(*ResumeSessionResponse)(nil),      // 13: sandbox.v1.ResumeSessionResponse
-	(*ExecRequest)(nil),                // 14: sandbox.v1.ExecRequest
-	(*ExecResponse)(nil),               // 15: sandbox.v1.ExecResponse
-	(*ExecStreamResponse)(nil),         // 16: sandbox.v1.ExecStreamResponse
-	(*WriteFileRequest)(nil),           // 17: sandbox.v1.WriteFileRequest
-	(*WriteFileResponse)(nil),          // 18: sandbox.v1.WriteFileResponse
-	(*ReadFileRequest)(nil),            // 19: sandbox.v1.ReadFileRequest
-	(*ReadFileResponse)(nil),           // 20: sandbox.v1.ReadFileResponse
-	(*ListFilesRequest)(nil),           // 21: sandbox.v1.ListFilesRequest
-	(*ListFilesResponse)(nil),          // 22: sandbox.v1.ListFilesResponse
-	(*FileEntry)(nil),                  // 23: sandbox.v1.FileEntry
-	(*PipInstallRequest)(nil),          // 24: sandbox.v1.PipInstallRequest
-	(*PipInstallResponse)(nil),         // 25: sandbox.v1.PipInstallResponse
-	(*RunSubAgentRequest)(nil),         // 26: sandbox.v1.RunSubAgentRequest
-	(*RunSubAgentResponse)(nil),        // 27: sandbox.v1.RunSubAgentResponse
-	(*ConfirmActionRequest)(nil),       // 28: sandbox.v1.ConfirmActionRequest
-	(*ConfirmActionResponse)(nil),      // 29: sandbox.v1.ConfirmActionResponse
-	(*ApproveActionRequest)(nil),       // 30: sandbox.v1.ApproveActionRequest
-	(*ApproveActionResponse)(nil),      // 31: sandbox.v1.ApproveActionResponse
-	(*LoginRequest)(nil),               // 32: sandbox.v1.LoginRequest
-	(*LoginResponse)(nil),              // 33: sandbox.v1.LoginResponse
-	(*PollRunRequest)(nil),             // 34: sandbox.v1.PollRunRequest
-	(*PollRunResponse)(nil),            // 35: sandbox.v1.PollRunResponse
-	(*GetTranscriptRequest)(nil),       // 36: sandbox.v1.GetTranscriptRequest
-	(*GetTranscriptResponse)(nil),      // 37: sandbox.v1.GetTranscriptResponse
-	(*GetEventsRequest)(nil),           // 38: sandbox.v1.GetEventsRequest
-	(*GetEventsResponse)(nil),          // 39: sandbox.v1.GetEventsResponse
-	(*SnapshotPutRequest)(nil),         // 40: sandbox.v1.SnapshotPutRequest
-	(*SnapshotPutResponse)(nil),        // 41: sandbox.v1.SnapshotPutResponse
-	(*SnapshotGetRequest)(nil),         // 42: sandbox.v1.SnapshotGetRequest
-	(*SnapshotGetResponse)(nil),        // 43: sandbox.v1.SnapshotGetResponse
-	(*SnapshotListRequest)(nil),        // 44: sandbox.v1.SnapshotListRequest
-	(*SnapshotListResponse)(nil),       // 45: sandbox.v1.SnapshotListResponse
-	(*GetProcessesRequest)(nil),        // 46: sandbox.v1.GetProcessesRequest
-	(*ProcessInfo)(nil),                // 47: sandbox.v1.ProcessInfo
-	(*GetProcessesResponse)(nil),       // 48: sandbox.v1.GetProcessesResponse
-	(*CreateTerminalRequest)(nil),      // 49: sandbox.v1.CreateTerminalRequest
-	(*CreateTerminalResponse)(nil),     // 50: sandbox.v1.CreateTerminalResponse
-	(*TerminalStreamRequest)(nil),      // 51: sandbox.v1.TerminalStreamRequest
-	(*TerminalStreamResponse)(nil),     // 52: sandbox.v1.TerminalStreamResponse
-	(*TerminalExit)(nil),               // 53: sandbox.v1.TerminalExit
-	(*TerminalWriteRequest)(nil),       // 54: sandbox.v1.TerminalWriteRequest
-	(*TerminalWriteResponse)(nil),      // 55: sandbox.v1.TerminalWriteResponse
-	(*TerminalResizeRequest)(nil),      // 56: sandbox.v1.TerminalResizeRequest
-	(*TerminalResizeResponse)(nil),     // 57: sandbox.v1.TerminalResizeResponse
-	(*TerminalForegroundRequest)(nil),  // 58: sandbox.v1.TerminalForegroundRequest
-	(*TerminalForegroundResponse)(nil), // 59: sandbox.v1.TerminalForegroundResponse
-	(*TerminalSignalRequest)(nil),      // 60: sandbox.v1.TerminalSignalRequest
-	(*TerminalSignalResponse)(nil),     // 61: sandbox.v1.TerminalSignalResponse
-	(*TerminalDestroyRequest)(nil),     // 62: sandbox.v1.TerminalDestroyRequest
-	(*TerminalDestroyResponse)(nil),    // 63: sandbox.v1.TerminalDestroyResponse
-	(*ExposeServiceRequest)(nil),       // 64: sandbox.v1.ExposeServiceRequest
-	(*ExposeServiceResponse)(nil),      // 65: sandbox.v1.ExposeServiceResponse
-	(*UnexposeServiceRequest)(nil),     // 66: sandbox.v1.UnexposeServiceRequest
-	(*UnexposeServiceResponse)(nil),    // 67: sandbox.v1.UnexposeServiceResponse
-	(*ExposedService)(nil),             // 68: sandbox.v1.ExposedService
-	(*ListExposedRequest)(nil),         // 69: sandbox.v1.ListExposedRequest
-	(*ListExposedResponse)(nil),        // 70: sandbox.v1.ListExposedResponse
-	(*UpdateAllowedHostsRequest)(nil),  // 71: sandbox.v1.UpdateAllowedHostsRequest
-	(*UpdateAllowedHostsResponse)(nil), // 72: sandbox.v1.UpdateAllowedHostsResponse
-	nil,                                // 73: sandbox.v1.CreateSessionRequest.EnvEntry
-	nil,                                // 74: sandbox.v1.CreateTerminalRequest.EnvEntry
+	(SessionEventType)(0),              // 0: sandbox.v1.SessionEventType
+	(PauseMode)(0),                     // 1: sandbox.v1.PauseMode
+	(TerminalSignal)(0),                // 2: sandbox.v1.TerminalSignal
+	(ExposeAccess)(0),                  // 3: sandbox.v1.ExposeAccess
+	(*SecretRef)(nil),                  // 4: sandbox.v1.SecretRef
+	(*EgressRule)(nil),                 // 5: sandbox.v1.EgressRule
+	(*EgressHTTPRule)(nil),             // 6: sandbox.v1.EgressHTTPRule
+	(*CreateSessionRequest)(nil),       // 7: sandbox.v1.CreateSessionRequest
+	(*CreateSessionResponse)(nil),      // 8: sandbox.v1.CreateSessionResponse
+	(*GetSessionRequest)(nil),          // 9: sandbox.v1.GetSessionRequest
+	(*GetSessionResponse)(nil),         // 10: sandbox.v1.GetSessionResponse
+	(*ListSessionsRequest)(nil),        // 11: sandbox.v1.ListSessionsRequest
+	(*ListSessionsResponse)(nil),       // 12: sandbox.v1.ListSessionsResponse
+	(*WatchSessionsRequest)(nil),       // 13: sandbox.v1.WatchSessionsRequest
+	(*SessionEvent)(nil),               // 14: sandbox.v1.SessionEvent
+	(*DestroySessionRequest)(nil),      // 15: sandbox.v1.DestroySessionRequest
+	(*DestroySessionResponse)(nil),     // 16: sandbox.v1.DestroySessionResponse
+	(*PauseSessionRequest)(nil),        // 17: sandbox.v1.PauseSessionRequest
+	(*PauseSessionResponse)(nil),       // 18: sandbox.v1.PauseSessionResponse
+	(*ResumeSessionRequest)(nil),       // 19: sandbox.v1.ResumeSessionRequest
+	(*ResumeSessionResponse)(nil),      // 20: sandbox.v1.ResumeSessionResponse
+	(*ExecRequest)(nil),                // 21: sandbox.v1.ExecRequest
+	(*ExecResponse)(nil),               // 22: sandbox.v1.ExecResponse
+	(*OutputRef)(nil),                  // 23: sandbox.v1.OutputRef
+	(*ExecStreamResponse)(nil),         // 24: sandbox.v1.ExecStreamResponse
+	(*ExecFrame)(nil),                  // 25: sandbox.v1.ExecFrame
+	(*ExecExit)(nil),                   // 26: sandbox.v1.ExecExit
+	(*ExecInput)(nil),                  // 27: sandbox.v1.ExecInput
+	(*WriteFileRequest)(nil),           // 28: sandbox.v1.WriteFileRequest
+	(*WriteFileResponse)(nil),          // 29: sandbox.v1.WriteFileResponse
+	(*ReadFileRequest)(nil),            // 30: sandbox.v1.ReadFileRequest
+	(*ReadFileResponse)(nil),           // 31: sandbox.v1.ReadFileResponse
+	(*ListFilesRequest)(nil),           // 32: sandbox.v1.ListFilesRequest
+	(*ListFilesResponse)(nil),          // 33: sandbox.v1.ListFilesResponse
+	(*FileEntry)(nil),                  // 34: sandbox.v1.FileEntry
+	(*PipInstallRequest)(nil),          // 35: sandbox.v1.PipInstallRequest
+	(*PipInstallResponse)(nil),         // 36: sandbox.v1.PipInstallResponse
+	(*RunSubAgentRequest)(nil),         // 37: sandbox.v1.RunSubAgentRequest
+	(*RunSubAgentResponse)(nil),        // 38: sandbox.v1.RunSubAgentResponse
+	(*ConfirmActionRequest)(nil),       // 39: sandbox.v1.ConfirmActionRequest
+	(*ConfirmActionResponse)(nil),      // 40: sandbox.v1.ConfirmActionResponse
+	(*ApproveActionRequest)(nil),       // 41: sandbox.v1.ApproveActionRequest
+	(*ApproveActionResponse)(nil),      // 42: sandbox.v1.ApproveActionResponse
+	(*Approval)(nil),                   // 43: sandbox.v1.Approval
+	(*ListApprovalsRequest)(nil),       // 44: sandbox.v1.ListApprovalsRequest
+	(*ListApprovalsResponse)(nil),      // 45: sandbox.v1.ListApprovalsResponse
+	(*WatchApprovalsRequest)(nil),      // 46: sandbox.v1.WatchApprovalsRequest
+	(*AuditRecord)(nil),                // 47: sandbox.v1.AuditRecord
+	(*QueryAuditRequest)(nil),          // 48: sandbox.v1.QueryAuditRequest
+	(*QueryAuditResponse)(nil),         // 49: sandbox.v1.QueryAuditResponse
+	(*SessionUsage)(nil),               // 50: sandbox.v1.SessionUsage
+	(*TenantUsage)(nil),                // 51: sandbox.v1.TenantUsage
+	(*QueryUsageRequest)(nil),          // 52: sandbox.v1.QueryUsageRequest
+	(*QueryUsageResponse)(nil),         // 53: sandbox.v1.QueryUsageResponse
+	(*LoginRequest)(nil),               // 54: sandbox.v1.LoginRequest
+	(*LoginResponse)(nil),              // 55: sandbox.v1.LoginResponse
+	(*GetCRLRequest)(nil),              // 56: sandbox.v1.GetCRLRequest
+	(*GetCRLResponse)(nil),             // 57: sandbox.v1.GetCRLResponse
+	(*PollRunRequest)(nil),             // 58: sandbox.v1.PollRunRequest
+	(*PollRunResponse)(nil),            // 59: sandbox.v1.PollRunResponse
+	(*GetTranscriptRequest)(nil),       // 60: sandbox.v1.GetTranscriptRequest
+	(*GetTranscriptResponse)(nil),      // 61: sandbox.v1.GetTranscriptResponse
+	(*GetRunOutputRequest)(nil),        // 62: sandbox.v1.GetRunOutputRequest
+	(*GetRunOutputResponse)(nil),       // 63: sandbox.v1.GetRunOutputResponse
+	(*GetEventsRequest)(nil),           // 64: sandbox.v1.GetEventsRequest
+	(*GetEventsResponse)(nil),          // 65: sandbox.v1.GetEventsResponse
+	(*SnapshotPutRequest)(nil),         // 66: sandbox.v1.SnapshotPutRequest
+	(*SnapshotPutResponse)(nil),        // 67: sandbox.v1.SnapshotPutResponse
+	(*SnapshotGetRequest)(nil),         // 68: sandbox.v1.SnapshotGetRequest
+	(*SnapshotGetResponse)(nil),        // 69: sandbox.v1.SnapshotGetResponse
+	(*SnapshotListRequest)(nil),        // 70: sandbox.v1.SnapshotListRequest
+	(*SnapshotListResponse)(nil),       // 71: sandbox.v1.SnapshotListResponse
+	(*SnapshotSessionRequest)(nil),     // 72: sandbox.v1.SnapshotSessionRequest
+	(*SnapshotSessionResponse)(nil),    // 73: sandbox.v1.SnapshotSessionResponse
+	(*RestoreSessionRequest)(nil),      // 74: sandbox.v1.RestoreSessionRequest
+	(*RestoreSessionResponse)(nil),     // 75: sandbox.v1.RestoreSessionResponse
+	(*ForkSessionRequest)(nil),         // 76: sandbox.v1.ForkSessionRequest
+	(*ForkSessionResponse)(nil),        // 77: sandbox.v1.ForkSessionResponse
+	(*GetProcessesRequest)(nil),        // 78: sandbox.v1.GetProcessesRequest
+	(*ProcessInfo)(nil),                // 79: sandbox.v1.ProcessInfo
+	(*GetProcessesResponse)(nil),       // 80: sandbox.v1.GetProcessesResponse
+	(*CreateTerminalRequest)(nil),      // 81: sandbox.v1.CreateTerminalRequest
+	(*CreateTerminalResponse)(nil),     // 82: sandbox.v1.CreateTerminalResponse
+	(*TerminalStreamRequest)(nil),      // 83: sandbox.v1.TerminalStreamRequest
+	(*TerminalStreamResponse)(nil),     // 84: sandbox.v1.TerminalStreamResponse
+	(*TerminalExit)(nil),               // 85: sandbox.v1.TerminalExit
+	(*TerminalWriteRequest)(nil),       // 86: sandbox.v1.TerminalWriteRequest
+	(*TerminalWriteResponse)(nil),      // 87: sandbox.v1.TerminalWriteResponse
+	(*TerminalResizeRequest)(nil),      // 88: sandbox.v1.TerminalResizeRequest
+	(*TerminalResizeResponse)(nil),     // 89: sandbox.v1.TerminalResizeResponse
+	(*TerminalForegroundRequest)(nil),  // 90: sandbox.v1.TerminalForegroundRequest
+	(*TerminalForegroundResponse)(nil), // 91: sandbox.v1.TerminalForegroundResponse
+	(*TerminalSignalRequest)(nil),      // 92: sandbox.v1.TerminalSignalRequest
+	(*TerminalSignalResponse)(nil),     // 93: sandbox.v1.TerminalSignalResponse
+	(*TerminalDestroyRequest)(nil),     // 94: sandbox.v1.TerminalDestroyRequest
+	(*TerminalDestroyResponse)(nil),    // 95: sandbox.v1.TerminalDestroyResponse
+	(*ExposeServiceRequest)(nil),       // 96: sandbox.v1.ExposeServiceRequest
+	(*ExposeServiceResponse)(nil),      // 97: sandbox.v1.ExposeServiceResponse
+	(*UnexposeServiceRequest)(nil),     // 98: sandbox.v1.UnexposeServiceRequest
+	(*UnexposeServiceResponse)(nil),    // 99: sandbox.v1.UnexposeServiceResponse
+	(*ExposedService)(nil),             // 100: sandbox.v1.ExposedService
+	(*ListExposedRequest)(nil),         // 101: sandbox.v1.ListExposedRequest
+	(*ListExposedResponse)(nil),        // 102: sandbox.v1.ListExposedResponse
+	(*UpdateAllowedHostsRequest)(nil),  // 103: sandbox.v1.UpdateAllowedHostsRequest
+	(*UpdateAllowedHostsResponse)(nil), // 104: sandbox.v1.UpdateAllowedHostsResponse
+	nil,                                // 105: sandbox.v1.CreateSessionRequest.EnvEntry
+	nil,                                // 106: sandbox.v1.CreateTerminalRequest.EnvEntry
 }
 var file_sandbox_v1_sandbox_proto_depIdxs = []int32{
-	73, // 0: sandbox.v1.CreateSessionRequest.env:type_name -> sandbox.v1.CreateSessionRequest.EnvEntry
-	1,  // 1: sandbox.v1.CreateSessionRequest.secret_refs:type_name -> sandbox.v1.SecretRef
-	5,  // 2: sandbox.v1.ListSessionsResponse.sessions:type_name -> sandbox.v1.GetSessionResponse
-	23, // 3: sandbox.v1.ListFilesResponse.files:type_name -> sandbox.v1.FileEntry
-	47, // 4: sandbox.v1.GetProcessesResponse.processes:type_name -> sandbox.v1.ProcessInfo
-	74, // 5: sandbox.v1.CreateTerminalRequest.env:type_name -> sandbox.v1.CreateTerminalRequest.EnvEntry
-	53, // 6: sandbox.v1.TerminalStreamResponse.exit:type_name -> sandbox.v1.TerminalExit
-	0,  // 7: sandbox.v1.TerminalSignalRequest.signal:type_name -> sandbox.v1.TerminalSignal
-	68, // 8: sandbox.v1.ListExposedResponse.services:type_name -> sandbox.v1.ExposedService
-	2,  // 9: sandbox.v1.SandboxService.CreateSession:input_type -> sandbox.v1.CreateSessionRequest
-	4,  // 10: sandbox.v1.SandboxService.GetSession:input_type -> sandbox.v1.GetSessionRequest
-	6,  // 11: sandbox.v1.SandboxService.ListSessions:input_type -> sandbox.v1.ListSessionsRequest
-	8,  // 12: sandbox.v1.SandboxService.DestroySession:input_type -> sandbox.v1.DestroySessionRequest
-	10, // 13: sandbox.v1.SandboxService.PauseSession:input_type -> sandbox.v1.PauseSessionRequest
-	12, // 14: sandbox.v1.SandboxService.ResumeSession:input_type -> sandbox.v1.ResumeSessionRequest
-	14, // 15: sandbox.v1.SandboxService.Exec:input_type -> sandbox.v1.ExecRequest
-	14, // 16: sandbox.v1.SandboxService.ExecStream:input_type -> sandbox.v1.ExecRequest
-	17, // 17: sandbox.v1.SandboxService.WriteFile:input_type -> sandbox.v1.WriteFileRequest
-	19, // 18: sandbox.v1.SandboxService.ReadFile:input_type -> sandbox.v1.ReadFileRequest
-	21, // 19: sandbox.v1.SandboxService.ListFiles:input_type -> sandbox.v1.ListFilesRequest
-	24, // 20: sandbox.v1.SandboxService.PipInstall:input_type -> sandbox.v1.PipInstallRequest
-	26, // 21: sandbox.v1.SandboxService.RunSubAgent:input_type -> sandbox.v1.RunSubAgentRequest
-	28, // 22: sandbox.v1.SandboxService.ConfirmAction:input_type -> sandbox.v1.ConfirmActionRequest
-	30, // 23: sandbox.v1.SandboxService.ApproveAction:input_type -> sandbox.v1.ApproveActionRequest
-	32, // 24: sandbox.v1.SandboxService.Login:input_type -> sandbox.v1.LoginRequest
-	34, // 25: sandbox.v1.SandboxService.PollRun:input_type -> sandbox.v1.PollRunRequest
-	36, // 26: sandbox.v1.SandboxService.GetTranscript:input_type -> sandbox.v1.GetTranscriptRequest
-	38, // 27: sandbox.v1.SandboxService.GetEvents:input_type -> sandbox.v1.GetEventsRequest
-	40, // 28: sandbox.v1.SandboxService.SnapshotPut:input_type -> sandbox.v1.SnapshotPutRequest
-	42, // 29: sandbox.v1.SandboxService.SnapshotGet:input_type -> sandbox.v1.SnapshotGetRequest
-	44, // 30: sandbox.v1.SandboxService.SnapshotList:input_type -> sandbox.v1.SnapshotListRequest
-	46, // 31: sandbox.v1.SandboxService.GetProcesses:input_type -> sandbox.v1.GetProcessesRequest
-	49, // 32: sandbox.v1.SandboxService.CreateTerminal:input_type -> sandbox.v1.CreateTerminalRequest
-	51, // 33: sandbox.v1.SandboxService.TerminalStream:input_type -> sandbox.v1.TerminalStreamRequest
-	54, // 34: sandbox.v1.SandboxService.TerminalWrite:input_type -> sandbox.v1.TerminalWriteRequest
-	56, // 35: sandbox.v1.SandboxService.TerminalResize:input_type -> sandbox.v1.TerminalResizeRequest
-	58, // 36: sandbox.v1.SandboxService.TerminalForeground:input_type -> sandbox.v1.TerminalForegroundRequest
-	60, // 37: sandbox.v1.SandboxService.TerminalSignal:input_type -> sandbox.v1.TerminalSignalRequest
-	62, // 38: sandbox.v1.SandboxService.TerminalDestroy:input_type -> sandbox.v1.TerminalDestroyRequest
-	64, // 39: sandbox.v1.SandboxService.ExposeService:input_type -> sandbox.v1.ExposeServiceRequest
-	66, // 40: sandbox.v1.SandboxService.UnexposeService:input_type -> sandbox.v1.UnexposeServiceRequest
-	69, // 41: sandbox.v1.SandboxService.ListExposed:input_type -> sandbox.v1.ListExposedRequest
-	71, // 42: sandbox.v1.SandboxService.UpdateAllowedHosts:input_type -> sandbox.v1.UpdateAllowedHostsRequest
-	3,  // 43: sandbox.v1.SandboxService.CreateSession:output_type -> sandbox.v1.CreateSessionResponse
-	5,  // 44: sandbox.v1.SandboxService.GetSession:output_type -> sandbox.v1.GetSessionResponse
-	7,  // 45: sandbox.v1.SandboxService.ListSessions:output_type -> sandbox.v1.ListSessionsResponse
-	9,  // 46: sandbox.v1.SandboxService.DestroySession:output_type -> sandbox.v1.DestroySessionResponse
-	11, // 47: sandbox.v1.SandboxService.PauseSession:output_type -> sandbox.v1.PauseSessionResponse
-	13, // 48: sandbox.v1.SandboxService.ResumeSession:output_type -> sandbox.v1.ResumeSessionResponse
-	15, // 49: sandbox.v1.SandboxService.Exec:output_type -> sandbox.v1.ExecResponse
-	16, // 50: sandbox.v1.SandboxService.ExecStream:output_type -> sandbox.v1.ExecStreamResponse
-	18, // 51: sandbox.v1.SandboxService.WriteFile:output_type -> sandbox.v1.WriteFileResponse
-	20, // 52: sandbox.v1.SandboxService.ReadFile:output_type -> sandbox.v1.ReadFileResponse
-	22, // 53: sandbox.v1.SandboxService.ListFiles:output_type -> sandbox.v1.ListFilesResponse
-	25, // 54: sandbox.v1.SandboxService.PipInstall:output_type -> sandbox.v1.PipInstallResponse
-	27, // 55: sandbox.v1.SandboxService.RunSubAgent:output_type -> sandbox.v1.RunSubAgentResponse
-	29, // 56: sandbox.v1.SandboxService.ConfirmAction:output_type -> sandbox.v1.ConfirmActionResponse
-	31, // 57: sandbox.v1.SandboxService.ApproveAction:output_type -> sandbox.v1.ApproveActionResponse
-	33, // 58: sandbox.v1.SandboxService.Login:output_type -> sandbox.v1.LoginResponse
-	35, // 59: sandbox.v1.SandboxService.PollRun:output_type -> sandbox.v1.PollRunResponse
-	37, // 60: sandbox.v1.SandboxService.GetTranscript:output_type -> sandbox.v1.GetTranscriptResponse
-	39, // 61: sandbox.v1.SandboxService.GetEvents:output_type -> sandbox.v1.GetEventsResponse
-	41, // 62: sandbox.v1.SandboxService.SnapshotPut:output_type -> sandbox.v1.SnapshotPutResponse
-	43, // 63: sandbox.v1.SandboxService.SnapshotGet:output_type -> sandbox.v1.SnapshotGetResponse
-	45, // 64: sandbox.v1.SandboxService.SnapshotList:output_type -> sandbox.v1.SnapshotListResponse
-	48, // 65: sandbox.v1.SandboxService.GetProcesses:output_type -> sandbox.v1.GetProcessesResponse
-	50, // 66: sandbox.v1.SandboxService.CreateTerminal:output_type -> sandbox.v1.CreateTerminalResponse
-	52, // 67: sandbox.v1.SandboxService.TerminalStream:output_type -> sandbox.v1.TerminalStreamResponse
-	55, // 68: sandbox.v1.SandboxService.TerminalWrite:output_type -> sandbox.v1.TerminalWriteResponse
-	57, // 69: sandbox.v1.SandboxService.TerminalResize:output_type -> sandbox.v1.TerminalResizeResponse
-	59, // 70: sandbox.v1.SandboxService.TerminalForeground:output_type -> sandbox.v1.TerminalForegroundResponse
-	61, // 71: sandbox.v1.SandboxService.TerminalSignal:output_type -> sandbox.v1.TerminalSignalResponse
-	63, // 72: sandbox.v1.SandboxService.TerminalDestroy:output_type -> sandbox.v1.TerminalDestroyResponse
-	65, // 73: sandbox.v1.SandboxService.ExposeService:output_type -> sandbox.v1.ExposeServiceResponse
-	67, // 74: sandbox.v1.SandboxService.UnexposeService:output_type -> sandbox.v1.UnexposeServiceResponse
-	70, // 75: sandbox.v1.SandboxService.ListExposed:output_type -> sandbox.v1.ListExposedResponse
-	72, // 76: sandbox.v1.SandboxService.UpdateAllowedHosts:output_type -> sandbox.v1.UpdateAllowedHostsResponse
-	43, // [43:77] is the sub-list for method output_type
-	9,  // [9:43] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	6,   // 0: sandbox.v1.EgressRule.http:type_name -> sandbox.v1.EgressHTTPRule
+	105, // 1: sandbox.v1.CreateSessionRequest.env:type_name -> sandbox.v1.CreateSessionRequest.EnvEntry
+	4,   // 2: sandbox.v1.CreateSessionRequest.secret_refs:type_name -> sandbox.v1.SecretRef
+	5,   // 3: sandbox.v1.CreateSessionRequest.egress_rules:type_name -> sandbox.v1.EgressRule
+	5,   // 4: sandbox.v1.GetSessionResponse.egress_rules:type_name -> sandbox.v1.EgressRule
+	10,  // 5: sandbox.v1.ListSessionsResponse.sessions:type_name -> sandbox.v1.GetSessionResponse
+	0,   // 6: sandbox.v1.WatchSessionsRequest.types:type_name -> sandbox.v1.SessionEventType
+	0,   // 7: sandbox.v1.SessionEvent.type:type_name -> sandbox.v1.SessionEventType
+	5,   // 8: sandbox.v1.SessionEvent.egress_rules:type_name -> sandbox.v1.EgressRule
+	1,   // 9: sandbox.v1.PauseSessionRequest.mode:type_name -> sandbox.v1.PauseMode
+	1,   // 10: sandbox.v1.PauseSessionResponse.mode:type_name -> sandbox.v1.PauseMode
+	23,  // 11: sandbox.v1.ExecResponse.output_ref:type_name -> sandbox.v1.OutputRef
+	26,  // 12: sandbox.v1.ExecFrame.exit:type_name -> sandbox.v1.ExecExit
+	21,  // 13: sandbox.v1.ExecInput.start:type_name -> sandbox.v1.ExecRequest
+	34,  // 14: sandbox.v1.ListFilesResponse.files:type_name -> sandbox.v1.FileEntry
+	43,  // 15: sandbox.v1.ListApprovalsResponse.approvals:type_name -> sandbox.v1.Approval
+	47,  // 16: sandbox.v1.QueryAuditResponse.records:type_name -> sandbox.v1.AuditRecord
+	50,  // 17: sandbox.v1.QueryUsageResponse.sessions:type_name -> sandbox.v1.SessionUsage
+	51,  // 18: sandbox.v1.QueryUsageResponse.tenants:type_name -> sandbox.v1.TenantUsage
+	23,  // 19: sandbox.v1.PollRunResponse.output_ref:type_name -> sandbox.v1.OutputRef
+	79,  // 20: sandbox.v1.GetProcessesResponse.processes:type_name -> sandbox.v1.ProcessInfo
+	106, // 21: sandbox.v1.CreateTerminalRequest.env:type_name -> sandbox.v1.CreateTerminalRequest.EnvEntry
+	85,  // 22: sandbox.v1.TerminalStreamResponse.exit:type_name -> sandbox.v1.TerminalExit
+	2,   // 23: sandbox.v1.TerminalSignalRequest.signal:type_name -> sandbox.v1.TerminalSignal
+	3,   // 24: sandbox.v1.ExposeServiceRequest.access:type_name -> sandbox.v1.ExposeAccess
+	3,   // 25: sandbox.v1.ExposeServiceResponse.access:type_name -> sandbox.v1.ExposeAccess
+	3,   // 26: sandbox.v1.ExposedService.access:type_name -> sandbox.v1.ExposeAccess
+	100, // 27: sandbox.v1.ListExposedResponse.services:type_name -> sandbox.v1.ExposedService
+	5,   // 28: sandbox.v1.UpdateAllowedHostsRequest.rules:type_name -> sandbox.v1.EgressRule
+	5,   // 29: sandbox.v1.UpdateAllowedHostsResponse.rules:type_name -> sandbox.v1.EgressRule
+	7,   // 30: sandbox.v1.SandboxService.CreateSession:input_type -> sandbox.v1.CreateSessionRequest
+	9,   // 31: sandbox.v1.SandboxService.GetSession:input_type -> sandbox.v1.GetSessionRequest
+	11,  // 32: sandbox.v1.SandboxService.ListSessions:input_type -> sandbox.v1.ListSessionsRequest
+	15,  // 33: sandbox.v1.SandboxService.DestroySession:input_type -> sandbox.v1.DestroySessionRequest
+	17,  // 34: sandbox.v1.SandboxService.PauseSession:input_type -> sandbox.v1.PauseSessionRequest
+	19,  // 35: sandbox.v1.SandboxService.ResumeSession:input_type -> sandbox.v1.ResumeSessionRequest
+	13,  // 36: sandbox.v1.SandboxService.WatchSessions:input_type -> sandbox.v1.WatchSessionsRequest
+	21,  // 37: sandbox.v1.SandboxService.Exec:input_type -> sandbox.v1.ExecRequest
+	21,  // 38: sandbox.v1.SandboxService.ExecStream:input_type -> sandbox.v1.ExecRequest
+	21,  // 39: sandbox.v1.SandboxService.ExecStreamV2:input_type -> sandbox.v1.ExecRequest
+	27,  // 40: sandbox.v1.SandboxService.ExecInteractive:input_type -> sandbox.v1.ExecInput
+	28,  // 41: sandbox.v1.SandboxService.WriteFile:input_type -> sandbox.v1.WriteFileRequest
+	30,  // 42: sandbox.v1.SandboxService.ReadFile:input_type -> sandbox.v1.ReadFileRequest
+	32,  // 43: sandbox.v1.SandboxService.ListFiles:input_type -> sandbox.v1.ListFilesRequest
+	35,  // 44: sandbox.v1.SandboxService.PipInstall:input_type -> sandbox.v1.PipInstallRequest
+	37,  // 45: sandbox.v1.SandboxService.RunSubAgent:input_type -> sandbox.v1.RunSubAgentRequest
+	39,  // 46: sandbox.v1.SandboxService.ConfirmAction:input_type -> sandbox.v1.ConfirmActionRequest
+	41,  // 47: sandbox.v1.SandboxService.ApproveAction:input_type -> sandbox.v1.ApproveActionRequest
+	44,  // 48: sandbox.v1.SandboxService.ListApprovals:input_type -> sandbox.v1.ListApprovalsRequest
+	46,  // 49: sandbox.v1.SandboxService.WatchApprovals:input_type -> sandbox.v1.WatchApprovalsRequest
+	48,  // 50: sandbox.v1.SandboxService.QueryAudit:input_type -> sandbox.v1.QueryAuditRequest
+	52,  // 51: sandbox.v1.SandboxService.QueryUsage:input_type -> sandbox.v1.QueryUsageRequest
+	54,  // 52: sandbox.v1.SandboxService.Login:input_type -> sandbox.v1.LoginRequest
+	56,  // 53: sandbox.v1.SandboxService.GetCRL:input_type -> sandbox.v1.GetCRLRequest
+	58,  // 54: sandbox.v1.SandboxService.PollRun:input_type -> sandbox.v1.PollRunRequest
+	60,  // 55: sandbox.v1.SandboxService.GetTranscript:input_type -> sandbox.v1.GetTranscriptRequest
+	62,  // 56: sandbox.v1.SandboxService.GetRunOutput:input_type -> sandbox.v1.GetRunOutputRequest
+	64,  // 57: sandbox.v1.SandboxService.GetEvents:input_type -> sandbox.v1.GetEventsRequest
+	66,  // 58: sandbox.v1.SandboxService.SnapshotPut:input_type -> sandbox.v1.SnapshotPutRequest
+	68,  // 59: sandbox.v1.SandboxService.SnapshotGet:input_type -> sandbox.v1.SnapshotGetRequest
+	70,  // 60: sandbox.v1.SandboxService.SnapshotList:input_type -> sandbox.v1.SnapshotListRequest
+	72,  // 61: sandbox.v1.SandboxService.SnapshotSession:input_type -> sandbox.v1.SnapshotSessionRequest
+	74,  // 62: sandbox.v1.SandboxService.RestoreSession:input_type -> sandbox.v1.RestoreSessionRequest
+	76,  // 63: sandbox.v1.SandboxService.ForkSession:input_type -> sandbox.v1.ForkSessionRequest
+	78,  // 64: sandbox.v1.SandboxService.GetProcesses:input_type -> sandbox.v1.GetProcessesRequest
+	81,  // 65: sandbox.v1.SandboxService.CreateTerminal:input_type -> sandbox.v1.CreateTerminalRequest
+	83,  // 66: sandbox.v1.SandboxService.TerminalStream:input_type -> sandbox.v1.TerminalStreamRequest
+	86,  // 67: sandbox.v1.SandboxService.TerminalWrite:input_type -> sandbox.v1.TerminalWriteRequest
+	88,  // 68: sandbox.v1.SandboxService.TerminalResize:input_type -> sandbox.v1.TerminalResizeRequest
+	90,  // 69: sandbox.v1.SandboxService.TerminalForeground:input_type -> sandbox.v1.TerminalForegroundRequest
+	92,  // 70: sandbox.v1.SandboxService.TerminalSignal:input_type -> sandbox.v1.TerminalSignalRequest
+	94,  // 71: sandbox.v1.SandboxService.TerminalDestroy:input_type -> sandbox.v1.TerminalDestroyRequest
+	96,  // 72: sandbox.v1.SandboxService.ExposeService:input_type -> sandbox.v1.ExposeServiceRequest
+	98,  // 73: sandbox.v1.SandboxService.UnexposeService:input_type -> sandbox.v1.UnexposeServiceRequest
+	101, // 74: sandbox.v1.SandboxService.ListExposed:input_type -> sandbox.v1.ListExposedRequest
+	103, // 75: sandbox.v1.SandboxService.UpdateAllowedHosts:input_type -> sandbox.v1.UpdateAllowedHostsRequest
+	8,   // 76: sandbox.v1.SandboxService.CreateSession:output_type -> sandbox.v1.CreateSessionResponse
+	10,  // 77: sandbox.v1.SandboxService.GetSession:output_type -> sandbox.v1.GetSessionResponse
+	12,  // 78: sandbox.v1.SandboxService.ListSessions:output_type -> sandbox.v1.ListSessionsResponse
+	16,  // 79: sandbox.v1.SandboxService.DestroySession:output_type -> sandbox.v1.DestroySessionResponse
+	18,  // 80: sandbox.v1.SandboxService.PauseSession:output_type -> sandbox.v1.PauseSessionResponse
+	20,  // 81: sandbox.v1.SandboxService.ResumeSession:output_type -> sandbox.v1.ResumeSessionResponse
+	14,  // 82: sandbox.v1.SandboxService.WatchSessions:output_type -> sandbox.v1.SessionEvent
+	22,  // 83: sandbox.v1.SandboxService.Exec:output_type -> sandbox.v1.ExecResponse
+	24,  // 84: sandbox.v1.SandboxService.ExecStream:output_type -> sandbox.v1.ExecStreamResponse
+	25,  // 85: sandbox.v1.SandboxService.ExecStreamV2:output_type -> sandbox.v1.ExecFrame
+	25,  // 86: sandbox.v1.SandboxService.ExecInteractive:output_type -> sandbox.v1.ExecFrame
+	29,  // 87: sandbox.v1.SandboxService.WriteFile:output_type -> sandbox.v1.WriteFileResponse
+	31,  // 88: sandbox.v1.SandboxService.ReadFile:output_type -> sandbox.v1.ReadFileResponse
+	33,  // 89: sandbox.v1.SandboxService.ListFiles:output_type -> sandbox.v1.ListFilesResponse
+	36,  // 90: sandbox.v1.SandboxService.PipInstall:output_type -> sandbox.v1.PipInstallResponse
+	38,  // 91: sandbox.v1.SandboxService.RunSubAgent:output_type -> sandbox.v1.RunSubAgentResponse
+	40,  // 92: sandbox.v1.SandboxService.ConfirmAction:output_type -> sandbox.v1.ConfirmActionResponse
+	42,  // 93: sandbox.v1.SandboxService.ApproveAction:output_type -> sandbox.v1.ApproveActionResponse
+	45,  // 94: sandbox.v1.SandboxService.ListApprovals:output_type -> sandbox.v1.ListApprovalsResponse
+	43,  // 95: sandbox.v1.SandboxService.WatchApprovals:output_type -> sandbox.v1.Approval
+	49,  // 96: sandbox.v1.SandboxService.QueryAudit:output_type -> sandbox.v1.QueryAuditResponse
+	53,  // 97: sandbox.v1.SandboxService.QueryUsage:output_type -> sandbox.v1.QueryUsageResponse
+	55,  // 98: sandbox.v1.SandboxService.Login:output_type -> sandbox.v1.LoginResponse
+	57,  // 99: sandbox.v1.SandboxService.GetCRL:output_type -> sandbox.v1.GetCRLResponse
+	59,  // 100: sandbox.v1.SandboxService.PollRun:output_type -> sandbox.v1.PollRunResponse
+	61,  // 101: sandbox.v1.SandboxService.GetTranscript:output_type -> sandbox.v1.GetTranscriptResponse
+	63,  // 102: sandbox.v1.SandboxService.GetRunOutput:output_type -> sandbox.v1.GetRunOutputResponse
+	65,  // 103: sandbox.v1.SandboxService.GetEvents:output_type -> sandbox.v1.GetEventsResponse
+	67,  // 104: sandbox.v1.SandboxService.SnapshotPut:output_type -> sandbox.v1.SnapshotPutResponse
+	69,  // 105: sandbox.v1.SandboxService.SnapshotGet:output_type -> sandbox.v1.SnapshotGetResponse
+	71,  // 106: sandbox.v1.SandboxService.SnapshotList:output_type -> sandbox.v1.SnapshotListResponse
+	73,  // 107: sandbox.v1.SandboxService.SnapshotSession:output_type -> sandbox.v1.SnapshotSessionResponse
+	75,  // 108: sandbox.v1.SandboxService.RestoreSession:output_type -> sandbox.v1.RestoreSessionResponse
+	77,  // 109: sandbox.v1.SandboxService.ForkSession:output_type -> sandbox.v1.ForkSessionResponse
+	80,  // 110: sandbox.v1.SandboxService.GetProcesses:output_type -> sandbox.v1.GetProcessesResponse
+	82,  // 111: sandbox.v1.SandboxService.CreateTerminal:output_type -> sandbox.v1.CreateTerminalResponse
+	84,  // 112: sandbox.v1.SandboxService.TerminalStream:output_type -> sandbox.v1.TerminalStreamResponse
+	87,  // 113: sandbox.v1.SandboxService.TerminalWrite:output_type -> sandbox.v1.TerminalWriteResponse
+	89,  // 114: sandbox.v1.SandboxService.TerminalResize:output_type -> sandbox.v1.TerminalResizeResponse
+	91,  // 115: sandbox.v1.SandboxService.TerminalForeground:output_type -> sandbox.v1.TerminalForegroundResponse
+	93,  // 116: sandbox.v1.SandboxService.TerminalSignal:output_type -> sandbox.v1.TerminalSignalResponse
+	95,  // 117: sandbox.v1.SandboxService.TerminalDestroy:output_type -> sandbox.v1.TerminalDestroyResponse
+	97,  // 118: sandbox.v1.SandboxService.ExposeService:output_type -> sandbox.v1.ExposeServiceResponse
+	99,  // 119: sandbox.v1.SandboxService.UnexposeService:output_type -> sandbox.v1.UnexposeServiceResponse
+	102, // 120: sandbox.v1.SandboxService.ListExposed:output_type -> sandbox.v1.ListExposedResponse
+	104, // 121: sandbox.v1.SandboxService.UpdateAllowedHosts:output_type -> sandbox.v1.UpdateAllowedHostsResponse
+	76,  // [76:122] is the sub-list for method output_type
+	30,  // [30:76] is the sub-list for method input_type
+	30,  // [30:30] is the sub-list for extension type_name
+	30,  // [30:30] is the sub-list for extension extendee
+	0,   // [0:30] is the sub-list for field type_name
 }
 
 func init() { file_sandbox_v1_sandbox_proto_init() }
@@ -4556,7 +7672,17 @@ func file_sandbox_v1_sandbox_proto_init() {
 	if File_sandbox_v1_sandbox_proto != nil {
 		return
 	}
-	file_sandbox_v1_sandbox_proto_msgTypes[51].OneofWrappers = []any{
+	file_sandbox_v1_sandbox_proto_msgTypes[21].OneofWrappers = []any{
+		(*ExecFrame_Stdout)(nil),
+		(*ExecFrame_Stderr)(nil),
+		(*ExecFrame_Exit)(nil),
+	}
+	file_sandbox_v1_sandbox_proto_msgTypes[23].OneofWrappers = []any{
+		(*ExecInput_Start)(nil),
+		(*ExecInput_Stdin)(nil),
+		(*ExecInput_CloseStdin)(nil),
+	}
+	file_sandbox_v1_sandbox_proto_msgTypes[80].OneofWrappers = []any{
 		(*TerminalStreamResponse_Data)(nil),
 		(*TerminalStreamResponse_Exit)(nil),
 	}
@@ -4565,8 +7691,8 @@ func file_sandbox_v1_sandbox_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sandbox_v1_sandbox_proto_rawDesc), len(file_sandbox_v1_sandbox_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   74,
+			NumEnums:      4,
+			NumMessages:   103,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
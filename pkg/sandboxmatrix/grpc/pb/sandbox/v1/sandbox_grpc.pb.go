@@ -25,8 +25,11 @@ const (
 	SandboxService_DestroySession_FullMethodName     = "/sandbox.v1.SandboxService/DestroySession"
 	SandboxService_PauseSession_FullMethodName       = "/sandbox.v1.SandboxService/PauseSession"
 	SandboxService_ResumeSession_FullMethodName      = "/sandbox.v1.SandboxService/ResumeSession"
+	SandboxService_WatchSessions_FullMethodName      = "/sandbox.v1.SandboxService/WatchSessions"
 	SandboxService_Exec_FullMethodName               = "/sandbox.v1.SandboxService/Exec"
 	SandboxService_ExecStream_FullMethodName         = "/sandbox.v1.SandboxService/ExecStream"
+	SandboxService_ExecStreamV2_FullMethodName       = "/sandbox.v1.SandboxService/ExecStreamV2"
+	SandboxService_ExecInteractive_FullMethodName    = "/sandbox.v1.SandboxService/ExecInteractive"
 	SandboxService_WriteFile_FullMethodName          = "/sandbox.v1.SandboxService/WriteFile"
 	SandboxService_ReadFile_FullMethodName           = "/sandbox.v1.SandboxService/ReadFile"
 	SandboxService_ListFiles_FullMethodName          = "/sandbox.v1.SandboxService/ListFiles"
@@ -34,13 +37,22 @@ const (
 	SandboxService_RunSubAgent_FullMethodName        = "/sandbox.v1.SandboxService/RunSubAgent"
 	SandboxService_ConfirmAction_FullMethodName      = "/sandbox.v1.SandboxService/ConfirmAction"
 	SandboxService_ApproveAction_FullMethodName      = "/sandbox.v1.SandboxService/ApproveAction"
+	SandboxService_ListApprovals_FullMethodName      = "/sandbox.v1.SandboxService/ListApprovals"
+	SandboxService_WatchApprovals_FullMethodName     = "/sandbox.v1.SandboxService/WatchApprovals"
+	SandboxService_QueryAudit_FullMethodName         = "/sandbox.v1.SandboxService/QueryAudit"
+	SandboxService_QueryUsage_FullMethodName         = "/sandbox.v1.SandboxService/QueryUsage"
 	SandboxService_Login_FullMethodName              = "/sandbox.v1.SandboxService/Login"
+	SandboxService_GetCRL_FullMethodName             = "/sandbox.v1.SandboxService/GetCRL"
 	SandboxService_PollRun_FullMethodName            = "/sandbox.v1.SandboxService/PollRun"
 	SandboxService_GetTranscript_FullMethodName      = "/sandbox.v1.SandboxService/GetTranscript"
+	SandboxService_GetRunOutput_FullMethodName       = "/sandbox.v1.SandboxService/GetRunOutput"
 	SandboxService_GetEvents_FullMethodName          = "/sandbox.v1.SandboxService/GetEvents"
 	SandboxService_SnapshotPut_FullMethodName        = "/sandbox.v1.SandboxService/SnapshotPut"
 	SandboxService_SnapshotGet_FullMethodName        = "/sandbox.v1.SandboxService/SnapshotGet"
 	SandboxService_SnapshotList_FullMethodName       = "/sandbox.v1.SandboxService/SnapshotList"
+	SandboxService_SnapshotSession_FullMethodName    = "/sandbox.v1.SandboxService/SnapshotSession"
+	SandboxService_RestoreSession_FullMethodName     = "/sandbox.v1.SandboxService/RestoreSession"
+	SandboxService_ForkSession_FullMethodName        = "/sandbox.v1.SandboxService/ForkSession"
 	SandboxService_GetProcesses_FullMethodName       = "/sandbox.v1.SandboxService/GetProcesses"
 	SandboxService_CreateTerminal_FullMethodName     = "/sandbox.v1.SandboxService/CreateTerminal"
 	SandboxService_TerminalStream_FullMethodName     = "/sandbox.v1.SandboxService/TerminalStream"
@@ -68,8 +80,18 @@ type SandboxServiceClient interface {
 	// with its filesystem intact (E2B pause semantics, KIP-18).
 	PauseSession(ctx context.Context, in *PauseSessionRequest, opts ...grpc.CallOption) (*PauseSessionResponse, error)
 	ResumeSession(ctx context.Context, in *ResumeSessionRequest, opts ...grpc.CallOption) (*ResumeSessionResponse, error)
+	// WatchSessions streams session lifecycle events (phase changes, TTL
+	// expiry warnings, background run completions, expose/unexpose and
+	// allowed-host changes) until the client goes away.
+	WatchSessions(ctx context.Context, in *WatchSessionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionEvent], error)
 	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error)
 	ExecStream(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecStreamResponse], error)
+	// ExecStreamV2 streams typed frames: stdout and stderr kept apart, then one
+	// final exit frame (code, signal, timed_out, duration).
+	ExecStreamV2(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecFrame], error)
+	// ExecInteractive is ExecStreamV2 with stdin: the first message must be
+	// start, then any number of stdin frames and an optional close_stdin.
+	ExecInteractive(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ExecInput, ExecFrame], error)
 	WriteFile(ctx context.Context, in *WriteFileRequest, opts ...grpc.CallOption) (*WriteFileResponse, error)
 	ReadFile(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (*ReadFileResponse, error)
 	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
@@ -77,15 +99,34 @@ type SandboxServiceClient interface {
 	RunSubAgent(ctx context.Context, in *RunSubAgentRequest, opts ...grpc.CallOption) (*RunSubAgentResponse, error)
 	ConfirmAction(ctx context.Context, in *ConfirmActionRequest, opts ...grpc.CallOption) (*ConfirmActionResponse, error)
 	ApproveAction(ctx context.Context, in *ApproveActionRequest, opts ...grpc.CallOption) (*ApproveActionResponse, error)
+	// ListApprovals returns approvals (SandboxApproval CRDs, pending and
+	// decided); WatchApprovals sends the current set, then every change.
+	ListApprovals(ctx context.Context, in *ListApprovalsRequest, opts ...grpc.CallOption) (*ListApprovalsResponse, error)
+	WatchApprovals(ctx context.Context, in *WatchApprovalsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Approval], error)
+	// QueryAudit reads this gateway node's audit log (one record per gateway
+	// RPC and E2B control call), oldest first. Logs are per node: other
+	// nodes' records need a query against each of them. Certificate callers
+	// see only their own tenant's records.
+	QueryAudit(ctx context.Context, in *QueryAuditRequest, opts ...grpc.CallOption) (*QueryAuditResponse, error)
+	// QueryUsage reads the cluster-wide usage ledger (one record per
+	// terminated session) with per-tenant totals, optionally adding the live
+	// usage of sessions that have not ended yet.
+	QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error)
 	// Login authenticates the client and returns a signed X.509 certificate for mTLS.
 	// First login: pass API key via gRPC metadata (authorization: Bearer <key>).
 	// Renewal: present existing valid client cert via mTLS — no API key needed.
 	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// GetCRL returns the sandbox CA's signed X.509 CRL built from the
+	// cluster-wide revocation list (no client certificate required).
+	GetCRL(ctx context.Context, in *GetCRLRequest, opts ...grpc.CallOption) (*GetCRLResponse, error)
 	// PollRun checks the status of a background execution.
 	PollRun(ctx context.Context, in *PollRunRequest, opts ...grpc.CallOption) (*PollRunResponse, error)
 	// GetTranscript reads a bounded window of a session's exec transcript
 	// (file-backed, offset-resumable; KIP-16 M4 / issue #512).
 	GetTranscript(ctx context.Context, in *GetTranscriptRequest, opts ...grpc.CallOption) (*GetTranscriptResponse, error)
+	// GetRunOutput pages the full stdout/stderr of a sync or background run
+	// whose Exec/PollRun response was truncated (see OutputRef).
+	GetRunOutput(ctx context.Context, in *GetRunOutputRequest, opts ...grpc.CallOption) (*GetRunOutputResponse, error)
 	// GetEvents reads the daemon's NDJSON event stream (exec/files/bg events;
 	// KIP-16 M5 / issue #513).
 	GetEvents(ctx context.Context, in *GetEventsRequest, opts ...grpc.CallOption) (*GetEventsResponse, error)
@@ -94,6 +135,15 @@ type SandboxServiceClient interface {
 	SnapshotPut(ctx context.Context, in *SnapshotPutRequest, opts ...grpc.CallOption) (*SnapshotPutResponse, error)
 	SnapshotGet(ctx context.Context, in *SnapshotGetRequest, opts ...grpc.CallOption) (*SnapshotGetResponse, error)
 	SnapshotList(ctx context.Context, in *SnapshotListRequest, opts ...grpc.CallOption) (*SnapshotListResponse, error)
+	// SnapshotSession archives a session's /workspace straight into the
+	// registry as content-defined chunks; RestoreSession unpacks a registry
+	// snapshot into a session, shipping only layers the sandbox lacks.
+	SnapshotSession(ctx context.Context, in *SnapshotSessionRequest, opts ...grpc.CallOption) (*SnapshotSessionResponse, error)
+	RestoreSession(ctx context.Context, in *RestoreSessionRequest, opts ...grpc.CallOption) (*RestoreSessionResponse, error)
+	// ForkSession snapshots a session's /workspace and hydrates count new,
+	// independent sessions from it with the parent's env, secret refs, allowed
+	// hosts and template. Each child records its lineage in spec.lineage.
+	ForkSession(ctx context.Context, in *ForkSessionRequest, opts ...grpc.CallOption) (*ForkSessionResponse, error)
 	// GetProcesses lists processes in the sandbox pod (KIP-16 M5 process topology).
 	GetProcesses(ctx context.Context, in *GetProcessesRequest, opts ...grpc.CallOption) (*GetProcessesResponse, error)
 	// ── PTY terminal primitive (KIP-19) ───────────────────────────────────────
@@ -189,6 +239,25 @@ func (c *sandboxServiceClient) ResumeSession(ctx context.Context, in *ResumeSess
 	return out, nil
 }
 
+func (c *sandboxServiceClient) WatchSessions(ctx context.Context, in *WatchSessionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SessionEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[0], SandboxService_WatchSessions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchSessionsRequest, SessionEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_WatchSessionsClient = grpc.ServerStreamingClient[SessionEvent]
+
 func (c *sandboxServiceClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ExecResponse)
@@ -201,7 +270,7 @@ func (c *sandboxServiceClient) Exec(ctx context.Context, in *ExecRequest, opts .
 
 func (c *sandboxServiceClient) ExecStream(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecStreamResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[0], SandboxService_ExecStream_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[1], SandboxService_ExecStream_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +287,38 @@ func (c *sandboxServiceClient) ExecStream(ctx context.Context, in *ExecRequest,
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SandboxService_ExecStreamClient = grpc.ServerStreamingClient[ExecStreamResponse]
 
+func (c *sandboxServiceClient) ExecStreamV2(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecFrame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[2], SandboxService_ExecStreamV2_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExecRequest, ExecFrame]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_ExecStreamV2Client = grpc.ServerStreamingClient[ExecFrame]
+
+func (c *sandboxServiceClient) ExecInteractive(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ExecInput, ExecFrame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[3], SandboxService_ExecInteractive_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExecInput, ExecFrame]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_ExecInteractiveClient = grpc.BidiStreamingClient[ExecInput, ExecFrame]
+
 func (c *sandboxServiceClient) WriteFile(ctx context.Context, in *WriteFileRequest, opts ...grpc.CallOption) (*WriteFileResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(WriteFileResponse)
@@ -288,6 +389,55 @@ func (c *sandboxServiceClient) ApproveAction(ctx context.Context, in *ApproveAct
 	return out, nil
 }
 
+func (c *sandboxServiceClient) ListApprovals(ctx context.Context, in *ListApprovalsRequest, opts ...grpc.CallOption) (*ListApprovalsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListApprovalsResponse)
+	err := c.cc.Invoke(ctx, SandboxService_ListApprovals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxServiceClient) WatchApprovals(ctx context.Context, in *WatchApprovalsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Approval], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[4], SandboxService_WatchApprovals_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchApprovalsRequest, Approval]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_WatchApprovalsClient = grpc.ServerStreamingClient[Approval]
+
+func (c *sandboxServiceClient) QueryAudit(ctx context.Context, in *QueryAuditRequest, opts ...grpc.CallOption) (*QueryAuditResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryAuditResponse)
+	err := c.cc.Invoke(ctx, SandboxService_QueryAudit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxServiceClient) QueryUsage(ctx context.Context, in *QueryUsageRequest, opts ...grpc.CallOption) (*QueryUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryUsageResponse)
+	err := c.cc.Invoke(ctx, SandboxService_QueryUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sandboxServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(LoginResponse)
@@ -298,6 +448,16 @@ func (c *sandboxServiceClient) Login(ctx context.Context, in *LoginRequest, opts
 	return out, nil
 }
 
+func (c *sandboxServiceClient) GetCRL(ctx context.Context, in *GetCRLRequest, opts ...grpc.CallOption) (*GetCRLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCRLResponse)
+	err := c.cc.Invoke(ctx, SandboxService_GetCRL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sandboxServiceClient) PollRun(ctx context.Context, in *PollRunRequest, opts ...grpc.CallOption) (*PollRunResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(PollRunResponse)
@@ -318,6 +478,16 @@ func (c *sandboxServiceClient) GetTranscript(ctx context.Context, in *GetTranscr
 	return out, nil
 }
 
+func (c *sandboxServiceClient) GetRunOutput(ctx context.Context, in *GetRunOutputRequest, opts ...grpc.CallOption) (*GetRunOutputResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRunOutputResponse)
+	err := c.cc.Invoke(ctx, SandboxService_GetRunOutput_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sandboxServiceClient) GetEvents(ctx context.Context, in *GetEventsRequest, opts ...grpc.CallOption) (*GetEventsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetEventsResponse)
@@ -358,6 +528,36 @@ func (c *sandboxServiceClient) SnapshotList(ctx context.Context, in *SnapshotLis
 	return out, nil
 }
 
+func (c *sandboxServiceClient) SnapshotSession(ctx context.Context, in *SnapshotSessionRequest, opts ...grpc.CallOption) (*SnapshotSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotSessionResponse)
+	err := c.cc.Invoke(ctx, SandboxService_SnapshotSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxServiceClient) RestoreSession(ctx context.Context, in *RestoreSessionRequest, opts ...grpc.CallOption) (*RestoreSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreSessionResponse)
+	err := c.cc.Invoke(ctx, SandboxService_RestoreSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxServiceClient) ForkSession(ctx context.Context, in *ForkSessionRequest, opts ...grpc.CallOption) (*ForkSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ForkSessionResponse)
+	err := c.cc.Invoke(ctx, SandboxService_ForkSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *sandboxServiceClient) GetProcesses(ctx context.Context, in *GetProcessesRequest, opts ...grpc.CallOption) (*GetProcessesResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetProcessesResponse)
@@ -380,7 +580,7 @@ func (c *sandboxServiceClient) CreateTerminal(ctx context.Context, in *CreateTer
 
 func (c *sandboxServiceClient) TerminalStream(ctx context.Context, in *TerminalStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TerminalStreamResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[1], SandboxService_TerminalStream_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &SandboxService_ServiceDesc.Streams[5], SandboxService_TerminalStream_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -500,8 +700,18 @@ type SandboxServiceServer interface {
 	// with its filesystem intact (E2B pause semantics, KIP-18).
 	PauseSession(context.Context, *PauseSessionRequest) (*PauseSessionResponse, error)
 	ResumeSession(context.Context, *ResumeSessionRequest) (*ResumeSessionResponse, error)
+	// WatchSessions streams session lifecycle events (phase changes, TTL
+	// expiry warnings, background run completions, expose/unexpose and
+	// allowed-host changes) until the client goes away.
+	WatchSessions(*WatchSessionsRequest, grpc.ServerStreamingServer[SessionEvent]) error
 	Exec(context.Context, *ExecRequest) (*ExecResponse, error)
 	ExecStream(*ExecRequest, grpc.ServerStreamingServer[ExecStreamResponse]) error
+	// ExecStreamV2 streams typed frames: stdout and stderr kept apart, then one
+	// final exit frame (code, signal, timed_out, duration).
+	ExecStreamV2(*ExecRequest, grpc.ServerStreamingServer[ExecFrame]) error
+	// ExecInteractive is ExecStreamV2 with stdin: the first message must be
+	// start, then any number of stdin frames and an optional close_stdin.
+	ExecInteractive(grpc.BidiStreamingServer[ExecInput, ExecFrame]) error
 	WriteFile(context.Context, *WriteFileRequest) (*WriteFileResponse, error)
 	ReadFile(context.Context, *ReadFileRequest) (*ReadFileResponse, error)
 	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
@@ -509,15 +719,34 @@ type SandboxServiceServer interface {
 	RunSubAgent(context.Context, *RunSubAgentRequest) (*RunSubAgentResponse, error)
 	ConfirmAction(context.Context, *ConfirmActionRequest) (*ConfirmActionResponse, error)
 	ApproveAction(context.Context, *ApproveActionRequest) (*ApproveActionResponse, error)
+	// ListApprovals returns approvals (SandboxApproval CRDs, pending and
+	// decided); WatchApprovals sends the current set, then every change.
+	ListApprovals(context.Context, *ListApprovalsRequest) (*ListApprovalsResponse, error)
+	WatchApprovals(*WatchApprovalsRequest, grpc.ServerStreamingServer[Approval]) error
+	// QueryAudit reads this gateway node's audit log (one record per gateway
+	// RPC and E2B control call), oldest first. Logs are per node: other
+	// nodes' records need a query against each of them. Certificate callers
+	// see only their own tenant's records.
+	QueryAudit(context.Context, *QueryAuditRequest) (*QueryAuditResponse, error)
+	// QueryUsage reads the cluster-wide usage ledger (one record per
+	// terminated session) with per-tenant totals, optionally adding the live
+	// usage of sessions that have not ended yet.
+	QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error)
 	// Login authenticates the client and returns a signed X.509 certificate for mTLS.
 	// First login: pass API key via gRPC metadata (authorization: Bearer <key>).
 	// Renewal: present existing valid client cert via mTLS — no API key needed.
 	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// GetCRL returns the sandbox CA's signed X.509 CRL built from the
+	// cluster-wide revocation list (no client certificate required).
+	GetCRL(context.Context, *GetCRLRequest) (*GetCRLResponse, error)
 	// PollRun checks the status of a background execution.
 	PollRun(context.Context, *PollRunRequest) (*PollRunResponse, error)
 	// GetTranscript reads a bounded window of a session's exec transcript
 	// (file-backed, offset-resumable; KIP-16 M4 / issue #512).
 	GetTranscript(context.Context, *GetTranscriptRequest) (*GetTranscriptResponse, error)
+	// GetRunOutput pages the full stdout/stderr of a sync or background run
+	// whose Exec/PollRun response was truncated (see OutputRef).
+	GetRunOutput(context.Context, *GetRunOutputRequest) (*GetRunOutputResponse, error)
 	// GetEvents reads the daemon's NDJSON event stream (exec/files/bg events;
 	// KIP-16 M5 / issue #513).
 	GetEvents(context.Context, *GetEventsRequest) (*GetEventsResponse, error)
@@ -526,6 +755,15 @@ type SandboxServiceServer interface {
 	SnapshotPut(context.Context, *SnapshotPutRequest) (*SnapshotPutResponse, error)
 	SnapshotGet(context.Context, *SnapshotGetRequest) (*SnapshotGetResponse, error)
 	SnapshotList(context.Context, *SnapshotListRequest) (*SnapshotListResponse, error)
+	// SnapshotSession archives a session's /workspace straight into the
+	// registry as content-defined chunks; RestoreSession unpacks a registry
+	// snapshot into a session, shipping only layers the sandbox lacks.
+	SnapshotSession(context.Context, *SnapshotSessionRequest) (*SnapshotSessionResponse, error)
+	RestoreSession(context.Context, *RestoreSessionRequest) (*RestoreSessionResponse, error)
+	// ForkSession snapshots a session's /workspace and hydrates count new,
+	// independent sessions from it with the parent's env, secret refs, allowed
+	// hosts and template. Each child records its lineage in spec.lineage.
+	ForkSession(context.Context, *ForkSessionRequest) (*ForkSessionResponse, error)
 	// GetProcesses lists processes in the sandbox pod (KIP-16 M5 process topology).
 	GetProcesses(context.Context, *GetProcessesRequest) (*GetProcessesResponse, error)
 	// ── PTY terminal primitive (KIP-19) ───────────────────────────────────────
@@ -579,12 +817,21 @@ func (UnimplementedSandboxServiceServer) PauseSession(context.Context, *PauseSes
 func (UnimplementedSandboxServiceServer) ResumeSession(context.Context, *ResumeSessionRequest) (*ResumeSessionResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ResumeSession not implemented")
 }
+func (UnimplementedSandboxServiceServer) WatchSessions(*WatchSessionsRequest, grpc.ServerStreamingServer[SessionEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchSessions not implemented")
+}
 func (UnimplementedSandboxServiceServer) Exec(context.Context, *ExecRequest) (*ExecResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Exec not implemented")
 }
 func (UnimplementedSandboxServiceServer) ExecStream(*ExecRequest, grpc.ServerStreamingServer[ExecStreamResponse]) error {
 	return status.Error(codes.Unimplemented, "method ExecStream not implemented")
 }
+func (UnimplementedSandboxServiceServer) ExecStreamV2(*ExecRequest, grpc.ServerStreamingServer[ExecFrame]) error {
+	return status.Error(codes.Unimplemented, "method ExecStreamV2 not implemented")
+}
+func (UnimplementedSandboxServiceServer) ExecInteractive(grpc.BidiStreamingServer[ExecInput, ExecFrame]) error {
+	return status.Error(codes.Unimplemented, "method ExecInteractive not implemented")
+}
 func (UnimplementedSandboxServiceServer) WriteFile(context.Context, *WriteFileRequest) (*WriteFileResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method WriteFile not implemented")
 }
@@ -606,15 +853,33 @@ func (UnimplementedSandboxServiceServer) ConfirmAction(context.Context, *Confirm
 func (UnimplementedSandboxServiceServer) ApproveAction(context.Context, *ApproveActionRequest) (*ApproveActionResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ApproveAction not implemented")
 }
+func (UnimplementedSandboxServiceServer) ListApprovals(context.Context, *ListApprovalsRequest) (*ListApprovalsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListApprovals not implemented")
+}
+func (UnimplementedSandboxServiceServer) WatchApprovals(*WatchApprovalsRequest, grpc.ServerStreamingServer[Approval]) error {
+	return status.Error(codes.Unimplemented, "method WatchApprovals not implemented")
+}
+func (UnimplementedSandboxServiceServer) QueryAudit(context.Context, *QueryAuditRequest) (*QueryAuditResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryAudit not implemented")
+}
+func (UnimplementedSandboxServiceServer) QueryUsage(context.Context, *QueryUsageRequest) (*QueryUsageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryUsage not implemented")
+}
 func (UnimplementedSandboxServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Login not implemented")
 }
+func (UnimplementedSandboxServiceServer) GetCRL(context.Context, *GetCRLRequest) (*GetCRLResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetCRL not implemented")
+}
 func (UnimplementedSandboxServiceServer) PollRun(context.Context, *PollRunRequest) (*PollRunResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method PollRun not implemented")
 }
 func (UnimplementedSandboxServiceServer) GetTranscript(context.Context, *GetTranscriptRequest) (*GetTranscriptResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetTranscript not implemented")
 }
+func (UnimplementedSandboxServiceServer) GetRunOutput(context.Context, *GetRunOutputRequest) (*GetRunOutputResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetRunOutput not implemented")
+}
 func (UnimplementedSandboxServiceServer) GetEvents(context.Context, *GetEventsRequest) (*GetEventsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetEvents not implemented")
 }
@@ -627,6 +892,15 @@ func (UnimplementedSandboxServiceServer) SnapshotGet(context.Context, *SnapshotG
 func (UnimplementedSandboxServiceServer) SnapshotList(context.Context, *SnapshotListRequest) (*SnapshotListResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method SnapshotList not implemented")
 }
+func (UnimplementedSandboxServiceServer) SnapshotSession(context.Context, *SnapshotSessionRequest) (*SnapshotSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SnapshotSession not implemented")
+}
+func (UnimplementedSandboxServiceServer) RestoreSession(context.Context, *RestoreSessionRequest) (*RestoreSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreSession not implemented")
+}
+func (UnimplementedSandboxServiceServer) ForkSession(context.Context, *ForkSessionRequest) (*ForkSessionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ForkSession not implemented")
+}
 func (UnimplementedSandboxServiceServer) GetProcesses(context.Context, *GetProcessesRequest) (*GetProcessesResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetProcesses not implemented")
 }
@@ -792,6 +1066,17 @@ func _SandboxService_ResumeSession_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SandboxService_WatchSessions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSessionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SandboxServiceServer).WatchSessions(m, &grpc.GenericServerStream[WatchSessionsRequest, SessionEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_WatchSessionsServer = grpc.ServerStreamingServer[SessionEvent]
+
 func _SandboxService_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ExecRequest)
 	if err := dec(in); err != nil {
@@ -821,6 +1106,24 @@ func _SandboxService_ExecStream_Handler(srv interface{}, stream grpc.ServerStrea
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SandboxService_ExecStreamServer = grpc.ServerStreamingServer[ExecStreamResponse]
 
+func _SandboxService_ExecStreamV2_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SandboxServiceServer).ExecStreamV2(m, &grpc.GenericServerStream[ExecRequest, ExecFrame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_ExecStreamV2Server = grpc.ServerStreamingServer[ExecFrame]
+
+func _SandboxService_ExecInteractive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SandboxServiceServer).ExecInteractive(&grpc.GenericServerStream[ExecInput, ExecFrame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_ExecInteractiveServer = grpc.BidiStreamingServer[ExecInput, ExecFrame]
+
 func _SandboxService_WriteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(WriteFileRequest)
 	if err := dec(in); err != nil {
@@ -947,6 +1250,71 @@ func _SandboxService_ApproveAction_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SandboxService_ListApprovals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListApprovalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).ListApprovals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_ListApprovals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).ListApprovals(ctx, req.(*ListApprovalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SandboxService_WatchApprovals_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchApprovalsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SandboxServiceServer).WatchApprovals(m, &grpc.GenericServerStream[WatchApprovalsRequest, Approval]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SandboxService_WatchApprovalsServer = grpc.ServerStreamingServer[Approval]
+
+func _SandboxService_QueryAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).QueryAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_QueryAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).QueryAudit(ctx, req.(*QueryAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SandboxService_QueryUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).QueryUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_QueryUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).QueryUsage(ctx, req.(*QueryUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SandboxService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(LoginRequest)
 	if err := dec(in); err != nil {
@@ -965,6 +1333,24 @@ func _SandboxService_Login_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SandboxService_GetCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCRLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).GetCRL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_GetCRL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).GetCRL(ctx, req.(*GetCRLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SandboxService_PollRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PollRunRequest)
 	if err := dec(in); err != nil {
@@ -1001,6 +1387,24 @@ func _SandboxService_GetTranscript_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SandboxService_GetRunOutput_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunOutputRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).GetRunOutput(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_GetRunOutput_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).GetRunOutput(ctx, req.(*GetRunOutputRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SandboxService_GetEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetEventsRequest)
 	if err := dec(in); err != nil {
@@ -1073,6 +1477,60 @@ func _SandboxService_SnapshotList_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SandboxService_SnapshotSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).SnapshotSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_SnapshotSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).SnapshotSession(ctx, req.(*SnapshotSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SandboxService_RestoreSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).RestoreSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_RestoreSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).RestoreSession(ctx, req.(*RestoreSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SandboxService_ForkSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForkSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServiceServer).ForkSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SandboxService_ForkSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServiceServer).ForkSession(ctx, req.(*ForkSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SandboxService_GetProcesses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetProcessesRequest)
 	if err := dec(in); err != nil {
@@ -1345,10 +1803,26 @@ var SandboxService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ApproveAction",
 			Handler:    _SandboxService_ApproveAction_Handler,
 		},
+		{
+			MethodName: "ListApprovals",
+			Handler:    _SandboxService_ListApprovals_Handler,
+		},
+		{
+			MethodName: "QueryAudit",
+			Handler:    _SandboxService_QueryAudit_Handler,
+		},
+		{
+			MethodName: "QueryUsage",
+			Handler:    _SandboxService_QueryUsage_Handler,
+		},
 		{
 			MethodName: "Login",
 			Handler:    _SandboxService_Login_Handler,
 		},
+		{
+			MethodName: "GetCRL",
+			Handler:    _SandboxService_GetCRL_Handler,
+		},
 		{
 			MethodName: "PollRun",
 			Handler:    _SandboxService_PollRun_Handler,
@@ -1357,6 +1831,10 @@ var SandboxService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTranscript",
 			Handler:    _SandboxService_GetTranscript_Handler,
 		},
+		{
+			MethodName: "GetRunOutput",
+			Handler:    _SandboxService_GetRunOutput_Handler,
+		},
 		{
 			MethodName: "GetEvents",
 			Handler:    _SandboxService_GetEvents_Handler,
@@ -1373,6 +1851,18 @@ var SandboxService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SnapshotList",
 			Handler:    _SandboxService_SnapshotList_Handler,
 		},
+		{
+			MethodName: "SnapshotSession",
+			Handler:    _SandboxService_SnapshotSession_Handler,
+		},
+		{
+			MethodName: "RestoreSession",
+			Handler:    _SandboxService_RestoreSession_Handler,
+		},
+		{
+			MethodName: "ForkSession",
+			Handler:    _SandboxService_ForkSession_Handler,
+		},
 		{
 			MethodName: "GetProcesses",
 			Handler:    _SandboxService_GetProcesses_Handler,
@@ -1419,11 +1909,32 @@ var SandboxService_ServiceDesc = grpc.ServiceDesc{
 		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSessions",
+			Handler:       _SandboxService_WatchSessions_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "ExecStream",
 			Handler:       _SandboxService_ExecStream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ExecStreamV2",
+			Handler:       _SandboxService_ExecStreamV2_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExecInteractive",
+			Handler:       _SandboxService_ExecInteractive_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchApprovals",
+			Handler:       _SandboxService_WatchApprovals_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "TerminalStream",
 			Handler:       _SandboxService_TerminalStream_Handler,
@@ -0,0 +1,523 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+)
+
+// Per-tenant quotas (SandboxTenantQuota). The rate limiter bounds how fast a
+// tenant calls the gateway and CheckCapacity bounds the node as a whole;
+// quotas bound what one tenant holds at once, so a single team cannot take
+// every pod, run or port on a shared node. Usage is computed from cluster
+// state at admission time: the session CRDs, their run annotations and
+// exposed ports. Admissions are serialized across gateway replicas by
+// reservations written to the quota's status under its resourceVersion: a
+// replica that admits from a stale read loses the update and re-checks.
+
+// QuotaGVR is the SandboxTenantQuota resource. Exported for the controller.
+var QuotaGVR = schema.GroupVersionResource{Group: sandboxAPIGroup, Version: "v1alpha1", Resource: "sandboxtenantquotas"}
+
+const (
+	// tenantAnnotation records the tenant a session counts against (see
+	// requestTenant), so ephemeral sessions (no tenant_id, no PVC) are still
+	// accounted.
+	tenantAnnotation = "sandbox.k8e.io/tenant"
+	// cpuAnnotation and memoryAnnotation record the pod limits a session was
+	// admitted with, so usage does not depend on pod listing.
+	cpuAnnotation    = "sandbox.k8e.io/cpu"
+	memoryAnnotation = "sandbox.k8e.io/memory"
+
+	tenantHeader = "x-sandbox-tenant"
+
+	// defaultQuotaTenant is the quota tenant of callers without one: a
+	// SandboxTenantQuota for "default" caps them together.
+	defaultQuotaTenant = "default"
+
+	// quotaReservationTTL bounds how long a reservation outlives a gateway
+	// that crashed before releasing it.
+	quotaReservationTTL = 5 * time.Minute
+)
+
+// Reservation kinds (QuotaReservation.Kind).
+const (
+	reserveSession       = "session"
+	reserveBackgroundRun = "backgroundRun"
+	reserveExposedPort   = "exposedPort"
+	reserveSnapshot      = "snapshot"
+)
+
+// requestTenant is the tenant a request acts for. A caller authenticated by
+// client certificate acts for its API key name, whatever it claims; trusted
+// loopback callers (the embedded e2b server, the node's own CLI) pass an
+// explicit tenant_id, else the x-sandbox-tenant header the rate limiter
+// also keys on.
+func requestTenant(ctx context.Context, explicit string) string {
+	if name, _ := peerIdentity(ctx); name != "" {
+		return name
+	}
+	if explicit != "" {
+		return explicit
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(tenantHeader); len(vals) > 0 {
+			return vals[0]
+		}
+	}
+	return ""
+}
+
+// sessionTenant is the tenant a session counts against.
+func sessionTenant(s *sandboxv1.SandboxSession) string {
+	if t := s.Annotations[tenantAnnotation]; t != "" {
+		return t
+	}
+	return s.Spec.TenantID
+}
+
+// quotaSubject is the tenant whose quota covers tenant: tenantless sessions
+// and requests count against defaultQuotaTenant.
+func quotaSubject(tenant string) string {
+	if tenant == "" {
+		return defaultQuotaTenant
+	}
+	return tenant
+}
+
+// quotaTenant is the tenant a quota applies to: spec.tenantID, else its name.
+func quotaTenant(q *sandboxv1.SandboxTenantQuota) string {
+	if q.Spec.TenantID != "" {
+		return q.Spec.TenantID
+	}
+	return q.Name
+}
+
+// listQuotas returns every SandboxTenantQuota. A cluster without the CRD
+// has no quotas.
+func (o *Orchestrator) listQuotas(ctx context.Context) ([]*sandboxv1.SandboxTenantQuota, error) {
+	list, err := o.dynamic.Resource(QuotaGVR).Namespace(sandboxNS).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	quotas := make([]*sandboxv1.SandboxTenantQuota, 0, len(list.Items))
+	for i := range list.Items {
+		var q sandboxv1.SandboxTenantQuota
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, &q); err != nil {
+			logrus.Warnf("sandbox quota %s: %v", list.Items[i].GetName(), err)
+			continue
+		}
+		quotas = append(quotas, &q)
+	}
+	return quotas, nil
+}
+
+// tenantQuota returns the quota for tenant, or nil when it has none. Quota
+// lookups fail closed: when the quota list cannot be read the request is
+// refused with Unavailable rather than admitted unchecked.
+func (o *Orchestrator) tenantQuota(ctx context.Context, tenant string) (*sandboxv1.SandboxTenantQuota, error) {
+	if tenant == "" {
+		return nil, nil
+	}
+	quotas, err := o.listQuotas(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "quota: look up tenant %s: %v", tenant, err)
+	}
+	for _, q := range quotas {
+		if quotaTenant(q) == tenant {
+			return q, nil
+		}
+	}
+	return nil, nil
+}
+
+// tenantUsage is what a tenant holds, excluding snapshots (see
+// Server.snapshotUsage).
+type tenantUsage struct {
+	sessions       int
+	backgroundRuns int
+	exposedPorts   int
+	cpu, memory    resource.Quantity
+}
+
+// reserved adds the quota's unexpired reservations to u.
+func (u tenantUsage) reserved(q *sandboxv1.SandboxTenantQuota, now time.Time) tenantUsage {
+	for _, r := range liveReservations(q, now) {
+		switch r.Kind {
+		case reserveSession:
+			u.sessions++
+			if c, err := resource.ParseQuantity(r.CPU); err == nil {
+				u.cpu.Add(c)
+			}
+			if m, err := resource.ParseQuantity(r.Memory); err == nil {
+				u.memory.Add(m)
+			}
+		case reserveBackgroundRun:
+			u.backgroundRuns++
+		case reserveExposedPort:
+			u.exposedPorts++
+		}
+	}
+	return u
+}
+
+// reservedBytes sums the quota's unexpired snapshot reservations.
+func reservedBytes(q *sandboxv1.SandboxTenantQuota, now time.Time) int64 {
+	var n int64
+	for _, r := range liveReservations(q, now) {
+		if r.Kind == reserveSnapshot {
+			n += r.Bytes
+		}
+	}
+	return n
+}
+
+// liveReservations is the quota's reservations that have not expired.
+func liveReservations(q *sandboxv1.SandboxTenantQuota, now time.Time) []sandboxv1.QuotaReservation {
+	var live []sandboxv1.QuotaReservation
+	for _, r := range q.Status.Reservations {
+		if r.ExpiresAt.Time.After(now) {
+			live = append(live, r)
+		}
+	}
+	return live
+}
+
+// usage sums the tenant's live sessions and what they hold, as recorded on
+// the session CRDs.
+func (o *Orchestrator) usage(ctx context.Context, tenant string) (tenantUsage, error) {
+	var u tenantUsage
+	sessions, err := o.listSessions(ctx, sandboxNS, "all")
+	if err != nil {
+		return u, status.Errorf(codes.Internal, "quota: list sessions: %v", err)
+	}
+	for _, s := range sessions {
+		if quotaSubject(sessionTenant(s)) != tenant || s.Status.Phase == sandboxv1.SandboxPhaseTerminating {
+			continue
+		}
+		u.sessions++
+		u.backgroundRuns += liveRuns(s)
+		u.exposedPorts += len(s.Status.ExposedPorts)
+		if q, err := resource.ParseQuantity(s.Annotations[cpuAnnotation]); err == nil {
+			u.cpu.Add(q)
+		}
+		if q, err := resource.ParseQuantity(s.Annotations[memoryAnnotation]); err == nil {
+			u.memory.Add(q)
+		}
+	}
+	return u, nil
+}
+
+// quotaExceeded is the ResourceExhausted error every quota check returns.
+func quotaExceeded(q *sandboxv1.SandboxTenantQuota, what string, used, limit any) error {
+	return status.Errorf(codes.ResourceExhausted,
+		"tenant %s quota exceeded: %s %v of %v (SandboxTenantQuota %s); release some or ask an admin to raise the quota",
+		quotaTenant(q), what, used, limit, q.Name)
+}
+
+// admit reserves r against tenant's quota when check passes. check sees the
+// quota and the tenant's usage including other pending reservations. The
+// reservation is written to the quota status with the resourceVersion the
+// check read, so of two replicas admitting at once one conflicts and checks
+// again against the other's reservation. release drops the reservation; the
+// caller calls it once what it admitted is visible in cluster state (or has
+// failed). Tenants without a quota are admitted without a reservation.
+func (o *Orchestrator) admit(ctx context.Context, tenant string, r sandboxv1.QuotaReservation,
+	check func(q *sandboxv1.SandboxTenantQuota, u tenantUsage) error) (release func(), err error) {
+	tenant = quotaSubject(tenant)
+	var name string
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		q, err := o.tenantQuota(ctx, tenant)
+		if err != nil || q == nil {
+			return err
+		}
+		u, err := o.usage(ctx, tenant)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := check(q, u.reserved(q, now)); err != nil {
+			return err
+		}
+		if r.ID == "" {
+			if r.ID, err = newQuotaReservationID(); err != nil {
+				return status.Errorf(codes.Internal, "quota: reservation id: %v", err)
+			}
+		}
+		r.ExpiresAt = metav1.NewTime(now.Add(quotaReservationTTL))
+		q.Status.Reservations = append(liveReservations(q, now), r)
+		if err := o.writeQuotaStatus(ctx, q); err != nil {
+			return err
+		}
+		name = q.Name
+		return nil
+	})
+	if apierrors.IsConflict(err) {
+		return nil, status.Errorf(codes.Aborted, "quota: tenant %s admissions contended; retry", tenant)
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Unavailable, "quota: reserve for tenant %s: %v", tenant, err)
+		}
+		return nil, err
+	}
+	if name == "" {
+		return func() {}, nil
+	}
+	return func() { o.releaseReservation(name, r.ID) }, nil
+}
+
+// releaseReservation drops reservation id from the named quota. Failures are
+// only logged: the reservation expires on its own.
+func (o *Orchestrator) releaseReservation(name, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u, err := o.dynamic.Resource(QuotaGVR).Namespace(sandboxNS).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var q sandboxv1.SandboxTenantQuota
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &q); err != nil {
+			return err
+		}
+		kept := q.Status.Reservations[:0]
+		for _, r := range q.Status.Reservations {
+			if r.ID != id && r.ExpiresAt.Time.After(time.Now()) {
+				kept = append(kept, r)
+			}
+		}
+		q.Status.Reservations = kept
+		return o.writeQuotaStatus(ctx, &q)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logrus.Debugf("sandbox quota %s: release reservation %s: %v", name, id, err)
+	}
+}
+
+// writeQuotaStatus writes q's status, guarded by q's resourceVersion.
+func (o *Orchestrator) writeQuotaStatus(ctx context.Context, q *sandboxv1.SandboxTenantQuota) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(q)
+	if err != nil {
+		return err
+	}
+	_, err = o.dynamic.Resource(QuotaGVR).Namespace(sandboxNS).UpdateStatus(ctx,
+		&unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	return err
+}
+
+func newQuotaReservationID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// admitSession checks a new session with pod profile p against the tenant's
+// quota (the default quota when tenant is empty). On success the caller
+// must call release once the session CRD exists, so the next admission for
+// the tenant counts it.
+func (o *Orchestrator) admitSession(ctx context.Context, tenant string, p podProfile) (release func(), err error) {
+	cpu, memory := podLimits(p.cpu, p.memory)
+	return o.admit(ctx, tenant, sandboxv1.QuotaReservation{Kind: reserveSession, CPU: cpu, Memory: memory},
+		func(q *sandboxv1.SandboxTenantQuota, u tenantUsage) error { return checkSession(q, u, p) })
+}
+
+func checkSession(q *sandboxv1.SandboxTenantQuota, u tenantUsage, p podProfile) error {
+	if allowed := q.Spec.AllowedRuntimeClasses; len(allowed) > 0 && !containsString(allowed, p.runtimeClass) {
+		return status.Errorf(codes.PermissionDenied,
+			"tenant %s may not use runtime class %q (SandboxTenantQuota %s allows %s)",
+			quotaTenant(q), p.runtimeClass, q.Name, strings.Join(allowed, ", "))
+	}
+	cpu, memory := podLimits(p.cpu, p.memory)
+	if max := q.Spec.MaxSessions; max > 0 && u.sessions+1 > max {
+		return quotaExceeded(q, "sessions", u.sessions, max)
+	}
+	for _, r := range []struct {
+		name corev1.ResourceName
+		used resource.Quantity
+		add  string
+	}{{corev1.ResourceCPU, u.cpu, cpu}, {corev1.ResourceMemory, u.memory, memory}} {
+		limit, ok := q.Spec.MaxResources[r.name]
+		if !ok || limit.IsZero() {
+			continue
+		}
+		want := r.used.DeepCopy()
+		if add, err := resource.ParseQuantity(r.add); err == nil {
+			want.Add(add)
+		}
+		if want.Cmp(limit) > 0 {
+			return quotaExceeded(q, string(r.name), r.used.String()+" (+"+r.add+")", limit.String())
+		}
+	}
+	return nil
+}
+
+// admitBackgroundRun checks one more background run for the session's
+// tenant. release must be called once the run is registered (or failed).
+func (o *Orchestrator) admitBackgroundRun(ctx context.Context, session *sandboxv1.SandboxSession) (release func(), err error) {
+	return o.admitCount(ctx, session, reserveBackgroundRun, "background runs",
+		func(q *sandboxv1.SandboxTenantQuota) int { return q.Spec.MaxBackgroundRuns },
+		func(u tenantUsage) int { return u.backgroundRuns })
+}
+
+// admitExpose checks one more exposed port for the session's tenant.
+// release must be called once the port is registered (or failed).
+func (o *Orchestrator) admitExpose(ctx context.Context, session *sandboxv1.SandboxSession) (release func(), err error) {
+	return o.admitCount(ctx, session, reserveExposedPort, "exposed ports",
+		func(q *sandboxv1.SandboxTenantQuota) int { return q.Spec.MaxExposedPorts },
+		func(u tenantUsage) int { return u.exposedPorts })
+}
+
+func (o *Orchestrator) admitCount(ctx context.Context, session *sandboxv1.SandboxSession, kind, what string,
+	limit func(*sandboxv1.SandboxTenantQuota) int, used func(tenantUsage) int) (func(), error) {
+	tenant := quotaSubject(sessionTenant(session))
+	q, err := o.tenantQuota(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil || limit(q) <= 0 {
+		return func() {}, nil
+	}
+	return o.admit(ctx, tenant, sandboxv1.QuotaReservation{Kind: kind},
+		func(q *sandboxv1.SandboxTenantQuota, u tenantUsage) error {
+			if max := limit(q); max > 0 && used(u)+1 > max {
+				return quotaExceeded(q, what, used(u), max)
+			}
+			return nil
+		})
+}
+
+// snapshotUsage sums the logical size of the tenant's registry snapshots,
+// skipping the manifest named except (it is about to be replaced).
+func snapshotUsage(store *sandboxlayer.Store, tenant, except string) (int64, error) {
+	names, err := store.ListManifests()
+	if err != nil {
+		return 0, err
+	}
+	var used int64
+	for _, n := range names {
+		if n == except {
+			continue
+		}
+		m, err := store.LoadManifest(n)
+		if err != nil || quotaSubject(m.Owner) != tenant {
+			continue
+		}
+		size, err := store.LogicalSize(m.Layers)
+		if err != nil {
+			continue // dangling layers count as nothing
+		}
+		used += size
+	}
+	return used, nil
+}
+
+// checkSnapshot checks adding a snapshot of size bytes under name for
+// tenant, counting other pending snapshot reservations, without reserving.
+func (s *Server) checkSnapshot(ctx context.Context, store *sandboxlayer.Store, tenant, name string, size int64) error {
+	q, err := s.orch.tenantQuota(ctx, quotaSubject(tenant))
+	if err != nil || q == nil {
+		return err
+	}
+	return snapshotFits(q, store, quotaSubject(tenant), name, size, time.Now())
+}
+
+// admitSnapshot reserves size bytes under name for tenant. release must be
+// called once the manifest is published (or failed).
+func (s *Server) admitSnapshot(ctx context.Context, store *sandboxlayer.Store, tenant, name string, size int64) (release func(), err error) {
+	q, err := s.orch.tenantQuota(ctx, quotaSubject(tenant))
+	if err != nil {
+		return nil, err
+	}
+	if q == nil || q.Spec.MaxSnapshotBytes <= 0 {
+		return func() {}, nil
+	}
+	return s.orch.admit(ctx, tenant, sandboxv1.QuotaReservation{Kind: reserveSnapshot, Bytes: size},
+		func(q *sandboxv1.SandboxTenantQuota, _ tenantUsage) error {
+			return snapshotFits(q, store, quotaSubject(tenant), name, size, time.Now())
+		})
+}
+
+func snapshotFits(q *sandboxv1.SandboxTenantQuota, store *sandboxlayer.Store, tenant, name string, size int64, now time.Time) error {
+	if q.Spec.MaxSnapshotBytes <= 0 {
+		return nil
+	}
+	used, err := snapshotUsage(store, tenant, name)
+	if err != nil {
+		return status.Errorf(codes.Internal, "quota: snapshot usage: %v", err)
+	}
+	used += reservedBytes(q, now)
+	if used+size > q.Spec.MaxSnapshotBytes {
+		return quotaExceeded(q, "snapshot bytes", fmt.Sprintf("%d (+%d)", used, size), q.Spec.MaxSnapshotBytes)
+	}
+	return nil
+}
+
+// RefreshQuotaStatus writes every quota's current usage to its status,
+// keeping unexpired reservations. The controller leader calls it
+// periodically; a write that conflicts with an admission is skipped until
+// the next pass.
+func (s *Server) RefreshQuotaStatus(ctx context.Context) {
+	quotas, err := s.orch.listQuotas(ctx)
+	if err != nil {
+		return
+	}
+	for _, q := range quotas {
+		tenant := quotaTenant(q)
+		u, err := s.orch.usage(ctx, tenant)
+		if err != nil {
+			continue
+		}
+		now := metav1.NewTime(time.Now().UTC().Truncate(time.Second))
+		st := sandboxv1.SandboxTenantQuotaStatus{
+			Sessions:       u.sessions,
+			BackgroundRuns: u.backgroundRuns,
+			ExposedPorts:   u.exposedPorts,
+			Resources:      corev1.ResourceList{corev1.ResourceCPU: u.cpu, corev1.ResourceMemory: u.memory},
+			UpdatedAt:      &now,
+			Reservations:   liveReservations(q, now.Time),
+		}
+		if s.layerStore != nil {
+			if used, err := snapshotUsage(s.layerStore, tenant, ""); err == nil {
+				st.SnapshotBytes = used
+			}
+		}
+		q.Status = st
+		if err := s.orch.writeQuotaStatus(ctx, q); err != nil {
+			logrus.Debugf("sandbox quota %s: update status: %v", q.Name, err)
+		}
+	}
+}
+
+// quotaAnnotations records what a new session is charged to its tenant.
+func quotaAnnotations(tenant string, p podProfile) map[string]string {
+	cpu, memory := podLimits(p.cpu, p.memory)
+	a := map[string]string{cpuAnnotation: cpu, memoryAnnotation: memory}
+	if tenant != "" {
+		a[tenantAnnotation] = tenant
+	}
+	return a
+}
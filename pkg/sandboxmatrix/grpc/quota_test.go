@@ -0,0 +1,352 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// seedQuota creates a SandboxTenantQuota named after its tenant.
+func seedQuota(t *testing.T, o *Orchestrator, tenant string, spec sandboxv1.SandboxTenantQuotaSpec) {
+	t.Helper()
+	q := &sandboxv1.SandboxTenantQuota{
+		TypeMeta:   metav1.TypeMeta{APIVersion: sandboxAPIVersion, Kind: "SandboxTenantQuota"},
+		ObjectMeta: metav1.ObjectMeta{Name: tenant, Namespace: sandboxNS},
+		Spec:       spec,
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.dynamic.Resource(QuotaGVR).Namespace(sandboxNS).Create(context.Background(),
+		&unstructured.Unstructured{Object: obj}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("seed quota: %v", err)
+	}
+}
+
+func tenantCtx(tenant string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantHeader, tenant))
+}
+
+func TestQuota_MaxSessions(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 1})
+	ctx := tenantCtx("team-a")
+
+	sess, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-1"})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	if got := sess.Annotations[tenantAnnotation]; got != "team-a" {
+		t.Fatalf("tenant annotation = %q", got)
+	}
+	_, err = o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-2"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if msg := status.Convert(err).Message(); !strings.Contains(msg, "team-a") || !strings.Contains(msg, "sessions 1 of 1") {
+		t.Fatalf("error should name the tenant and usage: %s", msg)
+	}
+
+	// Other tenants and tenant-less callers are unaffected.
+	if _, err := o.CreateSession(tenantCtx("team-b"), &pb.CreateSessionRequest{SessionId: "b-1"}); err != nil {
+		t.Fatalf("other tenant: %v", err)
+	}
+	mustCreateSession(t, o, "anon-1")
+}
+
+func TestQuota_DefaultCoversTenantlessSessions(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, defaultQuotaTenant, sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 1})
+
+	mustCreateSession(t, o, "anon-1")
+	_, err := o.CreateSession(context.Background(), &pb.CreateSessionRequest{SessionId: "anon-2"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("tenantless callers share the default quota, got %v", err)
+	}
+}
+
+func TestRequestTenant_CertIdentityWins(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "key-a"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	ctx := peer.NewContext(tenantCtx("team-b"), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 40000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+	if got := requestTenant(ctx, "team-c"); got != "key-a" {
+		t.Fatalf("certificate caller claimed tenant %q", got)
+	}
+	if got := requestTenant(tenantCtx("team-b"), ""); got != "team-b" {
+		t.Fatalf("trusted caller tenant = %q", got)
+	}
+}
+
+func TestQuota_TerminatingSessionsDoNotCount(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 1})
+	ctx := tenantCtx("team-a")
+	sess, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-1"})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	if _, err := o.updateSessionStatus(ctx, sess.Name, func(st *sandboxv1.SandboxSessionStatus) {
+		st.Phase = sandboxv1.SandboxPhaseTerminating
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-2"}); err != nil {
+		t.Fatalf("terminating session still counted: %v", err)
+	}
+}
+
+func TestQuota_MaxResources(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{
+		MaxResources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	})
+	ctx := tenantCtx("team-a")
+	for _, id := range []string{"a-1", "a-2"} { // 2 x default 500m
+		if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: id}); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+	_, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-3"})
+	if status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "cpu") {
+		t.Fatalf("expected cpu ResourceExhausted, got %v", err)
+	}
+}
+
+func TestQuota_AllowedRuntimeClasses(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{AllowedRuntimeClasses: []string{"gvisor"}})
+	ctx := tenantCtx("team-a")
+	_, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-1", RuntimeClass: "kata"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-2", RuntimeClass: "gvisor"}); err != nil {
+		t.Fatalf("allowed runtime class: %v", err)
+	}
+}
+
+func TestQuota_MaxBackgroundRunsAcrossSessions(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxBackgroundRuns: 1})
+	ctx := tenantCtx("team-a")
+	for _, id := range []string{"a-1", "a-2"} {
+		if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The run was submitted through another replica: this one only sees
+	// the session annotation.
+	if err := o.recordRunOnSession(ctx, "a-1", "a-1-bg-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The per-session cap has room on a-2; the tenant quota does not.
+	_, err := o.ExecBackground(ctx, "a-2", "sleep 1", 30, "/workspace", nil)
+	if status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "background runs") {
+		t.Fatalf("expected tenant background-run quota, got %v", err)
+	}
+
+	// A run PollRun has seen exit stays pollable but no longer counts, on
+	// any replica.
+	o.mu.Lock()
+	o.runRegistry["a-1-bg-1"] = "a-1"
+	o.mu.Unlock()
+	o.markRunExited(ctx, "a-1-bg-1")
+	other := &Orchestrator{dynamic: o.dynamic, k8s: o.k8s}
+	if u, err := other.usage(ctx, "team-a"); err != nil || u.backgroundRuns != 0 {
+		t.Fatalf("exited run still counted: %+v %v", u, err)
+	}
+	if o.countBackgroundRuns("a-1") != 0 || o.countAllBackgroundRuns() != 0 {
+		t.Fatal("exited run still counted against the per-session cap")
+	}
+	if err := o.DestroySession(ctx, "a-1"); err != nil {
+		t.Fatal(err)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.runRegistry) != 0 || len(o.runsExited) != 0 {
+		t.Fatalf("destroyed session's runs kept: %v %v", o.runRegistry, o.runsExited)
+	}
+}
+
+func TestQuota_MaxExposedPorts(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxExposedPorts: 1})
+	ctx := tenantCtx("team-a")
+	if _, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "a-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.ExposeService(ctx, "a-1", 8080, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err != nil {
+		t.Fatalf("first expose: %v", err)
+	}
+	// Re-exposing the same port is idempotent and not charged again.
+	if _, err := o.ExposeService(ctx, "a-1", 8080, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC); err != nil {
+		t.Fatalf("idempotent expose: %v", err)
+	}
+	_, err := o.ExposeService(ctx, "a-1", 9090, "", "http://gw", pb.ExposeAccess_EXPOSE_ACCESS_PUBLIC)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestQuota_SnapshotBytes(t *testing.T) {
+	s, ls := snapshotTestServer(t, "snap-owner")
+	archive := testWorkspaceTar(t)
+	seedQuota(t, s.orch, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSnapshotBytes: int64(len(archive)) + 100})
+	sess, err := s.orch.getSession(context.Background(), "snap-owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Annotations = map[string]string{tenantAnnotation: "team-a"}
+	s.orch.updateSession(context.Background(), sess)
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeExecSSE(w, archive, 0)
+	}))
+
+	if _, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-owner", Name: "v1"}); err != nil {
+		t.Fatalf("first snapshot: %v", err)
+	}
+	m, err := ls.LoadManifest("v1")
+	if err != nil || m.Owner != "team-a" {
+		t.Fatalf("manifest owner not recorded: %+v %v", m, err)
+	}
+	// Overwriting the same name replaces its bytes rather than adding to them.
+	if _, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-owner", Name: "v1"}); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	_, err = s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-owner", Name: "v2"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if _, lerr := ls.LoadManifest("v2"); lerr == nil {
+		t.Fatal("over-quota snapshot must not be published")
+	}
+
+	// SnapshotPut charges the x-sandbox-tenant caller the same way.
+	_, err = s.SnapshotPut(tenantCtx("team-a"), &pb.SnapshotPutRequest{Name: "copy", Layers: m.Layers})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected SnapshotPut ResourceExhausted, got %v", err)
+	}
+	if _, err := s.SnapshotPut(tenantCtx("team-b"), &pb.SnapshotPutRequest{Name: "copy", Layers: m.Layers}); err != nil {
+		t.Fatalf("unquoted tenant: %v", err)
+	}
+}
+
+func TestRefreshQuotaStatus(t *testing.T) {
+	s := newTestServer()
+	seedQuota(t, s.orch, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 5})
+	ctx := tenantCtx("team-a")
+	for _, id := range []string{"a-1", "a-2"} {
+		if _, err := s.orch.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.RefreshQuotaStatus(context.Background())
+
+	u, err := s.dyn.Resource(QuotaGVR).Namespace(sandboxNS).Get(context.Background(), "team-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, _, _ := unstructured.NestedInt64(u.Object, "status", "sessions")
+	cpu, _, _ := unstructured.NestedString(u.Object, "status", "resources", "cpu")
+	if sessions != 2 || cpu != "1" {
+		t.Fatalf("status sessions=%d cpu=%q, want 2 and 1", sessions, cpu)
+	}
+}
+
+func TestQuota_ReservationFromAnotherReplicaCounts(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 1})
+	dyn := o.dynamic.(*dynamicfake.FakeDynamicClient)
+	// Another replica reserves the tenant's last session between this
+	// replica's read of the quota and its status write.
+	raced := false
+	dyn.PrependReactor("update", "sandboxtenantquotas", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		if raced || a.GetSubresource() != "status" {
+			return false, nil, nil
+		}
+		raced = true
+		cur, err := dyn.Tracker().Get(QuotaGVR, sandboxNS, "team-a")
+		if err != nil {
+			return true, nil, err
+		}
+		u := cur.(*unstructured.Unstructured).DeepCopy()
+		r := map[string]interface{}{"id": "other", "kind": reserveSession,
+			"expiresAt": time.Now().Add(time.Minute).UTC().Format(time.RFC3339)}
+		if err := unstructured.SetNestedSlice(u.Object, []interface{}{r}, "status", "reservations"); err != nil {
+			return true, nil, err
+		}
+		if err := dyn.Tracker().Update(QuotaGVR, u, sandboxNS); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(QuotaGVR.GroupResource(), "team-a", errors.New("modified"))
+	})
+
+	_, err := o.CreateSession(tenantCtx("team-a"), &pb.CreateSessionRequest{SessionId: "a-1"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the other replica's reservation to count, got %v", err)
+	}
+}
+
+func TestQuota_ReleaseDropsReservation(t *testing.T) {
+	o := newTestOrchestrator()
+	seedQuota(t, o, "team-a", sandboxv1.SandboxTenantQuotaSpec{MaxSessions: 1})
+	release, err := o.admitSession(tenantCtx("team-a"), "team-a", newPodProfile(nil, "", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.admitSession(tenantCtx("team-a"), "team-a", newPodProfile(nil, "", "", "")); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("pending reservation not counted: %v", err)
+	}
+	release()
+	u, err := o.dynamic.Resource(QuotaGVR).Namespace(sandboxNS).Get(context.Background(), "team-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs, _, _ := unstructured.NestedSlice(u.Object, "status", "reservations"); len(rs) != 0 {
+		t.Fatalf("reservation kept after release: %v", rs)
+	}
+}
+
+func TestQuota_LookupFailsClosed(t *testing.T) {
+	o := newTestOrchestrator()
+	o.dynamic.(*dynamicfake.FakeDynamicClient).PrependReactor("list", "sandboxtenantquotas",
+		func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("apiserver unavailable")
+		})
+	_, err := o.CreateSession(tenantCtx("team-a"), &pb.CreateSessionRequest{SessionId: "a-1"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
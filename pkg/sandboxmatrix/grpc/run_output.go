@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// Sync exec output past sandboxd's 1 MiB per-stream cap is spilled to the
+// pod's run directory instead of dropped, keyed by a gateway-generated run
+// id; background runs already keep their output there. GetRunOutput pages
+// either kind.
+
+// execRunSep separates the session id from the sequence in sync exec run
+// ids, so GetRunOutput can find the session without a registry entry.
+const execRunSep = "-exec-"
+
+// newExecRunID names the spill directory of one sync exec.
+func newExecRunID(sessionID string) string {
+	return fmt.Sprintf("%s%s%d", sessionID, execRunSep, time.Now().UnixNano())
+}
+
+// runOutputSizes are the full-size fields sandboxd adds to /exec and
+// /exec/background/<id> responses. *Cut is where the inline output stops.
+type runOutputSizes struct {
+	StdoutBytes int64 `json:"stdout_bytes"`
+	StderrBytes int64 `json:"stderr_bytes"`
+	StdoutCut   int64 `json:"stdout_cut"`
+	StderrCut   int64 `json:"stderr_cut"`
+}
+
+// outputRef returns where runID's full output continues, or nil when the
+// inline output is complete (or sandboxd predates the size fields).
+func (r runOutputSizes) outputRef(runID string) *pb.OutputRef {
+	if runID == "" || (r.StdoutBytes <= r.StdoutCut && r.StderrBytes <= r.StderrCut) {
+		return nil
+	}
+	return &pb.OutputRef{
+		RunId:        runID,
+		StdoutOffset: r.StdoutCut,
+		StderrOffset: r.StderrCut,
+		StdoutBytes:  r.StdoutBytes,
+		StderrBytes:  r.StderrBytes,
+	}
+}
+
+// runSessionID resolves the session that owns runID: the request's, a
+// tracked background run's, or the prefix of a sync exec run id.
+func (s *Server) runSessionID(req *pb.GetRunOutputRequest) string {
+	if req.SessionId != "" {
+		return req.SessionId
+	}
+	s.orch.mu.Lock()
+	sessionID := s.orch.runRegistry[req.RunId]
+	s.orch.mu.Unlock()
+	if sessionID != "" {
+		return sessionID
+	}
+	if i := strings.LastIndex(req.RunId, execRunSep); i > 0 {
+		return req.RunId[:i]
+	}
+	return ""
+}
+
+// GetRunOutput reads one window of a run's full stdout or stderr.
+func (s *Server) GetRunOutput(ctx context.Context, req *pb.GetRunOutputRequest) (*pb.GetRunOutputResponse, error) {
+	if req.RunId == "" {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	stream := req.Stream
+	if stream == "" {
+		stream = "stdout"
+	}
+	if stream != "stdout" && stream != "stderr" {
+		return nil, status.Errorf(codes.InvalidArgument, "stream must be stdout or stderr, got %q", stream)
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+	sessionID := s.runSessionID(req)
+	if sessionID == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "run %s: session_id is required", req.RunId)
+	}
+	// A run id naming another session must not reach that session's pod.
+	if !strings.HasPrefix(req.RunId, sessionID+"-") {
+		return nil, status.Errorf(codes.NotFound, "run %s not found in session %s", req.RunId, sessionID)
+	}
+	podIP, err := s.getPodIP(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/exec/output?run_id=%s&stream=%s&offset=%d&limit=%d",
+		url.QueryEscape(req.RunId), stream, req.Offset, req.Limit)
+	resp, err := sandboxdGet(ctx, podIP, path)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "sandboxd output: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, status.Errorf(codes.NotFound, "no %s kept for run %s", stream, req.RunId)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, status.Errorf(codes.Internal, "sandboxd output: http %d", resp.StatusCode)
+	}
+	var result struct {
+		Data       string `json:"data"`
+		Offset     int64  `json:"offset"`
+		NextOffset int64  `json:"next_offset"`
+		Size       int64  `json:"size"`
+		Eof        bool   `json:"eof"`
+		Running    bool   `json:"running"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, status.Errorf(codes.Internal, "sandboxd output: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "sandboxd output: %v", err)
+	}
+	return &pb.GetRunOutputResponse{
+		RunId:      req.RunId,
+		Stream:     stream,
+		Data:       data,
+		Offset:     result.Offset,
+		NextOffset: result.NextOffset,
+		Size:       result.Size,
+		Eof:        result.Eof,
+		Running:    result.Running,
+	}, nil
+}
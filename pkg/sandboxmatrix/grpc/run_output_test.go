@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func TestExec_TruncatedOutputCarriesOutputRef(t *testing.T) {
+	s := execStreamTestServer(t, "spill")
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		runID, _ := body["run_id"].(string)
+		if !strings.HasPrefix(runID, "spill-exec-") {
+			t.Errorf("exec body has no spill run_id: %v", body)
+		}
+		fmt.Fprintf(w, `{"stdout":"abc","stderr":"e","exit_code":0,"truncated":true,"stdout_bytes":10,"stderr_bytes":1,"stdout_cut":3,"stderr_cut":1,"run_id":%q}`, runID)
+	}))
+
+	resp, err := s.Exec(context.Background(), &pb.ExecRequest{SessionId: "spill", Command: "cat big"})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	ref := resp.OutputRef
+	if !resp.Truncated || ref == nil || !strings.HasPrefix(ref.RunId, "spill-exec-") {
+		t.Fatalf("output_ref missing: %+v", resp)
+	}
+	if ref.StdoutOffset != 3 || ref.StdoutBytes != 10 || ref.StderrOffset != 1 || ref.StderrBytes != 1 {
+		t.Fatalf("output_ref offsets: %+v", ref)
+	}
+}
+
+func TestExec_CompleteOutputHasNoOutputRef(t *testing.T) {
+	s := execStreamTestServer(t, "small")
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"stdout":"ok\n","stderr":"","exit_code":0,"stdout_bytes":3,"stderr_bytes":0,"stdout_cut":3,"stderr_cut":0}`)
+	}))
+
+	resp, err := s.Exec(context.Background(), &pb.ExecRequest{SessionId: "small", Command: "echo ok"})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if resp.OutputRef != nil {
+		t.Fatalf("unexpected output_ref: %+v", resp.OutputRef)
+	}
+}
+
+func TestGetRunOutput_PagesSpilledStream(t *testing.T) {
+	s := execStreamTestServer(t, "page")
+	full := "0123456789"
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/exec/output" || q.Get("run_id") != "page-exec-1" || q.Get("stream") != "stderr" {
+			http.NotFound(w, r)
+			return
+		}
+		off, _ := strconv.Atoi(q.Get("offset"))
+		end := min(off+4, len(full))
+		fmt.Fprintf(w, `{"data":%q,"offset":%d,"next_offset":%d,"size":%d,"eof":%t,"running":false}`,
+			b64(full[off:end]), off, end, len(full), end == len(full))
+	}))
+
+	// No session_id: the session is the sync run id's prefix.
+	var got []byte
+	var offset int64
+	for i := 0; ; i++ {
+		resp, err := s.GetRunOutput(context.Background(), &pb.GetRunOutputRequest{RunId: "page-exec-1", Stream: "stderr", Offset: offset, Limit: 4})
+		if err != nil {
+			t.Fatalf("GetRunOutput: %v", err)
+		}
+		if resp.Offset != offset || resp.Size != int64(len(full)) {
+			t.Fatalf("window %d: %+v", i, resp)
+		}
+		got = append(got, resp.Data...)
+		offset = resp.NextOffset
+		if resp.Eof {
+			break
+		}
+		if i > 5 {
+			t.Fatal("paging did not reach eof")
+		}
+	}
+	if string(got) != full {
+		t.Fatalf("paged %q, want %q", got, full)
+	}
+
+	_, err := s.GetRunOutput(context.Background(), &pb.GetRunOutputRequest{RunId: "page-exec-2", Stream: "stderr"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("missing run: %v", err)
+	}
+}
+
+func TestGetRunOutput_RejectsBadRequests(t *testing.T) {
+	s := execStreamTestServer(t, "bad")
+	cases := []struct {
+		req  *pb.GetRunOutputRequest
+		code codes.Code
+	}{
+		{&pb.GetRunOutputRequest{}, codes.InvalidArgument},
+		{&pb.GetRunOutputRequest{RunId: "bad-exec-1", Stream: "stdin"}, codes.InvalidArgument},
+		{&pb.GetRunOutputRequest{RunId: "orphan"}, codes.InvalidArgument},
+		{&pb.GetRunOutputRequest{SessionId: "bad", RunId: "other-exec-1"}, codes.NotFound},
+	}
+	for _, c := range cases {
+		if _, err := s.GetRunOutput(context.Background(), c.req); status.Code(err) != c.code {
+			t.Errorf("%+v: got %v, want %v", c.req, err, c.code)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Gateway → sandboxd authentication. Every sandbox pod gets its own HMAC key,
+// derived from a cluster master key and a random key id stamped on the pod:
+//
+//	podKey = HMAC-SHA256(master, keyID)
+//
+// The key reaches sandboxd as SANDBOXD_AUTH_KEY (sandboxd scrubs it from its
+// environment at startup). Requests carry X-Sandboxd-Auth-V2 — a timestamp,
+// a random nonce sandboxd remembers for its skew window, and the MAC — and
+// responses must carry a matching X-Sandboxd-Proof, so neither another pod
+// that can reach a sandbox IP nor a pod that inherited a recycled IP can
+// impersonate either side, and a captured request cannot be replayed.
+// Response bodies are MACed too (X-Sandboxd-Body-MAC, or a trailing comment
+// on event streams). This is integrity only, not confidentiality: commands,
+// file contents and resolved secret env cross the pod network unencrypted.
+// Pods without a key id predate all of this and are spoken to in the clear.
+const (
+	sandboxdAuthSecretName = "sandboxd-auth"
+	sandboxdAuthSecretKey  = "master"
+	// SandboxdKeyIDAnnotation names the pod's key id.
+	SandboxdKeyIDAnnotation = "sandbox.k8e.io/sandboxd-key-id"
+	sandboxdAuthEnv         = "SANDBOXD_AUTH_KEY"
+	sandboxdAuthV2Header    = "X-Sandboxd-Auth-V2"
+	sandboxdProofHeader     = "X-Sandboxd-Proof"
+	sandboxdBodyMACHeader   = "X-Sandboxd-Body-MAC"
+	// sandboxdKeyCacheTTL bounds how long a pod IP → key mapping is trusted
+	// before the pod is looked up again.
+	sandboxdKeyCacheTTL = time.Minute
+)
+
+// SandboxdKeyring derives per-pod sandboxd keys and resolves them by pod IP.
+type SandboxdKeyring struct {
+	master []byte
+	k8s    kubernetes.Interface
+
+	mu   sync.Mutex
+	byIP map[string]cachedPodKey
+}
+
+type cachedPodKey struct {
+	key []byte // nil: the pod predates sandboxd auth
+	at  time.Time
+}
+
+// LoadSandboxdKeyring reads the master key from the sandboxd-auth Secret,
+// creating it on first use. Every gateway in an HA control plane shares it.
+func LoadSandboxdKeyring(ctx context.Context, k8s kubernetes.Interface) (*SandboxdKeyring, error) {
+	secrets := k8s.CoreV1().Secrets(sandboxNS)
+	secret, err := secrets.Get(ctx, sandboxdAuthSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		master := make([]byte, 32)
+		if _, err := rand.Read(master); err != nil {
+			return nil, err
+		}
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: sandboxdAuthSecretName, Namespace: sandboxNS},
+			Data:       map[string][]byte{sandboxdAuthSecretKey: master},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// Another server created it first.
+			secret, err = secrets.Get(ctx, sandboxdAuthSecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sandboxd auth secret: %w", err)
+	}
+	master := secret.Data[sandboxdAuthSecretKey]
+	if len(master) < 16 {
+		return nil, fmt.Errorf("sandboxd auth secret %s/%s: %q must hold at least 16 bytes", sandboxNS, sandboxdAuthSecretName, sandboxdAuthSecretKey)
+	}
+	return NewSandboxdKeyring(master, k8s), nil
+}
+
+// NewSandboxdKeyring builds a keyring from a master key.
+func NewSandboxdKeyring(master []byte, k8s kubernetes.Interface) *SandboxdKeyring {
+	return &SandboxdKeyring{master: master, k8s: k8s, byIP: make(map[string]cachedPodKey)}
+}
+
+func (k *SandboxdKeyring) derive(keyID string) []byte {
+	m := hmac.New(sha256.New, k.master)
+	m.Write([]byte(keyID))
+	return m.Sum(nil)
+}
+
+// keyForPod returns the pod's key, or nil when the pod has no key id.
+func (k *SandboxdKeyring) keyForPod(pod *corev1.Pod) []byte {
+	id := pod.Annotations[SandboxdKeyIDAnnotation]
+	if id == "" {
+		return nil
+	}
+	return k.derive(id)
+}
+
+// keyForIP resolves the key of the sandbox pod currently holding ip. An IP
+// that belongs to no sandbox pod is an error: the gateway only ever talks to
+// sandbox pods.
+func (k *SandboxdKeyring) keyForIP(ctx context.Context, ip string) ([]byte, error) {
+	k.mu.Lock()
+	c, ok := k.byIP[ip]
+	k.mu.Unlock()
+	if ok && time.Since(c.at) < sandboxdKeyCacheTTL {
+		return c.key, nil
+	}
+	pods, err := k.k8s.CoreV1().Pods(sandboxNS).List(ctx, metav1.ListOptions{FieldSelector: "status.podIP=" + ip})
+	if err != nil {
+		return nil, fmt.Errorf("sandboxd auth: look up pod %s: %w", ip, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// Checked explicitly: fake clients ignore field selectors.
+		if pod.Status.PodIP != ip || pod.DeletionTimestamp != nil {
+			continue
+		}
+		key := k.keyForPod(pod)
+		k.mu.Lock()
+		k.byIP[ip] = cachedPodKey{key: key, at: time.Now()}
+		k.mu.Unlock()
+		return key, nil
+	}
+	return nil, fmt.Errorf("sandboxd auth: no sandbox pod has IP %s", ip)
+}
+
+func (k *SandboxdKeyring) forget(ip string) {
+	k.mu.Lock()
+	delete(k.byIP, ip)
+	k.mu.Unlock()
+}
+
+// stamp gives pod a fresh key id and injects the derived key into its sandbox
+// container.
+func (k *SandboxdKeyring) stamp(pod *corev1.Pod) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return
+	}
+	k.setKeyID(pod, hex.EncodeToString(raw))
+}
+
+// setKeyID replaces pod's key id and key env with id's; an empty id leaves
+// the pod unkeyed. k may be nil (auth disabled): the annotation is still
+// set, since the gateway cannot derive the env anyway.
+func (k *SandboxdKeyring) setKeyID(pod *corev1.Pod, id string) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	delete(pod.Annotations, SandboxdKeyIDAnnotation)
+	if id != "" {
+		pod.Annotations[SandboxdKeyIDAnnotation] = id
+	}
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name != "sandbox" {
+			continue
+		}
+		env := c.Env[:0]
+		for _, e := range c.Env {
+			if e.Name != sandboxdAuthEnv {
+				env = append(env, e)
+			}
+		}
+		c.Env = env
+		if id != "" && k != nil {
+			c.Env = append(c.Env, corev1.EnvVar{Name: sandboxdAuthEnv, Value: hex.EncodeToString(k.derive(id))})
+		}
+	}
+}
+
+// sandboxdKeys is the active keyring; nil leaves gateway → sandboxd traffic
+// unauthenticated (tests, and clusters where the Secret is unreadable).
+var sandboxdKeys atomic.Pointer[SandboxdKeyring]
+
+// EnableSandboxdAuth makes new sandbox pods keyed and every sandboxd call
+// from this process authenticated with k.
+func EnableSandboxdAuth(k *SandboxdKeyring) { sandboxdKeys.Store(k) }
+
+// StampSandboxdAuth gives a new sandbox pod its sandboxd key (no-op while
+// auth is disabled). Exported for the warm pool reconciler.
+func StampSandboxdAuth(pod *corev1.Pod) {
+	if k := sandboxdKeys.Load(); k != nil {
+		k.stamp(pod)
+	}
+}
+
+// restampSandboxdAuth gives a pod restored from a memory checkpoint the key
+// id the checkpointed sandboxd was started with: the restored process holds
+// that key in memory (it scrubbed the env at startup), not a fresh one.
+func restampSandboxdAuth(pod *corev1.Pod, keyID string) {
+	sandboxdKeys.Load().setKeyID(pod, keyID)
+}
+
+// SandboxdTransport returns the RoundTripper every sandboxd client uses: it
+// signs requests to keyed pods and rejects responses without a valid proof.
+// The embedded E2B server shares it for its direct sandboxd calls.
+func SandboxdTransport() http.RoundTripper { return sandboxdTransport }
+
+// Each call also gets a client span and a traceparent header.
+var sandboxdTransport = tracing.Transport(&sandboxdAuthTransport{base: http.DefaultTransport}, "sandboxd")
+
+type sandboxdAuthTransport struct {
+	base http.RoundTripper
+}
+
+func (t *sandboxdAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	keys := sandboxdKeys.Load()
+	if keys == nil {
+		return t.base.RoundTrip(req)
+	}
+	ip := req.URL.Hostname()
+	key, err := keys.keyForIP(req.Context(), ip)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return t.base.RoundTrip(req)
+	}
+	signed, mac, err := signSandboxdRequest(req, key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(signed)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticateSandboxdResponse(key, mac, resp); err != nil {
+		resp.Body.Close()
+		keys.forget(ip)
+		return nil, fmt.Errorf("sandboxd %s: %w", ip, err)
+	}
+	return resp, nil
+}
+
+// signSandboxdRequest returns a copy of req carrying X-Sandboxd-Auth-V2 and
+// the request MAC the response proof and body must be bound to.
+func signSandboxdRequest(req *http.Request, key []byte, now time.Time) (*http.Request, string, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		body = b
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	ts, nonce := strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(raw)
+
+	m := hmac.New(sha256.New, key)
+	fmt.Fprintf(m, "%s\n%s\n%s\n%s\n", req.Method, req.URL.RequestURI(), ts, nonce)
+	m.Write(body)
+	mac := hex.EncodeToString(m.Sum(nil))
+
+	out := req.Clone(req.Context())
+	out.Body, out.ContentLength = http.NoBody, 0
+	if len(body) > 0 {
+		out.Body, out.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+	}
+	out.Header.Set(sandboxdAuthV2Header, ts+":"+nonce+":"+mac)
+	return out, mac, nil
+}
+
+// authenticateSandboxdResponse checks resp's proof and its body: a fixed
+// body against X-Sandboxd-Body-MAC up front, an event stream against its
+// trailing MAC comment when read to the end.
+func authenticateSandboxdResponse(key []byte, mac string, resp *http.Response) error {
+	if !validSandboxdProof(key, mac, resp.Header.Get(sandboxdProofHeader)) {
+		return fmt.Errorf("response failed authentication (http %d)", resp.StatusCode)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &sandboxdStreamBody{body: resp.Body, mac: sandboxdBodyMAC(key, "stream", mac)}
+		return nil
+	}
+	// Fixed bodies are verified before the caller sees a byte: most callers
+	// decode JSON and never read to EOF.
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	m := sandboxdBodyMAC(key, "body", mac)
+	m.Write(body)
+	got, err := hex.DecodeString(resp.Header.Get(sandboxdBodyMACHeader))
+	if err != nil || !hmac.Equal(got, m.Sum(nil)) {
+		return fmt.Errorf("response body failed authentication (http %d)", resp.StatusCode)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+var errSandboxdBodyAuth = errors.New("sandboxd: response stream failed authentication")
+
+func sandboxdBodyMAC(key []byte, kind, mac string) hash.Hash {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(kind + "\n" + mac + "\n"))
+	return m
+}
+
+// sandboxdStreamTrailer starts the comment frame sandboxd ends an event
+// stream with.
+var sandboxdStreamTrailer = []byte(": sandboxd-mac ")
+
+// sandboxdStreamBody releases an event stream frame by frame, folding each
+// into the stream MAC, and strips the trailing MAC comment. A stream that
+// ends without a matching trailer — truncated or tampered with — ends in
+// errSandboxdBodyAuth rather than io.EOF.
+type sandboxdStreamBody struct {
+	body  io.ReadCloser
+	mac   hash.Hash
+	chunk []byte
+	buf   []byte // read, not yet a complete frame
+	out   []byte // complete frames not yet returned
+	done  bool   // trailer verified
+	err   error
+}
+
+func (b *sandboxdStreamBody) Read(p []byte) (int, error) {
+	for len(b.out) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.fill()
+	}
+	n := copy(p, b.out)
+	b.out = b.out[n:]
+	return n, nil
+}
+
+func (b *sandboxdStreamBody) fill() {
+	if b.chunk == nil {
+		b.chunk = make([]byte, 32<<10)
+	}
+	n, err := b.body.Read(b.chunk)
+	b.buf = append(b.buf, b.chunk[:n]...)
+	for {
+		i := bytes.Index(b.buf, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		frame := b.buf[:i+2]
+		switch {
+		case b.done:
+			// Nothing may follow the trailer.
+			b.err = errSandboxdBodyAuth
+			return
+		case bytes.HasPrefix(frame, sandboxdStreamTrailer):
+			got, herr := hex.DecodeString(string(frame[len(sandboxdStreamTrailer):i]))
+			if herr != nil || !hmac.Equal(got, b.mac.Sum(nil)) {
+				b.err = errSandboxdBodyAuth
+				return
+			}
+			b.done = true
+		default:
+			b.mac.Write(frame)
+			b.out = append(b.out, frame...)
+		}
+		b.buf = b.buf[i+2:]
+	}
+	if err == io.EOF && (!b.done || len(b.buf) > 0) {
+		err = errSandboxdBodyAuth
+	}
+	b.err = err
+}
+
+func (b *sandboxdStreamBody) Close() error { return b.body.Close() }
+
+// finishSandboxdStream reads the rest of an event stream so its trailing MAC
+// is checked before the caller acts on the stream's final frame.
+func finishSandboxdStream(r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func sandboxdProof(key []byte, mac string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("sandboxd\n" + mac))
+	return m.Sum(nil)
+}
+
+func validSandboxdProof(key []byte, mac, header string) bool {
+	got, err := hex.DecodeString(header)
+	return err == nil && hmac.Equal(got, sandboxdProof(key, mac))
+}
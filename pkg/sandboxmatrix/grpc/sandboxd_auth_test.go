@@ -0,0 +1,278 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// keyedSandboxd is a fake sandboxd that checks X-Sandboxd-Auth-V2 the way
+// sandboxd/src/auth.zig does and answers with the proof header and the body
+// MAC (or, for an event stream, the trailing MAC comment).
+func keyedSandboxd(t *testing.T, key []byte, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		parts := strings.SplitN(r.Header.Get(sandboxdAuthV2Header), ":", 3)
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		m := hmac.New(sha256.New, key)
+		fmt.Fprintf(m, "%s\n%s\n%s\n%s\n", r.Method, r.URL.RequestURI(), parts[0], parts[1])
+		m.Write(body)
+		want := hex.EncodeToString(m.Sum(nil))
+		if !hmac.Equal([]byte(parts[2]), []byte(want)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Set(sandboxdProofHeader, hex.EncodeToString(sandboxdProof(key, want)))
+		out := rec.Body.Bytes()
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "text/event-stream") {
+			mac := sandboxdBodyMAC(key, "stream", want)
+			mac.Write(out)
+			out = append(out, fmt.Sprintf(": sandboxd-mac %x\n\n", mac.Sum(nil))...)
+		} else {
+			mac := sandboxdBodyMAC(key, "body", want)
+			mac.Write(out)
+			w.Header().Set(sandboxdBodyMACHeader, hex.EncodeToString(mac.Sum(nil)))
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(out) //nolint:errcheck
+	}))
+}
+
+// authTestTransport enables k and routes every sandboxd dial to srv.
+func authTestTransport(t *testing.T, k *SandboxdKeyring, srv *httptest.Server) *http.Client {
+	t.Helper()
+	EnableSandboxdAuth(k)
+	t.Cleanup(func() { EnableSandboxdAuth(nil) })
+	return &http.Client{Transport: &sandboxdAuthTransport{base: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}}
+}
+
+func keyedPod(k *SandboxdKeyring, name, ip string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sandboxNS},
+		Spec:       SandboxPodSpec("gvisor", "", "", "", "img"),
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+	if k != nil {
+		k.stamp(pod)
+	}
+	return pod
+}
+
+func TestSandboxdKeyring_StampInjectsDerivedKey(t *testing.T) {
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), kubefake.NewSimpleClientset())
+	a, b := keyedPod(k, "a", "10.0.0.1"), keyedPod(k, "b", "10.0.0.2")
+	if a.Annotations[SandboxdKeyIDAnnotation] == b.Annotations[SandboxdKeyIDAnnotation] {
+		t.Fatal("pods share a key id")
+	}
+	env := a.Spec.Containers[0].Env
+	if len(env) != 1 || env[0].Name != sandboxdAuthEnv || env[0].Value != hex.EncodeToString(k.keyForPod(a)) {
+		t.Fatalf("sandbox container env: %+v", env)
+	}
+	if bytes.Equal(k.keyForPod(a), k.keyForPod(b)) {
+		t.Fatal("pods share a key")
+	}
+}
+
+func TestSandboxdTransport_SignsAndVerifiesProof(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset()
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	pod := keyedPod(k, "sandbox-auth", "127.0.0.1")
+	if _, err := k8s.CoreV1().Pods(sandboxNS).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	srv := keyedSandboxd(t, k.keyForPod(pod), func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body) //nolint:errcheck
+	})
+	defer srv.Close()
+	client := authTestTransport(t, k, srv)
+
+	resp, err := client.Post("http://127.0.0.1:2024/exec?x=1", "application/json", strings.NewReader(`{"command":"id"}`))
+	if err != nil {
+		t.Fatalf("signed request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"command":"id"}` {
+		t.Fatalf("response %d %q", resp.StatusCode, body)
+	}
+	if resp, err := client.Get("http://127.0.0.1:2024/events?limit=5"); err != nil {
+		t.Fatalf("signed GET: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestSandboxdTransport_RejectsImpostor(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset()
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	pod := keyedPod(k, "sandbox-auth", "127.0.0.1")
+	k8s.CoreV1().Pods(sandboxNS).Create(context.Background(), pod, metav1.CreateOptions{}) //nolint:errcheck
+
+	// A server at the pod's IP that does not hold the pod's key.
+	srv := keyedSandboxd(t, []byte("someone else's key"), func(w http.ResponseWriter, r *http.Request) {})
+	defer srv.Close()
+	client := authTestTransport(t, k, srv)
+	if _, err := client.Post("http://127.0.0.1:2024/exec", "application/json", strings.NewReader("{}")); err == nil || !strings.Contains(err.Error(), "failed authentication") {
+		t.Fatalf("expected authentication failure, got %v", err)
+	}
+
+	// An IP no sandbox pod holds is refused before dialing.
+	if _, err := client.Get("http://10.9.9.9:2024/ready"); err == nil || !strings.Contains(err.Error(), "no sandbox pod") {
+		t.Fatalf("expected unknown-IP error, got %v", err)
+	}
+}
+
+func TestSandboxdTransport_UnkeyedPodStaysPlain(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset(keyedPod(nil, "legacy", "127.0.0.1"))
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(sandboxdAuthV2Header) != "" {
+			t.Error("legacy pod received an auth header")
+		}
+	}))
+	defer srv.Close()
+	client := authTestTransport(t, k, srv)
+	resp, err := client.Get("http://127.0.0.1:2024/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestLoadSandboxdKeyring_CreatesSecretOnce(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset()
+	a, err := LoadSandboxdKeyring(context.Background(), k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadSandboxdKeyring(context.Background(), k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.master) != 32 || !bytes.Equal(a.master, b.master) {
+		t.Fatal("second load did not reuse the stored master key")
+	}
+}
+
+func TestReadyHandshake_VerifiesProof(t *testing.T) {
+	key := []byte("per-pod-key")
+	srv := keyedSandboxd(t, key, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ready","venv":true}`)) //nolint:errcheck
+	})
+	defer srv.Close()
+	hostPort := strings.TrimPrefix(srv.URL, "http://")
+	if !readyHandshake(context.Background(), hostPort, key) {
+		t.Fatal("keyed handshake should pass")
+	}
+	if readyHandshake(context.Background(), hostPort, []byte("wrong key")) {
+		t.Fatal("handshake with the wrong key should fail")
+	}
+}
+
+func TestSandboxdTransport_RejectsTamperedBody(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset()
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	pod := keyedPod(k, "sandbox-auth", "127.0.0.1")
+	k8s.CoreV1().Pods(sandboxNS).Create(context.Background(), pod, metav1.CreateOptions{}) //nolint:errcheck
+	inner := keyedSandboxd(t, k.keyForPod(pod), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"exit_code":0}`)) //nolint:errcheck
+	})
+	defer inner.Close()
+	// A proxy on the path keeps the genuine headers but rewrites the body.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest(r.Method, inner.URL+r.URL.RequestURI(), r.Body)
+		req.Header = r.Header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.Header().Del("Content-Length")
+		w.Write([]byte(`{"exit_code":1}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+	client := authTestTransport(t, k, srv)
+	if _, err := client.Post("http://127.0.0.1:2024/exec", "application/json", strings.NewReader("{}")); err == nil || !strings.Contains(err.Error(), "body failed authentication") {
+		t.Fatalf("expected body authentication failure, got %v", err)
+	}
+}
+
+func TestSandboxdStreamBody_VerifiesTrailer(t *testing.T) {
+	key, reqMAC := []byte("per-pod-key"), "abc"
+	frames := "data: {\"pid\":7}\n\ndata: {\"exit\":0}\n\n"
+	mac := sandboxdBodyMAC(key, "stream", reqMAC)
+	mac.Write([]byte(frames))
+	trailer := fmt.Sprintf(": sandboxd-mac %x\n\n", mac.Sum(nil))
+	read := func(raw string) (string, error) {
+		b := &sandboxdStreamBody{body: io.NopCloser(strings.NewReader(raw)), mac: sandboxdBodyMAC(key, "stream", reqMAC)}
+		out, err := io.ReadAll(b)
+		return string(out), err
+	}
+
+	if out, err := read(frames + trailer); err != nil || out != frames {
+		t.Fatalf("intact stream: %q, %v", out, err)
+	}
+	if _, err := read(frames); err != errSandboxdBodyAuth {
+		t.Fatalf("truncated stream: %v", err)
+	}
+	if _, err := read(strings.Replace(frames, `"exit":0`, `"exit":1`, 1) + trailer); err != errSandboxdBodyAuth {
+		t.Fatalf("tampered stream: %v", err)
+	}
+	if _, err := read(frames + trailer + "data: late\n\n"); err != errSandboxdBodyAuth {
+		t.Fatalf("frame after trailer: %v", err)
+	}
+}
+
+func TestSandboxdTransport_SendsNonceBoundAuthOnly(t *testing.T) {
+	k8s := kubefake.NewSimpleClientset()
+	k := NewSandboxdKeyring([]byte("0123456789abcdef0123456789abcdef"), k8s)
+	pod := keyedPod(k, "sandbox-auth", "127.0.0.1")
+	k8s.CoreV1().Pods(sandboxNS).Create(context.Background(), pod, metav1.CreateOptions{}) //nolint:errcheck
+	// The nonce-less header would let a peer downgrade to a replayable
+	// request with an unauthenticated body.
+	srv := keyedSandboxd(t, k.keyForPod(pod), func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Sandboxd-Auth") != "" {
+			t.Error("request carries the nonce-less X-Sandboxd-Auth header")
+		}
+	})
+	defer srv.Close()
+	client := authTestTransport(t, k, srv)
+	resp, err := client.Get("http://127.0.0.1:2024/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
-	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -22,10 +21,15 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/xiaods/k8e/pkg/sandbox/apikey"
+	"github.com/xiaods/k8e/pkg/sandbox/e2b"
+	"github.com/xiaods/k8e/pkg/sandbox/tracing"
 	"github.com/xiaods/k8e/pkg/sandboxlayer"
 	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
 	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
 	"github.com/xiaods/k8e/pkg/sandboxmatrix/ratelimit"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/usage"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -42,13 +46,19 @@ import (
 const apiKeySecretNS = "sandbox-matrix"
 const apiKeySecretName = "sandbox-apikeys"
 
+// clientCertTTLDays is the Login leaf lifetime: 90 days (issue #538) is long
+// enough for agent/CI sessions, short enough for key rotation. Clients renew
+// when <30 days remain.
+const clientCertTTLDays = 90
+
 const sandboxdPort = 2024
 
 // SandboxdPort is exported for use by the controller.
 const SandboxdPort = sandboxdPort
 
-// sandboxdClient is a dedicated HTTP client for sandboxd calls with a base timeout.
-var sandboxdClient = &http.Client{Timeout: 5 * time.Minute}
+// sandboxdClient is a dedicated HTTP client for sandboxd calls with a base
+// timeout; its transport authenticates both ends (see sandboxd_auth.go).
+var sandboxdClient = &http.Client{Timeout: 5 * time.Minute, Transport: sandboxdTransport}
 
 // sandboxdURL builds a sandboxd HTTP URL for the given pod IP and path.
 func sandboxdURL(podIP, path string) string {
@@ -114,9 +124,23 @@ type ServerConfig struct {
 	// LayerStoreDir, when set, enables the server-side content-addressed
 	// snapshot layer registry (KIP-16 M2 / issue #511).
 	LayerStoreDir string
+	// LayerStoreBackend, when set, holds the registry instead of
+	// LayerStoreDir (e.g. an S3 bucket shared by every server); LayerStoreDir,
+	// if also set, is its local pull-through layer cache.
+	LayerStoreBackend sandboxlayer.Backend
+	// SnapshotCDC sets the FastCDC chunk sizes for SnapshotSession archives
+	// (zero → sandboxlayer.DefaultCDCParams).
+	SnapshotCDC sandboxlayer.CDCParams
 	// FQDNEnabled enables Cilium toFQDNs egress for sessions with allowedHosts
 	// (requires Cilium DNS proxy; KIP-16 M10 / issue #510).
 	FQDNEnabled bool
+	// EgressProxyPort, when set, runs the CNI-agnostic egress proxy on this
+	// port: sandbox pods get HTTP(S)_PROXY and a NetworkPolicy instead of
+	// the per-session CNP.
+	EgressProxyPort int
+	// NodeName is the Node this server runs on, labelled as an egress
+	// proxy node when EgressProxyPort is set.
+	NodeName string
 	// AdvertiseHostname is the external DNS name (or IP) remote clients use to reach
 	// the gateway; it is added to the server cert SANs so mTLS handshakes against
 	// that name succeed. In AWS it is typically a public domain/EIP name, distinct
@@ -125,6 +149,32 @@ type ServerConfig struct {
 	// ExposeBaseURL is the public base URL (scheme://host[:port]) for KIP-24
 	// exposed-service URLs. Unset → http://<advertise-hostname> → http://localhost.
 	ExposeBaseURL string
+	// ExposeDomain, when set, is a wildcard DNS domain routed to the e2b
+	// server: exposed URLs become <port>-<session>.<domain>, one origin per
+	// exposure, taking scheme and port from the base URL.
+	ExposeDomain string
+	// ApprovalWebhook, when its URL is set, is notified of every new
+	// ConfirmAction approval.
+	ApprovalWebhook ApprovalWebhook
+	// AuditDir, when set, receives the append-only audit log of every RPC
+	// (rotated files prefixed "grpc"); QueryAudit reads it.
+	AuditDir string
+	// AuditRetention is how long rotated audit files are kept
+	// (zero → audit.DefaultRetention).
+	AuditRetention time.Duration
+	// UsageDir, when set, enables usage metering: one record per ended
+	// session for chargeback, kept cluster-wide for QueryUsage and archived
+	// in UsageDir.
+	UsageDir string
+	// MetricsMaxTenants caps distinct tenant label values on the sandbox
+	// metrics; further tenants are reported as "other" (zero → 100).
+	MetricsMaxTenants int
+	// PodStats, when set, meters session CPU, memory and egress.
+	PodStats PodStatsSource
+	// CheckpointRuntimeClasses lists the runtime classes whose CRI runtime
+	// can checkpoint and restore containers; PAUSE_MODE_MEMORY works for
+	// sessions using them (requires the layer store).
+	CheckpointRuntimeClasses []string
 }
 
 // Server implements the SandboxService gRPC interface.
@@ -140,6 +190,7 @@ type Server struct {
 	serverKeyFile         string
 	advertiseHostname     string
 	exposeBaseURLOverride string
+	exposeSigningSecret   string // e2b signing secret; signs signed-access expose URLs
 	caCert                *x509.Certificate
 	caKey                 *ecdsa.PrivateKey
 	// apiKeysMu guards apiKeys + apiKeyByToken against concurrent Login reads
@@ -152,10 +203,17 @@ type Server struct {
 	localAuth     bool
 	rateLimiter   *ratelimit.Limiter
 	layerStore    *sandboxlayer.Store
+	snapshotCDC   sandboxlayer.CDCParams
+	audit         *audit.Log
+	auditDir      string
+	usageLedger   *usage.Ledger
 	// terminal registry (KIP-19): branded terminal_id → sandboxd terminal.
 	terminalsMu sync.RWMutex
 	terminals   map[string]terminalEntry
 	terminalSeq uint64
+	// egressProxyPort serves the egress proxy when non-zero.
+	egressProxyPort int
+	nodeName        string
 }
 
 func NewServer(cfg ServerConfig) *Server {
@@ -173,20 +231,65 @@ func NewServer(cfg ServerConfig) *Server {
 		serverKeyFile:         cfg.ServerKeyFile,
 		advertiseHostname:     cfg.AdvertiseHostname,
 		exposeBaseURLOverride: cfg.ExposeBaseURL,
+		exposeSigningSecret:   e2b.ResolveSigningSecret(),
 		localAuth:             cfg.LocalAuth,
 		rateLimiter:           ratelimit.NewLimiter(ratelimit.DefaultRateConfig()),
 		terminals:             make(map[string]terminalEntry),
+		snapshotCDC:           cfg.SnapshotCDC,
+		egressProxyPort:       cfg.EgressProxyPort,
+		nodeName:              cfg.NodeName,
 	}
 	s.orch = NewOrchestrator(cfg.K8s, cfg.Dyn)
+	s.orch.exposeDomain = strings.ToLower(strings.Trim(cfg.ExposeDomain, "."))
 	if cfg.FQDNEnabled {
 		s.orch.SetFQDNEGressEnabled(true)
 	}
+	if cfg.EgressProxyPort > 0 {
+		enableEgressProxy(cfg.EgressProxyPort)
+	}
+	s.orch.approvalWebhook = cfg.ApprovalWebhook
+	SetMetricsMaxTenants(cfg.MetricsMaxTenants)
+	s.rateLimiter.OnReject = RateLimitRejected
 	RegisterSandboxMetrics(s.orch)
-	if cfg.LayerStoreDir != "" {
+	if cfg.AuditDir != "" {
+		l, err := audit.Open(audit.Options{Dir: cfg.AuditDir, Prefix: "grpc", Retention: cfg.AuditRetention})
+		if err != nil {
+			logrus.Errorf("sandbox gRPC: audit log disabled: %v", err)
+		} else {
+			s.audit, s.auditDir = l, cfg.AuditDir
+		}
+	}
+	if cfg.PodStats != nil {
+		s.orch.SetPodStatsSource(cfg.PodStats)
+	}
+	if cfg.UsageDir != "" {
+		l, err := usage.Open(cfg.UsageDir)
+		if err != nil {
+			logrus.Errorf("sandbox gRPC: usage ledger disabled: %v", err)
+		} else {
+			s.usageLedger = l
+			s.orch.SetUsageLedger(l)
+		}
+	}
+	if cfg.LayerStoreBackend != nil {
+		ls, err := sandboxlayer.NewWithBackend(cfg.LayerStoreBackend, cfg.LayerStoreDir)
+		if err != nil {
+			logrus.Warnf("sandbox gRPC: layer cache %s disabled: %v", cfg.LayerStoreDir, err)
+			ls, _ = sandboxlayer.NewWithBackend(cfg.LayerStoreBackend, "")
+		}
+		s.layerStore = ls
+	} else if cfg.LayerStoreDir != "" {
 		if ls, err := sandboxlayer.New(cfg.LayerStoreDir); err == nil {
 			s.layerStore = ls
 		}
 	}
+	if len(cfg.CheckpointRuntimeClasses) > 0 {
+		if s.layerStore == nil {
+			logrus.Warnf("sandbox gRPC: memory checkpoints disabled: no layer store")
+		} else {
+			s.orch.SetCheckpointer(NewNodeCheckpointer(cfg.K8s, s.layerStore, cfg.CheckpointRuntimeClasses))
+		}
+	}
 	return s
 }
 
@@ -214,10 +317,13 @@ func (s *Server) loadAPIKeys(ctx context.Context) {
 	s.apiKeysMu.RLock()
 	prev := s.apiKeys
 	s.apiKeysMu.RUnlock()
-	if s.revocList != nil && s.issuedStore != nil && prev != nil {
+	if s.revocList != nil && prev != nil {
 		for name := range prev {
 			if _, ok := store[name]; !ok {
-				s.revocList.RevokeByKeyName(s.issuedStore, name)
+				if err := s.revocList.RevokeByKeyName(ctx, s.issuedStore, name, "api key deleted or expired"); err != nil {
+					logrus.Warnf("sandbox gRPC: revoke certificates for deleted API key %q: %v", name, err)
+					continue
+				}
 				logrus.Infof("sandbox gRPC: revoked certificates for deleted API key %q", name)
 			}
 		}
@@ -276,6 +382,11 @@ func (s *Server) reloadConfigLoop(ctx context.Context) {
 		case <-ticker.C:
 			s.loadAPIKeys(ctx)
 			s.reloadRateLimits(ctx)
+			if s.revocList != nil {
+				if err := s.revocList.Prune(ctx); err != nil {
+					logrus.Debugf("sandbox gRPC: prune cert revocations: %v", err)
+				}
+			}
 		}
 	}
 }
@@ -306,6 +417,12 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("grpc port %s is already in use — another gateway may be running", s.lisAddr)
 	}
 
+	// Every server must agree on egress proxy mode: pods created by one
+	// replica are scheduled against the proxies the others run.
+	if err := s.orch.checkEgressProxyNodes(ctx, s.nodeName, s.egressProxyPort); err != nil {
+		return err
+	}
+
 	lis, err := net.Listen("tcp", s.lisAddr)
 	if err != nil {
 		return fmt.Errorf("grpc listen: %w", err)
@@ -319,6 +436,20 @@ func (s *Server) Start(ctx context.Context) error {
 	go s.orch.StartApprovalGC(ctx)
 	// Rebuild background run registry from existing Session CRDs
 	go s.orch.RebuildRunRegistry(ctx, "sandbox-matrix")
+	// Rebuild the expose index from session status.exposedPorts
+	go s.orch.RebuildExposedRegistry(ctx, "sandbox-matrix")
+	// Session informer behind WatchSessions
+	go s.orch.RunSessionWatch(ctx)
+	// Fold this replica's exec and snapshot counts into session usage
+	go s.orch.RunUsageFlush(ctx)
+	if s.egressProxyPort > 0 {
+		go s.orch.labelEgressProxyNode(ctx, s.nodeName, s.egressProxyPort)
+		go func() {
+			if err := s.serveEgressProxy(ctx, s.egressProxyPort); err != nil {
+				logrus.Errorf("sandbox egress proxy: %v", err)
+			}
+		}()
+	}
 
 	// Initialize sandbox CA and server certificate
 	caKey, caCert, err := ensureCA(s.caCertFile, s.caKeyFile)
@@ -343,7 +474,13 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 
 	s.issuedStore = newIssuedCertStore(s.caCertFile[:strings.LastIndex(s.caCertFile, "/")] + "/sandbox-issued.json")
-	s.revocList = newRevocationList()
+	s.revocList = newRevocationList(s.k8s)
+	// Revocations are cluster-wide (Secret-backed): load them before serving
+	// and keep every replica in sync via a watch.
+	if err := s.revocList.Load(ctx); err != nil {
+		logrus.Warnf("sandbox gRPC: load cert revocations: %v", err)
+	}
+	go s.revocList.Watch(ctx)
 
 	opts := []grpc.ServerOption{
 		grpc.Creds(creds),
@@ -351,8 +488,10 @@ func (s *Server) Start(ctx context.Context) error {
 		// restore / file payloads routinely exceed it (see KIP-16 M7).
 		grpc.MaxRecvMsgSize(64 * 1024 * 1024),
 		grpc.MaxSendMsgSize(64 * 1024 * 1024),
-		grpc.ChainUnaryInterceptor(s.rateLimiter.UnaryInterceptor, s.mTLSAuthInterceptor),
-		grpc.ChainStreamInterceptor(s.rateLimiter.StreamInterceptor, s.mTLSStreamInterceptor),
+		// Continue the caller's trace from the traceparent metadata.
+		grpc.StatsHandler(tracing.ServerHandler()),
+		grpc.ChainUnaryInterceptor(s.auditUnaryInterceptor, rpcMetricsUnaryInterceptor, s.rateLimiter.UnaryInterceptor, s.mTLSAuthInterceptor),
+		grpc.ChainStreamInterceptor(s.auditStreamInterceptor, rpcMetricsStreamInterceptor, s.rateLimiter.StreamInterceptor, s.mTLSStreamInterceptor),
 	}
 	gs := grpc.NewServer(opts...)
 	pb.RegisterSandboxServiceServer(gs, s)
@@ -360,6 +499,15 @@ func (s *Server) Start(ctx context.Context) error {
 	go func() {
 		<-ctx.Done()
 		gs.GracefulStop()
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.orch.flushUsage(flushCtx, "")
+		cancel()
+		if s.audit != nil {
+			s.audit.Close()
+		}
+		if s.usageLedger != nil {
+			s.usageLedger.Close()
+		}
 	}()
 	return gs.Serve(lis)
 }
@@ -371,11 +519,22 @@ func (s *Server) CreateSession(ctx context.Context, req *pb.CreateSessionRequest
 	if err := validateSecretRefs(req.SecretRefs); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "secret_refs: %v", err)
 	}
+	if hasHostBoundSecrets(req.SecretRefs) && !egressProxyEnabled() {
+		return nil, status.Errorf(codes.FailedPrecondition, "secret_refs: hosts need the egress proxy (--sandbox-egress-proxy-port)")
+	}
+	if err := validateEgressRules(req.EgressRules); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "egress_rules: %v", err)
+	}
 	if err := s.orch.CheckCapacity(ctx); err != nil {
 		return nil, err
 	}
 	session, err := s.orch.CreateSession(ctx, req)
 	if err != nil {
+		// Orchestrator status errors (e.g. unknown template → NotFound) keep
+		// their code; anything else is an internal failure.
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
 		return nil, status.Errorf(codes.Internal, "create session: %v", err)
 	}
 	return &pb.CreateSessionResponse{SessionId: session.Name, PodIp: session.Status.PodIP}, nil
@@ -416,15 +575,17 @@ func (s *Server) DestroySession(ctx context.Context, req *pb.DestroySessionReque
 }
 
 // PauseSession releases the sandbox pod (CPU/memory) keeping the workspace
-// PVC and session CRD (E2B pause, KIP-18).
+// PVC and session CRD (E2B pause, KIP-18), and with PAUSE_MODE_MEMORY a
+// checkpoint of its processes.
 func (s *Server) PauseSession(ctx context.Context, req *pb.PauseSessionRequest) (*pb.PauseSessionResponse, error) {
 	if req.SessionId == "" {
 		return nil, status.Error(codes.InvalidArgument, "session_id required")
 	}
-	if err := s.orch.PauseSession(ctx, req.SessionId); err != nil {
+	res, err := s.orch.PauseSession(ctx, req.SessionId, req.Mode)
+	if err != nil {
 		return nil, err
 	}
-	return &pb.PauseSessionResponse{Ok: true}, nil
+	return &pb.PauseSessionResponse{Ok: true, Mode: res.Mode, Fallback: res.Fallback}, nil
 }
 
 // ResumeSession re-creates a paused sandbox's pod with its workspace PVC.
@@ -432,10 +593,15 @@ func (s *Server) ResumeSession(ctx context.Context, req *pb.ResumeSessionRequest
 	if req.SessionId == "" {
 		return nil, status.Error(codes.InvalidArgument, "session_id required")
 	}
-	if _, err := s.orch.ResumeSession(ctx, req.SessionId); err != nil {
+	pod, err := s.orch.ResumeSession(ctx, req.SessionId)
+	if err != nil {
 		return nil, err
 	}
-	return &pb.ResumeSessionResponse{Ok: true}, nil
+	return &pb.ResumeSessionResponse{Ok: true, MemoryRestored: pod.Annotations[CheckpointRestoredAnnotation] != ""}, nil
+}
+
+func (s *Server) WatchSessions(req *pb.WatchSessionsRequest, stream pb.SandboxService_WatchSessionsServer) error {
+	return s.orch.WatchSessions(req, stream)
 }
 
 func (s *Server) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecResponse, error) {
@@ -472,6 +638,9 @@ func (s *Server) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecRespons
 		return nil, envErr
 	}
 	body := sandboxdExecBody(req.SessionId, req.Command, timeout, workdir, env)
+	// run_id lets sandboxd spill output past its cap for GetRunOutput.
+	runID := newExecRunID(req.SessionId)
+	body["run_id"] = runID
 	start := time.Now()
 	httpCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout+5)*time.Second)
 	defer cancel()
@@ -490,6 +659,8 @@ func (s *Server) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecRespons
 		DurationMs int64  `json:"duration_ms"`
 		Truncated  bool   `json:"truncated"`
 		TimedOut   bool   `json:"timed_out"`
+		RunID      string `json:"run_id"` // set only when the output was spilled
+		runOutputSizes
 	}
 	json.NewDecoder(resp.Body).Decode(&result)
 	duration := result.DurationMs
@@ -499,6 +670,7 @@ func (s *Server) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecRespons
 	// Prefer sandboxd truncation flag; also mark if streams hit gateway-side cap observation.
 	truncated := result.Truncated || len(result.Stdout) >= maxExecOutputBytes || len(result.Stderr) >= maxExecOutputBytes
 	timedOut := result.TimedOut || (timeout > 0 && duration >= int64(timeout)*1000 && result.ExitCode != 0)
+	s.observeExec(req, time.Duration(duration)*time.Millisecond)
 	return &pb.ExecResponse{
 		Stdout:     result.Stdout,
 		Stderr:     result.Stderr,
@@ -508,6 +680,7 @@ func (s *Server) Exec(ctx context.Context, req *pb.ExecRequest) (*pb.ExecRespons
 		DurationMs: duration,
 		Truncated:  truncated,
 		Language:   req.Language,
+		OutputRef:  result.outputRef(result.RunID),
 	}, nil
 }
 
@@ -529,6 +702,7 @@ func (s *Server) ExecStream(req *pb.ExecRequest, stream pb.SandboxService_ExecSt
 		return envErr
 	}
 	body := sandboxdExecBody(req.SessionId, req.Command, timeout, workdir, env)
+	start := time.Now()
 	httpCtx, cancel := context.WithTimeout(stream.Context(), time.Duration(timeout+5)*time.Second)
 	defer cancel()
 
@@ -537,6 +711,7 @@ func (s *Server) ExecStream(req *pb.ExecRequest, stream pb.SandboxService_ExecSt
 		return status.Errorf(codes.Unavailable, "sandboxd stream: %v", err)
 	}
 	defer resp.Body.Close()
+	defer func() { s.observeExec(req, time.Since(start)) }()
 
 	buf := make([]byte, 4096)
 	for {
@@ -577,14 +752,27 @@ func (s *Server) resolveSessionEnv(ctx context.Context, sessionID string) (map[s
 	if out == nil {
 		out = make(map[string]string, len(sess.Spec.SecretRefs))
 	}
+	// The span records how many refs were resolved, never their values.
+	ctx, span := tracing.Start(ctx, "sandbox.resolveSecrets",
+		attribute.String("sandbox.session_id", sessionID),
+		attribute.Int("sandbox.secret_refs", len(sess.Spec.SecretRefs)))
 	for _, ref := range sess.Spec.SecretRefs {
+		if len(ref.Hosts) > 0 {
+			// Host-bound: the egress proxy injects the value; the
+			// sandbox only ever sees the placeholder.
+			out[ref.EnvVar] = secretPlaceholder(ref)
+			continue
+		}
 		val, rerr := s.readSecretKey(ctx, ref.SecretName, ref.Key)
 		if rerr != nil {
-			return nil, status.Errorf(codes.FailedPrecondition,
+			err := status.Errorf(codes.FailedPrecondition,
 				"secret_ref %s/%s for env %s: %v", ref.SecretName, ref.Key, ref.EnvVar, rerr)
+			tracing.End(span, err)
+			return nil, err
 		}
 		out[ref.EnvVar] = val
 	}
+	tracing.End(span, nil)
 	return out, nil
 }
 
@@ -715,6 +903,14 @@ func (s *Server) ApproveAction(ctx context.Context, req *pb.ApproveActionRequest
 	return s.orch.ApproveAction(ctx, req)
 }
 
+func (s *Server) ListApprovals(ctx context.Context, req *pb.ListApprovalsRequest) (*pb.ListApprovalsResponse, error) {
+	return s.orch.ListApprovals(ctx, req)
+}
+
+func (s *Server) WatchApprovals(req *pb.WatchApprovalsRequest, stream pb.SandboxService_WatchApprovalsServer) error {
+	return s.orch.WatchApprovals(req, stream)
+}
+
 // GetTranscript reads a bounded, offset-resumable window of a session's exec
 // transcript (file-backed in sandboxd; KIP-16 M4 / issue #512).
 func (s *Server) GetTranscript(ctx context.Context, req *pb.GetTranscriptRequest) (*pb.GetTranscriptResponse, error) {
@@ -792,8 +988,18 @@ func (s *Server) GetEvents(ctx context.Context, req *pb.GetEventsRequest) (*pb.G
 	}, nil
 }
 
+// GCLayerStore reclaims registry layers no snapshot manifest references and
+// that were written more than grace ago; younger ones may belong to a
+// snapshot still streaming through another server. No-op without a registry.
+func (s *Server) GCLayerStore(grace time.Duration) (int64, error) {
+	if s.layerStore == nil {
+		return 0, nil
+	}
+	return s.layerStore.GCBefore(time.Now().Add(-grace))
+}
+
 // requireLayerStore returns an error when the server-side layer registry is
-// not enabled (ServerConfig.LayerStoreDir unset).
+// not enabled (neither ServerConfig.LayerStoreDir nor LayerStoreBackend set).
 func (s *Server) requireLayerStore() (*sandboxlayer.Store, error) {
 	if s.layerStore == nil {
 		return nil, status.Error(codes.FailedPrecondition,
@@ -812,7 +1018,23 @@ func (s *Server) SnapshotPut(ctx context.Context, req *pb.SnapshotPutRequest) (*
 	if req.Name == "" || len(req.Layers) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "name and layers required")
 	}
-	if err := store.SaveManifest(req.Name, req.Layers); err != nil {
+	tenant := requestTenant(ctx, "")
+	q, err := s.orch.tenantQuota(ctx, quotaSubject(tenant))
+	if err != nil {
+		return nil, err
+	}
+	if q != nil && q.Spec.MaxSnapshotBytes > 0 {
+		size, err := store.LogicalSize(req.Layers)
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "snapshot put: %v", err)
+		}
+		release, err := s.admitSnapshot(ctx, store, tenant, req.Name, size)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	if err := store.SaveManifestAs(req.Name, sandboxlayer.Manifest{Layers: req.Layers, Owner: tenant}); err != nil {
 		return nil, status.Errorf(codes.Internal, "snapshot put: %v", err)
 	}
 	return &pb.SnapshotPutResponse{Name: req.Name, Layers: int64(len(req.Layers))}, nil
@@ -841,7 +1063,17 @@ func (s *Server) SnapshotList(ctx context.Context, req *pb.SnapshotListRequest)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "snapshot list: %v", err)
 	}
-	return &pb.SnapshotListResponse{Names: names}, nil
+	st, err := store.Stats()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "snapshot list: %v", err)
+	}
+	return &pb.SnapshotListResponse{
+		Names:        names,
+		StoredBytes:  st.Stored,
+		LogicalBytes: st.Logical,
+		UniqueBytes:  st.Unique,
+		DedupRatio:   st.DedupRatio(),
+	}, nil
 }
 
 // GetProcesses lists processes visible in the sandbox pod's pid namespace
@@ -885,10 +1117,36 @@ func (s *Server) PollRun(ctx context.Context, req *pb.PollRunRequest) (*pb.PollR
 // returns the public URL through the k8e API Gateway (KIP-24): the embedded
 // e2b HTTP server (fronted by the Cilium Gateway API on :80/:443) reverse-
 // proxies /k8e/expose/<session>/<port>/ to http://<podIP>:<port>.
+//
+// A signed-access port gets a freshly signed URL on every call, valid for
+// ttl_seconds (default one hour).
 func (s *Server) ExposeService(ctx context.Context, req *pb.ExposeServiceRequest) (*pb.ExposeServiceResponse, error) {
-	return s.orch.ExposeService(ctx, req.SessionId, req.Port, req.Host, s.exposeBaseURL())
+	if req.TtlSeconds < 0 || req.TtlSeconds > maxExposeTTLSeconds {
+		return nil, status.Errorf(codes.InvalidArgument, "ttl_seconds must be in [0, %d]", maxExposeTTLSeconds)
+	}
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultExposeTTL
+	}
+	resp, err := s.orch.ExposeService(ctx, req.SessionId, req.Port, req.Host, s.exposeBaseURL(), req.Access)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Access == pb.ExposeAccess_EXPOSE_ACCESS_SIGNED {
+		expires := time.Now().Add(ttl)
+		nonce := s.orch.exposedNonce(req.SessionId, int(req.Port))
+		resp.Url += "?" + e2b.SignExposeQuery(s.exposeSigningSecret, req.SessionId, int(req.Port), nonce, expires)
+		resp.ExpiresAt = expires.Unix()
+	}
+	return resp, nil
 }
 
+// Signed expose URL lifetimes.
+const (
+	defaultExposeTTL    = time.Hour
+	maxExposeTTLSeconds = 7 * 24 * 3600
+)
+
 // exposeBaseURL is the public gateway base URL exposed services are reachable
 // at. Prefers the advertised external hostname (--sandbox-advertise-hostname,
 // KIP-22); falls back to localhost for loopback/local deployments.
@@ -914,17 +1172,25 @@ func (s *Server) ListExposed(ctx context.Context, req *pb.ListExposedRequest) (*
 
 // UpdateAllowedHosts replaces the session egress allowlist live (spec + CNP).
 func (s *Server) UpdateAllowedHosts(ctx context.Context, req *pb.UpdateAllowedHostsRequest) (*pb.UpdateAllowedHostsResponse, error) {
-	hosts, err := s.orch.UpdateAllowedHosts(ctx, req.SessionId, req.Hosts)
+	if err := validateEgressRules(req.Rules); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "rules: %v", err)
+	}
+	hosts, rules, err := s.orch.UpdateAllowedHosts(ctx, req.SessionId, req.Hosts, egressRulesFromPB(req.Rules))
 	if err != nil {
 		return nil, err
 	}
-	return &pb.UpdateAllowedHostsResponse{Hosts: hosts}, nil
+	return &pb.UpdateAllowedHostsResponse{Hosts: hosts, Rules: egressRulesToPB(rules)}, nil
 }
 
 // Login authenticates the client (via mTLS or API key) and returns a signed client certificate.
 func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
 	keyName, _ := peerIdentity(ctx)
 
+	// A revoked certificate must not renew itself into a fresh one.
+	if keyName != "" && s.revocList.IsRevokedCert(peerCertFromContext(ctx)) {
+		return nil, status.Error(codes.PermissionDenied, "client certificate has been revoked")
+	}
+
 	if keyName == "" {
 		md, ok := metadata.FromIncomingContext(ctx)
 		if !ok {
@@ -941,10 +1207,7 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 		}
 	}
 
-	// 90-day leaf certs (issue #538): long enough for agent/CI sessions, short
-	// enough for key rotation. Clients renew when <30 days remain.
-	const clientCertTTLDays = 90
-	certPEM, fingerprint, err := signClientCert(s.caKey, s.caCert, req.Csr, keyName, clientCertTTLDays)
+	certPEM, fingerprint, serial, err := signClientCert(s.caKey, s.caCert, req.Csr, keyName, clientCertTTLDays)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "sign certificate: %v", err)
 	}
@@ -952,7 +1215,7 @@ func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResp
 	if s.issuedStore != nil {
 		// Opportunistic prune keeps the on-disk ledger from growing without bound.
 		s.issuedStore.PruneExpired()
-		s.issuedStore.Add(keyName, fingerprint, time.Now(), time.Now().Add(time.Duration(clientCertTTLDays)*24*time.Hour))
+		s.issuedStore.Add(keyName, fingerprint, serial, time.Now(), time.Now().Add(time.Duration(clientCertTTLDays)*24*time.Hour))
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -1019,7 +1282,7 @@ func (s *Server) pollForPodIP(ctx context.Context, sessionID string) (string, er
 
 // mTLSAuthInterceptor enforces mTLS for all RPCs except Login.
 func (s *Server) mTLSAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-	if info.FullMethod == "/sandbox.v1.SandboxService/Login" {
+	if info.FullMethod == "/sandbox.v1.SandboxService/Login" || info.FullMethod == "/sandbox.v1.SandboxService/GetCRL" {
 		return handler(ctx, req)
 	}
 	if err := s.checkMTLSAuth(ctx); err != nil {
@@ -1044,21 +1307,41 @@ func (s *Server) checkMTLSAuth(ctx context.Context) error {
 	if keyName == "" {
 		return status.Error(codes.Unauthenticated, "client certificate required for mTLS")
 	}
-	if s.revocList.IsRevoked(certFingerprintFromContext(ctx)) {
+	if s.revocList.IsRevokedCert(peerCertFromContext(ctx)) {
 		return status.Error(codes.PermissionDenied, "client certificate has been revoked")
 	}
 	return nil
 }
 
-func certFingerprintFromContext(ctx context.Context) string {
+// peerCertFromContext returns the verified client leaf certificate, or nil.
+func peerCertFromContext(ctx context.Context) *x509.Certificate {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
-		return ""
+		return nil
 	}
 	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
 	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
-		return ""
+		return nil
 	}
-	h := sha256.Sum256(tlsInfo.State.PeerCertificates[0].Raw)
-	return fmt.Sprintf("%x", h[:])
+	return tlsInfo.State.PeerCertificates[0]
+}
+
+// GetCRL returns the sandbox CA's signed certificate revocation list. It is
+// served without a client certificate (like Login): a CRL is public and
+// clients must be able to fetch it with a revoked or expired cert.
+func (s *Server) GetCRL(ctx context.Context, req *pb.GetCRLRequest) (*pb.GetCRLResponse, error) {
+	if s.caKey == nil || s.caCert == nil {
+		return nil, status.Error(codes.Unavailable, "sandbox CA not initialized")
+	}
+	now := time.Now()
+	der, n, err := buildCRL(s.caKey, s.caCert, s.revocList.Snapshot(), s.issuedStore, now)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	return &pb.GetCRLResponse{
+		Crl:          string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})),
+		ThisUpdate:   now.Unix(),
+		NextUpdate:   now.Add(crlTTL).Unix(),
+		RevokedCount: int32(n),
+	}, nil
 }
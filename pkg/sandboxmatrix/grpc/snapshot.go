@@ -0,0 +1,452 @@
+package grpc
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Native workspace snapshot/restore (KIP-16 M2 follow-up). The workspace is
+// archived inside the sandbox and streamed over sandboxd /exec/stream
+// straight into the server-side layer registry, one chunk at a time, so
+// neither the gRPC message cap nor the string-typed ReadFile/WriteFile RPCs
+// bound the snapshot size. Restore ships only the layers the sandbox does
+// not already hold in its layer cache, then extracts the whole chain.
+
+// restorePieceSize bounds one /files/write upload while restoring a layer
+// (raw bytes, a multiple of 3 so pieces base64-encode without padding).
+const restorePieceSize = 3 * 1024 * 1024
+
+// defaultSnapshotTimeout matches the sandboxd client's overall timeout.
+const defaultSnapshotTimeout = 300
+
+// sandboxLayerCache is where a sandbox keeps restored layers so a later
+// restore against the same base only needs the new ones.
+const sandboxLayerCache = "/tmp/.k8e-layers"
+
+// snapshotArchiveCmd writes the workspace as a tar stream, base64-encoded:
+// sandboxd relays stdout as SSE text, which is not binary-safe. Run
+// directories under .k8e_bg are output spills, not workspace state.
+const snapshotArchiveCmd = "tar cf - -C /workspace --exclude=./.k8e_bg . 2>/dev/null | base64"
+
+// snapshotNameRe keeps registry names safe as manifest file names.
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func validSnapshotName(name string) error {
+	if !snapshotNameRe.MatchString(name) {
+		return status.Errorf(codes.InvalidArgument, "invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// SnapshotSession archives the session's /workspace into the server-side
+// registry under req.Name.
+func (s *Server) SnapshotSession(ctx context.Context, req *pb.SnapshotSessionRequest) (*pb.SnapshotSessionResponse, error) {
+	store, err := s.requireLayerStore()
+	if err != nil {
+		return nil, err
+	}
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id required")
+	}
+	if err := validSnapshotName(req.Name); err != nil {
+		return nil, err
+	}
+	session, err := s.orch.getSession(ctx, req.SessionId)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "session %s not found", req.SessionId)
+	}
+	// Refuse up front when the tenant is already at its snapshot quota; the
+	// archive size is only known once it has been stored.
+	tenant := sessionTenant(session)
+	if err := s.checkSnapshot(ctx, store, tenant, req.Name, 0); err != nil {
+		return nil, err
+	}
+	podIP, err := s.getPodIP(ctx, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultSnapshotTimeout
+	}
+	httpCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout+5)*time.Second)
+	defer cancel()
+
+	body := sandboxdExecBody(req.SessionId, snapshotArchiveCmd, timeout, "/workspace", nil)
+	resp, err := sandboxdPost(httpCtx, podIP, "/exec/stream", body)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "sandboxd stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, status.Errorf(codes.Unavailable, "sandboxd stream: HTTP %d", resp.StatusCode)
+	}
+
+	out := &sseStdout{r: bufio.NewReaderSize(resp.Body, 16*1024)}
+	archive := &countingReader{r: base64.NewDecoder(base64.StdEncoding, out)}
+	cdc := s.snapshotCDC
+	if cdc == (sandboxlayer.CDCParams{}) {
+		cdc = sandboxlayer.DefaultCDCParams
+	}
+	layers, files, err := storeArchive(store, archive, cdc)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "snapshot %s: %v", req.Name, err)
+	}
+	if !out.exited {
+		return nil, status.Errorf(codes.Unavailable, "snapshot %s: archive stream ended early", req.Name)
+	}
+	if out.exitCode != 0 {
+		return nil, status.Errorf(codes.Internal, "snapshot %s: archive exited %d", req.Name, out.exitCode)
+	}
+	// Unpublished layers are left to the layer GC.
+	release, err := s.admitSnapshot(ctx, store, tenant, req.Name, archive.n)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	m := sandboxlayer.Manifest{Layers: layers, Chunking: sandboxlayer.ChunkingFastCDC, CDC: &cdc, Owner: tenant}
+	if err := store.PublishManifest(req.Name, m); err != nil {
+		return nil, status.Errorf(codes.Internal, "snapshot %s: %v", req.Name, err)
+	}
+	s.orch.recordSnapshotBytes(req.SessionId, archive.n)
+	sandboxSnapshotBytes.WithLabelValues("put").Observe(float64(archive.n))
+	return &pb.SnapshotSessionResponse{
+		Name:      req.Name,
+		Layers:    layers,
+		SizeBytes: archive.n,
+		FileCount: files,
+	}, nil
+}
+
+// storeArchive chunks a tar stream into the store (content-defined, so
+// unchanged files dedup against earlier snapshots) while validating it, so
+// a truncated archive is rejected instead of published.
+func storeArchive(store *sandboxlayer.Store, archive io.Reader, cdc sandboxlayer.CDCParams) ([]string, int64, error) {
+	pr, pw := io.Pipe()
+	type tarResult struct {
+		files int64
+		err   error
+	}
+	done := make(chan tarResult, 1)
+	go func() {
+		files, err := countTarEntries(pr)
+		// A malformed archive fails the writer (and so PutCDC) with err.
+		pr.CloseWithError(err)
+		done <- tarResult{files, err}
+	}()
+	layers, err := store.PutCDC(io.TeeReader(archive, pw), cdc)
+	pw.Close()
+	res := <-done
+	if res.err != nil && (err == nil || errors.Is(err, res.err)) {
+		return nil, 0, fmt.Errorf("invalid archive: %w", res.err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.files == 0 {
+		return nil, 0, errors.New("empty archive")
+	}
+	return layers, res.files, nil
+}
+
+// countTarEntries walks a tar stream to its end-of-archive marker and
+// drains the trailing padding.
+func countTarEntries(r io.Reader) (int64, error) {
+	tr := tar.NewReader(r)
+	var n int64
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	_, err := io.Copy(io.Discard, r)
+	return n, err
+}
+
+// sseStdout reassembles stdout from a sandboxd /exec/stream body. It drops
+// the {"pid"} / {"exit"} control frames and records the exit code.
+// Payload newlines at frame boundaries may be lost, which is harmless for
+// the base64 text it carries.
+type sseStdout struct {
+	r        *bufio.Reader
+	pending  []byte
+	exited   bool
+	exitCode int
+}
+
+func (s *sseStdout) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		frame, err := s.next()
+		if err != nil {
+			return 0, err
+		}
+		s.pending = frame
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next returns the payload of the next stdout frame.
+func (s *sseStdout) next() ([]byte, error) {
+	for {
+		raw, err := s.r.ReadBytes('\n')
+		if len(raw) == 0 && err != nil {
+			return nil, err
+		}
+		// Accumulate until the blank line that ends the event.
+		for err == nil && !bytes.HasSuffix(raw, []byte("\n\n")) {
+			var more []byte
+			more, err = s.r.ReadBytes('\n')
+			raw = append(raw, more...)
+		}
+		payload := bytes.TrimLeft(raw, "\n")
+		payload = bytes.TrimSuffix(payload, []byte("\n\n"))
+		payload = bytes.TrimPrefix(payload, []byte("data: "))
+		if bytes.HasPrefix(payload, []byte("{")) {
+			if cerr := s.control(payload); cerr != nil {
+				return nil, cerr
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if len(payload) > 0 {
+			return payload, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// control handles a JSON control frame; base64 payloads never start with '{'.
+func (s *sseStdout) control(frame []byte) error {
+	var c struct {
+		Exit  *int   `json:"exit"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(frame, &c); err != nil {
+		return nil // not a control frame we know
+	}
+	if c.Error != "" {
+		return fmt.Errorf("sandboxd: %s", c.Error)
+	}
+	if c.Exit != nil {
+		s.exited = true
+		s.exitCode = *c.Exit
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// RestoreSession extracts registry snapshot req.Name into the session's
+// /workspace. With req.Base, layers shared with the base that the sandbox
+// still has cached are not transferred again (sandboxlayer.Delta).
+func (s *Server) RestoreSession(ctx context.Context, req *pb.RestoreSessionRequest) (*pb.RestoreSessionResponse, error) {
+	store, err := s.requireLayerStore()
+	if err != nil {
+		return nil, err
+	}
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id required")
+	}
+	if err := validSnapshotName(req.Name); err != nil {
+		return nil, err
+	}
+	want, err := store.LoadManifest(req.Name)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "snapshot %s: %v", req.Name, err)
+	}
+	var have *sandboxlayer.Manifest
+	if req.Base != "" {
+		if err := validSnapshotName(req.Base); err != nil {
+			return nil, err
+		}
+		if have, err = store.LoadManifest(req.Base); err != nil {
+			return nil, status.Errorf(codes.NotFound, "base snapshot %s: %v", req.Base, err)
+		}
+	}
+	podIP, err := s.getPodIP(ctx, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultSnapshotTimeout
+	}
+
+	ship, err := s.layersToShip(ctx, podIP, req.SessionId, have, want)
+	if err != nil {
+		return nil, err
+	}
+	var shipped int64
+	for _, d := range ship {
+		n, err := s.uploadLayer(ctx, podIP, store, d)
+		if err != nil {
+			return nil, err
+		}
+		shipped += n
+	}
+
+	script := restoreScript(ship, want.Layers)
+	res, err := s.sandboxdExec(ctx, podIP, req.SessionId, script, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, status.Errorf(codes.Internal, "restore %s: extract exited %d: %s",
+			req.Name, res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	sandboxSnapshotBytes.WithLabelValues("get").Observe(float64(shipped))
+	return &pb.RestoreSessionResponse{
+		Name:              req.Name,
+		Layers:            int64(len(want.Layers)),
+		TransferredLayers: int64(len(ship)),
+		TransferredBytes:  shipped,
+	}, nil
+}
+
+// layersToShip returns the distinct layers of want the sandbox lacks. With
+// no base that is all of them; with a base, the Delta plus any base layer
+// that has gone from the sandbox's cache (pod restarted, cache cleared).
+func (s *Server) layersToShip(ctx context.Context, podIP, sessionID string, have, want *sandboxlayer.Manifest) ([]string, error) {
+	cached := map[string]bool{}
+	missing := map[string]bool{}
+	if have != nil {
+		res, err := s.sandboxdExec(ctx, podIP, sessionID, "ls "+sandboxLayerCache+" 2>/dev/null || true", 30)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Fields(res.Stdout) {
+			cached[name] = true
+		}
+		for _, d := range sandboxlayer.Delta(have, want) {
+			missing[d] = true
+		}
+	}
+	seen := map[string]bool{}
+	var ship []string
+	for _, d := range want.Layers {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		if have == nil || missing[d] || !cached[d] {
+			ship = append(ship, d)
+		}
+	}
+	return ship, nil
+}
+
+// uploadLayer writes one layer into the sandbox cache as <digest>.b64 in
+// bounded pieces, returning its uncompressed size.
+func (s *Server) uploadLayer(ctx context.Context, podIP string, store *sandboxlayer.Store, digest string) (int64, error) {
+	content, err := store.Get(digest)
+	if err != nil {
+		return 0, status.Errorf(codes.DataLoss, "layer %s: %v", digest, err)
+	}
+	if err := writeBase64Pieces(ctx, podIP, sandboxLayerCache+"/"+digest+".b64", content, "w"); err != nil {
+		return 0, err
+	}
+	return int64(len(content)), nil
+}
+
+// writeBase64Pieces writes content base64-encoded to path through sandboxd
+// /files/write in bounded pieces. mode "a" appends to an existing file, so
+// successive calls concatenate as long as every content but the last is a
+// multiple of 3 bytes long.
+func writeBase64Pieces(ctx context.Context, podIP, path string, content []byte, mode string) error {
+	for off := 0; off == 0 || off < len(content); off += restorePieceSize {
+		end := off + restorePieceSize
+		if end > len(content) {
+			end = len(content)
+		}
+		body := map[string]any{
+			"path":    path,
+			"content": base64.StdEncoding.EncodeToString(content[off:end]),
+			"mode":    mode,
+		}
+		resp, err := sandboxdPost(ctx, podIP, "/files/write", body)
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "sandboxd write: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return status.Errorf(codes.Internal, "sandboxd write %s: HTTP %d", path, resp.StatusCode)
+		}
+		mode = "a"
+	}
+	return nil
+}
+
+// restoreScript decodes the shipped layers into the cache and extracts the
+// full chain into /workspace. Digests are hex, so they need no quoting.
+func restoreScript(shipped, chain []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "set -e; mkdir -p %s; cd %s", sandboxLayerCache, sandboxLayerCache)
+	for _, d := range shipped {
+		// Decode via a temp name: a cached layer is always complete.
+		fmt.Fprintf(&b, "; base64 -d %s.b64 > %s.tmp; mv %s.tmp %s; rm -f %s.b64", d, d, d, d, d)
+	}
+	fmt.Fprintf(&b, "; cat %s | tar xf - -C /workspace", strings.Join(chain, " "))
+	return b.String()
+}
+
+type sandboxdExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int32  `json:"exit_code"`
+}
+
+// sandboxdExec runs an internal command via sandboxd /exec.
+func (s *Server) sandboxdExec(ctx context.Context, podIP, sessionID, command string, timeout int32) (*sandboxdExecResult, error) {
+	return sandboxdExecAt(ctx, podIP, sessionID, command, timeout)
+}
+
+func sandboxdExecAt(ctx context.Context, podIP, sessionID, command string, timeout int32) (*sandboxdExecResult, error) {
+	httpCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout+5)*time.Second)
+	defer cancel()
+	resp, err := sandboxdPost(httpCtx, podIP, "/exec", sandboxdExecBody(sessionID, command, timeout, "/workspace", nil))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "sandboxd exec: %v", err)
+	}
+	defer resp.Body.Close()
+	var res sandboxdExecResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, status.Errorf(codes.Internal, "sandboxd exec: %v", err)
+	}
+	return &res, nil
+}
@@ -0,0 +1,292 @@
+package grpc
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xiaods/k8e/pkg/sandboxlayer"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSandboxd serves h for every pod IP by dialing the test server from
+// sandboxdClient, so tests do not need the fixed sandboxd port.
+func fakeSandboxd(t *testing.T, h http.Handler) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	old := sandboxdClient
+	sandboxdClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+	t.Cleanup(func() {
+		sandboxdClient = old
+		srv.Close()
+	})
+}
+
+func snapshotTestServer(t *testing.T, sessionID string) (*Server, *sandboxlayer.Store) {
+	t.Helper()
+	s := newTestServer()
+	ls, err := sandboxlayer.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("layerstore: %v", err)
+	}
+	s.layerStore = ls
+	mustCreateSession(t, s.orch, sessionID)
+	stubSessionPodIP(context.Background(), t, s.orch, sessionID, "127.0.0.1")
+	return s, ls
+}
+
+func testWorkspaceTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	bin := make([]byte, 20000)
+	for i := range bin {
+		bin[i] = byte(i * 7)
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"./main.go", []byte("package main\n")}, {"./bin/blob", bin}} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeExecSSE replays archive the way sandboxd relays `... | base64`:
+// wrapped base64 in 4 KiB stdout frames between the pid and exit frames.
+func writeExecSSE(w http.ResponseWriter, archive []byte, exit int) {
+	enc := base64.StdEncoding.EncodeToString(archive)
+	var wrapped strings.Builder
+	for len(enc) > 76 {
+		wrapped.WriteString(enc[:76] + "\n")
+		enc = enc[76:]
+	}
+	wrapped.WriteString(enc + "\n")
+	out := wrapped.String()
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, "data: {\"pid\":42}\n\n")
+	for len(out) > 0 {
+		n := 4096
+		if n > len(out) {
+			n = len(out)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", out[:n])
+		out = out[n:]
+	}
+	fmt.Fprintf(w, "data: {\"exit\":%d}\n\n", exit)
+}
+
+func TestSnapshotSession_StreamsIntoRegistry(t *testing.T) {
+	s, ls := snapshotTestServer(t, "snap-sess")
+	archive := testWorkspaceTar(t)
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/exec/stream" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		writeExecSSE(w, archive, 0)
+	}))
+
+	resp, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-sess", Name: "v1"})
+	if err != nil {
+		t.Fatalf("SnapshotSession: %v", err)
+	}
+	if resp.FileCount != 2 || resp.SizeBytes != int64(len(archive)) {
+		t.Fatalf("unexpected stats: files=%d size=%d (want 2, %d)", resp.FileCount, resp.SizeBytes, len(archive))
+	}
+	m, err := ls.LoadManifest("v1")
+	if err != nil {
+		t.Fatalf("manifest not published: %v", err)
+	}
+	got, err := ls.Assemble(m.Layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, archive) {
+		t.Fatal("registry content does not match the workspace archive")
+	}
+	if m.Chunking != sandboxlayer.ChunkingFastCDC || m.CDC == nil || *m.CDC != sandboxlayer.DefaultCDCParams {
+		t.Fatalf("manifest chunking not recorded: %q %+v", m.Chunking, m.CDC)
+	}
+
+	// A second identical snapshot adds no layers: the registry reports 2×.
+	if _, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-sess", Name: "v2"}); err != nil {
+		t.Fatalf("SnapshotSession v2: %v", err)
+	}
+	list, err := s.SnapshotList(context.Background(), &pb.SnapshotListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.LogicalBytes != 2*int64(len(archive)) || list.UniqueBytes != int64(len(archive)) || list.DedupRatio != 2 {
+		t.Fatalf("unexpected dedup stats: %+v", list)
+	}
+}
+
+func TestSnapshotSession_RejectsBrokenArchive(t *testing.T) {
+	archive := testWorkspaceTar(t)
+	for name, tc := range map[string]struct {
+		archive []byte
+		exit    int
+	}{
+		"truncated": {archive[:len(archive)/2], 0},
+		"nonzero":   {archive, 1},
+		"empty":     {nil, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, ls := snapshotTestServer(t, "snap-bad")
+			fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeExecSSE(w, tc.archive, tc.exit)
+			}))
+			_, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-bad", Name: "bad"})
+			if err == nil {
+				t.Fatal("expected broken archive to fail")
+			}
+			if _, lerr := ls.LoadManifest("bad"); lerr == nil {
+				t.Fatal("broken archive must not be published")
+			}
+		})
+	}
+}
+
+func TestSnapshotSession_Validation(t *testing.T) {
+	s := newTestServer()
+	if _, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "x", Name: "v1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without a registry, got %v", err)
+	}
+	s, _ = snapshotTestServer(t, "snap-val")
+	for _, name := range []string{"", "../escape", "a/b", ".hidden"} {
+		_, err := s.SnapshotSession(context.Background(), &pb.SnapshotSessionRequest{SessionId: "snap-val", Name: name})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("name %q: expected InvalidArgument, got %v", name, err)
+		}
+	}
+}
+
+// restoreSandboxd records what RestoreSession ships to the sandbox.
+type restoreSandboxd struct {
+	mu      sync.Mutex
+	cached  []string
+	files   map[string]string // path → uploaded base64 text
+	scripts []string
+}
+
+func (f *restoreSandboxd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/files/write":
+		path, _ := body["path"].(string)
+		content, _ := body["content"].(string)
+		if body["mode"] == "a" {
+			f.files[path] += content
+		} else {
+			f.files[path] = content
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	case "/exec":
+		cmd, _ := body["command"].(string)
+		out := ""
+		if strings.HasPrefix(cmd, "ls ") {
+			out = strings.Join(f.cached, "\n")
+		} else {
+			f.scripts = append(f.scripts, cmd)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"stdout": out, "exit_code": 0})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRestoreSession_ShipsOnlyMissingLayers(t *testing.T) {
+	s, ls := snapshotTestServer(t, "restore-sess")
+	var digests []string
+	for _, c := range []string{"layer-a", "layer-b", "layer-c"} {
+		d, err := ls.Put([]byte(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, d)
+	}
+	a, b, c := digests[0], digests[1], digests[2]
+	if err := ls.PublishManifest("base", sandboxlayer.Manifest{Layers: []string{a, b}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.PublishManifest("next", sandboxlayer.Manifest{Layers: []string{a, b, c}}); err != nil {
+		t.Fatal(err)
+	}
+	// The sandbox still holds a but lost b: b is re-shipped with the Delta (c).
+	fake := &restoreSandboxd{cached: []string{a}, files: map[string]string{}}
+	fakeSandboxd(t, fake)
+
+	resp, err := s.RestoreSession(context.Background(), &pb.RestoreSessionRequest{SessionId: "restore-sess", Name: "next", Base: "base"})
+	if err != nil {
+		t.Fatalf("RestoreSession: %v", err)
+	}
+	if resp.Layers != 3 || resp.TransferredLayers != 2 || resp.TransferredBytes != int64(len("layer-b")+len("layer-c")) {
+		t.Fatalf("unexpected transfer: %+v", resp)
+	}
+	if _, ok := fake.files[sandboxLayerCache+"/"+a+".b64"]; ok {
+		t.Fatal("cached base layer must not be re-uploaded")
+	}
+	raw, _ := base64.StdEncoding.DecodeString(fake.files[sandboxLayerCache+"/"+c+".b64"])
+	if string(raw) != "layer-c" {
+		t.Fatalf("uploaded layer content %q", raw)
+	}
+	if len(fake.scripts) != 1 || !strings.Contains(fake.scripts[0], "cat "+a+" "+b+" "+c+" | tar xf - -C /workspace") {
+		t.Fatalf("unexpected extract script: %v", fake.scripts)
+	}
+
+	// Without a base every layer ships.
+	full, err := s.RestoreSession(context.Background(), &pb.RestoreSessionRequest{SessionId: "restore-sess", Name: "next"})
+	if err != nil {
+		t.Fatalf("RestoreSession (full): %v", err)
+	}
+	if full.TransferredLayers != 3 {
+		t.Fatalf("expected a full restore to ship 3 layers, got %d", full.TransferredLayers)
+	}
+
+	if _, err := s.RestoreSession(context.Background(), &pb.RestoreSessionRequest{SessionId: "restore-sess", Name: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for unknown snapshot, got %v", err)
+	}
+}
+
+func TestSSEStdout_ReassemblesFrames(t *testing.T) {
+	stream := "data: {\"pid\":7}\n\ndata: QUJD\nREVG\n\n\ndata: \nR0hJ\n\ndata: {\"exit\":3}\n\n"
+	out := &sseStdout{r: bufio.NewReader(strings.NewReader(stream))}
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.ReplaceAll(got.String(), "\n", "") != "QUJDREVGR0hJ" {
+		t.Fatalf("reassembled %q", got.String())
+	}
+	if !out.exited || out.exitCode != 3 {
+		t.Fatalf("exit frame not recorded: exited=%v code=%d", out.exited, out.exitCode)
+	}
+}
@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SandboxTemplate as a consumed resource: a session names a template and its
+// pod is built from the template's image, runtime class, resource limits and
+// allowed hosts. Warm pools reference a template via spec.templateRef and
+// label their pods with it, so a claim only adopts a pod booted from the same
+// template (a python-data pod is never handed to a rust-toolchain session).
+
+// LabelTemplate records the SandboxTemplate a sandbox pod was built from.
+// Pods built without a template carry no label.
+const LabelTemplate = "sandbox.k8e.io/template"
+
+// TemplateGVR is the SandboxTemplate resource. Exported for the warm-pool controller.
+var TemplateGVR = schema.GroupVersionResource{Group: sandboxAPIGroup, Version: "v1alpha1", Resource: "sandboxtemplates"}
+
+// GetTemplate reads the named SandboxTemplate from namespace.
+func GetTemplate(ctx context.Context, dyn dynamic.Interface, namespace, name string) (*sandboxv1.SandboxTemplate, error) {
+	u, err := dyn.Resource(TemplateGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var t sandboxv1.SandboxTemplate
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ApplyTemplate overlays the template's image and cpu/memory limits onto the
+// given defaults; anything the template leaves unset keeps its default. A nil
+// template returns the defaults unchanged.
+func ApplyTemplate(t *sandboxv1.SandboxTemplate, image, cpu, memory string) (string, string, string) {
+	if t == nil {
+		return image, cpu, memory
+	}
+	if t.Spec.Image != "" {
+		image = t.Spec.Image
+	}
+	if q, ok := t.Spec.ResourceLimits["cpu"]; ok {
+		cpu = q.String()
+	}
+	if q, ok := t.Spec.ResourceLimits["memory"]; ok {
+		memory = q.String()
+	}
+	return image, cpu, memory
+}
+
+// podProfile is what claimOrCreatePod builds (or matches a warm pod) against.
+type podProfile struct {
+	runtimeClass string
+	template     string // "" = no template; only unlabeled warm pods match
+	image        string
+	cpu          string
+	memory       string
+}
+
+// sessionTemplate loads the template a session names, mapping a missing
+// template to NotFound so callers see a client error rather than Internal.
+// An empty name returns (nil, nil).
+func (o *Orchestrator) sessionTemplate(ctx context.Context, name string) (*sandboxv1.SandboxTemplate, error) {
+	if name == "" {
+		return nil, nil
+	}
+	t, err := GetTemplate(ctx, o.dynamic, sandboxNS, name)
+	if apierrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "sandbox template %s not found", name)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get sandbox template %s: %v", name, err)
+	}
+	return t, nil
+}
+
+// newPodProfile resolves the pod profile for a session: template values
+// override the matrix defaults, and an explicit runtimeClass wins over both.
+func newPodProfile(t *sandboxv1.SandboxTemplate, runtimeClass, matrixCPU, matrixMemory string) podProfile {
+	if runtimeClass == "" && t != nil {
+		runtimeClass = t.Spec.RuntimeClass
+	}
+	if runtimeClass == "" {
+		runtimeClass = "gvisor"
+	}
+	p := podProfile{runtimeClass: runtimeClass}
+	if t != nil {
+		p.template = t.Name
+	}
+	p.image, p.cpu, p.memory = ApplyTemplate(t, sandboxImage, matrixCPU, matrixMemory)
+	return p
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// createTestTemplate stores a SandboxTemplate in the fake dynamic client.
+func createTestTemplate(t *testing.T, o *Orchestrator, tmpl *sandboxv1.SandboxTemplate) {
+	t.Helper()
+	tmpl.TypeMeta = metav1.TypeMeta{APIVersion: testAPIVer, Kind: "SandboxTemplate"}
+	tmpl.Namespace = sandboxNS
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tmpl)
+	if err != nil {
+		t.Fatalf("convert template: %v", err)
+	}
+	if _, err := o.dynamic.Resource(TemplateGVR).Namespace(sandboxNS).Create(context.Background(),
+		&unstructured.Unstructured{Object: obj}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+}
+
+func pythonTemplate() *sandboxv1.SandboxTemplate {
+	return &sandboxv1.SandboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "python-data"},
+		Spec: sandboxv1.SandboxTemplateSpec{
+			RuntimeClass: "kata",
+			AllowedHosts: []string{"pypi.org"},
+			Image:        "ghcr.io/example/python-data:1",
+			ResourceLimits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+	}
+}
+
+func TestCreateSession_Template_BuildsPodFromTemplate(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	createTestTemplate(t, o, pythonTemplate())
+
+	sess, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "tpl", Template: "python-data"})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	if sess.Spec.Template != "python-data" || sess.Spec.RuntimeClass != "kata" {
+		t.Fatalf("expected template python-data / runtime kata, got %q / %q", sess.Spec.Template, sess.Spec.RuntimeClass)
+	}
+	if len(sess.Spec.AllowedHosts) != 1 || sess.Spec.AllowedHosts[0] != "pypi.org" {
+		t.Fatalf("expected template allowed hosts, got %v", sess.Spec.AllowedHosts)
+	}
+	pod, err := o.k8s.CoreV1().Pods(sandboxNS).Get(ctx, sess.Status.PodName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	if pod.Labels[LabelTemplate] != "python-data" {
+		t.Fatalf("expected template label on pod, got %v", pod.Labels)
+	}
+	c := pod.Spec.Containers[0]
+	if c.Image != "ghcr.io/example/python-data:1" {
+		t.Fatalf("expected template image, got %s", c.Image)
+	}
+	if got := c.Resources.Limits[corev1.ResourceMemory]; got.String() != "4Gi" {
+		t.Fatalf("expected template memory limit 4Gi, got %s", got.String())
+	}
+}
+
+func TestCreateSession_Template_RequestOverrides(t *testing.T) {
+	o := newTestOrchestrator()
+	createTestTemplate(t, o, pythonTemplate())
+
+	sess, err := o.CreateSession(context.Background(), &pb.CreateSessionRequest{
+		SessionId:    "tpl-override",
+		Template:     "python-data",
+		RuntimeClass: "gvisor",
+		AllowedHosts: []string{"github.com"},
+	})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	if sess.Spec.RuntimeClass != "gvisor" {
+		t.Fatalf("explicit runtime should win over template, got %s", sess.Spec.RuntimeClass)
+	}
+	if len(sess.Spec.AllowedHosts) != 1 || sess.Spec.AllowedHosts[0] != "github.com" {
+		t.Fatalf("explicit allowed hosts should win over template, got %v", sess.Spec.AllowedHosts)
+	}
+}
+
+func TestCreateSession_Template_NotFound(t *testing.T) {
+	o := newTestOrchestrator()
+	_, err := o.CreateSession(context.Background(), &pb.CreateSessionRequest{SessionId: "tpl-missing", Template: "nope"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for unknown template, got %v", err)
+	}
+}
+
+func TestClaimWarmPod_TemplateMatch(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	o.warmPodHealthCheck = func(ctx context.Context, pod *corev1.Pod) bool { return true }
+	createTestTemplate(t, o, pythonTemplate())
+
+	plain := warmTestPod("warm-plain", "10.0.1.1")
+	plain.Labels[labelRuntimeClass] = "kata"
+	rust := warmTestPod("warm-rust", "10.0.1.2")
+	rust.Labels[labelRuntimeClass] = "kata"
+	rust.Labels[LabelTemplate] = "rust-toolchain"
+	py := warmTestPod("warm-py", "10.0.1.3")
+	py.Labels[labelRuntimeClass] = "kata"
+	py.Labels[LabelTemplate] = "python-data"
+	for _, p := range []*corev1.Pod{plain, rust, py} {
+		o.k8s.CoreV1().Pods(sandboxNS).Create(ctx, p, metav1.CreateOptions{}) //nolint:errcheck
+	}
+
+	sess, err := o.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: "tpl-warm", Template: "python-data"})
+	if err != nil {
+		t.Fatalf(msgCreate, err)
+	}
+	if sess.Status.PodName != "warm-py" {
+		t.Fatalf("expected python-data warm pod claimed, got %s", sess.Status.PodName)
+	}
+}
+
+func TestClaimWarmPod_UntemplatedSessionSkipsTemplatePods(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	o.warmPodHealthCheck = func(ctx context.Context, pod *corev1.Pod) bool { return true }
+
+	py := warmTestPod("warm-py", "10.0.1.4")
+	py.Labels[LabelTemplate] = "python-data"
+	o.k8s.CoreV1().Pods(sandboxNS).Create(ctx, py, metav1.CreateOptions{}) //nolint:errcheck
+
+	sess := mustCreateSession(t, o, "no-tpl")
+	if sess.Status.PodName == "warm-py" {
+		t.Fatal("untemplated session must not claim a template warm pod")
+	}
+}
+
+func TestApplyTemplate_NilKeepsDefaults(t *testing.T) {
+	img, cpu, mem := ApplyTemplate(nil, "img", "500m", "512Mi")
+	if img != "img" || cpu != "500m" || mem != "512Mi" {
+		t.Fatalf("nil template should keep defaults, got %s %s %s", img, cpu, mem)
+	}
+}
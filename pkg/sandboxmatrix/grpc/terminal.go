@@ -254,6 +254,9 @@ func (s *Server) TerminalStream(req *pb.TerminalStreamRequest, stream pb.Sandbox
 			return status.Errorf(codes.Internal, "pty stream frame: %v", err)
 		}
 		if frame.exit != nil {
+			if err := finishSandboxdStream(resp.Body); err != nil {
+				return status.Errorf(codes.Unavailable, "pty stream read: %v", err)
+			}
 			if err := stream.Send(&pb.TerminalStreamResponse{
 				Frame: &pb.TerminalStreamResponse_Exit{Exit: frame.exit},
 			}); err != nil {
@@ -0,0 +1,446 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/usage"
+)
+
+// Per-session usage metering for chargeback. The leader samples each
+// session pod's cumulative kubelet counters (CPU time, working set,
+// transmitted bytes) into status.usage; every gateway replica counts the
+// execs and snapshots it served and folds them in on the same interval.
+// When a session ends its usage is written to the cluster-wide ledger,
+// which QueryUsage reads, and appended to this server's ledger archive.
+
+// usageInterval paces the flush of exec and snapshot counts.
+const usageInterval = 30 * time.Second
+
+// defaultUsageQueryLimit caps QueryUsage when the request sets no limit.
+const defaultUsageQueryLimit = 1000
+
+// PodStats is one pod's counters as reported by the kubelet. CPU time and
+// transmitted bytes are cumulative over the pod's life.
+type PodStats struct {
+	CPUNanoSeconds int64
+	MemoryBytes    int64
+	TxBytes        int64
+}
+
+// PodStatsSource reads the counters of the sandbox pods on one node, keyed
+// by pod name.
+type PodStatsSource interface {
+	NodePodStats(ctx context.Context, node string) (map[string]PodStats, error)
+}
+
+// KubeletPodStats reads the kubelet summary API through the API server's
+// node proxy (the source metrics-server scrapes).
+func KubeletPodStats(k8s kubernetes.Interface) PodStatsSource {
+	return kubeletStats{k8s: k8s}
+}
+
+type kubeletStats struct {
+	k8s kubernetes.Interface
+}
+
+// kubeletSummary is the subset of the kubelet stats/summary response used
+// for metering.
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		CPU *struct {
+			UsageCoreNanoSeconds *uint64 `json:"usageCoreNanoSeconds"`
+		} `json:"cpu"`
+		Memory *struct {
+			WorkingSetBytes *uint64 `json:"workingSetBytes"`
+		} `json:"memory"`
+		Network *struct {
+			TxBytes *uint64 `json:"txBytes"`
+		} `json:"network"`
+	} `json:"pods"`
+}
+
+func (k kubeletStats) NodePodStats(ctx context.Context, node string) (map[string]PodStats, error) {
+	raw, err := k.k8s.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", node, "proxy", "stats", "summary").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("kubelet stats %s: %w", node, err)
+	}
+	var summary kubeletSummary
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		return nil, fmt.Errorf("kubelet stats %s: %w", node, err)
+	}
+	out := map[string]PodStats{}
+	for _, p := range summary.Pods {
+		if p.PodRef.Namespace != sandboxNS {
+			continue
+		}
+		var st PodStats
+		if p.CPU != nil && p.CPU.UsageCoreNanoSeconds != nil {
+			st.CPUNanoSeconds = int64(*p.CPU.UsageCoreNanoSeconds)
+		}
+		if p.Memory != nil && p.Memory.WorkingSetBytes != nil {
+			st.MemoryBytes = int64(*p.Memory.WorkingSetBytes)
+		}
+		if p.Network != nil && p.Network.TxBytes != nil {
+			st.TxBytes = int64(*p.Network.TxBytes)
+		}
+		out[p.PodRef.Name] = st
+	}
+	return out, nil
+}
+
+// SetPodStatsSource enables CPU, memory and egress metering.
+func (o *Orchestrator) SetPodStatsSource(src PodStatsSource) {
+	o.podStats = src
+}
+
+// SetUsageLedger makes session termination record usage in the cluster
+// ledger and append it to l.
+func (o *Orchestrator) SetUsageLedger(l *usage.Ledger) {
+	o.usageLedger = l
+	o.usageRecords = usage.NewClusterLedger(o.k8s, sandboxNS)
+}
+
+// recordExec counts runs execs of d total wall time against a session.
+func (o *Orchestrator) recordExec(sessionID string, runs int64, d time.Duration) {
+	o.addPendingUsage(sessionID, func(u *sandboxv1.SessionUsage) {
+		u.ExecCount += runs
+		u.ExecMilliSeconds += d.Milliseconds()
+	})
+}
+
+// recordSnapshotBytes counts a snapshot archive against a session.
+func (o *Orchestrator) recordSnapshotBytes(sessionID string, n int64) {
+	o.addPendingUsage(sessionID, func(u *sandboxv1.SessionUsage) {
+		u.SnapshotBytes += n
+	})
+}
+
+func (o *Orchestrator) addPendingUsage(sessionID string, fn func(*sandboxv1.SessionUsage)) {
+	if sessionID == "" {
+		return
+	}
+	o.usageMu.Lock()
+	defer o.usageMu.Unlock()
+	u := o.pendingUsage[sessionID]
+	if u == nil {
+		u = &sandboxv1.SessionUsage{}
+		o.pendingUsage[sessionID] = u
+	}
+	fn(u)
+}
+
+// RunUsageFlush folds this replica's exec and snapshot counts into the
+// sessions' status until ctx is done.
+func (o *Orchestrator) RunUsageFlush(ctx context.Context) {
+	ticker := time.NewTicker(usageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.flushUsage(ctx, "")
+		}
+	}
+}
+
+// flushUsage writes pending counts for one session, or for all when
+// sessionID is empty. Counts for sessions that no longer exist are dropped;
+// other failures are kept for the next flush.
+func (o *Orchestrator) flushUsage(ctx context.Context, sessionID string) {
+	o.usageMu.Lock()
+	pending := o.pendingUsage
+	if sessionID == "" {
+		o.pendingUsage = map[string]*sandboxv1.SessionUsage{}
+	} else {
+		pending = map[string]*sandboxv1.SessionUsage{}
+		if u := o.pendingUsage[sessionID]; u != nil {
+			pending[sessionID] = u
+			delete(o.pendingUsage, sessionID)
+		}
+	}
+	o.usageMu.Unlock()
+
+	for id, add := range pending {
+		_, err := o.mutateUsage(ctx, id, func(u *sandboxv1.SessionUsage) {
+			u.ExecCount += add.ExecCount
+			u.ExecMilliSeconds += add.ExecMilliSeconds
+			u.SnapshotBytes += add.SnapshotBytes
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			logrus.Debugf("sandbox usage: flush %s: %v", id, err)
+			o.addPendingUsage(id, func(u *sandboxv1.SessionUsage) {
+				u.ExecCount += add.ExecCount
+				u.ExecMilliSeconds += add.ExecMilliSeconds
+				u.SnapshotBytes += add.SnapshotBytes
+			})
+		}
+	}
+}
+
+// mutateUsage applies fn to the session's status.usage, re-reading on
+// conflicts so concurrent writers never lose each other's increments.
+func (o *Orchestrator) mutateUsage(ctx context.Context, sessionID string, fn func(*sandboxv1.SessionUsage)) (*sandboxv1.SandboxSession, error) {
+	return o.updateSessionStatus(ctx, sessionID, func(st *sandboxv1.SandboxSessionStatus) {
+		if st.Usage == nil {
+			st.Usage = &sandboxv1.SessionUsage{}
+		}
+		fn(st.Usage)
+	})
+}
+
+// SampleUsage reads the kubelet counters of every session pod, one summary
+// per node, and accrues them into the sessions' usage. The leader runs it.
+func (o *Orchestrator) SampleUsage(ctx context.Context) {
+	if o.podStats == nil {
+		return
+	}
+	sessions, err := o.listSessions(ctx, sandboxNS, "all")
+	if err != nil {
+		logrus.Debugf("sandbox usage: list sessions: %v", err)
+		return
+	}
+	pods, err := o.k8s.CoreV1().Pods(sandboxNS).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("sandbox usage: list pods: %v", err)
+		return
+	}
+	nodeOf := map[string]string{}
+	for i := range pods.Items {
+		nodeOf[pods.Items[i].Name] = pods.Items[i].Spec.NodeName
+	}
+	stats := map[string]map[string]PodStats{}
+	now := time.Now()
+	for _, s := range sessions {
+		// Sub-agents share their parent's pod and have no PodName of their
+		// own, so pod usage is metered once, on the parent.
+		node := nodeOf[s.Status.PodName]
+		if s.Status.PodName == "" || node == "" || s.Status.Phase == sandboxv1.SandboxPhaseTerminating {
+			continue
+		}
+		if _, ok := stats[node]; !ok {
+			st, err := o.podStats.NodePodStats(ctx, node)
+			if err != nil {
+				logrus.Debugf("sandbox usage: %v", err)
+			}
+			stats[node] = st
+		}
+		st, ok := stats[node][s.Status.PodName]
+		if !ok {
+			continue
+		}
+		pod := s.Status.PodName
+		if _, err := o.mutateUsage(ctx, s.Name, func(u *sandboxv1.SessionUsage) { accrueUsage(u, pod, st, now) }); err != nil && !apierrors.IsNotFound(err) {
+			logrus.Debugf("sandbox usage: sample %s: %v", s.Name, err)
+		}
+	}
+}
+
+// sampleSession samples one session's pod (baseline at creation, final
+// reading at termination).
+func (o *Orchestrator) sampleSession(ctx context.Context, sessionID string) {
+	if o.podStats == nil {
+		return
+	}
+	s, err := o.getSession(ctx, sessionID)
+	if err != nil || s.Status.PodName == "" {
+		return
+	}
+	pod, err := o.k8s.CoreV1().Pods(sandboxNS).Get(ctx, s.Status.PodName, metav1.GetOptions{})
+	if err != nil || pod.Spec.NodeName == "" {
+		return
+	}
+	stats, err := o.podStats.NodePodStats(ctx, pod.Spec.NodeName)
+	if err != nil {
+		logrus.Debugf("sandbox usage: %v", err)
+		return
+	}
+	st, ok := stats[pod.Name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	o.mutateUsage(ctx, sessionID, func(u *sandboxv1.SessionUsage) { accrueUsage(u, pod.Name, st, now) }) //nolint:errcheck
+}
+
+// accrueUsage adds the counter growth since the previous reading of the same
+// pod. The first reading of a pod (a claimed warm pod carries its earlier
+// sessions' counters) only sets the base; a counter that went backwards was
+// reset by a container restart and counts from zero.
+func accrueUsage(u *sandboxv1.SessionUsage, pod string, st PodStats, now time.Time) {
+	if u.LastPod == pod {
+		u.CPUNanoSeconds += counterDelta(u.LastCPUNanoSeconds, st.CPUNanoSeconds)
+		u.EgressBytes += counterDelta(u.LastTxBytes, st.TxBytes)
+	}
+	u.LastPod, u.LastCPUNanoSeconds, u.LastTxBytes = pod, st.CPUNanoSeconds, st.TxBytes
+	u.MemoryPeakBytes = max(u.MemoryPeakBytes, st.MemoryBytes)
+	u.MemoryAvgBytes = (u.MemoryAvgBytes*u.MemorySamples + st.MemoryBytes) / (u.MemorySamples + 1)
+	u.MemorySamples++
+	u.SampledAt = &metav1.Time{Time: now}
+}
+
+func counterDelta(last, cur int64) int64 {
+	if cur < last {
+		return cur
+	}
+	return cur - last
+}
+
+// finishUsage takes a last sample, flushes this replica's counts and records
+// the session in the usage ledgers.
+func (o *Orchestrator) finishUsage(ctx context.Context, sessionID, reason string) {
+	o.sampleSession(ctx, sessionID)
+	o.flushUsage(ctx, sessionID)
+	if o.usageLedger == nil {
+		return
+	}
+	s, err := o.getSession(ctx, sessionID)
+	if err != nil {
+		return
+	}
+	r := usageRecord(s, time.Now())
+	r.Reason = reason
+	if err := o.usageRecords.Write(ctx, r); err != nil {
+		logrus.Warnf("sandbox usage: cluster ledger %s: %v", sessionID, err)
+	}
+	if err := o.usageLedger.Write(r); err != nil {
+		logrus.Warnf("sandbox usage: ledger %s: %v", sessionID, err)
+	}
+}
+
+// usageRecord converts a session's usage as of now.
+func usageRecord(s *sandboxv1.SandboxSession, now time.Time) usage.Record {
+	r := usage.Record{
+		Time:         now,
+		Session:      s.Name,
+		Tenant:       sessionTenant(s),
+		RuntimeClass: s.Spec.RuntimeClass,
+		Template:     s.Spec.Template,
+	}
+	if s.Status.CreatedAt != nil {
+		r.StartedAt = s.Status.CreatedAt.Time
+		r.DurationMS = now.Sub(r.StartedAt).Milliseconds()
+	}
+	if u := s.Status.Usage; u != nil {
+		r.CPUSeconds = float64(u.CPUNanoSeconds) / 1e9
+		r.MemoryPeakBytes = u.MemoryPeakBytes
+		r.MemoryAvgBytes = u.MemoryAvgBytes
+		r.EgressBytes = u.EgressBytes
+		r.ExecCount = u.ExecCount
+		r.ExecMS = u.ExecMilliSeconds
+		r.SnapshotBytes = u.SnapshotBytes
+	}
+	return r
+}
+
+// SampleUsage samples session pod usage. The controller leader calls it
+// periodically.
+func (s *Server) SampleUsage(ctx context.Context) {
+	s.orch.SampleUsage(ctx)
+}
+
+// UsageLedger returns the usage ledger, nil when it is disabled.
+func (s *Server) UsageLedger() *usage.Ledger {
+	return s.usageLedger
+}
+
+// QueryUsage reads the cluster usage ledger and, on request, the live usage
+// of sessions that have not ended.
+func (s *Server) QueryUsage(ctx context.Context, req *pb.QueryUsageRequest) (*pb.QueryUsageResponse, error) {
+	if s.orch.usageRecords == nil {
+		return nil, status.Error(codes.FailedPrecondition, "usage ledger is not enabled on this gateway")
+	}
+	// Limit bounds only the listed sessions: the tenant totals cover every
+	// matching record.
+	f := usage.Filter{Tenant: req.TenantId, Session: req.SessionId}
+	if req.Since > 0 {
+		f.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		f.Until = time.Unix(req.Until, 0)
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultUsageQueryLimit
+	}
+	records, err := s.orch.usageRecords.Query(ctx, f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "read usage ledger: %v", err)
+	}
+	resp := &pb.QueryUsageResponse{}
+	for _, r := range records[max(0, len(records)-limit):] {
+		resp.Sessions = append(resp.Sessions, usageRecordToPB(r, false))
+	}
+	if req.IncludeActive {
+		sessions, err := s.orch.listSessions(ctx, sandboxNS, "all")
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list sessions: %v", err)
+		}
+		now := time.Now()
+		for _, sess := range sessions {
+			r := usageRecord(sess, now)
+			if (req.TenantId != "" && r.Tenant != req.TenantId) || (req.SessionId != "" && r.Session != req.SessionId) {
+				continue
+			}
+			records = append(records, r)
+			resp.Sessions = append(resp.Sessions, usageRecordToPB(r, true))
+		}
+	}
+	for _, t := range usage.Summarize(records) {
+		resp.Tenants = append(resp.Tenants, &pb.TenantUsage{
+			TenantId:        t.Tenant,
+			Sessions:        int32(t.Sessions),
+			DurationMs:      t.DurationMS,
+			CpuSeconds:      t.CPUSeconds,
+			MemoryPeakBytes: t.MemoryPeakBytes,
+			EgressBytes:     t.EgressBytes,
+			ExecCount:       t.ExecCount,
+			ExecMs:          t.ExecMS,
+			SnapshotBytes:   t.SnapshotBytes,
+		})
+	}
+	return resp, nil
+}
+
+func usageRecordToPB(r usage.Record, active bool) *pb.SessionUsage {
+	out := &pb.SessionUsage{
+		SessionId:       r.Session,
+		TenantId:        r.Tenant,
+		Active:          active,
+		Reason:          r.Reason,
+		RuntimeClass:    r.RuntimeClass,
+		Template:        r.Template,
+		DurationMs:      r.DurationMS,
+		CpuSeconds:      r.CPUSeconds,
+		MemoryPeakBytes: r.MemoryPeakBytes,
+		MemoryAvgBytes:  r.MemoryAvgBytes,
+		EgressBytes:     r.EgressBytes,
+		ExecCount:       r.ExecCount,
+		ExecMs:          r.ExecMS,
+		SnapshotBytes:   r.SnapshotBytes,
+	}
+	if !r.StartedAt.IsZero() {
+		out.StartedAt = r.StartedAt.Unix()
+	}
+	if !active {
+		out.EndedAt = r.Time.Unix()
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/usage"
+)
+
+// fakePodStats serves fixed counters for every node.
+type fakePodStats struct {
+	mu    sync.Mutex
+	stats map[string]PodStats
+}
+
+func (f *fakePodStats) set(pod string, st PodStats) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats[pod] = st
+}
+
+func (f *fakePodStats) NodePodStats(ctx context.Context, node string) (map[string]PodStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]PodStats, len(f.stats))
+	for k, v := range f.stats {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func TestAccrueUsage_CountsDeltasPerPod(t *testing.T) {
+	u := &sandboxv1.SessionUsage{}
+	now := time.Now()
+	// A claimed warm pod already carries counters: the first reading is the base.
+	accrueUsage(u, "pod-a", PodStats{CPUNanoSeconds: 5e9, MemoryBytes: 100, TxBytes: 1000}, now)
+	if u.CPUNanoSeconds != 0 || u.EgressBytes != 0 || u.MemoryPeakBytes != 100 {
+		t.Fatalf("base reading charged: %+v", u)
+	}
+	accrueUsage(u, "pod-a", PodStats{CPUNanoSeconds: 7e9, MemoryBytes: 300, TxBytes: 1500}, now)
+	if u.CPUNanoSeconds != 2e9 || u.EgressBytes != 500 || u.MemoryPeakBytes != 300 || u.MemoryAvgBytes != 200 {
+		t.Fatalf("after growth: %+v", u)
+	}
+	// Container restart resets the counters.
+	accrueUsage(u, "pod-a", PodStats{CPUNanoSeconds: 1e9, MemoryBytes: 200, TxBytes: 100}, now)
+	if u.CPUNanoSeconds != 3e9 || u.EgressBytes != 600 {
+		t.Fatalf("after reset: %+v", u)
+	}
+	// A new pod (resume after a filesystem pause) starts a new base.
+	accrueUsage(u, "pod-b", PodStats{CPUNanoSeconds: 9e9, MemoryBytes: 50, TxBytes: 9000}, now)
+	if u.CPUNanoSeconds != 3e9 || u.EgressBytes != 600 || u.LastPod != "pod-b" || u.MemorySamples != 4 {
+		t.Fatalf("after pod change: %+v", u)
+	}
+}
+
+func TestUsage_MetersSessionIntoLedger(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	if _, err := s.QueryUsage(ctx, &pb.QueryUsageRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("want FailedPrecondition without a ledger, got %v", err)
+	}
+	dir := t.TempDir()
+	l, err := usage.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s.usageLedger = l
+	s.orch.SetUsageLedger(l)
+
+	mustCreateSession(t, s.orch, "meter-1")
+	stubSessionPodIP(ctx, t, s.orch, "meter-1", "127.0.0.1")
+	s.k8s.CoreV1().Pods(sandboxNS).Create(ctx, &corev1.Pod{ //nolint:errcheck
+		ObjectMeta: metav1.ObjectMeta{Name: "meter-pod", Namespace: sandboxNS},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+	}, metav1.CreateOptions{})
+	u, err := s.orch.dynamic.Resource(sessionGVR).Namespace(sandboxNS).Get(ctx, "meter-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Object["status"].(map[string]interface{})["podName"] = "meter-pod"
+	if _, err := s.orch.dynamic.Resource(sessionGVR).Namespace(sandboxNS).UpdateStatus(ctx, u, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &fakePodStats{stats: map[string]PodStats{}}
+	s.orch.SetPodStatsSource(stats)
+	stats.set("meter-pod", PodStats{CPUNanoSeconds: 1e9, MemoryBytes: 100, TxBytes: 1000})
+	s.SampleUsage(ctx)
+	stats.set("meter-pod", PodStats{CPUNanoSeconds: 3e9, MemoryBytes: 300, TxBytes: 5000})
+	s.SampleUsage(ctx)
+
+	fakeSandboxd(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"stdout":"ok","exit_code":0,"duration_ms":1500}`)) //nolint:errcheck
+	}))
+	if _, err := s.Exec(ctx, &pb.ExecRequest{SessionId: "meter-1", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	s.orch.recordSnapshotBytes("meter-1", 4096)
+	s.orch.flushUsage(ctx, "")
+
+	resp, err := s.QueryUsage(ctx, &pb.QueryUsageRequest{IncludeActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Sessions) != 1 {
+		t.Fatalf("sessions %+v", resp.Sessions)
+	}
+	live := resp.Sessions[0]
+	if !live.Active || live.CpuSeconds != 2 || live.EgressBytes != 4000 || live.ExecCount != 1 || live.ExecMs != 1500 || live.SnapshotBytes != 4096 {
+		t.Fatalf("live usage %+v", live)
+	}
+
+	// Counts still pending on this replica and the final sample land in the ledger.
+	s.orch.recordExec("meter-1", 1, 500*time.Millisecond)
+	stats.set("meter-pod", PodStats{CPUNanoSeconds: 4e9, MemoryBytes: 200, TxBytes: 6000})
+	if err := s.orch.DestroySession(ctx, "meter-1"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = s.QueryUsage(ctx, &pb.QueryUsageRequest{SessionId: "meter-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Sessions) != 1 || len(resp.Tenants) != 1 {
+		t.Fatalf("ledger %+v", resp)
+	}
+	rec := resp.Sessions[0]
+	if rec.Active || rec.Reason != "destroyed" || rec.CpuSeconds != 3 || rec.EgressBytes != 5000 ||
+		rec.MemoryPeakBytes != 300 || rec.ExecCount != 2 || rec.ExecMs != 2000 || rec.SnapshotBytes != 4096 {
+		t.Fatalf("ledger record %+v", rec)
+	}
+	if tot := resp.Tenants[0]; tot.Sessions != 1 || tot.CpuSeconds != 3 || tot.ExecCount != 2 {
+		t.Fatalf("tenant totals %+v", tot)
+	}
+}
+
+func TestQueryUsage_LimitBoundsSessionsNotTotals(t *testing.T) {
+	s := newTestServer()
+	dir := t.TempDir()
+	l, err := usage.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s.usageLedger = l
+	s.orch.SetUsageLedger(l)
+	// Records written by another server reach this one's queries through
+	// the cluster ledger; none are in this server's archive.
+	other := usage.NewClusterLedger(s.k8s, sandboxNS)
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"u-1", "u-2", "u-3"} {
+		other.Write(context.Background(), usage.Record{Time: base.Add(time.Duration(i) * time.Minute), Session: id, Tenant: "team-a", CPUSeconds: 1}) //nolint:errcheck
+	}
+
+	resp, err := s.QueryUsage(context.Background(), &pb.QueryUsageRequest{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Sessions) != 1 || resp.Sessions[0].SessionId != "u-3" {
+		t.Fatalf("sessions %+v, want only the newest", resp.Sessions)
+	}
+	if len(resp.Tenants) != 1 || resp.Tenants[0].Sessions != 3 || resp.Tenants[0].CpuSeconds != 3 {
+		t.Fatalf("tenant totals %+v, want all three records", resp.Tenants)
+	}
+}
@@ -0,0 +1,426 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Session lifecycle watch. One SandboxSession informer per gateway feeds
+// every WatchSessions stream, so a client waiting on a phase change or a
+// background run holds one stream instead of a GetSession/PollRun loop.
+// Events with no CRD change behind them come from the gateway itself:
+// expiry warnings from a scan of the informer cache, run completions from
+// polling the runs this gateway registered, and expose/unexpose from the
+// in-memory exposure registry (seen only on the gateway that handled the
+// call).
+
+const (
+	// sessionExpiryWarning is how long before expiresAt EXPIRING is sent.
+	sessionExpiryWarning = 5 * time.Minute
+	// sessionWatchInterval paces the expiry scan and run polling.
+	sessionWatchInterval = 5 * time.Second
+	// sessionWatchBuffer is how far a subscriber may fall behind before its
+	// stream is closed.
+	sessionWatchBuffer = 256
+)
+
+type sessionWatcher struct {
+	req *pb.WatchSessionsRequest
+	ch  chan *pb.SessionEvent
+}
+
+// matchesSession reports whether the watcher wants events about a session.
+func (w *sessionWatcher) matchesSession(sessionID, tenant string) bool {
+	return (w.req.SessionId == "" || w.req.SessionId == sessionID) &&
+		(w.req.TenantId == "" || w.req.TenantId == tenant)
+}
+
+func (w *sessionWatcher) matches(ev *pb.SessionEvent) bool {
+	if !w.matchesSession(ev.SessionId, ev.TenantId) {
+		return false
+	}
+	return len(w.req.Types) == 0 || slices.Contains(w.req.Types, ev.Type)
+}
+
+// sessionWatchHub fans session events out to WatchSessions subscribers.
+type sessionWatchHub struct {
+	mu    sync.Mutex
+	subs  map[*sessionWatcher]struct{}
+	store cache.Indexer // informer cache; nil until RunSessionWatch has synced
+
+	// warned maps a session to the expiresAt (unix seconds) already warned
+	// about, so an extended TTL is warned about again.
+	warned map[string]int64
+	// runs tracks background runs: true while a completion is still owed,
+	// false once sent (or when the run had already finished when first seen).
+	runs map[string]bool
+}
+
+func newSessionWatchHub() *sessionWatchHub {
+	return &sessionWatchHub{
+		subs:   make(map[*sessionWatcher]struct{}),
+		warned: make(map[string]int64),
+		runs:   make(map[string]bool),
+	}
+}
+
+// publish delivers ev to every matching subscriber. A subscriber whose
+// buffer is full is dropped; its stream ends and the client reconnects.
+func (h *sessionWatchHub) publish(ev *pb.SessionEvent) {
+	if ev.Time == 0 {
+		ev.Time = time.Now().UnixMilli()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.subs {
+		if !w.matches(ev) {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			delete(h.subs, w)
+			close(w.ch)
+		}
+	}
+}
+
+// subscribe registers a watcher and returns, when asked for, the current
+// phase of every matching session.
+func (h *sessionWatchHub) subscribe(req *pb.WatchSessionsRequest) (*sessionWatcher, []*pb.SessionEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.store == nil {
+		return nil, nil, status.Error(codes.Unavailable, "session watch is not running")
+	}
+	w := &sessionWatcher{req: req, ch: make(chan *pb.SessionEvent, sessionWatchBuffer)}
+	var initial []*pb.SessionEvent
+	if req.SendInitial {
+		for _, obj := range h.store.List() {
+			s := objToSession(obj)
+			if s == nil {
+				continue
+			}
+			if ev := sessionEvent(s, pb.SessionEventType_SESSION_EVENT_PHASE); w.matches(ev) {
+				initial = append(initial, ev)
+			}
+		}
+	}
+	h.subs[w] = struct{}{}
+	return w, initial, nil
+}
+
+func (h *sessionWatchHub) unsubscribe(w *sessionWatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[w]; ok {
+		delete(h.subs, w)
+		close(w.ch)
+	}
+}
+
+// wants reports whether any subscriber would receive events about a session.
+func (h *sessionWatchHub) wants(sessionID, tenant string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.subs {
+		if w.matchesSession(sessionID, tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// session returns the cached session, or nil.
+func (h *sessionWatchHub) session(name string) *sandboxv1.SandboxSession {
+	h.mu.Lock()
+	store := h.store
+	h.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+	obj, ok, err := store.GetByKey(sandboxNS + "/" + name)
+	if err != nil || !ok {
+		return nil
+	}
+	return objToSession(obj)
+}
+
+func (h *sessionWatchHub) onAdd(obj any, isInInitialList bool) {
+	if isInInitialList {
+		return
+	}
+	// Sessions are created with an empty status; the phase arrives as an
+	// update.
+	if s := objToSession(obj); s != nil && s.Status.Phase != "" {
+		h.publish(sessionEvent(s, pb.SessionEventType_SESSION_EVENT_PHASE))
+	}
+}
+
+func (h *sessionWatchHub) onUpdate(oldObj, newObj any) {
+	old, cur := objToSession(oldObj), objToSession(newObj)
+	if old == nil || cur == nil {
+		return
+	}
+	if old.Status.Phase != cur.Status.Phase {
+		ev := sessionEvent(cur, pb.SessionEventType_SESSION_EVENT_PHASE)
+		ev.PreviousPhase = string(old.Status.Phase)
+		h.publish(ev)
+	}
+	if !slices.Equal(old.Spec.AllowedHosts, cur.Spec.AllowedHosts) || !reflect.DeepEqual(old.Spec.EgressRules, cur.Spec.EgressRules) {
+		ev := sessionEvent(cur, pb.SessionEventType_SESSION_EVENT_ALLOWED_HOSTS)
+		ev.AllowedHosts = cur.Spec.AllowedHosts
+		ev.EgressRules = egressRulesToPB(cur.Spec.EgressRules)
+		h.publish(ev)
+	}
+}
+
+func (h *sessionWatchHub) onDelete(obj any) {
+	if tomb, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tomb.Obj
+	}
+	s := objToSession(obj)
+	if s == nil {
+		return
+	}
+	h.mu.Lock()
+	delete(h.warned, s.Name)
+	h.mu.Unlock()
+	h.publish(sessionEvent(s, pb.SessionEventType_SESSION_EVENT_DELETED))
+}
+
+// scanExpiry warns once about each session whose TTL runs out within
+// sessionExpiryWarning.
+func (h *sessionWatchHub) scanExpiry(now time.Time) {
+	h.mu.Lock()
+	store := h.store
+	h.mu.Unlock()
+	if store == nil {
+		return
+	}
+	var due []*pb.SessionEvent
+	for _, obj := range store.List() {
+		s := objToSession(obj)
+		if s == nil || s.Status.ExpiresAt == nil || s.Status.Phase == sandboxv1.SandboxPhaseTerminating {
+			continue
+		}
+		exp := s.Status.ExpiresAt.Time
+		if left := exp.Sub(now); left <= 0 || left > sessionExpiryWarning {
+			continue
+		}
+		h.mu.Lock()
+		seen := h.warned[s.Name] == exp.Unix()
+		h.warned[s.Name] = exp.Unix()
+		h.mu.Unlock()
+		if !seen {
+			ev := sessionEvent(s, pb.SessionEventType_SESSION_EVENT_EXPIRING)
+			ev.ExpiresAt = exp.Unix()
+			due = append(due, ev)
+		}
+	}
+	for _, ev := range due {
+		h.publish(ev)
+	}
+}
+
+// trackRun marks a newly submitted run as owing a completion event.
+func (h *sessionWatchHub) trackRun(runID string) {
+	h.mu.Lock()
+	h.runs[runID] = true
+	h.mu.Unlock()
+}
+
+// RunSessionWatch runs the SandboxSession informer behind WatchSessions,
+// plus the expiry scan and run polling, until ctx is done.
+func (o *Orchestrator) RunSessionWatch(ctx context.Context) {
+	inf := dynamicinformer.NewFilteredDynamicInformer(o.dynamic, sessionGVR, sandboxNS, 0, sessionIndexers(), nil).Informer()
+	if _, err := inf.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    o.watch.onAdd,
+		UpdateFunc: o.watch.onUpdate,
+		DeleteFunc: o.watch.onDelete,
+	}); err != nil {
+		logrus.Errorf("sandbox watch: %v", err)
+		return
+	}
+	// Keep the expose index in step with exposes made on other replicas.
+	if _, err := inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.onSessionExposed,
+		UpdateFunc: func(_, obj any) { o.onSessionExposed(obj) },
+		DeleteFunc: o.onSessionExposedDelete,
+	}); err != nil {
+		logrus.Errorf("sandbox watch: %v", err)
+		return
+	}
+	go inf.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), inf.HasSynced) {
+		return
+	}
+	o.watch.mu.Lock()
+	o.watch.store = inf.GetIndexer()
+	o.watch.mu.Unlock()
+
+	ticker := time.NewTicker(sessionWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			o.watch.scanExpiry(now)
+			o.pollRunCompletions(ctx)
+		}
+	}
+}
+
+// pollRunCompletions polls the background runs someone is watching and
+// sends RUN_COMPLETED for those that finished since they were last seen.
+func (o *Orchestrator) pollRunCompletions(ctx context.Context) {
+	o.mu.Lock()
+	registry := make(map[string]string, len(o.runRegistry))
+	for runID, sessionID := range o.runRegistry {
+		registry[runID] = sessionID
+	}
+	o.mu.Unlock()
+
+	h := o.watch
+	h.mu.Lock()
+	for runID := range h.runs {
+		if _, ok := registry[runID]; !ok {
+			delete(h.runs, runID)
+		}
+	}
+	h.mu.Unlock()
+
+	for runID, sessionID := range registry {
+		h.mu.Lock()
+		owed, known := h.runs[runID]
+		h.mu.Unlock()
+		if known && !owed {
+			continue
+		}
+		s := h.session(sessionID)
+		if s == nil || !h.wants(sessionID, sessionTenant(s)) {
+			continue
+		}
+		pctx, cancel := context.WithTimeout(ctx, sessionWatchInterval)
+		res, err := o.PollRun(pctx, runID)
+		cancel()
+		if err != nil {
+			continue
+		}
+		if res.Status == execStatusStarted || res.Status == execStatusRunning {
+			h.trackRun(runID)
+			continue
+		}
+		h.mu.Lock()
+		h.runs[runID] = false
+		h.mu.Unlock()
+		// A run that had already finished when first seen predates the
+		// watch; only runs seen in flight are reported. not_found runs
+		// (sandbox gone) are dropped without an event.
+		switch res.Status {
+		case execStatusCompleted, execStatusFailed, execStatusTimedOut:
+		default:
+			continue
+		}
+		if !known {
+			continue
+		}
+		ev := sessionEvent(s, pb.SessionEventType_SESSION_EVENT_RUN_COMPLETED)
+		ev.RunId = runID
+		ev.RunStatus = res.Status
+		ev.ExitCode = res.ExitCode
+		h.publish(ev)
+	}
+}
+
+// WatchSessions streams session events matching req until the client goes
+// away or falls too far behind.
+func (o *Orchestrator) WatchSessions(req *pb.WatchSessionsRequest, stream pb.SandboxService_WatchSessionsServer) error {
+	w, initial, err := o.watch.subscribe(req)
+	if err != nil {
+		return err
+	}
+	defer o.watch.unsubscribe(w)
+	for _, ev := range initial {
+		if err := stream.Send(ev); err != nil {
+			return err
+		}
+	}
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "session watch fell behind; reconnect")
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sessionEvent starts an event about s.
+func sessionEvent(s *sandboxv1.SandboxSession, t pb.SessionEventType) *pb.SessionEvent {
+	return &pb.SessionEvent{
+		Type:      t,
+		SessionId: s.Name,
+		TenantId:  sessionTenant(s),
+		Time:      time.Now().UnixMilli(),
+		Phase:     string(s.Status.Phase),
+	}
+}
+
+// sessionPodIPIndex indexes cached sessions by status.podIP, so the egress
+// proxy resolves a source address without converting every session.
+const sessionPodIPIndex = "status.podIP"
+
+func sessionIndexers() cache.Indexers {
+	return cache.Indexers{sessionPodIPIndex: sessionPodIP}
+}
+
+func sessionPodIP(obj any) ([]string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
+	ip, _, _ := unstructured.NestedString(u.Object, "status", "podIP")
+	if ip == "" {
+		return nil, nil
+	}
+	return []string{ip}, nil
+}
+
+// newSessionIndexer returns an empty session cache with the informer's
+// indexes.
+func newSessionIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, sessionIndexers())
+}
+
+func objToSession(obj any) *sandboxv1.SandboxSession {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	s, err := unstructuredToSession(u)
+	if err != nil {
+		return nil
+	}
+	return s
+}
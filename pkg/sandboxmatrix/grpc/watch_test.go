@@ -0,0 +1,186 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// sessionStream is a WatchSessions server stream that hands events to the test.
+type sessionStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *pb.SessionEvent
+}
+
+func (s *sessionStream) Context() context.Context { return s.ctx }
+func (s *sessionStream) Send(ev *pb.SessionEvent) error {
+	s.sent <- ev
+	return nil
+}
+
+func nextSessionEvent(t *testing.T, s *sessionStream) *pb.SessionEvent {
+	t.Helper()
+	select {
+	case ev := <-s.sent:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no session event")
+		return nil
+	}
+}
+
+func TestWatchSessions_StreamsLifecycle(t *testing.T) {
+	o := newTestOrchestrator()
+	mustCreateSession(t, o, "watch-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.RunSessionWatch(ctx)
+	for deadline := time.Now().Add(5 * time.Second); o.watch.session("watch-1") == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("session watch did not sync")
+		}
+	}
+
+	stream := &sessionStream{ctx: ctx, sent: make(chan *pb.SessionEvent, 16)}
+	done := make(chan error, 1)
+	go func() {
+		done <- o.WatchSessions(&pb.WatchSessionsRequest{SessionId: "watch-1", SendInitial: true}, stream)
+	}()
+	if ev := nextSessionEvent(t, stream); ev.Type != pb.SessionEventType_SESSION_EVENT_PHASE || ev.Phase != "Active" || ev.PreviousPhase != "" {
+		t.Fatalf("initial event: %+v", ev)
+	}
+
+	mustCreateSession(t, o, "watch-other") // filtered out
+	if _, _, err := o.UpdateAllowedHosts(ctx, "watch-1", []string{"pypi.org"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextSessionEvent(t, stream); ev.Type != pb.SessionEventType_SESSION_EVENT_ALLOWED_HOSTS || len(ev.AllowedHosts) != 1 {
+		t.Fatalf("allowed hosts event: %+v", ev)
+	}
+	if err := o.DestroySession(ctx, "watch-1"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextSessionEvent(t, stream); ev.Type != pb.SessionEventType_SESSION_EVENT_PHASE || ev.Phase != "Terminating" || ev.PreviousPhase != "Active" {
+		t.Fatalf("phase event: %+v", ev)
+	}
+	for {
+		ev := nextSessionEvent(t, stream)
+		if ev.SessionId != "watch-1" {
+			t.Fatalf("event for another session: %+v", ev)
+		}
+		if ev.Type == pb.SessionEventType_SESSION_EVENT_DELETED {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("watch returned %v", err)
+	}
+}
+
+func TestWatchSessions_NotRunning(t *testing.T) {
+	o := newTestOrchestrator()
+	err := o.WatchSessions(&pb.WatchSessionsRequest{}, &sessionStream{ctx: context.Background()})
+	if err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Fatalf("expected Unavailable before the informer syncs, got %v", err)
+	}
+}
+
+func TestSessionWatchHub_WarnsBeforeExpiryOnce(t *testing.T) {
+	h := newSessionWatchHub()
+	h.store = newSessionIndexer()
+	now := time.Now()
+	put := func(expires time.Time) {
+		s := &sandboxv1.SandboxSession{
+			ObjectMeta: metav1.ObjectMeta{Name: "ttl-1", Namespace: sandboxNS, Annotations: map[string]string{tenantAnnotation: "team-a"}},
+			Status:     sandboxv1.SandboxSessionStatus{Phase: sandboxv1.SandboxPhaseActive, ExpiresAt: &metav1.Time{Time: expires}},
+		}
+		u, err := sessionToUnstructured(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.store.Add(u) //nolint:errcheck
+	}
+	w, _, err := h.subscribe(&pb.WatchSessionsRequest{TenantId: "team-a", Types: []pb.SessionEventType{pb.SessionEventType_SESSION_EVENT_EXPIRING}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put(now.Add(time.Hour))
+	h.scanExpiry(now)
+	put(now.Add(2 * time.Minute))
+	h.scanExpiry(now)
+	h.scanExpiry(now.Add(time.Second))
+	put(now.Add(4 * time.Minute)) // TTL extended, still inside the window
+	h.scanExpiry(now)
+
+	if len(w.ch) != 2 {
+		t.Fatalf("want one warning per expiresAt, got %d", len(w.ch))
+	}
+	if ev := <-w.ch; ev.ExpiresAt != now.Add(2*time.Minute).Unix() || ev.TenantId != "team-a" {
+		t.Fatalf("warning %+v", ev)
+	}
+}
+
+func TestPollRunCompletions_ReportsRunsSeenInFlight(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	mustCreateSession(t, o, "bg-watch")
+	stubSessionPodIP(ctx, t, o, "bg-watch", "127.0.0.1")
+	u, _ := o.getSession(ctx, "bg-watch")
+	obj, _ := sessionToUnstructured(u)
+	o.watch.store = newSessionIndexer()
+	o.watch.store.Add(obj) //nolint:errcheck
+
+	var finished atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		state := "running"
+		if id == "run-old" || finished.Load() {
+			state = "completed"
+		}
+		w.Write([]byte(`{"run_id":"` + id + `","status":"` + state + `","exit_code":3}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+	old := bgSandboxdClient
+	bgSandboxdClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+	defer func() { bgSandboxdClient = old }()
+
+	o.runRegistry["run-old"] = "bg-watch"
+	o.runRegistry["run-new"] = "bg-watch"
+	w, _, err := o.watch.subscribe(&pb.WatchSessionsRequest{SessionId: "bg-watch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.pollRunCompletions(ctx)
+	if len(w.ch) != 0 {
+		t.Fatalf("no run has finished while watched yet, got %d events", len(w.ch))
+	}
+	finished.Store(true)
+	o.pollRunCompletions(ctx)
+	o.pollRunCompletions(ctx)
+	if len(w.ch) != 1 {
+		t.Fatalf("want exactly one completion, got %d", len(w.ch))
+	}
+	ev := <-w.ch
+	if ev.Type != pb.SessionEventType_SESSION_EVENT_RUN_COMPLETED || ev.RunId != "run-new" || ev.RunStatus != "completed" || ev.ExitCode != 3 {
+		t.Fatalf("completion event %+v", ev)
+	}
+}
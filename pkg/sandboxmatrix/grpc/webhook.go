@@ -0,0 +1,382 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+// Outbound lifecycle webhooks. Each SandboxWebhook CRD subscribes a URL to
+// a set of event types; the gateway replica that observes an event POSTs
+// it to every matching webhook in the background, retrying with exponential
+// backoff. An event that exhausts its retries is appended to the webhook's
+// status.deadLetters so operators can see what was lost.
+
+var webhookGVR = schema.GroupVersionResource{Group: sandboxAPIGroup, Version: "v1alpha1", Resource: "sandboxwebhooks"}
+
+// Webhook event types (SandboxWebhook.spec.events).
+const (
+	WebhookSessionCreated    = "session.created"
+	WebhookSessionDestroyed  = "session.destroyed"
+	WebhookSessionExpired    = "session.expired"
+	WebhookRunCompleted      = "run.completed"
+	WebhookApprovalRequested = "approval.requested"
+)
+
+const (
+	defaultWebhookAttempts   = 5
+	defaultWebhookBackoff    = 1  // seconds
+	defaultWebhookMaxBackoff = 60 // seconds
+	// maxWebhookDeadLetters bounds status.deadLetters; older entries are
+	// dropped (failedDeliveries keeps counting).
+	maxWebhookDeadLetters = 20
+	// maxReportedRuns bounds the run ids remembered as already reported.
+	maxReportedRuns = 4096
+	// WebhookSignatureTolerance is how far X-K8E-Timestamp may be from the
+	// receiver's clock before it should reject the delivery as a replay.
+	WebhookSignatureTolerance = 5 * time.Minute
+)
+
+// webhookBackoffUnit scales the retry policy's seconds. Overridable in tests.
+var webhookBackoffUnit = time.Second
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookEvent is the JSON body POSTed to a SandboxWebhook URL.
+type WebhookEvent struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Time      time.Time      `json:"time"`
+	SessionID string         `json:"session_id,omitempty"`
+	TenantID  string         `json:"tenant_id,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// emitWebhook fans an event out to the matching webhooks. It returns at once;
+// listing and delivery happen in the background so no RPC waits on them.
+func (o *Orchestrator) emitWebhook(typ, sessionID, tenant string, data map[string]any) {
+	ev := &WebhookEvent{
+		ID:        newWebhookEventID(),
+		Type:      typ,
+		Time:      time.Now().UTC(),
+		SessionID: sessionID,
+		TenantID:  tenant,
+		Data:      data,
+	}
+	go o.dispatchWebhook(ev)
+}
+
+func (o *Orchestrator) dispatchWebhook(ev *WebhookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := o.dynamic.Resource(webhookGVR).Namespace(sandboxNS).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("sandbox webhooks: list: %v", err)
+		return
+	}
+	legacy := ev.Type == WebhookApprovalRequested && o.approvalWebhook.URL != ""
+	for i := range list.Items {
+		hook, err := unstructuredToWebhook(&list.Items[i])
+		if err != nil || !webhookMatches(hook, ev) {
+			continue
+		}
+		if hook.Spec.URL == o.approvalWebhook.URL {
+			// Subscribed both ways: deliver once.
+			legacy = false
+		}
+		go o.deliverWebhook(hook, ev)
+	}
+	if legacy {
+		w := o.approvalWebhook
+		go o.postWebhookRetrying("", w.URL, w.Secret, nil, ev)
+	}
+}
+
+func webhookMatches(hook *sandboxv1.SandboxWebhook, ev *WebhookEvent) bool {
+	if hook.Spec.Suspend || hook.Spec.URL == "" {
+		return false
+	}
+	if hook.Spec.TenantID != "" && hook.Spec.TenantID != ev.TenantID {
+		return false
+	}
+	return len(hook.Spec.Events) == 0 || slices.Contains(hook.Spec.Events, ev.Type)
+}
+
+// deliverWebhook delivers ev to hook.
+func (o *Orchestrator) deliverWebhook(hook *sandboxv1.SandboxWebhook, ev *WebhookEvent) {
+	secret, err := o.webhookSecret(hook)
+	if err != nil {
+		o.deadLetterWebhook(hook.Name, ev, 0, err)
+		return
+	}
+	o.postWebhookRetrying(hook.Name, hook.Spec.URL, secret, hook.Spec.Retry, ev)
+}
+
+// postWebhookRetrying POSTs ev until it is accepted or the retry policy
+// gives up, then dead-letters it under name ("" for the approval webhook
+// flag, which has no status to record it in). 4xx answers other than 408
+// and 429 are final.
+func (o *Orchestrator) postWebhookRetrying(name, url, secret string, policy *sandboxv1.WebhookRetryPolicy, ev *WebhookEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	attempts, backoff, maxBackoff := webhookRetry(policy)
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		var retry bool
+		retry, err = postWebhook(url, secret, ev, body)
+		if err == nil {
+			o.webhookDelivered.Add(1)
+			return
+		}
+		if !retry || attempt >= attempts {
+			break
+		}
+		o.webhookRetries.Add(1)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+	target := name
+	if target == "" {
+		target = url
+	}
+	logrus.Warnf("sandbox webhooks: %s: %s %s: %v", target, ev.Type, ev.ID, err)
+	o.deadLetterWebhook(name, ev, attempt, err)
+}
+
+// webhookRetry applies the retry policy defaults.
+func webhookRetry(p *sandboxv1.WebhookRetryPolicy) (attempts int, backoff, maxBackoff time.Duration) {
+	attempts, initial, max := defaultWebhookAttempts, defaultWebhookBackoff, defaultWebhookMaxBackoff
+	if p != nil {
+		if p.MaxAttempts > 0 {
+			attempts = p.MaxAttempts
+		}
+		if p.InitialBackoffSeconds > 0 {
+			initial = p.InitialBackoffSeconds
+		}
+		if p.MaxBackoffSeconds > 0 {
+			max = p.MaxBackoffSeconds
+		}
+	}
+	return attempts, time.Duration(initial) * webhookBackoffUnit, time.Duration(max) * webhookBackoffUnit
+}
+
+// postWebhook sends one attempt and reports whether a failure is worth
+// retrying.
+func postWebhook(url, secret string, ev *WebhookEvent, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-K8E-Event", ev.Type)
+	req.Header.Set("X-K8E-Delivery", ev.ID)
+	// Each attempt is stamped afresh so retries stay inside the receiver's
+	// tolerance window.
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-K8E-Timestamp", ts)
+	if secret != "" {
+		req.Header.Set("X-K8E-Signature", signWebhookBody(secret, ts, body))
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		final := resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+		return !final, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// signWebhookBody returns the X-K8E-Signature value for body sent at ts:
+// the HMAC covers ts + "." + body, so a captured delivery cannot be
+// replayed outside WebhookSignatureTolerance.
+func signWebhookBody(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSecret resolves spec.secretRef; no ref means unsigned deliveries.
+func (o *Orchestrator) webhookSecret(hook *sandboxv1.SandboxWebhook) (string, error) {
+	ref := hook.Spec.SecretRef
+	if ref == nil {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sec, err := o.k8s.CoreV1().Secrets(sandboxNS).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", ref.Name, err)
+	}
+	val, ok := sec.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return string(val), nil
+}
+
+// deadLetterWebhook records an undeliverable event in the webhook's status,
+// retrying on conflicts with other replicas' writes. An unnamed webhook is
+// only counted.
+func (o *Orchestrator) deadLetterWebhook(name string, ev *WebhookEvent, attempts int, cause error) {
+	o.webhookDeadLetters.Add(1)
+	if name == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	letter := sandboxv1.WebhookDeadLetter{
+		EventID:   ev.ID,
+		Type:      ev.Type,
+		SessionID: ev.SessionID,
+		Attempts:  attempts,
+		Error:     cause.Error(),
+		FailedAt:  metav1.Now(),
+	}
+	for try := 0; try < 3; try++ {
+		u, err := o.dynamic.Resource(webhookGVR).Namespace(sandboxNS).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			logrus.Debugf("sandbox webhooks: dead-letter %s: %v", name, err)
+			return
+		}
+		hook, err := unstructuredToWebhook(u)
+		if err != nil {
+			return
+		}
+		hook.Status.FailedDeliveries++
+		hook.Status.DeadLetters = append(hook.Status.DeadLetters, letter)
+		if n := len(hook.Status.DeadLetters); n > maxWebhookDeadLetters {
+			hook.Status.DeadLetters = hook.Status.DeadLetters[n-maxWebhookDeadLetters:]
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(hook)
+		if err != nil {
+			return
+		}
+		_, err = o.dynamic.Resource(webhookGVR).Namespace(sandboxNS).UpdateStatus(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+		if !apierrors.IsConflict(err) {
+			if err != nil {
+				logrus.Debugf("sandbox webhooks: dead-letter %s: %v", name, err)
+			}
+			return
+		}
+	}
+}
+
+// notifyRunCompleted emits run.completed and meters the run's wall time the
+// first time this gateway sees runID in a final state (PollRun may be called
+// repeatedly for it).
+func (o *Orchestrator) notifyRunCompleted(sessionID, runID string, r *pb.PollRunResponse) {
+	switch r.Status {
+	case "completed", "failed", "timed_out":
+	default:
+		return
+	}
+	if !o.webhookRuns.add(runID) {
+		return
+	}
+	o.recordExec(sessionID, 0, time.Duration(r.DurationMs)*time.Millisecond)
+	tenant := ""
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if s, err := o.getSession(ctx, sessionID); err == nil {
+		tenant = sessionTenant(s)
+	}
+	o.emitWebhook(WebhookRunCompleted, sessionID, tenant, map[string]any{
+		"run_id":      runID,
+		"status":      r.Status,
+		"exit_code":   r.ExitCode,
+		"duration_ms": r.DurationMs,
+	})
+}
+
+// sessionWebhookData is the data block of the session.* events.
+func sessionWebhookData(s *sandboxv1.SandboxSession) map[string]any {
+	data := map[string]any{
+		"phase":         string(s.Status.Phase),
+		"runtime_class": s.Spec.RuntimeClass,
+	}
+	if s.Spec.Template != "" {
+		data["template"] = s.Spec.Template
+	}
+	if s.Status.ExpiresAt != nil {
+		data["expires_at"] = s.Status.ExpiresAt.Unix()
+	}
+	if s.Spec.ParentSessionID != "" {
+		data["parent_session_id"] = s.Spec.ParentSessionID
+	}
+	return data
+}
+
+// WebhookMetrics returns delivered events, retried attempts and
+// dead-lettered events since start.
+func (o *Orchestrator) WebhookMetrics() (delivered, retries, deadLetters int64) {
+	return o.webhookDelivered.Load(), o.webhookRetries.Load(), o.webhookDeadLetters.Load()
+}
+
+func unstructuredToWebhook(u *unstructured.Unstructured) (*sandboxv1.SandboxWebhook, error) {
+	var w sandboxv1.SandboxWebhook
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// reportedRuns remembers the newest maxReportedRuns run ids, evicting the
+// oldest. An evicted run that is polled again is reported again; receivers
+// dedupe by data.run_id anyway.
+type reportedRuns struct {
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	order []string
+}
+
+// add records runID and reports whether it was new.
+func (r *reportedRuns) add(runID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[runID]; ok {
+		return false
+	}
+	if r.seen == nil {
+		r.seen = make(map[string]struct{})
+	}
+	if len(r.order) >= maxReportedRuns {
+		delete(r.seen, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.seen[runID] = struct{}{}
+	r.order = append(r.order, runID)
+	return true
+}
+
+func newWebhookEventID() string {
+	raw := make([]byte, 8)
+	rand.Read(raw) //nolint:errcheck
+	return "evt-" + hex.EncodeToString(raw)
+}
@@ -0,0 +1,223 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	sandboxv1 "github.com/xiaods/k8e/pkg/sandboxmatrix/api/v1alpha1"
+	pb "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc/pb/sandbox/v1"
+)
+
+func mustCreateWebhook(t *testing.T, o *Orchestrator, name string, spec sandboxv1.SandboxWebhookSpec) {
+	t.Helper()
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&sandboxv1.SandboxWebhook{
+		TypeMeta:   metav1.TypeMeta{APIVersion: sandboxAPIVersion, Kind: "SandboxWebhook"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sandboxNS},
+		Spec:       spec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.dynamic.Resource(webhookGVR).Namespace(sandboxNS).Create(context.Background(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type webhookDelivery struct {
+	event     string
+	timestamp string
+	signature string
+	body      WebhookEvent
+	raw       []byte
+}
+
+// webhookReceiver records deliveries; fail answers the first n requests with 503.
+func webhookReceiver(t *testing.T, fail int32) (*httptest.Server, chan webhookDelivery) {
+	t.Helper()
+	got := make(chan webhookDelivery, 16)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		d := webhookDelivery{event: r.Header.Get("X-K8E-Event"), timestamp: r.Header.Get("X-K8E-Timestamp"), signature: r.Header.Get("X-K8E-Signature"), raw: raw}
+		json.Unmarshal(raw, &d.body) //nolint:errcheck
+		got <- d
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func nextDelivery(t *testing.T, got chan webhookDelivery) webhookDelivery {
+	t.Helper()
+	select {
+	case d := <-got:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivery")
+		return webhookDelivery{}
+	}
+}
+
+func TestWebhook_DeliversSignedFilteredEvents(t *testing.T) {
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	o.k8s.CoreV1().Secrets(sandboxNS).Create(ctx, &corev1.Secret{ //nolint:errcheck
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-hook", Namespace: sandboxNS},
+		Data:       map[string][]byte{"hmac": []byte("s3cret")},
+	}, metav1.CreateOptions{})
+	srv, got := webhookReceiver(t, 0)
+	mustCreateWebhook(t, o, "ci", sandboxv1.SandboxWebhookSpec{
+		URL:       srv.URL,
+		SecretRef: &sandboxv1.WebhookSecretRef{Name: "ci-hook", Key: "hmac"},
+		Events:    []string{WebhookSessionCreated, WebhookSessionDestroyed},
+	})
+	other, otherGot := webhookReceiver(t, 0)
+	mustCreateWebhook(t, o, "other-tenant", sandboxv1.SandboxWebhookSpec{URL: other.URL, TenantID: "team-b"})
+	mustCreateWebhook(t, o, "suspended", sandboxv1.SandboxWebhookSpec{URL: other.URL, Suspend: true})
+
+	mustCreateSession(t, o, "hook-1")
+	d := nextDelivery(t, got)
+	if d.event != WebhookSessionCreated || d.body.SessionID != "hook-1" || d.body.ID == "" {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	if d.signature != signWebhookBody("s3cret", d.timestamp, d.raw) {
+		t.Fatalf("bad signature %q", d.signature)
+	}
+	if ts, err := strconv.ParseInt(d.timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > WebhookSignatureTolerance {
+		t.Fatalf("bad timestamp %q", d.timestamp)
+	}
+	if d.signature == signWebhookBody("s3cret", "0", d.raw) {
+		t.Fatal("signature does not cover the timestamp")
+	}
+	// Not subscribed to approvals.
+	if _, err := o.ConfirmAction(ctx, &pb.ConfirmActionRequest{SessionId: "hook-1", Action: "rm -rf /data"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.DestroySession(ctx, "hook-1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := nextDelivery(t, got); d.event != WebhookSessionDestroyed || d.body.Data["phase"] != "Terminating" {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	select {
+	case d := <-got:
+		t.Fatalf("filtered event delivered: %+v", d)
+	case d := <-otherGot:
+		t.Fatalf("event delivered to another tenant's or a suspended webhook: %+v", d)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebhook_RetriesThenDeadLetters(t *testing.T) {
+	old := webhookBackoffUnit
+	webhookBackoffUnit = time.Millisecond
+	defer func() { webhookBackoffUnit = old }()
+
+	o := newTestOrchestrator()
+	ctx := context.Background()
+	srv, got := webhookReceiver(t, 2)
+	mustCreateWebhook(t, o, "flaky", sandboxv1.SandboxWebhookSpec{URL: srv.URL, Retry: &sandboxv1.WebhookRetryPolicy{MaxAttempts: 3}})
+	dead, _ := webhookReceiver(t, 100)
+	mustCreateWebhook(t, o, "down", sandboxv1.SandboxWebhookSpec{URL: dead.URL, Retry: &sandboxv1.WebhookRetryPolicy{MaxAttempts: 2}})
+
+	o.emitWebhook(WebhookSessionExpired, "exp-1", "", nil)
+	if d := nextDelivery(t, got); d.body.Type != WebhookSessionExpired {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	var hook *sandboxv1.SandboxWebhook
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		u, err := o.dynamic.Resource(webhookGVR).Namespace(sandboxNS).Get(ctx, "down", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hook, _ = unstructuredToWebhook(u); len(hook.Status.DeadLetters) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event was not dead-lettered")
+		}
+	}
+	l := hook.Status.DeadLetters[0]
+	if hook.Status.FailedDeliveries != 1 || l.Attempts != 2 || l.SessionID != "exp-1" || l.Error != "HTTP 503" {
+		t.Fatalf("dead letter %+v (failed=%d)", l, hook.Status.FailedDeliveries)
+	}
+	// The receiver records a delivery before the gateway reads the answer.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		delivered, retries, deadLetters := o.WebhookMetrics()
+		if delivered == 1 && retries == 3 && deadLetters == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("metrics delivered=%d retries=%d dead=%d", delivered, retries, deadLetters)
+		}
+	}
+}
+
+func TestWebhookRetry_BacksOffExponentially(t *testing.T) {
+	attempts, backoff, maxBackoff := webhookRetry(nil)
+	if attempts != defaultWebhookAttempts || backoff != webhookBackoffUnit || maxBackoff != 60*webhookBackoffUnit {
+		t.Fatalf("defaults: %d %v %v", attempts, backoff, maxBackoff)
+	}
+	_, backoff, maxBackoff = webhookRetry(&sandboxv1.WebhookRetryPolicy{InitialBackoffSeconds: 4, MaxBackoffSeconds: 10})
+	var waits []time.Duration
+	for i := 0; i < 3; i++ {
+		waits = append(waits, backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+	if waits[0] != 4*webhookBackoffUnit || waits[1] != 8*webhookBackoffUnit || waits[2] != 10*webhookBackoffUnit {
+		t.Fatalf("waits %v", waits)
+	}
+}
+
+func TestNotifyRunCompleted_OncePerRun(t *testing.T) {
+	o := newTestOrchestrator()
+	srv, got := webhookReceiver(t, 0)
+	mustCreateWebhook(t, o, "runs", sandboxv1.SandboxWebhookSpec{URL: srv.URL, Events: []string{WebhookRunCompleted}})
+
+	o.notifyRunCompleted("s1", "run-1", &pb.PollRunResponse{Status: "running"})
+	o.notifyRunCompleted("s1", "run-1", &pb.PollRunResponse{Status: "failed", ExitCode: 2})
+	o.notifyRunCompleted("s1", "run-1", &pb.PollRunResponse{Status: "failed", ExitCode: 2})
+	d := nextDelivery(t, got)
+	if d.body.Data["run_id"] != "run-1" || d.body.Data["status"] != "failed" || d.body.Data["exit_code"] != float64(2) {
+		t.Fatalf("unexpected delivery %+v", d.body)
+	}
+	select {
+	case d := <-got:
+		t.Fatalf("run reported twice: %+v", d.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestReportedRuns_EvictsOldest(t *testing.T) {
+	var r reportedRuns
+	for i := 0; i < maxReportedRuns+1; i++ {
+		if !r.add(fmt.Sprintf("run-%d", i)) {
+			t.Fatalf("run-%d reported as seen", i)
+		}
+	}
+	if len(r.seen) != maxReportedRuns || len(r.order) != maxReportedRuns {
+		t.Fatalf("remembered %d/%d runs, want %d", len(r.seen), len(r.order), maxReportedRuns)
+	}
+	if r.add(fmt.Sprintf("run-%d", maxReportedRuns)) {
+		t.Fatal("newest run forgotten")
+	}
+	if !r.add("run-0") {
+		t.Fatal("oldest run not evicted")
+	}
+}
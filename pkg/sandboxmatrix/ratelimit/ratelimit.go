@@ -27,6 +27,10 @@ type Limiter struct {
 	mu     sync.Mutex
 	cache  map[string]*tokenBucket
 	config RateConfig
+
+	// OnReject, when set, is called with the tenant of every rejected RPC
+	// (for metrics). Set it before serving.
+	OnReject func(tenant string)
 }
 
 // RateConfig holds burst and rate for read and write operations.
@@ -188,6 +192,7 @@ func (l *Limiter) UnaryInterceptor(ctx context.Context, req any, info *grpc.Unar
 	tenant := extractTenant(ctx)
 	isWrite := isWriteRPC(info.FullMethod)
 	if !l.Allow(tenant, isWrite) {
+		l.rejected(tenant)
 		return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for tenant %s — slow down and retry", tenant)
 	}
 	return handler(ctx, req)
@@ -198,11 +203,18 @@ func (l *Limiter) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.St
 	tenant := extractTenant(ss.Context())
 	isWrite := isWriteRPC(info.FullMethod)
 	if !l.Allow(tenant, isWrite) {
+		l.rejected(tenant)
 		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for tenant %s — slow down and retry", tenant)
 	}
 	return handler(srv, ss)
 }
 
+func (l *Limiter) rejected(tenant string) {
+	if l.OnReject != nil {
+		l.OnReject(tenant)
+	}
+}
+
 // isWriteRPC returns true for mutating sandbox RPCs.
 func isWriteRPC(method string) bool {
 	switch method {
@@ -210,8 +222,11 @@ func isWriteRPC(method string) bool {
 		"/sandbox.v1.SandboxService/DestroySession",
 		"/sandbox.v1.SandboxService/Exec",
 		"/sandbox.v1.SandboxService/ExecStream",
+		"/sandbox.v1.SandboxService/ExecStreamV2",
+		"/sandbox.v1.SandboxService/ExecInteractive",
 		"/sandbox.v1.SandboxService/PipInstall",
 		"/sandbox.v1.SandboxService/RunSubAgent",
+		"/sandbox.v1.SandboxService/ForkSession",
 		"/sandbox.v1.SandboxService/WriteFile",
 		"/sandbox.v1.SandboxService/GetCACert",
 		"/sandbox.v1.SandboxService/ConfirmAction",
@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The cluster ledger keeps the same records in ConfigMaps, so every server
+// answers usage queries from the same data whichever server saw the session
+// end. Records are sharded by the UTC day the session ended: ConfigMap
+// sandbox-usage-<yyyymmdd>-<n> holds one key per session, and a shard that
+// would outgrow shardBytes spills into the next n.
+const (
+	// LedgerLabel marks the ledger ConfigMaps.
+	LedgerLabel = "sandbox.k8e.io/usage-ledger"
+	// DayLabel is a ledger ConfigMap's yyyymmdd.
+	DayLabel = "sandbox.k8e.io/usage-day"
+
+	defaultShardBytes = 512 << 10 // well under the 1 MiB object limit
+	maxShards         = 1000
+)
+
+// ClusterLedger reads and writes the ConfigMap ledger in one namespace.
+type ClusterLedger struct {
+	k8s        kubernetes.Interface
+	namespace  string
+	shardBytes int
+}
+
+// NewClusterLedger returns the ledger kept in namespace.
+func NewClusterLedger(k8s kubernetes.Interface, namespace string) *ClusterLedger {
+	return &ClusterLedger{k8s: k8s, namespace: namespace, shardBytes: defaultShardBytes}
+}
+
+// Write stores r under its session in the shard of r.Time's day; a zero
+// r.Time is set to now. Writing a session again replaces its record when it
+// lands in the same shard.
+func (l *ClusterLedger) Write(ctx context.Context, r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	day := r.Time.UTC().Format("20060102")
+	for shard := 0; shard < maxShards; shard++ {
+		full, err := l.writeShard(ctx, fmt.Sprintf("sandbox-usage-%s-%d", day, shard), day, r.Session, string(value))
+		if err != nil || !full {
+			return err
+		}
+	}
+	return fmt.Errorf("usage ledger: day %s has no room for %s", day, r.Session)
+}
+
+// writeShard puts key into the named shard, creating it when missing. It
+// reports full, without writing, when the shard has no room for value.
+// Concurrent writers re-read and retry.
+func (l *ClusterLedger) writeShard(ctx context.Context, name, day, key, value string) (full bool, err error) {
+	cms := l.k8s.CoreV1().ConfigMaps(l.namespace)
+	for {
+		cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = cms.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: l.namespace,
+					Labels: map[string]string{LedgerLabel: "true", DayLabel: day},
+				},
+				Data: map[string]string{key: value},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return false, err
+		}
+		if err != nil {
+			return false, err
+		}
+		size := 0
+		for k, v := range cm.Data {
+			if k != key {
+				size += len(k) + len(v)
+			}
+		}
+		if size > 0 && size+len(key)+len(value) > l.shardBytes {
+			return true, nil
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = value
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return false, err
+	}
+}
+
+// Query returns the matching records from every shard, oldest first.
+func (l *ClusterLedger) Query(ctx context.Context, f Filter) ([]Record, error) {
+	list, err := l.k8s.CoreV1().ConfigMaps(l.namespace).List(ctx, metav1.ListOptions{LabelSelector: LedgerLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for i := range list.Items {
+		cm := &list.Items[i]
+		// Checked explicitly: fake clients may not filter by label selector.
+		if cm.Labels[LedgerLabel] != "true" {
+			continue
+		}
+		for _, v := range cm.Data {
+			var r Record
+			if json.Unmarshal([]byte(v), &r) == nil && r.Session != "" && f.match(r) {
+				out = append(out, r)
+			}
+		}
+	}
+	return f.order(out), nil
+}
+
+// order sorts records oldest first and applies the limit.
+func (f Filter) order(out []Record) []Record {
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
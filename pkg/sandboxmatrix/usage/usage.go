@@ -0,0 +1,141 @@
+// Package usage keeps the sandbox gateway's usage ledger: one record per
+// terminated session with the CPU, memory, egress, exec and snapshot
+// figures accrued over its life, for tenant chargeback. Records go to the
+// cluster-wide ConfigMap ledger, which queries read, and to an NDJSON
+// archive on the server that recorded them.
+package usage
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/xiaods/k8e/pkg/sandboxmatrix/audit"
+)
+
+// Record is one terminated session's usage.
+type Record struct {
+	// Time is when the session ended.
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+	Tenant  string    `json:"tenant,omitempty"`
+	// Reason is "destroyed" or "expired".
+	Reason       string    `json:"reason"`
+	RuntimeClass string    `json:"runtime_class,omitempty"`
+	Template     string    `json:"template,omitempty"`
+	StartedAt    time.Time `json:"started_at,omitempty"`
+	// DurationMS is the session's wall-clock lifetime.
+	DurationMS      int64   `json:"duration_ms"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	MemoryPeakBytes int64   `json:"memory_peak_bytes"`
+	MemoryAvgBytes  int64   `json:"memory_avg_bytes"`
+	EgressBytes     int64   `json:"egress_bytes"`
+	ExecCount       int64   `json:"exec_count"`
+	ExecMS          int64   `json:"exec_ms"`
+	SnapshotBytes   int64   `json:"snapshot_bytes"`
+}
+
+// Ledger appends usage records. Files rotate like the audit log but are
+// never deleted: chargeback needs the full history.
+type Ledger struct {
+	log *audit.Log
+}
+
+// Open opens (or creates) the ledger in dir. The directory must hold only
+// usage files, since Query reads every NDJSON file in it.
+func Open(dir string) (*Ledger, error) {
+	l, err := audit.Open(audit.Options{Dir: dir, Prefix: "usage", Retention: -1})
+	if err != nil {
+		return nil, err
+	}
+	return &Ledger{log: l}, nil
+}
+
+// Write appends r; a zero r.Time is set to now.
+func (l *Ledger) Write(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	return l.log.Append(r)
+}
+
+// Close closes the active file.
+func (l *Ledger) Close() error {
+	return l.log.Close()
+}
+
+// Filter selects records in Query. Zero fields match everything.
+type Filter struct {
+	// Since and Until bound the session end time.
+	Since, Until time.Time
+	Tenant       string
+	Session      string
+	// Limit keeps only the newest Limit matches (zero → all).
+	Limit int
+}
+
+func (f Filter) match(r Record) bool {
+	switch {
+	case !f.Since.IsZero() && r.Time.Before(f.Since),
+		!f.Until.IsZero() && r.Time.After(f.Until),
+		f.Tenant != "" && r.Tenant != f.Tenant,
+		f.Session != "" && r.Session != f.Session:
+		return false
+	}
+	return true
+}
+
+// Query returns the matching records in dir, oldest first.
+func Query(dir string, f Filter) ([]Record, error) {
+	var out []Record
+	err := audit.ReadLines(dir, func(line []byte) {
+		var r Record
+		if json.Unmarshal(line, &r) == nil && r.Session != "" && f.match(r) {
+			out = append(out, r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f.order(out), nil
+}
+
+// Totals sums one tenant's records.
+type Totals struct {
+	Tenant     string
+	Sessions   int
+	DurationMS int64
+	CPUSeconds float64
+	// MemoryPeakBytes is the largest single-session peak.
+	MemoryPeakBytes int64
+	EgressBytes     int64
+	ExecCount       int64
+	ExecMS          int64
+	SnapshotBytes   int64
+}
+
+// Summarize totals records per tenant, sorted by tenant.
+func Summarize(records []Record) []Totals {
+	byTenant := map[string]*Totals{}
+	for _, r := range records {
+		t := byTenant[r.Tenant]
+		if t == nil {
+			t = &Totals{Tenant: r.Tenant}
+			byTenant[r.Tenant] = t
+		}
+		t.Sessions++
+		t.DurationMS += r.DurationMS
+		t.CPUSeconds += r.CPUSeconds
+		t.MemoryPeakBytes = max(t.MemoryPeakBytes, r.MemoryPeakBytes)
+		t.EgressBytes += r.EgressBytes
+		t.ExecCount += r.ExecCount
+		t.ExecMS += r.ExecMS
+		t.SnapshotBytes += r.SnapshotBytes
+	}
+	out := make([]Totals, 0, len(byTenant))
+	for _, t := range byTenant {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out
+}
@@ -0,0 +1,91 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLedger_QueryFiltersAndSummarizes(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for _, r := range []Record{
+		{Time: day.Add(time.Hour), Session: "a-1", Tenant: "team-a", CPUSeconds: 1.5, MemoryPeakBytes: 100, ExecCount: 2, EgressBytes: 10},
+		{Time: day.Add(2 * time.Hour), Session: "b-1", Tenant: "team-b", CPUSeconds: 4, ExecCount: 1},
+		{Time: day.Add(3 * time.Hour), Session: "a-2", Tenant: "team-a", CPUSeconds: 0.5, MemoryPeakBytes: 300, ExecCount: 3, EgressBytes: 5},
+		{Time: day.Add(-time.Hour), Session: "a-0", Tenant: "team-a", CPUSeconds: 9},
+	} {
+		if err := l.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Lines that are not usage records are ignored.
+	os.WriteFile(filepath.Join(dir, "usage-20260101T000000.000000000Z.ndjson"), []byte("{}\nnot json\n"), 0600) //nolint:errcheck
+
+	recs, err := Query(dir, Filter{Tenant: "team-a", Since: day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Session != "a-1" || recs[1].Session != "a-2" {
+		t.Fatalf("records %+v", recs)
+	}
+	if recs, _ := Query(dir, Filter{Limit: 1}); len(recs) != 1 || recs[0].Session != "a-2" {
+		t.Fatalf("limit keeps the newest: %+v", recs)
+	}
+
+	all, _ := Query(dir, Filter{Since: day})
+	totals := Summarize(all)
+	if len(totals) != 2 || totals[0].Tenant != "team-a" {
+		t.Fatalf("totals %+v", totals)
+	}
+	a := totals[0]
+	if a.Sessions != 2 || a.CPUSeconds != 2 || a.MemoryPeakBytes != 300 || a.ExecCount != 5 || a.EgressBytes != 15 {
+		t.Fatalf("team-a totals %+v", a)
+	}
+}
+
+func TestClusterLedger_ShardsByDayAndSize(t *testing.T) {
+	ctx := context.Background()
+	k8s := kubefake.NewSimpleClientset()
+	l := NewClusterLedger(k8s, "sandbox-matrix")
+	l.shardBytes = 600 // room for two records per shard
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a-1", "a-2", "a-3"} {
+		if err := l.Write(ctx, Record{Time: day.Add(time.Duration(i) * time.Hour), Session: id, Tenant: "team-a", CPUSeconds: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Write(ctx, Record{Time: day.Add(-time.Hour), Session: "a-0", Tenant: "team-a"}); err != nil {
+		t.Fatal(err)
+	}
+	// Rewriting a session replaces its record.
+	if err := l.Write(ctx, Record{Time: day, Session: "a-1", Tenant: "team-a", CPUSeconds: 5}); err != nil {
+		t.Fatal(err)
+	}
+	cms, _ := k8s.CoreV1().ConfigMaps("sandbox-matrix").List(ctx, metav1.ListOptions{})
+	names := map[string]int{}
+	for _, cm := range cms.Items {
+		names[cm.Name] = len(cm.Data)
+	}
+	if names["sandbox-usage-20261017-0"] != 2 || names["sandbox-usage-20261017-1"] != 1 || names["sandbox-usage-20261016-0"] != 1 {
+		t.Fatalf("shards %v", names)
+	}
+
+	recs, err := l.Query(ctx, Filter{Since: day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || recs[0].Session != "a-1" || recs[0].CPUSeconds != 5 || recs[2].Session != "a-3" {
+		t.Fatalf("records %+v", recs)
+	}
+}
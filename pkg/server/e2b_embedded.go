@@ -17,6 +17,7 @@ import (
 	"github.com/xiaods/k8e/pkg/daemons/config"
 	"github.com/xiaods/k8e/pkg/sandbox/client"
 	sandboxe2b "github.com/xiaods/k8e/pkg/sandbox/e2b"
+	sandboxgrpc "github.com/xiaods/k8e/pkg/sandboxmatrix/grpc"
 )
 
 const (
@@ -64,6 +65,13 @@ func runEmbeddedE2B(ctx context.Context, cfg config.SandboxConfig, kubeconfig st
 		logrus.Warnf("e2b (embedded): CRD state store unavailable (%v); using in-memory (single-node semantics)", err)
 	}
 
+	// The expose proxy authorizes from a session informer rather than a
+	// ListExposed RPC per request; without one it falls back to the RPC.
+	exposeIndex, err := newEmbeddedExposeIndex(ctx, kubeconfig, cfg.Namespace)
+	if err != nil {
+		logrus.Warnf("e2b (embedded): expose index unavailable (%v); expose proxy asks the gateway per request", err)
+	}
+
 	staticKey := resolveEmbeddedAPIKey(cfg.E2BAPIKey)
 	srv := sandboxe2b.NewServer(sandboxe2b.Config{
 		Listen:          cfg.E2BListen,
@@ -73,6 +81,12 @@ func runEmbeddedE2B(ctx context.Context, cfg config.SandboxConfig, kubeconfig st
 		DefaultMemoryMB: 512,
 		DefaultDiskMB:   10 * 1024,
 		StateStore:      store,
+		AuditDir:        cfg.AuditDir,
+		AuditRetention:  cfg.AuditRetention,
+		// Same process as the gateway: reuse its sandboxd keyring.
+		SandboxdTransport: sandboxgrpc.SandboxdTransport(),
+		ExposeIndex:       exposeIndex,
+		ExposeDomain:      cfg.ExposeDomain,
 	}, sandboxe2b.GatewayFromClient(c))
 
 	cache := &e2bAPIKeyCache{static: staticKey}
@@ -215,3 +229,17 @@ func newEmbeddedStateStore(kubeconfig, namespace string) (sandboxe2b.StateStore,
 	}
 	return sandboxe2b.NewCRDStateStore(dyn, namespace), nil
 }
+
+// newEmbeddedExposeIndex starts the SandboxSession informer behind the
+// expose proxy's authorization check.
+func newEmbeddedExposeIndex(ctx context.Context, kubeconfig, namespace string) (sandboxe2b.ExposeIndex, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return sandboxe2b.NewExposeIndex(ctx, dyn, namespace), nil
+}
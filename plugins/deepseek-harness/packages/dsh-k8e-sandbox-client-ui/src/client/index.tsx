@@ -41,6 +41,10 @@ const zh = {
   'exposed.error': '无法读取暴露列表（网关不可达或未配置）。',
   'exposed.refresh': '刷新',
   'exposed.stop': '停止',
+  'exposed.token': 'token',
+  'exposed.tokenHint': '需要 expose 时返回的带 token 的 URL；token 只签发一次，丢失后请 unexpose 再重新 expose。',
+  'exposed.signed': '签名',
+  'exposed.signedHint': '链接为刚签发的签名 URL，到期后刷新列表即可获得新链接。',
   'status.runtimeFromConfig': '（来自 dsh 配置）',
   'hint.hostConfig': 'endpoint 未显式配置时，插件会自动从 ~/.k8e/sandbox/profiles.yaml 发现（KIP-17）；endpoint/certDir 为部署级配置，在 dsh-k8e-sandbox 行里设置，网页只读。',
 } as const
@@ -73,6 +77,10 @@ const en: Record<keyof typeof zh, string> = {
   'exposed.error': 'Could not load exposures (gateway unreachable or not configured).',
   'exposed.refresh': 'Refresh',
   'exposed.stop': 'Stop',
+  'exposed.token': 'token',
+  'exposed.tokenHint': 'Needs the token-bearing URL returned at expose time; the token is issued once, so if it is lost, unexpose and expose again.',
+  'exposed.signed': 'signed',
+  'exposed.signedHint': 'The link is a freshly signed URL; refresh the list for a new one after it expires.',
   'status.runtimeFromConfig': '(from dsh config)',
   'hint.hostConfig': 'When endpoint is not configured explicitly, the plugin auto-discovers it from ~/.k8e/sandbox/profiles.yaml (KIP-17); endpoint/certDir are deployment-level config, set in the dsh-k8e-sandbox profile row and read-only on the web.',
 }
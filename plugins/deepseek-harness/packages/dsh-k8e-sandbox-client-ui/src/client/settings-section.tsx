@@ -82,15 +82,20 @@ export function K8eSettingsSection(props: K8eSettingsSectionProps): ReactNode {
   const [saved, setSaved] = useState(false)
   const [saveError, setSaveError] = useState(false)
   const [opening, setOpening] = useState(false)
-  const [exposed, setExposed] = useState<Array<{ port: number; url: string }>>([])
+  const [exposed, setExposed] = useState<Array<{ port: number; url: string; access: string; openUrl?: string }>>([])
   const [exposedErr, setExposedErr] = useState(false)
 
   const refreshExposed = async (): Promise<void> => {
     try {
       const res = await fetch('/k8e-sandbox/api/exposed', { method: 'POST' })
-      const body = (await res.json()) as { ok?: boolean; services?: Array<{ port: number; url: string }> }
+      const body = (await res.json()) as { ok?: boolean; services?: Array<{ port: number; url: string; access?: string; openUrl?: string }> }
       if (body.ok === true && Array.isArray(body.services)) {
-        setExposed(body.services.map((x) => ({ port: Number(x.port), url: String(x.url) })))
+        setExposed(body.services.map((x) => ({
+          port: Number(x.port),
+          url: String(x.url),
+          access: String(x.access ?? 'public'),
+          ...(typeof x.openUrl === 'string' ? { openUrl: x.openUrl } : {}),
+        })))
         setExposedErr(false)
       } else {
         setExposed([])
@@ -231,7 +236,12 @@ export function K8eSettingsSection(props: K8eSettingsSectionProps): ReactNode {
           : createElement('div', null,
             exposed.map((x) => createElement('div', { key: x.port, style: { display: 'flex', alignItems: 'center', gap: '8px', marginBottom: '6px' } },
               createElement('span', { style: { fontSize: '12px', color: '#4caf50' } }, ':' + x.port),
-              createElement('a', { href: x.url, target: '_blank', rel: 'noreferrer', style: { fontSize: '12px', flex: '1', overflowWrap: 'anywhere', color: 'var(--dsw-alias-accent, #2f6fed)' } }, x.url),
+              x.openUrl !== undefined
+                ? createElement('a', { href: x.openUrl, target: '_blank', rel: 'noreferrer', style: { fontSize: '12px', flex: '1', overflowWrap: 'anywhere', color: 'var(--dsw-alias-accent, #2f6fed)' } }, x.url)
+                : createElement('span', { style: { fontSize: '12px', flex: '1', overflowWrap: 'anywhere' } }, x.url),
+              x.access !== 'public'
+                ? createElement('span', { style: { fontSize: '11px', color: 'var(--dsw-alias-label-secondary, #999)' }, title: t(x.access === 'token' ? 'exposed.tokenHint' : 'exposed.signedHint') }, t(x.access === 'token' ? 'exposed.token' : 'exposed.signed'))
+                : null,
               createElement('button', {
                 type: 'button', style: { ...buttonStyle, padding: '2px 8px', fontSize: '11px' },
                 onClick: () => { void unexpose(x.port) },
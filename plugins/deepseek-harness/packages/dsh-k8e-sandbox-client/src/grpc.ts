@@ -19,7 +19,10 @@ import type {
   BackgroundResult,
   CreateSessionOptions,
   ExecResult,
+  ExposeAccessMode,
   ExposedServiceInfo,
+  ExposeOptions,
+  ExposeResult,
   FileEntry,
   PollResult,
   RunOptions,
@@ -29,7 +32,10 @@ export type {
   BackgroundResult,
   CreateSessionOptions,
   ExecResult,
+  ExposeAccessMode,
   ExposedServiceInfo,
+  ExposeOptions,
+  ExposeResult,
   FileEntry,
   PollResult,
   RunOptions,
@@ -222,6 +228,17 @@ function terminalSignalEnum(signal: TerminalSignal): number {
   }
 }
 
+// exposeAccessMode maps the ExposeAccess enum name (the loader decodes
+// enums as strings) to the plugin's form. The gateway reports ports exposed
+// before access control as public; it never lists UNSPECIFIED.
+function exposeAccessMode(access: unknown): ExposeAccessMode {
+  switch (access) {
+    case 'EXPOSE_ACCESS_TOKEN': return 'token'
+    case 'EXPOSE_ACCESS_SIGNED': return 'signed'
+  }
+  return 'public'
+}
+
 type UnaryMethod = (
   request: unknown,
   metadata: grpc.Metadata,
@@ -460,15 +477,24 @@ export class GrpcK8eClient {
 
   /**
    * Register an in-sandbox service port for k8e API Gateway proxying and
-   * return the public URL: http(s)://<gateway>/k8e/expose/<session>/<port>/.
+   * return its URL: http(s)://<gateway>/k8e/expose/<session>/<port>/, with
+   * the token or signature as query parameters unless access is public.
    */
-  async exposeService(sessionId: string, port: number, host?: string): Promise<{ url: string }> {
+  async exposeService(sessionId: string, port: number, opts: ExposeOptions = {}): Promise<ExposeResult> {
     const resp = await this.call<any>(this.client.exposeService, {
       sessionId,
       port,
-      ...(host !== undefined && host !== '' ? { host } : {}),
+      ...(opts.host !== undefined && opts.host !== '' ? { host: opts.host } : {}),
+      ...(opts.access !== undefined ? { access: 'EXPOSE_ACCESS_' + opts.access.toUpperCase() } : {}),
+      ...(opts.ttlSeconds !== undefined ? { ttlSeconds: String(opts.ttlSeconds) } : {}),
     }, 45_000)
-    return { url: resp.url as string }
+    const expiresAt = Number(resp.expiresAt ?? 0)
+    return {
+      url: resp.url as string,
+      access: exposeAccessMode(resp.access),
+      ...(resp.token ? { token: resp.token as string } : {}),
+      ...(expiresAt > 0 ? { expiresAt } : {}),
+    }
   }
 
   /** Remove the gateway proxy registration for a port. Idempotent. */
@@ -485,6 +511,7 @@ export class GrpcK8eClient {
       url: svc.url as string,
       host: svc.host as string,
       startedAt: Number(svc.startedAt ?? 0),
+      access: exposeAccessMode(svc.access),
     }))
   }
 
@@ -82,12 +82,40 @@ export interface RunOptions {
   tenant?: string
 }
 
-/** One live gateway-proxied exposure (KIP-24). */
+/**
+ * Who may reach an exposed URL (KIP-24): `token` (default) needs the token
+ * returned once by the expose call, `signed` a signed URL that expires,
+ * `public` nothing.
+ */
+export type ExposeAccessMode = 'public' | 'token' | 'signed'
+
+export interface ExposeOptions {
+  host?: string
+  access?: ExposeAccessMode
+  /** Signed URL lifetime in seconds (gateway default 3600). */
+  ttlSeconds?: number
+}
+
+/** Result of an expose call. `url` carries the credential, if any. */
+export interface ExposeResult {
+  url: string
+  access: ExposeAccessMode
+  /** Token access: only returned by the call that created the exposure. */
+  token?: string
+  /** Signed access: unix seconds the URL stops working. */
+  expiresAt?: number
+}
+
+/**
+ * One live gateway-proxied exposure (KIP-24). `url` is the bare URL: unless
+ * `access` is public it answers 401 without a credential.
+ */
 export interface ExposedServiceInfo {
   port: number
   url: string
   host: string
   startedAt: number
+  access: ExposeAccessMode
 }
 
 /**
@@ -106,7 +134,7 @@ export interface SandboxTransport {
   destroySession(sessionId: string): Promise<void>
   status(): Promise<StatusResult>
   // KIP-24: expose in-sandbox services through the k8e API Gateway.
-  exposeService(sessionId: string, port: number, host?: string): Promise<{ url: string }>
+  exposeService(sessionId: string, port: number, opts?: ExposeOptions): Promise<ExposeResult>
   unexposeService(sessionId: string, port: number): Promise<{ ok: boolean }>
   listExposed(sessionId: string): Promise<ExposedServiceInfo[]>
   updateAllowedHosts(sessionId: string, hosts: string[]): Promise<string[]>
@@ -341,10 +369,18 @@ export class CliK8eClient implements SandboxTransport {
 
   // ── KIP-24 service exposure (CLI-backed) ─────────────────────────────────
 
-  async exposeService(sessionId: string, port: number, host?: string): Promise<{ url: string }> {
+  async exposeService(sessionId: string, port: number, opts: ExposeOptions = {}): Promise<ExposeResult> {
     const args = ['expose', String(port), '--session-id', sessionId]
-    if (host !== undefined && host !== '') args.push('--host', host)
-    return parseJSON<{ url: string }>(await runCli(args, this.opts), 'expose')
+    if (opts.host !== undefined && opts.host !== '') args.push('--host', opts.host)
+    if (opts.access !== undefined) args.push('--access', opts.access)
+    if (opts.ttlSeconds !== undefined) args.push('--ttl', String(opts.ttlSeconds))
+    const out = parseJSON<{ url: string; access?: ExposeAccessMode; token?: string; expires_at?: number }>(await runCli(args, this.opts), 'expose')
+    return {
+      url: out.url,
+      access: out.access ?? 'public',
+      ...(out.token ? { token: out.token } : {}),
+      ...(out.expires_at ? { expiresAt: out.expires_at } : {}),
+    }
   }
 
   async unexposeService(sessionId: string, port: number): Promise<{ ok: boolean }> {
@@ -352,8 +388,15 @@ export class CliK8eClient implements SandboxTransport {
   }
 
   async listExposed(sessionId: string): Promise<ExposedServiceInfo[]> {
-    const out = parseJSON<{ services: ExposedServiceInfo[] }>(await runCli(['exposed', '--session-id', sessionId], this.opts), 'exposed')
-    return out.services ?? []
+    const out = parseJSON<{ services: Array<{ port: number; url: string; host: string; started_at: number; access?: ExposeAccessMode }> }>(
+      await runCli(['exposed', '--session-id', sessionId], this.opts), 'exposed')
+    return (out.services ?? []).map((svc) => ({
+      port: svc.port,
+      url: svc.url,
+      host: svc.host,
+      startedAt: svc.started_at,
+      access: svc.access ?? 'public',
+    }))
   }
 
   async updateAllowedHosts(sessionId: string, hosts: string[]): Promise<string[]> {
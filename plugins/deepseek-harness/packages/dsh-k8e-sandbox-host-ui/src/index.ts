@@ -345,12 +345,25 @@ export function apply(ctx: Context): void {
       if (method === 'exposed') {
         // KIP-24: list live exposures so the web terminal can manage them
         // without shelling out to k8e-sandbox-cli (which does not exist in
-        // the browser path).
+        // the browser path). Listed URLs are bare and only public ports
+        // open without a credential: signed ports get a freshly signed
+        // link (openUrl); a token port's token was handed out once, at
+        // expose time, so it has none.
         try {
           const grpc = ctx.k8eSandbox.getGrpcClient()
           const sessionId = await ctx.k8eSandbox.getSession()
           const list = await grpc.listExposed(sessionId)
-          writeJson(res, 200, { ok: true, sessionId, services: list })
+          const services = await Promise.all(list.map(async (svc) => {
+            if (svc.access === 'public') return { ...svc, openUrl: svc.url }
+            if (svc.access !== 'signed') return svc
+            try {
+              const signed = await grpc.exposeService(sessionId, svc.port, { access: 'signed' })
+              return { ...svc, openUrl: signed.url, expiresAt: signed.expiresAt }
+            } catch {
+              return svc
+            }
+          }))
+          writeJson(res, 200, { ok: true, sessionId, services })
           return
         } catch (error) {
           writeJson(res, 503, { ok: false, error: { code: 'grpc', message: error instanceof Error ? error.message : String(error) } })
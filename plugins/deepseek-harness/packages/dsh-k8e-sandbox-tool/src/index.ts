@@ -183,15 +183,19 @@ export class K8eSandboxTools extends Service {
 
     ctx.tools.register(defineTool({
       name: 'k8e_sandbox_expose',
-      description: 'Expose an in-sandbox service port through the k8e API Gateway and return its public URL (http(s)://<gateway>/k8e/expose/<session>/<port>/). Use after starting a long-running service (e.g. a web server) in the sandbox. Teardown with k8e_sandbox_unexpose.',
+      description: 'Expose an in-sandbox service port through the k8e API Gateway and return its URL (http(s)://<gateway>/k8e/expose/<session>/<port>/). Access defaults to token: the returned URL carries a token that is issued only once, so hand the URL over exactly as returned; without it the gateway answers 401. Use access "signed" for a link that expires after ttlSeconds, or "public" for no credential. Use after starting a long-running service (e.g. a web server) in the sandbox. Teardown with k8e_sandbox_unexpose.',
       parameters: {
         port: { type: 'number', required: true, description: 'In-sandbox service port to expose (1-65535)' },
         host: { type: 'string', description: 'In-sandbox listen address (default 127.0.0.1)' },
+        access: { type: 'string', description: 'Who may reach the URL: token (default), signed or public. An already exposed port keeps its mode.' },
+        ttlSeconds: { type: 'number', description: 'Signed access: URL lifetime in seconds (default 3600, max 604800)' },
       },
       output: {
         schema: resultSchema({
           url: { type: 'string' },
           port: { type: 'number' },
+          access: { type: 'string' },
+          expiresAt: { type: 'number' },
         }),
         render: renderValue,
       },
@@ -201,14 +205,18 @@ export class K8eSandboxTools extends Service {
         // sandbox (web-terminal deployments), where the binary does not exist.
         const grpc = runtime().getGrpcClient()
         const sessionId = await runtime().getSession()
-        const res = await grpc.exposeService(sessionId, args.port, args.host)
-        return { url: res.url, port: args.port }
+        const access = args.access === undefined || args.access === '' ? undefined : args.access
+        if (access !== undefined && access !== 'token' && access !== 'signed' && access !== 'public') {
+          throw new Error(`access must be token, signed or public, got "${access}"`)
+        }
+        const res = await grpc.exposeService(sessionId, args.port, { host: args.host, access, ttlSeconds: args.ttlSeconds })
+        return { url: res.url, port: args.port, access: res.access, ...(res.expiresAt !== undefined ? { expiresAt: res.expiresAt } : {}) }
       },
     }))
 
     ctx.tools.register(defineTool({
       name: 'k8e_sandbox_unexpose',
-      description: 'Remove a public tunnel for an in-sandbox service port (stops k8e_sandbox_expose). Idempotent: unexposing a port that is not exposed returns ok=false.',
+      description: 'Remove the gateway exposure of an in-sandbox service port (stops k8e_sandbox_expose; links and cookies handed out for it stop working). Idempotent: unexposing a port that is not exposed returns ok=false.',
       parameters: {
         port: { type: 'number', required: true, description: 'In-sandbox service port to unexpose' },
       },
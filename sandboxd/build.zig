@@ -52,4 +52,12 @@ pub fn build(b: *std.Build) void {
     const pty_mod = b.createModule(.{ .root_source_file = b.path("src/pty_test.zig"), .target = native });
     const pty_tests = b.addTest(.{ .root_module = pty_mod });
     test_step.dependOn(&b.addRunArtifact(pty_tests).step);
+
+    const output_mod = b.createModule(.{ .root_source_file = b.path("src/output_test.zig"), .target = native });
+    const output_tests = b.addTest(.{ .root_module = output_mod });
+    test_step.dependOn(&b.addRunArtifact(output_tests).step);
+
+    const auth_mod = b.createModule(.{ .root_source_file = b.path("src/auth_test.zig"), .target = native });
+    const auth_tests = b.addTest(.{ .root_module = auth_mod });
+    test_step.dependOn(&b.addRunArtifact(auth_tests).step);
 }
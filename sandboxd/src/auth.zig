@@ -0,0 +1,200 @@
+const std = @import("std");
+
+/// Gateway ↔ sandboxd request authentication.
+///
+/// The orchestrator injects a per-pod key (hex) as SANDBOXD_AUTH_KEY. Every
+/// request then carries
+///
+///     X-Sandboxd-Auth-V2: <unix seconds>:<nonce>:<hex HMAC-SHA256(key, METHOD\nURI\nTS\nNONCE\nBODY)>
+///
+/// and every response answers with
+///
+///     X-Sandboxd-Proof: <hex HMAC-SHA256(key, "sandboxd\n" ++ request mac)>
+///
+/// so the gateway knows it reached this pod and not whatever now owns the IP.
+/// Nonces are remembered for the skew window, so a captured request cannot
+/// be replayed. Response bodies are authenticated too: fixed bodies by
+///
+///     X-Sandboxd-Body-MAC: <hex HMAC-SHA256(key, "body\n" ++ request mac ++ "\n" ++ BODY)>
+///
+/// and event streams by a final comment line
+///
+///     : sandboxd-mac <hex HMAC-SHA256(key, "stream\n" ++ request mac ++ "\n" ++ STREAM)>
+///
+/// This is integrity, not confidentiality: traffic stays readable on the pod
+/// network. Without the variable (pods created before auth existed) sandboxd
+/// accepts every request and sends no proof.
+const HmacSha256 = std.crypto.auth.hmac.sha2.HmacSha256;
+
+pub const env_name = "SANDBOXD_AUTH_KEY";
+
+/// max_skew_secs bounds the request timestamp against the pod clock, and
+/// so how long a nonce must be remembered.
+pub const max_skew_secs: i64 = 60;
+
+const mac_hex_len = HmacSha256.mac_length * 2;
+pub const nonce_hex_len = 32;
+
+/// seen_cap bounds the remembered nonces: the gateway would have to send a
+/// pod more than seen_cap requests within max_skew_secs before an evicted
+/// nonce could be replayed.
+const seen_cap = 8192;
+
+const Seen = struct {
+    nonce: [nonce_hex_len]u8 = [_]u8{0} ** nonce_hex_len,
+    ts: i64 = 0, // 0: empty slot
+};
+
+var seen = [_]Seen{.{}} ** seen_cap;
+var seen_next: usize = 0;
+var seen_mu: std.Thread.Mutex = .{};
+
+var key_buf: [64]u8 = undefined;
+var key_len: usize = 0;
+/// misconfigured is set when the variable is present but unusable: fail
+/// closed rather than silently serving unauthenticated.
+var misconfigured = false;
+
+/// proof_line is the response header for the request this thread is serving
+/// (one thread per connection, see main.handleConn).
+threadlocal var proof_line_buf: [128]u8 = undefined;
+threadlocal var proof_line_len: usize = 0;
+/// req_mac is the verified request mac the response MACs are bound to.
+threadlocal var req_mac: [mac_hex_len]u8 = undefined;
+threadlocal var body_line_buf: [128]u8 = undefined;
+threadlocal var stream_mac: HmacSha256 = undefined;
+threadlocal var streaming = false;
+threadlocal var trailer_buf: [128]u8 = undefined;
+
+/// init loads the key once at startup and scrubs it from the process
+/// environment so sandboxed code cannot read it back from /proc/1/environ.
+pub fn init() void {
+    const raw = std.posix.getenv(env_name) orelse return;
+    defer @memset(@constCast(raw), '0');
+    if (raw.len == 0 or raw.len > key_buf.len * 2) {
+        misconfigured = true;
+        std.log.err("{s}: want 1..{d} hex bytes", .{ env_name, key_buf.len });
+        return;
+    }
+    const decoded = std.fmt.hexToBytes(&key_buf, raw) catch {
+        misconfigured = true;
+        std.log.err("{s}: not hex", .{env_name});
+        return;
+    };
+    key_len = decoded.len;
+    std.log.info("request authentication enabled", .{});
+}
+
+/// setKey installs a raw key (tests; init decodes the environment).
+pub fn setKey(key: []const u8) void {
+    @memcpy(key_buf[0..key.len], key);
+    key_len = key.len;
+    misconfigured = false;
+}
+
+pub fn enabled() bool {
+    return key_len > 0 or misconfigured;
+}
+
+/// verify checks the X-Sandboxd-Auth-V2 header value for this request and,
+/// on success, prepares the proof header sent with the response.
+pub fn verify(header: []const u8, method: []const u8, uri: []const u8, body: []const u8) bool {
+    proof_line_len = 0;
+    streaming = false;
+    if (!enabled()) return true;
+    if (misconfigured) return false;
+
+    var fields = std.mem.splitScalar(u8, header, ':');
+    const ts_str = fields.next() orelse return false;
+    const nonce = fields.next() orelse return false;
+    const got = fields.rest();
+    if (nonce.len != nonce_hex_len or got.len != mac_hex_len) return false;
+    const ts = std.fmt.parseInt(i64, ts_str, 10) catch return false;
+
+    var now: std.os.linux.timespec = undefined;
+    _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.REALTIME, &now);
+    const skew = now.sec - ts;
+    if (skew > max_skew_secs or skew < -max_skew_secs) return false;
+
+    var mac: [HmacSha256.mac_length]u8 = undefined;
+    var h = HmacSha256.init(key_buf[0..key_len]);
+    h.update(method);
+    h.update("\n");
+    h.update(uri);
+    h.update("\n");
+    h.update(ts_str);
+    h.update("\n");
+    h.update(nonce);
+    h.update("\n");
+    h.update(body);
+    h.final(&mac);
+    const want = std.fmt.bytesToHex(mac, .lower);
+    if (!std.crypto.timing_safe.eql([mac_hex_len]u8, want, got[0..mac_hex_len].*)) return false;
+    if (!remember(nonce[0..nonce_hex_len], ts, now.sec)) return false;
+    req_mac = want;
+
+    var proof: [HmacSha256.mac_length]u8 = undefined;
+    var p = HmacSha256.init(key_buf[0..key_len]);
+    p.update("sandboxd\n");
+    p.update(&want);
+    p.final(&proof);
+    const line = std.fmt.bufPrint(&proof_line_buf, "X-Sandboxd-Proof: {s}\r\n", .{std.fmt.bytesToHex(proof, .lower)}) catch return false;
+    proof_line_len = line.len;
+    return true;
+}
+
+/// proofHeader is the "X-Sandboxd-Proof: ...\r\n" line for the current
+/// response, or "" when auth is off.
+pub fn proofHeader() []const u8 {
+    return proof_line_buf[0..proof_line_len];
+}
+
+/// remember records a verified request's nonce, or reports a replay.
+fn remember(nonce: *const [nonce_hex_len]u8, ts: i64, now: i64) bool {
+    seen_mu.lock();
+    defer seen_mu.unlock();
+    for (&seen) |*s| {
+        if (s.ts != 0 and now - s.ts <= max_skew_secs and std.mem.eql(u8, &s.nonce, nonce)) return false;
+    }
+    seen[seen_next] = .{ .nonce = nonce.*, .ts = ts };
+    seen_next = (seen_next + 1) % seen_cap;
+    return true;
+}
+
+/// bodyMacHeader is the "X-Sandboxd-Body-MAC: ...\r\n" line for a fixed
+/// response body, or "" when the request was not authenticated.
+pub fn bodyMacHeader(body: []const u8) []const u8 {
+    if (proof_line_len == 0) return "";
+    var mac: [HmacSha256.mac_length]u8 = undefined;
+    var h = HmacSha256.init(key_buf[0..key_len]);
+    h.update("body\n");
+    h.update(&req_mac);
+    h.update("\n");
+    h.update(body);
+    h.final(&mac);
+    return std.fmt.bufPrint(&body_line_buf, "X-Sandboxd-Body-MAC: {s}\r\n", .{std.fmt.bytesToHex(mac, .lower)}) catch "";
+}
+
+/// streamBegin starts the MAC over an event-stream body.
+pub fn streamBegin() void {
+    streaming = proof_line_len > 0;
+    if (!streaming) return;
+    stream_mac = HmacSha256.init(key_buf[0..key_len]);
+    stream_mac.update("stream\n");
+    stream_mac.update(&req_mac);
+    stream_mac.update("\n");
+}
+
+pub fn streamUpdate(data: []const u8) void {
+    if (streaming) stream_mac.update(data);
+}
+
+/// streamTrailer ends the current event stream: the ": sandboxd-mac ..."
+/// comment line, or "" when no authenticated stream is open.
+pub fn streamTrailer() []const u8 {
+    if (!streaming) return "";
+    streaming = false;
+    var mac: [HmacSha256.mac_length]u8 = undefined;
+    stream_mac.final(&mac);
+    return std.fmt.bufPrint(&trailer_buf, ": sandboxd-mac {s}\n\n", .{std.fmt.bytesToHex(mac, .lower)}) catch "";
+}
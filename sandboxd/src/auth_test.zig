@@ -0,0 +1,88 @@
+const std = @import("std");
+const auth = @import("auth.zig");
+
+const HmacSha256 = std.crypto.auth.hmac.sha2.HmacSha256;
+
+fn sign(key: []const u8, method: []const u8, uri: []const u8, ts: []const u8, nonce: []const u8, body: []const u8) [64]u8 {
+    var mac: [HmacSha256.mac_length]u8 = undefined;
+    var h = HmacSha256.init(key);
+    h.update(method);
+    h.update("\n");
+    h.update(uri);
+    h.update("\n");
+    h.update(ts);
+    h.update("\n");
+    h.update(nonce);
+    h.update("\n");
+    h.update(body);
+    h.final(&mac);
+    return std.fmt.bytesToHex(mac, .lower);
+}
+
+fn now() i64 {
+    var ts: std.os.linux.timespec = undefined;
+    _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.REALTIME, &ts);
+    return ts.sec;
+}
+
+test "verify accepts a signed request and sets the proof header" {
+    const key = "per-pod-key-0123456789abcdef";
+    auth.setKey(key);
+    var ts_buf: [32]u8 = undefined;
+    const ts = try std.fmt.bufPrint(&ts_buf, "{d}", .{now()});
+    const nonce = "00112233445566778899aabbccddeef0";
+    const mac = sign(key, "POST", "/exec", ts, nonce, "{\"command\":\"id\"}");
+    var hdr_buf: [160]u8 = undefined;
+    const hdr = try std.fmt.bufPrint(&hdr_buf, "{s}:{s}:{s}", .{ ts, nonce, mac });
+
+    // Any change to the signed request is rejected and clears the proof.
+    try std.testing.expect(!auth.verify(hdr, "POST", "/exec", "{\"command\":\"rm -rf /\"}"));
+    try std.testing.expectEqual(@as(usize, 0), auth.proofHeader().len);
+    try std.testing.expect(!auth.verify(hdr, "POST", "/files/read", "{\"command\":\"id\"}"));
+    try std.testing.expect(!auth.verify("", "POST", "/exec", ""));
+
+    try std.testing.expect(auth.verify(hdr, "POST", "/exec", "{\"command\":\"id\"}"));
+    try std.testing.expect(std.mem.startsWith(u8, auth.proofHeader(), "X-Sandboxd-Proof: "));
+    try std.testing.expect(std.mem.startsWith(u8, auth.bodyMacHeader("{}"), "X-Sandboxd-Body-MAC: "));
+}
+
+test "verify rejects a replayed nonce" {
+    const key = "per-pod-key-0123456789abcdef";
+    auth.setKey(key);
+    var ts_buf: [32]u8 = undefined;
+    const ts = try std.fmt.bufPrint(&ts_buf, "{d}", .{now()});
+    const nonce = "ffeeddccbbaa99887766554433221100";
+    const mac = sign(key, "GET", "/ready", ts, nonce, "");
+    var hdr_buf: [160]u8 = undefined;
+    const hdr = try std.fmt.bufPrint(&hdr_buf, "{s}:{s}:{s}", .{ ts, nonce, mac });
+    try std.testing.expect(auth.verify(hdr, "GET", "/ready", ""));
+    try std.testing.expect(!auth.verify(hdr, "GET", "/ready", ""));
+}
+
+test "verify rejects a stale timestamp" {
+    const key = "per-pod-key-0123456789abcdef";
+    auth.setKey(key);
+    var ts_buf: [32]u8 = undefined;
+    const ts = try std.fmt.bufPrint(&ts_buf, "{d}", .{now() - auth.max_skew_secs - 60});
+    const nonce = "0123456789abcdef0123456789abcdef";
+    const mac = sign(key, "GET", "/ready", ts, nonce, "");
+    var hdr_buf: [160]u8 = undefined;
+    const hdr = try std.fmt.bufPrint(&hdr_buf, "{s}:{s}:{s}", .{ ts, nonce, mac });
+    try std.testing.expect(!auth.verify(hdr, "GET", "/ready", ""));
+}
+
+test "stream trailer closes an authenticated event stream once" {
+    const key = "per-pod-key-0123456789abcdef";
+    auth.setKey(key);
+    var ts_buf: [32]u8 = undefined;
+    const ts = try std.fmt.bufPrint(&ts_buf, "{d}", .{now()});
+    const nonce = "a0a1a2a3a4a5a6a7a8a9aaabacadaeaf";
+    const mac = sign(key, "POST", "/exec/stream", ts, nonce, "{}");
+    var hdr_buf: [160]u8 = undefined;
+    const hdr = try std.fmt.bufPrint(&hdr_buf, "{s}:{s}:{s}", .{ ts, nonce, mac });
+    try std.testing.expect(auth.verify(hdr, "POST", "/exec/stream", "{}"));
+    auth.streamBegin();
+    auth.streamUpdate("data: {\"pid\":1}\n\n");
+    try std.testing.expect(std.mem.startsWith(u8, auth.streamTrailer(), ": sandboxd-mac "));
+    try std.testing.expectEqual(@as(usize, 0), auth.streamTrailer().len);
+}
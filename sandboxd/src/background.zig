@@ -1,8 +1,9 @@
 const std = @import("std");
 const main = @import("main.zig");
 const exec = @import("exec.zig");
+const output = @import("output.zig");
 
-const BG_DIR = "/workspace/.k8e_bg";
+const BG_DIR = output.RUN_DIR;
 
 // Fixed wrapper run by /bin/sh. An EXIT trap records the real exit code of the
 // user command on any normal shell exit (including `exit N` and errors), so a
@@ -143,8 +144,14 @@ pub fn handleBgPoll(allocator: std.mem.Allocator, client_fd: i32, run_id: []cons
         const stderr_esc = try exec.jsonEscape(allocator, stderr_raw);
         defer allocator.free(stderr_esc);
 
+        // Output past the 10 MiB read cap stays on disk; the sizes and cut
+        // offsets tell the gateway where GET /exec/output should continue.
+        const stdout_bytes = output.fileSize(BG_DIR, run_id, "stdout");
+        const stderr_bytes = output.fileSize(BG_DIR, run_id, "stderr");
+        const truncated = stdout_bytes > stdout_raw.len or stderr_bytes > stderr_raw.len;
+
         const status = if (exit_code == -1) "timed_out" else "completed";
-        const resp = try std.fmt.allocPrint(allocator, "{{\"run_id\":\"{s}\",\"status\":\"{s}\",\"exit_code\":{d},\"stdout\":\"{s}\",\"stderr\":\"{s}\"}}", .{ run_id, status, exit_code, stdout_esc, stderr_esc });
+        const resp = try std.fmt.allocPrint(allocator, "{{\"run_id\":\"{s}\",\"status\":\"{s}\",\"exit_code\":{d},\"stdout\":\"{s}\",\"stderr\":\"{s}\",\"truncated\":{s},\"stdout_bytes\":{d},\"stderr_bytes\":{d},\"stdout_cut\":{d},\"stderr_cut\":{d}}}", .{ run_id, status, exit_code, stdout_esc, stderr_esc, if (truncated) "true" else "false", stdout_bytes, stderr_bytes, stdout_raw.len, stderr_raw.len });
         defer allocator.free(resp);
         try main.writeResponse(client_fd, "200 OK", "application/json", resp);
         return;
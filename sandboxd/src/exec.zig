@@ -2,6 +2,7 @@ const std = @import("std");
 const main = @import("main.zig");
 const venv = @import("venv.zig");
 const execctl = @import("execctl.zig");
+const output = @import("output.zig");
 
 const default_path_value = "/workspace/.venv/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin";
 const default_venv_value = "/workspace/.venv";
@@ -14,6 +15,13 @@ const ExecRequest = struct {
     env: std.json.Value = .{ .null = {} },
     // Optional session id for transcript recording (KIP-16 M4).
     session_id: []const u8 = "",
+    // /exec/stream only: keep stderr on its own pipe and emit typed base64
+    // frames ({"stdout":..} / {"stderr":..}) plus a full exit frame
+    // (ExecStreamV2). false keeps the raw merged-output frames.
+    framed: bool = false,
+    // /exec only: where to spill full output when a stream overflows its
+    // cap (see output.zig). Empty keeps the old drop-the-rest behavior.
+    run_id: []const u8 = "",
 };
 
 // max_stdout_bytes / max_stderr_bytes: capture caps (~1 MiB agent-facing policy).
@@ -26,6 +34,13 @@ pub const ExecResult = struct {
     exit_code: i32,
     duration_ms: i64 = 0,
     truncated: bool = false,
+    // Full stream sizes; larger than stdout/stderr.len when truncated.
+    stdout_bytes: usize = 0,
+    stderr_bytes: usize = 0,
+    // Both streams were written in full under the run's spill directory.
+    spilled: bool = false,
+    // A stream outgrew output.max_spill_bytes; its file stops there.
+    spill_truncated: bool = false,
 
     pub fn deinit(self: ExecResult, allocator: std.mem.Allocator) void {
         allocator.free(self.stdout);
@@ -112,6 +127,12 @@ pub fn runCommand(allocator: std.mem.Allocator, command: []const u8, workdir: []
 }
 
 pub fn runCommandWithEnv(allocator: std.mem.Allocator, command: []const u8, workdir: []const u8, user_env: std.json.Value) !ExecResult {
+    return runCommandSpill(allocator, command, workdir, user_env, null);
+}
+
+/// runCommandSpill is runCommandWithEnv that, given a spill, writes the full
+/// stdout and stderr to disk when either overflows its in-memory cap.
+pub fn runCommandSpill(allocator: std.mem.Allocator, command: []const u8, workdir: []const u8, user_env: std.json.Value, spill: ?output.Spill) !ExecResult {
     // Null-terminate command for execve (child has no allocator)
     var cmd_buf: [65536]u8 = undefined;
     if (command.len >= cmd_buf.len) return error.CommandTooLong;
@@ -168,12 +189,17 @@ pub fn runCommandWithEnv(allocator: std.mem.Allocator, command: []const u8, work
     _ = std.os.linux.close(stdout_pipe[1]); // close write end
     _ = std.os.linux.close(stderr_pipe[1]); // close write end
 
-    var stdout_trunc = false;
-    var stderr_trunc = false;
-    const stdout = readAllFromFdTrunc(allocator, stdout_pipe[0], max_stdout_bytes, &stdout_trunc) catch
+    var stdout_cap = Capture{};
+    var stderr_cap = Capture{};
+    const stdout = readAllFromFdSpill(allocator, stdout_pipe[0], max_stdout_bytes, spill, "stdout", &stdout_cap) catch
         allocator.dupe(u8, "") catch @panic("oom");
-    const stderr = readAllFromFdTrunc(allocator, stderr_pipe[0], max_stderr_bytes, &stderr_trunc) catch
+    const stderr = readAllFromFdSpill(allocator, stderr_pipe[0], max_stderr_bytes, spill, "stderr", &stderr_cap) catch
         allocator.dupe(u8, "") catch @panic("oom");
+    // A spill must hold both streams: write the one that fit in memory too.
+    if (spill) |sp| {
+        if (stdout_cap.spilled and !stderr_cap.spilled) output.writeStream(sp, "stderr", stderr);
+        if (stderr_cap.spilled and !stdout_cap.spilled) output.writeStream(sp, "stdout", stdout);
+    }
 
     _ = std.os.linux.close(stdout_pipe[0]);
     _ = std.os.linux.close(stderr_pipe[0]);
@@ -207,7 +233,11 @@ pub fn runCommandWithEnv(allocator: std.mem.Allocator, command: []const u8, work
         .stderr = stderr,
         .exit_code = exit_code,
         .duration_ms = if (duration_ms < 0) 0 else duration_ms,
-        .truncated = stdout_trunc or stderr_trunc,
+        .truncated = stdout_cap.truncated or stderr_cap.truncated,
+        .stdout_bytes = stdout_cap.total,
+        .stderr_bytes = stderr_cap.total,
+        .spilled = stdout_cap.spilled or stderr_cap.spilled,
+        .spill_truncated = stdout_cap.spill_truncated or stderr_cap.spill_truncated,
     };
 }
 
@@ -218,7 +248,26 @@ pub fn readAllFromFd(allocator: std.mem.Allocator, fd: i32, max_bytes: usize) ![
 
 /// readAllFromFdTrunc reads up to max_bytes; sets *truncated when more data was available.
 pub fn readAllFromFdTrunc(allocator: std.mem.Allocator, fd: i32, max_bytes: usize, truncated: *bool) ![]u8 {
-    truncated.* = false;
+    var cap = Capture{};
+    const out = try readAllFromFdSpill(allocator, fd, max_bytes, null, "", &cap);
+    truncated.* = cap.truncated;
+    return out;
+}
+
+/// Capture describes one stream read by readAllFromFdSpill.
+pub const Capture = struct {
+    total: usize = 0, // bytes the stream produced, including dropped ones
+    truncated: bool = false,
+    spilled: bool = false, // the stream is in the spill file
+    spill_truncated: bool = false, // ... up to output.max_spill_bytes only
+};
+
+/// readAllFromFdSpill reads up to max_bytes into memory and drains the rest
+/// so the writer does not block. With a spill, overflowing output is not
+/// dropped: the captured prefix and everything after it, up to
+/// output.max_spill_bytes, go to the run's stream file.
+pub fn readAllFromFdSpill(allocator: std.mem.Allocator, fd: i32, max_bytes: usize, spill: ?output.Spill, stream: []const u8, cap: *Capture) ![]u8 {
+    cap.* = .{};
     var buf = try allocator.alloc(u8, max_bytes);
     errdefer allocator.free(buf);
     var total: usize = 0;
@@ -233,14 +282,35 @@ pub fn readAllFromFdTrunc(allocator: std.mem.Allocator, fd: i32, max_bytes: usiz
         @memcpy(buf[total..][0..@as(usize, @intCast(n))], tmp[0..@as(usize, @intCast(n))]);
         total += @as(usize, @intCast(n));
     }
+    cap.total = total;
     if (total >= max_bytes) {
         // Drain remainder so the writer does not block; mark truncated.
+        var spill_fd: ?i32 = null;
+        defer if (spill_fd) |f| {
+            _ = std.os.linux.close(f);
+        };
+        var spilled: usize = total;
         var drain: [4096]u8 = undefined;
         while (true) {
             const n = std.os.linux.read(fd, &drain, drain.len);
             if (n <= 0) break;
-            truncated.* = true;
+            const chunk = drain[0..@as(usize, @intCast(n))];
+            if (!cap.truncated) {
+                cap.truncated = true;
+                if (spill) |sp| {
+                    spill_fd = output.openSpill(sp, stream);
+                    if (spill_fd) |f| output.writeAll(f, buf[0..total]);
+                }
+            }
+            if (spill_fd) |f| {
+                const keep = @min(chunk.len, output.max_spill_bytes -| spilled);
+                output.writeAll(f, chunk[0..keep]);
+                spilled += keep;
+                if (keep < chunk.len) cap.spill_truncated = true;
+            }
+            cap.total += chunk.len;
         }
+        cap.spilled = spill_fd != null;
     }
     // Shrink to exact size so DebugAllocator canary check passes
     if (allocator.resize(buf, total)) {
@@ -314,6 +384,19 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
             try main.writeResponse(client_fd, "500 Internal Server Error", "application/json", "{\"error\":\"pipe failed\"}");
             return;
         }
+        // Framed streams keep stderr separate; otherwise it shares stdout.
+        var stderr_pipe: [2]i32 = .{ -1, -1 };
+        if (req.framed and std.os.linux.pipe2(&stderr_pipe, std.os.linux.O{ .CLOEXEC = true }) < 0) {
+            _ = std.os.linux.close(stdout_pipe[0]);
+            _ = std.os.linux.close(stdout_pipe[1]);
+            _ = std.os.linux.close(stdin_pipe[0]);
+            _ = std.os.linux.close(stdin_pipe[1]);
+            try main.writeResponse(client_fd, "500 Internal Server Error", "application/json", "{\"error\":\"pipe failed\"}");
+            return;
+        }
+
+        var ts_start: std.os.linux.timespec = undefined;
+        _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.MONOTONIC, &ts_start);
 
         const child_pid = std.os.linux.fork();
         if (child_pid == 0) {
@@ -322,7 +405,13 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
             _ = std.os.linux.close(stdout_pipe[0]);
             _ = std.os.linux.close(stdin_pipe[1]); // child keeps read end only
             _ = std.os.linux.dup2(stdout_pipe[1], 1);
-            _ = std.os.linux.dup2(stdout_pipe[1], 2);
+            if (req.framed) {
+                _ = std.os.linux.close(stderr_pipe[0]);
+                _ = std.os.linux.dup2(stderr_pipe[1], 2);
+                _ = std.os.linux.close(stderr_pipe[1]);
+            } else {
+                _ = std.os.linux.dup2(stdout_pipe[1], 2);
+            }
             _ = std.os.linux.dup2(stdin_pipe[0], 0);
             _ = std.os.linux.close(stdout_pipe[1]);
             _ = std.os.linux.close(stdin_pipe[0]);
@@ -342,6 +431,7 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
         // Connect can describe the process.
         _ = std.os.linux.close(stdout_pipe[1]);
         _ = std.os.linux.close(stdin_pipe[0]);
+        if (req.framed) _ = std.os.linux.close(stderr_pipe[1]);
         execctl.registerWithConfig(@intCast(child_pid), stdin_pipe[1], req.command);
 
         // spawn timeout killer thread
@@ -354,18 +444,27 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
         }
 
         // Write SSE header
-        const header = "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n";
-        _ = std.os.linux.write(client_fd, header.ptr, header.len);
+        main.writeEventStreamHeader(client_fd);
 
         // First SSE frame carries the in-guest pid: the e2b layer addresses
         // /exec/stdin and /exec/signal by it (its synthetic process-table pid
         // is not the sandbox's real pid). Frame: data: {"pid":N}\n\n
         var pid_frame_buf: [64]u8 = undefined;
         const pid_frame = std.fmt.bufPrint(&pid_frame_buf, "data: {{\"pid\":{d}}}\n\n", .{child_pid}) catch {
-            _ = std.os.linux.write(client_fd, "data: {\"error\":\"pid frame\"}\n\n".ptr, 27);
+            main.writeEventStream(client_fd, "data: {\"error\":\"pid frame\"}\n\n");
             return;
         };
-        _ = std.os.linux.write(client_fd, pid_frame.ptr, pid_frame.len);
+        main.writeEventStream(client_fd, pid_frame);
+
+        if (req.framed) {
+            streamFramed(allocator, client_fd, @intCast(child_pid), stdout_pipe[0], stderr_pipe[0]);
+            _ = std.os.linux.close(stdout_pipe[0]);
+            _ = std.os.linux.close(stderr_pipe[0]);
+            execctl.markDone(@intCast(child_pid));
+            writeFramedExit(client_fd, @intCast(child_pid), ts_start, req.timeout);
+            execctl.unregister(@intCast(child_pid));
+            return;
+        }
 
         // Stream stdout to client, buffering for attach (E2B Connect).
         var stdout_buf: [4096]u8 = undefined;
@@ -376,10 +475,10 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
             execctl.appendOutput(@intCast(child_pid), data);
             var line_buf: [4200]u8 = undefined;
             const line = std.fmt.bufPrint(&line_buf, "data: {s}\n\n", .{data}) catch {
-                _ = std.os.linux.write(client_fd, "data: {\"error\":\"output too large\"}\n\n".ptr, 34);
+                main.writeEventStream(client_fd, "data: {\"error\":\"output too large\"}\n\n");
                 break;
             };
-            _ = std.os.linux.write(client_fd, line.ptr, line.len);
+            main.writeEventStream(client_fd, line);
         }
 
         _ = std.os.linux.close(stdout_pipe[0]);
@@ -403,16 +502,17 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
             -1; // killed by signal / not reaped normally
         var exit_frame_buf: [64]u8 = undefined;
         const exit_frame = std.fmt.bufPrint(&exit_frame_buf, "data: {{\"exit\":{d}}}\n\n", .{exit_code}) catch {
-            _ = std.os.linux.write(client_fd, "data: {\"exit\":-1}\n\n".ptr, 22);
+            main.writeEventStream(client_fd, "data: {\"exit\":-1}\n\n");
             return;
         };
-        _ = std.os.linux.write(client_fd, exit_frame.ptr, exit_frame.len);
+        main.writeEventStream(client_fd, exit_frame);
 
         execctl.unregister(@intCast(child_pid));
         return;
     }
 
-    const result = runCommandWithEnv(allocator, req.command, req.workdir, req.env) catch |err| {
+    const spill: ?output.Spill = if (output.validRunId(req.run_id)) .{ .dir = output.RUN_DIR, .run_id = req.run_id } else null;
+    const result = runCommandSpill(allocator, req.command, req.workdir, req.env, spill) catch |err| {
         const msg = try std.fmt.allocPrint(allocator, "{{\"error\":\"{s}\"}}", .{@errorName(err)});
         defer allocator.free(msg);
         try main.writeResponse(client_fd, "500 Internal Server Error", "application/json", msg);
@@ -432,13 +532,102 @@ pub fn handleExec(allocator: std.mem.Allocator, client_fd: i32, body: []const u8
     defer allocator.free(stderr_json);
 
     const trunc_lit: []const u8 = if (result.truncated) "true" else "false";
+    // stdout_cut/stderr_cut are the excerpt lengths: where the response
+    // stops and GET /exec/output?run_id=... continues.
+    var run_buf: [256]u8 = undefined;
+    const run_lit: []const u8 = if (result.spilled)
+        std.fmt.bufPrint(&run_buf, ",\"run_id\":\"{s}\"{s}", .{ req.run_id, if (result.spill_truncated) ",\"spill_truncated\":true" else "" }) catch ""
+    else
+        "";
     const resp = try std.fmt.allocPrint(allocator,
-        "{{\"stdout\":\"{s}\",\"stderr\":\"{s}\",\"exit_code\":{d},\"duration_ms\":{d},\"truncated\":{s}}}",
-        .{ stdout_json, stderr_json, result.exit_code, result.duration_ms, trunc_lit });
+        "{{\"stdout\":\"{s}\",\"stderr\":\"{s}\",\"exit_code\":{d},\"duration_ms\":{d},\"truncated\":{s},\"stdout_bytes\":{d},\"stderr_bytes\":{d},\"stdout_cut\":{d},\"stderr_cut\":{d}{s}}}",
+        .{ stdout_json, stderr_json, result.exit_code, result.duration_ms, trunc_lit, result.stdout_bytes, result.stderr_bytes, result.stdout.len, result.stderr.len, run_lit });
     defer allocator.free(resp);
     try main.writeResponse(client_fd, "200 OK", "application/json", resp);
 }
 
+/// streamFramed drains a framed exec's stdout and stderr pipes until both
+/// hit EOF, sending each read as data: {"stdout":"<base64>"} or
+/// data: {"stderr":"<base64>"}. Base64 keeps binary output and blank lines
+/// from breaking the SSE framing. Both streams feed the attach buffer.
+fn streamFramed(allocator: std.mem.Allocator, client_fd: i32, pid: std.os.linux.pid_t, stdout_fd: i32, stderr_fd: i32) void {
+    var fds = [2]std.os.linux.pollfd{
+        .{ .fd = stdout_fd, .events = std.os.linux.POLL.IN, .revents = 0 },
+        .{ .fd = stderr_fd, .events = std.os.linux.POLL.IN, .revents = 0 },
+    };
+    const keys = [2][]const u8{ "stdout", "stderr" };
+    var open: usize = 2;
+    var buf: [4096]u8 = undefined;
+    while (open > 0) {
+        const rc = std.os.linux.poll(&fds, fds.len, -1);
+        switch (std.posix.errno(rc)) {
+            .SUCCESS => {},
+            .INTR => continue,
+            else => return,
+        }
+        for (&fds, 0..) |*p, i| {
+            if (p.fd < 0 or p.revents == 0) continue;
+            const n = std.os.linux.read(p.fd, &buf, buf.len);
+            if (std.posix.errno(n) != .SUCCESS or n == 0) {
+                // EOF or error: poll ignores negative fds from here on.
+                p.fd = -1;
+                open -= 1;
+                continue;
+            }
+            const data = buf[0..n];
+            execctl.appendOutput(pid, data);
+            writeTypedFrame(allocator, client_fd, keys[i], data) catch return;
+        }
+    }
+}
+
+fn writeTypedFrame(allocator: std.mem.Allocator, client_fd: i32, key: []const u8, data: []const u8) !void {
+    const encoded = try allocator.alloc(u8, std.base64.standard.Encoder.calcSize(data.len));
+    defer allocator.free(encoded);
+    _ = std.base64.standard.Encoder.encode(encoded, data);
+    const frame = try std.fmt.allocPrint(allocator, "data: {{\"{s}\":\"{s}\"}}\n\n", .{ key, encoded });
+    defer allocator.free(frame);
+    main.writeEventStream(client_fd, frame);
+}
+
+/// writeFramedExit reaps the child and sends the final frame:
+/// data: {"exit":N,"signal":S,"timed_out":B,"duration_ms":D}. exit is -1 when
+/// the process was killed by a signal; timed_out means the timeout killer's
+/// SIGKILL ended it.
+fn writeFramedExit(client_fd: i32, pid: std.os.linux.pid_t, ts_start: std.os.linux.timespec, timeout_sec: u32) void {
+    var status: u32 = 0;
+    const wrc = std.os.linux.syscall4(
+        .wait4,
+        @as(usize, @bitCast(@as(isize, @intCast(pid)))),
+        @intFromPtr(&status),
+        0,
+        0,
+    );
+    const reaped = std.posix.errno(@as(usize, @bitCast(wrc))) == .SUCCESS;
+    const exit_code: i32 = if (reaped and std.posix.W.IFEXITED(status))
+        @intCast(std.posix.W.EXITSTATUS(status))
+    else
+        -1;
+    const signal: u32 = if (reaped and std.posix.W.IFSIGNALED(status)) std.posix.W.TERMSIG(status) else 0;
+
+    var ts_end: std.os.linux.timespec = undefined;
+    _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.MONOTONIC, &ts_end);
+    const elapsed: i64 = @as(i64, @intCast(ts_end.sec - ts_start.sec)) * 1000 +
+        @divTrunc(@as(i64, @intCast(ts_end.nsec - ts_start.nsec)), 1_000_000);
+    const duration_ms: i64 = if (elapsed < 0) 0 else elapsed;
+    const timed_out = timeout_sec > 0 and signal == @intFromEnum(std.os.linux.SIG.KILL) and
+        duration_ms >= @as(i64, timeout_sec) * 1000;
+
+    const timed_out_lit: []const u8 = if (timed_out) "true" else "false";
+
+    var frame_buf: [160]u8 = undefined;
+    const frame = std.fmt.bufPrint(&frame_buf, "data: {{\"exit\":{d},\"signal\":{d},\"timed_out\":{s},\"duration_ms\":{d}}}\n\n", .{ exit_code, signal, timed_out_lit, duration_ms }) catch {
+        main.writeEventStream(client_fd, "data: {\"exit\":-1}\n\n");
+        return;
+    };
+    main.writeEventStream(client_fd, frame);
+}
+
 // jsonEscape returns the string with JSON special chars escaped (no surrounding quotes).
 pub fn jsonEscape(allocator: std.mem.Allocator, s: []const u8) ![]u8 {
     var out = try std.ArrayList(u8).initCapacity(allocator, 0);
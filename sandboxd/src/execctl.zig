@@ -424,12 +424,11 @@ pub fn handleAttach(allocator: std.mem.Allocator, client_fd: i32, query: []const
         return;
     }
 
-    const header = "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n";
-    _ = std.os.linux.write(client_fd, header.ptr, header.len);
+    main.writeEventStreamHeader(client_fd);
 
     var pid_frame_buf: [64]u8 = undefined;
     const pid_frame = std.fmt.bufPrint(&pid_frame_buf, "data: {{\"pid\":{d}}}\n\n", .{pid}) catch return;
-    _ = std.os.linux.write(client_fd, pid_frame.ptr, pid_frame.len);
+    main.writeEventStream(client_fd, pid_frame);
 
     // Replay the buffered output as SSE data frames.
     var buf: [BUFFER_MAX]u8 = undefined;
@@ -437,12 +436,12 @@ pub fn handleAttach(allocator: std.mem.Allocator, client_fd: i32, query: []const
     var line_buf: [BUFFER_MAX + 32]u8 = undefined;
     if (n > 0) {
         const line = std.fmt.bufPrint(&line_buf, "data: {s}\n\n", .{buf[0..n]}) catch {
-            _ = std.os.linux.write(client_fd, "data: {\"error\":\"attach output too large\"}\n\n".ptr, 37);
+            main.writeEventStream(client_fd, "data: {\"error\":\"attach output too large\"}\n\n");
             return;
         };
-        _ = std.os.linux.write(client_fd, line.ptr, line.len);
+        main.writeEventStream(client_fd, line);
     }
 
     // Done marker (the e2b layer reads the exit-code file for the code).
-    _ = std.os.linux.write(client_fd, "data: {\"done\":true}\n\n".ptr, 24);
+    main.writeEventStream(client_fd, "data: {\"done\":true}\n\n");
 }
@@ -8,8 +8,10 @@ const transcript = @import("transcript.zig");
 const events = @import("events.zig");
 const processes = @import("processes.zig");
 const execctl = @import("execctl.zig");
+const output = @import("output.zig");
 const watch = @import("watch.zig");
 const pty = @import("pty.zig");
+const auth = @import("auth.zig");
 
 pub fn main() !void {
     var gpa = std.heap.DebugAllocator(.{}){};
@@ -55,7 +57,9 @@ pub fn main() !void {
         return error.ListenFailed;
     }
 
+    auth.init();
     venv.ensureVenv();
+    output.startSweeper();
 
     std.log.info("sandboxd listening on :2024", .{});
 
@@ -88,6 +92,9 @@ fn handleConn(allocator: std.mem.Allocator, client_fd: i32) void {
     handleRequest(allocator, client_fd) catch |err| {
         std.log.err("request error: {s}", .{@errorName(err)});
     };
+    // An event stream ends with the MAC over everything it sent; a
+    // truncated stream therefore fails the gateway's check.
+    writeAll(client_fd, auth.streamTrailer());
 }
 
 /// maxRequestBodyBytes caps a single HTTP request body. Raised far above the
@@ -108,13 +115,17 @@ fn handleRequest(allocator: std.mem.Allocator, client_fd: i32) !void {
     const head = header_buf[0..@as(usize, @intCast(n0))];
 
     // Parse Content-Length so we know how much body to read.
+    var auth_header: []const u8 = "";
     var head_lines = std.mem.splitScalar(u8, head, '\n');
     _ = head_lines.next(); // request line
     while (head_lines.next()) |line| {
         const trimmed = std.mem.trim(u8, line, &std.ascii.whitespace);
+        if (trimmed.len == 0) break; // end of headers; the rest is body
         if (std.ascii.startsWithIgnoreCase(trimmed, "content-length:")) {
             const val = std.mem.trim(u8, trimmed["content-length:".len..], &std.ascii.whitespace);
             content_length = std.fmt.parseInt(usize, val, 10) catch 0;
+        } else if (std.ascii.startsWithIgnoreCase(trimmed, "x-sandboxd-auth-v2:")) {
+            auth_header = std.mem.trim(u8, trimmed["x-sandboxd-auth-v2:".len..], &std.ascii.whitespace);
         }
     }
     if (content_length > maxRequestBodyBytes) {
@@ -156,6 +167,12 @@ fn handleRequest(allocator: std.mem.Allocator, client_fd: i32) !void {
 
     const body = if (std.mem.indexOf(u8, request, "\r\n\r\n")) |i| request[i + 4 ..] else "";
 
+    // auth_header points into header_buf, which stays live for this call.
+    if (!auth.verify(auth_header, method, path_full, body)) {
+        try writeResponse(client_fd, "401 Unauthorized", "application/json", "{\"error\":\"unauthorized\"}");
+        return;
+    }
+
     if (std.mem.eql(u8, path, "/ready") and std.mem.eql(u8, method, "POST")) {
         try handleReady(client_fd);
     } else if (std.mem.eql(u8, path, "/exec") and std.mem.eql(u8, method, "POST")) {
@@ -166,6 +183,8 @@ fn handleRequest(allocator: std.mem.Allocator, client_fd: i32) !void {
         try workspace.handleReset(allocator, client_fd);
     } else if (std.mem.eql(u8, path, "/exec/background") and std.mem.eql(u8, method, "POST")) {
         try background.handleBgSubmit(allocator, client_fd, body);
+    } else if (std.mem.eql(u8, path, "/exec/output") and std.mem.eql(u8, method, "GET")) {
+        try output.handleOutput(allocator, client_fd, query);
     } else if (std.mem.startsWith(u8, path, "/exec/background/")) {
         const run_id = path["/exec/background/".len..];
         try background.handleBgPoll(allocator, client_fd, run_id);
@@ -285,12 +304,43 @@ fn handleEvents(allocator: std.mem.Allocator, client_fd: i32, query: []const u8)
 pub fn writeResponse(client_fd: i32, status: []const u8, content_type: []const u8, body: []const u8) !void {
     var header_buf: [4096]u8 = undefined;
     const header = try std.fmt.bufPrint(&header_buf,
-        "HTTP/1.1 {s}\r\nContent-Type: {s}\r\nContent-Length: {d}\r\nConnection: close\r\n\r\n",
-        .{ status, content_type, body.len });
+        "HTTP/1.1 {s}\r\nContent-Type: {s}\r\nContent-Length: {d}\r\nConnection: close\r\n{s}{s}\r\n",
+        .{ status, content_type, body.len, auth.proofHeader(), auth.bodyMacHeader(body) });
     _ = std.os.linux.write(client_fd, header.ptr, header.len);
     _ = std.os.linux.write(client_fd, body.ptr, body.len);
 }
 
+/// writeEventStreamHeader starts an SSE response (exec/stream, exec/attach,
+/// pty/stream). Its body must go through writeEventStream.
+pub fn writeEventStreamHeader(client_fd: i32) void {
+    var header_buf: [512]u8 = undefined;
+    const header = std.fmt.bufPrint(&header_buf,
+        "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n{s}\r\n",
+        .{auth.proofHeader()}) catch return;
+    _ = std.os.linux.write(client_fd, header.ptr, header.len);
+    auth.streamBegin();
+}
+
+/// writeEventStream sends SSE bytes, folding them into the stream MAC.
+pub fn writeEventStream(client_fd: i32, data: []const u8) void {
+    auth.streamUpdate(data);
+    writeAll(client_fd, data);
+}
+
+fn writeAll(fd: i32, data: []const u8) void {
+    var off: usize = 0;
+    while (off < data.len) {
+        const n = std.os.linux.write(fd, data.ptr + off, data.len - off);
+        switch (std.posix.errno(n)) {
+            .SUCCESS => {},
+            .INTR => continue,
+            else => return,
+        }
+        if (n == 0) return;
+        off += n;
+    }
+}
+
 fn setupSignals() void {
     // Ignore SIGCHLD to auto-reap zombies as PID 1
     const sa = std.os.linux.Sigaction{
@@ -0,0 +1,247 @@
+const std = @import("std");
+const main = @import("main.zig");
+
+/// Full run output (spill-to-disk). A sync exec response carries at most
+/// 1 MiB per stream; when either stream overflows and the gateway supplied a
+/// run_id, both streams are written in full to <RUN_DIR>/<run_id>/{stdout,
+/// stderr} — the layout background runs already use — and GET /exec/output
+/// pages either file by absolute byte offset.
+pub const RUN_DIR = "/workspace/.k8e_bg";
+/// Default and hard cap for one /exec/output window (bounds per-request memory).
+pub const default_window_bytes: usize = 256 * 1024;
+pub const max_window_bytes: usize = 1024 * 1024;
+/// max_spill_bytes caps one spilled stream file; output past it is drained
+/// and dropped, as all overflow was before spilling existed.
+pub const max_spill_bytes: usize = 256 * 1024 * 1024;
+/// retention_secs is how long a finished run directory is kept; sweep
+/// removes older ones. /workspace/reset removes all of them at session end.
+pub const retention_secs: i64 = 24 * 60 * 60;
+const sweep_interval_secs = 10 * 60;
+
+/// Spill names where an overflowing exec writes its full output.
+pub const Spill = struct {
+    dir: []const u8,
+    run_id: []const u8,
+};
+
+/// validRunId accepts gateway-generated run ids only: they become a path
+/// component, so separators and leading dots are rejected.
+pub fn validRunId(run_id: []const u8) bool {
+    if (run_id.len == 0 or run_id.len > 200 or run_id[0] == '.') return false;
+    for (run_id) |c| {
+        if (!(std.ascii.isAlphanumeric(c) or c == '-' or c == '_' or c == '.')) return false;
+    }
+    return true;
+}
+
+fn validStream(stream: []const u8) bool {
+    return std.mem.eql(u8, stream, "stdout") or std.mem.eql(u8, stream, "stderr");
+}
+
+fn streamPath(buf: []u8, dir: []const u8, run_id: []const u8, stream: []const u8) ?[:0]u8 {
+    return std.fmt.bufPrintZ(buf, "{s}/{s}/{s}", .{ dir, run_id, stream }) catch null;
+}
+
+/// openSpill creates the run directory and opens stream's file for writing
+/// (truncating). Returns null on any failure; spilling is best-effort.
+pub fn openSpill(spill: Spill, stream: []const u8) ?i32 {
+    var dir_buf: [512]u8 = undefined;
+    const dir = std.fmt.bufPrintZ(&dir_buf, "{s}", .{spill.dir}) catch return null;
+    _ = std.os.linux.mkdir(dir.ptr, 0o755);
+    var run_buf: [512]u8 = undefined;
+    const run_dir = std.fmt.bufPrintZ(&run_buf, "{s}/{s}", .{ spill.dir, spill.run_id }) catch return null;
+    _ = std.os.linux.mkdir(run_dir.ptr, 0o755);
+
+    var path_buf: [600]u8 = undefined;
+    const path = streamPath(&path_buf, spill.dir, spill.run_id, stream) orelse return null;
+    const fd_raw = std.os.linux.open(path.ptr, std.os.linux.O{ .CREAT = true, .ACCMODE = .WRONLY, .TRUNC = true }, 0o644);
+    const fd: isize = @bitCast(fd_raw);
+    if (fd < 0) return null;
+    return @intCast(fd);
+}
+
+/// startSweeper removes expired run directories every sweep_interval_secs.
+pub fn startSweeper() void {
+    const thread = std.Thread.spawn(.{}, sweepLoop, .{}) catch return;
+    thread.detach();
+}
+
+fn sweepLoop() void {
+    while (true) {
+        const ts = std.os.linux.timespec{ .sec = sweep_interval_secs, .nsec = 0 };
+        _ = std.os.linux.nanosleep(&ts, null);
+        var now: std.os.linux.timespec = undefined;
+        _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.REALTIME, &now);
+        sweep(RUN_DIR, now.sec);
+    }
+}
+
+/// sweep deletes run directories under dir last changed more than
+/// retention_secs before now. A background run still in progress (a pid
+/// file but no exit_code yet) is kept however old it is. The directory's
+/// mtime moves when exit_code is written, so a finished background run is
+/// kept for retention_secs after it ends.
+pub fn sweep(dir_path: []const u8, now: i64) void {
+    var dir = std.fs.openDirAbsolute(dir_path, .{ .iterate = true }) catch return;
+    defer dir.close();
+    var it = dir.iterate();
+    while (it.next() catch null) |entry| {
+        if (entry.kind != .directory or !validRunId(entry.name)) continue;
+        var run = dir.openDir(entry.name, .{}) catch continue;
+        const st = run.stat() catch {
+            run.close();
+            continue;
+        };
+        const has_pid = if (run.access("pid", .{})) |_| true else |_| false;
+        const has_exit = if (run.access("exit_code", .{})) |_| true else |_| false;
+        const running = has_pid and !has_exit;
+        run.close();
+        const mtime: i64 = @intCast(@divFloor(st.mtime, std.time.ns_per_s));
+        if (running or now - mtime <= retention_secs) continue;
+        dir.deleteTree(entry.name) catch |err| {
+            std.log.warn("run dir {s}: {s}", .{ entry.name, @errorName(err) });
+        };
+    }
+}
+
+/// writeAll writes data to fd, retrying short writes; errors end it silently.
+pub fn writeAll(fd: i32, data: []const u8) void {
+    var off: usize = 0;
+    while (off < data.len) {
+        const n: isize = @bitCast(std.os.linux.write(fd, data.ptr + off, data.len - off));
+        if (n <= 0) return;
+        off += @intCast(n);
+    }
+}
+
+/// writeStream spills a stream that fit in memory, so a run whose other
+/// stream overflowed has both files on disk.
+pub fn writeStream(spill: Spill, stream: []const u8, data: []const u8) void {
+    const fd = openSpill(spill, stream) orelse return;
+    defer _ = std.os.linux.close(fd);
+    writeAll(fd, data);
+}
+
+/// Window is a byte-exact slice of a run's stdout or stderr file. The caller
+/// releases it with freeWindow.
+pub const Window = struct {
+    data: []u8,
+    alloc: []u8, // allocation backing data (longer after a short read)
+    offset: i64, // absolute offset data starts at (requested offset clamped to size)
+    next_offset: i64, // offset for the next window
+    size: i64, // current file size
+    eof: bool, // next_offset reached the current end of the file
+};
+
+/// readWindowAt reads up to limit bytes of dir/run_id/stream from offset.
+/// limit 0 means default_window_bytes; larger limits are clamped to
+/// max_window_bytes. Returns null when the file does not exist.
+pub fn readWindowAt(allocator: std.mem.Allocator, dir: []const u8, run_id: []const u8, stream: []const u8, offset: i64, limit: usize) ?Window {
+    var path_buf: [600]u8 = undefined;
+    const path = streamPath(&path_buf, dir, run_id, stream) orelse return null;
+    const fd_raw = std.os.linux.open(path.ptr, std.os.linux.O{ .ACCMODE = .RDONLY }, 0);
+    const fd: isize = @bitCast(fd_raw);
+    if (fd < 0) return null;
+    defer _ = std.os.linux.close(@intCast(fd));
+
+    const size_rc: isize = @bitCast(std.os.linux.lseek(@intCast(fd), 0, std.os.linux.SEEK.END));
+    if (size_rc < 0) return null;
+    const size: i64 = @intCast(size_rc);
+
+    const want_limit: usize = if (limit == 0) default_window_bytes else @min(limit, max_window_bytes);
+    const start: i64 = if (offset < 0) 0 else @min(offset, size);
+    const want: usize = @min(want_limit, @as(usize, @intCast(size - start)));
+
+    const buf = allocator.alloc(u8, want) catch return null;
+    var got: usize = 0;
+    while (got < want) {
+        const n: isize = @bitCast(std.os.linux.pread(@intCast(fd), buf.ptr + got, want - got, @intCast(start + @as(i64, @intCast(got)))));
+        if (n <= 0) break;
+        got += @intCast(n);
+    }
+    const next = start + @as(i64, @intCast(got));
+    return Window{
+        .data = buf[0..got],
+        .alloc = buf,
+        .offset = start,
+        .next_offset = next,
+        .size = size,
+        .eof = next >= size,
+    };
+}
+
+pub fn freeWindow(allocator: std.mem.Allocator, w: Window) void {
+    allocator.free(w.alloc);
+}
+
+/// fileSize returns the size of dir/run_id/stream, or 0 when it is missing.
+pub fn fileSize(dir: []const u8, run_id: []const u8, stream: []const u8) usize {
+    var path_buf: [600]u8 = undefined;
+    const path = streamPath(&path_buf, dir, run_id, stream) orelse return 0;
+    const fd_raw = std.os.linux.open(path.ptr, std.os.linux.O{ .ACCMODE = .RDONLY }, 0);
+    const fd: isize = @bitCast(fd_raw);
+    if (fd < 0) return 0;
+    defer _ = std.os.linux.close(@intCast(fd));
+    const size_rc: isize = @bitCast(std.os.linux.lseek(@intCast(fd), 0, std.os.linux.SEEK.END));
+    if (size_rc < 0) return 0;
+    return @intCast(size_rc);
+}
+
+fn exists(dir: []const u8, run_id: []const u8, name: []const u8) bool {
+    var path_buf: [600]u8 = undefined;
+    const path = streamPath(&path_buf, dir, run_id, name) orelse return false;
+    const fd_raw = std.os.linux.open(path.ptr, std.os.linux.O{ .ACCMODE = .RDONLY }, 0);
+    const fd: isize = @bitCast(fd_raw);
+    if (fd < 0) return false;
+    _ = std.os.linux.close(@intCast(fd));
+    return true;
+}
+
+/// handleOutput serves GET /exec/output?run_id=<id>&stream=stdout|stderr&offset=<n>&limit=<n>.
+/// data is base64 so binary output survives; running is true while a
+/// background run has not recorded its exit code (the file may still grow).
+pub fn handleOutput(allocator: std.mem.Allocator, client_fd: i32, query: []const u8) !void {
+    var run_id: []const u8 = "";
+    var stream: []const u8 = "stdout";
+    var offset: i64 = 0;
+    var limit: usize = 0;
+
+    var params = std.mem.splitScalar(u8, query, '&');
+    while (params.next()) |pair| {
+        var kv = std.mem.splitScalar(u8, pair, '=');
+        const key = kv.next() orelse continue;
+        const val = kv.next() orelse "";
+        if (std.mem.eql(u8, key, "run_id")) {
+            run_id = val;
+        } else if (std.mem.eql(u8, key, "stream")) {
+            stream = val;
+        } else if (std.mem.eql(u8, key, "offset")) {
+            offset = std.fmt.parseInt(i64, val, 10) catch 0;
+        } else if (std.mem.eql(u8, key, "limit")) {
+            limit = std.fmt.parseInt(usize, val, 10) catch 0;
+        }
+    }
+
+    if (!validRunId(run_id) or !validStream(stream)) {
+        try main.writeResponse(client_fd, "400 Bad Request", "application/json", "{\"error\":\"valid run_id and stream (stdout|stderr) required\"}");
+        return;
+    }
+
+    const w = readWindowAt(allocator, RUN_DIR, run_id, stream, offset, limit) orelse {
+        try main.writeResponse(client_fd, "404 Not Found", "application/json", "{\"error\":\"no output\"}");
+        return;
+    };
+    defer freeWindow(allocator, w);
+
+    const running = exists(RUN_DIR, run_id, "pid") and !exists(RUN_DIR, run_id, "exit_code");
+
+    const encoded = try allocator.alloc(u8, std.base64.standard.Encoder.calcSize(w.data.len));
+    defer allocator.free(encoded);
+    _ = std.base64.standard.Encoder.encode(encoded, w.data);
+
+    const resp = try std.fmt.allocPrint(allocator,
+        "{{\"run_id\":\"{s}\",\"stream\":\"{s}\",\"data\":\"{s}\",\"offset\":{d},\"next_offset\":{d},\"size\":{d},\"eof\":{s},\"running\":{s}}}",
+        .{ run_id, stream, encoded, w.offset, w.next_offset, w.size, if (w.eof) "true" else "false", if (running) "true" else "false" });
+    defer allocator.free(resp);
+    try main.writeResponse(client_fd, "200 OK", "application/json", resp);
+}
@@ -0,0 +1,117 @@
+const std = @import("std");
+const builtin = @import("builtin");
+const output = @import("output.zig");
+const exec = @import("exec.zig");
+
+// Spill files are written and paged with raw Linux syscalls (open/pread);
+// like transcript_test these tests are Linux-only and run in CI.
+
+const TEST_BASE = "/tmp/k8e-output-test";
+
+fn teardownRun(run_id: []const u8) void {
+    var buf: [256]u8 = undefined;
+    for ([_][]const u8{ "stdout", "stderr" }) |name| {
+        const p = std.fmt.bufPrintZ(&buf, "{s}/{s}/{s}", .{ TEST_BASE, run_id, name }) catch continue;
+        _ = std.os.linux.unlink(p.ptr);
+    }
+    const d = std.fmt.bufPrintZ(&buf, "{s}/{s}", .{ TEST_BASE, run_id }) catch return;
+    _ = std.os.linux.rmdir(d.ptr);
+    _ = std.os.linux.rmdir(TEST_BASE);
+}
+
+test "validRunId rejects path components" {
+    try std.testing.expect(output.validRunId("sess-1-exec-1700000000"));
+    try std.testing.expect(output.validRunId("run_1.a"));
+    try std.testing.expect(!output.validRunId(""));
+    try std.testing.expect(!output.validRunId(".."));
+    try std.testing.expect(!output.validRunId(".hidden"));
+    try std.testing.expect(!output.validRunId("a/b"));
+    try std.testing.expect(!output.validRunId("a b"));
+}
+
+test "writeStream then readWindowAt pages by offset" {
+    if (builtin.os.tag != .linux) return error.SkipZigTest;
+    var gpa = std.heap.DebugAllocator(.{}){};
+    const allocator = gpa.allocator();
+    defer _ = gpa.deinit();
+
+    const sp = output.Spill{ .dir = TEST_BASE, .run_id = "run-page" };
+    output.writeStream(sp, "stdout", "0123456789");
+    defer teardownRun("run-page");
+
+    const w1 = output.readWindowAt(allocator, TEST_BASE, "run-page", "stdout", 0, 4) orelse return error.TestUnexpectedResult;
+    defer output.freeWindow(allocator, w1);
+    try std.testing.expectEqualStrings("0123", w1.data);
+    try std.testing.expectEqual(@as(i64, 4), w1.next_offset);
+    try std.testing.expectEqual(@as(i64, 10), w1.size);
+    try std.testing.expect(!w1.eof);
+
+    const w2 = output.readWindowAt(allocator, TEST_BASE, "run-page", "stdout", w1.next_offset, 100) orelse return error.TestUnexpectedResult;
+    defer output.freeWindow(allocator, w2);
+    try std.testing.expectEqualStrings("456789", w2.data);
+    try std.testing.expect(w2.eof);
+
+    // Offsets past the end clamp to the size and return an empty, eof window.
+    const w3 = output.readWindowAt(allocator, TEST_BASE, "run-page", "stdout", 99, 0) orelse return error.TestUnexpectedResult;
+    defer output.freeWindow(allocator, w3);
+    try std.testing.expectEqual(@as(usize, 0), w3.data.len);
+    try std.testing.expectEqual(@as(i64, 10), w3.offset);
+    try std.testing.expect(w3.eof);
+
+    try std.testing.expect(output.readWindowAt(allocator, TEST_BASE, "run-page", "stderr", 0, 0) == null);
+    try std.testing.expectEqual(@as(usize, 10), output.fileSize(TEST_BASE, "run-page", "stdout"));
+}
+
+test "runCommandSpill writes both streams in full on overflow" {
+    if (builtin.os.tag != .linux) return error.SkipZigTest;
+    const allocator = std.testing.allocator;
+
+    const sp = output.Spill{ .dir = TEST_BASE, .run_id = "run-spill" };
+    defer teardownRun("run-spill");
+    // 1.5 MiB of stdout overflows the 1 MiB capture cap; stderr fits.
+    const result = try exec.runCommandSpill(allocator, "head -c 1572864 /dev/zero; echo err >&2", "/tmp", .{ .null = {} }, sp);
+    defer result.deinit(allocator);
+
+    try std.testing.expect(result.truncated);
+    try std.testing.expect(result.spilled);
+    try std.testing.expectEqual(@as(usize, 1024 * 1024), result.stdout.len);
+    try std.testing.expectEqual(@as(usize, 1572864), result.stdout_bytes);
+    try std.testing.expectEqual(@as(usize, 1572864), output.fileSize(TEST_BASE, "run-spill", "stdout"));
+    try std.testing.expectEqual(@as(usize, 4), output.fileSize(TEST_BASE, "run-spill", "stderr"));
+}
+
+test "runCommandSpill leaves no files when output fits" {
+    if (builtin.os.tag != .linux) return error.SkipZigTest;
+    const allocator = std.testing.allocator;
+
+    const sp = output.Spill{ .dir = TEST_BASE, .run_id = "run-small" };
+    defer teardownRun("run-small");
+    const result = try exec.runCommandSpill(allocator, "echo hello", "/tmp", .{ .null = {} }, sp);
+    defer result.deinit(allocator);
+
+    try std.testing.expect(!result.spilled);
+    try std.testing.expectEqual(@as(usize, 6), result.stdout_bytes);
+    try std.testing.expectEqual(@as(usize, 0), output.fileSize(TEST_BASE, "run-small", "stdout"));
+}
+
+test "sweep removes expired run dirs but keeps running ones" {
+    if (builtin.os.tag != .linux) return error.SkipZigTest;
+    std.fs.deleteTreeAbsolute(TEST_BASE) catch {};
+    defer std.fs.deleteTreeAbsolute(TEST_BASE) catch {};
+    try std.fs.cwd().makePath(TEST_BASE ++ "/run-done");
+    try std.fs.cwd().makePath(TEST_BASE ++ "/run-live");
+    output.writeStream(.{ .dir = TEST_BASE, .run_id = "run-done" }, "stdout", "x");
+    try std.fs.cwd().writeFile(.{ .sub_path = TEST_BASE ++ "/run-done/exit_code", .data = "0" });
+    try std.fs.cwd().writeFile(.{ .sub_path = TEST_BASE ++ "/run-live/pid", .data = "42" });
+
+    var now: std.os.linux.timespec = undefined;
+    _ = std.os.linux.clock_gettime(std.os.linux.CLOCK.REALTIME, &now);
+
+    // Within the retention period nothing goes.
+    output.sweep(TEST_BASE, now.sec);
+    try std.testing.expectEqual(@as(usize, 1), output.fileSize(TEST_BASE, "run-done", "stdout"));
+
+    output.sweep(TEST_BASE, now.sec + output.retention_secs + 60);
+    try std.testing.expectError(error.FileNotFound, std.fs.cwd().access(TEST_BASE ++ "/run-done", .{}));
+    try std.fs.cwd().access(TEST_BASE ++ "/run-live/pid", .{});
+}
@@ -559,9 +559,9 @@ fn writeDataFrame(allocator: std.mem.Allocator, client_fd: i32, data: []const u8
     defer allocator.free(encoded);
     _ = std.base64.standard.Encoder.encode(encoded, data);
 
-    _ = std.os.linux.write(client_fd, "data: ".ptr, "data: ".len);
-    _ = std.os.linux.write(client_fd, encoded.ptr, encoded.len);
-    _ = std.os.linux.write(client_fd, "\n\n".ptr, "\n\n".len);
+    main.writeEventStream(client_fd, "data: ");
+    main.writeEventStream(client_fd, encoded);
+    main.writeEventStream(client_fd, "\n\n");
 }
 
 pub fn handleStream(allocator: std.mem.Allocator, client_fd: i32, query: []const u8) !void {
@@ -579,12 +579,11 @@ pub fn handleStream(allocator: std.mem.Allocator, client_fd: i32, query: []const
     const pid = t0.pid;
     unlockTable();
 
-    const header = "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n";
-    _ = std.os.linux.write(client_fd, header.ptr, header.len);
+    main.writeEventStreamHeader(client_fd);
 
     var pid_frame_buf: [64]u8 = undefined;
     const pid_frame = std.fmt.bufPrint(&pid_frame_buf, "data: {{\"pid\":{d}}}\n\n", .{pid}) catch return;
-    _ = std.os.linux.write(client_fd, pid_frame.ptr, pid_frame.len);
+    main.writeEventStream(client_fd, pid_frame);
 
     var sent: u64 = 0;
     var truncated = false;
@@ -616,8 +615,8 @@ pub fn handleStream(allocator: std.mem.Allocator, client_fd: i32, query: []const
     const trunc_lit: []const u8 = if (truncated) "true" else "false";
     var exit_buf: [128]u8 = undefined;
     const exit_frame = std.fmt.bufPrint(&exit_buf, "data: {{\"exit\":{d},\"signal\":\"\",\"truncated\":{s}}}\n\n", .{ exit_code, trunc_lit }) catch {
-        _ = std.os.linux.write(client_fd, "data: {\"exit\":0,\"signal\":\"\"}\n\n".ptr, 28);
+        main.writeEventStream(client_fd, "data: {\"exit\":0,\"signal\":\"\"}\n\n");
         return;
     };
-    _ = std.os.linux.write(client_fd, exit_frame.ptr, exit_frame.len);
+    main.writeEventStream(client_fd, exit_frame);
 }